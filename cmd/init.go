@@ -11,16 +11,23 @@ import (
 	"github.com/hellofresh/stats-go/bucket"
 	"github.com/hellofresh/stats-go/client"
 	"github.com/hellofresh/stats-go/hooks"
+	openzipkin "github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/reporter"
+	zipkinHTTP "github.com/openzipkin/zipkin-go/reporter/http"
 	log "github.com/sirupsen/logrus"
 	"go.opencensus.io/exporter/jaeger"
-	"go.opencensus.io/exporter/prometheus"
+	"go.opencensus.io/exporter/zipkin"
 	"go.opencensus.io/stats/view"
 	"go.opencensus.io/trace"
 )
 
 var (
-	globalConfig *config.Specification
-	statsClient  client.Client
+	globalConfig   *config.Specification
+	statsClient    client.Client
+	jaegerExporter *jaeger.Exporter
+	otlpExporter   *obs.OTLPExporter
+	ddExporter     *obs.DatadogExporter
+	zipkinReporter reporter.Reporter
 )
 
 func initConfig() {
@@ -120,30 +127,57 @@ func initStatsExporter() {
 	}
 }
 
+func initStatsDEmitter() {
+	if globalConfig.Stats.StatsD.Addr == "" {
+		return
+	}
+
+	emitter, err := obs.NewStatsD(globalConfig.Stats.StatsD.Addr, globalConfig.Stats.StatsD.Prefix, globalConfig.Stats.StatsD.SampleRate)
+	if err != nil {
+		log.WithError(err).WithField("stats.statsd.addr", globalConfig.Stats.StatsD.Addr).
+			Error("Failed initialising StatsD emitter")
+		return
+	}
+
+	obs.StatsDEmitter = emitter
+}
+
 func initPrometheusExporter() (err error) {
-	obs.PrometheusExporter, err = prometheus.NewExporter(prometheus.Options{})
+	obs.PrometheusExporter, obs.PrometheusHandler, err = obs.NewPrometheusExporter(globalConfig.Stats.PrometheusExemplars)
 	if err != nil {
 		log.WithError(err).Warn("Failed to create prometheus exporter")
 	} else {
 		view.RegisterExporter(obs.PrometheusExporter)
 	}
+
+	// the latency is recorded before the span of the proxied request starts, only the API spans are
+	// started before it
+	if globalConfig.Stats.PrometheusExemplars && !globalConfig.Tracing.PluginSpans {
+		log.Warn("The prometheus exemplars are only recorded with the plugin spans of tracing enabled")
+	}
 	return err
 }
 
 func initTracingExporter() {
+	initTracingPropagation()
+
 	var err error
 	logger := log.WithField("tracing.exporter", globalConfig.Tracing.Exporter)
 
 	switch globalConfig.Tracing.Exporter {
 	case obs.AzureMonitor:
-	case obs.Datadog:
 	case obs.Stackdriver:
-	case obs.Zipkin:
 		logger.Warn("Not implemented!")
 		return
 	case obs.Jaeger:
 		err = initJaegerExporter()
 		break
+	case obs.Datadog:
+		err = initDatadogExporter()
+	case obs.OTel:
+		err = initOTelExporter()
+	case obs.Zipkin:
+		err = initZipkinExporter()
 	default:
 		logger.Info("Invalid or no tracing exporter was specified")
 		return
@@ -172,18 +206,109 @@ func initTracingExporter() {
 		return
 	}
 
+	traceConfig.DefaultSampler = obs.NewCountingSampler(traceConfig.DefaultSampler)
 	trace.ApplyConfig(traceConfig)
 }
 
+// initTracingPropagation sets the propagation format of the trace context, whatever the exporter
+func initTracingPropagation() {
+	propagation, err := obs.NewPropagation(globalConfig.Tracing.PropagationFormat)
+	if err != nil {
+		log.WithError(err).Warn("Invalid tracing propagation format, the trace context is propagated with the B3 headers")
+		return
+	}
+	obs.Propagation = propagation
+}
+
 func initJaegerExporter() (err error) {
-	jaegerExporter, err := jaeger.NewExporter(jaeger.Options{
+	jaegerExporter, err = jaeger.NewExporter(jaeger.Options{
 		AgentEndpoint: globalConfig.Tracing.JaegerTracing.SamplingServerURL,
 		ServiceName:   globalConfig.Tracing.ServiceName,
+		OnError: func(err error) {
+			log.WithError(err).Warn("Could not send the spans to jaeger")
+			obs.RecordExportError(obs.Jaeger)
+		},
 	})
 	if err != nil {
 		log.WithError(err).Warn("Failed to create jaeger exporter")
 	} else {
-		trace.RegisterExporter(jaegerExporter)
+		trace.RegisterExporter(obs.NewCountingExporter(obs.Jaeger, jaegerExporter))
+	}
+	return err
+}
+
+func initOTelExporter() (err error) {
+	otlpExporter, err = obs.NewOTLPExporter(obs.OTLPOptions{
+		Endpoint:    globalConfig.Tracing.OTelTracing.Endpoint,
+		Headers:     globalConfig.Tracing.OTelTracing.Headers,
+		ServiceName: globalConfig.Tracing.ServiceName,
+		Timeout:     globalConfig.Tracing.OTelTracing.Timeout,
+	})
+	if err != nil {
+		log.WithError(err).Warn("Failed to create OpenTelemetry exporter")
+	} else {
+		trace.RegisterExporter(obs.NewCountingExporter(obs.OTel, otlpExporter))
+	}
+	return err
+}
+
+func initDatadogExporter() (err error) {
+	cfg := globalConfig.Tracing.DatadogTracing
+	ddExporter, err = obs.NewDatadogExporter(obs.DatadogOptions{
+		AgentAddr:   cfg.AgentAddr,
+		Service:     globalConfig.Tracing.ServiceName,
+		Env:         cfg.Env,
+		Version:     cfg.Version,
+		Tags:        cfg.Tags,
+		APIServices: cfg.APIServices,
+	})
+	if err != nil {
+		log.WithError(err).Warn("Failed to create Datadog exporter")
+	} else {
+		trace.RegisterExporter(obs.NewCountingExporter(obs.Datadog, ddExporter))
 	}
 	return err
 }
+
+func initZipkinExporter() error {
+	cfg := globalConfig.Tracing.ZipkinTracing
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = obs.DefaultZipkinEndpoint
+	}
+
+	localEndpoint, err := openzipkin.NewEndpoint(globalConfig.Tracing.ServiceName, cfg.LocalEndpoint)
+	if err != nil {
+		log.WithError(err).Warn("Failed to create zipkin local endpoint")
+		return err
+	}
+
+	zipkinReporter = zipkinHTTP.NewReporter(cfg.Endpoint)
+	trace.RegisterExporter(obs.NewCountingExporter(obs.Zipkin, zipkin.NewExporter(zipkinReporter, localEndpoint)))
+	if b3, ok := obs.Propagation.(*obs.B3Format); ok {
+		b3.SingleHeader = cfg.B3SingleHeader
+	}
+	return nil
+}
+
+// flushExporters sends the buffered spans and metrics before exiting
+func flushExporters() {
+	if jaegerExporter != nil {
+		jaegerExporter.Flush()
+	}
+
+	if otlpExporter != nil {
+		otlpExporter.Close()
+	}
+
+	if ddExporter != nil {
+		ddExporter.Close()
+	}
+
+	if zipkinReporter != nil {
+		zipkinReporter.Close()
+	}
+
+	if obs.StatsDEmitter != nil {
+		obs.StatsDEmitter.Close()
+	}
+}
@@ -10,16 +10,28 @@ import (
 	"github.com/spf13/cobra"
 
 	// this is needed to call the init function on each plugin
+	_ "github.com/hellofresh/janus/pkg/plugin/abtest"
 	_ "github.com/hellofresh/janus/pkg/plugin/basic"
 	_ "github.com/hellofresh/janus/pkg/plugin/bodylmt"
 	_ "github.com/hellofresh/janus/pkg/plugin/cb"
+	_ "github.com/hellofresh/janus/pkg/plugin/clientcert"
+	_ "github.com/hellofresh/janus/pkg/plugin/coalescing"
 	_ "github.com/hellofresh/janus/pkg/plugin/compression"
+	_ "github.com/hellofresh/janus/pkg/plugin/concurrency"
 	_ "github.com/hellofresh/janus/pkg/plugin/cors"
+	_ "github.com/hellofresh/janus/pkg/plugin/geo"
+	_ "github.com/hellofresh/janus/pkg/plugin/idempotency"
+	_ "github.com/hellofresh/janus/pkg/plugin/negotiation"
 	_ "github.com/hellofresh/janus/pkg/plugin/oauth2"
+	_ "github.com/hellofresh/janus/pkg/plugin/quota"
 	_ "github.com/hellofresh/janus/pkg/plugin/rate"
 	_ "github.com/hellofresh/janus/pkg/plugin/requesttransformer"
 	_ "github.com/hellofresh/janus/pkg/plugin/responsetransformer"
 	_ "github.com/hellofresh/janus/pkg/plugin/retry"
+	_ "github.com/hellofresh/janus/pkg/plugin/shadow"
+	_ "github.com/hellofresh/janus/pkg/plugin/statusmap"
+	_ "github.com/hellofresh/janus/pkg/plugin/transcoding"
+	_ "github.com/hellofresh/janus/pkg/plugin/useragent"
 
 	// dynamically registered auth providers
 	_ "github.com/hellofresh/janus/pkg/jwt/basic"
@@ -44,8 +56,8 @@ func NewServerStartCmd(ctx context.Context) *cobra.Command {
 		},
 	}
 
-	cmd.PersistentFlags().BoolVarP(&opts.profilingEnabled, "profiling-enabled", "", false, "Enable profiler, will be available on API port at /debug/pprof path")
-	cmd.PersistentFlags().BoolVarP(&opts.profilingPublic, "profiling-public", "", false, "Allow accessing profiler endpoint w/out authentication")
+	cmd.PersistentFlags().BoolVarP(&opts.profilingEnabled, "profiling-enabled", "", false, "Enable profiler, will be available on API port at /debug/pprof path, same as web.profiling.enabled")
+	cmd.PersistentFlags().BoolVarP(&opts.profilingPublic, "profiling-public", "", false, "Allow accessing profiler endpoint w/out authentication, same as web.profiling.public")
 
 	return cmd
 }
@@ -58,10 +70,12 @@ func RunServerStart(ctx context.Context, opts *ServerStartOptions) error {
 	initLog()
 	initStatsClient()
 	initStatsExporter()
+	initStatsDEmitter()
 	initTracingExporter()
 
 	defer statsClient.Close()
 	defer globalConfig.Log.Flush()
+	defer flushExporters()
 
 	repo, err := api.BuildRepository(globalConfig.Database.DSN, globalConfig.Cluster.UpdateFrequency)
 	if err != nil {
@@ -80,8 +94,8 @@ func RunServerStart(ctx context.Context, opts *ServerStartOptions) error {
 	svr.StartWithContext(ctx)
 	defer svr.Close()
 
-	svr.Wait()
+	err = svr.Wait()
 	log.Info("Shutting down")
 
-	return nil
+	return err
 }
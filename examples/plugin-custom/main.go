@@ -0,0 +1,20 @@
+// Janus built with the served by plugin, the plugins maintained out of the Janus tree are compiled
+// in by importing them next to the Janus commands.
+package main
+
+import (
+	"os"
+
+	"github.com/hellofresh/janus/cmd"
+	_ "github.com/hellofresh/janus/examples/plugin-custom/servedby"
+	log "github.com/sirupsen/logrus"
+)
+
+func main() {
+	rootCmd := cmd.NewRootCmd()
+
+	if err := rootCmd.Execute(); err != nil {
+		log.WithError(err).Error(err.Error())
+		os.Exit(1)
+	}
+}
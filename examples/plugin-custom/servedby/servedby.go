@@ -0,0 +1,37 @@
+// Package servedby is an example of a plugin maintained out of the Janus tree. It sets the
+// X-Served-By header of the responses to the configured name.
+package servedby
+
+import (
+	"net/http"
+
+	"github.com/hellofresh/janus/pkg/plugin"
+	"github.com/pkg/errors"
+)
+
+// Config represents the served by configuration
+type Config struct {
+	Name string `json:"name"`
+}
+
+func init() {
+	plugin.RegisterPlugin("served_by", plugin.MiddlewareFactory(NewMiddleware))
+}
+
+// NewMiddleware creates the served by middleware from the raw plugin config
+func NewMiddleware(rawConfig plugin.Config) (func(http.Handler) http.Handler, error) {
+	var config Config
+	if err := plugin.Decode(rawConfig, &config); err != nil {
+		return nil, err
+	}
+	if config.Name == "" {
+		return nil, errors.New("served by name is not set")
+	}
+
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Served-By", config.Name)
+			handler.ServeHTTP(w, r)
+		})
+	}, nil
+}
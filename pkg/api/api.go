@@ -1,11 +1,13 @@
 package api
 
 import (
-	"encoding/json"
 	"reflect"
 
 	"github.com/asaskevich/govalidator"
+	"github.com/globalsign/mgo/bson"
+	"github.com/hellofresh/janus/pkg/errors"
 	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/hellofresh/janus/pkg/sampling"
 )
 
 // Plugin represents the plugins for an API
@@ -17,17 +19,78 @@ type Plugin struct {
 
 // Definition represents an API that you want to proxy
 type Definition struct {
+	// Version is the schema version of the definition, the definitions of the older versions are migrated
+	// to CurrentVersion when they are loaded
+	Version     int               `bson:"version" json:"version"`
 	Name        string            `bson:"name" json:"name" valid:"required~name is required,matches(^[A-Za-z0-9]+(?:-[A-Za-z0-9]+)*$)~name cannot contain non-URL friendly characters"`
 	Active      bool              `bson:"active" json:"active"`
 	Proxy       *proxy.Definition `bson:"proxy" json:"proxy" valid:"required"`
 	Plugins     []Plugin          `bson:"plugins" json:"plugins"`
 	HealthCheck HealthCheck       `bson:"health_check" json:"health_check"`
+	Maintenance Maintenance       `bson:"maintenance" json:"maintenance"`
+	SlowLog     SlowLog           `bson:"slow_log" json:"slow_log"`
+	AccessLog   AccessLog         `bson:"access_log" json:"access_log"`
+	Tracing     Tracing           `bson:"tracing" json:"tracing"`
+	// RequestTimeout bounds the time serving a request, it overrides the global request timeout when it is set
+	RequestTimeout proxy.Duration `bson:"request_timeout" json:"request_timeout,omitempty"`
+	// ErrorTemplates override the global error templates when they are set
+	ErrorTemplates []errors.Template `bson:"error_templates" json:"error_templates,omitempty"`
+}
+
+// SlowLog represents the slow request log settings of an API
+type SlowLog struct {
+	// Threshold overrides the global slow log threshold when it is set
+	Threshold proxy.Duration `bson:"threshold" json:"threshold,omitempty"`
+}
+
+// AccessLog represents the access log settings of an API, they override the global access log settings
+type AccessLog struct {
+	// Enabled overrides the global access log toggle when it is set
+	Enabled *bool `bson:"enabled" json:"enabled,omitempty"`
+	// Format overrides the global access log format when it is set, possible values are: json, common, combined
+	Format string `bson:"format" json:"format,omitempty" valid:"in(json|common|combined)~access log format must be one of json, common or combined"`
+}
+
+// Tracing represents the tracing settings of an API, e.g. to sample a high volume API at a lower rate than the
+// others
+type Tracing struct {
+	// SamplingStrategy overrides the global sampling strategy when it is set, possible values are: always, never,
+	// probabilistic
+	SamplingStrategy string `bson:"sampling_strategy" json:"sampling_strategy,omitempty"`
+	// SamplingParam is the rate of the probabilistic sampling strategy, between 0 and 1
+	SamplingParam float64 `bson:"sampling_param" json:"sampling_param,omitempty"`
+	// Tags are added to the spans of the requests of the API
+	Tags map[string]string `bson:"tags" json:"tags,omitempty"`
+}
+
+// SamplingRate returns the sampling rate of the API, ok is false when it uses the global sampling strategy
+func (t Tracing) SamplingRate() (rate float64, ok bool, err error) {
+	if t.SamplingStrategy == "" {
+		return 0, false, nil
+	}
+
+	rate, err = sampling.Strategy(t.SamplingStrategy, t.SamplingParam)
+	return rate, err == nil, err
+}
+
+// Maintenance represents the maintenance mode of an API, the requests are answered by the gateway
+// instead of being proxied while it is enabled
+type Maintenance struct {
+	Enabled bool `bson:"enabled" json:"enabled"`
+	// Status is the response status code, 503 when it is not set
+	Status  int               `bson:"status" json:"status,omitempty"`
+	Headers map[string]string `bson:"headers" json:"headers,omitempty"`
+	// RetryAfter is the Retry-After header value in seconds, not sent when it is not set
+	RetryAfter int    `bson:"retry_after" json:"retry_after,omitempty"`
+	Body       string `bson:"body" json:"body,omitempty"`
 }
 
 // HealthCheck represents the health check configs
 type HealthCheck struct {
 	URL     string `bson:"url" json:"url" valid:"url"`
 	Timeout int    `bson:"timeout" json:"timeout"`
+	// Critical upstreams take the gateway health down when all of them are unavailable
+	Critical bool `bson:"critical" json:"critical"`
 }
 
 // Configuration represents all the api definitions
@@ -52,6 +115,8 @@ type ConfigurationOperation int
 type ConfigurationMessage struct {
 	Operation     ConfigurationOperation
 	Configuration *Definition
+	// Changes are the messages of a BatchOperation, applied in order as a single configuration change
+	Changes []ConfigurationMessage
 }
 
 const (
@@ -61,11 +126,14 @@ const (
 	UpdatedOperation
 	// AddedOperation means a definition was added
 	AddedOperation
+	// BatchOperation means several definitions were changed at once, e.g. by an import
+	BatchOperation
 )
 
 // NewDefinition creates a new API Definition with default values
 func NewDefinition() *Definition {
 	return &Definition{
+		Version: CurrentVersion,
 		Active:  true,
 		Plugins: make([]Plugin, 0),
 		Proxy:   proxy.NewDefinition(),
@@ -74,7 +142,19 @@ func NewDefinition() *Definition {
 
 // Validate validates proxy data
 func (d *Definition) Validate() (bool, error) {
-	return govalidator.ValidateStruct(d)
+	if ok, err := govalidator.ValidateStruct(d); !ok || d.Proxy == nil {
+		return ok, err
+	}
+
+	if err := errors.ValidateTemplates(d.ErrorTemplates); err != nil {
+		return false, err
+	}
+
+	if _, _, err := d.Tracing.SamplingRate(); err != nil {
+		return false, err
+	}
+
+	return d.Proxy.Validate()
 }
 
 // UnmarshalJSON api.Definition JSON.Unmarshaller implementation
@@ -83,7 +163,21 @@ func (d *Definition) UnmarshalJSON(b []byte) error {
 	type definitionAlias Definition
 	defAlias := definitionAlias(*NewDefinition())
 
-	if err := json.Unmarshal(b, &defAlias); err != nil {
+	if err := unmarshalMigrated(b, &defAlias); err != nil {
+		return err
+	}
+
+	*d = Definition(defAlias)
+	return nil
+}
+
+// SetBSON api.Definition bson.Setter implementation
+func (d *Definition) SetBSON(raw bson.Raw) error {
+	// Aliasing Definition to avoid recursive call of this method
+	type definitionAlias Definition
+	var defAlias definitionAlias
+
+	if err := setMigratedBSON(raw, &defAlias); err != nil {
 		return err
 	}
 
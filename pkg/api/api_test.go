@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/hellofresh/janus/pkg/api"
+	"github.com/hellofresh/janus/pkg/errors"
 	"github.com/hellofresh/janus/pkg/proxy"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -67,6 +68,22 @@ func TestNameValidation(t *testing.T) {
 	require.False(t, isValid)
 }
 
+func TestErrorTemplatesValidation(t *testing.T) {
+	instance := api.NewDefinition()
+	instance.Name = "templates"
+	instance.Proxy.ListenPath = "/"
+	instance.ErrorTemplates = []errors.Template{{ContentType: "text/html", Body: "<p>{{.Message}}</p>"}}
+
+	isValid, err := instance.Validate()
+	require.NoError(t, err)
+	require.True(t, isValid)
+
+	instance.ErrorTemplates = []errors.Template{{ContentType: "text/html", Body: "<p>{{.Message</p>"}}
+	isValid, err = instance.Validate()
+	require.Error(t, err)
+	require.False(t, isValid)
+}
+
 func TestConfiguration_EqualsTo(t *testing.T) {
 	def11 := api.NewDefinition()
 	def12 := api.NewDefinition()
@@ -1208,3 +1225,33 @@ const (
     }
 }`
 )
+
+func TestTracingValidation(t *testing.T) {
+	instance := api.NewDefinition()
+	instance.Name = "traced"
+	instance.Proxy.ListenPath = "/"
+	instance.Tracing = api.Tracing{SamplingStrategy: "probabilistic", SamplingParam: 0.001}
+
+	isValid, err := instance.Validate()
+	require.NoError(t, err)
+	require.True(t, isValid)
+
+	rate, ok, err := instance.Tracing.SamplingRate()
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 0.001, rate)
+
+	_, ok, err = api.Tracing{}.SamplingRate()
+	require.NoError(t, err)
+	assert.False(t, ok, "the global sampling strategy is used")
+
+	instance.Tracing = api.Tracing{SamplingStrategy: "probabilistic", SamplingParam: 2}
+	isValid, err = instance.Validate()
+	require.Error(t, err)
+	require.False(t, isValid)
+
+	instance.Tracing = api.Tracing{SamplingStrategy: "sometimes"}
+	isValid, err = instance.Validate()
+	require.Error(t, err)
+	require.False(t, isValid)
+}
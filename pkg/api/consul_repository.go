@@ -0,0 +1,205 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	consulAPI "github.com/hashicorp/consul/api"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// ConsulRepository represents a consul KV repository
+type ConsulRepository struct {
+	kv          *consulAPI.KV
+	prefix      string
+	refreshTime time.Duration
+}
+
+// NewConsulRepository creates a consul KV API definition repo.
+// The DSN host is the consul agent address and the path is the KV prefix holding the definitions,
+// e.g. consul://localhost:8500/janus/apis?token=secret&datacenter=dc1
+func NewConsulRepository(dsn string, refreshTime time.Duration) (*ConsulRepository, error) {
+	dsnURL, err := url.Parse(dsn)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error parsing the DSN")
+	}
+
+	cfg := consulAPI.DefaultConfig()
+	cfg.Address = dsnURL.Host
+	if scheme := dsnURL.Query().Get("scheme"); scheme != "" {
+		cfg.Scheme = scheme
+	}
+	if token := dsnURL.Query().Get("token"); token != "" {
+		cfg.Token = token
+	}
+	if datacenter := dsnURL.Query().Get("datacenter"); datacenter != "" {
+		cfg.Datacenter = datacenter
+	}
+
+	client, err := consulAPI.NewClient(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create a consul client")
+	}
+
+	repo := &ConsulRepository{
+		kv:          client.KV(),
+		prefix:      strings.TrimPrefix(dsnURL.Path, "/"),
+		refreshTime: refreshTime,
+	}
+
+	log.WithField("address", cfg.Address).Debug("Trying to connect to Consul...")
+	if _, _, err := repo.list(nil); err != nil {
+		return nil, errors.Wrap(err, "could not connect to consul")
+	}
+	log.Debug("Connected to Consul")
+
+	return repo, nil
+}
+
+// Close terminates the session.  It's a runtime error to use a session
+// after it has been closed.
+func (r *ConsulRepository) Close() error {
+	return nil
+}
+
+// Ping checks the connection to Consul
+func (r *ConsulRepository) Ping() error {
+	_, _, err := r.kv.Get(r.prefix, nil)
+	return err
+}
+
+// CertificateCache returns the cache storing the ACME account key and certificates in the KV store, under the
+// certificates prefix next to the definitions prefix
+func (r *ConsulRepository) CertificateCache() autocert.Cache {
+	return &consulCertCache{kv: r.kv, prefix: certificatesPrefix(r.prefix)}
+}
+
+// FindAll fetches all the API definitions available
+func (r *ConsulRepository) FindAll() ([]*Definition, error) {
+	defs, _, err := r.list(nil)
+	return defs, err
+}
+
+// Watch watches for changes on the consul KV prefix using blocking queries.
+// If consul becomes unavailable the last known configuration is kept until it is reachable again. The queries
+// returning without a change or with an index going backwards are retried after the refresh time.
+func (r *ConsulRepository) Watch(ctx context.Context, cfgChan chan<- ConfigurationChanged) {
+	go func() {
+		log.WithField("prefix", r.prefix).Debug("Watching Consul...")
+		var lastIndex uint64
+
+		for {
+			opts := &consulAPI.QueryOptions{WaitIndex: lastIndex}
+			defs, meta, err := r.list(opts.WithContext(ctx))
+
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+
+			if err != nil {
+				log.WithError(err).Error("Failed to get configurations from consul, keeping the last known configuration")
+				select {
+				case <-time.After(r.refreshTime):
+				case <-ctx.Done():
+					return
+				}
+				continue
+			}
+
+			// 0 is never a valid index, it would make the next query return right away
+			index := meta.LastIndex
+			if index == 0 {
+				index = 1
+			}
+
+			switch {
+			case index < lastIndex:
+				// the index going backwards means the KV store was reset, so we start over
+				lastIndex = 0
+			case index == lastIndex:
+				// the blocking query returned without any change, e.g. on the wait timeout
+			default:
+				lastIndex = index
+
+				select {
+				case cfgChan <- ConfigurationChanged{Configurations: &Configuration{Definitions: defs}}:
+				case <-ctx.Done():
+					return
+				}
+				continue
+			}
+
+			// the query is not blocking again right away, so the agents returning without a change
+			// are not hammered
+			select {
+			case <-time.After(r.refreshTime):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (r *ConsulRepository) list(opts *consulAPI.QueryOptions) ([]*Definition, *consulAPI.QueryMeta, error) {
+	pairs, meta, err := r.kv.List(r.prefix, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var definitions []*Definition
+	for _, pair := range pairs {
+		// skip "folders" created under the prefix
+		if strings.HasSuffix(pair.Key, "/") && len(pair.Value) == 0 {
+			continue
+		}
+
+		definition := NewDefinition()
+		if err := json.Unmarshal(pair.Value, definition); err != nil {
+			log.WithError(err).WithField("key", pair.Key).Error("Couldn't unmarshal api configuration")
+			continue
+		}
+
+		definitions = append(definitions, definition)
+	}
+
+	return definitions, meta, nil
+}
+
+// consulCertCache stores the ACME account key and certificates under the consul KV prefix
+type consulCertCache struct {
+	kv     *consulAPI.KV
+	prefix string
+}
+
+// Get returns the certificate data stored for the key
+func (c *consulCertCache) Get(ctx context.Context, key string) ([]byte, error) {
+	pair, _, err := c.kv.Get(path.Join(c.prefix, key), (&consulAPI.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if pair == nil {
+		return nil, autocert.ErrCacheMiss
+	}
+
+	return pair.Value, nil
+}
+
+// Put stores the certificate data for the key
+func (c *consulCertCache) Put(ctx context.Context, key string, data []byte) error {
+	_, err := c.kv.Put(&consulAPI.KVPair{Key: path.Join(c.prefix, key), Value: data}, (&consulAPI.WriteOptions{}).WithContext(ctx))
+	return err
+}
+
+// Delete removes the certificate data stored for the key
+func (c *consulCertCache) Delete(ctx context.Context, key string) error {
+	_, err := c.kv.Delete(path.Join(c.prefix, key), (&consulAPI.WriteOptions{}).WithContext(ctx))
+	return err
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+const consulDefinition = `{"name": "example", "active": true, "proxy": {"listen_path": "/example/*", "upstreams": {"balancing": "roundrobin", "targets": [{"target": "http://localhost:9089/hello-world"}]}}}`
+
+func newConsulServer(t *testing.T, index *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/kv/janus/apis", r.URL.Path)
+
+		w.Header().Set("X-Consul-Index", fmt.Sprintf("%d", *index))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(
+			w,
+			`[{"Key": "janus/apis/", "Value": null}, {"Key": "janus/apis/example", "Value": "%s"}]`,
+			base64.StdEncoding.EncodeToString([]byte(consulDefinition)),
+		)
+	}))
+}
+
+func TestConsulRepository(t *testing.T) {
+	index := 1
+	ts := newConsulServer(t, &index)
+	defer ts.Close()
+
+	repo, err := NewConsulRepository(fmt.Sprintf("consul://%s/janus/apis", strings.TrimPrefix(ts.URL, "http://")), time.Millisecond)
+	require.NoError(t, err)
+	defer repo.Close()
+
+	defs, err := repo.FindAll()
+	require.NoError(t, err)
+	require.Len(t, defs, 1)
+	assert.Equal(t, "example", defs[0].Name)
+	assert.Equal(t, "/example/*", defs[0].Proxy.ListenPath)
+}
+
+func TestConsulRepositoryWatch(t *testing.T) {
+	index := 1
+	ts := newConsulServer(t, &index)
+	defer ts.Close()
+
+	repo, err := NewConsulRepository(fmt.Sprintf("consul://%s/janus/apis", strings.TrimPrefix(ts.URL, "http://")), time.Millisecond)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfgChan := make(chan ConfigurationChanged)
+	repo.Watch(ctx, cfgChan)
+
+	select {
+	case cfg := <-cfgChan:
+		require.Len(t, cfg.Configurations.Definitions, 1)
+		assert.Equal(t, "example", cfg.Configurations.Definitions[0].Name)
+	case <-time.After(time.Second):
+		t.Fatal("configuration change was not received")
+	}
+}
+
+func TestConsulRepositoryWatchUnchangedIndex(t *testing.T) {
+	for name, index := range map[string]int{"same index": 5, "index 0": 0} {
+		t.Run(name, func(t *testing.T) {
+			var queries int32
+			ts := newConsulServer(t, &index)
+			defer ts.Close()
+			counted := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&queries, 1)
+				ts.Config.Handler.ServeHTTP(w, r)
+			}))
+			defer counted.Close()
+
+			repo, err := NewConsulRepository(fmt.Sprintf("consul://%s/janus/apis", strings.TrimPrefix(counted.URL, "http://")), 50*time.Millisecond)
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			cfgChan := make(chan ConfigurationChanged, 10)
+			repo.Watch(ctx, cfgChan)
+
+			time.Sleep(300 * time.Millisecond)
+			cancel()
+
+			assert.Len(t, cfgChan, 1, "the unchanged definitions are sent once")
+			assert.True(t, atomic.LoadInt32(&queries) <= 10, "the unchanged queries are retried after the refresh time, got %d queries", atomic.LoadInt32(&queries))
+		})
+	}
+}
+
+func TestConsulRepositoryUnavailable(t *testing.T) {
+	_, err := NewConsulRepository("consul://127.0.0.1:1/janus/apis", time.Second)
+	assert.Error(t, err)
+}
+
+func TestConsulCertCache(t *testing.T) {
+	values := make(map[string][]byte)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+		switch {
+		case key == "janus/apis":
+			w.Header().Set("X-Consul-Index", "1")
+			fmt.Fprint(w, `[]`)
+		case r.Method == http.MethodPut:
+			values[key], _ = ioutil.ReadAll(r.Body)
+			fmt.Fprint(w, `true`)
+		case r.Method == http.MethodDelete:
+			delete(values, key)
+			fmt.Fprint(w, `true`)
+		case values[key] != nil:
+			w.Header().Set("X-Consul-Index", "1")
+			fmt.Fprintf(w, `[{"Key": "%s", "Value": "%s"}]`, key, base64.StdEncoding.EncodeToString(values[key]))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	repo, err := NewConsulRepository(fmt.Sprintf("consul://%s/janus/apis", strings.TrimPrefix(ts.URL, "http://")), time.Second)
+	require.NoError(t, err)
+	cache := repo.CertificateCache()
+
+	_, err = cache.Get(context.Background(), "api.example.com")
+	assert.Equal(t, autocert.ErrCacheMiss, err)
+
+	require.NoError(t, cache.Put(context.Background(), "api.example.com", []byte("certificate")))
+	assert.Equal(t, []byte("certificate"), values["janus/certificates/api.example.com"], "the certificates are stored next to the definitions")
+
+	data, err := cache.Get(context.Background(), "api.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("certificate"), data)
+
+	require.NoError(t, cache.Delete(context.Background(), "api.example.com"))
+	_, err = cache.Get(context.Background(), "api.example.com")
+	assert.Equal(t, autocert.ErrCacheMiss, err)
+}
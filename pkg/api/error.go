@@ -16,6 +16,9 @@ var (
 	// ErrAPIListenPathExists is used when the API listen path is already registered on the datastore
 	ErrAPIListenPathExists = errors.New(http.StatusConflict, "api listen path is already registered")
 
+	// ErrPluginNotFound is used when the api definition has no plugin of the given name
+	ErrPluginNotFound = errors.New(http.StatusNotFound, "plugin not found")
+
 	// ErrDBContextNotSet is used when the database request context is not set
 	ErrDBContextNotSet = errors.New(http.StatusInternalServerError, "DB context was not set for this request")
 )
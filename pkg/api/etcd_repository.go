@@ -0,0 +1,289 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"go.etcd.io/etcd/clientv3"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+const (
+	etcdDialTimeout    = 5 * time.Second
+	etcdRequestTimeout = 5 * time.Second
+)
+
+// EtcdRepository represents an etcd v3 repository
+type EtcdRepository struct {
+	client      *clientv3.Client
+	prefix      string
+	refreshTime time.Duration
+}
+
+// NewEtcdRepository creates an etcd API definition repo.
+// The DSN host is the etcd endpoint and the path is the key prefix holding the definitions, nested in a
+// parent prefix holding the certificates next to it, e.g.
+// etcd://localhost:2379/janus/apis?endpoints=etcd2:2379,etcd3:2379&username=janus&password=secret
+// Client certificate authentication is enabled when "cert" and "key" file paths are given, "ca" is
+// the optional trusted CA file.
+func NewEtcdRepository(dsn string, refreshTime time.Duration) (*EtcdRepository, error) {
+	cfg, prefix, err := etcdConfigFromDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	log.WithField("endpoints", cfg.Endpoints).Debug("Trying to connect to etcd...")
+	client, err := clientv3.New(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not connect to etcd")
+	}
+
+	repo := &EtcdRepository{client: client, prefix: prefix, refreshTime: refreshTime}
+	if _, _, err := repo.list(context.Background()); err != nil {
+		client.Close()
+		return nil, errors.Wrap(err, "could not connect to etcd")
+	}
+	log.Debug("Connected to etcd")
+
+	return repo, nil
+}
+
+// Close terminates the session.  It's a runtime error to use a session
+// after it has been closed.
+func (r *EtcdRepository) Close() error {
+	return r.client.Close()
+}
+
+// Ping checks the connection to etcd
+func (r *EtcdRepository) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), etcdRequestTimeout)
+	defer cancel()
+
+	_, err := r.client.Get(ctx, r.prefix, clientv3.WithKeysOnly(), clientv3.WithLimit(1))
+	return err
+}
+
+// CertificateCache returns the cache storing the ACME account key and certificates in etcd, under the
+// certificates prefix next to the definitions prefix
+func (r *EtcdRepository) CertificateCache() autocert.Cache {
+	return &etcdCertCache{client: r.client, prefix: certificatesPrefix(r.prefix)}
+}
+
+// FindAll fetches all the API definitions available
+func (r *EtcdRepository) FindAll() ([]*Definition, error) {
+	defs, _, err := r.list(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return sortedDefinitions(defs), nil
+}
+
+// Watch watches for changes on the etcd prefix. Every time the watch is interrupted, e.g. during an
+// etcd outage, the full state is listed again once etcd is reachable and the watch is re-established.
+func (r *EtcdRepository) Watch(ctx context.Context, cfgChan chan<- ConfigurationChanged) {
+	go func() {
+		log.WithField("prefix", r.prefix).Debug("Watching etcd...")
+		for {
+			defs, revision, err := r.list(ctx)
+			if err != nil {
+				log.WithError(err).Error("Failed to get configurations from etcd, keeping the last known configuration")
+			} else {
+				select {
+				case cfgChan <- ConfigurationChanged{Configurations: &Configuration{Definitions: sortedDefinitions(defs)}}:
+				case <-ctx.Done():
+					return
+				}
+
+				r.watch(ctx, revision, defs, cfgChan)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(r.refreshTime):
+				log.Debug("Re-establishing etcd watch")
+			}
+		}
+	}()
+}
+
+func (r *EtcdRepository) watch(ctx context.Context, revision int64, defs map[string]*Definition, cfgChan chan<- ConfigurationChanged) {
+	watchChan := r.client.Watch(
+		clientv3.WithRequireLeader(ctx),
+		r.prefix,
+		clientv3.WithPrefix(),
+		clientv3.WithRev(revision+1),
+	)
+
+	for resp := range watchChan {
+		if err := resp.Err(); err != nil {
+			log.WithError(err).Error("etcd watch was interrupted")
+			return
+		}
+
+		for _, event := range resp.Events {
+			key := string(event.Kv.Key)
+			switch event.Type {
+			case clientv3.EventTypeDelete:
+				delete(defs, key)
+			case clientv3.EventTypePut:
+				definition, err := parseEtcdDefinition(key, event.Kv.Value)
+				if err != nil {
+					continue
+				}
+				defs[key] = definition
+			}
+		}
+
+		select {
+		case cfgChan <- ConfigurationChanged{Configurations: &Configuration{Definitions: sortedDefinitions(defs)}}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *EtcdRepository) list(ctx context.Context) (map[string]*Definition, int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, etcdRequestTimeout)
+	defer cancel()
+
+	resp, err := r.client.Get(ctx, r.prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defs := make(map[string]*Definition, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		key := string(kv.Key)
+		definition, err := parseEtcdDefinition(key, kv.Value)
+		if err != nil {
+			continue
+		}
+		defs[key] = definition
+	}
+
+	return defs, resp.Header.Revision, nil
+}
+
+func parseEtcdDefinition(key string, value []byte) (*Definition, error) {
+	definition := NewDefinition()
+	if err := json.Unmarshal(value, definition); err != nil {
+		log.WithError(err).WithField("key", key).Error("Couldn't unmarshal api configuration")
+		return nil, err
+	}
+
+	return definition, nil
+}
+
+// sortedDefinitions returns the definitions ordered by their keys to have the same order all the time
+func sortedDefinitions(defs map[string]*Definition) []*Definition {
+	keys := make([]string, 0, len(defs))
+	for key := range defs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var result []*Definition
+	for _, key := range keys {
+		result = append(result, defs[key])
+	}
+
+	return result
+}
+
+func etcdConfigFromDSN(dsn string) (clientv3.Config, string, error) {
+	dsnURL, err := url.Parse(dsn)
+	if err != nil {
+		return clientv3.Config{}, "", errors.Wrap(err, "Error parsing the DSN")
+	}
+
+	query := dsnURL.Query()
+	cfg := clientv3.Config{
+		Endpoints:   []string{dsnURL.Host},
+		DialTimeout: etcdDialTimeout,
+		Username:    query.Get("username"),
+		Password:    query.Get("password"),
+	}
+
+	if endpoints := query.Get("endpoints"); endpoints != "" {
+		cfg.Endpoints = append(cfg.Endpoints, strings.Split(endpoints, ",")...)
+	}
+
+	if query.Get("cert") != "" && query.Get("key") != "" {
+		cert, err := tls.LoadX509KeyPair(query.Get("cert"), query.Get("key"))
+		if err != nil {
+			return clientv3.Config{}, "", errors.Wrap(err, "could not load etcd client certificate")
+		}
+		cfg.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+
+		if ca := query.Get("ca"); ca != "" {
+			caCert, err := ioutil.ReadFile(ca)
+			if err != nil {
+				return clientv3.Config{}, "", errors.Wrap(err, "could not load etcd CA certificate")
+			}
+			cfg.TLS.RootCAs = x509.NewCertPool()
+			cfg.TLS.RootCAs.AppendCertsFromPEM(caCert)
+		}
+	}
+
+	prefix, err := etcdPrefix(dsnURL.Path)
+	if err != nil {
+		return clientv3.Config{}, "", err
+	}
+
+	return cfg, prefix, nil
+}
+
+// etcdPrefix returns the key prefix of the definitions ending with a slash, so the keys of the sibling
+// prefixes, e.g. /janus/apis-staging for /janus/apis, are neither listed nor watched. The prefixes of
+// the root are rejected, their certificates would be stored under /certificates shared by all of them.
+func etcdPrefix(p string) (string, error) {
+	p = strings.TrimSuffix(p, "/")
+	if dir := path.Dir(p); dir == "/" || dir == "." {
+		return "", errors.Errorf("the etcd key prefix %q must be nested in a parent prefix, e.g. /janus/apis", p)
+	}
+
+	return p + "/", nil
+}
+
+// etcdCertCache stores the ACME account key and certificates under the etcd prefix
+type etcdCertCache struct {
+	client *clientv3.Client
+	prefix string
+}
+
+// Get returns the certificate data stored for the key
+func (c *etcdCertCache) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := c.client.Get(ctx, path.Join(c.prefix, key))
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, autocert.ErrCacheMiss
+	}
+
+	return resp.Kvs[0].Value, nil
+}
+
+// Put stores the certificate data for the key
+func (c *etcdCertCache) Put(ctx context.Context, key string, data []byte) error {
+	_, err := c.client.Put(ctx, path.Join(c.prefix, key), string(data))
+	return err
+}
+
+// Delete removes the certificate data stored for the key
+func (c *etcdCertCache) Delete(ctx context.Context, key string) error {
+	_, err := c.client.Delete(ctx, path.Join(c.prefix, key))
+	return err
+}
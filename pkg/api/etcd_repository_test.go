@@ -0,0 +1,55 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEtcdConfigFromDSN(t *testing.T) {
+	cfg, prefix, err := etcdConfigFromDSN("etcd://etcd1:2379/janus/apis?endpoints=etcd2:2379,etcd3:2379&username=janus&password=secret")
+	require.NoError(t, err)
+
+	assert.Equal(t, "/janus/apis/", prefix, "the sibling prefixes are not matched")
+	assert.Equal(t, []string{"etcd1:2379", "etcd2:2379", "etcd3:2379"}, cfg.Endpoints)
+	assert.Equal(t, "janus", cfg.Username)
+	assert.Equal(t, "secret", cfg.Password)
+	assert.Nil(t, cfg.TLS)
+}
+
+func TestEtcdConfigFromDSNWithInvalidCertificate(t *testing.T) {
+	_, _, err := etcdConfigFromDSN("etcd://etcd1:2379/janus/apis?cert=/not/found.crt&key=/not/found.key")
+	assert.Error(t, err)
+}
+
+func TestEtcdPrefix(t *testing.T) {
+	prefix, err := etcdPrefix("/janus/apis/")
+	require.NoError(t, err)
+	assert.Equal(t, "/janus/apis/", prefix)
+	assert.Equal(t, "/janus/certificates", certificatesPrefix(prefix))
+
+	for _, p := range []string{"", "/", "/apis", "/apis/"} {
+		_, err := etcdPrefix(p)
+		assert.Error(t, err, "the prefix %q is in the root", p)
+	}
+}
+
+func TestSortedDefinitions(t *testing.T) {
+	defs := map[string]*Definition{
+		"/janus/apis/b": {Name: "b"},
+		"/janus/apis/a": {Name: "a"},
+	}
+
+	sorted := sortedDefinitions(defs)
+	require.Len(t, sorted, 2)
+	assert.Equal(t, "a", sorted[0].Name)
+	assert.Equal(t, "b", sorted[1].Name)
+}
+
+func TestCertificatesPrefix(t *testing.T) {
+	assert.Equal(t, "/janus/certificates", certificatesPrefix("/janus/apis"))
+	assert.Equal(t, "/janus/certificates", certificatesPrefix("/janus/apis/"))
+	assert.Equal(t, "janus/certificates", certificatesPrefix("janus/apis"))
+	assert.Equal(t, "certificates", certificatesPrefix("apis"))
+}
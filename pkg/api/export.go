@@ -0,0 +1,152 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RedactedValue replaces secret plugin configuration values in redacted exports
+const RedactedValue = "<redacted>"
+
+// secretConfigKeys are the plugin configuration key suffixes considered as secrets
+var secretConfigKeys = []string{"secret", "secrets", "password", "token", "dsn", "authorization", "credentials", "api_key"}
+
+// Export represents the document holding all the API definitions, it is used for
+// exporting and importing the whole gateway configuration at once
+type Export struct {
+	Definitions []*Definition `json:"definitions"`
+}
+
+// NewExport creates a new export document for the given definitions. When redact is set,
+// secret plugin configuration values are replaced with RedactedValue in the copied definitions.
+func NewExport(definitions []*Definition, redact bool) (*Export, error) {
+	export := &Export{Definitions: make([]*Definition, 0, len(definitions))}
+	for _, definition := range definitions {
+		if redact {
+			redacted, err := definition.Redacted()
+			if err != nil {
+				return nil, err
+			}
+			definition = redacted
+		}
+
+		export.Definitions = append(export.Definitions, definition)
+	}
+
+	return export, nil
+}
+
+// Redacted returns a copy of the definition with secret plugin configuration values redacted
+func (d *Definition) Redacted() (*Definition, error) {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+
+	definition := NewDefinition()
+	if err := json.Unmarshal(b, definition); err != nil {
+		return nil, err
+	}
+
+	for _, plg := range definition.Plugins {
+		redactConfig(plg.Config)
+	}
+
+	return definition, nil
+}
+
+// RestoreRedacted replaces the RedactedValue values of the plugin configurations with the values stored at
+// the same place in the stored definition, e.g. when a redacted export is imported again. An error naming the
+// plugin configuration key is returned when a redacted value has no stored value to restore.
+func (d *Definition) RestoreRedacted(stored *Definition) error {
+	storedPlugins := make(map[string]map[string]interface{})
+	if stored != nil {
+		for _, plg := range stored.Plugins {
+			storedPlugins[plg.Name] = plg.Config
+		}
+	}
+
+	for _, plg := range d.Plugins {
+		if err := restoreConfig(plg.Config, storedPlugins[plg.Name], plg.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func restoreConfig(config, stored map[string]interface{}, path string) error {
+	for key, value := range config {
+		keyPath := path + "." + key
+		storedValue, ok := stored[key]
+		if value == RedactedValue {
+			if !ok || storedValue == RedactedValue {
+				return fmt.Errorf("plugin config %s is redacted and has no stored value", keyPath)
+			}
+			config[key] = storedValue
+			continue
+		}
+
+		if err := restoreValue(value, storedValue, keyPath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func restoreValue(value, stored interface{}, path string) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		storedConfig, _ := stored.(map[string]interface{})
+		return restoreConfig(v, storedConfig, path)
+	case []interface{}:
+		storedItems, _ := stored.([]interface{})
+		for i, item := range v {
+			var storedItem interface{}
+			if i < len(storedItems) {
+				storedItem = storedItems[i]
+			}
+			if err := restoreValue(item, storedItem, path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func redactConfig(config map[string]interface{}) {
+	for key, value := range config {
+		if isSecretConfigKey(key) {
+			config[key] = RedactedValue
+			continue
+		}
+
+		redactValue(value)
+	}
+}
+
+func redactValue(value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		redactConfig(v)
+	case []interface{}:
+		for _, item := range v {
+			redactValue(item)
+		}
+	}
+}
+
+func isSecretConfigKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, secretKey := range secretConfigKeys {
+		if strings.HasSuffix(key, secretKey) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,84 @@
+package api_test
+
+import (
+	"testing"
+
+	"github.com/hellofresh/janus/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newExportDefinition() *api.Definition {
+	definition := api.NewDefinition()
+	definition.Name = "example"
+	definition.Proxy.ListenPath = "/example/*"
+	definition.Plugins = []api.Plugin{{
+		Name:    "oauth2",
+		Enabled: true,
+		Config: map[string]interface{}{
+			"server_name": "local",
+			"secrets":     map[string]interface{}{"client": "client-secret"},
+			"token_strategy": map[string]interface{}{
+				"name":     "jwt",
+				"settings": []interface{}{map[string]interface{}{"alg": "HS256", "secret": "jwt-secret"}},
+			},
+		},
+	}, {
+		Name:    "rate_limit",
+		Enabled: true,
+		Config:  map[string]interface{}{"limit": "10-S", "policy": "redis", "redis": map[string]interface{}{"dsn": "redis://:password@localhost:6379"}},
+	}}
+
+	return definition
+}
+
+func TestNewExport(t *testing.T) {
+	definition := newExportDefinition()
+
+	export, err := api.NewExport([]*api.Definition{definition}, false)
+	require.NoError(t, err)
+	require.Len(t, export.Definitions, 1)
+	assert.Equal(t, definition, export.Definitions[0])
+}
+
+func TestNewExportRedacted(t *testing.T) {
+	definition := newExportDefinition()
+
+	export, err := api.NewExport([]*api.Definition{definition}, true)
+	require.NoError(t, err)
+	require.Len(t, export.Definitions, 1)
+
+	redacted := export.Definitions[0]
+	assert.Equal(t, "example", redacted.Name)
+	assert.Equal(t, "local", redacted.Plugins[0].Config["server_name"])
+	assert.Equal(t, api.RedactedValue, redacted.Plugins[0].Config["secrets"])
+	settings := redacted.Plugins[0].Config["token_strategy"].(map[string]interface{})["settings"].([]interface{})
+	assert.Equal(t, "HS256", settings[0].(map[string]interface{})["alg"])
+	assert.Equal(t, api.RedactedValue, settings[0].(map[string]interface{})["secret"])
+	assert.Equal(t, api.RedactedValue, redacted.Plugins[1].Config["redis"].(map[string]interface{})["dsn"])
+
+	// original definition must stay untouched
+	assert.Equal(t, "jwt-secret", definition.Plugins[0].Config["token_strategy"].(map[string]interface{})["settings"].([]interface{})[0].(map[string]interface{})["secret"])
+}
+
+func TestDefinitionRestoreRedacted(t *testing.T) {
+	stored := newExportDefinition()
+	redacted, err := stored.Redacted()
+	require.NoError(t, err)
+
+	require.NoError(t, redacted.RestoreRedacted(stored))
+	assert.Equal(t, stored, redacted)
+}
+
+func TestDefinitionRestoreRedactedWithoutStoredValue(t *testing.T) {
+	stored := newExportDefinition()
+	redacted, err := stored.Redacted()
+	require.NoError(t, err)
+
+	stored.Plugins = stored.Plugins[:1]
+	err = redacted.RestoreRedacted(stored)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rate_limit.redis.dsn")
+
+	assert.Error(t, redacted.RestoreRedacted(nil))
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/hellofresh/janus/pkg/config"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 )
@@ -57,7 +58,11 @@ func NewFileSystemRepository(dir string) (*FileSystemRepository, error) {
 				return nil, err
 			}
 
-			definition := repo.parseDefinition(appConfigBody)
+			definition, err := repo.parseDefinition(appConfigBody)
+			if err != nil {
+				logger.WithError(err).Error("Couldn't parse the api definition file")
+				return nil, err
+			}
 			for _, v := range definition.defs {
 				if err = repo.add(v); err != nil {
 					logger.WithField("name", v.Name).WithError(err).Error("Failed during add definition to the repository")
@@ -89,8 +94,13 @@ func (r *FileSystemRepository) Watch(ctx context.Context, cfgChan chan<- Configu
 						log.WithError(err).Error("Couldn't load the api definition file")
 						continue
 					}
+					definition, err := r.parseDefinition(body)
+					if err != nil {
+						log.WithError(err).WithField("path", event.Name).Error("Couldn't parse the api definition file, keeping the current definitions")
+						continue
+					}
 					cfgChan <- ConfigurationChanged{
-						Configurations: &Configuration{Definitions: r.parseDefinition(body).defs},
+						Configurations: &Configuration{Definitions: definition.defs},
 					}
 				}
 			case err := <-r.watcher.Errors:
@@ -103,19 +113,34 @@ func (r *FileSystemRepository) Watch(ctx context.Context, cfgChan chan<- Configu
 	}()
 }
 
-func (r *FileSystemRepository) parseDefinition(apiDef []byte) definitionList {
+func (r *FileSystemRepository) parseDefinition(apiDef []byte) (definitionList, error) {
 	appConfigs := definitionList{}
 
 	// Try unmarshalling as if json is an unnamed Array of multiple definitions
 	if err := json.Unmarshal(apiDef, &appConfigs); err != nil {
+		if isMigrationError(err) {
+			// the definitions of an unknown schema version are not loaded
+			return appConfigs, err
+		}
+
 		// Try unmarshalling as if json is a single Definition
-		appConfigs.defs = append(appConfigs.defs, NewDefinition())
+		appConfigs.defs = append(appConfigs.defs[:0], NewDefinition())
 		if err := json.Unmarshal(apiDef, &appConfigs.defs[0]); err != nil {
+			if isMigrationError(err) {
+				return appConfigs, err
+			}
 			log.WithError(err).Error("[RPC] --> Couldn't unmarshal api configuration")
 		}
 	}
 
-	return appConfigs
+	// the definition files may refer to the environment variables, e.g. for the secrets
+	for _, def := range appConfigs.defs {
+		if err := config.ExpandEnv(def); err != nil {
+			return appConfigs, errors.Wrapf(err, "api definition %q", def.Name)
+		}
+	}
+
+	return appConfigs, nil
 }
 
 func (d *definitionList) UnmarshalJSON(b []byte) error {
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
@@ -72,3 +73,40 @@ func TestFileSystemRepository_Add(t *testing.T) {
 	err := fsRepo.add(invalidName)
 	assert.Error(t, err)
 }
+
+func TestFileSystemRepositoryInterpolation(t *testing.T) {
+	os.Setenv("JANUS_TEST_REDIS_PASSWORD", "s3cr3t")
+	defer os.Unsetenv("JANUS_TEST_REDIS_PASSWORD")
+
+	dir, err := ioutil.TempDir("", "janus-apis")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	definitionFile := filepath.Join(dir, "example.json")
+	require.NoError(t, ioutil.WriteFile(definitionFile, []byte(`{
+    "name": "example",
+    "proxy": {
+        "listen_path": "/example/*",
+        "upstreams": {"balancing": "roundrobin", "targets": [{"target": "${JANUS_TEST_UPSTREAM:-http://example.com}"}]}
+    },
+    "plugins": [{
+        "name": "rate_limit",
+        "enabled": true,
+        "config": {"limit": "10-S", "policy": "redis", "redis": {"dsn": "redis://:${JANUS_TEST_REDIS_PASSWORD}@localhost:6379"}}
+    }]
+}`), 0644))
+
+	repo, err := NewFileSystemRepository(dir)
+	require.NoError(t, err)
+	defer repo.Close()
+
+	definition, err := repo.findByName("example")
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com", definition.Proxy.Upstreams.Targets[0].Target)
+	assert.Equal(t, map[string]interface{}{"dsn": "redis://:s3cr3t@localhost:6379"}, definition.Plugins[0].Config["redis"])
+
+	os.Unsetenv("JANUS_TEST_REDIS_PASSWORD")
+	_, err = NewFileSystemRepository(dir)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "JANUS_TEST_REDIS_PASSWORD")
+}
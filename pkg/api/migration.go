@@ -0,0 +1,197 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/globalsign/mgo/bson"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// legacyVersion is the schema version of the definitions without a version, they were written before
+	// the definitions were versioned
+	legacyVersion = 1
+	// CurrentVersion is the schema version of the definitions written by this Janus version
+	CurrentVersion = 2
+)
+
+// migrationError is returned for the definitions that can not be migrated to the current schema version
+type migrationError struct {
+	name    string
+	message string
+}
+
+func (e *migrationError) Error() string {
+	return fmt.Sprintf("api definition %q: %s", e.name, e.message)
+}
+
+func isMigrationError(err error) bool {
+	_, ok := err.(*migrationError)
+	return ok
+}
+
+// migration upgrades a raw definition from its schema version to the next one
+type migration func(raw map[string]interface{}) error
+
+// migrations are the upgrades of the definition schema, the migration at index i upgrades the version
+// i+1 to i+2
+var migrations = []migration{
+	migrateUpstreamURL,
+}
+
+// migrateDefinition upgrades the raw definition to the current schema version, the definitions of an
+// unknown or newer version are rejected
+func migrateDefinition(raw map[string]interface{}) error {
+	name, _ := raw["name"].(string)
+
+	version, err := definitionVersion(raw)
+	if err != nil {
+		return &migrationError{name: name, message: err.Error()}
+	}
+	if version > CurrentVersion {
+		return &migrationError{name: name, message: fmt.Sprintf("schema version %d is newer than the supported version %d, upgrade Janus to load it", version, CurrentVersion)}
+	}
+
+	if version < CurrentVersion {
+		log.WithField("name", name).
+			WithField("from_version", version).
+			WithField("to_version", CurrentVersion).
+			Info("Migrating the api definition to the current schema version")
+	}
+
+	for ; version < CurrentVersion; version++ {
+		if err := migrations[version-1](raw); err != nil {
+			return &migrationError{name: name, message: fmt.Sprintf("could not migrate the schema version %d: %s", version, err)}
+		}
+	}
+
+	raw["version"] = CurrentVersion
+	return nil
+}
+
+// definitionVersion returns the schema version of the raw definition, the legacy version when it has
+// none
+func definitionVersion(raw map[string]interface{}) (int, error) {
+	value, ok := raw["version"]
+	if !ok || value == nil {
+		return legacyVersion, nil
+	}
+
+	var version float64
+	switch v := value.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return 0, fmt.Errorf("schema version %v is not a number", value)
+		}
+		version = f
+	case float64:
+		version = v
+	case int:
+		version = float64(v)
+	case int64:
+		version = float64(v)
+	default:
+		return 0, fmt.Errorf("schema version %v is not a number", value)
+	}
+
+	if version != math.Trunc(version) || version < legacyVersion {
+		return 0, fmt.Errorf("unknown schema version %v", value)
+	}
+
+	return int(version), nil
+}
+
+// migrateUpstreamURL replaces the single upstream_url of the proxy, removed in 3.7, with the upstreams
+// balancing the requests to it
+func migrateUpstreamURL(raw map[string]interface{}) error {
+	proxy, ok := asMap(raw["proxy"])
+	if !ok {
+		return nil
+	}
+
+	url, ok := proxy["upstream_url"]
+	if !ok {
+		return nil
+	}
+	delete(proxy, "upstream_url")
+
+	target, ok := url.(string)
+	if !ok {
+		return fmt.Errorf("proxy.upstream_url %v is not a string", url)
+	}
+
+	if upstreams, ok := asMap(proxy["upstreams"]); ok {
+		if targets, ok := upstreams["targets"].([]interface{}); ok && len(targets) > 0 {
+			// the upstreams win over the legacy url, like they did in 3.7
+			return nil
+		}
+	}
+
+	if target != "" {
+		proxy["upstreams"] = map[string]interface{}{
+			"balancing": "roundrobin",
+			"targets":   []interface{}{map[string]interface{}{"target": target}},
+		}
+	}
+
+	return nil
+}
+
+// asMap returns the document of a decoded JSON or BSON value
+func asMap(value interface{}) (map[string]interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v, true
+	case bson.M:
+		return v, true
+	}
+	return nil, false
+}
+
+// unmarshalMigrated decodes the JSON definition upgraded to the current schema version
+func unmarshalMigrated(b []byte, v interface{}) error {
+	// the numbers are kept as they are written, a float64 would round the large integers
+	var raw map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return err
+	}
+	if raw == nil {
+		return json.Unmarshal(b, v)
+	}
+
+	if err := migrateDefinition(raw); err != nil {
+		return err
+	}
+
+	migrated, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(migrated, v)
+}
+
+// setMigratedBSON decodes the BSON definition upgraded to the current schema version
+func setMigratedBSON(b bson.Raw, v interface{}) error {
+	var raw map[string]interface{}
+	if err := b.Unmarshal(&raw); err != nil {
+		return err
+	}
+
+	if err := migrateDefinition(raw); err != nil {
+		return err
+	}
+
+	migrated, err := bson.Marshal(raw)
+	if err != nil {
+		return err
+	}
+
+	return bson.Unmarshal(migrated, v)
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const legacyDefinition = `{
+    "name": "legacy",
+    "active": true,
+    "proxy": {
+        "listen_path": "/legacy/*",
+        "upstream_url": "http://example.com/legacy",
+        "strip_path": true,
+        "methods": ["GET"]
+    },
+    "health_check": {"url": "http://example.com/status", "timeout": 3}
+}`
+
+func TestMigrateLegacyDefinition(t *testing.T) {
+	definition := NewDefinition()
+	require.NoError(t, json.Unmarshal([]byte(legacyDefinition), definition))
+
+	assert.Equal(t, CurrentVersion, definition.Version)
+	assert.Equal(t, "legacy", definition.Name)
+	assert.True(t, definition.Proxy.StripPath)
+	assert.Equal(t, []string{"GET"}, definition.Proxy.Methods)
+	assert.Equal(t, 3, definition.HealthCheck.Timeout)
+	require.NotNil(t, definition.Proxy.Upstreams)
+	assert.Equal(t, "roundrobin", definition.Proxy.Upstreams.Balancing)
+	require.Len(t, definition.Proxy.Upstreams.Targets, 1)
+	assert.Equal(t, "http://example.com/legacy", definition.Proxy.Upstreams.Targets[0].Target)
+
+	ok, err := definition.Validate()
+	assert.True(t, ok)
+	assert.NoError(t, err)
+
+	// the migrated definition is written in the current version and loaded as it is
+	b, err := json.Marshal(definition)
+	require.NoError(t, err)
+
+	var raw map[string]interface{}
+	require.NoError(t, json.Unmarshal(b, &raw))
+	assert.Equal(t, float64(CurrentVersion), raw["version"])
+	assert.NotContains(t, raw["proxy"], "upstream_url")
+
+	reloaded := NewDefinition()
+	require.NoError(t, json.Unmarshal(b, reloaded))
+	assert.Equal(t, definition, reloaded)
+}
+
+func TestMigrateKeepsUpstreams(t *testing.T) {
+	definition := NewDefinition()
+	require.NoError(t, json.Unmarshal([]byte(`{
+        "name": "both",
+        "proxy": {
+            "listen_path": "/both/*",
+            "upstream_url": "http://legacy.example.com",
+            "upstreams": {"balancing": "weight", "targets": [{"target": "http://example.com", "weight": 100}]}
+        }
+    }`), definition))
+
+	assert.Equal(t, "weight", definition.Proxy.Upstreams.Balancing)
+	require.Len(t, definition.Proxy.Upstreams.Targets, 1)
+	assert.Equal(t, "http://example.com", definition.Proxy.Upstreams.Targets[0].Target)
+}
+
+func TestMigrateCurrentDefinition(t *testing.T) {
+	definition := NewDefinition()
+	require.NoError(t, json.Unmarshal([]byte(`{"version": 2, "name": "current", "proxy": {"listen_path": "/current/*", "upstreams": {"balancing": "roundrobin", "targets": [{"target": "http://example.com"}]}}}`), definition))
+
+	assert.Equal(t, CurrentVersion, definition.Version)
+	assert.Equal(t, "http://example.com", definition.Proxy.Upstreams.Targets[0].Target)
+}
+
+func TestMigrateUnsupportedVersions(t *testing.T) {
+	for _, version := range []string{`3`, `0`, `-1`, `1.5`, `"2"`, `true`} {
+		t.Run(version, func(t *testing.T) {
+			err := json.Unmarshal([]byte(`{"version": `+version+`, "name": "unsupported", "proxy": {"listen_path": "/unsupported/*"}}`), NewDefinition())
+			require.Error(t, err)
+			assert.True(t, isMigrationError(err))
+			assert.Contains(t, err.Error(), `"unsupported"`)
+		})
+	}
+
+	err := json.Unmarshal([]byte(`{"version": 3, "name": "newer"}`), NewDefinition())
+	assert.EqualError(t, err, `api definition "newer": schema version 3 is newer than the supported version 2, upgrade Janus to load it`)
+}
+
+func TestMigrateLegacyBSON(t *testing.T) {
+	var raw map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(legacyDefinition), &raw))
+	b, err := bson.Marshal(raw)
+	require.NoError(t, err)
+
+	var definition Definition
+	require.NoError(t, bson.Unmarshal(b, &definition))
+	assert.Equal(t, CurrentVersion, definition.Version)
+	require.NotNil(t, definition.Proxy.Upstreams)
+	assert.Equal(t, "http://example.com/legacy", definition.Proxy.Upstreams.Targets[0].Target)
+
+	b, err = bson.Marshal(definition)
+	require.NoError(t, err)
+
+	var reloaded Definition
+	require.NoError(t, bson.Unmarshal(b, &reloaded))
+	assert.Equal(t, CurrentVersion, reloaded.Version)
+	assert.Equal(t, definition.Proxy.Upstreams, reloaded.Proxy.Upstreams)
+
+	raw = map[string]interface{}{"version": 3, "name": "newer"}
+	b, err = bson.Marshal(raw)
+	require.NoError(t, err)
+	assert.Error(t, bson.Unmarshal(b, &definition))
+}
+
+func TestFileSystemRepositoryRejectsNewerVersions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "janus-apis")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "legacy.json"), []byte(legacyDefinition), 0644))
+	repo, err := NewFileSystemRepository(dir)
+	require.NoError(t, err)
+	definition, err := repo.findByName("legacy")
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com/legacy", definition.Proxy.Upstreams.Targets[0].Target)
+	repo.Close()
+
+	for name, body := range map[string]string{
+		"single.json":   `{"version": 3, "name": "newer", "proxy": {"listen_path": "/newer/*"}}`,
+		"multiple.json": `[{"version": 3, "name": "newer", "proxy": {"listen_path": "/newer/*"}}]`,
+	} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "legacy.json"), []byte(body), 0644))
+		_, err = NewFileSystemRepository(dir)
+		assert.Error(t, err, name)
+	}
+}
@@ -8,10 +8,12 @@ import (
 	"github.com/globalsign/mgo/bson"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/acme/autocert"
 )
 
 const (
-	collectionName = "api_specs"
+	collectionName         = "api_specs"
+	certificatesCollection = "certificates"
 )
 
 // MongoRepository represents a mongodb repository
@@ -43,6 +45,19 @@ func (r *MongoRepository) Close() error {
 	return nil
 }
 
+// Ping checks the connection to MongoDB
+func (r *MongoRepository) Ping() error {
+	session := r.Session.Copy()
+	defer session.Close()
+
+	return session.Ping()
+}
+
+// CertificateCache returns the cache storing the ACME account key and certificates in the certificates collection
+func (r *MongoRepository) CertificateCache() autocert.Cache {
+	return &mongoCertCache{session: r.Session}
+}
+
 // Listen watches for changes on the configuration
 func (r *MongoRepository) Listen(ctx context.Context, cfgChan <-chan ConfigurationMessage) {
 	go func() {
@@ -159,3 +174,51 @@ func (r *MongoRepository) getSession() (*mgo.Session, *mgo.Collection) {
 
 	return session, coll
 }
+
+type certificateDocument struct {
+	Key  string `bson:"_id"`
+	Data []byte `bson:"data"`
+}
+
+// mongoCertCache stores the ACME account key and certificates in the mongodb collection
+type mongoCertCache struct {
+	session *mgo.Session
+}
+
+// Get returns the certificate data stored for the key
+func (c *mongoCertCache) Get(ctx context.Context, key string) ([]byte, error) {
+	session := c.session.Copy()
+	defer session.Close()
+
+	var doc certificateDocument
+	if err := session.DB("").C(certificatesCollection).FindId(key).One(&doc); err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, autocert.ErrCacheMiss
+		}
+		return nil, err
+	}
+
+	return doc.Data, nil
+}
+
+// Put stores the certificate data for the key
+func (c *mongoCertCache) Put(ctx context.Context, key string, data []byte) error {
+	session := c.session.Copy()
+	defer session.Close()
+
+	_, err := session.DB("").C(certificatesCollection).UpsertId(key, certificateDocument{Key: key, Data: data})
+	return err
+}
+
+// Delete removes the certificate data stored for the key
+func (c *mongoCertCache) Delete(ctx context.Context, key string) error {
+	session := c.session.Copy()
+	defer session.Close()
+
+	err := session.DB("").C(certificatesCollection).RemoveId(key)
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+
+	return err
+}
@@ -5,15 +5,20 @@ import (
 	"fmt"
 	"io"
 	"net/url"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/acme/autocert"
 )
 
 const (
 	mongodb = "mongodb"
 	file    = "file"
+	consul  = "consul"
+	etcd    = "etcd"
 )
 
 // Repository defines the behavior of a proxy specs repository
@@ -22,6 +27,11 @@ type Repository interface {
 	FindAll() ([]*Definition, error)
 }
 
+// Pinger defines how a provider tells whether its backend is reachable
+type Pinger interface {
+	Ping() error
+}
+
 // Watcher defines how a provider should watch for changes on configurations
 type Watcher interface {
 	Watch(ctx context.Context, cfgChan chan<- ConfigurationChanged)
@@ -32,6 +42,11 @@ type Listener interface {
 	Listen(ctx context.Context, cfgChan <-chan ConfigurationMessage)
 }
 
+// CertificateStore defines how a provider shares the ACME account key and certificates between the instances
+type CertificateStore interface {
+	CertificateCache() autocert.Cache
+}
+
 // BuildRepository creates a repository instance that will depend on your given DSN
 func BuildRepository(dsn string, refreshTime time.Duration) (Repository, error) {
 	dsnURL, err := url.Parse(dsn)
@@ -53,7 +68,19 @@ func BuildRepository(dsn string, refreshTime time.Duration) (Repository, error)
 			return nil, errors.Wrap(err, "could not create a file system repository")
 		}
 		return repo, nil
+	case consul:
+		log.Debug("Consul KV based configuration chosen")
+		return NewConsulRepository(dsn, refreshTime)
+	case etcd:
+		log.Debug("etcd based configuration chosen")
+		return NewEtcdRepository(dsn, refreshTime)
 	default:
 		return nil, errors.New("The selected scheme is not supported to load API definitions")
 	}
 }
+
+// certificatesPrefix returns the key prefix of the certificates next to the definitions prefix of a key value
+// store, e.g. janus/certificates for janus/apis
+func certificatesPrefix(prefix string) string {
+	return path.Join(path.Dir(strings.TrimSuffix(prefix, "/")), "certificates")
+}
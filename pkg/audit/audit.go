@@ -0,0 +1,84 @@
+package audit
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// CreatedOperation means a definition was created
+	CreatedOperation = "created"
+	// UpdatedOperation means a definition was updated
+	UpdatedOperation = "updated"
+	// DeletedOperation means a definition was deleted
+	DeletedOperation = "deleted"
+
+	defaultSize = 1000
+)
+
+// Entry represents a single configuration change made through the admin API
+type Entry struct {
+	Time       time.Time `bson:"time" json:"time"`
+	Actor      string    `bson:"actor" json:"actor"`
+	Operation  string    `bson:"operation" json:"operation"`
+	Definition string    `bson:"definition" json:"definition"`
+	Diff       []Change  `bson:"diff" json:"diff"`
+}
+
+// Sink persists the audit entries
+type Sink interface {
+	Write(entry Entry) error
+}
+
+// Trail writes the audit entries to the sink and keeps the most recent ones in memory for paging
+type Trail struct {
+	sink Sink
+	size int
+
+	sync.RWMutex
+	entries []Entry
+}
+
+// NewTrail creates a new instance of Trail keeping up to size recent entries
+func NewTrail(sink Sink, size int) *Trail {
+	if size <= 0 {
+		size = defaultSize
+	}
+
+	return &Trail{sink: sink, size: size}
+}
+
+// Record writes the entry to the sink, the entry is kept in the recent ones even if the sink failed
+func (t *Trail) Record(entry Entry) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	if err := t.sink.Write(entry); err != nil {
+		log.WithError(err).WithField("definition", entry.Definition).Error("Could not write the audit entry")
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	t.entries = append(t.entries, entry)
+	if len(t.entries) > t.size {
+		t.entries = t.entries[len(t.entries)-t.size:]
+	}
+}
+
+// Recent returns the page of the recent entries, newest first, and the total number of the recent entries
+func (t *Trail) Recent(offset, limit int) ([]Entry, int) {
+	t.RLock()
+	defer t.RUnlock()
+
+	total := len(t.entries)
+	result := make([]Entry, 0, limit)
+	for i := total - 1 - offset; i >= 0 && len(result) < limit; i-- {
+		result = append(result, t.entries[i])
+	}
+
+	return result, total
+}
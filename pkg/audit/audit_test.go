@@ -0,0 +1,107 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hellofresh/janus/pkg/api"
+	"github.com/hellofresh/janus/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingSink struct{}
+
+func (s *failingSink) Write(entry Entry) error {
+	return errors.New("sink is not available")
+}
+
+func TestTrailRecent(t *testing.T) {
+	trail := NewTrail(&failingSink{}, 3)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		trail.Record(Entry{Definition: name})
+	}
+
+	entries, total := trail.Recent(0, 2)
+	assert.Equal(t, 3, total)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "d", entries[0].Definition)
+	assert.Equal(t, "c", entries[1].Definition)
+	assert.False(t, entries[0].Time.IsZero())
+
+	entries, _ = trail.Recent(2, 2)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "b", entries[0].Definition)
+
+	entries, _ = trail.Recent(5, 2)
+	assert.Len(t, entries, 0)
+}
+
+func TestNewEntry(t *testing.T) {
+	old := api.NewDefinition()
+	old.Name = "example"
+	old.Proxy.ListenPath = "/example/*"
+	old.Plugins = []api.Plugin{{Name: "basic_auth", Enabled: true, Config: map[string]interface{}{"password": "old"}}}
+
+	new := api.NewDefinition()
+	new.Name = "example"
+	new.Proxy.ListenPath = "/example/v2/*"
+	new.Plugins = []api.Plugin{{Name: "basic_auth", Enabled: true, Config: map[string]interface{}{"password": "new"}}}
+
+	entry, err := NewEntry("admin", UpdatedOperation, old, new)
+	require.NoError(t, err)
+	assert.Equal(t, "admin", entry.Actor)
+	assert.Equal(t, "example", entry.Definition)
+	assert.Equal(t, []Change{{Path: "proxy.listen_path", Old: "/example/*", New: "/example/v2/*"}}, entry.Diff)
+
+	entry, err = NewEntry("admin", DeletedOperation, old, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "example", entry.Definition)
+	for _, change := range entry.Diff {
+		assert.Nil(t, change.New)
+		assert.NotEqual(t, "old", change.Old)
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.log")
+	sink, err := NewSink(config.Audit{Sink: fileSink, File: path}, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, sink.Write(Entry{Actor: "admin", Definition: "a"}))
+	require.NoError(t, sink.Write(Entry{Actor: "admin", Definition: "b"}))
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var definitions []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry Entry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		definitions = append(definitions, entry.Definition)
+	}
+	assert.Equal(t, []string{"a", "b"}, definitions)
+}
+
+func TestNewSink(t *testing.T) {
+	sink, err := NewSink(config.Audit{}, nil)
+	require.NoError(t, err)
+	assert.IsType(t, &LogSink{}, sink)
+
+	_, err = NewSink(config.Audit{Sink: storeSink}, api.NewInMemoryRepository())
+	assert.Error(t, err)
+
+	_, err = NewSink(config.Audit{Sink: "kafka"}, nil)
+	assert.Error(t, err)
+}
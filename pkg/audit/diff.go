@@ -0,0 +1,112 @@
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/hellofresh/janus/pkg/api"
+)
+
+// Change represents a changed value of the definition, the path is the dot separated JSON path of the value
+type Change struct {
+	Path string      `bson:"path" json:"path"`
+	Old  interface{} `bson:"old,omitempty" json:"old,omitempty"`
+	New  interface{} `bson:"new,omitempty" json:"new,omitempty"`
+}
+
+// NewEntry creates a new entry for the change of the definition from old to new, any of them can be nil.
+// Secret plugin configuration values are redacted in the diff.
+func NewEntry(actor, operation string, old, new *api.Definition) (Entry, error) {
+	entry := Entry{Actor: actor, Operation: operation}
+	for _, definition := range []*api.Definition{new, old} {
+		if definition != nil {
+			entry.Definition = definition.Name
+			break
+		}
+	}
+
+	oldValues, err := flatten(old)
+	if err != nil {
+		return entry, err
+	}
+
+	newValues, err := flatten(new)
+	if err != nil {
+		return entry, err
+	}
+
+	entry.Diff = diff(oldValues, newValues)
+	return entry, nil
+}
+
+func diff(old, new map[string]interface{}) []Change {
+	changes := make([]Change, 0)
+	for path, oldValue := range old {
+		if newValue, ok := new[path]; !ok || !reflect.DeepEqual(oldValue, newValue) {
+			changes = append(changes, Change{Path: path, Old: oldValue, New: newValue})
+		}
+	}
+
+	for path, newValue := range new {
+		if _, ok := old[path]; !ok {
+			changes = append(changes, Change{Path: path, New: newValue})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	return changes
+}
+
+// flatten converts the redacted definition to the map of JSON paths to the scalar values
+func flatten(definition *api.Definition) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	if definition == nil {
+		return values, nil
+	}
+
+	redacted, err := definition.Redacted()
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(redacted)
+	if err != nil {
+		return nil, err
+	}
+
+	var document interface{}
+	if err := json.Unmarshal(b, &document); err != nil {
+		return nil, err
+	}
+
+	flattenValue("", document, values)
+	return values, nil
+}
+
+func flattenValue(path string, value interface{}, values map[string]interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			flattenValue(joinPath(path, key), item, values)
+		}
+	case []interface{}:
+		for i, item := range v {
+			flattenValue(joinPath(path, strconv.Itoa(i)), item, values)
+		}
+	default:
+		values[path] = v
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
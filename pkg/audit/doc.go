@@ -0,0 +1,5 @@
+/*
+Package audit records the admin API configuration changes. Every entry holds the actor, the time,
+the affected API definition and the diff of the change and is written to the configured sink.
+*/
+package audit
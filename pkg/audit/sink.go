@@ -0,0 +1,101 @@
+package audit
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/globalsign/mgo"
+	"github.com/hellofresh/janus/pkg/api"
+	"github.com/hellofresh/janus/pkg/config"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	logSink   = "log"
+	fileSink  = "file"
+	storeSink = "store"
+
+	collectionName = "audit_log"
+)
+
+// NewSink creates the sink configured for the audit trail, "store" sink writes the entries
+// to the API definitions database and it is available only for mongodb
+func NewSink(cfg config.Audit, provider api.Repository) (Sink, error) {
+	switch cfg.Sink {
+	case "", logSink:
+		return &LogSink{}, nil
+	case fileSink:
+		return NewFileSink(cfg.File)
+	case storeSink:
+		mgoRepo, ok := provider.(*api.MongoRepository)
+		if !ok {
+			return nil, errors.New("audit store sink is supported only for mongodb database")
+		}
+		return &MongoSink{session: mgoRepo.Session}, nil
+	default:
+		return nil, errors.Errorf("unknown audit sink %q", cfg.Sink)
+	}
+}
+
+// LogSink writes the audit entries to the application log
+type LogSink struct{}
+
+// Write writes the entry
+func (s *LogSink) Write(entry Entry) error {
+	log.WithFields(log.Fields{
+		"actor":      entry.Actor,
+		"operation":  entry.Operation,
+		"definition": entry.Definition,
+		"diff":       entry.Diff,
+	}).Info("Audit")
+	return nil
+}
+
+// FileSink appends the audit entries to the file as JSON lines
+type FileSink struct {
+	sync.Mutex
+	file *os.File
+}
+
+// NewFileSink creates a new instance of FileSink, the file is created if it does not exist
+func NewFileSink(path string) (*FileSink, error) {
+	if path == "" {
+		return nil, errors.New("audit file path is not set")
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not open the audit file")
+	}
+
+	return &FileSink{file: file}, nil
+}
+
+// Write writes the entry
+func (s *FileSink) Write(entry Entry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	_, err = s.file.Write(append(b, '\n'))
+	return err
+}
+
+// MongoSink writes the audit entries to the mongodb collection
+type MongoSink struct {
+	session *mgo.Session
+}
+
+// Write writes the entry
+func (s *MongoSink) Write(entry Entry) error {
+	session := s.session.Copy()
+	defer session.Close()
+
+	return session.DB("").C(collectionName).Insert(entry)
+}
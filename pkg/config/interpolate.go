@@ -0,0 +1,172 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ExpandEnv replaces the ${VAR} and ${VAR:-default} references to the environment variables in all the
+// string fields of the value, it must be a pointer. The maps, the slices and the interface values are
+// walked too, so the plugin configs of the API definitions are expanded. The error names the variable
+// and the field when a variable is not set and has no default.
+func ExpandEnv(v interface{}) error {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return errors.New("the expanded value must be a non nil pointer")
+	}
+
+	return expandValue(value.Elem(), "")
+}
+
+func expandValue(v reflect.Value, path string) error {
+	switch v.Kind() {
+	case reflect.String:
+		expanded, err := Interpolate(v.String())
+		if err != nil {
+			return errors.Wrapf(err, "could not expand %s", fieldPath(path))
+		}
+		if v.CanSet() {
+			v.SetString(expanded)
+		}
+	case reflect.Ptr:
+		if !v.IsNil() {
+			return expandValue(v.Elem(), path)
+		}
+	case reflect.Interface:
+		if v.IsNil() || !v.CanSet() {
+			return nil
+		}
+		// the value held by an interface can not be set, a copy is expanded instead
+		elem := v.Elem()
+		expanded := reflect.New(elem.Type()).Elem()
+		expanded.Set(elem)
+		if err := expandValue(expanded, path); err != nil {
+			return err
+		}
+		v.Set(expanded)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Field(i)
+			if !field.CanSet() {
+				continue
+			}
+			if err := expandValue(field, joinPath(path, v.Type().Field(i).Name)); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := expandValue(v.Index(i), path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			// the map values are not addressable, a copy is expanded and stored back
+			expanded := reflect.New(v.Type().Elem()).Elem()
+			expanded.Set(v.MapIndex(key))
+			if err := expandValue(expanded, joinPath(path, fmt.Sprint(key.Interface()))); err != nil {
+				return err
+			}
+			v.SetMapIndex(key, expanded)
+		}
+	}
+
+	return nil
+}
+
+// Interpolate replaces the ${VAR} and ${VAR:-default} references to the environment variables in the
+// string. The default is used when the variable is not set or empty, $${ is kept as a literal ${.
+func Interpolate(s string) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+
+		if i > 0 && s[i-1] == '$' {
+			b.WriteString(s[:i])
+			b.WriteString("{")
+			s = s[i+2:]
+			continue
+		}
+
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			return "", errors.Errorf("unterminated environment variable reference %q", s[i:])
+		}
+
+		value, err := lookupReference(s[i+2 : i+end])
+		if err != nil {
+			return "", err
+		}
+
+		b.WriteString(s[:i])
+		b.WriteString(value)
+		s = s[i+end+1:]
+	}
+}
+
+// lookupReference returns the value of the VAR or VAR:-default reference
+func lookupReference(ref string) (string, error) {
+	name, def, hasDefault := ref, "", false
+	if i := strings.Index(ref, ":-"); i >= 0 {
+		name, def, hasDefault = ref[:i], ref[i+2:], true
+	}
+
+	if !validEnvName(name) {
+		return "", errors.Errorf("invalid environment variable name %q", name)
+	}
+
+	value, ok := os.LookupEnv(name)
+	if hasDefault && value == "" {
+		return def, nil
+	}
+	if !ok {
+		return "", errors.Errorf("environment variable %s is not set and has no default", name)
+	}
+
+	return value, nil
+}
+
+func validEnvName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for i, c := range name {
+		switch {
+		case c == '_', c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func fieldPath(path string) string {
+	if path == "" {
+		return "the value"
+	}
+	return path
+}
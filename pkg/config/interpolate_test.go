@@ -0,0 +1,97 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterpolate(t *testing.T) {
+	os.Setenv("JANUS_TEST_SECRET", "s3cr3t")
+	os.Setenv("JANUS_TEST_EMPTY", "")
+	defer os.Unsetenv("JANUS_TEST_SECRET")
+	defer os.Unsetenv("JANUS_TEST_EMPTY")
+
+	for value, expected := range map[string]string{
+		"plain":                "plain",
+		"${JANUS_TEST_SECRET}": "s3cr3t",
+		"redis://:${JANUS_TEST_SECRET}@redis:6379/0": "redis://:s3cr3t@redis:6379/0",
+		"${JANUS_TEST_SECRET}-${JANUS_TEST_SECRET}":  "s3cr3t-s3cr3t",
+		"${JANUS_TEST_MISSING:-fallback}":            "fallback",
+		"${JANUS_TEST_MISSING:-}":                    "",
+		"${JANUS_TEST_EMPTY:-fallback}":              "fallback",
+		"${JANUS_TEST_EMPTY}":                        "",
+		"${JANUS_TEST_SECRET:-fallback}":             "s3cr3t",
+		"$${JANUS_TEST_SECRET}":                      "${JANUS_TEST_SECRET}",
+		"^/users/[0-9]+$":                            "^/users/[0-9]+$",
+		"$JANUS_TEST_SECRET":                         "$JANUS_TEST_SECRET",
+	} {
+		actual, err := Interpolate(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, expected, actual, value)
+	}
+
+	for value, message := range map[string]string{
+		"${JANUS_TEST_MISSING}":      "environment variable JANUS_TEST_MISSING is not set and has no default",
+		"prefix-${JANUS_TEST_SECRET": `unterminated environment variable reference "${JANUS_TEST_SECRET"`,
+		"${}":                        `invalid environment variable name ""`,
+		"${1ST}":                     `invalid environment variable name "1ST"`,
+		"${JANUS TEST}":              `invalid environment variable name "JANUS TEST"`,
+	} {
+		_, err := Interpolate(value)
+		assert.EqualError(t, err, message, value)
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	os.Setenv("JANUS_TEST_SECRET", "s3cr3t")
+	defer os.Unsetenv("JANUS_TEST_SECRET")
+
+	type nested struct {
+		Secret string
+	}
+	type value struct {
+		Name     string
+		Port     int
+		Nested   *nested
+		Values   []string
+		Headers  map[string]string
+		Config   map[string]interface{}
+		internal string
+	}
+
+	v := value{
+		Name:    "${JANUS_TEST_SECRET}",
+		Port:    8080,
+		Nested:  &nested{Secret: "${JANUS_TEST_SECRET}"},
+		Values:  []string{"${JANUS_TEST_SECRET}", "plain"},
+		Headers: map[string]string{"Authorization": "Bearer ${JANUS_TEST_SECRET}"},
+		Config: map[string]interface{}{
+			"dsn":     "redis://:${JANUS_TEST_SECRET}@redis:6379",
+			"limit":   10,
+			"servers": []interface{}{"${JANUS_TEST_SECRET:-one}", map[string]interface{}{"password": "${JANUS_TEST_SECRET}"}},
+		},
+		internal: "${JANUS_TEST_MISSING}",
+	}
+	require.NoError(t, ExpandEnv(&v))
+
+	assert.Equal(t, "s3cr3t", v.Name)
+	assert.Equal(t, 8080, v.Port)
+	assert.Equal(t, "s3cr3t", v.Nested.Secret)
+	assert.Equal(t, []string{"s3cr3t", "plain"}, v.Values)
+	assert.Equal(t, map[string]string{"Authorization": "Bearer s3cr3t"}, v.Headers)
+	assert.Equal(t, map[string]interface{}{
+		"dsn":     "redis://:s3cr3t@redis:6379",
+		"limit":   10,
+		"servers": []interface{}{"s3cr3t", map[string]interface{}{"password": "s3cr3t"}},
+	}, v.Config)
+	assert.Equal(t, "${JANUS_TEST_MISSING}", v.internal, "the unexported fields are not expanded")
+
+	v.Config["servers"] = []interface{}{map[string]interface{}{"password": "${JANUS_TEST_MISSING}"}}
+	err := ExpandEnv(&v)
+	assert.EqualError(t, err, "could not expand Config.servers[0].password: environment variable JANUS_TEST_MISSING is not set and has no default")
+
+	assert.Error(t, ExpandEnv(v), "the value must be a pointer")
+}
@@ -1,8 +1,10 @@
 package config
 
 import (
+	"os"
 	"time"
 
+	httpErrors "github.com/hellofresh/janus/pkg/errors"
 	"github.com/hellofresh/logging-go"
 	"github.com/kelseyhightower/envconfig"
 	"github.com/mitchellh/go-homedir"
@@ -13,19 +15,160 @@ import (
 // Specification for basic configurations
 type Specification struct {
 	Port                 int           `envconfig:"PORT"`
+	Listen               string        `envconfig:"LISTEN"`
+	SocketMode           os.FileMode   `envconfig:"SOCKET_MODE"`
 	GraceTimeOut         int64         `envconfig:"GRACE_TIMEOUT"`
+	ShutdownGracePeriod  time.Duration `envconfig:"SHUTDOWN_GRACE_PERIOD"`
+	DrainGracePeriod     time.Duration `envconfig:"DRAIN_GRACE_PERIOD"`
 	MaxIdleConnsPerHost  int           `envconfig:"MAX_IDLE_CONNS_PER_HOST"`
 	BackendFlushInterval time.Duration `envconfig:"BACKEND_FLUSH_INTERVAL"`
 	IdleConnTimeout      time.Duration `envconfig:"IDLE_CONN_TIMEOUT"`
 	RequestID            bool          `envconfig:"REQUEST_ID_ENABLED"`
-	Log                  logging.LogConfig
-	Web                  Web
-	Database             Database
-	Stats                Stats
-	Tracing              Tracing
-	TLS                  TLS
-	Cluster              Cluster
-	RespondingTimeouts   RespondingTimeouts
+	RequestTimeout       time.Duration `envconfig:"REQUEST_TIMEOUT"`
+	ProblemDetails       bool          `envconfig:"PROBLEM_DETAILS"`
+	// H2C serves HTTP/2 without TLS besides HTTP/1.1 on the HTTP port, e.g. for the gRPC clients
+	H2C bool `envconfig:"H2C"`
+	// ErrorTemplates are the bodies of the errors of Janus selected by the Accept header, the API
+	// definitions can override them with their own templates
+	ErrorTemplates     []httpErrors.Template `ignored:"true"`
+	Log                logging.LogConfig
+	AccessLog          AccessLog
+	SlowLog            SlowLog
+	Web                Web
+	Database           Database
+	Stats              Stats
+	Tracing            Tracing
+	TLS                TLS
+	Cluster            Cluster
+	RespondingTimeouts RespondingTimeouts
+	RequestHeaders     RequestHeaders
+	ProxyProtocol      ProxyProtocol
+	ClientIP           ClientIP
+	CorrelationID      CorrelationID
+	Buffering          Buffering
+	HealthChecks       HealthChecks
+	Webhooks           Webhooks
+	// Listeners are the named proxy listeners besides the default one, they serve the API definitions
+	// bound to them only
+	Listeners []Listener `ignored:"true"`
+}
+
+// Listener is a named proxy listener with its own address, TLS and timeouts, e.g. the partner traffic on
+// another port with mutual TLS
+type Listener struct {
+	Name    string
+	Address string
+	TLS     ListenerTLS
+	// RespondingTimeouts override the global responding timeouts they set
+	RespondingTimeouts RespondingTimeouts
+	// H2C serves HTTP/2 without TLS besides HTTP/1.1 on the listeners without certificates
+	H2C bool
+}
+
+// ListenerTLS holds the certificates and the client certificate policy of a listener serving HTTPS
+type ListenerTLS struct {
+	CertFile     string
+	KeyFile      string
+	Certificates []Certificate
+	ClientAuth   string
+}
+
+// ProxyProtocol holds the configuration of the PROXY protocol header sent by the load balancers
+// in front of the proxy listeners, e.g. AWS NLB, to recover the client address
+type ProxyProtocol struct {
+	// HTTP enables the PROXY protocol on the HTTP listener
+	HTTP bool `envconfig:"PROXY_PROTOCOL_HTTP"`
+	// HTTPS enables the PROXY protocol on the TLS listener
+	HTTPS bool `envconfig:"PROXY_PROTOCOL_HTTPS"`
+	// TrustedCIDRs are the source ranges, or addresses, the PROXY protocol header is read from
+	TrustedCIDRs []string `envconfig:"PROXY_PROTOCOL_TRUSTED_CIDRS"`
+	// HeaderTimeout is the time the header is waited for before closing the connection
+	HeaderTimeout time.Duration `envconfig:"PROXY_PROTOCOL_HEADER_TIMEOUT"`
+}
+
+// ClientIP holds the configuration of the proxies in front of the gateway setting the X-Forwarded-For
+// header, the client address is the rightmost not trusted address of the header
+type ClientIP struct {
+	// TrustedProxies are the source ranges, or addresses, of the proxies the X-Forwarded-For header is
+	// trusted from, the header is ignored when empty
+	TrustedProxies []string `envconfig:"CLIENT_IP_TRUSTED_PROXIES"`
+	// TrustUnixSocket trusts the X-Forwarded-For header of the Unix domain socket connections, e.g. of
+	// the co-located proxy of a sidecar deployment
+	TrustUnixSocket bool `envconfig:"CLIENT_IP_TRUST_UNIX_SOCKET"`
+}
+
+// CorrelationID holds the configuration of the correlation ID of the requests, it is their request ID
+type CorrelationID struct {
+	// Header is the request and response header holding the correlation ID, it is forwarded upstream
+	Header string `envconfig:"CORRELATION_ID_HEADER"`
+	// BaggageKey is the key of the correlation ID in the baggage and the spans of the request
+	BaggageKey string `envconfig:"CORRELATION_ID_BAGGAGE_KEY"`
+	// FromTrace makes the trace ID of the incoming trace context the correlation ID of the requests
+	// without the header
+	FromTrace bool `envconfig:"CORRELATION_ID_FROM_TRACE"`
+}
+
+// Buffering holds the default buffering of the requests and the responses proxied to the upstreams, the
+// API definitions can override it with their own settings
+type Buffering struct {
+	// ReadBufferSize is the size in bytes of the buffer the upstream connections are read with, the
+	// net/http default of 4KB when zero
+	ReadBufferSize int `envconfig:"BUFFERING_READ_BUFFER_SIZE"`
+	// FlushBufferSize is the size in bytes of the buffer the response bodies are copied to the clients
+	// with, 32KB when zero
+	FlushBufferSize int `envconfig:"BUFFERING_FLUSH_BUFFER_SIZE"`
+	// Stream flushes the response bodies to the clients after each write instead of every
+	// BackendFlushInterval
+	Stream bool `envconfig:"BUFFERING_STREAM"`
+	// MaxResponseBodySize is the largest upstream response body in bytes, not capped when zero
+	MaxResponseBodySize int64 `envconfig:"BUFFERING_MAX_RESPONSE_BODY_SIZE"`
+	// BufferRequestBodySize is the largest request body in bytes buffered to be sent with a
+	// Content-Length, the request bodies are streamed when zero
+	BufferRequestBodySize int64 `envconfig:"BUFFERING_BUFFER_REQUEST_BODY_SIZE"`
+}
+
+// HealthChecks holds the configuration of the upstream health checks of the health endpoints
+type HealthChecks struct {
+	// Leader runs the health checks on the instance elected through redis only, every Interval, and the
+	// health endpoints of all the instances serve its results
+	Leader bool `envconfig:"HEALTH_CHECKS_LEADER"`
+	// Interval is the time between the health checks of the leader
+	Interval time.Duration `envconfig:"HEALTH_CHECKS_INTERVAL"`
+	// LockTTL is the ttl of the leader lock, another instance takes over within it when the leader dies
+	LockTTL time.Duration `envconfig:"HEALTH_CHECKS_LOCK_TTL"`
+	// RedisDSN is the address of the redis server the leader lock and the results are kept in
+	RedisDSN string `envconfig:"HEALTH_CHECKS_REDIS_DSN"`
+	// Prefix is the prefix of the keys in redis
+	Prefix string `envconfig:"HEALTH_CHECKS_PREFIX"`
+}
+
+// Webhooks holds the configuration of the notifications sent when API definitions change
+type Webhooks struct {
+	// URLs are the endpoints the notifications are POSTed to
+	URLs []string `envconfig:"WEBHOOKS_URLS"`
+	// Secret is the shared secret used to sign the notification payload with HMAC-SHA256, not signed when empty
+	Secret string `envconfig:"WEBHOOKS_SECRET"`
+	// Timeout is the timeout of a single delivery attempt
+	Timeout time.Duration `envconfig:"WEBHOOKS_TIMEOUT"`
+	// Retries is the number of the delivery retries after the first failed attempt
+	Retries int `envconfig:"WEBHOOKS_RETRIES"`
+}
+
+// AccessLog holds the configuration of the structured access log, the API definitions can override it
+type AccessLog struct {
+	// Enabled writes one entry per request to the standard output
+	Enabled bool `envconfig:"ACCESS_LOG_ENABLED"`
+	// Format is the format of the entries, possible values are: json, common, combined
+	Format string `envconfig:"ACCESS_LOG_FORMAT"`
+	// Fields are the fields of the json entries, all the supported fields are written when empty
+	Fields []string `envconfig:"ACCESS_LOG_FIELDS"`
+}
+
+// SlowLog holds the configuration of the slow request log
+type SlowLog struct {
+	// Threshold is the time above which the requests are logged, zero disables the log unless an API
+	// definition sets its own threshold
+	Threshold time.Duration `envconfig:"SLOW_LOG_THRESHOLD"`
 }
 
 // Cluster represents the cluster configuration
@@ -34,17 +177,53 @@ type Cluster struct {
 }
 
 // RespondingTimeouts contains timeout configurations for incoming requests to the Janus instance.
+// Zero disables the timeout, ReadTimeout and WriteTimeout bound the whole request and response, including the
+// SSE, gRPC and WebSocket streams, so they are disabled by default.
 type RespondingTimeouts struct {
-	ReadTimeout  time.Duration `envconfig:"RESPONDING_TIMEOUTS_READ_TIMEOUT"`
-	WriteTimeout time.Duration `envconfig:"RESPONDING_TIMEOUTS_WRITE_TIMEOUT"`
-	IdleTimeout  time.Duration `envconfig:"RESPONDING_TIMEOUTS_IDLE_TIMEOUT"`
+	ReadTimeout       time.Duration `envconfig:"RESPONDING_TIMEOUTS_READ_TIMEOUT"`
+	ReadHeaderTimeout time.Duration `envconfig:"RESPONDING_TIMEOUTS_READ_HEADER_TIMEOUT"`
+	WriteTimeout      time.Duration `envconfig:"RESPONDING_TIMEOUTS_WRITE_TIMEOUT"`
+	IdleTimeout       time.Duration `envconfig:"RESPONDING_TIMEOUTS_IDLE_TIMEOUT"`
+}
+
+// RequestHeaders holds the limits of the incoming request headers, the requests over the limits are
+// rejected with 431 Request Header Fields Too Large before they are routed
+type RequestHeaders struct {
+	// MaxSize is the maximum total size in bytes of the request headers
+	MaxSize int `envconfig:"REQUEST_HEADERS_MAX_SIZE"`
+	// MaxCount is the maximum number of the request header lines, zero disables the limit
+	MaxCount int `envconfig:"REQUEST_HEADERS_MAX_COUNT"`
 }
 
 // Web represents the API configurations
 type Web struct {
-	Port        int `envconfig:"API_PORT"`
+	Port        int    `envconfig:"API_PORT"`
+	Listen      string `envconfig:"API_LISTEN"`
+	LivePath    string `envconfig:"API_LIVE_PATH"`
+	ReadyPath   string `envconfig:"API_READY_PATH"`
 	Credentials Credentials
 	TLS         TLS
+	Audit       Audit
+	Profiling   Profiling
+}
+
+// Profiling holds the configuration of the profiler served by the admin API at /debug/pprof
+type Profiling struct {
+	// Enabled serves the profiler endpoints, they are not served by default
+	Enabled bool `envconfig:"API_PROFILING_ENABLED"`
+	// Public serves the profiler endpoints without the admin authentication, it should never be enabled
+	// when the admin API is reachable from outside of the trusted network
+	Public bool `envconfig:"API_PROFILING_PUBLIC"`
+}
+
+// Audit holds the configuration of the admin API configuration changes audit trail
+type Audit struct {
+	// Sink is where the audit entries are written to, possible values are: log, file, store
+	Sink string `envconfig:"AUDIT_SINK"`
+	// File is the path of the file the entries are appended to by the file sink
+	File string `envconfig:"AUDIT_FILE"`
+	// Size is the number of the recent entries kept in memory for the audit endpoint
+	Size int `envconfig:"AUDIT_SIZE"`
 }
 
 // TLS represents the TLS configurations
@@ -53,6 +232,46 @@ type TLS struct {
 	CertFile string `envconfig:"CERT_PATH"`
 	KeyFile  string `envconfig:"KEY_PATH"`
 	Redirect bool   `envconfig:"REDIRECT"`
+	// RedirectStatus is the status code of the HTTP to HTTPS redirects, 301 or 308
+	RedirectStatus int `envconfig:"REDIRECT_STATUS"`
+	// RedirectPort is the port of the HTTPS redirect location, e.g. 443 behind a load balancer, Port when zero
+	RedirectPort int `envconfig:"REDIRECT_PORT"`
+	// Certificates are selected by the server name of the TLS handshakes of the proxy, the certificate
+	// files are the default for the other names
+	Certificates []Certificate `ignored:"true"`
+	// CertificatesReloadInterval is how often the certificate files are checked for changes, they are
+	// reloaded without a restart
+	CertificatesReloadInterval time.Duration `envconfig:"TLS_CERTIFICATES_RELOAD_INTERVAL"`
+	// ClientAuth is the client certificate policy of the proxy, "request" asks the clients for a certificate
+	// verified by the client_cert plugin of the routes, "require" refuses the clients without one
+	ClientAuth string `envconfig:"TLS_CLIENT_AUTH"`
+	ACME       ACME
+}
+
+// Certificate is a certificate and key pair served to the TLS clients asking for its hosts
+type Certificate struct {
+	// Hosts are the server names the certificate is served for, "*.example.com" matches the names with
+	// one more label. The DNS names of the certificate are used when they are not set.
+	Hosts    []string
+	CertFile string
+	KeyFile  string
+}
+
+// ACME holds the configuration of the certificates obtained and renewed automatically from
+// an ACME certificate authority, e.g. Let's Encrypt
+type ACME struct {
+	// Enabled obtains the certificates of the Hosts instead of using the certificate files
+	Enabled bool `envconfig:"TLS_ACME_ENABLED"`
+	// Hosts are the host names the certificates are obtained for, the TLS handshakes for other hosts are refused
+	Hosts []string `envconfig:"TLS_ACME_HOSTS"`
+	// Email is the optional contact address of the certificate authority account
+	Email string `envconfig:"TLS_ACME_EMAIL"`
+	// DirectoryURL is the ACME directory endpoint, Let's Encrypt production directory when empty
+	DirectoryURL string `envconfig:"TLS_ACME_DIRECTORY_URL"`
+	// CacheDir is the directory the certificates are stored in when the API definitions are not stored in mongodb
+	CacheDir string `envconfig:"TLS_ACME_CACHE_DIR"`
+	// RenewBefore is how long before the expiry the certificates are renewed, 30 days when zero
+	RenewBefore time.Duration `envconfig:"TLS_ACME_RENEW_BEFORE"`
 }
 
 // IsHTTPS checks if you have https enabled
@@ -60,6 +279,25 @@ func (s *TLS) IsHTTPS() bool {
 	return s.CertFile != "" && s.KeyFile != ""
 }
 
+// HasCertificates checks if the proxy serves the certificate files or the certificates selected by
+// the server name
+func (s *TLS) HasCertificates() bool {
+	return s.IsHTTPS() || len(s.Certificates) > 0
+}
+
+// HasCertificates checks if the listener serves HTTPS
+func (s *ListenerTLS) HasCertificates() bool {
+	return (s.CertFile != "" && s.KeyFile != "") || len(s.Certificates) > 0
+}
+
+// GetRedirectPort returns the port of the HTTPS redirect location
+func (s *TLS) GetRedirectPort() int {
+	if s.RedirectPort != 0 {
+		return s.RedirectPort
+	}
+	return s.Port
+}
+
 // Database holds the configuration for a database
 type Database struct {
 	DSN string `envconfig:"DATABASE_DSN"`
@@ -73,6 +311,20 @@ type Stats struct {
 	AutoDiscoverWhiteList []string `envconfig:"STATS_AUTO_DISCOVER_WHITE_LIST"`
 	ErrorsSection         string   `envconfig:"STATS_ERRORS_SECTION"`
 	Exporter              string   `envconfig:"STATS_EXPORTER"`
+	// PrometheusPath is the admin API path serving the metrics when the exporter is prometheus
+	PrometheusPath string `envconfig:"STATS_PROMETHEUS_PATH"`
+	// PrometheusExemplars attaches the trace IDs of the sampled requests to the request latency
+	// histogram, they are served to the scrapers accepting the OpenMetrics format
+	PrometheusExemplars bool `envconfig:"STATS_PROMETHEUS_EXEMPLARS"`
+	StatsD              StatsD
+}
+
+// StatsD holds the configuration for the StatsD emitter, it runs alongside the stats exporter
+type StatsD struct {
+	// Addr is the host:port of the StatsD server, the emitter is disabled when it is empty
+	Addr       string  `envconfig:"STATS_STATSD_ADDR"`
+	Prefix     string  `envconfig:"STATS_STATSD_PREFIX"`
+	SampleRate float64 `envconfig:"STATS_STATSD_SAMPLE_RATE"`
 }
 
 // Credentials represents the credentials that are going to be
@@ -84,8 +336,51 @@ type Credentials struct {
 	Secret         string        `envconfig:"SECRET"`
 	JanusAdminTeam string        `envconfig:"JANUS_ADMIN_TEAM"`
 	Timeout        time.Duration `envconfig:"TOKEN_TIMEOUT"`
-	Github         Github
-	Basic          Basic
+	// StaticSecretEnabled allows admin tokens signed with the Secret, e.g. issued by the login endpoint.
+	// It can be disabled only when JWKS is configured.
+	StaticSecretEnabled bool `envconfig:"STATIC_SECRET_ENABLED"`
+	Github              Github
+	Basic               Basic
+	JWKS                JWKS
+	RBAC                RBAC
+}
+
+// RBAC holds the role based access control configuration of the admin API
+type RBAC struct {
+	// Enabled turns on the access control, otherwise every authenticated user has full access
+	Enabled bool `envconfig:"RBAC_ENABLED"`
+	// RolesClaim is the token claim holding the user roles, either a list or a single string
+	RolesClaim string `envconfig:"RBAC_ROLES_CLAIM"`
+	// DefaultRoles are given to the users whose token does not have any role, e.g. issued by the login endpoint
+	DefaultRoles []string `envconfig:"RBAC_DEFAULT_ROLES"`
+	// Permissions map roles to the allowed methods and paths, the default viewer, editor and admin
+	// permissions are used when empty
+	Permissions []Permission `ignored:"true"`
+}
+
+// Permission allows a role to use the given HTTP methods on the given admin API paths.
+// Paths ending with "*" match the path before it and the paths below it, empty methods or paths match any.
+type Permission struct {
+	Role    string
+	Methods []string
+	Paths   []string
+}
+
+// JWKS holds the configuration of the external issuer used to validate admin JWT tokens
+type JWKS struct {
+	// URL is the issuer JSON Web Key Set location, e.g. https://issuer.example.com/.well-known/jwks.json
+	URL string `envconfig:"JWKS_URL"`
+	// Issuer is the expected "iss" claim value, not checked when empty
+	Issuer string `envconfig:"JWKS_ISSUER"`
+	// Audience is the expected "aud" claim value, not checked when empty
+	Audience string `envconfig:"JWKS_AUDIENCE"`
+	// RefreshInterval defines how often the key set is fetched again to pick up rotated keys
+	RefreshInterval time.Duration `envconfig:"JWKS_REFRESH_INTERVAL"`
+}
+
+// IsConfigured checks if JWKS is enabled
+func (j *JWKS) IsConfigured() bool {
+	return j.URL != ""
 }
 
 // Basic holds the basic users configurations
@@ -107,11 +402,32 @@ func (auth *Github) IsConfigured() bool {
 
 // Tracing represents the distributed tracing configuration
 type Tracing struct {
-	Exporter         string        `envconfig:"TRACING_EXPORTER"`
-	ServiceName      string        `envconfig:"TRACING_SERVICE_NAME"`
-	SamplingStrategy string        `envconfig:"TRACING_SAMPLING_STRATEGY"`
-	SamplingParam    float64       `envconfig:"TRACING_SAMPLING_PARAM"`
-	JaegerTracing    JaegerTracing `mapstructure:"jaeger"`
+	Exporter         string  `envconfig:"TRACING_EXPORTER"`
+	ServiceName      string  `envconfig:"TRACING_SERVICE_NAME"`
+	SamplingStrategy string  `envconfig:"TRACING_SAMPLING_STRATEGY"`
+	SamplingParam    float64 `envconfig:"TRACING_SAMPLING_PARAM"`
+	// PluginSpans enables a span for each plugin of the requests, nested in a span of their API
+	PluginSpans bool `envconfig:"TRACING_PLUGIN_SPANS"`
+	// Baggage is the allow-list of the baggage keys forwarded upstream and added to the spans, e.g. consumer
+	Baggage []string `envconfig:"TRACING_BAGGAGE"`
+	// PropagationFormat is the format of the trace context read from the requests and sent upstream, b3 or w3c
+	PropagationFormat string         `envconfig:"TRACING_PROPAGATION_FORMAT"`
+	JaegerTracing     JaegerTracing  `mapstructure:"jaeger"`
+	OTelTracing       OTelTracing    `mapstructure:"otel"`
+	ZipkinTracing     ZipkinTracing  `mapstructure:"zipkin"`
+	DatadogTracing    DatadogTracing `mapstructure:"datadog"`
+}
+
+// SamplingRate returns the rate of the sampling strategy, the share of the traces sampled by default
+func (t *Tracing) SamplingRate() float64 {
+	switch t.SamplingStrategy {
+	case "always":
+		return 1
+	case "probabilistic":
+		return t.SamplingParam
+	default:
+		return 0
+	}
 }
 
 // JaegerTracing holds the Jaeger tracing configuration
@@ -119,16 +435,59 @@ type JaegerTracing struct {
 	SamplingServerURL string `envconfig:"TRACING_JAEGER_SAMPLING_SERVER_URL"`
 }
 
+// OTelTracing holds the configuration of the OpenTelemetry collector the spans are exported to with OTLP/HTTP
+type OTelTracing struct {
+	Endpoint string            `envconfig:"TRACING_OTEL_ENDPOINT"`
+	Headers  map[string]string `envconfig:"TRACING_OTEL_HEADERS"`
+	Timeout  time.Duration     `envconfig:"TRACING_OTEL_TIMEOUT"`
+}
+
+// DatadogTracing holds the configuration of the Datadog agent the spans are reported to, the DD_ environment
+// variables of the Datadog tracers override it
+type DatadogTracing struct {
+	// AgentAddr is the host:port of the trace API of the agent
+	AgentAddr string `envconfig:"TRACING_DATADOG_AGENT_ADDR"`
+	Env       string `envconfig:"TRACING_DATADOG_ENV"`
+	Version   string `envconfig:"TRACING_DATADOG_VERSION"`
+	// Tags are added to all the spans
+	Tags map[string]string `envconfig:"TRACING_DATADOG_TAGS"`
+	// APIServices reports the spans of the APIs as the services named after the APIs
+	APIServices bool `envconfig:"TRACING_DATADOG_API_SERVICES"`
+}
+
+// ZipkinTracing holds the configuration of the Zipkin collector the spans are reported to
+type ZipkinTracing struct {
+	Endpoint string `envconfig:"TRACING_ZIPKIN_ENDPOINT"`
+	// LocalEndpoint is the host:port of the instance reported with its spans
+	LocalEndpoint string `envconfig:"TRACING_ZIPKIN_LOCAL_ENDPOINT"`
+	// B3SingleHeader sends the trace context to the upstreams in the single b3 header rather than the X-B3-* ones
+	B3SingleHeader bool `envconfig:"TRACING_ZIPKIN_B3_SINGLE_HEADER"`
+}
+
 func init() {
 	serviceName := "janus"
 
 	viper.SetDefault("port", "8080")
+	viper.SetDefault("socketMode", 0660)
 	viper.SetDefault("tls.port", "8433")
 	viper.SetDefault("tls.redirect", true)
+	viper.SetDefault("tls.redirectStatus", 301)
+	viper.SetDefault("tls.certificatesReloadInterval", time.Minute)
 	viper.SetDefault("backendFlushInterval", "20ms")
 	viper.SetDefault("requestID", true)
+	viper.SetDefault("correlationID.header", "X-Request-ID")
+	viper.SetDefault("correlationID.baggageKey", "correlation_id")
+	viper.SetDefault("correlationID.fromTrace", true)
+	viper.SetDefault("shutdownGracePeriod", 30*time.Second)
+	viper.SetDefault("drainGracePeriod", 30*time.Second)
 
+	viper.SetDefault("proxyProtocol.headerTimeout", 5*time.Second)
+
+	viper.SetDefault("respondingTimeouts.ReadHeaderTimeout", 10*time.Second)
 	viper.SetDefault("respondingTimeouts.IdleTimeout", 180*time.Second)
+	viper.SetDefault("requestHeaders.maxSize", 64<<10)
+	viper.SetDefault("requestHeaders.maxCount", 100)
+	viper.SetDefault("accessLog.format", "json")
 
 	viper.SetDefault("cluster.updateFrequency", "10s")
 	viper.SetDefault("database.dsn", "file:///etc/janus")
@@ -136,18 +495,37 @@ func init() {
 	viper.SetDefault("web.port", "8081")
 	viper.SetDefault("web.tls.port", "8444")
 	viper.SetDefault("web.tls.redirect", true)
+	viper.SetDefault("web.tls.redirectStatus", 301)
 	viper.SetDefault("web.credentials.algorithm", "HS256")
 	viper.SetDefault("web.credentials.timeout", time.Hour)
+	viper.SetDefault("web.credentials.staticSecretEnabled", true)
+	viper.SetDefault("web.credentials.jwks.refreshInterval", time.Hour)
+	viper.SetDefault("web.credentials.rbac.rolesClaim", "roles")
+	viper.SetDefault("web.audit.sink", "log")
+	viper.SetDefault("web.audit.size", 1000)
+	viper.SetDefault("web.livePath", "/live")
+	viper.SetDefault("web.readyPath", "/ready")
 	viper.SetDefault("web.credentials.basic.users", map[string]string{"admin": "admin"})
 	viper.SetDefault("web.credentials.github.teams", make(map[string]string))
 
+	viper.SetDefault("healthChecks.interval", 10*time.Second)
+	viper.SetDefault("healthChecks.lockTTL", 30*time.Second)
+	viper.SetDefault("healthChecks.prefix", "janus:health")
+
+	viper.SetDefault("webhooks.timeout", 5*time.Second)
+	viper.SetDefault("webhooks.retries", 3)
+
 	viper.SetDefault("stats.dsn", "log://")
 	viper.SetDefault("stats.errorsSection", "error-log")
 	viper.SetDefault("stats.namespace", serviceName)
+	viper.SetDefault("stats.prometheusPath", "/metrics")
+	viper.SetDefault("stats.statsd.prefix", serviceName)
+	viper.SetDefault("stats.statsd.sampleRate", 1)
 
 	viper.SetDefault("tracing.serviceName", serviceName)
 	viper.SetDefault("tracing.samplingStrategy", "probabilistic")
 	viper.SetDefault("tracing.samplingParam", 0.15)
+	viper.SetDefault("tracing.propagationFormat", "b3")
 
 	logging.InitDefaults(viper.GetViper(), "log")
 }
@@ -177,6 +555,10 @@ func Load(configFile string) (*Specification, error) {
 		return nil, err
 	}
 
+	if err := ExpandEnv(&config); err != nil {
+		return nil, errors.Wrap(err, "could not interpolate the config file")
+	}
+
 	return &config, nil
 }
 
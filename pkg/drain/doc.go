@@ -0,0 +1,3 @@
+// Package drain provides the draining of the gateway, answering the new requests with 503 while the
+// in-flight ones finish, so an instance is removed from the rotation without shutting it down.
+package drain
@@ -0,0 +1,149 @@
+package drain
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hellofresh/janus/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrDraining is the response of the new requests while the gateway is draining
+var ErrDraining = errors.New(http.StatusServiceUnavailable, "the gateway is draining")
+
+// Connections are the client connections of the gateway listeners
+type Connections interface {
+	// SetKeepAlivesEnabled enables or disables the keep-alives, the idle connections are closed when
+	// they are disabled
+	SetKeepAlivesEnabled(enabled bool)
+	// CloseAll closes the connections still open, e.g. the WebSocket and SSE streams
+	CloseAll()
+}
+
+// Status is the drain state of the gateway
+type Status struct {
+	Draining bool       `json:"draining"`
+	Since    *time.Time `json:"since,omitempty"`
+}
+
+// Drainer drains the gateway independently of its shutdown, e.g. to remove an instance from the
+// rotation for a maintenance. The state is read on every request, so draining takes effect right away.
+type Drainer struct {
+	sync.Mutex
+	draining    int32
+	since       time.Time
+	timer       *time.Timer
+	gracePeriod time.Duration
+	conns       Connections
+}
+
+// NewDrainer creates a new instance of Drainer. The connections still open once the grace period has
+// elapsed since the drain started are closed, they are not closed when the grace period is zero.
+func NewDrainer(gracePeriod time.Duration, conns Connections) *Drainer {
+	return &Drainer{gracePeriod: gracePeriod, conns: conns}
+}
+
+// IsDraining returns whether the gateway is draining
+func (d *Drainer) IsDraining() bool {
+	return atomic.LoadInt32(&d.draining) == 1
+}
+
+// Status returns the drain state of the gateway
+func (d *Drainer) Status() Status {
+	d.Lock()
+	defer d.Unlock()
+
+	return d.status()
+}
+
+// Drain starts draining the gateway, the new requests are answered with 503 and their connections are
+// closed, the in-flight requests finish. Draining a draining gateway keeps its grace period.
+func (d *Drainer) Drain() Status {
+	d.Lock()
+	defer d.Unlock()
+
+	if d.IsDraining() {
+		return d.status()
+	}
+
+	atomic.StoreInt32(&d.draining, 1)
+	d.since = time.Now()
+	if d.conns != nil {
+		d.conns.SetKeepAlivesEnabled(false)
+		if d.gracePeriod > 0 {
+			d.timer = time.AfterFunc(d.gracePeriod, d.closeConns)
+		}
+	}
+
+	log.WithField("grace_period", d.gracePeriod).Warn("The gateway is draining")
+	return d.status()
+}
+
+// Undrain stops draining the gateway, the new requests are served again
+func (d *Drainer) Undrain() Status {
+	d.Lock()
+	defer d.Unlock()
+
+	if !d.IsDraining() {
+		return d.status()
+	}
+
+	atomic.StoreInt32(&d.draining, 0)
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	if d.conns != nil {
+		d.conns.SetKeepAlivesEnabled(true)
+	}
+
+	log.WithField("drained", time.Since(d.since)).Warn("The gateway stopped draining")
+	return d.status()
+}
+
+// Check is the readiness check of the gateway, it fails while the gateway is draining
+func (d *Drainer) Check() error {
+	if d.IsDraining() {
+		return ErrDraining
+	}
+
+	return nil
+}
+
+// Handler is the middleware answering the new requests with 503 while the gateway is draining. The
+// connection is closed with the response, so the client reconnects to another instance.
+func (d *Drainer) Handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !d.IsDraining() {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Connection", "close")
+		errors.Handler(w, ErrDraining)
+	})
+}
+
+func (d *Drainer) status() Status {
+	if !d.IsDraining() {
+		return Status{}
+	}
+
+	since := d.since
+	return Status{Draining: true, Since: &since}
+}
+
+func (d *Drainer) closeConns() {
+	d.Lock()
+	defer d.Unlock()
+
+	// the timer of a previous drain may fire after the gateway was undrained and drained again
+	if !d.IsDraining() || time.Since(d.since) < d.gracePeriod {
+		return
+	}
+
+	log.WithField("grace_period", d.gracePeriod).Warn("Drain grace period exceeded, closing the remaining connections")
+	d.conns.CloseAll()
+}
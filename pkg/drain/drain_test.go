@@ -0,0 +1,98 @@
+package drain
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hellofresh/janus/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeConns struct {
+	sync.Mutex
+	keepAlives bool
+	closed     int
+}
+
+func (c *fakeConns) SetKeepAlivesEnabled(enabled bool) {
+	c.Lock()
+	defer c.Unlock()
+	c.keepAlives = enabled
+}
+
+func (c *fakeConns) CloseAll() {
+	c.Lock()
+	defer c.Unlock()
+	c.closed++
+}
+
+func (c *fakeConns) state() (bool, int) {
+	c.Lock()
+	defer c.Unlock()
+	return c.keepAlives, c.closed
+}
+
+func serve(d *Drainer) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	d.Handler(http.HandlerFunc(test.Ping)).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	return w
+}
+
+func TestHandler(t *testing.T) {
+	conns := &fakeConns{keepAlives: true}
+	d := NewDrainer(time.Hour, conns)
+
+	assert.Equal(t, http.StatusOK, serve(d).Code)
+	assert.NoError(t, d.Check())
+	assert.Equal(t, Status{}, d.Status())
+
+	status := d.Drain()
+	assert.True(t, status.Draining)
+	require.NotNil(t, status.Since)
+	assert.Equal(t, status, d.Drain(), "draining a draining gateway keeps its start")
+
+	w := serve(d)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "close", w.Header().Get("Connection"))
+	assert.Equal(t, ErrDraining, d.Check())
+	keepAlives, _ := conns.state()
+	assert.False(t, keepAlives)
+
+	assert.Equal(t, Status{}, d.Undrain())
+	assert.Equal(t, http.StatusOK, serve(d).Code)
+	assert.NoError(t, d.Check())
+	keepAlives, _ = conns.state()
+	assert.True(t, keepAlives)
+}
+
+func TestDrainClosesConnectionsAfterGracePeriod(t *testing.T) {
+	conns := &fakeConns{}
+	d := NewDrainer(50*time.Millisecond, conns)
+
+	d.Drain()
+	time.Sleep(150 * time.Millisecond)
+	_, closed := conns.state()
+	assert.Equal(t, 1, closed)
+
+	d.Undrain()
+	d.Drain()
+	d.Undrain()
+	time.Sleep(150 * time.Millisecond)
+	_, closed = conns.state()
+	assert.Equal(t, 1, closed, "the connections are not closed once the gateway is undrained")
+}
+
+func TestDrainWithoutGracePeriod(t *testing.T) {
+	conns := &fakeConns{}
+	d := NewDrainer(0, conns)
+
+	d.Drain()
+	time.Sleep(50 * time.Millisecond)
+	_, closed := conns.state()
+	assert.Equal(t, 0, closed, "the connections are not closed without a grace period")
+}
@@ -8,6 +8,7 @@ To create an error:
 package errors
 
 import (
+	"fmt"
 	"net/http"
 	"runtime/debug"
 
@@ -19,6 +20,8 @@ import (
 var (
 	// ErrRouteNotFound happens when no route was matched
 	ErrRouteNotFound = New(http.StatusNotFound, "no API found with those values")
+	// ErrMethodNotAllowed happens when a route was matched but not with the request method
+	ErrMethodNotAllowed = New(http.StatusMethodNotAllowed, "method not allowed by the API")
 	// ErrInvalidID represents an invalid identifier
 	ErrInvalidID = New(http.StatusBadRequest, "please provide a valid ID")
 )
@@ -51,23 +54,42 @@ func RecoveryHandler(w http.ResponseWriter, r *http.Request, err interface{}) {
 }
 
 // Handler marshals an error to JSON, automatically escaping HTML and setting the
-// Content-Type as application/json.
+// Content-Type as application/json. The error is rendered as problem details instead when they are
+// enabled with the ProblemDetails middleware, and with an error template when the Templates middleware
+// has one for the response.
 func Handler(w http.ResponseWriter, err interface{}) {
+	problem := ProblemDetailsEnabled(w)
+
 	switch internalErr := err.(type) {
 	case *Error:
 		log.WithFields(log.Fields{
 			"code":       internalErr.Code,
 			log.ErrorKey: internalErr.Error(),
 		}).Info("Internal error handled")
+		setTemplateError(w, internalErr.Message)
+		if problem {
+			renderProblem(w, internalErr.Code, internalErr.Message)
+			return
+		}
 		render.JSON(w, internalErr.Code, internalErr)
 	case error:
 		log.WithError(internalErr).WithField("stack", string(debug.Stack())).Error("Internal server error handled")
+		setTemplateError(w, internalErr.Error())
+		if problem {
+			renderProblem(w, http.StatusInternalServerError, internalErr.Error())
+			return
+		}
 		render.JSON(w, http.StatusInternalServerError, internalErr.Error())
 	default:
 		log.WithFields(log.Fields{
 			log.ErrorKey: err,
 			"stack":      string(debug.Stack()),
 		}).Error("Internal server error handled")
+		setTemplateError(w, fmt.Sprint(err))
+		if problem {
+			renderProblem(w, http.StatusInternalServerError, fmt.Sprint(err))
+			return
+		}
 		render.JSON(w, http.StatusInternalServerError, err)
 	}
 }
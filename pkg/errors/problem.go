@@ -0,0 +1,129 @@
+package errors
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/felixge/httpsnoop"
+	obs "github.com/hellofresh/janus/pkg/observability"
+	"go.opencensus.io/trace"
+)
+
+const (
+	// ProblemContentType is the content type of the errors rendered as problem details
+	ProblemContentType = "application/problem+json"
+
+	// problemKey and traceKey are kept in the response header map while the request is served, and
+	// removed before the response is written. They are not canonical, so they never collide with the
+	// headers of the upstream responses.
+	problemKey = "janus-problem"
+	traceKey   = "janus-error-trace"
+)
+
+// RequestIDHeader is the response header holding the request ID of the errors, it is the correlation ID
+// header of the config
+var RequestIDHeader = "X-Request-ID"
+
+// Problem is an error rendered as the problem details of RFC 7807
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	TraceID  string `json:"trace_id,omitempty"`
+}
+
+// ProblemDetails is a middleware rendering the errors handled by Handler as problem details, the
+// responses of the upstreams are left untouched. The instance of the problem is the request ID and the
+// trace ID is the one of the request span, or the one propagated by the client.
+func ProblemDetails(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		header[problemKey] = []string{"1"}
+		if traceID := requestTraceID(r); traceID != "" {
+			header[traceKey] = []string{traceID}
+		}
+
+		strip := func() {
+			delete(header, problemKey)
+			delete(header, traceKey)
+		}
+
+		handler.ServeHTTP(httpsnoop.Wrap(w, httpsnoop.Hooks{
+			WriteHeader: func(next httpsnoop.WriteHeaderFunc) httpsnoop.WriteHeaderFunc {
+				return func(code int) {
+					strip()
+					next(code)
+				}
+			},
+			Write: func(next httpsnoop.WriteFunc) httpsnoop.WriteFunc {
+				return func(b []byte) (int, error) {
+					strip()
+					return next(b)
+				}
+			},
+			ReadFrom: func(next httpsnoop.ReadFromFunc) httpsnoop.ReadFromFunc {
+				return func(src io.Reader) (int64, error) {
+					strip()
+					return next(src)
+				}
+			},
+		}), r)
+	})
+}
+
+// ProblemDetailsEnabled tells if the errors of the response are rendered as problem details
+func ProblemDetailsEnabled(w http.ResponseWriter) bool {
+	_, ok := w.Header()[problemKey]
+	return ok
+}
+
+// SetTraceID sets the trace ID of the problem details and of the error templates, e.g. once the
+// request span is started
+func SetTraceID(w http.ResponseWriter, traceID string) {
+	if ProblemDetailsEnabled(w) || TemplatesEnabled(w) {
+		w.Header()[traceKey] = []string{traceID}
+	}
+}
+
+// requestTraceID returns the trace ID of the request span, or the one propagated by the client
+func requestTraceID(r *http.Request) string {
+	if span := trace.FromContext(r.Context()); span != nil {
+		return span.SpanContext().TraceID.String()
+	}
+	if sc, ok := obs.Propagation.SpanContextFromRequest(r); ok {
+		return sc.TraceID.String()
+	}
+	return ""
+}
+
+// responseTraceID returns the trace ID kept in the response header map
+func responseTraceID(w http.ResponseWriter) string {
+	if values := w.Header()[traceKey]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// renderProblem writes the error as problem details, the title is the status text and the detail is
+// the error message
+func renderProblem(w http.ResponseWriter, code int, detail string) {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(true)
+	enc.Encode(Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(code),
+		Status:   code,
+		Detail:   detail,
+		Instance: w.Header().Get(RequestIDHeader),
+		TraceID:  responseTraceID(w),
+	})
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(code)
+	w.Write(buf.Bytes())
+}
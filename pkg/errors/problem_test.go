@@ -0,0 +1,79 @@
+package errors
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProblemDetails(t *testing.T) {
+	handler := ProblemDetails(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(RequestIDHeader, "b4f8a2c0")
+		Handler(w, New(http.StatusUnauthorized, "authorization field missing"))
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/example", nil)
+	r.Header.Set("X-B3-TraceId", "463ac35c9f6413ad48485a3953bb6124")
+	r.Header.Set("X-B3-SpanId", "a2fb4a1d1a96d312")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+	assert.NotContains(t, w.Header(), problemKey)
+	assert.NotContains(t, w.Header(), traceKey)
+
+	var problem Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, Problem{
+		Type:     "about:blank",
+		Title:    "Unauthorized",
+		Status:   http.StatusUnauthorized,
+		Detail:   "authorization field missing",
+		Instance: "b4f8a2c0",
+		TraceID:  "463ac35c9f6413ad48485a3953bb6124",
+	}, problem)
+}
+
+func TestProblemDetailsInternalError(t *testing.T) {
+	handler := ProblemDetails(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetTraceID(w, "0af7651916cd43dd8448eb211c80319c")
+		Handler(w, "something went wrong")
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.JSONEq(t, `{"type": "about:blank", "title": "Internal Server Error", "status": 500, "detail": "something went wrong", "trace_id": "0af7651916cd43dd8448eb211c80319c"}`, w.Body.String())
+}
+
+func TestProblemDetailsUpstreamResponse(t *testing.T) {
+	handler := ProblemDetails(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"message": "invalid recipe"}`))
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, `{"message": "invalid recipe"}`, w.Body.String(), "the upstream body is untouched")
+	assert.NotContains(t, w.Header(), problemKey)
+}
+
+func TestProblemDetailsDisabled(t *testing.T) {
+	w := httptest.NewRecorder()
+	SetTraceID(w, "0af7651916cd43dd8448eb211c80319c")
+	Handler(w, ErrInvalidID)
+
+	assert.False(t, ProblemDetailsEnabled(w))
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"error": "please provide a valid ID"}`, w.Body.String())
+}
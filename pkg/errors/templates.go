@@ -0,0 +1,292 @@
+package errors
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/felixge/httpsnoop"
+	baseErrors "github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// templatesKey and errorKey are kept in the response header map while the request is served, and
+	// removed before the response is written, like problemKey. errorKey holds the message of the error
+	// handled by Handler.
+	templatesKey = "janus-error-templates"
+	errorKey     = "janus-error"
+)
+
+// Template is the body of the errors handled by Handler for a content type
+type Template struct {
+	// ContentType is the media type the template is rendered for, it is selected by the Accept header
+	ContentType string `bson:"content_type" json:"content_type"`
+	// Status is the status code the template overrides the other templates of the content type for,
+	// the template is used for all the errors when it is not set
+	Status int `bson:"status" json:"status,omitempty"`
+	// Body is the template text, the text/template syntax is used and text/html bodies are escaped as
+	// html/template does
+	Body string `bson:"body" json:"body,omitempty"`
+	// File is the path of the file holding the template text, when the body is not set
+	File string `bson:"file" json:"file,omitempty"`
+}
+
+// TemplateData is the data the error templates are executed with
+type TemplateData struct {
+	Status    int
+	Title     string
+	Message   string
+	RequestID string
+	TraceID   string
+}
+
+type executor interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+type compiledTemplate struct {
+	mediaType   string
+	contentType string
+	status      int
+	executor    executor
+}
+
+// Templates is a set of error templates selected by content negotiation
+type Templates struct {
+	templates []compiledTemplate
+}
+
+// NewTemplates compiles the error templates, the templates of a file are read once
+func NewTemplates(templates []Template) (*Templates, error) {
+	t := &Templates{}
+	for i, tmpl := range templates {
+		compiled, err := compileTemplate(i, tmpl)
+		if err != nil {
+			return nil, err
+		}
+		t.templates = append(t.templates, compiled)
+	}
+
+	return t, nil
+}
+
+// ValidateTemplates checks the error templates can be compiled
+func ValidateTemplates(templates []Template) error {
+	_, err := NewTemplates(templates)
+	return err
+}
+
+func compileTemplate(i int, tmpl Template) (compiledTemplate, error) {
+	mediaType, _, err := mime.ParseMediaType(tmpl.ContentType)
+	if err != nil || !strings.Contains(mediaType, "/") {
+		return compiledTemplate{}, baseErrors.Errorf("error template %d: invalid content type %q", i, tmpl.ContentType)
+	}
+	if tmpl.Status != 0 && (tmpl.Status < 400 || tmpl.Status > 599) {
+		return compiledTemplate{}, baseErrors.Errorf("error template %d: invalid status %d", i, tmpl.Status)
+	}
+
+	body := tmpl.Body
+	if body == "" {
+		if tmpl.File == "" {
+			return compiledTemplate{}, baseErrors.Errorf("error template %d: body or file is required", i)
+		}
+		b, err := ioutil.ReadFile(tmpl.File)
+		if err != nil {
+			return compiledTemplate{}, baseErrors.Wrapf(err, "error template %d: cannot read the file", i)
+		}
+		body = string(b)
+	}
+
+	name := "error-" + strconv.Itoa(i)
+	var exec executor
+	if mediaType == "text/html" {
+		exec, err = htmltemplate.New(name).Parse(body)
+	} else {
+		exec, err = texttemplate.New(name).Parse(body)
+	}
+	if err != nil {
+		return compiledTemplate{}, baseErrors.Wrapf(err, "error template %d: cannot parse the template", i)
+	}
+
+	return compiledTemplate{mediaType: mediaType, contentType: tmpl.ContentType, status: tmpl.Status, executor: exec}, nil
+}
+
+// Handler is a middleware rendering the errors handled by Handler with the template of the response
+// status and of the content type accepted by the client, the responses of the upstreams are left
+// untouched. The template of the status wins over the ones for all the errors, and the default body is
+// sent when no template matches the Accept header, a template is not selected by */* alone. The
+// innermost set is used when the middleware is nested, so the templates of an API override the global
+// ones.
+func (t *Templates) Handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		header[templatesKey] = []string{"1"}
+		if _, ok := header[traceKey]; !ok {
+			if traceID := requestTraceID(r); traceID != "" {
+				header[traceKey] = []string{traceID}
+			}
+		}
+
+		accept := r.Header.Get("Accept")
+		rendered := false
+		strip := func() {
+			delete(header, templatesKey)
+			delete(header, errorKey)
+			delete(header, traceKey)
+		}
+
+		handler.ServeHTTP(httpsnoop.Wrap(w, httpsnoop.Hooks{
+			WriteHeader: func(next httpsnoop.WriteHeaderFunc) httpsnoop.WriteHeaderFunc {
+				return func(code int) {
+					message, handled := header[errorKey]
+					traceID := responseTraceID(w)
+					strip()
+					if !handled {
+						next(code)
+						return
+					}
+
+					tmpl := t.match(code, accept)
+					if tmpl == nil {
+						next(code)
+						return
+					}
+
+					buf := &bytes.Buffer{}
+					err := tmpl.executor.Execute(buf, TemplateData{
+						Status:    code,
+						Title:     http.StatusText(code),
+						Message:   strings.Join(message, ""),
+						RequestID: header.Get(RequestIDHeader),
+						TraceID:   traceID,
+					})
+					if err != nil {
+						log.WithError(err).Error("Error executing the error template")
+						next(code)
+						return
+					}
+
+					rendered = true
+					header.Set("Content-Type", tmpl.contentType)
+					header.Del("Content-Length")
+					next(code)
+					w.Write(buf.Bytes())
+				}
+			},
+			Write: func(next httpsnoop.WriteFunc) httpsnoop.WriteFunc {
+				return func(b []byte) (int, error) {
+					if rendered {
+						return len(b), nil
+					}
+					strip()
+					return next(b)
+				}
+			},
+			ReadFrom: func(next httpsnoop.ReadFromFunc) httpsnoop.ReadFromFunc {
+				return func(src io.Reader) (int64, error) {
+					if rendered {
+						return io.Copy(ioutil.Discard, src)
+					}
+					strip()
+					return next(src)
+				}
+			},
+		}), r)
+	})
+}
+
+// TemplatesEnabled tells if the errors of the response are rendered with the error templates
+func TemplatesEnabled(w http.ResponseWriter) bool {
+	_, ok := w.Header()[templatesKey]
+	return ok
+}
+
+// setTemplateError keeps the message of the handled error for the error templates
+func setTemplateError(w http.ResponseWriter, message string) {
+	if TemplatesEnabled(w) {
+		w.Header()[errorKey] = []string{message}
+	}
+}
+
+// match returns the template of the most preferred media type accepted by the client, the template of
+// the status is preferred over the one for all the errors
+func (t *Templates) match(status int, accept string) *compiledTemplate {
+	for _, mediaRange := range parseAccept(accept) {
+		if mediaRange == "*/*" {
+			continue
+		}
+
+		var fallback *compiledTemplate
+		for i := range t.templates {
+			tmpl := &t.templates[i]
+			if !matchMediaRange(mediaRange, tmpl.mediaType) {
+				continue
+			}
+			if tmpl.status == status {
+				return tmpl
+			}
+			if tmpl.status == 0 && fallback == nil {
+				fallback = tmpl
+			}
+		}
+		if fallback != nil {
+			return fallback
+		}
+	}
+
+	return nil
+}
+
+func matchMediaRange(mediaRange, mediaType string) bool {
+	if strings.HasSuffix(mediaRange, "/*") {
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(mediaRange, "*"))
+	}
+	return mediaRange == mediaType
+}
+
+// parseAccept returns the media ranges of the Accept header accepted by the client, the most preferred
+// first
+func parseAccept(accept string) []string {
+	type mediaRange struct {
+		value string
+		q     float64
+	}
+
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if raw, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(raw, 64); err != nil {
+				continue
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+
+		ranges = append(ranges, mediaRange{value: mediaType, q: q})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+
+	values := make([]string, len(ranges))
+	for i, r := range ranges {
+		values[i] = r.value
+	}
+	return values
+}
@@ -0,0 +1,160 @@
+package errors
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveTemplates(t *testing.T, templates *Templates, accept string, handler http.HandlerFunc) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/example", nil)
+	if accept != "" {
+		r.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	templates.Handler(handler).ServeHTTP(w, r)
+
+	assert.NotContains(t, w.Header(), templatesKey)
+	assert.NotContains(t, w.Header(), errorKey)
+	assert.NotContains(t, w.Header(), traceKey)
+	return w
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(RequestIDHeader, "b4f8a2c0")
+	SetTraceID(w, "463ac35c9f6413ad48485a3953bb6124")
+	Handler(w, New(http.StatusUnauthorized, "authorization field missing"))
+}
+
+func TestTemplates(t *testing.T) {
+	templates, err := NewTemplates([]Template{
+		{ContentType: "text/html", Body: "<h1>{{.Status}} {{.Title}}</h1><p>{{.Message}}</p>"},
+		{ContentType: "text/html", Status: http.StatusUnauthorized, Body: "<p>{{.Message}} ({{.RequestID}}, {{.TraceID}})</p>"},
+		{ContentType: "text/plain; charset=utf-8", Body: "{{.Status}}: {{.Message}}"},
+	})
+	require.NoError(t, err)
+
+	w := serveTemplates(t, templates, "text/html,application/xhtml+xml;q=0.9", unauthorized)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "text/html", w.Header().Get("Content-Type"))
+	assert.Equal(t, "<p>authorization field missing (b4f8a2c0, 463ac35c9f6413ad48485a3953bb6124)</p>", w.Body.String(),
+		"the template of the status wins")
+
+	w = serveTemplates(t, templates, "text/html", func(w http.ResponseWriter, r *http.Request) {
+		Handler(w, New(http.StatusNotFound, "<no API>"))
+	})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "<h1>404 Not Found</h1><p>&lt;no API&gt;</p>", w.Body.String(), "the html templates are escaped")
+
+	w = serveTemplates(t, templates, "application/json;q=0.5, text/*;q=0.8", unauthorized)
+	assert.Equal(t, "text/html", w.Header().Get("Content-Type"), "the most preferred media type is selected")
+
+	w = serveTemplates(t, templates, "text/plain", func(w http.ResponseWriter, r *http.Request) {
+		Handler(w, "something went wrong")
+	})
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "500: something went wrong", w.Body.String())
+}
+
+func TestTemplatesFallback(t *testing.T) {
+	templates, err := NewTemplates([]Template{{ContentType: "text/html", Body: "<p>{{.Message}}</p>"}})
+	require.NoError(t, err)
+
+	for _, accept := range []string{"", "*/*", "application/json", "text/html;q=0"} {
+		w := serveTemplates(t, templates, accept, unauthorized)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"), accept)
+		assert.JSONEq(t, `{"error": "authorization field missing"}`, w.Body.String(), accept)
+	}
+}
+
+func TestTemplatesProblemDetailsFallback(t *testing.T) {
+	templates, err := NewTemplates([]Template{{ContentType: "text/html", Body: "<p>{{.Message}}</p>"}})
+	require.NoError(t, err)
+
+	handler := ProblemDetails(templates.Handler(http.HandlerFunc(unauthorized)))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", "text/html")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, "text/html", w.Header().Get("Content-Type"))
+	assert.Equal(t, "<p>authorization field missing</p>", w.Body.String())
+}
+
+func TestTemplatesOverride(t *testing.T) {
+	global, err := NewTemplates([]Template{
+		{ContentType: "text/html", Body: "global"},
+		{ContentType: "text/plain", Body: "global"},
+	})
+	require.NoError(t, err)
+	api, err := NewTemplates([]Template{{ContentType: "text/html", Body: "api"}})
+	require.NoError(t, err)
+
+	handler := global.Handler(api.Handler(http.HandlerFunc(unauthorized)))
+	for accept, body := range map[string]string{"text/html": "api", "text/plain": `{"error":"authorization field missing"}` + "\n"} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, body, w.Body.String(), accept)
+	}
+}
+
+func TestTemplatesUpstreamResponse(t *testing.T) {
+	templates, err := NewTemplates([]Template{{ContentType: "text/html", Body: "<p>{{.Message}}</p>"}})
+	require.NoError(t, err)
+
+	w := serveTemplates(t, templates, "text/html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"message": "invalid recipe"}`))
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, `{"message": "invalid recipe"}`, w.Body.String(), "the upstream body is untouched")
+}
+
+func TestNewTemplatesFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "templates")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "error.html")
+	require.NoError(t, ioutil.WriteFile(file, []byte("<p>{{.Title}}</p>"), 0644))
+
+	templates, err := NewTemplates([]Template{{ContentType: "text/html", File: file}})
+	require.NoError(t, err)
+
+	w := serveTemplates(t, templates, "text/html", unauthorized)
+	assert.Equal(t, "<p>Unauthorized</p>", w.Body.String())
+}
+
+func TestNewTemplatesInvalid(t *testing.T) {
+	for name, tmpl := range map[string]Template{
+		"content type": {ContentType: "html", Body: "error"},
+		"status":       {ContentType: "text/html", Status: http.StatusOK, Body: "error"},
+		"body":         {ContentType: "text/html"},
+		"file":         {ContentType: "text/html", File: "/not/found.html"},
+		"syntax":       {ContentType: "text/plain", Body: "{{.Message"},
+	} {
+		_, err := NewTemplates([]Template{tmpl})
+		assert.Error(t, err, name)
+	}
+}
+
+func TestParseAccept(t *testing.T) {
+	assert.Equal(t, []string{"text/html", "application/xhtml+xml", "*/*"},
+		parseAccept("*/*;q=0.8, text/html, application/xhtml+xml;q=0.9, image/webp;q=0"))
+	assert.Empty(t, parseAccept(""))
+}
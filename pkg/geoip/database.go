@@ -0,0 +1,103 @@
+package geoip
+
+import (
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultReloadInterval is how often the database file is checked for changes when no interval is set
+const DefaultReloadInterval = time.Minute
+
+// Database is a MaxMind DB file reloaded when it is modified, e.g. by geoipupdate, so the
+// lookups use the latest database without restarting Janus
+type Database struct {
+	path     string
+	interval time.Duration
+
+	sync.RWMutex
+	reader  *Reader
+	modTime time.Time
+	checked time.Time
+}
+
+// OpenDatabase loads the MaxMind DB file, it is checked for changes at most once per interval
+func OpenDatabase(path string, interval time.Duration) (*Database, error) {
+	if interval <= 0 {
+		interval = DefaultReloadInterval
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Database{
+		path:     path,
+		interval: interval,
+		reader:   reader,
+		modTime:  info.ModTime(),
+		checked:  time.Now(),
+	}, nil
+}
+
+// LookupCountry returns the country of the IP address with the latest database
+func (db *Database) LookupCountry(ip net.IP) (Country, bool, error) {
+	db.reloadIfModified()
+
+	db.RLock()
+	reader := db.reader
+	db.RUnlock()
+
+	return reader.LookupCountry(ip)
+}
+
+// reloadIfModified loads the database again when the file was modified, the requests keep using
+// the previous database while it loads, and when the new one is invalid
+func (db *Database) reloadIfModified() {
+	db.RLock()
+	due := time.Since(db.checked) >= db.interval
+	db.RUnlock()
+	if !due {
+		return
+	}
+
+	db.Lock()
+	if time.Since(db.checked) < db.interval {
+		db.Unlock()
+		return
+	}
+	db.checked = time.Now()
+	modTime := db.modTime
+	db.Unlock()
+
+	logger := log.WithField("path", db.path)
+	info, err := os.Stat(db.path)
+	if err != nil {
+		logger.WithError(err).Warn("Could not check the GeoIP database for changes")
+		return
+	}
+	if info.ModTime().Equal(modTime) {
+		return
+	}
+
+	reader, err := Open(db.path)
+	if err != nil {
+		logger.WithError(err).Error("Could not reload the GeoIP database, keeping the previous one")
+		return
+	}
+
+	db.Lock()
+	db.reader = reader
+	db.modTime = info.ModTime()
+	db.Unlock()
+
+	logger.WithField("type", reader.DatabaseType).Info("GeoIP database reloaded")
+}
@@ -0,0 +1,56 @@
+package geoip
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatabaseReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geoip")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := writeDatabase(t, dir, testNetworks)
+	db, err := OpenDatabase(path, time.Millisecond)
+	require.NoError(t, err)
+
+	country, ok, err := db.LookupCountry(net.ParseIP("81.2.69.142"))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "GB", country.ISOCode)
+
+	// the database is replaced the way geoipupdate does it, with a rename
+	updated := filepath.Join(dir, "updated.mmdb")
+	require.NoError(t, ioutil.WriteFile(updated, buildDatabase([]network{
+		{"81.2.69.0/24", map[string]interface{}{"country": map[string]interface{}{"iso_code": "IE"}}},
+	}), 0644))
+	future := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(updated, future, future))
+	require.NoError(t, os.Rename(updated, path))
+	time.Sleep(2 * time.Millisecond)
+
+	country, _, err = db.LookupCountry(net.ParseIP("81.2.69.142"))
+	require.NoError(t, err)
+	assert.Equal(t, "IE", country.ISOCode)
+
+	// an invalid database is not loaded
+	require.NoError(t, ioutil.WriteFile(path, []byte("corrupted"), 0644))
+	require.NoError(t, os.Chtimes(path, future.Add(time.Hour), future.Add(time.Hour)))
+	time.Sleep(2 * time.Millisecond)
+
+	country, _, err = db.LookupCountry(net.ParseIP("81.2.69.142"))
+	require.NoError(t, err)
+	assert.Equal(t, "IE", country.ISOCode, "the previous database is kept")
+}
+
+func TestOpenDatabaseMissing(t *testing.T) {
+	_, err := OpenDatabase(filepath.Join(os.TempDir(), "missing.mmdb"), 0)
+	assert.Error(t, err)
+}
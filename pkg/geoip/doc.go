@@ -0,0 +1,3 @@
+// Package geoip resolves the IP addresses to their country with a MaxMind DB, e.g. the GeoIP2 and the GeoLite2
+// Country and City databases.
+package geoip
@@ -0,0 +1,79 @@
+package geoip
+
+import (
+	"io/ioutil"
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/pkg/errors"
+)
+
+// Country is the location of an IP address
+type Country struct {
+	// ISOCode is the ISO 3166-1 alpha-2 code of the country, e.g. DE
+	ISOCode string
+	// Continent is the continent code, e.g. EU
+	Continent string
+}
+
+// countryRecord holds the fields of the GeoIP2 and GeoLite2 Country and City records the country is read from
+type countryRecord struct {
+	Continent struct {
+		Code string `maxminddb:"code"`
+	} `maxminddb:"continent"`
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+}
+
+// Reader looks up the IP addresses in a MaxMind DB loaded in memory, the database is not memory mapped
+// so a reloaded file does not invalidate the lookups still using the previous reader
+type Reader struct {
+	// DatabaseType is the type of the database, e.g. GeoLite2-Country
+	DatabaseType string
+
+	db *maxminddb.Reader
+}
+
+// Open reads the MaxMind DB file
+func Open(path string) (*Reader, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read the MaxMind DB")
+	}
+
+	return FromBytes(buf)
+}
+
+// FromBytes creates a Reader of the MaxMind DB content
+func FromBytes(buf []byte) (*Reader, error) {
+	db, err := maxminddb.FromBytes(buf)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid MaxMind DB")
+	}
+
+	return &Reader{DatabaseType: db.Metadata.DatabaseType, db: db}, nil
+}
+
+// LookupCountry returns the country of the IP address, it returns false when the address is not in the
+// database, e.g. a private address
+func (r *Reader) LookupCountry(ip net.IP) (Country, bool, error) {
+	if ip.To4() == nil && r.db.Metadata.IPVersion == 4 {
+		return Country{}, false, nil
+	}
+
+	var record countryRecord
+	if _, ok, err := r.db.LookupNetwork(ip, &record); !ok || err != nil {
+		return Country{}, false, err
+	}
+
+	country := Country{ISOCode: record.Country.ISOCode, Continent: record.Continent.Code}
+	if country.ISOCode == "" {
+		country.ISOCode = record.RegisteredCountry.ISOCode
+	}
+
+	return country, country.ISOCode != "" || country.Continent != "", nil
+}
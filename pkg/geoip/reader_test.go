@@ -0,0 +1,202 @@
+package geoip
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the MaxMind DB format used to build the test databases
+const (
+	typeString           = 2
+	typeUint16           = 5
+	typeUint32           = 6
+	typeMap              = 7
+	dataSectionSeparator = 16
+)
+
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+func encodeControl(typ int, size int) []byte {
+	return []byte{byte(typ<<5 | size)}
+}
+
+func encodeUint(typ int, value uint64) []byte {
+	var b []byte
+	for ; value > 0; value >>= 8 {
+		b = append([]byte{byte(value)}, b...)
+	}
+
+	return append(encodeControl(typ, len(b)), b...)
+}
+
+// encode encodes the value with the MaxMind DB data section format, the strings and maps are shorter
+// than 29 bytes and entries in the test databases
+func encode(value interface{}) []byte {
+	switch v := value.(type) {
+	case string:
+		return append(encodeControl(typeString, len(v)), v...)
+	case uint16:
+		return encodeUint(typeUint16, uint64(v))
+	case uint32:
+		return encodeUint(typeUint32, uint64(v))
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		b := encodeControl(typeMap, len(v))
+		for _, key := range keys {
+			b = append(b, encode(key)...)
+			b = append(b, encode(v[key])...)
+		}
+		return b
+	default:
+		panic("unsupported type")
+	}
+}
+
+type network struct {
+	cidr string
+	data interface{}
+}
+
+// buildDatabase writes an IPv6 MaxMind DB with 24 bits records, the IPv4 networks are stored in the
+// ::/96 subtree
+func buildDatabase(networks []network) []byte {
+	const empty, dataFlag = -1, 1 << 30
+
+	var data []byte
+	nodes := [][2]int{{empty, empty}}
+	for _, n := range networks {
+		_, ipNet, err := net.ParseCIDR(n.cidr)
+		if err != nil {
+			panic(err)
+		}
+		ip, ones := ipNet.IP, 0
+		ones, _ = ipNet.Mask.Size()
+		if ipv4 := ip.To4(); ipv4 != nil {
+			ip, ones = append(make(net.IP, 12), ipv4...), ones+96
+		}
+
+		value := dataFlag | len(data)
+		data = append(data, encode(n.data)...)
+
+		node := 0
+		for i := 0; i < ones; i++ {
+			bit := int(ip[i>>3]>>(7-uint(i&7))) & 1
+			if i == ones-1 {
+				nodes[node][bit] = value
+				break
+			}
+			if nodes[node][bit] == empty {
+				nodes = append(nodes, [2]int{empty, empty})
+				nodes[node][bit] = len(nodes) - 1
+			}
+			node = nodes[node][bit]
+		}
+	}
+
+	var buf []byte
+	for _, node := range nodes {
+		for _, record := range node {
+			value := record
+			switch {
+			case record == empty:
+				value = len(nodes)
+			case record&dataFlag != 0:
+				value = len(nodes) + dataSectionSeparator + record&^dataFlag
+			}
+			buf = append(buf, byte(value>>16), byte(value>>8), byte(value))
+		}
+	}
+
+	buf = append(buf, make([]byte, dataSectionSeparator)...)
+	buf = append(buf, data...)
+	buf = append(buf, metadataMarker...)
+	buf = append(buf, encode(map[string]interface{}{
+		"node_count":    uint32(len(nodes)),
+		"record_size":   uint16(24),
+		"ip_version":    uint16(6),
+		"database_type": "Test-Country",
+	})...)
+
+	return buf
+}
+
+var testNetworks = []network{
+	{"81.2.69.0/24", map[string]interface{}{
+		"continent": map[string]interface{}{"code": "EU"},
+		"country":   map[string]interface{}{"iso_code": "GB"},
+	}},
+	{"175.16.199.0/24", map[string]interface{}{
+		"continent":          map[string]interface{}{"code": "AS"},
+		"registered_country": map[string]interface{}{"iso_code": "CN"},
+	}},
+	{"2001:db8::/32", map[string]interface{}{
+		"continent": map[string]interface{}{"code": "EU"},
+		"country":   map[string]interface{}{"iso_code": "DE"},
+	}},
+}
+
+func writeDatabase(t *testing.T, dir string, networks []network) string {
+	path := filepath.Join(dir, "test.mmdb")
+	require.NoError(t, ioutil.WriteFile(path, buildDatabase(networks), 0644))
+
+	return path
+}
+
+func TestReaderLookupCountry(t *testing.T) {
+	r, err := FromBytes(buildDatabase(testNetworks))
+	require.NoError(t, err)
+	assert.Equal(t, "Test-Country", r.DatabaseType)
+
+	tests := map[string]Country{
+		"81.2.69.142":         {ISOCode: "GB", Continent: "EU"},
+		"::ffff:81.2.69.1":    {ISOCode: "GB", Continent: "EU"},
+		"175.16.199.10":       {ISOCode: "CN", Continent: "AS"},
+		"2001:db8:1234::beef": {ISOCode: "DE", Continent: "EU"},
+	}
+	for ip, expected := range tests {
+		country, ok, err := r.LookupCountry(net.ParseIP(ip))
+		require.NoError(t, err)
+		assert.True(t, ok, ip)
+		assert.Equal(t, expected, country, ip)
+	}
+
+	for _, ip := range []string{"10.0.0.1", "81.2.70.1", "2001:db9::1"} {
+		_, ok, err := r.LookupCountry(net.ParseIP(ip))
+		require.NoError(t, err)
+		assert.False(t, ok, ip)
+	}
+}
+
+func TestReaderInvalid(t *testing.T) {
+	_, err := FromBytes([]byte("not a database"))
+	assert.Error(t, err)
+
+	db := buildDatabase(testNetworks)
+	_, err = FromBytes(db[len(db)-40:])
+	assert.Error(t, err, "the search tree is missing")
+}
+
+func TestOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geoip")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	r, err := Open(writeDatabase(t, dir, testNetworks))
+	require.NoError(t, err)
+	assert.Equal(t, "Test-Country", r.DatabaseType)
+
+	_, err = Open(filepath.Join(dir, "missing.mmdb"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,77 @@
+package group
+
+import (
+	"net/http"
+
+	"github.com/hellofresh/janus/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/ulule/limiter"
+)
+
+// DefaultGroup is the baseline tier of the consumers that are not assigned to a group
+const DefaultGroup = "default"
+
+var (
+	// ErrGroupNotFound is used when a group is not found
+	ErrGroupNotFound = errors.New(http.StatusNotFound, "consumer group not found")
+	// ErrGroupExists is used when a group already exists
+	ErrGroupExists = errors.New(http.StatusConflict, "consumer group already exists")
+	// ErrInvalidAdminRouter is used when an invalid admin router is given
+	ErrInvalidAdminRouter = errors.New(http.StatusNotFound, "invalid admin router given")
+
+	repo Repository = NewInMemoryRepository()
+)
+
+// Group is a tier of consumers sharing the same limits, the limits apply to each member
+type Group struct {
+	Name      string   `json:"name" bson:"name"`
+	Consumers []string `json:"consumers" bson:"consumers"`
+	// RateLimit is the rate of the requests of each member, in the rate_limit plugin format
+	RateLimit string `json:"rate_limit,omitempty" bson:"rate_limit,omitempty"`
+	// Quota is the number of the requests of each member in the quota plugin window
+	Quota int64 `json:"quota,omitempty" bson:"quota,omitempty"`
+}
+
+// Validate checks the group name and limits
+func (g *Group) Validate() error {
+	if g.Name == "" {
+		return errors.New(http.StatusBadRequest, "consumer group name is required")
+	}
+	if g.RateLimit != "" {
+		if _, err := limiter.NewRateFromFormatted(g.RateLimit); err != nil {
+			return errors.New(http.StatusBadRequest, "invalid consumer group rate limit: "+err.Error())
+		}
+	}
+	if g.Quota < 0 {
+		return errors.New(http.StatusBadRequest, "consumer group quota can not be negative")
+	}
+
+	return nil
+}
+
+// Repository represents a consumer group repository
+type Repository interface {
+	FindAll() ([]*Group, error)
+	FindByName(name string) (*Group, error)
+	FindByConsumer(consumer string) (*Group, error)
+	Add(group *Group) error
+	Remove(name string) error
+}
+
+// Find returns the group of the consumer, the default group when the consumer is not assigned to a
+// group, or nil when neither is defined and the limits of the plugin configuration apply
+func Find(consumer string) *Group {
+	g, err := repo.FindByConsumer(consumer)
+	if err == ErrGroupNotFound {
+		g, err = repo.FindByName(DefaultGroup)
+	}
+
+	if err != nil {
+		if err != ErrGroupNotFound {
+			log.WithError(err).WithField("consumer", consumer).Error("Could not find the consumer group")
+		}
+		return nil
+	}
+
+	return g
+}
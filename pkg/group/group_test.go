@@ -0,0 +1,31 @@
+package group
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// withRepository replaces the repository used by Find and returns the function restoring it
+func withRepository(r Repository) func() {
+	previous := repo
+	repo = r
+	return func() { repo = previous }
+}
+
+func TestFind(t *testing.T) {
+	defer withRepository(newInMemoryRepo())()
+
+	assert.Equal(t, "gold", Find("alice").Name)
+	assert.Equal(t, DefaultGroup, Find("carol").Name, "ungrouped consumers get the baseline tier")
+
+	repo.Remove(DefaultGroup)
+	assert.Nil(t, Find("carol"))
+}
+
+func TestGroupValidate(t *testing.T) {
+	assert.NoError(t, (&Group{Name: "gold", RateLimit: "100-M", Quota: 1000}).Validate())
+	assert.Error(t, (&Group{RateLimit: "100-M"}).Validate(), "the name is required")
+	assert.Error(t, (&Group{Name: "gold", RateLimit: "often"}).Validate())
+	assert.Error(t, (&Group{Name: "gold", Quota: -1}).Validate())
+}
@@ -0,0 +1,162 @@
+package group
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/hellofresh/janus/pkg/errors"
+	"github.com/hellofresh/janus/pkg/render"
+	"github.com/hellofresh/janus/pkg/router"
+	"go.opencensus.io/trace"
+)
+
+// Handler is the api rest handlers
+type Handler struct {
+	repo Repository
+}
+
+// NewHandler creates a new instance of Handler
+func NewHandler(repo Repository) *Handler {
+	return &Handler{repo}
+}
+
+// Index is the find all handler
+func (c *Handler) Index() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, span := trace.StartSpan(r.Context(), "repo.FindAll")
+		data, err := c.repo.FindAll()
+		span.End()
+
+		if err != nil {
+			errors.Handler(w, err)
+			return
+		}
+
+		render.JSON(w, http.StatusOK, data)
+	}
+}
+
+// Show is the find by handler
+func (c *Handler) Show() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := router.URLParam(r, "name")
+		_, span := trace.StartSpan(r.Context(), "repo.FindByName")
+		data, err := c.repo.FindByName(name)
+		span.End()
+
+		if err != nil {
+			errors.Handler(w, err)
+			return
+		}
+
+		render.JSON(w, http.StatusOK, data)
+	}
+}
+
+// Update is the update handler, the members of the group get the new limits on their next request
+func (c *Handler) Update() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := router.URLParam(r, "name")
+		_, span := trace.StartSpan(r.Context(), "repo.FindByName")
+		existing, err := c.repo.FindByName(name)
+		span.End()
+
+		if err != nil {
+			errors.Handler(w, err)
+			return
+		}
+
+		g := *existing
+		g.Consumers = append([]string(nil), existing.Consumers...)
+		if err := json.NewDecoder(r.Body).Decode(&g); err != nil {
+			errors.Handler(w, errors.New(http.StatusBadRequest, err.Error()))
+			return
+		}
+		// the name identifies the group, a group is renamed by creating a new one
+		g.Name = name
+
+		if err := c.save(r, &g); err != nil {
+			errors.Handler(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// Create is the create handler
+func (c *Handler) Create() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		g := &Group{}
+		if err := json.NewDecoder(r.Body).Decode(g); err != nil {
+			errors.Handler(w, errors.New(http.StatusBadRequest, err.Error()))
+			return
+		}
+
+		_, span := trace.StartSpan(r.Context(), "repo.FindByName")
+		_, err := c.repo.FindByName(g.Name)
+		span.End()
+
+		if err == nil {
+			errors.Handler(w, ErrGroupExists)
+			return
+		}
+		if err != ErrGroupNotFound {
+			errors.Handler(w, err)
+			return
+		}
+
+		if err := c.save(r, g); err != nil {
+			errors.Handler(w, err)
+			return
+		}
+
+		w.Header().Add("Location", fmt.Sprintf("/consumer-groups/%s", g.Name))
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+// Delete is the delete handler, the members of the group fall back to the default group
+func (c *Handler) Delete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := router.URLParam(r, "name")
+
+		_, span := trace.StartSpan(r.Context(), "repo.Remove")
+		err := c.repo.Remove(name)
+		span.End()
+
+		if err != nil {
+			errors.Handler(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// save validates and stores the group, a consumer can be a member of one group only
+func (c *Handler) save(r *http.Request, g *Group) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+
+	for _, consumer := range g.Consumers {
+		_, span := trace.StartSpan(r.Context(), "repo.FindByConsumer")
+		assigned, err := c.repo.FindByConsumer(consumer)
+		span.End()
+
+		if err == nil && assigned.Name != g.Name {
+			return errors.New(http.StatusConflict, fmt.Sprintf("consumer %q is already assigned to the group %q", consumer, assigned.Name))
+		}
+		if err != nil && err != ErrGroupNotFound {
+			return err
+		}
+	}
+
+	_, span := trace.StartSpan(r.Context(), "repo.Add")
+	err := c.repo.Add(g)
+	span.End()
+
+	return err
+}
@@ -0,0 +1,82 @@
+package group
+
+import (
+	"sort"
+	"sync"
+)
+
+// InMemoryRepository represents a in memory repository
+type InMemoryRepository struct {
+	sync.RWMutex
+	groups map[string]*Group
+}
+
+// NewInMemoryRepository creates a in memory repository
+func NewInMemoryRepository() *InMemoryRepository {
+	return &InMemoryRepository{groups: make(map[string]*Group)}
+}
+
+// FindAll fetches all the groups sorted by name
+func (r *InMemoryRepository) FindAll() ([]*Group, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	groups := make([]*Group, 0, len(r.groups))
+	for _, g := range r.groups {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+
+	return groups, nil
+}
+
+// FindByName finds a group by name
+func (r *InMemoryRepository) FindByName(name string) (*Group, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	g, ok := r.groups[name]
+	if !ok {
+		return nil, ErrGroupNotFound
+	}
+
+	return g, nil
+}
+
+// FindByConsumer finds the group the consumer is a member of
+func (r *InMemoryRepository) FindByConsumer(consumer string) (*Group, error) {
+	groups, _ := r.FindAll()
+	for _, g := range groups {
+		for _, member := range g.Consumers {
+			if member == consumer {
+				return g, nil
+			}
+		}
+	}
+
+	return nil, ErrGroupNotFound
+}
+
+// Add adds a group to the repository, an existing group with the same name is replaced
+func (r *InMemoryRepository) Add(group *Group) error {
+	r.Lock()
+	defer r.Unlock()
+
+	r.groups[group.Name] = group
+
+	return nil
+}
+
+// Remove removes a group from the repository
+func (r *InMemoryRepository) Remove(name string) error {
+	r.Lock()
+	defer r.Unlock()
+
+	if _, ok := r.groups[name]; !ok {
+		return ErrGroupNotFound
+	}
+
+	delete(r.groups, name)
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package group
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newInMemoryRepo() *InMemoryRepository {
+	repo := NewInMemoryRepository()
+
+	repo.Add(&Group{Name: "gold", Consumers: []string{"alice", "bob"}, RateLimit: "100-S"})
+	repo.Add(&Group{Name: DefaultGroup, RateLimit: "10-S"})
+
+	return repo
+}
+
+func TestInMemoryFindAll(t *testing.T) {
+	results, err := newInMemoryRepo().FindAll()
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, DefaultGroup, results[0].Name)
+	assert.Equal(t, "gold", results[1].Name)
+}
+
+func TestInMemoryFindByConsumer(t *testing.T) {
+	repo := newInMemoryRepo()
+
+	result, err := repo.FindByConsumer("bob")
+	require.NoError(t, err)
+	assert.Equal(t, "gold", result.Name)
+
+	_, err = repo.FindByConsumer("carol")
+	assert.Equal(t, ErrGroupNotFound, err)
+}
+
+func TestInMemoryRemove(t *testing.T) {
+	repo := newInMemoryRepo()
+
+	assert.NoError(t, repo.Remove("gold"))
+	assert.Equal(t, ErrGroupNotFound, repo.Remove("gold"))
+
+	_, err := repo.FindByName("gold")
+	assert.Equal(t, ErrGroupNotFound, err)
+}
@@ -0,0 +1,101 @@
+package group
+
+import (
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	collectionName string = "consumer_groups"
+)
+
+// MongoRepository represents a mongodb repository
+type MongoRepository struct {
+	session *mgo.Session
+}
+
+// NewMongoRepository creates a mongo consumer group repo
+func NewMongoRepository(session *mgo.Session) *MongoRepository {
+	return &MongoRepository{session}
+}
+
+// FindAll fetches all the groups sorted by name
+func (r *MongoRepository) FindAll() ([]*Group, error) {
+	result := []*Group{}
+	session, coll := r.getSession()
+	defer session.Close()
+
+	err := coll.Find(nil).Sort("name").All(&result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// FindByName finds a group by name
+func (r *MongoRepository) FindByName(name string) (*Group, error) {
+	return r.findOneByQuery(bson.M{"name": name})
+}
+
+// FindByConsumer finds the group the consumer is a member of
+func (r *MongoRepository) FindByConsumer(consumer string) (*Group, error) {
+	return r.findOneByQuery(bson.M{"consumers": consumer})
+}
+
+func (r *MongoRepository) findOneByQuery(query interface{}) (*Group, error) {
+	var result Group
+	session, coll := r.getSession()
+	defer session.Close()
+
+	err := coll.Find(query).One(&result)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, ErrGroupNotFound
+		}
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// Add adds a group to the repository, an existing group with the same name is replaced
+func (r *MongoRepository) Add(group *Group) error {
+	session, coll := r.getSession()
+	defer session.Close()
+
+	_, err := coll.Upsert(bson.M{"name": group.Name}, group)
+	if err != nil {
+		log.WithField("name", group.Name).Error("There was an error adding the consumer group")
+		return err
+	}
+
+	log.WithField("name", group.Name).Debug("Consumer group added")
+	return nil
+}
+
+// Remove removes a group from the repository
+func (r *MongoRepository) Remove(name string) error {
+	session, coll := r.getSession()
+	defer session.Close()
+
+	err := coll.Remove(bson.M{"name": name})
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return ErrGroupNotFound
+		}
+		log.WithField("name", name).Error("There was an error removing the consumer group")
+		return err
+	}
+
+	log.WithField("name", name).Debug("Consumer group removed")
+	return nil
+}
+
+func (r *MongoRepository) getSession() (*mgo.Session, *mgo.Collection) {
+	session := r.session.Copy()
+	coll := session.DB("").C(collectionName)
+
+	return session, coll
+}
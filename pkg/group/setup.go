@@ -0,0 +1,62 @@
+package group
+
+import (
+	"errors"
+
+	"github.com/hellofresh/janus/pkg/config"
+	"github.com/hellofresh/janus/pkg/jwt"
+	"github.com/hellofresh/janus/pkg/plugin"
+	"github.com/hellofresh/janus/pkg/router"
+)
+
+var adminRouter router.Router
+
+func init() {
+	plugin.RegisterEventHook(plugin.StartupEvent, onStartup)
+	plugin.RegisterEventHook(plugin.AdminAPIStartupEvent, onAdminAPIStartup)
+}
+
+func onAdminAPIStartup(event interface{}) error {
+	e, ok := event.(plugin.OnAdminAPIStartup)
+	if !ok {
+		return errors.New("could not convert event to admin startup type")
+	}
+
+	adminRouter = e.Router
+	return nil
+}
+
+// onStartup stores the groups in mongodb when it holds the API definitions, otherwise they are kept
+// in memory and lost on restart
+func onStartup(event interface{}) error {
+	e, ok := event.(plugin.OnStartup)
+	if !ok {
+		return errors.New("could not convert event to startup type")
+	}
+
+	if adminRouter == nil {
+		return ErrInvalidAdminRouter
+	}
+
+	if e.MongoSession != nil {
+		repo = NewMongoRepository(e.MongoSession)
+	}
+
+	loadGroupEndpoints(adminRouter, repo, e.Config.Web.Credentials)
+	return nil
+}
+
+// loadGroupEndpoints registers the admin endpoints of the groups behind the admin API authentication
+func loadGroupEndpoints(router router.Router, repo Repository, cred config.Credentials) {
+	guard := jwt.NewGuard(cred)
+	handlers := NewHandler(repo)
+	routes := router.Group("/consumer-groups")
+	routes.Use(jwt.NewMiddleware(guard).Handler, jwt.NewRBACMiddleware(cred.RBAC).Handler)
+	{
+		routes.GET("/", handlers.Index())
+		routes.POST("/", handlers.Create())
+		routes.GET("/{name}", handlers.Show())
+		routes.PUT("/{name}", handlers.Update())
+		routes.DELETE("/{name}", handlers.Delete())
+	}
+}
@@ -0,0 +1,109 @@
+package group
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	baseJWT "github.com/dgrijalva/jwt-go"
+	"github.com/hellofresh/janus/pkg/config"
+	"github.com/hellofresh/janus/pkg/jwt"
+	"github.com/hellofresh/janus/pkg/plugin"
+	"github.com/hellofresh/janus/pkg/router"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var adminToken string
+
+func newAdminRouter(t *testing.T) router.Router {
+	token, err := jwt.IssueAdminToken(jwt.SigningMethod{Alg: "HS256", Key: "secret"}, baseJWT.MapClaims{"sub": "admin"}, time.Hour)
+	require.NoError(t, err)
+	adminToken = token.Token
+
+	r := router.NewChiRouter()
+	cfg := &config.Specification{Web: config.Web{Credentials: config.Credentials{Algorithm: "HS256", Secret: "secret"}}}
+	require.NoError(t, onAdminAPIStartup(plugin.OnAdminAPIStartup{Router: r}))
+	require.NoError(t, onStartup(plugin.OnStartup{Config: cfg}))
+
+	return r
+}
+
+func serve(r router.Router, method, target, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAdminAPI(t *testing.T) {
+	defer withRepository(NewInMemoryRepository())()
+	r := newAdminRouter(t)
+
+	w := serve(r, http.MethodPost, "/consumer-groups/", `{"name": "gold", "consumers": ["alice"], "rate_limit": "100-S"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/consumer-groups/gold", w.Header().Get("Location"))
+
+	w = serve(r, http.MethodPost, "/consumer-groups/", `{"name": "gold"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = serve(r, http.MethodPost, "/consumer-groups/", `{"name": "silver", "consumers": ["alice"]}`)
+	assert.Equal(t, http.StatusConflict, w.Code, "a consumer is a member of one group only")
+
+	w = serve(r, http.MethodPost, "/consumer-groups/", `{"name": "silver", "quota": -1}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serve(r, http.MethodPut, "/consumer-groups/gold", `{"name": "renamed", "consumers": ["alice", "bob"], "quota": 5000}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int64(5000), Find("bob").Quota, "the members get the group limits")
+
+	w = serve(r, http.MethodGet, "/consumer-groups/gold", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var g Group
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &g))
+	assert.Equal(t, Group{Name: "gold", Consumers: []string{"alice", "bob"}, RateLimit: "100-S", Quota: 5000}, g)
+
+	w = serve(r, http.MethodGet, "/consumer-groups/", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var groups []Group
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &groups))
+	assert.Len(t, groups, 1)
+
+	w = serve(r, http.MethodDelete, "/consumer-groups/gold", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Nil(t, Find("bob"))
+
+	w = serve(r, http.MethodPut, "/consumer-groups/gold", `{}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAdminAPIUnauthorized(t *testing.T) {
+	defer withRepository(NewInMemoryRepository())()
+	r := newAdminRouter(t)
+
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, "/consumer-groups/", strings.NewReader(`{"name": "gold"}`)))
+		assert.Equal(t, http.StatusUnauthorized, w.Code, method)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/consumer-groups/gold", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	groups, err := repo.FindAll()
+	require.NoError(t, err)
+	assert.Empty(t, groups, "the groups are not changed without a token")
+}
+
+func TestOnStartupMissingAdminRouter(t *testing.T) {
+	adminRouter = nil
+	assert.Equal(t, ErrInvalidAdminRouter, onStartup(plugin.OnStartup{}))
+}
+
+func TestOnStartupWrongEvent(t *testing.T) {
+	assert.Error(t, onStartup(plugin.OnAdminAPIStartup{}))
+	assert.Error(t, onAdminAPIStartup(plugin.OnStartup{}))
+}
@@ -70,3 +70,19 @@ func (c *JanusClaims) VerifyIssuedAt(cmp int64, req bool) bool {
 func (c *JanusClaims) VerifyNotBefore(cmp int64, req bool) bool {
 	return c.MapClaims.VerifyNotBefore(cmp+c.leeway, req)
 }
+
+// VerifyAudience overrides jwt.MapClaims.VerifyAudience() to support the list of audiences
+func (c *JanusClaims) VerifyAudience(cmp string, req bool) bool {
+	audiences, ok := c.MapClaims["aud"].([]interface{})
+	if !ok {
+		return c.MapClaims.VerifyAudience(cmp, req)
+	}
+
+	for _, aud := range audiences {
+		if jwt.MapClaims(map[string]interface{}{"aud": aud}).VerifyAudience(cmp, true) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -21,17 +21,30 @@ type Guard struct {
 	// This means that the maximum validity timespan for a token is MaxRefresh + Timeout.
 	// Optional, defaults to 0 meaning not refreshable.
 	MaxRefresh time.Duration
+
+	// RolesClaim is the token claim holding the user roles. Optional, roles are not read when empty.
+	RolesClaim string
 }
 
-// NewGuard creates a new instance of Guard with default handlers
+// NewGuard creates a new instance of Guard with default handlers.
+// Tokens signed with the static secret are not accepted only when JWKS is configured and static secret is disabled.
 func NewGuard(cred config.Credentials) Guard {
+	parserConfig := ParserConfig{TokenLookup: "header:Authorization"}
+	if cred.StaticSecretEnabled || !cred.JWKS.IsConfigured() {
+		parserConfig.SigningMethods = []SigningMethod{{Alg: cred.Algorithm, Key: cred.Secret}}
+	}
+
+	if cred.JWKS.IsConfigured() {
+		parserConfig.KeySet = NewKeySet(cred.JWKS.URL, cred.JWKS.RefreshInterval)
+		parserConfig.Issuer = cred.JWKS.Issuer
+		parserConfig.Audience = cred.JWKS.Audience
+	}
+
 	return Guard{
-		ParserConfig: ParserConfig{
-			SigningMethods: []SigningMethod{{Alg: cred.Algorithm, Key: cred.Secret}},
-			TokenLookup:    "header:Authorization",
-		},
+		ParserConfig:  parserConfig,
 		SigningMethod: SigningMethod{Alg: cred.Algorithm, Key: cred.Secret},
 		Timeout:       cred.Timeout,
 		MaxRefresh:    time.Hour * 24,
+		RolesClaim:    cred.RBAC.RolesClaim,
 	}
 }
@@ -0,0 +1,202 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	jwksFetchTimeout = 5 * time.Second
+	// jwksMinRefetchInterval protects the issuer from being flooded by tokens with unknown key ids
+	jwksMinRefetchInterval = 10 * time.Second
+)
+
+var (
+	// ErrUnknownKeyID is the error returned when the key set has no key for the token key id
+	ErrUnknownKeyID = errors.New("unknown token key id")
+	// ErrInvalidIssuer is the error returned when the token was issued by an unexpected issuer
+	ErrInvalidIssuer = errors.New("invalid token issuer")
+	// ErrInvalidAudience is the error returned when the token was issued for an unexpected audience
+	ErrInvalidAudience = errors.New("invalid token audience")
+)
+
+// jsonWebKey is the RFC 7517 JSON Web Key representation, only public RSA and EC keys are supported
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// KeySet fetches and caches the public keys published by the issuer as JSON Web Key Set
+type KeySet struct {
+	url             string
+	refreshInterval time.Duration
+	client          *http.Client
+
+	sync.RWMutex
+	keys      map[string]interface{}
+	fetchedAt time.Time
+}
+
+// NewKeySet creates a new instance of KeySet, keys are fetched lazily on the first lookup
+func NewKeySet(url string, refreshInterval time.Duration) *KeySet {
+	return &KeySet{
+		url:             url,
+		refreshInterval: refreshInterval,
+		client:          &http.Client{Timeout: jwksFetchTimeout},
+		keys:            make(map[string]interface{}),
+	}
+}
+
+// Key returns the public key for the given key id. Keys are fetched again when the refresh interval
+// is over or the key id is unknown, e.g. after the issuer rotated its keys.
+func (ks *KeySet) Key(kid string) (interface{}, error) {
+	ks.RLock()
+	key, ok := ks.lookup(kid)
+	stale := ks.refreshInterval > 0 && time.Since(ks.fetchedAt) > ks.refreshInterval
+	canRefetch := time.Since(ks.fetchedAt) > jwksMinRefetchInterval
+	ks.RUnlock()
+
+	if (ok && !stale) || !canRefetch {
+		if !ok {
+			return nil, ErrUnknownKeyID
+		}
+		return key, nil
+	}
+
+	if err := ks.fetch(); err != nil {
+		log.WithError(err).WithField("url", ks.url).Warn("Failed to fetch JWKS, keeping the last known keys")
+	}
+
+	ks.RLock()
+	defer ks.RUnlock()
+	if key, ok := ks.lookup(kid); ok {
+		return key, nil
+	}
+
+	return nil, ErrUnknownKeyID
+}
+
+// lookup must be called with the lock held. Tokens without key id are accepted only when the
+// key set has exactly one key.
+func (ks *KeySet) lookup(kid string) (interface{}, bool) {
+	if kid == "" && len(ks.keys) == 1 {
+		for _, key := range ks.keys {
+			return key, true
+		}
+	}
+
+	key, ok := ks.keys[kid]
+	return key, ok
+}
+
+func (ks *KeySet) fetch() error {
+	ks.Lock()
+	defer ks.Unlock()
+
+	// keys were refreshed by another request in the meantime
+	if time.Since(ks.fetchedAt) <= jwksMinRefetchInterval {
+		return nil
+	}
+	ks.fetchedAt = time.Now()
+
+	resp, err := ks.client.Get(ks.url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected JWKS response status %d", resp.StatusCode)
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return err
+	}
+
+	keys := make(map[string]interface{}, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := jwk.publicKey()
+		if err != nil {
+			log.WithError(err).WithField("kid", jwk.Kid).Warn("Skipping invalid JWKS key")
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	ks.keys = keys
+
+	return nil
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBase64BigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBase64BigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+
+		x, err := decodeBase64BigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBase64BigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBase64BigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,217 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	baseJWT "github.com/dgrijalva/jwt-go"
+	"github.com/hellofresh/janus/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	jwksIssuer   = "https://issuer.example.com/"
+	jwksAudience = "janus-admin"
+)
+
+func newJWKSServer(t *testing.T, rsaKey *rsa.PrivateKey, ecKey *ecdsa.PrivateKey) *httptest.Server {
+	encode := func(i *big.Int) string {
+		return base64.RawURLEncoding.EncodeToString(i.Bytes())
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []jsonWebKey{
+			{Kty: "RSA", Kid: "rsa", Use: "sig", N: encode(rsaKey.N), E: encode(big.NewInt(int64(rsaKey.E)))},
+			{Kty: "EC", Kid: "ec", Crv: "P-256", X: encode(ecKey.X), Y: encode(ecKey.Y)},
+			{Kty: "RSA", Kid: "enc", Use: "enc", N: encode(rsaKey.N), E: encode(big.NewInt(int64(rsaKey.E)))},
+		}})
+	}))
+}
+
+func generateJWKSToken(t *testing.T, method baseJWT.SigningMethod, kid string, key interface{}, claims baseJWT.MapClaims) string {
+	token := baseJWT.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+
+	tokenString, err := token.SignedString(key)
+	require.NoError(t, err)
+
+	return tokenString
+}
+
+func TestParser_Parse_KeySet(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	ts := newJWKSServer(t, rsaKey, ecKey)
+	defer ts.Close()
+
+	validClaims := func() baseJWT.MapClaims {
+		return baseJWT.MapClaims{
+			"sub": userName,
+			"iss": jwksIssuer,
+			"aud": jwksAudience,
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}
+	}
+
+	tests := []struct {
+		scenario string
+		token    func() string
+		err      error
+	}{
+		{
+			scenario: "RSA key",
+			token: func() string {
+				return generateJWKSToken(t, baseJWT.SigningMethodRS256, "rsa", rsaKey, validClaims())
+			},
+		},
+		{
+			scenario: "EC key",
+			token: func() string {
+				return generateJWKSToken(t, baseJWT.SigningMethodES256, "ec", ecKey, validClaims())
+			},
+		},
+		{
+			scenario: "list of audiences",
+			token: func() string {
+				claims := validClaims()
+				claims["aud"] = []string{"other", jwksAudience}
+				return generateJWKSToken(t, baseJWT.SigningMethodRS256, "rsa", rsaKey, claims)
+			},
+		},
+		{
+			scenario: "expired token",
+			token: func() string {
+				claims := validClaims()
+				claims["exp"] = time.Now().Add(-time.Hour).Unix()
+				return generateJWKSToken(t, baseJWT.SigningMethodRS256, "rsa", rsaKey, claims)
+			},
+			err: &baseJWT.ValidationError{},
+		},
+		{
+			scenario: "wrong audience",
+			token: func() string {
+				claims := validClaims()
+				claims["aud"] = "other"
+				return generateJWKSToken(t, baseJWT.SigningMethodRS256, "rsa", rsaKey, claims)
+			},
+			err: ErrInvalidAudience,
+		},
+		{
+			scenario: "wrong issuer",
+			token: func() string {
+				claims := validClaims()
+				claims["iss"] = "https://other.example.com/"
+				return generateJWKSToken(t, baseJWT.SigningMethodRS256, "rsa", rsaKey, claims)
+			},
+			err: ErrInvalidIssuer,
+		},
+		{
+			scenario: "unknown key id",
+			token: func() string {
+				return generateJWKSToken(t, baseJWT.SigningMethodRS256, "unknown", rsaKey, validClaims())
+			},
+			err: &baseJWT.ValidationError{},
+		},
+		{
+			scenario: "encryption key",
+			token: func() string {
+				return generateJWKSToken(t, baseJWT.SigningMethodRS256, "enc", rsaKey, validClaims())
+			},
+			err: &baseJWT.ValidationError{},
+		},
+	}
+
+	parser := NewParser(ParserConfig{
+		TokenLookup: "header:Authorization",
+		KeySet:      NewKeySet(ts.URL, time.Hour),
+		Issuer:      jwksIssuer,
+		Audience:    jwksAudience,
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.scenario, func(t *testing.T) {
+			token, err := parser.Parse(tt.token())
+			if tt.err != nil {
+				require.Error(t, err)
+				assert.IsType(t, tt.err, err)
+				return
+			}
+
+			require.NoError(t, err)
+			claims, ok := parser.GetMapClaims(token)
+			require.True(t, ok)
+			assert.Equal(t, userName, claims["sub"])
+		})
+	}
+}
+
+func TestNewGuard_StaticSecret(t *testing.T) {
+	tokenString, err := generateToken("HS256", "secret")
+	require.NoError(t, err)
+
+	tests := []struct {
+		scenario string
+		cred     config.Credentials
+		valid    bool
+	}{
+		{
+			scenario: "static secret without JWKS",
+			cred:     config.Credentials{Algorithm: "HS256", Secret: "secret"},
+			valid:    true,
+		},
+		{
+			scenario: "static secret enabled with JWKS",
+			cred:     config.Credentials{Algorithm: "HS256", Secret: "secret", StaticSecretEnabled: true, JWKS: config.JWKS{URL: "http://localhost:1"}},
+			valid:    true,
+		},
+		{
+			scenario: "static secret disabled with JWKS",
+			cred:     config.Credentials{Algorithm: "HS256", Secret: "secret", JWKS: config.JWKS{URL: "http://localhost:1"}},
+			valid:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scenario, func(t *testing.T) {
+			parser := NewParser(NewGuard(tt.cred).ParserConfig)
+			_, err := parser.Parse(tokenString)
+			assert.Equal(t, tt.valid, err == nil)
+		})
+	}
+}
+
+func TestMiddleware_UserFromContext(t *testing.T) {
+	tokenString := generateJWKSToken(t, baseJWT.SigningMethodHS256, "", []byte("secret"), baseJWT.MapClaims{"sub": userName, "email": userName})
+	guard := NewGuard(config.Credentials{Algorithm: "HS256", Secret: "secret"})
+
+	var user User
+	handler := NewMiddleware(guard).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ok bool
+		user, ok = UserFromContext(r.Context())
+		assert.True(t, ok)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/apis", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	req.Header.Set("Authorization", "Bearer "+tokenString)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, User{Username: userName, Email: userName}, user)
+}
@@ -1,6 +1,7 @@
 package jwt
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/hellofresh/janus/pkg/render"
@@ -14,6 +15,13 @@ type Payload struct{}
 type User struct {
 	Username string
 	Email    string
+	Roles    []string
+}
+
+// UserFromContext returns the user authenticated by the middleware, the token subject is used as username
+func UserFromContext(ctx context.Context) (User, bool) {
+	user, ok := ctx.Value(Payload{}).(User)
+	return user, ok
 }
 
 // Middleware struct contains data and logic required for middleware functionality
@@ -30,13 +38,40 @@ func NewMiddleware(config Guard) *Middleware {
 func (m *Middleware) Handler(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		parser := Parser{m.Guard.ParserConfig}
-		_, err := parser.ParseFromRequest(r)
+		token, err := parser.ParseFromRequest(r)
 		if err != nil {
 			log.WithError(err).Debug("failed to parse the token")
 			render.JSON(w, http.StatusUnauthorized, "failed to parse the token")
 			return
 		}
 
-		handler.ServeHTTP(w, r)
+		claims, _ := parser.GetMapClaims(token)
+		user := User{}
+		user.Username, _ = claims["sub"].(string)
+		user.Email, _ = claims["email"].(string)
+		if m.Guard.RolesClaim != "" {
+			user.Roles = rolesFromClaim(claims[m.Guard.RolesClaim])
+		}
+		log.WithField("sub", user.Username).Debug("Token authenticated")
+
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), Payload{}, user)))
 	})
 }
+
+// rolesFromClaim supports roles claim as a list or as a single string
+func rolesFromClaim(claim interface{}) []string {
+	switch roles := claim.(type) {
+	case string:
+		return []string{roles}
+	case []interface{}:
+		result := make([]string, 0, len(roles))
+		for _, role := range roles {
+			if role, ok := role.(string); ok {
+				result = append(result, role)
+			}
+		}
+		return result
+	default:
+		return nil
+	}
+}
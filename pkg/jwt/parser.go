@@ -49,6 +49,15 @@ type ParserConfig struct {
 
 	// Leeway is the time in seconds to account for clock skew when checking nbf, iat or expiration times
 	Leeway int64
+
+	// KeySet is the external issuer key set used to verify tokens not signed with any of SigningMethods.
+	// Optional.
+	KeySet *KeySet
+
+	// Issuer and Audience are the expected "iss" and "aud" claims of the tokens verified with KeySet.
+	// Optional, not checked when empty.
+	Issuer   string
+	Audience string
 }
 
 // NewParserConfig creates a new instance of ParserConfig
@@ -140,9 +149,40 @@ func (jp *Parser) Parse(tokenString string) (*jwt.Token, error) {
 		return token, err
 	}
 
+	if jp.Config.KeySet != nil {
+		return jp.parseWithKeySet(tokenString)
+	}
+
 	return nil, ErrFailedToParseToken
 }
 
+// parseWithKeySet validates the token against the external issuer key set and its "iss" and "aud" claims
+func (jp *Parser) parseWithKeySet(tokenString string) (*jwt.Token, error) {
+	token, err := jwt.ParseWithClaims(tokenString, NewJanusClaims(jp.Config.Leeway), func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+			kid, _ := token.Header["kid"].(string)
+			return jp.Config.KeySet.Key(kid)
+		default:
+			return nil, ErrUnsupportedSigningMethod
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims := token.Claims.(*JanusClaims)
+	if jp.Config.Issuer != "" && !claims.VerifyIssuer(jp.Config.Issuer, true) {
+		return nil, ErrInvalidIssuer
+	}
+
+	if jp.Config.Audience != "" && !claims.VerifyAudience(jp.Config.Audience, true) {
+		return nil, ErrInvalidAudience
+	}
+
+	return token, nil
+}
+
 // GetMapClaims returns a map version of Claims Section
 func (jp *Parser) GetMapClaims(token *jwt.Token) (jwt.MapClaims, bool) {
 	claims, ok := token.Claims.(*JanusClaims)
@@ -0,0 +1,155 @@
+package jwt
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/hellofresh/janus/pkg/config"
+	"github.com/hellofresh/janus/pkg/render"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// RoleViewer is allowed to read the admin API
+	RoleViewer = "viewer"
+	// RoleEditor is allowed to read and change the API definitions
+	RoleEditor = "editor"
+	// RoleAdmin is allowed to do everything
+	RoleAdmin = "admin"
+)
+
+// DefaultPermissions are used when RBAC is enabled without permissions configured
+var DefaultPermissions = []config.Permission{
+	{Role: RoleViewer, Methods: []string{http.MethodGet, http.MethodHead}, Paths: []string{"/apis*", "/oauth/servers*"}},
+	{Role: RoleEditor, Methods: []string{http.MethodGet, http.MethodHead}, Paths: []string{"/apis*", "/oauth/servers*"}},
+	{Role: RoleEditor, Methods: []string{http.MethodPost, http.MethodPut, http.MethodDelete}, Paths: []string{"/apis*"}},
+	{Role: RoleAdmin},
+}
+
+// RBACMiddleware checks that the user authenticated by the Middleware has a role allowed to access the request
+type RBACMiddleware struct {
+	config config.RBAC
+}
+
+// NewRBACMiddleware builds and returns new RBAC middleware instance
+func NewRBACMiddleware(cfg config.RBAC) *RBACMiddleware {
+	if len(cfg.Permissions) == 0 {
+		cfg.Permissions = DefaultPermissions
+	}
+
+	return &RBACMiddleware{cfg}
+}
+
+// Handler implementation
+func (m *RBACMiddleware) Handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !m.config.Enabled {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		user, roles := m.roles(r)
+		if !m.isAllowed(roles, r.Method, r.URL.Path) {
+			log.WithFields(log.Fields{
+				"sub":    user.Username,
+				"roles":  roles,
+				"method": r.Method,
+				"path":   r.URL.Path,
+			}).Info("Access to the admin API denied")
+			render.JSON(w, http.StatusForbidden, "access denied")
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// HasRole checks that the user authenticated by the Middleware has the role, all the users have all the
+// roles when RBAC is disabled
+func (m *RBACMiddleware) HasRole(r *http.Request, role string) bool {
+	if !m.config.Enabled {
+		return true
+	}
+
+	_, roles := m.roles(r)
+	return contains(roles, role)
+}
+
+// roles returns the user authenticated by the Middleware and its roles, the default roles when it has none
+func (m *RBACMiddleware) roles(r *http.Request) (User, []string) {
+	user, _ := UserFromContext(r.Context())
+	if len(user.Roles) == 0 {
+		return user, m.config.DefaultRoles
+	}
+
+	return user, user.Roles
+}
+
+func (m *RBACMiddleware) isAllowed(roles []string, method, path string) bool {
+	path = strings.TrimSuffix(path, "/")
+	for _, permission := range m.config.Permissions {
+		if !contains(roles, permission.Role) {
+			continue
+		}
+
+		if len(permission.Methods) > 0 && !containsFold(permission.Methods, method) {
+			continue
+		}
+
+		if len(permission.Paths) > 0 && !matchesAnyPath(permission.Paths, path) {
+			continue
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// matchesAnyPath checks the path against the patterns, the patterns ending with "*" match the paths below
+// their prefix on a path segment boundary, e.g. "/apis*" matches "/apis" and "/apis/users" but not "/apisx"
+func matchesAnyPath(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		if strings.HasSuffix(pattern, "*") {
+			if matchesPrefix(strings.TrimSuffix(pattern, "*"), path) {
+				return true
+			}
+			continue
+		}
+
+		if strings.TrimSuffix(pattern, "/") == path {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchesPrefix(prefix, path string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return strings.HasSuffix(prefix, "/") && path == strings.TrimSuffix(prefix, "/")
+	}
+
+	rest := path[len(prefix):]
+	return rest == "" || strings.HasSuffix(prefix, "/") || rest[0] == '/'
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
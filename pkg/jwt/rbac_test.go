@@ -0,0 +1,73 @@
+package jwt
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hellofresh/janus/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRBACMiddleware(t *testing.T) {
+	scoped := []config.Permission{
+		{Role: RoleViewer, Methods: []string{http.MethodGet}},
+		{Role: "users-editor", Methods: []string{http.MethodPut, http.MethodDelete}, Paths: []string{"/apis/users*"}},
+	}
+
+	tests := []struct {
+		scenario string
+		config   config.RBAC
+		roles    []string
+		method   string
+		path     string
+		code     int
+	}{
+		{scenario: "disabled", config: config.RBAC{}, method: http.MethodDelete, path: "/apis/example", code: http.StatusOK},
+		{scenario: "viewer can read", config: config.RBAC{Enabled: true}, roles: []string{RoleViewer}, method: http.MethodGet, path: "/apis/", code: http.StatusOK},
+		{scenario: "viewer can not change", config: config.RBAC{Enabled: true}, roles: []string{RoleViewer}, method: http.MethodPost, path: "/apis", code: http.StatusForbidden},
+		{scenario: "editor can change definitions", config: config.RBAC{Enabled: true}, roles: []string{RoleEditor}, method: http.MethodPut, path: "/apis/example", code: http.StatusOK},
+		{scenario: "editor can not change oauth servers", config: config.RBAC{Enabled: true}, roles: []string{RoleEditor}, method: http.MethodPost, path: "/oauth/servers", code: http.StatusForbidden},
+		{scenario: "editor can not read credentials", config: config.RBAC{Enabled: true}, roles: []string{RoleEditor}, method: http.MethodGet, path: "/credentials/basic_auth", code: http.StatusForbidden},
+		{scenario: "admin can change credentials", config: config.RBAC{Enabled: true}, roles: []string{RoleAdmin}, method: http.MethodPost, path: "/credentials/basic_auth", code: http.StatusOK},
+		{scenario: "admin can do everything", config: config.RBAC{Enabled: true}, roles: []string{RoleAdmin}, method: http.MethodGet, path: "/debug/pprof/profile", code: http.StatusOK},
+		{scenario: "no roles", config: config.RBAC{Enabled: true}, method: http.MethodGet, path: "/apis", code: http.StatusForbidden},
+		{scenario: "default roles", config: config.RBAC{Enabled: true, DefaultRoles: []string{RoleAdmin}}, method: http.MethodDelete, path: "/apis/example", code: http.StatusOK},
+		{scenario: "scoped editor in scope", config: config.RBAC{Enabled: true, Permissions: scoped}, roles: []string{"users-editor"}, method: http.MethodPut, path: "/apis/users", code: http.StatusOK},
+		{scenario: "scoped editor below the scope", config: config.RBAC{Enabled: true, Permissions: scoped}, roles: []string{"users-editor"}, method: http.MethodPut, path: "/apis/users/maintenance", code: http.StatusOK},
+		{scenario: "scoped editor sharing the scope prefix", config: config.RBAC{Enabled: true, Permissions: scoped}, roles: []string{"users-editor"}, method: http.MethodPut, path: "/apis/users-v2", code: http.StatusForbidden},
+		{scenario: "viewer out of the path segment", config: config.RBAC{Enabled: true}, roles: []string{RoleViewer}, method: http.MethodGet, path: "/apisX", code: http.StatusForbidden},
+		{scenario: "scoped editor out of scope", config: config.RBAC{Enabled: true, Permissions: scoped}, roles: []string{"users-editor"}, method: http.MethodPut, path: "/apis/orders", code: http.StatusForbidden},
+		{scenario: "admin without configured permissions", config: config.RBAC{Enabled: true, Permissions: scoped}, roles: []string{RoleAdmin}, method: http.MethodGet, path: "/apis", code: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scenario, func(t *testing.T) {
+			handler := NewRBACMiddleware(tt.config).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req = req.WithContext(context.WithValue(req.Context(), Payload{}, User{Username: userName, Roles: tt.roles}))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.code, w.Code)
+		})
+	}
+}
+
+func TestRBACMiddlewareHasRole(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/apis/export", nil)
+	viewer := req.WithContext(context.WithValue(req.Context(), Payload{}, User{Username: userName, Roles: []string{RoleViewer}}))
+
+	assert.True(t, NewRBACMiddleware(config.RBAC{}).HasRole(viewer, RoleAdmin), "all the roles when disabled")
+	assert.False(t, NewRBACMiddleware(config.RBAC{Enabled: true}).HasRole(viewer, RoleAdmin))
+	assert.True(t, NewRBACMiddleware(config.RBAC{Enabled: true}).HasRole(viewer, RoleViewer))
+	assert.True(t, NewRBACMiddleware(config.RBAC{Enabled: true, DefaultRoles: []string{RoleAdmin}}).HasRole(req, RoleAdmin))
+}
+
+func TestRolesFromClaim(t *testing.T) {
+	assert.Equal(t, []string{RoleAdmin}, rolesFromClaim(RoleAdmin))
+	assert.Equal(t, []string{RoleViewer, RoleEditor}, rolesFromClaim([]interface{}{RoleViewer, 1, RoleEditor}))
+	assert.Nil(t, rolesFromClaim(nil))
+}
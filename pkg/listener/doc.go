@@ -0,0 +1,2 @@
+// Package listener creates the network listeners of the proxy and the admin API servers.
+package listener
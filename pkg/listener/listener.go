@@ -0,0 +1,50 @@
+package listener
+
+import (
+	"net"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// unixPrefix is the prefix of the Unix domain socket addresses, e.g. "unix:/var/run/janus.sock"
+const unixPrefix = "unix:"
+
+// IsUnix checks if the address is a Unix domain socket address
+func IsUnix(address string) bool {
+	return strings.HasPrefix(address, unixPrefix)
+}
+
+// New announces on the address. The "unix:/path/to/sock" addresses listen on the Unix domain socket
+// created with the mode, its file is removed when the listener is closed. Any other address listens
+// on the TCP port.
+func New(address string, mode os.FileMode) (net.Listener, error) {
+	if !IsUnix(address) {
+		return net.Listen("tcp", address)
+	}
+
+	path := strings.TrimPrefix(address, unixPrefix)
+	if path == "" {
+		return nil, errors.New("unix socket path is not set")
+	}
+
+	// the socket file left by an instance that did not shut down gracefully would make listen fail
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, errors.Wrap(err, "could not remove the stale unix socket")
+		}
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(path, mode); err != nil {
+		ln.Close()
+		return nil, errors.Wrap(err, "could not set the unix socket permissions")
+	}
+
+	return ln, nil
+}
@@ -0,0 +1,75 @@
+package listener
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func socketPath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "listener")
+	require.NoError(t, err)
+
+	return filepath.Join(dir, "janus.sock"), func() { os.RemoveAll(dir) }
+}
+
+func TestNewUnix(t *testing.T) {
+	path, cleanup := socketPath(t)
+	defer cleanup()
+
+	ln, err := New("unix:"+path, 0660)
+	require.NoError(t, err)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0660), info.Mode().Perm())
+
+	conn, err := net.Dial("unix", path)
+	require.NoError(t, err)
+	conn.Close()
+
+	require.NoError(t, ln.Close())
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "the socket file is removed on close")
+}
+
+func TestNewUnixStaleSocket(t *testing.T) {
+	path, cleanup := socketPath(t)
+	defer cleanup()
+
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	require.NoError(t, err)
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+
+	ln, err := New("unix:"+path, 0600)
+	require.NoError(t, err)
+	ln.Close()
+}
+
+func TestNewUnixNotSocket(t *testing.T) {
+	path, cleanup := socketPath(t)
+	defer cleanup()
+	require.NoError(t, ioutil.WriteFile(path, []byte("data"), 0600))
+
+	_, err := New("unix:"+path, 0600)
+	assert.Error(t, err, "regular files are not removed")
+
+	_, err = New("unix:", 0600)
+	assert.Error(t, err)
+}
+
+func TestNewTCP(t *testing.T) {
+	ln, err := New("127.0.0.1:0", 0)
+	require.NoError(t, err)
+	defer ln.Close()
+
+	assert.Equal(t, "tcp", ln.Addr().Network())
+	assert.False(t, IsUnix("127.0.0.1:0"))
+	assert.True(t, IsUnix("unix:/var/run/janus.sock"))
+}
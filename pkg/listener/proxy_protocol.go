@@ -0,0 +1,297 @@
+package listener
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultProxyHeaderTimeout is the time the PROXY protocol header is waited for when no timeout is set
+	DefaultProxyHeaderTimeout = 5 * time.Second
+
+	// proxyV1MaxLength is the maximum length of a v1 header, including the CRLF
+	proxyV1MaxLength = 107
+	proxyV2HeaderLen = 16
+)
+
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// ErrInvalidProxyHeader is returned by the connections with a missing or malformed PROXY protocol header
+var ErrInvalidProxyHeader = errors.New("invalid PROXY protocol header")
+
+// ParseCIDRs parses the trusted source ranges, a single IP address is a range of one address
+func ParseCIDRs(values []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, errors.Errorf("invalid IP address %q", value)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid CIDR %q", value)
+		}
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+// proxyProtocolListener reads the PROXY protocol header of the connections from the trusted sources
+type proxyProtocolListener struct {
+	net.Listener
+	trusted []*net.IPNet
+	timeout time.Duration
+}
+
+// NewProxyProtocol wraps the listener to recover the client address from the PROXY protocol v1 and v2
+// headers. Only the connections from the trusted sources, and the Unix domain socket ones, are expected
+// to send the header and they are closed when it is missing or malformed. The other connections are
+// served as they are, so their PROXY header is not trusted.
+func NewProxyProtocol(ln net.Listener, trusted []*net.IPNet, timeout time.Duration) net.Listener {
+	if timeout <= 0 {
+		timeout = DefaultProxyHeaderTimeout
+	}
+
+	return &proxyProtocolListener{Listener: ln, trusted: trusted, timeout: timeout}
+}
+
+// Accept waits for and returns the next connection
+func (l *proxyProtocolListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	if !l.isTrusted(conn.RemoteAddr()) {
+		return conn, nil
+	}
+
+	// the header is read on the first use of the connection, in the connection serving goroutine,
+	// so a slow client does not block accepting the other connections
+	return &proxyProtocolConn{Conn: conn, reader: bufio.NewReader(conn), timeout: l.timeout}, nil
+}
+
+func (l *proxyProtocolListener) isTrusted(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return true
+	}
+
+	for _, network := range l.trusted {
+		if network.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type proxyProtocolConn struct {
+	net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+
+	once       sync.Once
+	err        error
+	remoteAddr net.Addr
+	localAddr  net.Addr
+}
+
+func (c *proxyProtocolConn) init() {
+	c.once.Do(func() {
+		c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+		c.err = c.readHeader()
+		c.Conn.SetReadDeadline(time.Time{})
+
+		if c.err != nil {
+			log.WithError(c.err).WithField("remote_addr", c.Conn.RemoteAddr().String()).
+				Warn("Rejecting the connection with an invalid PROXY protocol header")
+			c.Conn.Close()
+		}
+	})
+}
+
+// Read reads the data following the PROXY protocol header
+func (c *proxyProtocolConn) Read(b []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	return c.reader.Read(b)
+}
+
+// RemoteAddr returns the client address sent in the header
+func (c *proxyProtocolConn) RemoteAddr() net.Addr {
+	c.init()
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr returns the destination address sent in the header
+func (c *proxyProtocolConn) LocalAddr() net.Addr {
+	c.init()
+	if c.localAddr != nil {
+		return c.localAddr
+	}
+
+	return c.Conn.LocalAddr()
+}
+
+func (c *proxyProtocolConn) readHeader() error {
+	first, err := c.reader.Peek(1)
+	if err != nil {
+		return errors.Wrap(err, "could not read the PROXY protocol header")
+	}
+
+	switch first[0] {
+	case 'P':
+		return c.readV1()
+	case proxyV2Signature[0]:
+		return c.readV2()
+	default:
+		return ErrInvalidProxyHeader
+	}
+}
+
+func (c *proxyProtocolConn) readV1() error {
+	var line []byte
+	for {
+		b, err := c.reader.ReadByte()
+		if err != nil {
+			return errors.Wrap(err, "could not read the PROXY protocol header")
+		}
+		line = append(line, b)
+
+		if b == '\n' {
+			break
+		}
+		if len(line) >= proxyV1MaxLength {
+			return ErrInvalidProxyHeader
+		}
+	}
+
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return ErrInvalidProxyHeader
+	}
+
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	if fields[0] != "PROXY" || len(fields) < 2 {
+		return ErrInvalidProxyHeader
+	}
+
+	switch fields[1] {
+	case "UNKNOWN":
+		// the sender does not know the client address, e.g. a health check
+		return nil
+	case "TCP4", "TCP6":
+	default:
+		return ErrInvalidProxyHeader
+	}
+
+	if len(fields) != 6 {
+		return ErrInvalidProxyHeader
+	}
+
+	src, err := parseV1Addr(fields[1], fields[2], fields[4])
+	if err != nil {
+		return err
+	}
+	dst, err := parseV1Addr(fields[1], fields[3], fields[5])
+	if err != nil {
+		return err
+	}
+
+	c.remoteAddr, c.localAddr = src, dst
+	return nil
+}
+
+func parseV1Addr(protocol, host, port string) (*net.TCPAddr, error) {
+	ip := net.ParseIP(host)
+	if ip == nil || (protocol == "TCP4") != (ip.To4() != nil) {
+		return nil, ErrInvalidProxyHeader
+	}
+
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil || (len(port) > 1 && port[0] == '0') {
+		return nil, ErrInvalidProxyHeader
+	}
+
+	return &net.TCPAddr{IP: ip, Port: int(p)}, nil
+}
+
+func (c *proxyProtocolConn) readV2() error {
+	header := make([]byte, proxyV2HeaderLen)
+	if _, err := io.ReadFull(c.reader, header); err != nil {
+		return errors.Wrap(err, "could not read the PROXY protocol header")
+	}
+
+	if !bytes.Equal(header[:len(proxyV2Signature)], proxyV2Signature) || header[12]>>4 != 2 {
+		return ErrInvalidProxyHeader
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return errors.Wrap(err, "could not read the PROXY protocol header")
+	}
+
+	switch header[12] & 0x0F {
+	case 0x0:
+		// LOCAL command, the connection was established by the proxy itself, e.g. a health check
+		return nil
+	case 0x1:
+	default:
+		return ErrInvalidProxyHeader
+	}
+
+	var ipLen int
+	switch header[13] {
+	case 0x11: // TCP over IPv4
+		ipLen = net.IPv4len
+	case 0x21: // TCP over IPv6
+		ipLen = net.IPv6len
+	case 0x00, 0x12, 0x22, 0x31, 0x32:
+		// unspecified, UDP and unix families carry no TCP client address, the TLVs are ignored
+		return nil
+	default:
+		return ErrInvalidProxyHeader
+	}
+
+	if len(payload) < 2*ipLen+4 {
+		return ErrInvalidProxyHeader
+	}
+
+	c.remoteAddr = &net.TCPAddr{
+		IP:   net.IP(payload[:ipLen]),
+		Port: int(binary.BigEndian.Uint16(payload[2*ipLen:])),
+	}
+	c.localAddr = &net.TCPAddr{
+		IP:   net.IP(payload[ipLen : 2*ipLen]),
+		Port: int(binary.BigEndian.Uint16(payload[2*ipLen+2:])),
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package listener
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func proxyV2Header(command byte, family byte, src, dst net.IP, srcPort, dstPort uint16) []byte {
+	var payload []byte
+	payload = append(payload, src...)
+	payload = append(payload, dst...)
+	ports := make([]byte, 4)
+	binary.BigEndian.PutUint16(ports, srcPort)
+	binary.BigEndian.PutUint16(ports[2:], dstPort)
+	payload = append(payload, ports...)
+
+	header := append([]byte{}, proxyV2Signature...)
+	header = append(header, 0x20|command, family, 0, 0)
+	binary.BigEndian.PutUint16(header[14:], uint16(len(payload)))
+
+	return append(header, payload...)
+}
+
+// acceptWith sends the data on a new connection and returns the remote address and the data read
+// from the accepted one
+func acceptWith(t *testing.T, trusted []string, data []byte) (string, string, error) {
+	networks, err := ParseCIDRs(trusted)
+	require.NoError(t, err)
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ln := NewProxyProtocol(tcp, networks, time.Second)
+	defer ln.Close()
+
+	go func() {
+		client, err := net.Dial("tcp", tcp.Addr().String())
+		if err != nil {
+			return
+		}
+		client.Write(data)
+		client.Close()
+	}()
+
+	conn, err := ln.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	remoteAddr := conn.RemoteAddr().String()
+	body, err := ioutil.ReadAll(conn)
+
+	return remoteAddr, string(body), err
+}
+
+func TestProxyProtocolV1(t *testing.T) {
+	remoteAddr, body, err := acceptWith(t, []string{"127.0.0.1"}, []byte("PROXY TCP4 203.0.113.7 10.0.0.1 56324 443\r\nGET / HTTP/1.1\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "203.0.113.7:56324", remoteAddr)
+	assert.Equal(t, "GET / HTTP/1.1\r\n", body)
+
+	remoteAddr, _, err = acceptWith(t, []string{"127.0.0.0/8"}, []byte("PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "[2001:db8::1]:56324", remoteAddr)
+
+	remoteAddr, _, err = acceptWith(t, []string{"127.0.0.0/8"}, []byte("PROXY UNKNOWN\r\n"))
+	require.NoError(t, err)
+	assert.Contains(t, remoteAddr, "127.0.0.1:")
+}
+
+func TestProxyProtocolV2(t *testing.T) {
+	header := proxyV2Header(0x1, 0x11, net.ParseIP("203.0.113.7").To4(), net.ParseIP("10.0.0.1").To4(), 56324, 443)
+	remoteAddr, body, err := acceptWith(t, []string{"127.0.0.1/32"}, append(header, "GET /"...))
+	require.NoError(t, err)
+	assert.Equal(t, "203.0.113.7:56324", remoteAddr)
+	assert.Equal(t, "GET /", body)
+
+	header = proxyV2Header(0x1, 0x21, net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2"), 56324, 443)
+	remoteAddr, _, err = acceptWith(t, []string{"127.0.0.1/32"}, header)
+	require.NoError(t, err)
+	assert.Equal(t, "[2001:db8::1]:56324", remoteAddr)
+
+	header = proxyV2Header(0x0, 0x11, net.ParseIP("203.0.113.7").To4(), net.ParseIP("10.0.0.1").To4(), 56324, 443)
+	remoteAddr, _, err = acceptWith(t, []string{"127.0.0.1/32"}, header)
+	require.NoError(t, err)
+	assert.Contains(t, remoteAddr, "127.0.0.1:", "LOCAL command keeps the connection address")
+}
+
+func TestProxyProtocolMalformed(t *testing.T) {
+	headers := map[string][]byte{
+		"missing":        []byte("GET / HTTP/1.1\r\n\r\n"),
+		"bad address":    []byte("PROXY TCP4 203.0.113 10.0.0.1 56324 443\r\n"),
+		"family":         []byte("PROXY TCP4 2001:db8::1 10.0.0.1 56324 443\r\n"),
+		"port":           []byte("PROXY TCP4 203.0.113.7 10.0.0.1 99999 443\r\n"),
+		"no crlf":        []byte("PROXY TCP4 203.0.113.7 10.0.0.1 56324 443\n"),
+		"too long":       append([]byte("PROXY TCP4 "), make([]byte, 200)...),
+		"v2 version":     append(append([]byte{}, proxyV2Signature...), 0x11, 0x11, 0, 0),
+		"v2 truncated":   proxyV2Header(0x1, 0x11, nil, nil, 0, 0),
+		"v2 bad command": proxyV2Header(0x2, 0x11, net.IPv4(1, 2, 3, 4).To4(), net.IPv4(1, 2, 3, 4).To4(), 1, 2),
+	}
+
+	for name, header := range headers {
+		t.Run(name, func(t *testing.T) {
+			_, body, err := acceptWith(t, []string{"127.0.0.1"}, header)
+			assert.Error(t, err)
+			assert.Empty(t, body)
+		})
+	}
+}
+
+func TestProxyProtocolUntrusted(t *testing.T) {
+	data := "PROXY TCP4 203.0.113.7 10.0.0.1 56324 443\r\n"
+	remoteAddr, body, err := acceptWith(t, []string{"10.0.0.0/8"}, []byte(data))
+	require.NoError(t, err)
+	assert.Contains(t, remoteAddr, "127.0.0.1:", "the header of untrusted sources is ignored")
+	assert.Equal(t, data, body)
+}
+
+func TestParseCIDRs(t *testing.T) {
+	networks, err := ParseCIDRs([]string{"10.0.0.0/8", "192.168.1.1", "2001:db8::/32"})
+	require.NoError(t, err)
+	require.Len(t, networks, 3)
+	assert.True(t, networks[1].Contains(net.ParseIP("192.168.1.1")))
+	assert.False(t, networks[1].Contains(net.ParseIP("192.168.1.2")))
+
+	_, err = ParseCIDRs([]string{"10.0.0.0/33"})
+	assert.Error(t, err)
+	_, err = ParseCIDRs([]string{"not-an-ip"})
+	assert.Error(t, err)
+}
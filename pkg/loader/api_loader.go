@@ -1,34 +1,114 @@
 package loader
 
 import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
 	"github.com/hellofresh/janus/pkg/api"
+	"github.com/hellofresh/janus/pkg/errors"
+	"github.com/hellofresh/janus/pkg/maintenance"
 	"github.com/hellofresh/janus/pkg/middleware"
 	obs "github.com/hellofresh/janus/pkg/observability"
 	"github.com/hellofresh/janus/pkg/plugin"
 	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/hellofresh/janus/pkg/router"
+	"github.com/hellofresh/janus/pkg/sampling"
+	"github.com/hellofresh/janus/pkg/upstream"
 	log "github.com/sirupsen/logrus"
 	"go.opencensus.io/tag"
 )
 
 // APILoader is responsible for loading all apis form a datastore and configure them in a register
 type APILoader struct {
-	register *proxy.Register
+	register       *proxy.Register
+	maintenance    *maintenance.Modes
+	weights        *upstream.Weights
+	samplingRates  *sampling.Rates
+	requestTimeout time.Duration
+
+	accessLog        *middleware.AccessLog
+	accessLogEnabled bool
+	pluginSpans      bool
+
+	// instances are the middleware of the stateful plugins of the registered APIs, they are kept by
+	// the reloads as long as the plugin configs are unchanged
+	instancesMu sync.Mutex
+	instances   map[string]pluginInstance
+}
+
+// pluginInstance is the middleware a stateful plugin set up for an API with a config
+type pluginInstance struct {
+	config     string
+	middleware []router.Constructor
 }
 
-// NewAPILoader creates a new instance of the api manager
-func NewAPILoader(register *proxy.Register) *APILoader {
-	return &APILoader{register: register}
+// NewAPILoader creates a new instance of the api manager. The APIs are registered in their own maintenance
+// modes, weights and sampling rates, not sampled by default, without the options setting them.
+func NewAPILoader(register *proxy.Register, opts ...Option) *APILoader {
+	m := APILoader{
+		register:      register,
+		maintenance:   maintenance.NewModes(),
+		weights:       upstream.NewWeights(),
+		samplingRates: sampling.NewRates(0),
+		instances:     make(map[string]pluginInstance),
+	}
+
+	for _, opt := range opts {
+		opt(&m)
+	}
+
+	return &m
 }
 
-// RegisterAPIs load application middleware
+// RegisterAPIs load application middleware, the stateful plugins of the APIs registered before keep
+// their middleware when their config is unchanged and the others are set up again. The sampling rates
+// set at runtime are dropped, the reloaded APIs are sampled by the default sampler.
 func (m *APILoader) RegisterAPIs(cfgs []*api.Definition) {
+	m.instancesMu.Lock()
+	defer m.instancesMu.Unlock()
+
+	m.samplingRates.Reset()
+
+	// the instances of the APIs and the plugins not registered anymore are dropped
+	previous := m.instances
+	m.instances = make(map[string]pluginInstance)
 	for _, spec := range cfgs {
-		m.RegisterAPI(spec)
+		m.registerAPI(spec, previous)
+	}
+}
+
+// accessLogRoute returns the middleware setting the route name of the API and writing its access log
+// entries, with the access log settings of the API taking precedence over the global ones
+func (m *APILoader) accessLogRoute(def *api.Definition) func(http.Handler) http.Handler {
+	if m.accessLog == nil {
+		return middleware.NewAccessLogRoute(def.Name)
+	}
+
+	enabled := m.accessLogEnabled
+	if def.AccessLog.Enabled != nil {
+		enabled = *def.AccessLog.Enabled
+	}
+
+	accessLog := m.accessLog
+	if def.AccessLog.Format != "" {
+		accessLog = accessLog.WithFormat(def.AccessLog.Format)
 	}
+
+	return accessLog.Route(def.Name, enabled)
 }
 
 // RegisterAPI register an API Definition in the register
 func (m *APILoader) RegisterAPI(def *api.Definition) {
+	m.instancesMu.Lock()
+	defer m.instancesMu.Unlock()
+
+	m.registerAPI(def, m.instances)
+}
+
+func (m *APILoader) registerAPI(def *api.Definition, previous map[string]pluginInstance) {
 	logger := log.WithField("api_name", def.Name)
 	logger.Debug("Starting RegisterAPI")
 
@@ -45,45 +125,167 @@ func (m *APILoader) RegisterAPI(def *api.Definition) {
 	if active {
 		routerDefinition := proxy.NewRouterDefinition(def.Proxy)
 
+		// the sampler is set before the API span, the first span of the requests
+		if rate, ok, _ := def.Tracing.SamplingRate(); ok {
+			m.samplingRates.SetDefault(def.Name, rate)
+		}
+		routerDefinition.AddMiddleware(m.samplingRates.Handler(def.Name))
+		if len(def.Tracing.Tags) > 0 {
+			routerDefinition.AddMiddleware(middleware.NewSpanTags(def.Tracing.Tags))
+		}
+
+		// the plugin spans are nested in the API span, rather than being the roots of the traces
+		if m.pluginSpans {
+			routerDefinition.AddMiddleware(middleware.NewAPISpan(def.Name))
+		}
+
+		// Add middleware to insert tags to context, before the plugins so they can record metrics by API
+		tags := []tag.Mutator{
+			tag.Insert(obs.KeyListenPath, def.Proxy.ListenPath),
+			tag.Insert(obs.KeyAPIName, def.Name),
+		}
+		routerDefinition.AddMiddleware(middleware.NewStatsTagger(tags).Handler)
+		routerDefinition.AddMiddleware(middleware.NewAPIMetrics().Handler)
+		routerDefinition.AddMiddleware(m.accessLogRoute(def))
+		if def.SlowLog.Threshold > 0 {
+			routerDefinition.AddMiddleware(middleware.NewSlowLogThreshold(time.Duration(def.SlowLog.Threshold)))
+		}
+
+		// the error templates are set before the timeout and the plugins, so they render all their errors
+		if len(def.ErrorTemplates) > 0 {
+			templates, err := errors.NewTemplates(def.ErrorTemplates)
+			if err != nil {
+				logger.WithError(err).Error("Error templates are invalid")
+			} else {
+				routerDefinition.AddMiddleware(templates.Handler)
+			}
+		}
+
+		// the timeout is set before the plugins, so the deadline bounds them as well as the upstream call
+		requestTimeout := m.requestTimeout
+		if def.RequestTimeout > 0 {
+			requestTimeout = time.Duration(def.RequestTimeout)
+		}
+		if requestTimeout > 0 {
+			routerDefinition.AddMiddleware(middleware.NewTimeout(requestTimeout))
+		}
+
+		// the streamed responses are flushed as they are written, so they can not be rewritten
+		buffering := m.register.Buffering(def.Proxy)
+		streamed := buffering.Streamed()
+		decoded := def.Proxy.Decompression.Enabled
+		buffered := false
+		occurrences := make(map[string]int)
 		for _, plg := range def.Plugins {
 			l := logger.WithField("name", plg.Name)
+			key := fmt.Sprintf("%s/%s/%d", def.Name, plg.Name, occurrences[plg.Name])
+			occurrences[plg.Name]++
 
 			isValid, err := plugin.ValidateConfig(plg.Name, plg.Config)
 			if !isValid || err != nil {
 				l.WithError(err).Error("Plugin configuration is invalid")
 			}
 
-			if plg.Enabled {
+			if plg.Enabled && streamed && plugin.ModifiesResponseBody(plg.Name) {
+				l.Warn("Plugin modifies the response bodies, skipping it for the streamed API")
+			} else if plg.Enabled {
 				l.Debug("Plugin enabled")
-
-				setup, err := plugin.DirectiveAction(plg.Name)
-				if err != nil {
-					l.WithError(err).Error("Error loading plugin")
-					continue
-				}
-
-				err = setup(routerDefinition, plg.Config)
-				if err != nil {
-					l.WithError(err).Error("Error executing plugin")
-				}
+				m.setupPlugin(l, routerDefinition, key, plg, previous)
+				decoded = decoded || plugin.DecodesResponseBody(plg.Name)
+				buffered = buffered || plugin.BuffersResponseBody(plg.Name)
 			} else {
 				l.Debug("Plugin not enabled")
 			}
 		}
 
-		if len(def.Proxy.Hosts) > 0 {
-			routerDefinition.AddMiddleware(middleware.NewHostMatcher(def.Proxy.Hosts).Handler)
+		// the compressed upstream responses are decoded by the proxy, before the response writers of the
+		// plugins see them
+		if decoded {
+			routerDefinition.AddMiddleware(proxy.DecodeResponses(def.Proxy.Decompression))
 		}
 
-		// Add middleware to insert tags to context
-		tags := []tag.Mutator{
-			tag.Insert(obs.KeyListenPath, def.Proxy.ListenPath),
+		// the max response body size is enforced only when a plugin keeps the bodies in memory, the other
+		// APIs stream the bodies larger than it
+		if buffering.MaxResponseBodySize > 0 {
+			routerDefinition.AddMiddleware(proxy.LimitResponses(buffering.MaxResponseBodySize, buffered))
+		}
+
+		// the request bodies are buffered after the plugins, so the bodies they rewrite are sent with their
+		// own Content-Length
+		if buffering.BufferRequestBodySize > 0 {
+			routerDefinition.AddMiddleware(proxy.BufferRequests(buffering.BufferRequestBodySize))
 		}
-		routerDefinition.AddMiddleware(middleware.NewStatsTagger(tags).Handler)
 
-		m.register.Add(routerDefinition)
+		// the maintenance mode is checked after the plugins, so the requests turned away are still
+		// authenticated and logged
+		m.maintenance.Set(def.Name, def.Maintenance)
+		routerDefinition.AddMiddleware(m.maintenance.Handler(def.Name))
+
+		// the weights adjusted with the admin API take effect before the routes are reloaded, the targets
+		// joining the API warm up with its slow start
+		m.weights.SetSlowStart(def.Name, time.Duration(def.Proxy.Upstreams.SlowStart))
+		m.weights.Set(def.Name, def.Proxy.Upstreams.Targets)
+		routerDefinition.AddMiddleware(m.weights.Handler(def.Name))
+
+		if err := m.register.Add(routerDefinition); err != nil {
+			logger.WithError(err).Error("Could not register the API")
+			return
+		}
 		logger.Debug("API registered")
 	} else {
 		logger.WithError(err).Warn("API URI is invalid or not active, skipping...")
 	}
 }
+
+// setupPlugin adds the middleware of the plugin to the router definition, the middleware of a stateful
+// plugin is reused when it was set up with the same config before
+func (m *APILoader) setupPlugin(logger *log.Entry, def *proxy.RouterDefinition, key string, plg api.Plugin, previous map[string]pluginInstance) {
+	stateful := plugin.Stateful(plg.Name)
+
+	var config string
+	if stateful {
+		// the map keys are sorted, so the same configs are encoded the same way
+		b, err := json.Marshal(plg.Config)
+		if err != nil {
+			logger.WithError(err).Warn("Could not encode the plugin config, setting the plugin up again")
+			stateful = false
+		}
+		config = string(b)
+	}
+
+	if instance, ok := previous[key]; stateful && ok && instance.config == config {
+		logger.Debug("Plugin config is unchanged, keeping its state")
+		m.addPluginMiddleware(def, plg.Name, instance.middleware)
+		m.instances[key] = instance
+		return
+	}
+
+	setup, err := plugin.DirectiveAction(plg.Name)
+	if err != nil {
+		logger.WithError(err).Error("Error loading plugin")
+		return
+	}
+
+	// the plugin is set up on its own router definition sharing the proxy definition, so the middleware
+	// it adds is known and kept for the next reloads
+	pluginDef := proxy.NewRouterDefinition(def.Definition)
+	if err := setup(pluginDef, plg.Config); err != nil {
+		logger.WithError(err).Error("Error executing plugin")
+		return
+	}
+
+	m.addPluginMiddleware(def, plg.Name, pluginDef.Middleware())
+	if stateful {
+		m.instances[key] = pluginInstance{config: config, middleware: pluginDef.Middleware()}
+	}
+}
+
+// addPluginMiddleware adds the middleware of the plugin, wrapped in the plugin spans when they are enabled
+func (m *APILoader) addPluginMiddleware(def *proxy.RouterDefinition, name string, constructors []router.Constructor) {
+	for _, mw := range constructors {
+		if m.pluginSpans {
+			mw = middleware.PluginSpan(name, mw)
+		}
+		def.AddMiddleware(mw)
+	}
+}
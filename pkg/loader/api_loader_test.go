@@ -12,6 +12,7 @@ import (
 	"github.com/hellofresh/janus/pkg/middleware"
 	"github.com/hellofresh/janus/pkg/proxy"
 	"github.com/hellofresh/janus/pkg/router"
+	"github.com/hellofresh/janus/pkg/sampling"
 	"github.com/hellofresh/janus/pkg/test"
 	"github.com/hellofresh/stats-go/client"
 	log "github.com/sirupsen/logrus"
@@ -82,7 +83,7 @@ func createRegisterAndRouter() (router.Router, error) {
 		return nil, err
 	}
 
-	loader := NewAPILoader(register)
+	loader := NewAPILoader(register, WithSamplingRates(sampling.NewRates(1)))
 	loader.RegisterAPIs(defs)
 
 	return r, nil
@@ -0,0 +1,93 @@
+package loader
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hellofresh/janus/pkg/api"
+	"github.com/hellofresh/janus/pkg/plugin"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/hellofresh/janus/pkg/router"
+	"github.com/hellofresh/janus/pkg/sampling"
+	"github.com/hellofresh/stats-go/client"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// upperWriter upper cases the response body, as a plugin rewriting the decoded bodies does
+type upperWriter struct {
+	http.ResponseWriter
+}
+
+func (w upperWriter) Write(b []byte) (int, error) {
+	return w.ResponseWriter.Write(bytes.ToUpper(b))
+}
+
+func init() {
+	plugin.RegisterPlugin("test_upper", plugin.Plugin{
+		Action: func(def *proxy.RouterDefinition, rawConfig plugin.Config) error {
+			def.AddMiddleware(func(handler http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					handler.ServeHTTP(upperWriter{w}, r)
+				})
+			})
+			return nil
+		},
+		ModifiesResponseBody: true,
+		DecodesResponseBody:  true,
+	})
+}
+
+func TestPluginsReadDecodedResponses(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+
+	var encoded bytes.Buffer
+	gw := gzip.NewWriter(&encoded)
+	gw.Write([]byte("hello world"))
+	require.NoError(t, gw.Close())
+
+	upstreamServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(encoded.Bytes())
+	}))
+	defer upstreamServer.Close()
+
+	newDefinition := func(name string, plugins ...api.Plugin) *api.Definition {
+		def := api.NewDefinition()
+		def.Name = name
+		def.Proxy.ListenPath = "/" + name
+		def.Proxy.Upstreams = &proxy.Upstreams{Balancing: "roundrobin", Targets: []*proxy.Target{{Target: upstreamServer.URL}}}
+		def.Plugins = plugins
+		return def
+	}
+
+	r := router.NewChiRouter()
+	register := proxy.NewRegister(proxy.WithRouter(r), proxy.WithStatsClient(client.NewNoop()))
+	loader := NewAPILoader(register, WithSamplingRates(sampling.NewRates(1)))
+	loader.RegisterAPIs([]*api.Definition{
+		newDefinition("decoded", api.Plugin{Name: "test_upper", Enabled: true}),
+		newDefinition("disabled", api.Plugin{Name: "test_upper", Enabled: false}),
+	})
+
+	// the transport decodes the responses of the requests not accepting an encoding itself
+	do := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("/decoded")
+	assert.Equal(t, "HELLO WORLD", w.Body.String(), "the plugin rewrites the decoded body")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+
+	w = do("/disabled")
+	assert.Equal(t, encoded.Bytes(), w.Body.Bytes(), "the responses of the APIs without the plugin are passed encoded")
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+}
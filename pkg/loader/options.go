@@ -0,0 +1,67 @@
+package loader
+
+import (
+	"time"
+
+	"github.com/hellofresh/janus/pkg/maintenance"
+	"github.com/hellofresh/janus/pkg/middleware"
+	"github.com/hellofresh/janus/pkg/sampling"
+	"github.com/hellofresh/janus/pkg/upstream"
+)
+
+// Option represents the API loader options
+type Option func(*APILoader)
+
+// WithMaintenanceModes sets the maintenance modes the APIs are registered in, so they can be changed
+// at runtime
+func WithMaintenanceModes(modes *maintenance.Modes) Option {
+	return func(m *APILoader) {
+		m.maintenance = modes
+	}
+}
+
+// WithWeights sets the upstream target weights the APIs are registered in, so they can be changed
+// at runtime
+func WithWeights(weights *upstream.Weights) Option {
+	return func(m *APILoader) {
+		m.weights = weights
+	}
+}
+
+// WithSamplingRates sets the trace sampling rates the APIs are registered in, so they can be changed
+// at runtime
+func WithSamplingRates(rates *sampling.Rates) Option {
+	return func(m *APILoader) {
+		m.samplingRates = rates
+	}
+}
+
+// WithRequestTimeout sets the request timeout of the APIs not setting their own one
+func WithRequestTimeout(d time.Duration) Option {
+	return func(m *APILoader) {
+		m.requestTimeout = d
+	}
+}
+
+// WithAccessLog sets the access log the entries of the APIs are written to, they are not written
+// without it
+func WithAccessLog(accessLog *middleware.AccessLog) Option {
+	return func(m *APILoader) {
+		m.accessLog = accessLog
+	}
+}
+
+// WithAccessLogEnabled sets if the access log entries are written for the APIs not enabling or disabling
+// their own ones
+func WithAccessLogEnabled(enabled bool) Option {
+	return func(m *APILoader) {
+		m.accessLogEnabled = enabled
+	}
+}
+
+// WithPluginSpans sets if the plugins are wrapped in the spans named after them
+func WithPluginSpans(enabled bool) Option {
+	return func(m *APILoader) {
+		m.pluginSpans = enabled
+	}
+}
@@ -0,0 +1,77 @@
+package loader
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hellofresh/janus/pkg/api"
+	"github.com/hellofresh/janus/pkg/plugin"
+	_ "github.com/hellofresh/janus/pkg/plugin/rate"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/hellofresh/janus/pkg/router"
+	"github.com/hellofresh/janus/pkg/sampling"
+	"github.com/hellofresh/stats-go/client"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRateLimitedDefinition(upstreamURL string, limit string) *api.Definition {
+	def := api.NewDefinition()
+	def.Name = "limited"
+	def.Proxy.ListenPath = "/limited"
+	def.Proxy.Upstreams = &proxy.Upstreams{Balancing: "roundrobin", Targets: []*proxy.Target{{Target: upstreamURL}}}
+	def.Plugins = []api.Plugin{{
+		Name:    "rate_limit",
+		Enabled: true,
+		Config:  map[string]interface{}{"limit": limit, "policy": "local"},
+	}}
+
+	return def
+}
+
+func TestReloadKeepsPluginState(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	require.NoError(t, plugin.EmitEvent(plugin.StartupEvent, plugin.OnStartup{StatsClient: client.NewNoop()}))
+
+	upstreamServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstreamServer.Close()
+
+	r := router.NewChiRouter()
+	register := proxy.NewRegister(proxy.WithRouter(r), proxy.WithStatsClient(client.NewNoop()))
+	loader := NewAPILoader(register, WithSamplingRates(sampling.NewRates(1)))
+	loader.RegisterAPIs([]*api.Definition{newRateLimitedDefinition(upstreamServer.URL, "2-M")})
+
+	// reload creates a new router with the definitions, as the server does on the config changes
+	reload := func(defs ...*api.Definition) {
+		r = router.NewChiRouter()
+		register.UpdateRouter(r)
+		loader.RegisterAPIs(defs)
+	}
+	do := func() int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/limited", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	require.Equal(t, http.StatusOK, do())
+	require.Equal(t, http.StatusOK, do())
+
+	reload(newRateLimitedDefinition(upstreamServer.URL, "2-M"))
+	assert.Equal(t, http.StatusTooManyRequests, do(), "the unchanged plugin keeps its counters")
+
+	reload(newRateLimitedDefinition(upstreamServer.URL, "3-M"))
+	assert.Equal(t, http.StatusOK, do(), "the changed plugin is set up again")
+
+	// the instances of the removed APIs are dropped, they start over when they are added back
+	reload()
+	assert.Empty(t, loader.instances)
+	reload(newRateLimitedDefinition(upstreamServer.URL, "3-M"))
+	assert.Equal(t, http.StatusOK, do())
+}
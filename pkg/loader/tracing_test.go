@@ -0,0 +1,82 @@
+package loader
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/hellofresh/janus/pkg/api"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/hellofresh/janus/pkg/router"
+	"github.com/hellofresh/janus/pkg/sampling"
+	"github.com/hellofresh/stats-go/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/trace"
+)
+
+// spanRecorder keeps the ended spans by name
+type spanRecorder struct {
+	sync.Mutex
+	spans map[string]*trace.SpanData
+}
+
+func (e *spanRecorder) ExportSpan(s *trace.SpanData) {
+	e.Lock()
+	defer e.Unlock()
+	e.spans[s.Name] = s
+}
+
+func newTracedDefinition(name, upstreamURL string, tracing api.Tracing) *api.Definition {
+	def := api.NewDefinition()
+	def.Name = name
+	def.Proxy.ListenPath = "/" + name
+	def.Proxy.Upstreams = &proxy.Upstreams{Balancing: "roundrobin", Targets: []*proxy.Target{{Target: upstreamURL}}}
+	def.Tracing = tracing
+
+	return def
+}
+
+func TestRegisterAPITracing(t *testing.T) {
+	e := &spanRecorder{spans: make(map[string]*trace.SpanData)}
+	trace.RegisterExporter(e)
+	trace.ApplyConfig(trace.Config{DefaultSampler: trace.NeverSample()})
+	defer func() {
+		trace.UnregisterExporter(e)
+		trace.ApplyConfig(trace.Config{DefaultSampler: trace.ProbabilitySampler(1e-4)})
+	}()
+
+	upstreamServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstreamServer.Close()
+
+	r := router.NewChiRouter()
+	register := proxy.NewRegister(proxy.WithRouter(r), proxy.WithStatsClient(client.NewNoop()))
+	rates := sampling.NewRates(0)
+	loader := NewAPILoader(register, WithSamplingRates(rates), WithPluginSpans(true))
+	loader.RegisterAPIs([]*api.Definition{
+		newTracedDefinition("critical", upstreamServer.URL, api.Tracing{SamplingStrategy: sampling.Always, Tags: map[string]string{"team": "payments"}}),
+		newTracedDefinition("noisy", upstreamServer.URL, api.Tracing{SamplingStrategy: sampling.Probabilistic, SamplingParam: 0}),
+		newTracedDefinition("default", upstreamServer.URL, api.Tracing{}),
+	})
+
+	for _, path := range []string{"/critical", "/noisy", "/default"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	assert.Equal(t, sampling.Rate{Rate: 1}, rates.Get("critical"))
+	assert.Equal(t, sampling.Rate{Rate: 0}, rates.Get("noisy"))
+
+	e.Lock()
+	defer e.Unlock()
+	require.Contains(t, e.spans, "api.critical", "the API sampling strategy overrides the global one")
+	assert.Equal(t, "payments", e.spans["api.critical"].Attributes["team"])
+	require.Contains(t, e.spans, "/critical")
+	assert.Equal(t, "payments", e.spans["/critical"].Attributes["team"], "the tags are added to the proxy span")
+	assert.NotContains(t, e.spans, "api.noisy")
+	assert.NotContains(t, e.spans, "api.default", "the APIs without a sampling strategy use the global one")
+}
@@ -0,0 +1,75 @@
+// Package lock provides the locks serializing the work of the Janus instances on a key, e.g. the
+// requests in flight of an idempotency key or the leader of a periodic job.
+package lock
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+const (
+	// driftFactor and minDrift bound the drift between the clock of the instance holding a lock and the
+	// clock expiring it, the lock is considered lost by its holder that much before its ttl
+	driftFactor = 0.01
+	minDrift    = 2 * time.Millisecond
+)
+
+var (
+	// ErrNotHeld is returned when the lock of a token expired, another holder may have acquired it since
+	ErrNotHeld = errors.New("lock: the lock is not held")
+	// ErrInvalidTTL is returned when a lock is acquired or extended without a positive ttl
+	ErrInvalidTTL = errors.New("lock: the ttl must be greater than zero")
+)
+
+// Locker acquires the locks of the keys, each lock has a single holder at a time until it is released
+// or its ttl expires
+type Locker interface {
+	// Lock acquires the lock of the key for the ttl, it returns a nil token when the lock is held
+	Lock(key string, ttl time.Duration) (*Token, error)
+	// Unlock releases the lock of the token. It returns ErrNotHeld when the lock expired, the lock
+	// acquired by another holder since is left untouched.
+	Unlock(token *Token) error
+	// Extend sets the ttl of the lock of the token, so long tasks keep it. It returns ErrNotHeld when
+	// the lock expired.
+	Extend(token *Token, ttl time.Duration) error
+}
+
+// Token identifies the holder of a lock
+type Token struct {
+	Key   string
+	Value string
+
+	validUntil time.Time
+}
+
+// newToken creates a random token for the key, valid for the ttl from the start of the lock request
+func newToken(key string, start time.Time, ttl time.Duration) (*Token, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+
+	t := &Token{Key: key, Value: hex.EncodeToString(b)}
+	t.renew(start, ttl)
+	return t, nil
+}
+
+// renew sets the validity of the token. It is measured from the time the lock request was sent, with
+// the monotonic clock of the instance, and shortened by the clock drift, so the holder stops relying on
+// the lock before it expires wherever it is kept.
+func (t *Token) renew(start time.Time, ttl time.Duration) {
+	drift := time.Duration(float64(ttl)*driftFactor) + minDrift
+	t.validUntil = start.Add(ttl - drift)
+}
+
+// Valid tells if the lock of the token is still held, according to the clock of the instance
+func (t *Token) Valid() bool {
+	return t.TTL() > 0
+}
+
+// TTL returns the time the lock of the token is still held for, according to the clock of the instance
+func (t *Token) TTL() time.Duration {
+	return time.Until(t.validUntil)
+}
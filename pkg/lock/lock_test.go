@@ -0,0 +1,121 @@
+package lock
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testContention makes the goroutines race for the lock of a key, a single one must acquire it
+func testContention(t *testing.T, locker Locker) {
+	const contenders = 20
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		tokens []*Token
+	)
+	for i := 0; i < contenders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := locker.Lock("contended", time.Minute)
+			assert.NoError(t, err)
+			if token != nil {
+				mu.Lock()
+				tokens = append(tokens, token)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Len(t, tokens, 1, "a single contender holds the lock")
+	require.NoError(t, locker.Unlock(tokens[0]))
+
+	token, err := locker.Lock("contended", time.Minute)
+	require.NoError(t, err)
+	assert.NotNil(t, token, "the released lock is acquired again")
+}
+
+// testExpiry checks the expired lock of a holder is acquired by another one, which the first holder can
+// neither release nor extend
+func testExpiry(t *testing.T, locker Locker) {
+	stale, err := locker.Lock("expiring", 20*time.Millisecond)
+	require.NoError(t, err)
+	require.NotNil(t, stale)
+
+	token, err := locker.Lock("expiring", time.Minute)
+	require.NoError(t, err)
+	assert.Nil(t, token, "the lock is held")
+
+	time.Sleep(30 * time.Millisecond)
+	assert.False(t, stale.Valid())
+
+	token, err = locker.Lock("expiring", time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, token, "the expired lock is acquired")
+
+	assert.Equal(t, ErrNotHeld, locker.Unlock(stale))
+	assert.Equal(t, ErrNotHeld, locker.Extend(stale, time.Minute))
+
+	other, err := locker.Lock("expiring", time.Minute)
+	require.NoError(t, err)
+	assert.Nil(t, other, "the stale holder did not release the lock of the new holder")
+
+	require.NoError(t, locker.Extend(token, time.Hour))
+	assert.True(t, token.TTL() > time.Minute, "the extended token is renewed")
+	require.NoError(t, locker.Unlock(token))
+	assert.Equal(t, ErrNotHeld, locker.Unlock(token), "the lock is released once")
+}
+
+func TestMemoryLockerContention(t *testing.T) {
+	testContention(t, NewMemoryLocker())
+}
+
+func TestMemoryLockerExpiry(t *testing.T) {
+	testExpiry(t, NewMemoryLocker())
+}
+
+func TestMemoryLockerSweep(t *testing.T) {
+	locker := NewMemoryLocker().(*memoryLocker)
+	_, err := locker.Lock("expired", time.Millisecond)
+	require.NoError(t, err)
+	time.Sleep(2 * time.Millisecond)
+
+	locker.swept = time.Now().Add(-2 * sweepInterval)
+	_, err = locker.Lock("key", time.Hour)
+	require.NoError(t, err)
+	assert.Len(t, locker.locks, 1)
+}
+
+func TestInvalidTTL(t *testing.T) {
+	locker := NewMemoryLocker()
+	_, err := locker.Lock("key", 0)
+	assert.Equal(t, ErrInvalidTTL, err)
+
+	token, err := locker.Lock("key", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, ErrInvalidTTL, locker.Extend(token, -time.Second))
+}
+
+func TestTokenDrift(t *testing.T) {
+	start := time.Now()
+	token, err := newToken("key", start, 10*time.Second)
+	require.NoError(t, err)
+	assert.Len(t, token.Value, 32)
+
+	assert.Equal(t, start.Add(10*time.Second-100*time.Millisecond-minDrift), token.validUntil,
+		"the lock is considered lost before its ttl by the clock drift")
+	assert.True(t, token.Valid())
+
+	other, err := newToken("key", start, 10*time.Second)
+	require.NoError(t, err)
+	assert.NotEqual(t, token.Value, other.Value, "the tokens of the holders are unique")
+
+	token.renew(start.Add(-time.Minute), 10*time.Second)
+	assert.False(t, token.Valid(), "the validity is measured from the start of the lock request")
+}
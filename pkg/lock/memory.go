@@ -0,0 +1,99 @@
+package lock
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the memory locker removes the expired locks
+const sweepInterval = time.Minute
+
+type memoryLock struct {
+	value     string
+	expiresAt time.Time
+}
+
+// memoryLocker keeps the locks in the memory of the Janus instance
+type memoryLocker struct {
+	mu    sync.Mutex
+	locks map[string]memoryLock
+	swept time.Time
+}
+
+// NewMemoryLocker creates a Locker local to the Janus instance
+func NewMemoryLocker() Locker {
+	return &memoryLocker{locks: make(map[string]memoryLock), swept: time.Now()}
+}
+
+func (l *memoryLocker) Lock(key string, ttl time.Duration) (*Token, error) {
+	if ttl <= 0 {
+		return nil, ErrInvalidTTL
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+	if lock, ok := l.locks[key]; ok && now.Before(lock.expiresAt) {
+		return nil, nil
+	}
+
+	token, err := newToken(key, now, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	l.locks[key] = memoryLock{value: token.Value, expiresAt: now.Add(ttl)}
+	return token, nil
+}
+
+func (l *memoryLocker) Unlock(token *Token) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.held(token, time.Now()) {
+		return ErrNotHeld
+	}
+
+	delete(l.locks, token.Key)
+	return nil
+}
+
+func (l *memoryLocker) Extend(token *Token, ttl time.Duration) error {
+	if ttl <= 0 {
+		return ErrInvalidTTL
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if !l.held(token, now) {
+		return ErrNotHeld
+	}
+
+	l.locks[token.Key] = memoryLock{value: token.Value, expiresAt: now.Add(ttl)}
+	token.renew(now, ttl)
+	return nil
+}
+
+// held tells if the lock of the token is not expired, it must be called with the locker locked
+func (l *memoryLocker) held(token *Token, now time.Time) bool {
+	lock, ok := l.locks[token.Key]
+	return ok && lock.value == token.Value && now.Before(lock.expiresAt)
+}
+
+// sweep removes the expired locks, it must be called with the locker locked
+func (l *memoryLocker) sweep(now time.Time) {
+	if now.Sub(l.swept) < sweepInterval {
+		return
+	}
+	l.swept = now
+
+	for key, lock := range l.locks {
+		if !now.Before(lock.expiresAt) {
+			delete(l.locks, key)
+		}
+	}
+}
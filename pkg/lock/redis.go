@@ -0,0 +1,95 @@
+package lock
+
+import (
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+var (
+	// unlockScript deletes the lock only when it is still held by the token, KEYS[1] is the lock key
+	// and ARGV[1] the token value. It returns 1 when the lock was released.
+	unlockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+	// extendScript sets the ttl of the lock only when it is still held by the token, KEYS[1] is the lock
+	// key, ARGV are the token value and the ttl in milliseconds. It returns 1 when the lock was extended.
+	extendScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+)
+
+// redisLocker keeps the locks in redis, so they are shared by the Janus instances. The locks expire on
+// the redis clock, and their holders tell their validity with their own clocks, so the clocks of the
+// instances do not have to agree.
+type redisLocker struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisLocker creates a Locker shared by the Janus instances using the redis client, the lock keys
+// are prefixed with the prefix
+func NewRedisLocker(client *redis.Client, prefix string) Locker {
+	return &redisLocker{client: client, prefix: prefix}
+}
+
+func (l *redisLocker) Lock(key string, ttl time.Duration) (*Token, error) {
+	if ttl < time.Millisecond {
+		return nil, ErrInvalidTTL
+	}
+
+	start := time.Now()
+	token, err := newToken(key, start, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	// SET NX PX acquires the lock and sets its ttl atomically, so a lock is never left without one
+	cmd := redis.NewCmd("set", l.prefix+key, token.Value, "nx", "px", int64(ttl/time.Millisecond))
+	err = l.client.Process(cmd)
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+func (l *redisLocker) Unlock(token *Token) error {
+	released, err := unlockScript.Run(l.client, []string{l.prefix + token.Key}, token.Value).Result()
+	if err != nil {
+		return err
+	}
+	if released != int64(1) {
+		return ErrNotHeld
+	}
+
+	return nil
+}
+
+func (l *redisLocker) Extend(token *Token, ttl time.Duration) error {
+	if ttl < time.Millisecond {
+		return ErrInvalidTTL
+	}
+
+	start := time.Now()
+	extended, err := extendScript.Run(l.client, []string{l.prefix + token.Key}, token.Value, int64(ttl/time.Millisecond)).Result()
+	if err != nil {
+		return err
+	}
+	if extended != int64(1) {
+		return ErrNotHeld
+	}
+
+	token.renew(start, ttl)
+	return nil
+}
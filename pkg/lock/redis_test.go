@@ -0,0 +1,228 @@
+package lock
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// redisServer is a fake redis server running the commands and the scripts of the redis locker, its
+// clock is skewed by the offset
+type redisServer struct {
+	sync.Mutex
+	listener net.Listener
+	offset   time.Duration
+	values   map[string]string
+	expires  map[string]time.Time
+	commands []string
+}
+
+func newRedisServer(t *testing.T, offset time.Duration) *redisServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &redisServer{listener: listener, offset: offset, values: make(map[string]string), expires: make(map[string]time.Time)}
+	go s.serve()
+
+	return s
+}
+
+func (s *redisServer) client() *redis.Client {
+	return redis.NewClient(&redis.Options{Addr: s.listener.Addr().String()})
+}
+
+func (s *redisServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			defer conn.Close()
+			reader := bufio.NewReader(conn)
+			for {
+				args, err := readCommand(reader)
+				if err != nil {
+					return
+				}
+
+				fmt.Fprint(conn, s.exec(args))
+			}
+		}()
+	}
+}
+
+// readCommand reads a command sent as an array of bulk strings
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, count)
+	for i := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		args[i] = string(data[:size])
+	}
+
+	return args, nil
+}
+
+func (s *redisServer) now() time.Time {
+	return time.Now().Add(s.offset)
+}
+
+func (s *redisServer) get(key string) (string, bool) {
+	if expire, ok := s.expires[key]; ok && !s.now().Before(expire) {
+		delete(s.values, key)
+		delete(s.expires, key)
+	}
+
+	value, ok := s.values[key]
+	return value, ok
+}
+
+func (s *redisServer) exec(args []string) string {
+	s.Lock()
+	defer s.Unlock()
+
+	s.commands = append(s.commands, strings.ToLower(strings.Join(args, " ")))
+	switch strings.ToLower(args[0]) {
+	case "ping":
+		return "+PONG\r\n"
+	case "set":
+		// SET key value NX PX milliseconds
+		if len(args) != 6 || strings.ToLower(args[3]) != "nx" || strings.ToLower(args[4]) != "px" {
+			return "-ERR syntax error\r\n"
+		}
+		if _, ok := s.get(args[1]); ok {
+			return "$-1\r\n"
+		}
+		ms, _ := strconv.Atoi(args[5])
+		s.values[args[1]] = args[2]
+		s.expires[args[1]] = s.now().Add(time.Duration(ms) * time.Millisecond)
+		return "+OK\r\n"
+	case "evalsha":
+		return "-NOSCRIPT No matching script. Please use EVAL.\r\n"
+	case "eval":
+		return s.eval(args[1], args[3], args[4:])
+	}
+
+	return "-ERR unknown command\r\n"
+}
+
+// eval runs the scripts of the redis locker, told apart by their hash
+func (s *redisServer) eval(script, key string, argv []string) string {
+	sum := sha1.Sum([]byte(script))
+	hash := hex.EncodeToString(sum[:])
+
+	value, ok := s.get(key)
+	if !ok || value != argv[0] {
+		return ":0\r\n"
+	}
+
+	switch hash {
+	case unlockScript.Hash():
+		delete(s.values, key)
+		delete(s.expires, key)
+	case extendScript.Hash():
+		ms, _ := strconv.Atoi(argv[1])
+		s.expires[key] = s.now().Add(time.Duration(ms) * time.Millisecond)
+	default:
+		return "-ERR unknown script\r\n"
+	}
+
+	return ":1\r\n"
+}
+
+func TestRedisLockerContention(t *testing.T) {
+	server := newRedisServer(t, 0)
+	defer server.listener.Close()
+
+	testContention(t, NewRedisLocker(server.client(), "janus:lock:"))
+
+	server.Lock()
+	defer server.Unlock()
+	assert.Contains(t, server.values, "janus:lock:contended")
+}
+
+func TestRedisLockerExpiry(t *testing.T) {
+	server := newRedisServer(t, 0)
+	defer server.listener.Close()
+
+	testExpiry(t, NewRedisLocker(server.client(), "janus:lock:"))
+}
+
+func TestRedisLockerCommands(t *testing.T) {
+	server := newRedisServer(t, 0)
+	defer server.listener.Close()
+
+	locker := NewRedisLocker(server.client(), "janus:lock:")
+	token, err := locker.Lock("key", 1500*time.Millisecond)
+	require.NoError(t, err)
+	require.NoError(t, locker.Unlock(token))
+
+	server.Lock()
+	defer server.Unlock()
+	assert.Contains(t, server.commands, "set janus:lock:key "+token.Value+" nx px 1500", "the lock and its ttl are set at once")
+	assert.Contains(t, server.commands, "evalsha "+unlockScript.Hash()+" 1 janus:lock:key "+token.Value,
+		"the lock is released by the token")
+}
+
+func TestRedisLockerClockSkew(t *testing.T) {
+	// the redis clock is an hour ahead of the instance one, the locks expire after their ttl anyway
+	server := newRedisServer(t, time.Hour)
+	defer server.listener.Close()
+
+	locker := NewRedisLocker(server.client(), "janus:lock:")
+	token, err := locker.Lock("key", time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, token)
+	assert.True(t, token.Valid())
+	assert.True(t, token.TTL() < time.Minute, "the holder considers the lock lost before redis expires it")
+
+	other, err := locker.Lock("key", time.Minute)
+	require.NoError(t, err)
+	assert.Nil(t, other, "the lock is held")
+	require.NoError(t, locker.Extend(token, time.Minute))
+	require.NoError(t, locker.Unlock(token))
+}
+
+func TestRedisLockerUnavailable(t *testing.T) {
+	server := newRedisServer(t, 0)
+	client := server.client()
+	server.listener.Close()
+
+	locker := NewRedisLocker(client, "janus:lock:")
+	token, err := locker.Lock("key", time.Minute)
+	assert.Error(t, err)
+	assert.Nil(t, token)
+}
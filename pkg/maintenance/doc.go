@@ -0,0 +1,3 @@
+// Package maintenance provides the maintenance mode of the APIs, answering their requests with a
+// configured response while the upstreams are under maintenance.
+package maintenance
@@ -0,0 +1,96 @@
+package maintenance
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/hellofresh/janus/pkg/api"
+	"github.com/hellofresh/janus/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrUnderMaintenance is the response of the APIs under maintenance without a configured body
+	ErrUnderMaintenance = errors.New(http.StatusServiceUnavailable, "the API is under maintenance")
+	// ErrInvalidStatus is thrown when the maintenance response status is not a valid status code
+	ErrInvalidStatus = errors.New(http.StatusBadRequest, "maintenance status must be between 200 and 599")
+)
+
+// Modes holds the maintenance modes of the APIs by name. They are read on every request, so a
+// toggled maintenance mode takes effect without reloading the routes.
+type Modes struct {
+	sync.RWMutex
+	modes map[string]api.Maintenance
+}
+
+// NewModes creates a new instance of Modes
+func NewModes() *Modes {
+	return &Modes{modes: make(map[string]api.Maintenance)}
+}
+
+// Validate validates the maintenance mode response
+func Validate(mode api.Maintenance) error {
+	if mode.Status != 0 && (mode.Status < http.StatusOK || mode.Status > 599) {
+		return ErrInvalidStatus
+	}
+
+	return nil
+}
+
+// Set sets the maintenance mode of the API
+func (m *Modes) Set(name string, mode api.Maintenance) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.modes[name] = mode
+}
+
+// Get returns the maintenance mode of the API
+func (m *Modes) Get(name string) api.Maintenance {
+	m.RLock()
+	defer m.RUnlock()
+
+	return m.modes[name]
+}
+
+// Handler creates the middleware answering the requests of the API while it is under maintenance
+func (m *Modes) Handler(name string) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mode := m.Get(name)
+			if !mode.Enabled {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			log.WithFields(log.Fields{"api_name": name, "path": r.URL.Path}).Debug("API is under maintenance")
+			respond(w, mode)
+		})
+	}
+}
+
+func respond(w http.ResponseWriter, mode api.Maintenance) {
+	for name, value := range mode.Headers {
+		w.Header().Set(name, value)
+	}
+	if mode.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(mode.RetryAfter))
+	}
+
+	status := mode.Status
+	if status == 0 {
+		status = http.StatusServiceUnavailable
+	}
+
+	if mode.Body == "" {
+		errors.Handler(w, errors.New(status, ErrUnderMaintenance.Error()))
+		return
+	}
+
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	w.WriteHeader(status)
+	w.Write([]byte(mode.Body))
+}
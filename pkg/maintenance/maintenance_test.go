@@ -0,0 +1,53 @@
+package maintenance
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hellofresh/janus/pkg/api"
+	"github.com/hellofresh/janus/pkg/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func serve(modes *Modes) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	modes.Handler("example")(http.HandlerFunc(test.Ping)).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	return w
+}
+
+func TestHandler(t *testing.T) {
+	modes := NewModes()
+	assert.Equal(t, http.StatusOK, serve(modes).Code)
+
+	modes.Set("example", api.Maintenance{Enabled: true})
+	w := serve(modes)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, "toggling takes effect on the next request")
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Empty(t, w.Header().Get("Retry-After"))
+
+	modes.Set("example", api.Maintenance{
+		Enabled:    true,
+		Status:     http.StatusOK,
+		Headers:    map[string]string{"Content-Type": "text/html", "X-Maintenance": "true"},
+		RetryAfter: 600,
+		Body:       "<h1>Back soon</h1>",
+	})
+	w = serve(modes)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html", w.Header().Get("Content-Type"))
+	assert.Equal(t, "true", w.Header().Get("X-Maintenance"))
+	assert.Equal(t, "600", w.Header().Get("Retry-After"))
+	assert.Equal(t, "<h1>Back soon</h1>", w.Body.String())
+
+	modes.Set("example", api.Maintenance{})
+	assert.Equal(t, http.StatusOK, serve(modes).Code)
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate(api.Maintenance{Enabled: true}))
+	assert.NoError(t, Validate(api.Maintenance{Status: http.StatusServiceUnavailable}))
+	assert.Equal(t, ErrInvalidStatus, Validate(api.Maintenance{Status: 42}))
+	assert.Equal(t, ErrInvalidStatus, Validate(api.Maintenance{Status: 600}))
+}
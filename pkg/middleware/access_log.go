@@ -0,0 +1,345 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/felixge/httpsnoop"
+	log "github.com/sirupsen/logrus"
+)
+
+type accessLogKeyType int
+
+const accessLogKey accessLogKeyType = iota
+
+// Access log fields
+const (
+	AccessLogMethod    = "method"
+	AccessLogPath      = "path"
+	AccessLogRoute     = "route"
+	AccessLogStatus    = "status"
+	AccessLogBytes     = "bytes"
+	AccessLogLatency   = "latency"
+	AccessLogClientIP  = "client_ip"
+	AccessLogConsumer  = "consumer"
+	AccessLogRequestID = "request_id"
+	AccessLogTraceID   = "trace_id"
+	AccessLogVariant   = "variant"
+	// AccessLogShortCircuit is the reason of the plugin answering the request itself, empty for the
+	// proxied requests
+	AccessLogShortCircuit = "short_circuit"
+)
+
+// Access log formats
+const (
+	// AccessLogFormatJSON writes one JSON object with the configured fields per request
+	AccessLogFormatJSON = "json"
+	// AccessLogFormatCommon writes the NCSA Common Log Format lines followed by the route
+	AccessLogFormatCommon = "common"
+	// AccessLogFormatCombined writes the NCSA Combined Log Format lines followed by the route
+	AccessLogFormatCombined = "combined"
+)
+
+// clfTimeFormat is the time format of the Common and the Combined Log Format
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// AccessLogFields are all the supported access log fields, they are logged by default
+var AccessLogFields = []string{
+	AccessLogMethod,
+	AccessLogPath,
+	AccessLogRoute,
+	AccessLogStatus,
+	AccessLogBytes,
+	AccessLogLatency,
+	AccessLogClientIP,
+	AccessLogConsumer,
+	AccessLogRequestID,
+	AccessLogTraceID,
+	AccessLogVariant,
+	AccessLogShortCircuit,
+}
+
+// accessLogRecord holds the request details known only to the inner handlers, they are set on the
+// record shared through the request context by the access log and the slow log
+type accessLogRecord struct {
+	sync.Mutex
+	route string
+	// routeLogged is set when the matched route writes the entry itself, or disables it
+	routeLogged bool
+	consumer    string
+	traceID     string
+	variant     string
+	// shortCircuit is the reason of the plugin answering the request
+	shortCircuit string
+
+	upstream        string
+	upstreamLatency time.Duration
+	slowThreshold   time.Duration
+}
+
+// AccessLog is a middleware writing one entry per request once the response has been served. The
+// entries of the requests matching a route are written by the Route middleware, with the settings of
+// the route, the Handler writes the entries of the other requests.
+type AccessLog struct {
+	fields []string
+	format string
+	out    io.Writer
+	mu     *sync.Mutex
+}
+
+// NewAccessLog creates a new instance of AccessLog writing JSON objects of the given fields to out,
+// all the supported fields are written when none are given. The route is always written.
+func NewAccessLog(fields []string, out io.Writer) *AccessLog {
+	if len(fields) == 0 {
+		fields = AccessLogFields
+	}
+
+	supported := make(map[string]bool, len(AccessLogFields))
+	for _, field := range AccessLogFields {
+		supported[field] = true
+	}
+
+	known := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if !supported[field] {
+			log.WithField("field", field).Warn("Unknown access log field, skipping")
+			continue
+		}
+		known = append(known, field)
+	}
+
+	return &AccessLog{fields: known, format: AccessLogFormatJSON, out: out, mu: &sync.Mutex{}}
+}
+
+// IsAccessLogFormat tells if the format is a supported access log format
+func IsAccessLogFormat(format string) bool {
+	switch format {
+	case AccessLogFormatJSON, AccessLogFormatCommon, AccessLogFormatCombined:
+		return true
+	}
+	return false
+}
+
+// WithFormat returns a copy of the access log writing the entries in the given format to the same
+// output, the JSON format is kept for an empty or unknown format
+func (m *AccessLog) WithFormat(format string) *AccessLog {
+	if format == "" {
+		format = AccessLogFormatJSON
+	} else if !IsAccessLogFormat(format) {
+		log.WithField("format", format).Warn("Unknown access log format, using json")
+		format = AccessLogFormatJSON
+	}
+
+	copied := *m
+	copied.format = format
+	return &copied
+}
+
+// Handler is the middleware function, the requests handled by a Route middleware are skipped
+func (m *AccessLog) Handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		record, r := withRequestRecord(r)
+		mt := httpsnoop.CaptureMetrics(handler, w, r)
+
+		record.Lock()
+		defer record.Unlock()
+
+		if !record.routeLogged {
+			m.log(r, mt, record)
+		}
+	})
+}
+
+// Route is a middleware setting the matched route name and writing the entries of the route requests
+// with the settings of the access log. The requests of a route disabling the access log are served
+// without capturing the response.
+func (m *AccessLog) Route(name string, enabled bool) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			record, r := withRequestRecord(r)
+			record.Lock()
+			record.route = name
+			record.routeLogged = true
+			record.Unlock()
+
+			if !enabled {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			mt := httpsnoop.CaptureMetrics(handler, w, r)
+
+			record.Lock()
+			defer record.Unlock()
+			m.log(r, mt, record)
+		})
+	}
+}
+
+// log writes the entry of the request, the record is locked by the caller
+func (m *AccessLog) log(r *http.Request, mt httpsnoop.Metrics, record *accessLogRecord) {
+	switch m.format {
+	case AccessLogFormatCommon, AccessLogFormatCombined:
+		m.writeLine(m.line(r, mt, record))
+	default:
+		m.write(m.entry(r, mt, record))
+	}
+}
+
+func (m *AccessLog) entry(r *http.Request, mt httpsnoop.Metrics, record *accessLogRecord) map[string]interface{} {
+	entry := map[string]interface{}{
+		"time":         time.Now().Format(time.RFC3339Nano),
+		AccessLogRoute: record.route,
+	}
+	for _, field := range m.fields {
+		switch field {
+		case AccessLogMethod:
+			entry[field] = r.Method
+		case AccessLogPath:
+			entry[field] = r.URL.Path
+		case AccessLogStatus:
+			entry[field] = mt.Code
+		case AccessLogBytes:
+			entry[field] = mt.Written
+		case AccessLogLatency:
+			entry[field] = milliseconds(mt.Duration)
+		case AccessLogClientIP:
+			entry[field] = clientIP(r)
+		case AccessLogConsumer:
+			entry[field] = record.consumer
+		case AccessLogRequestID:
+			entry[field] = RequestIDFromContext(r.Context())
+		case AccessLogTraceID:
+			entry[field] = record.traceID
+		case AccessLogVariant:
+			entry[field] = record.variant
+		case AccessLogShortCircuit:
+			entry[field] = record.shortCircuit
+		}
+	}
+
+	return entry
+}
+
+// line returns the Common or the Combined Log Format line of the request, the consumer is the user and
+// the quoted route is appended to the line
+func (m *AccessLog) line(r *http.Request, mt httpsnoop.Metrics, record *accessLogRecord) string {
+	bytes := "-"
+	if mt.Written > 0 {
+		bytes = strconv.FormatInt(mt.Written, 10)
+	}
+
+	line := fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %s",
+		clfValue(clientIP(r)),
+		clfValue(record.consumer),
+		time.Now().Format(clfTimeFormat),
+		r.Method, r.RequestURI, r.Proto,
+		mt.Code,
+		bytes,
+	)
+	if m.format == AccessLogFormatCombined {
+		line += fmt.Sprintf(" %q %q", clfValue(r.Referer()), clfValue(r.UserAgent()))
+	}
+
+	return line + fmt.Sprintf(" %q\n", clfValue(record.route))
+}
+
+// clfValue returns the value of a field, "-" when it is empty
+func clfValue(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+func (m *AccessLog) write(entry map[string]interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := json.NewEncoder(m.out).Encode(entry); err != nil {
+		log.WithError(err).Error("Failed to write access log entry")
+	}
+}
+
+func (m *AccessLog) writeLine(line string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := io.WriteString(m.out, line); err != nil {
+		log.WithError(err).Error("Failed to write access log entry")
+	}
+}
+
+func clientIP(r *http.Request) string {
+	if ip := ClientIPFromRequest(r); ip != nil {
+		return ip.String()
+	}
+
+	return r.RemoteAddr
+}
+
+// NewAccessLogRoute is a middleware setting the matched route name of the access log entry
+func NewAccessLogRoute(name string) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			withAccessLogRecord(r.Context(), func(record *accessLogRecord) { record.route = name })
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
+
+// SetAccessLogConsumer sets the authenticated consumer of the access log entry
+func SetAccessLogConsumer(ctx context.Context, consumer string) {
+	withAccessLogRecord(ctx, func(record *accessLogRecord) { record.consumer = consumer })
+}
+
+// SetAccessLogTraceID sets the trace id of the access log entry
+func SetAccessLogTraceID(ctx context.Context, traceID string) {
+	withAccessLogRecord(ctx, func(record *accessLogRecord) { record.traceID = traceID })
+}
+
+// SetAccessLogVariant sets the experiment variant of the access log entry
+func SetAccessLogVariant(ctx context.Context, variant string) {
+	withAccessLogRecord(ctx, func(record *accessLogRecord) { record.variant = variant })
+}
+
+// SetAccessLogShortCircuit sets the reason of the plugin answering the request itself
+func SetAccessLogShortCircuit(ctx context.Context, reason string) {
+	withAccessLogRecord(ctx, func(record *accessLogRecord) { record.shortCircuit = reason })
+}
+
+// SetAccessLogUpstream sets the upstream target the request was proxied to
+func SetAccessLogUpstream(ctx context.Context, upstream string) {
+	withAccessLogRecord(ctx, func(record *accessLogRecord) { record.upstream = upstream })
+}
+
+// AddUpstreamLatency adds the time spent waiting for an upstream response, the attempts of a retried
+// request are summed up
+func AddUpstreamLatency(ctx context.Context, latency time.Duration) {
+	withAccessLogRecord(ctx, func(record *accessLogRecord) { record.upstreamLatency += latency })
+}
+
+// withRequestRecord returns the record shared through the request context, a new record is added to
+// the context of the returned request when there is none
+func withRequestRecord(r *http.Request) (*accessLogRecord, *http.Request) {
+	if record, ok := r.Context().Value(accessLogKey).(*accessLogRecord); ok {
+		return record, r
+	}
+
+	record := &accessLogRecord{}
+	return record, r.WithContext(context.WithValue(r.Context(), accessLogKey, record))
+}
+
+func withAccessLogRecord(ctx context.Context, set func(*accessLogRecord)) {
+	if record, ok := ctx.Value(accessLogKey).(*accessLogRecord); ok {
+		record.Lock()
+		set(record)
+		record.Unlock()
+	}
+}
@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessLog(t *testing.T) {
+	var out bytes.Buffer
+	mw := NewAccessLog(nil, &out)
+
+	handler := RequestID(mw.Handler(NewAccessLogRoute("example")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetAccessLogConsumer(r.Context(), "jane")
+		SetAccessLogTraceID(r.Context(), "trace")
+		SetAccessLogVariant(r.Context(), "new-ui")
+		SetAccessLogShortCircuit(r.Context(), "replayed")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("created"))
+	}))))
+
+	req := httptest.NewRequest(http.MethodPost, "/example/1?query=1", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set(DefaultCorrelationIDHeader, "request")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+
+	assert.Equal(t, "POST", entry[AccessLogMethod])
+	assert.Equal(t, "/example/1", entry[AccessLogPath])
+	assert.Equal(t, "example", entry[AccessLogRoute])
+	assert.Equal(t, float64(http.StatusCreated), entry[AccessLogStatus])
+	assert.Equal(t, float64(len("created")), entry[AccessLogBytes])
+	assert.Contains(t, entry, AccessLogLatency)
+	assert.Equal(t, "10.0.0.1", entry[AccessLogClientIP])
+	assert.Equal(t, "jane", entry[AccessLogConsumer])
+	assert.Equal(t, "request", entry[AccessLogRequestID])
+	assert.Equal(t, "trace", entry[AccessLogTraceID])
+	assert.Equal(t, "new-ui", entry[AccessLogVariant])
+	assert.Equal(t, "replayed", entry[AccessLogShortCircuit])
+	assert.Contains(t, entry, "time")
+}
+
+func TestAccessLogFields(t *testing.T) {
+	var out bytes.Buffer
+	mw := NewAccessLog([]string{AccessLogMethod, AccessLogStatus, "unknown"}, &out)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	mw.Handler(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+	assert.Equal(t, map[string]interface{}{
+		"time":          entry["time"],
+		AccessLogMethod: "GET",
+		AccessLogStatus: float64(http.StatusNotFound),
+		AccessLogRoute:  "",
+	}, entry, "the route is always written")
+}
+
+func TestAccessLogRoute(t *testing.T) {
+	var out bytes.Buffer
+	mw := NewAccessLog([]string{AccessLogStatus}, &out)
+
+	created := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	handler := mw.Handler(mw.Route("example", true)(created))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/example", nil))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 1, "the route entry is not written again by the global middleware")
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "example", entry[AccessLogRoute])
+	assert.Equal(t, float64(http.StatusCreated), entry[AccessLogStatus])
+
+	out.Reset()
+	handler = mw.Handler(mw.Route("internal", false)(created))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/internal", nil))
+	assert.Empty(t, out.String(), "the route disables the access log")
+
+	handler = mw.Route("example", true)(created)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/example", nil))
+	assert.NotEmpty(t, out.String(), "the route enables the access log without the global middleware")
+}
+
+func TestAccessLogCommonFormat(t *testing.T) {
+	var out bytes.Buffer
+	mw := NewAccessLog(nil, &out).WithFormat(AccessLogFormatCommon)
+
+	handler := mw.Route("example", true)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetAccessLogConsumer(r.Context(), "jane")
+		w.Write([]byte("recipes"))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/example/1?query=1", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Regexp(t, `^10\.0\.0\.1 - jane \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "GET /example/1\?query=1 HTTP/1\.1" 200 7 "example"\n$`, out.String())
+}
+
+func TestAccessLogCombinedFormat(t *testing.T) {
+	var out bytes.Buffer
+	mw := NewAccessLog(nil, &out).WithFormat(AccessLogFormatCombined)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("Referer", "https://example.com/")
+	req.Header.Set("User-Agent", "curl/7.54.0")
+	mw.Handler(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Regexp(t, `^10\.0\.0\.1 - - \[.+\] "GET / HTTP/1\.1" 404 19 "https://example.com/" "curl/7\.54\.0" "-"\n$`, out.String())
+}
+
+func TestAccessLogWithFormat(t *testing.T) {
+	mw := NewAccessLog(nil, &bytes.Buffer{})
+
+	assert.Equal(t, AccessLogFormatJSON, mw.format)
+	assert.Equal(t, AccessLogFormatCommon, mw.WithFormat(AccessLogFormatCommon).format)
+	assert.Equal(t, AccessLogFormatJSON, mw.WithFormat("unknown").format)
+	assert.True(t, mw.WithFormat(AccessLogFormatCommon).mu == mw.mu, "the copies share the output")
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/felixge/httpsnoop"
+	obs "github.com/hellofresh/janus/pkg/observability"
+	"go.opencensus.io/stats"
+)
+
+// APIMetrics is a middleware that records the request count, latency and in-flight requests of an API.
+// It must run after the API tags were added into the context by StatsTagger.
+type APIMetrics struct {
+	inFlight int64
+}
+
+// NewAPIMetrics creates a new instance of APIMetrics
+func NewAPIMetrics() *APIMetrics {
+	return &APIMetrics{}
+}
+
+// Handler is the middleware function
+func (m *APIMetrics) Handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		stats.Record(ctx, obs.MRequestsInFlight.M(atomic.AddInt64(&m.inFlight, 1)))
+
+		start := time.Now()
+		mt := httpsnoop.CaptureMetrics(handler, w, r)
+
+		stats.Record(ctx, obs.MRequestsInFlight.M(atomic.AddInt64(&m.inFlight, -1)))
+		obs.RecordRequest(ctx, r.Method, mt.Code, time.Since(start))
+	})
+}
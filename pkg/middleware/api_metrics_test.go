@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+
+	obs "github.com/hellofresh/janus/pkg/observability"
+	"github.com/hellofresh/janus/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+func TestAPIMetrics(t *testing.T) {
+	require.NoError(t, view.Register(obs.AllViews...))
+	defer view.Unregister(obs.AllViews...)
+
+	tagger := NewStatsTagger([]tag.Mutator{tag.Insert(obs.KeyAPIName, "example")})
+	mw := NewAPIMetrics()
+
+	for i := 0; i < 2; i++ {
+		w, err := test.Record(http.MethodGet, "/", nil, tagger.Handler(mw.Handler(http.HandlerFunc(test.Ping))))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	rows, err := view.RetrieveData("api_request_total")
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, int64(2), rows[0].Data.(*view.CountData).Value)
+	assert.Contains(t, rows[0].Tags, tag.Tag{Key: obs.KeyAPIName, Value: "example"})
+	assert.Contains(t, rows[0].Tags, tag.Tag{Key: obs.KeyStatusCode, Value: "200"})
+
+	rows, err = view.RetrieveData("api_request_latency")
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, int64(2), rows[0].Data.(*view.DistributionData).Count)
+
+	rows, err = view.RetrieveData("api_requests_in_flight")
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, float64(0), rows[0].Data.(*view.LastValueData).Value)
+}
@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+)
+
+type baggageKeyType int
+
+const baggageAllowListKey baggageKeyType = iota
+
+const (
+	// BaggageHeader is the W3C baggage header the baggage is forwarded upstream with, "{key}={value},..."
+	BaggageHeader = "Baggage"
+	// ConsumerBaggageKey is the baggage key of the consumer authenticated by the plugins
+	ConsumerBaggageKey = "consumer"
+)
+
+// Baggage forwards the allowed baggage of the requests to the upstreams, e.g. the user or tenant ID set by
+// the authentication plugins, so the upstreams can correlate the requests without checking the tokens again.
+// The baggage is kept in the tag map of the request context, the baggage header sent by the clients is
+// removed as they could set any value.
+type Baggage struct {
+	keys []tag.Key
+}
+
+// NewBaggage creates a new instance of Baggage forwarding the baggage keys of the allow-list
+func NewBaggage(keys []string) (*Baggage, error) {
+	b := &Baggage{keys: make([]tag.Key, 0, len(keys))}
+	for _, name := range keys {
+		key, err := tag.NewKey(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid baggage key %q", name)
+		}
+		b.keys = append(b.keys, key)
+	}
+
+	return b, nil
+}
+
+// Handler is the middleware function
+func (b *Baggage) Handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Del(BaggageHeader)
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), baggageAllowListKey, b.keys)))
+	})
+}
+
+// SetBaggage returns a copy of the context holding the baggage value, it is forwarded upstream when the key
+// is allowed. The values that can not be tag values, e.g. holding non ASCII characters, are skipped.
+func SetBaggage(ctx context.Context, key, value string) context.Context {
+	k, err := tag.NewKey(key)
+	if err != nil || !validTagValue(value) {
+		ContextLogger(ctx).WithField("key", key).Debug("Skipping the invalid baggage")
+		return ctx
+	}
+
+	tagged, err := tag.New(ctx, tag.Upsert(k, value))
+	if err != nil {
+		return ctx
+	}
+	return tagged
+}
+
+// BaggageFromContext returns the allowed baggage of the request in the order of the allow-list, the keys
+// without value are skipped
+func BaggageFromContext(ctx context.Context) []tag.Tag {
+	keys, ok := ctx.Value(baggageAllowListKey).([]tag.Key)
+	if !ok {
+		return nil
+	}
+
+	tags := tag.FromContext(ctx)
+	if tags == nil {
+		return nil
+	}
+
+	var baggage []tag.Tag
+	for _, key := range keys {
+		if value, ok := tags.Value(key); ok {
+			baggage = append(baggage, tag.Tag{Key: key, Value: value})
+		}
+	}
+	return baggage
+}
+
+// ForwardBaggage sets the allowed baggage of the request in the baggage header sent upstream, and adds it
+// to the span of the request as "baggage.{key}" attributes
+func ForwardBaggage(req *http.Request) {
+	baggage := BaggageFromContext(req.Context())
+	if len(baggage) == 0 {
+		return
+	}
+
+	span := trace.FromContext(req.Context())
+	members := make([]string, 0, len(baggage))
+	for _, t := range baggage {
+		// the values are percent encoded, a space is "%20" in the baggage header
+		members = append(members, t.Key.Name()+"="+strings.Replace(url.QueryEscape(t.Value), "+", "%20", -1))
+		if span != nil {
+			span.AddAttributes(trace.StringAttribute("baggage."+t.Key.Name(), t.Value))
+		}
+	}
+	req.Header.Set(BaggageHeader, strings.Join(members, ","))
+}
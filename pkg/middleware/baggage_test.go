@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/trace"
+)
+
+func TestBaggage(t *testing.T) {
+	b, err := NewBaggage([]string{"tenant-id", ConsumerBaggageKey})
+	require.NoError(t, err)
+
+	var upstream *http.Request
+	handler := b.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := WithConsumer(r.Context(), "jane")
+		ctx = SetBaggage(ctx, "tenant-id", "acme inc")
+		ctx = SetBaggage(ctx, "role", "admin")
+
+		upstream = r.WithContext(ctx)
+		ForwardBaggage(upstream)
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(BaggageHeader, "tenant-id=spoofed")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	assert.Equal(t, "tenant-id=acme%20inc,consumer=jane", upstream.Header.Get(BaggageHeader), "the keys not allowed are not forwarded")
+}
+
+func TestBaggageSpan(t *testing.T) {
+	b, err := NewBaggage([]string{ConsumerBaggageKey})
+	require.NoError(t, err)
+
+	recorder, reset := recordSpans()
+	defer reset()
+
+	handler := b.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := trace.StartSpan(WithConsumer(r.Context(), "jane"), "api.example")
+		defer span.End()
+		ForwardBaggage(r.WithContext(ctx))
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	require.Contains(t, recorder.spans, "api.example")
+	assert.Equal(t, "jane", recorder.spans["api.example"].Attributes["baggage.consumer"])
+}
+
+func TestBaggageDisabled(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r = r.WithContext(WithConsumer(context.Background(), "jane"))
+	ForwardBaggage(r)
+
+	assert.Empty(t, r.Header.Get(BaggageHeader), "the baggage is forwarded only with an allow-list")
+}
+
+func TestNewBaggageInvalidKey(t *testing.T) {
+	_, err := NewBaggage([]string{"tenant\nid"})
+	assert.Error(t, err)
+}
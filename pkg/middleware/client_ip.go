@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type clientIPKeyType int
+
+const clientIPKey clientIPKeyType = iota
+
+// ClientIP resolves the client address of the requests forwarded by the trusted proxies, e.g. the load
+// balancers in front of the gateway, from the X-Forwarded-For header
+type ClientIP struct {
+	trusted         []*net.IPNet
+	trustUnixSocket bool
+}
+
+// NewClientIP creates a new instance of ClientIP, the X-Forwarded-For header is ignored when there are no
+// trusted proxies. The connections without an IP address, i.e. the Unix domain socket ones, are trusted
+// proxies with trustUnixSocket.
+func NewClientIP(trusted []*net.IPNet, trustUnixSocket bool) *ClientIP {
+	return &ClientIP{trusted: trusted, trustUnixSocket: trustUnixSocket}
+}
+
+// Handler is the middleware function setting the client address of the request, so all the following
+// middlewares and plugins see the same one
+func (m *ClientIP) Handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip := m.Resolve(r); ip != nil {
+			r = r.WithContext(context.WithValue(r.Context(), clientIPKey, ip))
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// Resolve returns the client address of the request. The X-Forwarded-For addresses are walked from right
+// to left, as each proxy appends the address it received the request from, while they are trusted, so the
+// addresses set by the clients themselves are never used. It returns nil when the connection address is
+// not an IP address, e.g. the Unix domain socket ones, and no address of the header is used.
+func (m *ClientIP) Resolve(r *http.Request) net.IP {
+	ip := parseIP(r.RemoteAddr)
+	if ip == nil && !m.trustUnixSocket {
+		return nil
+	}
+	if ip != nil && !m.isTrusted(ip) {
+		return ip
+	}
+
+	hops := strings.Split(strings.Join(r.Header["X-Forwarded-For"], ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := parseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			// the malformed addresses are not trusted, the last trusted proxy is the client
+			break
+		}
+
+		ip = hop
+		if !m.isTrusted(ip) {
+			break
+		}
+	}
+
+	return ip
+}
+
+func (m *ClientIP) isTrusted(ip net.IP) bool {
+	for _, network := range m.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseIP parses the address with or without a port
+func parseIP(addr string) net.IP {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+
+	return net.ParseIP(addr)
+}
+
+// ClientIPFromRequest returns the client address of the request set by the ClientIP middleware, or the
+// connection address when it is not set. It returns nil when the address is not an IP address.
+func ClientIPFromRequest(r *http.Request) net.IP {
+	if ip, ok := r.Context().Value(clientIPKey).(net.IP); ok {
+		return ip
+	}
+
+	return parseIP(r.RemoteAddr)
+}
+
+// UnknownClient is the key of the requests without a client address, e.g. the Unix domain socket ones when
+// their X-Forwarded-For header is not trusted. They are counted together by the rate and quota plugins, so a
+// client on the socket can not bypass the limits.
+const UnknownClient = "unknown"
+
+// ClientKeyFromRequest returns the client address of the request the requests of a client are counted
+// with, or UnknownClient when the address is not an IP address
+func ClientKeyFromRequest(r *http.Request) string {
+	if ip := ClientIPFromRequest(r); ip != nil {
+		return ip.String()
+	}
+
+	return UnknownClient
+}
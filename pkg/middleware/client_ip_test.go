@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClientIP(t *testing.T, cidrs ...string) *ClientIP {
+	return newTestClientIPWithUnixSocket(t, false, cidrs...)
+}
+
+func newTestClientIPWithUnixSocket(t *testing.T, trustUnixSocket bool, cidrs ...string) *ClientIP {
+	trusted := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		require.NoError(t, err)
+		trusted = append(trusted, network)
+	}
+
+	return NewClientIP(trusted, trustUnixSocket)
+}
+
+func TestClientIPResolve(t *testing.T) {
+	m := newTestClientIP(t, "10.0.0.0/8", "2001:db8::/32")
+
+	tests := []struct {
+		name          string
+		remoteAddr    string
+		xForwardedFor []string
+		expected      string
+	}{
+		{name: "no header", remoteAddr: "203.0.113.7:4711", expected: "203.0.113.7"},
+		{name: "untrusted remote spoofing", remoteAddr: "203.0.113.7:4711", xForwardedFor: []string{"198.51.100.1"}, expected: "203.0.113.7"},
+		{name: "trusted proxy", remoteAddr: "10.0.0.1:4711", xForwardedFor: []string{"203.0.113.7"}, expected: "203.0.113.7"},
+		{name: "client spoofing through trusted proxy", remoteAddr: "10.0.0.1:4711", xForwardedFor: []string{"198.51.100.1, 203.0.113.7"}, expected: "203.0.113.7"},
+		{name: "multi-hop trusted chain", remoteAddr: "10.0.0.1:4711", xForwardedFor: []string{"198.51.100.1, 203.0.113.7, 10.1.0.1", "10.2.0.1"}, expected: "203.0.113.7"},
+		{name: "all trusted", remoteAddr: "10.0.0.1:4711", xForwardedFor: []string{"10.3.0.1, 10.2.0.1"}, expected: "10.3.0.1"},
+		{name: "malformed hop", remoteAddr: "10.0.0.1:4711", xForwardedFor: []string{"203.0.113.7, unknown, 10.2.0.1"}, expected: "10.2.0.1"},
+		{name: "trusted proxy without header", remoteAddr: "10.0.0.1:4711", expected: "10.0.0.1"},
+		{name: "ipv6", remoteAddr: "[2001:db8::1]:4711", xForwardedFor: []string{"[2001:db8:1::7]:80, 2001:db8::2"}, expected: "2001:db8:1::7"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = test.remoteAddr
+			req.Header["X-Forwarded-For"] = test.xForwardedFor
+
+			assert.Equal(t, test.expected, m.Resolve(req).String())
+		})
+	}
+}
+
+func TestClientIPWithoutTrustedProxies(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:4711"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+
+	assert.Equal(t, "10.0.0.1", newTestClientIP(t).Resolve(req).String(), "the header is ignored")
+
+	req.RemoteAddr = "@"
+	assert.Nil(t, newTestClientIP(t).Resolve(req))
+}
+
+func TestClientIPUnixSocket(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "@"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1, 203.0.113.7, 10.0.0.1")
+
+	assert.Nil(t, newTestClientIP(t, "10.0.0.0/8").Resolve(req), "the header of the Unix domain socket is not trusted by default")
+	m := newTestClientIPWithUnixSocket(t, true, "10.0.0.0/8")
+	assert.Equal(t, "203.0.113.7", m.Resolve(req).String())
+
+	req.Header.Del("X-Forwarded-For")
+	assert.Nil(t, m.Resolve(req))
+}
+
+func TestClientIPHandler(t *testing.T) {
+	var ip net.IP
+	handler := newTestClientIP(t, "10.0.0.0/8").Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip = ClientIPFromRequest(r)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:4711"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "203.0.113.7", ip.String())
+
+	assert.Equal(t, "10.0.0.1", ClientIPFromRequest(req).String(), "the connection address is used without the middleware")
+}
+
+func TestClientKeyFromRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "203.0.113.7:4711"
+	assert.Equal(t, "203.0.113.7", ClientKeyFromRequest(req))
+
+	req.RemoteAddr = "@"
+	assert.Equal(t, UnknownClient, ClientKeyFromRequest(req), "the clients without address share a key")
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import "context"
+
+type consumerKeyType int
+
+const consumerKey consumerKeyType = iota
+
+// WithConsumer returns a copy of the context holding the authenticated consumer, e.g. the basic
+// auth user name, so the plugins following the authentication can apply per consumer limits.
+// The consumer is also set on the access log entry and in the baggage under the "consumer" key.
+func WithConsumer(ctx context.Context, consumer string) context.Context {
+	SetAccessLogConsumer(ctx, consumer)
+	ctx = SetBaggage(ctx, ConsumerBaggageKey, consumer)
+	return context.WithValue(ctx, consumerKey, consumer)
+}
+
+// ConsumerFromContext returns the authenticated consumer, it returns an empty string when the request
+// is not authenticated
+func ConsumerFromContext(ctx context.Context) string {
+	consumer, _ := ctx.Value(consumerKey).(string)
+	return consumer
+}
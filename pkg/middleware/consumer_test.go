@@ -0,0 +1,15 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsumerFromContext(t *testing.T) {
+	assert.Empty(t, ConsumerFromContext(context.Background()))
+
+	ctx := WithConsumer(context.Background(), "jane")
+	assert.Equal(t, "jane", ConsumerFromContext(ctx))
+}
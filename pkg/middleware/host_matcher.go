@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"fmt"
+	"net"
 	"net/http"
 	"regexp"
 	"strings"
@@ -10,11 +11,20 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// plainHostWeight is added to the weight of plain hosts, it is longer than any valid host name
+// so plain hosts always win over wildcard hosts
+const plainHostWeight = 1 << 10
+
 // HostMatcher is a middleware that matches any host with the given list of hosts.
 // It also supports regex host like *.example.com
 type HostMatcher struct {
 	plainHosts    map[string]bool
-	wildcardHosts []*regexp.Regexp
+	wildcardHosts []wildcardHost
+}
+
+type wildcardHost struct {
+	regex  *regexp.Regexp
+	weight int
 }
 
 // NewHostMatcher creates a new instance of HostMatcher
@@ -28,35 +38,55 @@ func NewHostMatcher(hosts []string) *HostMatcher {
 func (h *HostMatcher) Handler(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		log.WithField("path", r.URL.Path).Debug("Starting host matcher middleware")
-		host := r.Host
 
-		if _, ok := h.plainHosts[host]; ok {
-			log.WithField("host", host).Debug("Plain host matched")
+		if _, ok := h.Match(r.Host); ok {
 			handler.ServeHTTP(w, r)
 			return
 		}
 
-		for _, hostRegex := range h.wildcardHosts {
-			if hostRegex.MatchString(host) {
-				log.WithField("host", host).Debug("Wildcard host matched")
-				handler.ServeHTTP(w, r)
-				return
-			}
-		}
-
 		err := errors.ErrRouteNotFound
 		log.WithError(err).Error("The host didn't match any of the provided hosts")
 		errors.Handler(w, err)
 	})
 }
 
+// Match checks if the host matches any of the given hosts, the port is ignored. The returned weight
+// is higher for more specific hosts: plain hosts win over wildcard hosts and longer wildcard hosts
+// win over shorter ones, e.g. *.api.example.com wins over *.example.com.
+func (h *HostMatcher) Match(host string) (int, bool) {
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+
+	if _, ok := h.plainHosts[host]; ok {
+		log.WithField("host", host).Debug("Plain host matched")
+		return plainHostWeight + len(host), true
+	}
+
+	weight, matched := 0, false
+	for _, wildcard := range h.wildcardHosts {
+		if wildcard.weight > weight && wildcard.regex.MatchString(host) {
+			weight, matched = wildcard.weight, true
+		}
+	}
+
+	if matched {
+		log.WithField("host", host).Debug("Wildcard host matched")
+	}
+
+	return weight, matched
+}
+
 func (h *HostMatcher) prepareIndexes(hosts []string) {
 	if len(hosts) > 0 {
 		for _, host := range hosts {
 			if strings.Contains(host, "*") {
 				regexStr := strings.Replace(host, ".", "\\.", -1)
 				regexStr = strings.Replace(regexStr, "*", ".+", -1)
-				h.wildcardHosts = append(h.wildcardHosts, regexp.MustCompile(fmt.Sprintf("^%s$", regexStr)))
+				h.wildcardHosts = append(h.wildcardHosts, wildcardHost{
+					regex:  regexp.MustCompile(fmt.Sprintf("^%s$", regexStr)),
+					weight: len(host),
+				})
 			} else {
 				h.plainHosts[host] = true
 			}
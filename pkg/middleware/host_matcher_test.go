@@ -83,3 +83,22 @@ func TestNotMatchRegexHeader(t *testing.T) {
 	assert.Equal(t, http.StatusNotFound, w.Code)
 	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
 }
+
+func TestHostMatcherWeight(t *testing.T) {
+	matcher := NewHostMatcher([]string{"api.example.com", "*.example.com", "*.api.example.com"})
+
+	plainWeight, ok := matcher.Match("api.example.com:8080")
+	assert.True(t, ok)
+
+	wildcardWeight, ok := matcher.Match("www.example.com")
+	assert.True(t, ok)
+
+	longerWildcardWeight, ok := matcher.Match("v1.api.example.com")
+	assert.True(t, ok)
+
+	assert.True(t, plainWeight > longerWildcardWeight)
+	assert.True(t, longerWildcardWeight > wildcardWeight)
+
+	_, ok = matcher.Match("example.org")
+	assert.False(t, ok)
+}
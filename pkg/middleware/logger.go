@@ -27,12 +27,17 @@ func (m *Logger) Handler(handler http.Handler) http.Handler {
 			"host":        r.Host,
 			"request":     r.RequestURI,
 			"remote-addr": r.RemoteAddr,
+			"client-ip":   clientIP(r),
 			"referer":     r.Referer(),
 			"user-agent":  r.UserAgent(),
 		}
 
+		record, r := withRequestRecord(r)
 		m := httpsnoop.CaptureMetrics(handler, w, r)
 
+		record.Lock()
+		fields["route"] = record.route
+		record.Unlock()
 		fields["code"] = m.Code
 		fields["duration"] = int(m.Duration / time.Millisecond)
 		fields["duration-fmt"] = m.Duration.String()
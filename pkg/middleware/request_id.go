@@ -4,29 +4,91 @@ import (
 	"context"
 	"net/http"
 
+	obs "github.com/hellofresh/janus/pkg/observability"
+	"github.com/pkg/errors"
 	"github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+	"go.opencensus.io/tag"
 )
 
 type reqIDKeyType int
 
 const (
-	reqIDKey        reqIDKeyType = iota
-	requestIDHeader              = "X-Request-ID"
+	reqIDKey reqIDKeyType = iota
+	baggageKeyKey
 )
 
-// RequestID middleware
+const (
+	// DefaultCorrelationIDHeader is the header holding the correlation ID when none is set
+	DefaultCorrelationIDHeader = "X-Request-ID"
+	// DefaultCorrelationIDBaggageKey is the key of the correlation ID in the baggage when none is set
+	DefaultCorrelationIDBaggageKey = "correlation_id"
+)
+
+var defaultCorrelationID, _ = NewCorrelationID(DefaultCorrelationIDHeader, DefaultCorrelationIDBaggageKey, true)
+
+// RequestID middleware seeds the correlation ID of the requests with the default header and baggage key
 func RequestID(handler http.Handler) http.Handler {
+	return defaultCorrelationID.Handler(handler)
+}
+
+// CorrelationID seeds the single correlation ID of a request, its request ID. The ID is read from the
+// request header, or from the trace ID of the incoming trace context, and generated when there is none.
+// It is forwarded upstream and sent back to the client in the header, kept in the tag map of the request
+// context, the OpenCensus baggage, and added to the log lines and the spans of the request.
+type CorrelationID struct {
+	header     string
+	baggageKey tag.Key
+	fromTrace  bool
+}
+
+// NewCorrelationID creates a new instance of CorrelationID, the trace ID of the incoming trace context is
+// the correlation ID of the requests without the header when fromTrace is set. The defaults are used for
+// the empty header and baggage key.
+func NewCorrelationID(header, baggageKey string, fromTrace bool) (*CorrelationID, error) {
+	if header == "" {
+		header = DefaultCorrelationIDHeader
+	}
+	if baggageKey == "" {
+		baggageKey = DefaultCorrelationIDBaggageKey
+	}
+
+	key, err := tag.NewKey(baggageKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid correlation ID baggage key %q", baggageKey)
+	}
+
+	return &CorrelationID{header: http.CanonicalHeaderKey(header), baggageKey: key, fromTrace: fromTrace}, nil
+}
+
+// Header returns the header holding the correlation ID
+func (c *CorrelationID) Header() string {
+	return c.header
+}
+
+// Handler is the middleware function
+func (c *CorrelationID) Handler(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		requestID := r.Header.Get(requestIDHeader)
+		requestID := r.Header.Get(c.header)
+		if requestID == "" && c.fromTrace {
+			if sc, ok := obs.Propagation.SpanContextFromRequest(r); ok {
+				requestID = sc.TraceID.String()
+			}
+		}
 		if requestID == "" {
 			requestID = uuid.NewV4().String()
 		}
 
-		r.Header.Set(requestIDHeader, requestID)
-		w.Header().Set(requestIDHeader, requestID)
+		r.Header.Set(c.header, requestID)
+		w.Header().Set(c.header, requestID)
 
 		ctx := r.Context()
 		ctx = context.WithValue(ctx, reqIDKey, requestID)
+		ctx = context.WithValue(ctx, baggageKeyKey, c.baggageKey)
+		// an invalid tag value would fail the tags added later on, e.g. the API name of the metrics
+		if validTagValue(requestID) {
+			ctx, _ = tag.New(ctx, tag.Upsert(c.baggageKey, requestID))
+		}
 
 		handler.ServeHTTP(w, r.WithContext(ctx))
 	})
@@ -44,3 +106,37 @@ func RequestIDFromContext(ctx context.Context) string {
 
 	return ""
 }
+
+// CorrelationBaggage returns the baggage key and the correlation ID of the request, ok is false when the
+// request IDs are disabled
+func CorrelationBaggage(ctx context.Context) (key string, value string, ok bool) {
+	baggageKey, ok := ctx.Value(baggageKeyKey).(tag.Key)
+	if !ok {
+		return "", "", false
+	}
+
+	return baggageKey.Name(), RequestIDFromContext(ctx), true
+}
+
+// validTagValue tells if the value can be a tag value, the tags hold up to 255 printable ASCII characters
+func validTagValue(v string) bool {
+	if len(v) > 255 {
+		return false
+	}
+	for _, c := range v {
+		if c < ' ' || c > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ContextLogger returns the log entry of the request, with its correlation ID
+func ContextLogger(ctx context.Context) *log.Entry {
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		return log.WithField("request-id", requestID)
+	}
+
+	return log.NewEntry(log.StandardLogger())
+}
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/tag"
+)
+
+// serveCorrelation serves the request and returns the correlation ID seen by the handler
+func serveCorrelation(t *testing.T, c *CorrelationID, r *http.Request) (*httptest.ResponseRecorder, context.Context) {
+	var ctx context.Context
+	w := httptest.NewRecorder()
+	c.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx = req.Context()
+		assert.Equal(t, RequestIDFromContext(ctx), req.Header.Get(c.Header()), "the correlation ID is forwarded upstream")
+	})).ServeHTTP(w, r)
+
+	return w, ctx
+}
+
+func TestCorrelationID(t *testing.T) {
+	c, err := NewCorrelationID("x-correlation-id", "correlation", true)
+	require.NoError(t, err)
+	assert.Equal(t, "X-Correlation-Id", c.Header())
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Correlation-ID", "b4f8a2c0")
+	w, ctx := serveCorrelation(t, c, r)
+	assert.Equal(t, "b4f8a2c0", RequestIDFromContext(ctx))
+	assert.Equal(t, "b4f8a2c0", w.Header().Get("X-Correlation-ID"))
+
+	key, value, ok := CorrelationBaggage(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "correlation", key)
+	assert.Equal(t, "b4f8a2c0", value)
+
+	baggage, _ := tag.NewKey("correlation")
+	tagged, _ := tag.FromContext(ctx).Value(baggage)
+	assert.Equal(t, "b4f8a2c0", tagged)
+	assert.Equal(t, "b4f8a2c0", ContextLogger(ctx).Data["request-id"])
+
+	_, ctx = serveCorrelation(t, c, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Len(t, RequestIDFromContext(ctx), 36, "a correlation ID is generated")
+}
+
+func TestCorrelationIDFromTrace(t *testing.T) {
+	traced := func() *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-B3-TraceId", "463ac35c9f6413ad48485a3953bb6124")
+		r.Header.Set("X-B3-SpanId", "a2fb4a1d1a96d312")
+		return r
+	}
+
+	c, err := NewCorrelationID("", "", true)
+	require.NoError(t, err)
+	assert.Equal(t, http.CanonicalHeaderKey(DefaultCorrelationIDHeader), c.Header())
+	_, ctx := serveCorrelation(t, c, traced())
+	assert.Equal(t, "463ac35c9f6413ad48485a3953bb6124", RequestIDFromContext(ctx))
+
+	r := traced()
+	r.Header.Set(DefaultCorrelationIDHeader, "b4f8a2c0")
+	_, ctx = serveCorrelation(t, c, r)
+	assert.Equal(t, "b4f8a2c0", RequestIDFromContext(ctx), "the header wins over the trace ID")
+
+	c, err = NewCorrelationID("", "", false)
+	require.NoError(t, err)
+	_, ctx = serveCorrelation(t, c, traced())
+	assert.NotEqual(t, "463ac35c9f6413ad48485a3953bb6124", RequestIDFromContext(ctx))
+}
+
+func TestCorrelationIDInvalidTagValue(t *testing.T) {
+	c, err := NewCorrelationID("", "", false)
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(DefaultCorrelationIDHeader, strings.Repeat("a", 300))
+	_, ctx := serveCorrelation(t, c, r)
+	assert.Len(t, RequestIDFromContext(ctx), 300)
+
+	// the tags added afterwards are not failed by the correlation ID
+	_, err = tag.New(ctx, tag.Upsert(mustKey(t, "api"), "users"))
+	assert.NoError(t, err)
+
+	_, err = NewCorrelationID("", "invalid\x00key", false)
+	assert.Error(t, err)
+}
+
+func mustKey(t *testing.T, name string) tag.Key {
+	key, err := tag.NewKey(name)
+	require.NoError(t, err)
+	return key
+}
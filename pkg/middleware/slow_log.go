@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/felixge/httpsnoop"
+	log "github.com/sirupsen/logrus"
+)
+
+// SlowLog is a middleware logging a warning for the requests served slower than a threshold, the
+// threshold of the matched API takes precedence over the global one
+type SlowLog struct {
+	threshold time.Duration
+	logger    log.FieldLogger
+}
+
+// NewSlowLog creates a new instance of SlowLog, the requests are not logged with a zero threshold
+// unless their API sets one
+func NewSlowLog(threshold time.Duration) *SlowLog {
+	return &SlowLog{threshold: threshold, logger: log.StandardLogger()}
+}
+
+// Handler is the middleware function
+func (m *SlowLog) Handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		record, r := withRequestRecord(r)
+		mt := httpsnoop.CaptureMetrics(handler, w, r)
+
+		record.Lock()
+		defer record.Unlock()
+
+		threshold := m.threshold
+		if record.slowThreshold > 0 {
+			threshold = record.slowThreshold
+		}
+		if threshold <= 0 || mt.Duration < threshold {
+			return
+		}
+
+		fields := log.Fields{
+			"method":     r.Method,
+			"path":       r.URL.Path,
+			"route":      record.route,
+			"status":     mt.Code,
+			"latency":    milliseconds(mt.Duration),
+			"threshold":  milliseconds(threshold),
+			"upstream":   record.upstream,
+			"request_id": RequestIDFromContext(r.Context()),
+			"trace_id":   record.traceID,
+		}
+		// the upstream time is known only for the proxied requests, the rest is spent in the gateway
+		if record.upstreamLatency > 0 {
+			fields["upstream_latency"] = milliseconds(record.upstreamLatency)
+			fields["gateway_latency"] = milliseconds(mt.Duration - record.upstreamLatency)
+		}
+
+		m.logger.WithFields(fields).Warn("Slow request")
+	})
+}
+
+// NewSlowLogThreshold is a middleware setting the slow log threshold of the matched API
+func NewSlowLogThreshold(threshold time.Duration) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			withAccessLogRecord(r.Context(), func(record *accessLogRecord) { record.slowThreshold = threshold })
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveSlowLog(threshold time.Duration, handler http.Handler) *bytes.Buffer {
+	var out bytes.Buffer
+	mw := NewSlowLog(threshold)
+	mw.logger = &log.Logger{Out: &out, Formatter: &log.JSONFormatter{}, Level: log.WarnLevel}
+
+	mw.Handler(NewAccessLogRoute("example")(handler)).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/example/1", nil))
+
+	return &out
+}
+
+func TestSlowLog(t *testing.T) {
+	out := serveSlowLog(10*time.Millisecond, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetAccessLogUpstream(r.Context(), "http://upstream")
+		SetAccessLogTraceID(r.Context(), "trace")
+		AddUpstreamLatency(r.Context(), 10*time.Millisecond)
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+
+	assert.Equal(t, "Slow request", entry["msg"])
+	assert.Equal(t, "GET", entry["method"])
+	assert.Equal(t, "example", entry["route"])
+	assert.Equal(t, float64(http.StatusAccepted), entry["status"])
+	assert.Equal(t, "http://upstream", entry["upstream"])
+	assert.Equal(t, "trace", entry["trace_id"])
+	assert.Equal(t, float64(10), entry["upstream_latency"])
+	assert.True(t, entry["latency"].(float64) >= 20)
+	assert.InDelta(t, entry["latency"].(float64)-10, entry["gateway_latency"], 0.001)
+}
+
+func TestSlowLogBelowThreshold(t *testing.T) {
+	assert.Empty(t, serveSlowLog(time.Second, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).String())
+	assert.Empty(t, serveSlowLog(0, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond)
+	})).String(), "the log is disabled without a threshold")
+}
+
+func TestSlowLogAPIThreshold(t *testing.T) {
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { time.Sleep(5 * time.Millisecond) })
+
+	out := serveSlowLog(0, NewSlowLogThreshold(time.Millisecond)(slow))
+	assert.Contains(t, out.String(), "Slow request", "the API threshold applies without a global one")
+
+	out = serveSlowLog(time.Millisecond, NewSlowLogThreshold(time.Second)(slow))
+	assert.Empty(t, out.String(), "the API threshold overrides the global one")
+}
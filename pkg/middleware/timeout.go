@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/felixge/httpsnoop"
+	"github.com/hellofresh/janus/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrRequestTimeout is used when a request is not served within the request timeout of its API
+var ErrRequestTimeout = errors.New(http.StatusGatewayTimeout, "request timeout")
+
+// NewTimeout is a middleware bounding the time serving the requests of an API. The plugins and the
+// upstream calls, retries included, share the deadline of the request context, so the upstream call
+// is cancelled once it is exceeded. The requests not answered by the deadline are answered with
+// 504 Gateway Timeout. The WebSocket connections are not bounded, they outlive the upgrade request.
+func NewTimeout(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			written := false
+			hooked := httpsnoop.Wrap(w, httpsnoop.Hooks{
+				WriteHeader: func(next httpsnoop.WriteHeaderFunc) httpsnoop.WriteHeaderFunc {
+					return func(code int) {
+						written = true
+						next(code)
+					}
+				},
+				Write: func(next httpsnoop.WriteFunc) httpsnoop.WriteFunc {
+					return func(b []byte) (int, error) {
+						written = true
+						return next(b)
+					}
+				},
+				ReadFrom: func(next httpsnoop.ReadFromFunc) httpsnoop.ReadFromFunc {
+					return func(src io.Reader) (int64, error) {
+						written = true
+						return next(src)
+					}
+				},
+			})
+
+			handler.ServeHTTP(hooked, r.WithContext(ctx))
+
+			if ctx.Err() != context.DeadlineExceeded {
+				return
+			}
+
+			log.WithFields(log.Fields{
+				"path":       r.URL.Path,
+				"timeout":    timeout.String(),
+				"request_id": RequestIDFromContext(r.Context()),
+			}).Warn("Request timeout exceeded")
+
+			// the response is already sent when the handler answered the timeout itself, e.g. the proxy
+			if !written {
+				errors.Handler(w, ErrRequestTimeout)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func serveTimeout(r *http.Request, handler http.HandlerFunc) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	NewTimeout(20*time.Millisecond)(handler).ServeHTTP(w, r)
+
+	return w
+}
+
+func TestTimeout(t *testing.T) {
+	w := serveTimeout(httptest.NewRequest(http.MethodGet, "/", nil), func(w http.ResponseWriter, r *http.Request) {
+		_, ok := r.Context().Deadline()
+		assert.True(t, ok, "the request context has a deadline")
+		w.WriteHeader(http.StatusAccepted)
+	})
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	w = serveTimeout(httptest.NewRequest(http.MethodGet, "/", nil), func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Contains(t, w.Body.String(), "request timeout")
+
+	w = serveTimeout(httptest.NewRequest(http.MethodGet, "/", nil), func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, "the response written by the handler is kept")
+}
+
+func TestTimeoutWebSocket(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Upgrade", "websocket")
+
+	w := serveTimeout(r, func(w http.ResponseWriter, r *http.Request) {
+		_, ok := r.Context().Deadline()
+		assert.False(t, ok, "the WebSocket connections are not bounded")
+		w.WriteHeader(http.StatusSwitchingProtocols)
+	})
+	assert.Equal(t, http.StatusSwitchingProtocols, w.Code)
+}
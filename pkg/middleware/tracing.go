@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/felixge/httpsnoop"
+	"github.com/hellofresh/janus/pkg/sampling"
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+)
+
+type pluginCallKey struct{}
+
+type spanTagsKey struct{}
+
+// pluginCall tracks the time the request spent in the next handlers of a plugin
+type pluginCall struct {
+	next       bool
+	downstream time.Duration
+	// shortCircuit is the reason of the plugin answering the request itself
+	shortCircuit string
+}
+
+// NewAPISpan starts the span of the requests of the API, the spans of its plugins and the span of the
+// upstream call are nested in it. The span is sampled by the sampling rate of the API when it is set.
+func NewAPISpan(apiName string) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, span := trace.StartSpan(r.Context(), "api."+apiName, trace.WithSampler(sampling.FromContext(r.Context())))
+			defer span.End()
+
+			span.AddAttributes(trace.StringAttribute("api.name", apiName))
+			AddSpanTags(ctx, span)
+			if key, correlationID, ok := CorrelationBaggage(ctx); ok {
+				span.AddAttributes(trace.StringAttribute(key, correlationID))
+			}
+			handler.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// NewSpanTags adds the tags to the spans of the requests of the API, the spans started by the next handlers
+// add them with AddSpanTags
+func NewSpanTags(tags map[string]string) func(http.Handler) http.Handler {
+	attributes := make([]trace.Attribute, 0, len(tags))
+	for key, value := range tags {
+		attributes = append(attributes, trace.StringAttribute(key, value))
+	}
+
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), spanTagsKey{}, attributes)))
+		})
+	}
+}
+
+// AddSpanTags adds the tags of the API of the request to the span
+func AddSpanTags(ctx context.Context, span *trace.Span) {
+	if attributes, ok := ctx.Value(spanTagsKey{}).([]trace.Attribute); ok && span != nil {
+		span.AddAttributes(attributes...)
+	}
+}
+
+// PluginSpan wraps the middleware of the plugin in a span named after it. The span holds the time the
+// plugin spent on the request without the time of the next handlers, and it is tagged as an error
+// when the plugin answered the request itself with an error status, e.g. an authentication failure.
+func PluginSpan(name string, mw func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		handler := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			call, ok := r.Context().Value(pluginCallKey{}).(*pluginCall)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			call.next = true
+			start := time.Now()
+			next.ServeHTTP(w, r)
+			call.downstream += time.Since(start)
+		}))
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, span := trace.StartSpan(r.Context(), "plugin."+name)
+			defer span.End()
+
+			call := &pluginCall{}
+			ctx = context.WithValue(ctx, pluginCallKey{}, call)
+			m := httpsnoop.CaptureMetrics(handler, w, r.WithContext(ctx))
+
+			span.AddAttributes(
+				trace.StringAttribute("plugin.name", name),
+				trace.Int64Attribute("plugin.self_time_us", int64((m.Duration-call.downstream)/time.Microsecond)),
+			)
+			if call.shortCircuit != "" {
+				span.AddAttributes(trace.StringAttribute("plugin.short_circuit", call.shortCircuit))
+			}
+			if !call.next && m.Code >= http.StatusBadRequest {
+				span.AddAttributes(
+					trace.BoolAttribute("error", true),
+					trace.StringAttribute("error.message", http.StatusText(m.Code)),
+					trace.Int64Attribute(ochttp.StatusCodeAttribute, int64(m.Code)),
+				)
+				span.SetStatus(ochttp.TraceStatus(m.Code, http.StatusText(m.Code)))
+			}
+		})
+	}
+}
+
+// SetPluginShortCircuit tags the span of the plugin with the reason of the plugin answering the request
+// itself, e.g. with a stored response
+func SetPluginShortCircuit(ctx context.Context, reason string) {
+	if call, ok := ctx.Value(pluginCallKey{}).(*pluginCall); ok {
+		call.shortCircuit = reason
+	}
+}
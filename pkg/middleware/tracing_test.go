@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/trace"
+)
+
+// spanRecorder keeps the ended spans by name
+type spanRecorder struct {
+	sync.Mutex
+	spans map[string]*trace.SpanData
+}
+
+func (e *spanRecorder) ExportSpan(s *trace.SpanData) {
+	e.Lock()
+	defer e.Unlock()
+	e.spans[s.Name] = s
+}
+
+func recordSpans() (*spanRecorder, func()) {
+	e := &spanRecorder{spans: make(map[string]*trace.SpanData)}
+	trace.RegisterExporter(e)
+	trace.ApplyConfig(trace.Config{DefaultSampler: trace.AlwaysSample()})
+
+	return e, func() {
+		trace.UnregisterExporter(e)
+		trace.ApplyConfig(trace.Config{DefaultSampler: trace.ProbabilitySampler(1e-4)})
+	}
+}
+
+// sleeping is a plugin middleware sleeping before the next handlers
+func sleeping(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(d)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestPluginSpans(t *testing.T) {
+	e, stop := recordSpans()
+	defer stop()
+
+	var upstream *trace.Span
+	handler := NewAPISpan("users")(
+		PluginSpan("slow", sleeping(20*time.Millisecond))(
+			PluginSpan("fast", sleeping(0))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				upstream = trace.FromContext(r.Context())
+				time.Sleep(30 * time.Millisecond)
+				w.WriteHeader(http.StatusOK)
+			}))))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	api, slow, fast := e.spans["api.users"], e.spans["plugin.slow"], e.spans["plugin.fast"]
+	require.NotNil(t, api)
+	require.NotNil(t, slow)
+	require.NotNil(t, fast)
+
+	// the spans nest, the upstream call is a child of the last plugin
+	assert.Equal(t, api.SpanID, slow.ParentSpanID)
+	assert.Equal(t, slow.SpanID, fast.ParentSpanID)
+	assert.Equal(t, fast.SpanID, upstream.SpanContext().SpanID)
+	assert.Equal(t, api.TraceID, fast.TraceID)
+
+	assert.Equal(t, "slow", slow.Attributes["plugin.name"])
+	assert.True(t, slow.Attributes["plugin.self_time_us"].(int64) >= int64(20*time.Millisecond/time.Microsecond))
+	assert.True(t, fast.Attributes["plugin.self_time_us"].(int64) < int64(20*time.Millisecond/time.Microsecond), "the time of the next handlers is not the plugin one")
+	assert.NotContains(t, slow.Attributes, "error")
+}
+
+func TestPluginSpanErrors(t *testing.T) {
+	e, stop := recordSpans()
+	defer stop()
+
+	reject := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		})
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	PluginSpan("auth", reject)(next).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	PluginSpan("cors", sleeping(0))(next).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	auth := e.spans["plugin.auth"]
+	require.NotNil(t, auth)
+	assert.Equal(t, true, auth.Attributes["error"])
+	assert.Equal(t, int64(http.StatusUnauthorized), auth.Attributes["http.status_code"])
+	assert.Equal(t, int32(trace.StatusCodeUnauthenticated), auth.Status.Code)
+
+	cors := e.spans["plugin.cors"]
+	require.NotNil(t, cors)
+	assert.NotContains(t, cors.Attributes, "error", "the errors of the next handlers are not the plugin ones")
+}
+
+func TestPluginSpanShortCircuit(t *testing.T) {
+	e, stop := recordSpans()
+	defer stop()
+
+	replay := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			SetPluginShortCircuit(r.Context(), "replayed")
+			w.WriteHeader(http.StatusOK)
+		})
+	}
+
+	PluginSpan("idempotency", replay)(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	span := e.spans["plugin.idempotency"]
+	require.NotNil(t, span)
+	assert.Equal(t, "replayed", span.Attributes["plugin.short_circuit"])
+	assert.NotContains(t, span.Attributes, "error")
+}
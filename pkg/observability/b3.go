@@ -0,0 +1,68 @@
+package observability
+
+import (
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"go.opencensus.io/plugin/ochttp/propagation/b3"
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+)
+
+// B3SingleHeader is the header of the B3 single header format, "{trace id}-{span id}-{sampled}-{parent span id}"
+const B3SingleHeader = "b3"
+
+// B3Format propagates the trace context with the B3 headers. The trace context is read from the single b3 header,
+// or from the X-B3-* headers when the request has none, and sent in the single header when SingleHeader is set,
+// in the X-B3-* headers otherwise.
+type B3Format struct {
+	SingleHeader bool
+	multi        b3.HTTPFormat
+}
+
+var _ propagation.HTTPFormat = (*B3Format)(nil)
+
+// SpanContextFromRequest extracts the B3 span context of the request
+func (f *B3Format) SpanContextFromRequest(r *http.Request) (trace.SpanContext, bool) {
+	header := r.Header.Get(B3SingleHeader)
+	if header == "" {
+		return f.multi.SpanContextFromRequest(r)
+	}
+
+	// the parent span ID is skipped, as by the X-B3-* headers, the span is a child of the client span
+	parts := strings.Split(header, "-")
+	if len(parts) < 2 {
+		return trace.SpanContext{}, false
+	}
+	traceID, ok := b3.ParseTraceID(parts[0])
+	if !ok {
+		return trace.SpanContext{}, false
+	}
+	spanID, ok := b3.ParseSpanID(parts[1])
+	if !ok {
+		return trace.SpanContext{}, false
+	}
+
+	// "d" is the debug flag, which implies the trace is sampled
+	var sampled trace.TraceOptions
+	if len(parts) > 2 && (parts[2] == "1" || parts[2] == "d") {
+		sampled = trace.TraceOptions(1)
+	}
+
+	return trace.SpanContext{TraceID: traceID, SpanID: spanID, TraceOptions: sampled}, true
+}
+
+// SpanContextToRequest sets the B3 headers of the span context on the request
+func (f *B3Format) SpanContextToRequest(sc trace.SpanContext, r *http.Request) {
+	if !f.SingleHeader {
+		f.multi.SpanContextToRequest(sc, r)
+		return
+	}
+
+	sampled := "0"
+	if sc.IsSampled() {
+		sampled = "1"
+	}
+	r.Header.Set(B3SingleHeader, hex.EncodeToString(sc.TraceID[:])+"-"+hex.EncodeToString(sc.SpanID[:])+"-"+sampled)
+}
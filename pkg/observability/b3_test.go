@@ -0,0 +1,102 @@
+package observability
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opencensus.io/trace"
+)
+
+func TestB3FormatSpanContextFromRequest(t *testing.T) {
+	tests := []struct {
+		scenario string
+		headers  map[string]string
+		ok       bool
+		sampled  bool
+	}{
+		{
+			scenario: "single header",
+			headers:  map[string]string{"b3": "4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1-05e3ac9a4f6e3b90"},
+			ok:       true,
+			sampled:  true,
+		},
+		{
+			scenario: "single header without sampling decision",
+			headers:  map[string]string{"b3": "4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7"},
+			ok:       true,
+		},
+		{
+			scenario: "single header with debug flag",
+			headers:  map[string]string{"b3": "4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-d"},
+			ok:       true,
+			sampled:  true,
+		},
+		{
+			scenario: "multiple headers",
+			headers: map[string]string{
+				"X-B3-TraceId": "4bf92f3577b34da6a3ce929d0e0e4736",
+				"X-B3-SpanId":  "00f067aa0ba902b7",
+				"X-B3-Sampled": "1",
+			},
+			ok:      true,
+			sampled: true,
+		},
+		{
+			scenario: "single header is preferred",
+			headers: map[string]string{
+				"b3":           "4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0",
+				"X-B3-TraceId": "0000000000000000000000000000000a",
+				"X-B3-SpanId":  "000000000000000b",
+				"X-B3-Sampled": "1",
+			},
+			ok: true,
+		},
+		{
+			scenario: "sampling decision only",
+			headers:  map[string]string{"b3": "0"},
+		},
+		{
+			scenario: "invalid trace id",
+			headers:  map[string]string{"b3": "xyz-00f067aa0ba902b7-1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scenario, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+
+			sc, ok := (&B3Format{}).SpanContextFromRequest(req)
+			assert.Equal(t, tt.ok, ok)
+			if tt.ok {
+				assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+				assert.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+				assert.Equal(t, tt.sampled, sc.IsSampled())
+			}
+		})
+	}
+}
+
+func TestB3FormatSpanContextToRequest(t *testing.T) {
+	sc := trace.SpanContext{
+		TraceID:      trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:       trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		TraceOptions: trace.TraceOptions(1),
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	(&B3Format{SingleHeader: true}).SpanContextToRequest(sc, req)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1", req.Header.Get("b3"))
+	assert.Empty(t, req.Header.Get("X-B3-TraceId"))
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	(&B3Format{}).SpanContextToRequest(sc, req)
+	assert.Empty(t, req.Header.Get("b3"))
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", req.Header.Get("X-B3-TraceId"))
+	assert.Equal(t, "00f067aa0ba902b7", req.Header.Get("X-B3-SpanId"))
+	assert.Equal(t, "1", req.Header.Get("X-B3-Sampled"))
+}
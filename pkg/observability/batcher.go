@@ -0,0 +1,110 @@
+package observability
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"go.opencensus.io/trace"
+)
+
+const (
+	spanBatchSize     = 512
+	spanQueueSize     = 16
+	spanFlushInterval = 5 * time.Second
+)
+
+// spanBatcher sends the spans of a tracing exporter in batches asynchronously, the batches are dropped when
+// the backend can not keep up
+type spanBatcher struct {
+	sync.Mutex
+	exporter string
+	post     func([]*trace.SpanData)
+	batch    []*trace.SpanData
+	queue    chan []*trace.SpanData
+	done     chan struct{}
+}
+
+func newSpanBatcher(exporter string, post func([]*trace.SpanData)) *spanBatcher {
+	b := &spanBatcher{
+		exporter: exporter,
+		post:     post,
+		queue:    make(chan []*trace.SpanData, spanQueueSize),
+		done:     make(chan struct{}),
+	}
+	go b.send()
+
+	return b
+}
+
+// ExportSpan adds the span to the batch sent to the backend
+func (b *spanBatcher) ExportSpan(span *trace.SpanData) {
+	b.Lock()
+	b.batch = append(b.batch, span)
+	if len(b.batch) < spanBatchSize {
+		b.Unlock()
+		return
+	}
+	batch := b.takeBatch()
+	b.Unlock()
+
+	select {
+	case b.queue <- batch:
+	default:
+		log.WithField("exporter", b.exporter).WithField("spans", len(batch)).Debug("Tracing exporter queue is full, dropping the spans")
+		RecordSpansDropped(b.exporter, len(batch))
+	}
+}
+
+// Flush sends the spans not sent yet, it is called before exiting
+func (b *spanBatcher) Flush() {
+	for {
+		select {
+		case batch := <-b.queue:
+			b.postBatch(batch)
+		default:
+			b.Lock()
+			batch := b.takeBatch()
+			b.Unlock()
+			b.postBatch(batch)
+			return
+		}
+	}
+}
+
+// Close flushes the spans and stops sending the batches
+func (b *spanBatcher) Close() {
+	close(b.done)
+	b.Flush()
+}
+
+func (b *spanBatcher) takeBatch() []*trace.SpanData {
+	batch := b.batch
+	b.batch = nil
+	return batch
+}
+
+func (b *spanBatcher) send() {
+	ticker := time.NewTicker(spanFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.done:
+			return
+		case batch := <-b.queue:
+			b.postBatch(batch)
+		case <-ticker.C:
+			b.Lock()
+			batch := b.takeBatch()
+			b.Unlock()
+			b.postBatch(batch)
+		}
+	}
+}
+
+func (b *spanBatcher) postBatch(batch []*trace.SpanData) {
+	if len(batch) > 0 {
+		b.post(batch)
+	}
+}
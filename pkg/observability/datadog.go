@@ -0,0 +1,255 @@
+package observability
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"go.opencensus.io/trace"
+)
+
+const (
+	// DefaultDatadogAgentAddr is the host:port of the trace API of a local Datadog agent
+	DefaultDatadogAgentAddr = "localhost:8126"
+
+	datadogTimeout = 10 * time.Second
+	// datadogPriorityKey is the metric holding the sampling priority of the spans, the spans sampled by
+	// Janus are kept by the agent
+	datadogPriorityKey = "_sampling_priority_v1"
+	// datadogPriorityUserKeep is the sampling priority of the spans kept by the tracer
+	datadogPriorityUserKeep = 2
+
+	// the span tags and types of the v0.3 trace API, the names of the ext package of dd-trace-go
+	datadogEnvTag       = "env"
+	datadogVersionTag   = "version"
+	datadogErrorMsgTag  = "error.msg"
+	datadogSpanTypeWeb  = "web"
+	datadogSpanTypeHTTP = "http"
+)
+
+// DatadogOptions are the options of the Datadog exporter, the DD_AGENT_HOST, DD_TRACE_AGENT_PORT, DD_SERVICE,
+// DD_ENV, DD_VERSION and DD_TAGS environment variables of the Datadog tracers override them
+type DatadogOptions struct {
+	// AgentAddr is the host:port of the agent, DefaultDatadogAgentAddr when it is empty
+	AgentAddr string
+	Service   string
+	Env       string
+	Version   string
+	// Tags are added to all the spans
+	Tags map[string]string
+	// APIServices reports the spans of the APIs as the services named after the APIs, rather than as
+	// the resources of the Janus service
+	APIServices bool
+}
+
+// DatadogExporter reports the OpenCensus spans to the trace API of a Datadog agent, in the span format of
+// dd-trace-go. The spans are encoded here rather than with dd-trace-go, whose agent transport is internal to
+// its tracer. The OpenCensus trace IDs are truncated to their lower 64 bits, the Datadog trace IDs. The span
+// of an API is the resource named after the API, or the service named after it with APIServices, the other
+// spans are the resources named after their span. The spans are sent in batches asynchronously and dropped
+// when the agent can not keep up.
+type DatadogExporter struct {
+	*spanBatcher
+	endpoint    string
+	service     string
+	apiServices bool
+	meta        map[string]string
+	client      *http.Client
+}
+
+// NewDatadogExporter creates a new instance of DatadogExporter
+func NewDatadogExporter(opts DatadogOptions) (*DatadogExporter, error) {
+	opts = datadogOptionsFromEnv(opts)
+	if _, _, err := net.SplitHostPort(opts.AgentAddr); err != nil {
+		return nil, fmt.Errorf("invalid Datadog agent address %q, it must be host:port", opts.AgentAddr)
+	}
+
+	meta := make(map[string]string, len(opts.Tags)+2)
+	for key, value := range opts.Tags {
+		meta[key] = value
+	}
+	if opts.Env != "" {
+		meta[datadogEnvTag] = opts.Env
+	}
+	if opts.Version != "" {
+		meta[datadogVersionTag] = opts.Version
+	}
+
+	e := &DatadogExporter{
+		endpoint:    "http://" + opts.AgentAddr + "/v0.3/traces",
+		service:     opts.Service,
+		apiServices: opts.APIServices,
+		meta:        meta,
+		client:      &http.Client{Timeout: datadogTimeout},
+	}
+	e.spanBatcher = newSpanBatcher(Datadog, e.post)
+
+	return e, nil
+}
+
+// datadogOptionsFromEnv overrides the options with the environment variables of the Datadog tracers
+func datadogOptionsFromEnv(opts DatadogOptions) DatadogOptions {
+	if opts.AgentAddr == "" {
+		opts.AgentAddr = DefaultDatadogAgentAddr
+	}
+	host, port, err := net.SplitHostPort(opts.AgentAddr)
+	if err == nil {
+		if v := os.Getenv("DD_AGENT_HOST"); v != "" {
+			host = v
+		}
+		if v := os.Getenv("DD_TRACE_AGENT_PORT"); v != "" {
+			port = v
+		}
+		opts.AgentAddr = net.JoinHostPort(host, port)
+	}
+
+	if v := os.Getenv("DD_SERVICE"); v != "" {
+		opts.Service = v
+	}
+	if v := os.Getenv("DD_ENV"); v != "" {
+		opts.Env = v
+	}
+	if v := os.Getenv("DD_VERSION"); v != "" {
+		opts.Version = v
+	}
+
+	// DD_TAGS holds the "key:value" tags separated by commas
+	if v := os.Getenv("DD_TAGS"); v != "" {
+		tags := make(map[string]string, len(opts.Tags))
+		for key, value := range opts.Tags {
+			tags[key] = value
+		}
+		for _, tag := range strings.Split(v, ",") {
+			kv := strings.SplitN(strings.TrimSpace(tag), ":", 2)
+			if kv[0] == "" {
+				continue
+			}
+			if len(kv) == 1 {
+				kv = append(kv, "")
+			}
+			tags[kv[0]] = strings.TrimSpace(kv[1])
+		}
+		opts.Tags = tags
+	}
+
+	return opts
+}
+
+func (e *DatadogExporter) post(batch []*trace.SpanData) {
+	// the agent receives the spans grouped by trace
+	var traces [][]datadogSpan
+	index := make(map[uint64]int)
+	for _, sd := range batch {
+		span := e.newSpan(sd)
+		i, ok := index[span.TraceID]
+		if !ok {
+			i = len(traces)
+			index[span.TraceID] = i
+			traces = append(traces, nil)
+		}
+		traces[i] = append(traces[i], span)
+	}
+
+	body, err := json.Marshal(traces)
+	if err != nil {
+		log.WithError(err).Warn("Could not encode the Datadog spans")
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPut, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		log.WithError(err).Warn("Could not create the Datadog agent request")
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Datadog-Meta-Lang", "go")
+	req.Header.Set("Datadog-Meta-Lang-Version", strings.TrimPrefix(runtime.Version(), "go"))
+	req.Header.Set("X-Datadog-Trace-Count", strconv.Itoa(len(traces)))
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		log.WithError(err).WithField("spans", len(batch)).Warn("Could not send the spans to the Datadog agent")
+		RecordExportError(Datadog)
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		log.WithField("code", resp.StatusCode).WithField("spans", len(batch)).Warn("The Datadog agent rejected the spans")
+		RecordExportError(Datadog)
+	}
+}
+
+// datadogSpan is the span of the agent trace API
+type datadogSpan struct {
+	TraceID  uint64             `json:"trace_id"`
+	SpanID   uint64             `json:"span_id"`
+	ParentID uint64             `json:"parent_id"`
+	Name     string             `json:"name"`
+	Resource string             `json:"resource"`
+	Service  string             `json:"service"`
+	Type     string             `json:"type,omitempty"`
+	Start    int64              `json:"start"`
+	Duration int64              `json:"duration"`
+	Error    int32              `json:"error"`
+	Meta     map[string]string  `json:"meta,omitempty"`
+	Metrics  map[string]float64 `json:"metrics,omitempty"`
+}
+
+func (e *DatadogExporter) newSpan(sd *trace.SpanData) datadogSpan {
+	span := datadogSpan{
+		TraceID:  binary.BigEndian.Uint64(sd.TraceID[8:]),
+		SpanID:   binary.BigEndian.Uint64(sd.SpanID[:]),
+		ParentID: binary.BigEndian.Uint64(sd.ParentSpanID[:]),
+		Name:     sd.Name,
+		Resource: sd.Name,
+		Service:  e.service,
+		Start:    sd.StartTime.UnixNano(),
+		Duration: sd.EndTime.Sub(sd.StartTime).Nanoseconds(),
+		Meta:     make(map[string]string, len(e.meta)+len(sd.Attributes)),
+		Metrics:  map[string]float64{datadogPriorityKey: datadogPriorityUserKeep},
+	}
+
+	switch sd.SpanKind {
+	case trace.SpanKindServer:
+		span.Type = datadogSpanTypeWeb
+	case trace.SpanKindClient:
+		span.Type = datadogSpanTypeHTTP
+	}
+
+	for key, value := range e.meta {
+		span.Meta[key] = value
+	}
+	for key, value := range sd.Attributes {
+		switch value := value.(type) {
+		case float64:
+			span.Metrics[key] = value
+		default:
+			span.Meta[key] = fmt.Sprint(value)
+		}
+	}
+
+	// the span of the API, see middleware.NewAPISpan
+	if api, ok := sd.Attributes["api.name"].(string); ok {
+		span.Resource = api
+		if e.apiServices {
+			span.Service = api
+		}
+	}
+
+	if sd.Code != trace.StatusCodeOK {
+		span.Error = 1
+		span.Meta[datadogErrorMsgTag] = sd.Message
+	}
+
+	return span
+}
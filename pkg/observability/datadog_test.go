@@ -0,0 +1,118 @@
+package observability
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/trace"
+)
+
+func TestDatadogExporter(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests <- r
+		bodies <- body
+	}))
+	defer agent.Close()
+
+	agentURL, err := url.Parse(agent.URL)
+	require.NoError(t, err)
+
+	exporter, err := NewDatadogExporter(DatadogOptions{
+		AgentAddr:   agentURL.Host,
+		Service:     "janus",
+		Env:         "staging",
+		APIServices: true,
+	})
+	require.NoError(t, err)
+
+	start := time.Unix(1500000000, 0)
+	traceID := trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0, 0, 0, 0, 0, 0, 0, 0x2a}
+	exporter.ExportSpan(&trace.SpanData{
+		SpanContext:  trace.SpanContext{TraceID: traceID, SpanID: trace.SpanID{0, 0, 0, 0, 0, 0, 0, 0x02}},
+		ParentSpanID: trace.SpanID{0, 0, 0, 0, 0, 0, 0, 0x01},
+		Name:         "api.example",
+		StartTime:    start,
+		EndTime:      start.Add(time.Second),
+		Attributes:   map[string]interface{}{"api.name": "example", "http.status_code": int64(502)},
+		Status:       trace.Status{Code: trace.StatusCodeUnavailable, Message: "bad gateway"},
+	})
+	exporter.ExportSpan(&trace.SpanData{
+		SpanContext:  trace.SpanContext{TraceID: traceID, SpanID: trace.SpanID{0, 0, 0, 0, 0, 0, 0, 0x03}},
+		ParentSpanID: trace.SpanID{0, 0, 0, 0, 0, 0, 0, 0x02},
+		SpanKind:     trace.SpanKindClient,
+		Name:         "/example",
+		StartTime:    start,
+		EndTime:      start.Add(time.Millisecond),
+	})
+	exporter.Close()
+
+	req := <-requests
+	assert.Equal(t, http.MethodPut, req.Method)
+	assert.Equal(t, "/v0.3/traces", req.URL.Path)
+	assert.Equal(t, "1", req.Header.Get("X-Datadog-Trace-Count"))
+
+	assert.JSONEq(t, `[[
+		{
+			"trace_id": 42,
+			"span_id": 2,
+			"parent_id": 1,
+			"name": "api.example",
+			"resource": "example",
+			"service": "example",
+			"start": 1500000000000000000,
+			"duration": 1000000000,
+			"error": 1,
+			"meta": {"api.name": "example", "http.status_code": "502", "env": "staging", "error.msg": "bad gateway"},
+			"metrics": {"_sampling_priority_v1": 2}
+		},
+		{
+			"trace_id": 42,
+			"span_id": 3,
+			"parent_id": 2,
+			"name": "/example",
+			"resource": "/example",
+			"service": "janus",
+			"type": "http",
+			"start": 1500000000000000000,
+			"duration": 1000000,
+			"error": 0,
+			"meta": {"env": "staging"},
+			"metrics": {"_sampling_priority_v1": 2}
+		}
+	]]`, string(<-bodies))
+}
+
+func TestDatadogOptionsFromEnv(t *testing.T) {
+	for name, value := range map[string]string{
+		"DD_AGENT_HOST":       "datadog-agent",
+		"DD_TRACE_AGENT_PORT": "8127",
+		"DD_SERVICE":          "gateway",
+		"DD_ENV":              "prod",
+		"DD_TAGS":             "team:platform, region:eu-west-1",
+	} {
+		os.Setenv(name, value)
+		defer os.Unsetenv(name)
+	}
+
+	opts := datadogOptionsFromEnv(DatadogOptions{Service: "janus", Env: "staging", Version: "1.2.0"})
+	assert.Equal(t, "datadog-agent:8127", opts.AgentAddr)
+	assert.Equal(t, "gateway", opts.Service)
+	assert.Equal(t, "prod", opts.Env)
+	assert.Equal(t, "1.2.0", opts.Version)
+	assert.Equal(t, map[string]string{"team": "platform", "region": "eu-west-1"}, opts.Tags)
+}
+
+func TestDatadogExporterInvalidAgentAddr(t *testing.T) {
+	_, err := NewDatadogExporter(DatadogOptions{AgentAddr: "localhost"})
+	assert.Error(t, err)
+}
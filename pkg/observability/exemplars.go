@@ -0,0 +1,184 @@
+package observability
+
+import (
+	"math"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/golang/protobuf/proto"
+	promclient "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"go.opencensus.io/exemplar"
+	"go.opencensus.io/exporter/prometheus"
+	"go.opencensus.io/stats/view"
+)
+
+// exemplarViews are the views whose histograms are served with the exemplars of their buckets
+var exemplarViews = []string{"api_request_latency"}
+
+// PrometheusHandler serves the metrics of the prometheus exporter on the admin API
+var PrometheusHandler http.Handler
+
+// NewPrometheusExporter creates the prometheus exporter of the views and the handler serving its metrics.
+// With exemplars the handler serves the OpenMetrics format to the scrapers accepting it, the buckets of
+// the request latency histogram link to the trace of the last sampled request they counted.
+func NewPrometheusExporter(exemplars bool) (*prometheus.Exporter, http.Handler, error) {
+	registry := promclient.NewRegistry()
+	exporter, err := prometheus.NewExporter(prometheus.Options{Registry: registry})
+	if err != nil {
+		return nil, nil, err
+	}
+	if !exemplars {
+		return exporter, exporter, nil
+	}
+
+	return exporter, exemplarHandler{exemplarGatherer{registry}}, nil
+}
+
+// exemplarHandler serves the gathered metrics in the OpenMetrics format to the scrapers accepting it, and in the
+// negotiated prometheus format to the others. The promhttp handler of the locked client_golang only serves the latter.
+type exemplarHandler struct {
+	gatherer promclient.Gatherer
+}
+
+func (h exemplarHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	families, err := h.gatherer.Gather()
+	if err != nil {
+		http.Error(w, "An error has occurred while gathering the metrics: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	format := expfmt.NegotiateIncludingOpenMetrics(r.Header)
+	w.Header().Set("Content-Type", string(format))
+	encoder := expfmt.NewEncoder(w, format)
+	for _, family := range families {
+		if err := encoder.Encode(family); err != nil {
+			return
+		}
+	}
+	if closer, ok := encoder.(expfmt.Closer); ok {
+		closer.Close()
+	}
+}
+
+// exemplarGatherer gathers the metrics of the exporter and attaches the exemplars recorded by the views,
+// the exporter itself drops them
+type exemplarGatherer struct {
+	promclient.Gatherer
+}
+
+// Gather gathers the metrics with the exemplars, only the samples recorded in a sampled span have one
+func (g exemplarGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.Gatherer.Gather()
+	if err != nil {
+		return families, err
+	}
+
+	for _, family := range families {
+		for _, name := range exemplarViews {
+			if family.GetName() == name && family.GetType() == dto.MetricType_HISTOGRAM {
+				attachExemplars(family, name)
+			}
+		}
+	}
+
+	return families, nil
+}
+
+// attachExemplars sets the exemplars of the view rows on the buckets of their series
+func attachExemplars(family *dto.MetricFamily, viewName string) {
+	v := view.Find(viewName)
+	if v == nil {
+		return
+	}
+	rows, err := view.RetrieveData(viewName)
+	if err != nil {
+		return
+	}
+
+	series := make(map[string]*dto.Histogram, len(family.GetMetric()))
+	for _, metric := range family.GetMetric() {
+		values := make(map[string]string, len(metric.GetLabel()))
+		for _, label := range metric.GetLabel() {
+			values[label.GetName()] = label.GetValue()
+		}
+
+		key := make([]string, 0, len(v.TagKeys))
+		for _, k := range v.TagKeys {
+			key = append(key, values[sanitizeLabel(k.Name())])
+		}
+		series[strings.Join(key, "\xff")] = metric.GetHistogram()
+	}
+
+	for _, row := range rows {
+		data, ok := row.Data.(*view.DistributionData)
+		if !ok {
+			continue
+		}
+
+		tags := make(map[string]string, len(row.Tags))
+		for _, t := range row.Tags {
+			tags[t.Key.Name()] = t.Value
+		}
+		key := make([]string, 0, len(v.TagKeys))
+		for _, k := range v.TagKeys {
+			key = append(key, tags[k.Name()])
+		}
+
+		histogram, ok := series[strings.Join(key, "\xff")]
+		if !ok {
+			continue
+		}
+		setBucketExemplars(histogram, v.Aggregation.Buckets, data)
+	}
+}
+
+// setBucketExemplars sets the exemplars of the distribution on the buckets of the same upper bound, the
+// exemplar of the overflow bucket goes to the +Inf bucket
+func setBucketExemplars(histogram *dto.Histogram, bounds []float64, data *view.DistributionData) {
+	buckets := make(map[float64]*dto.Bucket, len(histogram.GetBucket()))
+	for _, bucket := range histogram.GetBucket() {
+		buckets[bucket.GetUpperBound()] = bucket
+	}
+
+	for i, e := range data.ExemplarsPerBucket {
+		if e == nil {
+			continue
+		}
+		traceID, ok := e.Attachments[exemplar.KeyTraceID]
+		if !ok {
+			continue
+		}
+
+		var bucket *dto.Bucket
+		if i < len(bounds) {
+			bucket = buckets[bounds[i]]
+		} else {
+			bucket = &dto.Bucket{
+				UpperBound:      proto.Float64(math.Inf(1)),
+				CumulativeCount: proto.Uint64(histogram.GetSampleCount()),
+			}
+			histogram.Bucket = append(histogram.Bucket, bucket)
+		}
+		if bucket == nil {
+			continue
+		}
+
+		bucket.Exemplar = &dto.Exemplar{
+			Label: []*dto.LabelPair{{Name: proto.String(exemplar.KeyTraceID), Value: proto.String(traceID)}},
+			Value: proto.Float64(e.Value),
+		}
+	}
+}
+
+// sanitizeLabel returns the prometheus label name of the tag key, as the exporter names them
+func sanitizeLabel(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,97 @@
+package observability
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+)
+
+const openMetrics = "application/openmetrics-text; version=0.0.1"
+
+func scrape(t *testing.T, handler http.Handler, accept string) string {
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", accept)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	body, err := ioutil.ReadAll(w.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+// bucketLines returns the bucket lines of the request latency histogram of the API
+func bucketLines(metrics, api string) []string {
+	var lines []string
+	for _, line := range strings.Split(metrics, "\n") {
+		if strings.HasPrefix(line, "api_request_latency_bucket{") && strings.Contains(line, `api="`+api+`"`) {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func TestPrometheusExemplars(t *testing.T) {
+	require.NoError(t, view.Register(AllViews...))
+	defer view.Unregister(AllViews...)
+
+	record := func(api string, sampler trace.Sampler) string {
+		ctx, err := tag.New(context.Background(), tag.Insert(KeyAPIName, api))
+		require.NoError(t, err)
+		ctx, span := trace.StartSpan(ctx, "api."+api, trace.WithSampler(sampler))
+		defer span.End()
+
+		RecordRequest(ctx, http.MethodGet, http.StatusOK, 3*time.Millisecond)
+		return span.SpanContext().TraceID.String()
+	}
+	traceID := record("sampled", trace.AlwaysSample())
+	record("unsampled", trace.NeverSample())
+
+	newHandler := func(exemplars bool) http.Handler {
+		exporter, handler, err := NewPrometheusExporter(exemplars)
+		require.NoError(t, err)
+
+		// the views are exported on the reporting period, the test exports them right away
+		for _, name := range []string{"api_request_latency", "api_request_total"} {
+			rows, err := view.RetrieveData(name)
+			require.NoError(t, err)
+			exporter.ExportView(&view.Data{View: view.Find(name), Rows: rows})
+		}
+		return handler
+	}
+
+	handler := newHandler(true)
+	metrics := scrape(t, handler, openMetrics)
+	assert.Contains(t, metrics, "# EOF")
+
+	var exemplars []string
+	for _, line := range bucketLines(metrics, "sampled") {
+		if i := strings.Index(line, " # "); i >= 0 {
+			exemplars = append(exemplars, line[i+3:])
+		}
+	}
+	require.Len(t, exemplars, 1, "the bucket counting the sampled request links to its trace")
+	assert.Equal(t, `{trace_id="`+traceID+`"} 3.0`, exemplars[0])
+
+	require.NotEmpty(t, bucketLines(metrics, "unsampled"))
+	for _, line := range bucketLines(metrics, "unsampled") {
+		assert.NotContains(t, line, "trace_id", "the requests without a sampled trace have no exemplar")
+	}
+
+	metrics = scrape(t, handler, "text/plain")
+	assert.NotContains(t, metrics, "trace_id", "the exemplars are only served in the OpenMetrics format")
+
+	metrics = scrape(t, newHandler(false), openMetrics)
+	require.NotEmpty(t, bucketLines(metrics, "sampled"))
+	assert.NotContains(t, metrics, "trace_id", "the exemplars are disabled by default")
+}
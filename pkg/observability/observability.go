@@ -1,6 +1,10 @@
 package observability
 
 import (
+	"context"
+	"strconv"
+	"time"
+
 	"go.opencensus.io/exporter/prometheus"
 	"go.opencensus.io/plugin/ochttp"
 	"go.opencensus.io/stats"
@@ -19,11 +23,18 @@ const (
 	AzureMonitor = "azure_monitor"
 	Datadog      = "datadog"
 	Jaeger       = "jaeger"
+	OTel         = "otel"
 	Prometheus   = "prometheus"
 	Stackdriver  = "stackdriver"
 	Zipkin       = "zipkin"
 )
 
+// DefaultZipkinEndpoint is the spans endpoint of a local Zipkin collector
+const DefaultZipkinEndpoint = "http://localhost:9411/api/v2/spans"
+
+// DefaultPrometheusPath is the admin API path serving the prometheus metrics by default
+const DefaultPrometheusPath = "/metrics"
+
 // PrometheusExporter is the prometheus exporter containing HTTP handler for "/metrics"
 var PrometheusExporter *prometheus.Exporter
 
@@ -32,6 +43,35 @@ var (
 	KeyListenPath, _             = tag.NewKey("path")
 	KeyUpstreamPath, _           = tag.NewKey("upstream_path")
 	KeyJWTValidationErrorType, _ = tag.NewKey("error")
+	// KeyAPIName is the API definition name, requests are labeled by it rather than by their
+	// raw path to keep the cardinality low
+	KeyAPIName, _         = tag.NewKey("api")
+	KeyStatusCode, _      = tag.NewKey("code")
+	KeyRateLimitPolicy, _ = tag.NewKey("policy")
+	KeyRateLimitResult, _ = tag.NewKey("result")
+	KeyCircuitName, _     = tag.NewKey("circuit")
+	KeyCircuitState, _    = tag.NewKey("state")
+	// KeyUpstreamTarget is the upstream target address, the upstream metrics are labeled by it rather
+	// than by the request to keep the cardinality bounded by the targets
+	KeyUpstreamTarget, _ = tag.NewKey("target")
+	// KeyShadowResult is the result of the comparison of the mirror and primary responses
+	KeyShadowResult, _ = tag.NewKey("result")
+	// KeyPluginName is the name of the plugin, e.g. of the authentication plugin whose dependency failed
+	KeyPluginName, _ = tag.NewKey("plugin")
+	// KeyDependencyPolicy is the policy of the plugin answering the request it could not check
+	KeyDependencyPolicy, _ = tag.NewKey("policy")
+	// KeyTracingExporter is the tracing exporter the spans are sent with, e.g. jaeger
+	KeyTracingExporter, _ = tag.NewKey("exporter")
+	// KeySampled is the sampling decision of a trace, true or false
+	KeySampled, _ = tag.NewKey("sampled")
+)
+
+// Rate limit results, the store misses when it is unavailable
+const (
+	RateLimitAllowed = "allowed"
+	RateLimitLimited = "limited"
+	StoreHit         = "hit"
+	StoreMiss        = "miss"
 )
 
 // Metrics
@@ -41,6 +81,27 @@ var (
 	MOAuth2MalformedHeader      = stats.Int64("plugin_oauth2_malformed_header_total", "Number of failed oauth2 authentication due to malformed bearer header", dimensionless)
 	MOAuth2Authorized           = stats.Int64("plugin_oauth2_authorized_request_total", "Number of successful and authorized oauth2 authentication", dimensionless)
 	MOAuth2Unauthorized         = stats.Int64("plugin_oauth2_unauthorized_request_total", "Number of successful but unauthorized oauth2 authentication", dimensionless)
+	MRequestLatency             = stats.Float64("api_request_latency", "Latency of the requests by API and method", ms)
+	MRequestsInFlight           = stats.Int64("api_requests_in_flight", "Number of requests being served by API", dimensionless)
+	MRateLimitRequests          = stats.Int64("plugin_rate_limit_request_total", "Number of rate limited requests by result", dimensionless)
+	MRateLimitStoreRequests     = stats.Int64("plugin_rate_limit_store_request_total", "Number of rate limit store lookups by result", dimensionless)
+	MGlobalRateLimitUtilization = stats.Float64("plugin_global_rate_limit_utilization", "Share of the global rate limit used by API", dimensionless)
+	MConcurrencyInFlight        = stats.Int64("plugin_concurrency_limit_in_flight", "Number of requests holding a concurrency limit slot by API", dimensionless)
+	MConcurrencyQueued          = stats.Int64("plugin_concurrency_limit_queued", "Number of requests waiting for a concurrency limit slot by API", dimensionless)
+	MConcurrencyRejected        = stats.Int64("plugin_concurrency_limit_rejected_total", "Number of requests rejected by the concurrency limit by API", dimensionless)
+	MConcurrencyQueueWait       = stats.Float64("plugin_concurrency_limit_queue_wait", "Time the requests waited for a concurrency limit slot by API", ms)
+	MCircuitTransitions         = stats.Int64("plugin_cb_state_transition_total", "Number of circuit breaker state changes by circuit and new state", dimensionless)
+	MRetryBudgetUtilization     = stats.Float64("plugin_retry_budget_utilization", "Share of the retry budget used by API", dimensionless)
+	MRetriesDropped             = stats.Int64("plugin_retry_dropped_total", "Number of failed requests not retried because of the retry budget by API", dimensionless)
+	MCircuitState               = stats.Int64("plugin_cb_state", "Circuit breaker state by API and circuit, 0 closed, 1 half-open and 2 open", dimensionless)
+	MUpstreamHealth             = stats.Int64("upstream_health", "Result of the last upstream health check by API, 1 up and 0 down", dimensionless)
+	MUpstreamInFlight           = stats.Int64("upstream_requests_in_flight", "Number of requests being sent to the upstream by API and target", dimensionless)
+	MShadowComparisons          = stats.Int64("plugin_shadow_comparison_total", "Number of compared mirror and primary responses by API and result", dimensionless)
+	MAuthDependencyErrors       = stats.Int64("plugin_auth_dependency_error_total", "Number of requests an authentication plugin could not check by API, plugin and policy", dimensionless)
+	MTracingSpansExported       = stats.Int64("tracing_spans_exported_total", "Number of sampled spans handed to the tracing exporter by exporter", dimensionless)
+	MTracingSpansDropped        = stats.Int64("tracing_spans_dropped_total", "Number of spans dropped by the tracing exporter by exporter", dimensionless)
+	MTracingExportErrors        = stats.Int64("tracing_export_error_total", "Number of errors sending the spans to the tracing backend by exporter", dimensionless)
+	MTracingSamplerDecisions    = stats.Int64("tracing_sampler_decision_total", "Number of sampling decisions of the traces by decision", dimensionless)
 )
 
 // AllViews aggregates the metrics
@@ -71,6 +132,149 @@ var AllViews = []*view.View{
 		Measure:     MOAuth2Unauthorized,
 		Aggregation: view.Count(),
 	},
+	{
+		Name:        "api_request_total",
+		Description: "Number of requests by API, method and response status code",
+		TagKeys:     []tag.Key{KeyAPIName, ochttp.Method, KeyStatusCode},
+		Measure:     MRequestLatency,
+		Aggregation: view.Count(),
+	},
+	{
+		Name:        "api_request_latency",
+		Description: "Latency of the requests by API and method",
+		TagKeys:     []tag.Key{KeyAPIName, ochttp.Method},
+		Measure:     MRequestLatency,
+		Aggregation: ochttp.DefaultLatencyDistribution,
+	},
+	{
+		Name:        "api_requests_in_flight",
+		TagKeys:     []tag.Key{KeyAPIName},
+		Measure:     MRequestsInFlight,
+		Aggregation: view.LastValue(),
+	},
+	{
+		Name:        "api_upstream_response_total",
+		Description: "Number of upstream responses by API, method and upstream status code",
+		TagKeys:     []tag.Key{KeyAPIName, ochttp.KeyClientMethod, ochttp.KeyClientStatus},
+		Measure:     ochttp.ClientRoundtripLatency,
+		Aggregation: view.Count(),
+	},
+	{
+		Name:        "plugin_rate_limit_request_total",
+		TagKeys:     []tag.Key{KeyAPIName, KeyRateLimitResult},
+		Measure:     MRateLimitRequests,
+		Aggregation: view.Count(),
+	},
+	{
+		Name:        "plugin_rate_limit_store_request_total",
+		TagKeys:     []tag.Key{KeyRateLimitPolicy, KeyRateLimitResult},
+		Measure:     MRateLimitStoreRequests,
+		Aggregation: view.Count(),
+	},
+	{
+		Name:        "plugin_global_rate_limit_utilization",
+		TagKeys:     []tag.Key{KeyAPIName},
+		Measure:     MGlobalRateLimitUtilization,
+		Aggregation: view.LastValue(),
+	},
+	{
+		Name:        "plugin_concurrency_limit_in_flight",
+		TagKeys:     []tag.Key{KeyAPIName},
+		Measure:     MConcurrencyInFlight,
+		Aggregation: view.LastValue(),
+	},
+	{
+		Name:        "plugin_concurrency_limit_queued",
+		TagKeys:     []tag.Key{KeyAPIName},
+		Measure:     MConcurrencyQueued,
+		Aggregation: view.LastValue(),
+	},
+	{
+		Name:        "plugin_concurrency_limit_rejected_total",
+		TagKeys:     []tag.Key{KeyAPIName},
+		Measure:     MConcurrencyRejected,
+		Aggregation: view.Count(),
+	},
+	{
+		Name:        "plugin_concurrency_limit_queue_wait",
+		TagKeys:     []tag.Key{KeyAPIName},
+		Measure:     MConcurrencyQueueWait,
+		Aggregation: ochttp.DefaultLatencyDistribution,
+	},
+	{
+		Name:        "plugin_cb_state_transition_total",
+		TagKeys:     []tag.Key{KeyAPIName, KeyCircuitName, KeyCircuitState},
+		Measure:     MCircuitTransitions,
+		Aggregation: view.Count(),
+	},
+	{
+		Name:        "plugin_cb_state",
+		TagKeys:     []tag.Key{KeyAPIName, KeyCircuitName},
+		Measure:     MCircuitState,
+		Aggregation: view.LastValue(),
+	},
+	{
+		Name:        "upstream_health",
+		TagKeys:     []tag.Key{KeyAPIName},
+		Measure:     MUpstreamHealth,
+		Aggregation: view.LastValue(),
+	},
+	{
+		Name:        "upstream_requests_in_flight",
+		TagKeys:     []tag.Key{KeyAPIName, KeyUpstreamTarget},
+		Measure:     MUpstreamInFlight,
+		Aggregation: view.LastValue(),
+	},
+	{
+		Name:        "plugin_retry_budget_utilization",
+		TagKeys:     []tag.Key{KeyAPIName},
+		Measure:     MRetryBudgetUtilization,
+		Aggregation: view.LastValue(),
+	},
+	{
+		Name:        "plugin_retry_dropped_total",
+		TagKeys:     []tag.Key{KeyAPIName},
+		Measure:     MRetriesDropped,
+		Aggregation: view.Count(),
+	},
+	{
+		Name:        "plugin_shadow_comparison_total",
+		TagKeys:     []tag.Key{KeyAPIName, KeyShadowResult},
+		Measure:     MShadowComparisons,
+		Aggregation: view.Count(),
+	},
+	{
+		Name:        "plugin_auth_dependency_error_total",
+		Description: "Number of requests an authentication plugin could not check because its dependency failed",
+		TagKeys:     []tag.Key{KeyAPIName, KeyPluginName, KeyDependencyPolicy},
+		Measure:     MAuthDependencyErrors,
+		Aggregation: view.Count(),
+	},
+	{
+		Name:        "tracing_spans_exported_total",
+		TagKeys:     []tag.Key{KeyTracingExporter},
+		Measure:     MTracingSpansExported,
+		Aggregation: view.Count(),
+	},
+	{
+		Name:        "tracing_spans_dropped_total",
+		Description: "Number of spans dropped by the tracing exporter, e.g. because its queue is full",
+		TagKeys:     []tag.Key{KeyTracingExporter},
+		Measure:     MTracingSpansDropped,
+		Aggregation: view.Sum(),
+	},
+	{
+		Name:        "tracing_export_error_total",
+		TagKeys:     []tag.Key{KeyTracingExporter},
+		Measure:     MTracingExportErrors,
+		Aggregation: view.Count(),
+	},
+	{
+		Name:        "tracing_sampler_decision_total",
+		TagKeys:     []tag.Key{KeySampled},
+		Measure:     MTracingSamplerDecisions,
+		Aggregation: view.Count(),
+	},
 	{
 		Name:        "http_server_response_count_by_path_code_and_method",
 		TagKeys:     []tag.Key{KeyListenPath, ochttp.StatusCode, ochttp.Method},
@@ -101,3 +305,17 @@ var AllViews = []*view.View{
 		Aggregation: ochttp.DefaultLatencyDistribution,
 	},
 }
+
+// RecordRequest records the metrics of a request served by an API, the API is read from the
+// context tags. The metrics are collected by the stats exporter and sent to the StatsD emitter.
+func RecordRequest(ctx context.Context, method string, code int, latency time.Duration) {
+	stats.RecordWithTags(ctx, []tag.Mutator{
+		tag.Upsert(ochttp.Method, method),
+		tag.Upsert(KeyStatusCode, strconv.Itoa(code)),
+	}, MRequestLatency.M(float64(latency)/float64(time.Millisecond)))
+
+	if StatsDEmitter != nil {
+		api, _ := tag.FromContext(ctx).Value(KeyAPIName)
+		StatsDEmitter.Request(api, method, code, latency)
+	}
+}
@@ -0,0 +1,205 @@
+package observability
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"go.opencensus.io/trace"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// DefaultOTLPEndpoint is the traces endpoint of a local OpenTelemetry collector receiving OTLP/HTTP
+	DefaultOTLPEndpoint = "http://localhost:4318/v1/traces"
+
+	otlpTimeout = 10 * time.Second
+)
+
+// OTLPOptions are the options of the OTLP exporter
+type OTLPOptions struct {
+	// Endpoint is the URL the spans are posted to, DefaultOTLPEndpoint when it is empty
+	Endpoint string
+	// Headers are sent with the spans, e.g. the API key of a hosted collector
+	Headers     map[string]string
+	ServiceName string
+	// Timeout bounds the requests posting the spans, 10s when it is zero
+	Timeout time.Duration
+}
+
+// OTLPExporter exports the OpenCensus spans to an OpenTelemetry collector with the OTLP/HTTP protocol in its
+// protobuf encoding. OTLP/gRPC is not supported: the generated collector service requires a grpc release newer
+// than the one Janus is locked to. The spans are still created with the OpenCensus API, e.g. trace.FromContext,
+// so the instrumentation does not change while the backends move to OpenTelemetry. The spans are sent in
+// batches asynchronously and dropped when the collector can not keep up.
+type OTLPExporter struct {
+	*spanBatcher
+	endpoint string
+	headers  map[string]string
+	resource *resourcepb.Resource
+	client   *http.Client
+}
+
+// NewOTLPExporter creates a new instance of OTLPExporter
+func NewOTLPExporter(opts OTLPOptions) (*OTLPExporter, error) {
+	if opts.Endpoint == "" {
+		opts.Endpoint = DefaultOTLPEndpoint
+	}
+	endpoint, err := url.Parse(opts.Endpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q, it must be an http or https URL", opts.Endpoint)
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = otlpTimeout
+	}
+
+	e := &OTLPExporter{
+		endpoint: endpoint.String(),
+		headers:  opts.Headers,
+		resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{stringAttribute("service.name", opts.ServiceName)}},
+		client:   &http.Client{Timeout: opts.Timeout},
+	}
+	e.spanBatcher = newSpanBatcher(OTel, e.post)
+
+	return e, nil
+}
+
+func (e *OTLPExporter) post(batch []*trace.SpanData) {
+	body, err := proto.Marshal(e.request(batch))
+	if err != nil {
+		log.WithError(err).Warn("Could not encode the OTLP spans")
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		log.WithError(err).Warn("Could not create the OTLP request")
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	for name, value := range e.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		log.WithError(err).WithField("spans", len(batch)).Warn("Could not send the spans to the OTLP endpoint")
+		RecordExportError(OTel)
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		log.WithField("code", resp.StatusCode).WithField("spans", len(batch)).Warn("The OTLP endpoint rejected the spans")
+		RecordExportError(OTel)
+	}
+}
+
+// request converts the spans to an OTLP export request. TracesData has the wire format of the
+// ExportTraceServiceRequest of the collector service.
+func (e *OTLPExporter) request(batch []*trace.SpanData) *tracepb.TracesData {
+	spans := make([]*tracepb.Span, 0, len(batch))
+	for _, sd := range batch {
+		spans = append(spans, newOTLPSpan(sd))
+	}
+
+	return &tracepb.TracesData{ResourceSpans: []*tracepb.ResourceSpans{{
+		Resource:   e.resource,
+		ScopeSpans: []*tracepb.ScopeSpans{{Scope: &commonpb.InstrumentationScope{Name: "janus"}, Spans: spans}},
+	}}}
+}
+
+func newOTLPSpan(sd *trace.SpanData) *tracepb.Span {
+	span := &tracepb.Span{
+		TraceId:           sd.TraceID[:],
+		SpanId:            sd.SpanID[:],
+		Name:              sd.Name,
+		Kind:              otlpSpanKind(sd.SpanKind),
+		StartTimeUnixNano: uint64(sd.StartTime.UnixNano()),
+		EndTimeUnixNano:   uint64(sd.EndTime.UnixNano()),
+		Attributes:        otlpAttributes(sd.Attributes),
+		Status:            &tracepb.Status{},
+	}
+	if sd.ParentSpanID != (trace.SpanID{}) {
+		span.ParentSpanId = sd.ParentSpanID[:]
+	}
+	if sd.Code != trace.StatusCodeOK {
+		span.Status = &tracepb.Status{Code: tracepb.Status_STATUS_CODE_ERROR, Message: sd.Message}
+	}
+
+	for _, annotation := range sd.Annotations {
+		span.Events = append(span.Events, &tracepb.Span_Event{
+			TimeUnixNano: uint64(annotation.Time.UnixNano()),
+			Name:         annotation.Message,
+			Attributes:   otlpAttributes(annotation.Attributes),
+		})
+	}
+	for _, event := range sd.MessageEvents {
+		name := "message.sent"
+		if event.EventType == trace.MessageEventTypeRecv {
+			name = "message.received"
+		}
+		span.Events = append(span.Events, &tracepb.Span_Event{
+			TimeUnixNano: uint64(event.Time.UnixNano()),
+			Name:         name,
+			Attributes: otlpAttributes(map[string]interface{}{
+				"message.id":                event.MessageID,
+				"message.uncompressed_size": event.UncompressedByteSize,
+				"message.compressed_size":   event.CompressedByteSize,
+			}),
+		})
+	}
+	for _, link := range sd.Links {
+		traceID, spanID := link.TraceID, link.SpanID
+		span.Links = append(span.Links, &tracepb.Span_Link{
+			TraceId:    traceID[:],
+			SpanId:     spanID[:],
+			Attributes: otlpAttributes(link.Attributes),
+		})
+	}
+
+	return span
+}
+
+func otlpSpanKind(kind int) tracepb.Span_SpanKind {
+	switch kind {
+	case trace.SpanKindServer:
+		return tracepb.Span_SPAN_KIND_SERVER
+	case trace.SpanKindClient:
+		return tracepb.Span_SPAN_KIND_CLIENT
+	default:
+		return tracepb.Span_SPAN_KIND_INTERNAL
+	}
+}
+
+func otlpAttributes(attributes map[string]interface{}) []*commonpb.KeyValue {
+	converted := make([]*commonpb.KeyValue, 0, len(attributes))
+	for key, value := range attributes {
+		v := &commonpb.AnyValue{}
+		switch value := value.(type) {
+		case bool:
+			v.Value = &commonpb.AnyValue_BoolValue{BoolValue: value}
+		case int64:
+			v.Value = &commonpb.AnyValue_IntValue{IntValue: value}
+		case float64:
+			v.Value = &commonpb.AnyValue_DoubleValue{DoubleValue: value}
+		default:
+			v.Value = &commonpb.AnyValue_StringValue{StringValue: fmt.Sprint(value)}
+		}
+		converted = append(converted, &commonpb.KeyValue{Key: key, Value: v})
+	}
+	sort.Slice(converted, func(i, j int) bool { return converted[i].Key < converted[j].Key })
+
+	return converted
+}
+
+func stringAttribute(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
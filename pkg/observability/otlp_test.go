@@ -0,0 +1,86 @@
+package observability
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/trace"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestOTLPExporter(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests <- r
+		bodies <- body
+	}))
+	defer collector.Close()
+
+	exporter, err := NewOTLPExporter(OTLPOptions{
+		Endpoint:    collector.URL + "/v1/traces",
+		Headers:     map[string]string{"X-Api-Key": "secret"},
+		ServiceName: "janus",
+	})
+	require.NoError(t, err)
+
+	start := time.Unix(1500000000, 0)
+	exporter.ExportSpan(&trace.SpanData{
+		SpanContext: trace.SpanContext{
+			TraceID: trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+			SpanID:  trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		},
+		ParentSpanID: trace.SpanID{0x01},
+		SpanKind:     trace.SpanKindServer,
+		Name:         "api.example",
+		StartTime:    start,
+		EndTime:      start.Add(time.Second),
+		Attributes:   map[string]interface{}{"http.path": "/example", "http.status_code": int64(502), "sampled": true},
+		Annotations:  []trace.Annotation{{Time: start, Message: "retrying"}},
+		Status:       trace.Status{Code: trace.StatusCodeUnavailable, Message: "bad gateway"},
+	})
+	exporter.Close()
+
+	req := <-requests
+	assert.Equal(t, "/v1/traces", req.URL.Path)
+	assert.Equal(t, "application/x-protobuf", req.Header.Get("Content-Type"))
+	assert.Equal(t, "secret", req.Header.Get("X-Api-Key"))
+
+	var data tracepb.TracesData
+	require.NoError(t, proto.Unmarshal(<-bodies, &data))
+
+	expected := &tracepb.TracesData{ResourceSpans: []*tracepb.ResourceSpans{{
+		Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{stringAttribute("service.name", "janus")}},
+		ScopeSpans: []*tracepb.ScopeSpans{{Scope: &commonpb.InstrumentationScope{Name: "janus"}, Spans: []*tracepb.Span{{
+			TraceId:           []byte{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+			SpanId:            []byte{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+			ParentSpanId:      []byte{0x01, 0, 0, 0, 0, 0, 0, 0},
+			Name:              "api.example",
+			Kind:              tracepb.Span_SPAN_KIND_SERVER,
+			StartTimeUnixNano: 1500000000000000000,
+			EndTimeUnixNano:   1500000001000000000,
+			Attributes: []*commonpb.KeyValue{
+				stringAttribute("http.path", "/example"),
+				{Key: "http.status_code", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: 502}}},
+				{Key: "sampled", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: true}}},
+			},
+			Events: []*tracepb.Span_Event{{TimeUnixNano: 1500000000000000000, Name: "retrying"}},
+			Status: &tracepb.Status{Code: tracepb.Status_STATUS_CODE_ERROR, Message: "bad gateway"},
+		}}}},
+	}}}
+	assert.True(t, proto.Equal(expected, &data), "unexpected export request: %v", &data)
+}
+
+func TestOTLPExporterInvalidEndpoint(t *testing.T) {
+	_, err := NewOTLPExporter(OTLPOptions{Endpoint: "localhost:4318"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,33 @@
+package observability
+
+import (
+	"fmt"
+
+	"go.opencensus.io/plugin/ochttp/propagation/tracecontext"
+	"go.opencensus.io/trace/propagation"
+)
+
+// The formats of the trace context propagation
+const (
+	// B3Propagation reads and sends the trace context in the B3 headers of Zipkin
+	B3Propagation = "b3"
+	// W3CPropagation reads and sends the trace context in the W3C Trace Context "traceparent" and "tracestate"
+	// headers
+	W3CPropagation = "w3c"
+)
+
+// Propagation is the format of the trace context read from the requests and sent to the upstreams, it is set
+// before the API definitions are loaded
+var Propagation propagation.HTTPFormat = &B3Format{}
+
+// NewPropagation creates the propagation format of the trace context, B3 when the format is empty
+func NewPropagation(format string) (propagation.HTTPFormat, error) {
+	switch format {
+	case "", B3Propagation:
+		return &B3Format{}, nil
+	case W3CPropagation:
+		return &tracecontext.HTTPFormat{}, nil
+	default:
+		return nil, fmt.Errorf("invalid propagation format %q, it must be %s or %s", format, B3Propagation, W3CPropagation)
+	}
+}
@@ -0,0 +1,45 @@
+package observability
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPropagation(t *testing.T) {
+	format, err := NewPropagation("")
+	require.NoError(t, err)
+	assert.IsType(t, &B3Format{}, format)
+
+	_, err = NewPropagation("jaeger")
+	assert.Error(t, err)
+}
+
+func TestW3CPropagation(t *testing.T) {
+	format, err := NewPropagation(W3CPropagation)
+	require.NoError(t, err)
+
+	in := httptest.NewRequest(http.MethodGet, "/", nil)
+	in.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	in.Header.Set("tracestate", "congo=t61rcWkgMzE,rojo=00f067aa0ba902b7")
+
+	sc, ok := format.SpanContextFromRequest(in)
+	require.True(t, ok)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	assert.True(t, sc.IsSampled())
+
+	out := httptest.NewRequest(http.MethodGet, "/", nil)
+	format.SpanContextToRequest(sc, out)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", out.Header.Get("traceparent"))
+	assert.Equal(t, "congo=t61rcWkgMzE,rojo=00f067aa0ba902b7", out.Header.Get("tracestate"))
+	assert.Empty(t, out.Header.Get("X-B3-TraceId"))
+
+	in = httptest.NewRequest(http.MethodGet, "/", nil)
+	in.Header.Set("X-B3-TraceId", "4bf92f3577b34da6a3ce929d0e0e4736")
+	in.Header.Set("X-B3-SpanId", "00f067aa0ba902b7")
+	_, ok = format.SpanContextFromRequest(in)
+	assert.False(t, ok, "the B3 headers are not read")
+}
@@ -0,0 +1,81 @@
+package observability
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// statsDQueueSize is the number of packets buffered for sending, packets are dropped when it is full
+const statsDQueueSize = 1024
+
+// StatsDEmitter is the StatsD emitter the request metrics are sent to, when configured
+var StatsDEmitter *StatsD
+
+var statsDTagReplacer = strings.NewReplacer(":", "_", ",", "_", "|", "_", "#", "_", " ", "_")
+
+// StatsD sends the request metrics to a StatsD server, tagged in the DogStatsD format. The metrics
+// are sent asynchronously over UDP and dropped when the server is unreachable or the queue is full,
+// so the emitter never slows down the requests.
+type StatsD struct {
+	conn       net.Conn
+	prefix     string
+	sampleRate float64
+	queue      chan []byte
+}
+
+// NewStatsD creates a new instance of StatsD sending to the given host:port
+func NewStatsD(addr string, prefix string, sampleRate float64) (*StatsD, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if sampleRate <= 0 || sampleRate > 1 {
+		sampleRate = 1
+	}
+
+	s := &StatsD{conn: conn, prefix: prefix, sampleRate: sampleRate, queue: make(chan []byte, statsDQueueSize)}
+	go s.send()
+
+	return s, nil
+}
+
+// Close closes the connection, the packets emitted afterwards are dropped
+func (s *StatsD) Close() error {
+	return s.conn.Close()
+}
+
+// Request emits the count, latency and errors of a request
+func (s *StatsD) Request(api string, method string, code int, latency time.Duration) {
+	if s.sampleRate < 1 && rand.Float64() >= s.sampleRate {
+		return
+	}
+
+	suffix := ""
+	if s.sampleRate < 1 {
+		suffix = "|@" + strconv.FormatFloat(s.sampleRate, 'f', -1, 64)
+	}
+	suffix += fmt.Sprintf("|#api:%s,method:%s,status_class:%dxx", statsDTagReplacer.Replace(api), method, code/100)
+
+	packet := fmt.Sprintf("%s.request.count:1|c%s\n%s.request.latency:%d|ms%s",
+		s.prefix, suffix, s.prefix, latency/time.Millisecond, suffix)
+	if code >= 500 {
+		packet += fmt.Sprintf("\n%s.request.errors:1|c%s", s.prefix, suffix)
+	}
+
+	select {
+	case s.queue <- []byte(packet):
+	default:
+	}
+}
+
+func (s *StatsD) send() {
+	for packet := range s.queue {
+		// UDP errors are ignored on purpose, metrics are best effort
+		s.conn.Write(packet)
+	}
+}
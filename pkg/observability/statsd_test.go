@@ -0,0 +1,93 @@
+package observability
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/tag"
+)
+
+func listenStatsD(t *testing.T) *net.UDPConn {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+
+	return conn
+}
+
+func readPacket(t *testing.T, conn *net.UDPConn) string {
+	buf := make([]byte, 1024)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+
+	return string(buf[:n])
+}
+
+func TestStatsDRequest(t *testing.T) {
+	server := listenStatsD(t)
+	defer server.Close()
+
+	emitter, err := NewStatsD(server.LocalAddr().String(), "janus", 1)
+	require.NoError(t, err)
+	defer emitter.Close()
+
+	emitter.Request("example", http.MethodGet, http.StatusOK, 25*time.Millisecond)
+	assert.Equal(t, "janus.request.count:1|c|#api:example,method:GET,status_class:2xx\n"+
+		"janus.request.latency:25|ms|#api:example,method:GET,status_class:2xx", readPacket(t, server))
+
+	emitter.Request("my:api", http.MethodPost, http.StatusBadGateway, time.Millisecond)
+	assert.Equal(t, "janus.request.count:1|c|#api:my_api,method:POST,status_class:5xx\n"+
+		"janus.request.latency:1|ms|#api:my_api,method:POST,status_class:5xx\n"+
+		"janus.request.errors:1|c|#api:my_api,method:POST,status_class:5xx", readPacket(t, server))
+}
+
+func TestStatsDSampleRate(t *testing.T) {
+	server := listenStatsD(t)
+	defer server.Close()
+
+	emitter, err := NewStatsD(server.LocalAddr().String(), "janus", 0.5)
+	require.NoError(t, err)
+	defer emitter.Close()
+
+	for i := 0; i < 100; i++ {
+		emitter.Request("example", http.MethodGet, http.StatusOK, time.Millisecond)
+	}
+	assert.Contains(t, readPacket(t, server), "janus.request.count:1|c|@0.5|#api:example")
+}
+
+func TestStatsDUnreachableServer(t *testing.T) {
+	server := listenStatsD(t)
+	addr := server.LocalAddr().String()
+	server.Close()
+
+	emitter, err := NewStatsD(addr, "janus", 1)
+	require.NoError(t, err)
+	defer emitter.Close()
+
+	for i := 0; i < 2*statsDQueueSize; i++ {
+		emitter.Request("example", http.MethodGet, http.StatusOK, time.Millisecond)
+	}
+}
+
+func TestRecordRequestSendsToStatsD(t *testing.T) {
+	server := listenStatsD(t)
+	defer server.Close()
+
+	emitter, err := NewStatsD(server.LocalAddr().String(), "janus", 1)
+	require.NoError(t, err)
+	defer emitter.Close()
+
+	StatsDEmitter = emitter
+	defer func() { StatsDEmitter = nil }()
+
+	ctx, err := tag.New(context.Background(), tag.Insert(KeyAPIName, "example"))
+	require.NoError(t, err)
+
+	RecordRequest(ctx, http.MethodGet, http.StatusNotFound, time.Millisecond)
+	assert.Contains(t, readPacket(t, server), "janus.request.count:1|c|#api:example,method:GET,status_class:4xx")
+}
@@ -0,0 +1,58 @@
+package observability
+
+import (
+	"context"
+	"strconv"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+)
+
+// countingExporter records the spans handed to the tracing exporter
+type countingExporter struct {
+	trace.Exporter
+	ctx context.Context
+}
+
+// NewCountingExporter wraps the tracing exporter, so the spans it exports are counted by the
+// tracing_spans_exported_total metric
+func NewCountingExporter(exporter string, e trace.Exporter) trace.Exporter {
+	ctx, _ := tag.New(context.Background(), tag.Insert(KeyTracingExporter, exporter))
+	return &countingExporter{Exporter: e, ctx: ctx}
+}
+
+// ExportSpan exports the span
+func (e *countingExporter) ExportSpan(s *trace.SpanData) {
+	stats.Record(e.ctx, MTracingSpansExported.M(1))
+	e.Exporter.ExportSpan(s)
+}
+
+// NewCountingSampler wraps the sampler, so its decisions are counted by the tracing_sampler_decision_total
+// metric. The spans whose parent is a local span keep the decision of their parent, they are not counted.
+func NewCountingSampler(sampler trace.Sampler) trace.Sampler {
+	return func(p trace.SamplingParameters) trace.SamplingDecision {
+		decision := sampler(p)
+		if p.ParentContext == (trace.SpanContext{}) || p.HasRemoteParent {
+			stats.RecordWithTags(context.Background(), []tag.Mutator{
+				tag.Upsert(KeySampled, strconv.FormatBool(decision.Sample)),
+			}, MTracingSamplerDecisions.M(1))
+		}
+
+		return decision
+	}
+}
+
+// RecordSpansDropped records the spans the tracing exporter dropped, e.g. because its queue is full
+func RecordSpansDropped(exporter string, spans int) {
+	stats.RecordWithTags(context.Background(), []tag.Mutator{
+		tag.Upsert(KeyTracingExporter, exporter),
+	}, MTracingSpansDropped.M(int64(spans)))
+}
+
+// RecordExportError records an error of the tracing exporter sending the spans to its backend
+func RecordExportError(exporter string) {
+	stats.RecordWithTags(context.Background(), []tag.Mutator{
+		tag.Upsert(KeyTracingExporter, exporter),
+	}, MTracingExportErrors.M(1))
+}
@@ -0,0 +1,76 @@
+package observability
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+)
+
+type spanRecorder []*trace.SpanData
+
+func (r *spanRecorder) ExportSpan(s *trace.SpanData) {
+	*r = append(*r, s)
+}
+
+// counts returns the counts of the view rows by the value of the tag
+func counts(t *testing.T, name string, key tag.Key) map[string]int64 {
+	rows, err := view.RetrieveData(name)
+	require.NoError(t, err)
+
+	counts := make(map[string]int64)
+	for _, row := range rows {
+		for _, tg := range row.Tags {
+			if tg.Key != key {
+				continue
+			}
+			switch data := row.Data.(type) {
+			case *view.CountData:
+				counts[tg.Value] = data.Value
+			case *view.SumData:
+				counts[tg.Value] = int64(data.Value)
+			}
+		}
+	}
+	return counts
+}
+
+func TestCountingExporter(t *testing.T) {
+	require.NoError(t, view.Register(AllViews...))
+	defer view.Unregister(AllViews...)
+
+	recorder := &spanRecorder{}
+	exporter := NewCountingExporter(Jaeger, recorder)
+	exporter.ExportSpan(&trace.SpanData{Name: "api.example"})
+	exporter.ExportSpan(&trace.SpanData{Name: "api.example"})
+	RecordSpansDropped(OTel, 3)
+	RecordExportError(Jaeger)
+
+	assert.Len(t, *recorder, 2)
+	assert.Equal(t, map[string]int64{Jaeger: 2}, counts(t, "tracing_spans_exported_total", KeyTracingExporter))
+	assert.Equal(t, map[string]int64{OTel: 3}, counts(t, "tracing_spans_dropped_total", KeyTracingExporter))
+	assert.Equal(t, map[string]int64{Jaeger: 1}, counts(t, "tracing_export_error_total", KeyTracingExporter))
+}
+
+func TestCountingSampler(t *testing.T) {
+	require.NoError(t, view.Register(AllViews...))
+	defer view.Unregister(AllViews...)
+
+	always := NewCountingSampler(trace.AlwaysSample())
+	never := NewCountingSampler(trace.NeverSample())
+
+	ctx, root := trace.StartSpan(context.Background(), "root", trace.WithSampler(always))
+	// the child span of a local span keeps the decision of its parent
+	_, child := trace.StartSpan(ctx, "child", trace.WithSampler(always))
+	child.End()
+	root.End()
+
+	_, remote := trace.StartSpanWithRemoteParent(context.Background(), "remote", root.SpanContext(), trace.WithSampler(never))
+	remote.End()
+
+	assert.Equal(t, map[string]int64{"true": 1, "false": 1}, counts(t, "tracing_sampler_decision_total", KeySampled))
+}
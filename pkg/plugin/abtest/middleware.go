@@ -0,0 +1,120 @@
+package abtest
+
+import (
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/hellofresh/janus/pkg/middleware"
+	"github.com/hellofresh/janus/pkg/proxy"
+)
+
+// ABTest assigns the visitors to the experiment variants and routes their requests to the variant
+// upstreams
+type ABTest struct {
+	cookieName string
+	cookieTTL  time.Duration
+	header     string
+	variants   []Variant
+	total      int
+	rules      []rule
+
+	// intn returns a random number in [0, n)
+	intn func(n int) int
+}
+
+// NewABTest creates a new instance of ABTest
+func NewABTest(config Config) (*ABTest, error) {
+	rules, err := compileRules(config.Rules, config.Variants)
+	if err != nil {
+		return nil, err
+	}
+
+	total := 0
+	for _, variant := range config.Variants {
+		total += variant.Weight
+	}
+
+	return &ABTest{
+		cookieName: config.CookieName,
+		cookieTTL:  time.Duration(config.CookieTTL),
+		header:     config.Header,
+		variants:   config.Variants,
+		total:      total,
+		rules:      rules,
+		intn:       rand.Intn,
+	}, nil
+}
+
+// Handler is the A/B test middleware. The requests matching a rule are assigned its variant, the
+// first matching rule wins and no cookie is set, so changing the rules applies to the next requests.
+// The variant of a returning visitor is read from the cookie, the new visitors, and the ones with a
+// removed or disabled variant, are assigned one by weighted random and get the cookie. The variant is
+// sent to the upstream in the header, and the request is proxied to the variant targets, or to the API
+// upstreams when the variant has none.
+func (m *ABTest) Handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		variant, ok := m.fromRules(r)
+		if !ok {
+			variant, ok = m.fromCookie(r)
+		}
+		if !ok {
+			variant = m.elect()
+			http.SetCookie(w, &http.Cookie{
+				Name:     m.cookieName,
+				Value:    variant.Name,
+				Path:     "/",
+				MaxAge:   int(m.cookieTTL.Seconds()),
+				HttpOnly: true,
+				Secure:   r.TLS != nil,
+			})
+		}
+
+		r.Header.Set(m.header, variant.Name)
+		middleware.SetAccessLogVariant(r.Context(), variant.Name)
+
+		ctx := proxy.WithVariant(r.Context(), variant.Name)
+		if len(variant.Targets) > 0 {
+			ctx = proxy.WithUpstreamTargets(ctx, variant.Targets)
+		}
+
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (m *ABTest) fromRules(r *http.Request) (Variant, bool) {
+	for _, rule := range m.rules {
+		if rule.match(r) {
+			return rule.variant, true
+		}
+	}
+
+	return Variant{}, false
+}
+
+func (m *ABTest) fromCookie(r *http.Request) (Variant, bool) {
+	cookie, err := r.Cookie(m.cookieName)
+	if err != nil {
+		return Variant{}, false
+	}
+
+	for _, variant := range m.variants {
+		if variant.Name == cookie.Value {
+			return variant, variant.Weight > 0
+		}
+	}
+
+	return Variant{}, false
+}
+
+func (m *ABTest) elect() Variant {
+	n := m.intn(m.total)
+	for _, variant := range m.variants {
+		if n < variant.Weight {
+			return variant
+		}
+		n -= variant.Weight
+	}
+
+	return m.variants[len(m.variants)-1]
+}
@@ -0,0 +1,170 @@
+package abtest
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	jwtBase "github.com/dgrijalva/jwt-go"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var newUITargets = proxy.Targets{{Target: "http://new-ui.example.com"}}
+
+func newTestABTest(n int, rules ...Rule) *ABTest {
+	m, err := NewABTest(Config{
+		CookieName: DefaultCookieName,
+		CookieTTL:  proxy.Duration(DefaultCookieTTL),
+		Header:     DefaultHeader,
+		Variants: []Variant{
+			{Name: "control", Weight: 90},
+			{Name: "new-ui", Weight: 10, Targets: newUITargets},
+			{Name: "disabled", Weight: 0},
+		},
+		Rules: rules,
+	})
+	if err != nil {
+		panic(err)
+	}
+	m.intn = func(int) int { return n }
+
+	return m
+}
+
+// recordRequest returns a handler keeping the last proxied request
+func recordRequest(proxied **http.Request) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*proxied = r
+	})
+}
+
+func TestABTestAssignsNewVisitor(t *testing.T) {
+	var proxied *http.Request
+	handler := newTestABTest(95).Handler(recordRequest(&proxied))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	require.NotNil(t, proxied)
+	assert.Equal(t, "new-ui", proxied.Header.Get(DefaultHeader))
+	assert.Equal(t, "new-ui", proxy.VariantFromContext(proxied.Context()))
+
+	targets, ok := proxy.UpstreamTargetsFromContext(proxied.Context())
+	assert.True(t, ok)
+	assert.Equal(t, newUITargets, targets)
+
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, DefaultCookieName, cookies[0].Name)
+	assert.Equal(t, "new-ui", cookies[0].Value)
+	assert.Equal(t, "/", cookies[0].Path)
+	assert.Equal(t, int(DefaultCookieTTL.Seconds()), cookies[0].MaxAge)
+	assert.True(t, cookies[0].HttpOnly)
+	assert.False(t, cookies[0].Secure)
+}
+
+func TestABTestStickyVariant(t *testing.T) {
+	var proxied *http.Request
+	handler := newTestABTest(95).Handler(recordRequest(&proxied))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: "control"})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, "control", proxied.Header.Get(DefaultHeader))
+	assert.Empty(t, w.Result().Cookies(), "the cookie of a returning visitor is kept")
+
+	_, ok := proxy.UpstreamTargetsFromContext(proxied.Context())
+	assert.False(t, ok, "the variant without targets uses the API upstreams")
+}
+
+func TestABTestReassignsUnknownVariant(t *testing.T) {
+	for _, value := range []string{"removed", "disabled"} {
+		t.Run(value, func(t *testing.T) {
+			var proxied *http.Request
+			handler := newTestABTest(0).Handler(recordRequest(&proxied))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: value})
+			r.TLS = &tls.ConnectionState{}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			assert.Equal(t, "control", proxied.Header.Get(DefaultHeader))
+
+			cookies := w.Result().Cookies()
+			require.Len(t, cookies, 1)
+			assert.Equal(t, "control", cookies[0].Value)
+			assert.True(t, cookies[0].Secure)
+		})
+	}
+}
+
+func TestABTestElectWeights(t *testing.T) {
+	m := newTestABTest(0)
+	counts := make(map[string]int)
+	for n := 0; n < m.total; n++ {
+		m.intn = func(int) int { return n }
+		counts[m.elect().Name]++
+	}
+
+	assert.Equal(t, map[string]int{"control": 90, "new-ui": 10}, counts)
+}
+
+func bearerToken(t *testing.T, claims jwtBase.MapClaims) string {
+	token, err := jwtBase.NewWithClaims(jwtBase.SigningMethodHS256, claims).SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	return "Bearer " + token
+}
+
+func TestABTestRules(t *testing.T) {
+	m := newTestABTest(0,
+		Rule{Claim: "email", Pattern: `@hellofresh\.com$`, Variant: "disabled"},
+		Rule{Claim: "groups", Values: []string{"beta"}, Variant: "new-ui"},
+		Rule{Header: "X-Canary", Values: []string{"always"}, Variant: "new-ui"},
+		Rule{Cookie: "canary", Values: []string{"1"}, Variant: "new-ui"},
+	)
+
+	serve := func(r *http.Request) (string, *httptest.ResponseRecorder) {
+		var proxied *http.Request
+		w := httptest.NewRecorder()
+		m.Handler(recordRequest(&proxied)).ServeHTTP(w, r)
+
+		require.NotNil(t, proxied)
+		return proxied.Header.Get(DefaultHeader), w
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", bearerToken(t, jwtBase.MapClaims{"email": "jane@hellofresh.com"}))
+	r.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: "control"})
+	variant, w := serve(r)
+	assert.Equal(t, "disabled", variant, "the rules assign the variants without weight and win over the cookie")
+	assert.Empty(t, w.Result().Cookies(), "the variants assigned by a rule are not kept in the cookie")
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", bearerToken(t, jwtBase.MapClaims{"email": "jane@example.com", "groups": []interface{}{"staff", "beta"}}))
+	variant, _ = serve(r)
+	assert.Equal(t, "new-ui", variant, "the rules are evaluated in order and match any value of a list claim")
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Canary", "always")
+	variant, _ = serve(r)
+	assert.Equal(t, "new-ui", variant)
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: "canary", Value: "1"})
+	variant, _ = serve(r)
+	assert.Equal(t, "new-ui", variant)
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer not-a-token")
+	r.Header.Set("X-Canary", "never")
+	variant, w = serve(r)
+	assert.Equal(t, "control", variant, "the requests matching no rule are assigned by weighted random")
+	require.Len(t, w.Result().Cookies(), 1)
+}
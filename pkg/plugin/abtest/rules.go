@@ -0,0 +1,139 @@
+package abtest
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	jwtBase "github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+)
+
+// Rule assigns the requests whose header, cookie or token claim matches to a variant, whatever its
+// weight. The value must be one of the values or match the regular expression.
+type Rule struct {
+	Header  string   `json:"header"`
+	Cookie  string   `json:"cookie"`
+	Claim   string   `json:"claim"`
+	Values  []string `json:"values"`
+	Pattern string   `json:"pattern"`
+	Variant string   `json:"variant"`
+}
+
+// rule is a compiled rule
+type rule struct {
+	attribute func(r *http.Request) []string
+	values    map[string]bool
+	pattern   *regexp.Regexp
+	variant   Variant
+}
+
+func compileRules(rules []Rule, variants []Variant) ([]rule, error) {
+	compiled := make([]rule, 0, len(rules))
+	for i, config := range rules {
+		c := rule{values: make(map[string]bool, len(config.Values))}
+
+		set := 0
+		if config.Header != "" {
+			c.attribute = headerAttribute(config.Header)
+			set++
+		}
+		if config.Cookie != "" {
+			c.attribute = cookieAttribute(config.Cookie)
+			set++
+		}
+		if config.Claim != "" {
+			c.attribute = claimAttribute(config.Claim)
+			set++
+		}
+		if set != 1 {
+			return nil, errors.Errorf("A/B test rule %d must set one of header, cookie or claim", i)
+		}
+
+		if len(config.Values) == 0 && config.Pattern == "" {
+			return nil, errors.Errorf("A/B test rule %d must set values or a pattern", i)
+		}
+		for _, value := range config.Values {
+			c.values[value] = true
+		}
+		if config.Pattern != "" {
+			pattern, err := regexp.Compile(config.Pattern)
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not compile the pattern of the A/B test rule %d", i)
+			}
+			c.pattern = pattern
+		}
+
+		found := false
+		for _, variant := range variants {
+			if variant.Name == config.Variant {
+				c.variant, found = variant, true
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("A/B test rule %d assigns the unknown variant %q", i, config.Variant)
+		}
+
+		compiled = append(compiled, c)
+	}
+
+	return compiled, nil
+}
+
+func (c rule) match(r *http.Request) bool {
+	for _, value := range c.attribute(r) {
+		if c.values[value] || (c.pattern != nil && c.pattern.MatchString(value)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func headerAttribute(name string) func(r *http.Request) []string {
+	return func(r *http.Request) []string {
+		return r.Header[http.CanonicalHeaderKey(name)]
+	}
+}
+
+func cookieAttribute(name string) func(r *http.Request) []string {
+	return func(r *http.Request) []string {
+		cookie, err := r.Cookie(name)
+		if err != nil {
+			return nil
+		}
+		return []string{cookie.Value}
+	}
+}
+
+// claimAttribute reads the claim of the bearer token of the request. The token is not verified, the
+// API must verify it with an authentication plugin, e.g. oauth2, for the rule to be trusted.
+func claimAttribute(name string) func(r *http.Request) []string {
+	return func(r *http.Request) []string {
+		parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+		if len(parts) != 2 || !strings.EqualFold(parts[0], "bearer") {
+			return nil
+		}
+
+		claims := jwtBase.MapClaims{}
+		if _, _, err := new(jwtBase.Parser).ParseUnverified(parts[1], claims); err != nil {
+			return nil
+		}
+
+		switch claim := claims[name].(type) {
+		case nil:
+			return nil
+		case string:
+			return []string{claim}
+		case []interface{}:
+			values := make([]string, 0, len(claim))
+			for _, value := range claim {
+				values = append(values, fmt.Sprint(value))
+			}
+			return values
+		default:
+			return []string{fmt.Sprint(claim)}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package abtest
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/hellofresh/janus/pkg/plugin"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultCookieName is the name of the cookie holding the variant when none is set
+	DefaultCookieName = "janus_variant"
+	// DefaultHeader is the upstream request header holding the variant when none is set
+	DefaultHeader = "X-Variant"
+	// DefaultCookieTTL is the lifetime of the variant cookie when none is set
+	DefaultCookieTTL = 30 * 24 * time.Hour
+)
+
+var variantName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Variant is one of the buckets of the experiment
+type Variant struct {
+	Name    string        `json:"name"`
+	Weight  int           `json:"weight"`
+	Targets proxy.Targets `json:"targets"`
+}
+
+// Config represents the A/B Test configuration
+type Config struct {
+	CookieName string         `json:"cookie_name"`
+	CookieTTL  proxy.Duration `json:"cookie_ttl"`
+	Header     string         `json:"header"`
+	Variants   []Variant      `json:"variants"`
+	Rules      []Rule         `json:"rules"`
+}
+
+func init() {
+	plugin.RegisterPlugin("ab_test", plugin.Plugin{
+		Action:   setupABTest,
+		Validate: validateConfig,
+	})
+}
+
+func setupABTest(def *proxy.RouterDefinition, rawConfig plugin.Config) error {
+	config, err := decodeConfig(rawConfig)
+	if err != nil {
+		return err
+	}
+
+	abTest, err := NewABTest(config)
+	if err != nil {
+		return err
+	}
+
+	def.AddMiddleware(abTest.Handler)
+	return nil
+}
+
+func validateConfig(rawConfig plugin.Config) (bool, error) {
+	_, err := decodeConfig(rawConfig)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func decodeConfig(rawConfig plugin.Config) (Config, error) {
+	config := Config{
+		CookieName: DefaultCookieName,
+		CookieTTL:  proxy.Duration(DefaultCookieTTL),
+		Header:     DefaultHeader,
+	}
+	if err := plugin.Decode(rawConfig, &config); err != nil {
+		return config, err
+	}
+
+	if !variantName.MatchString(config.CookieName) {
+		return config, errors.Errorf("invalid A/B test cookie name %q", config.CookieName)
+	}
+	if config.CookieTTL < 0 {
+		return config, errors.New("A/B test cookie TTL can not be negative")
+	}
+	if len(config.Variants) == 0 {
+		return config, errors.New("A/B test requires at least one variant")
+	}
+
+	names := make(map[string]bool, len(config.Variants))
+	total := 0
+	for _, variant := range config.Variants {
+		if !variantName.MatchString(variant.Name) {
+			return config, errors.Errorf("invalid A/B test variant name %q", variant.Name)
+		}
+		if names[variant.Name] {
+			return config, errors.Errorf("duplicate A/B test variant %q", variant.Name)
+		}
+		names[variant.Name] = true
+
+		if variant.Weight < 0 {
+			return config, errors.Errorf("weight of the A/B test variant %q can not be negative", variant.Name)
+		}
+		total += variant.Weight
+
+		for _, target := range variant.Targets {
+			if _, err := govalidator.ValidateStruct(target); err != nil {
+				return config, errors.Wrapf(err, "invalid target of the A/B test variant %q", variant.Name)
+			}
+		}
+	}
+
+	if total == 0 {
+		return config, errors.New("A/B test requires at least one variant with a positive weight")
+	}
+
+	if _, err := compileRules(config.Rules, config.Variants); err != nil {
+		return config, err
+	}
+
+	return config, nil
+}
@@ -0,0 +1,121 @@
+package abtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hellofresh/janus/pkg/plugin"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetup(t *testing.T) {
+	def := proxy.NewRouterDefinition(proxy.NewDefinition())
+	err := setupABTest(def, plugin.Config{
+		"variants": []interface{}{
+			map[string]interface{}{"name": "control", "weight": 90},
+			map[string]interface{}{"name": "new-ui", "weight": 10, "targets": []interface{}{
+				map[string]interface{}{"target": "http://new-ui.example.com"},
+			}},
+		},
+	})
+	assert.NoError(t, err)
+
+	assert.Len(t, def.Middleware(), 1)
+}
+
+func TestDecodeConfigDefaults(t *testing.T) {
+	config, err := decodeConfig(plugin.Config{
+		"variants": []interface{}{map[string]interface{}{"name": "control", "weight": 1}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, DefaultCookieName, config.CookieName)
+	assert.Equal(t, DefaultHeader, config.Header)
+	assert.Equal(t, proxy.Duration(DefaultCookieTTL), config.CookieTTL)
+
+	config, err = decodeConfig(plugin.Config{
+		"cookie_name": "experiment",
+		"cookie_ttl":  "1h",
+		"header":      "X-Experiment",
+		"variants":    []interface{}{map[string]interface{}{"name": "control", "weight": 1}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "experiment", config.CookieName)
+	assert.Equal(t, "X-Experiment", config.Header)
+	assert.Equal(t, proxy.Duration(time.Hour), config.CookieTTL)
+}
+
+func TestValidateConfig(t *testing.T) {
+	valid, err := validateConfig(plugin.Config{
+		"variants": []interface{}{map[string]interface{}{"name": "control", "weight": 1}},
+	})
+	assert.NoError(t, err)
+	assert.True(t, valid)
+
+	valid, err = validateConfig(plugin.Config{
+		"variants": []interface{}{
+			map[string]interface{}{"name": "control", "weight": 1},
+			map[string]interface{}{"name": "canary", "weight": 0},
+		},
+		"rules": []interface{}{map[string]interface{}{"claim": "email", "pattern": `@hellofresh\.com$`, "variant": "canary"}},
+	})
+	assert.NoError(t, err)
+	assert.True(t, valid)
+
+	invalid := map[string]plugin.Config{
+		"no variants": {},
+		"cookie name": {
+			"cookie_name": "bad name",
+			"variants":    []interface{}{map[string]interface{}{"name": "control", "weight": 1}},
+		},
+		"variant name": {
+			"variants": []interface{}{map[string]interface{}{"name": "a;b", "weight": 1}},
+		},
+		"duplicate": {
+			"variants": []interface{}{
+				map[string]interface{}{"name": "control", "weight": 1},
+				map[string]interface{}{"name": "control", "weight": 1},
+			},
+		},
+		"negative weight": {
+			"variants": []interface{}{
+				map[string]interface{}{"name": "control", "weight": 2},
+				map[string]interface{}{"name": "new-ui", "weight": -1},
+			},
+		},
+		"zero weights": {
+			"variants": []interface{}{map[string]interface{}{"name": "control"}},
+		},
+		"rule attribute": {
+			"variants": []interface{}{map[string]interface{}{"name": "control", "weight": 1}},
+			"rules":    []interface{}{map[string]interface{}{"header": "X-Canary", "claim": "email", "values": []interface{}{"1"}, "variant": "control"}},
+		},
+		"rule values": {
+			"variants": []interface{}{map[string]interface{}{"name": "control", "weight": 1}},
+			"rules":    []interface{}{map[string]interface{}{"header": "X-Canary", "variant": "control"}},
+		},
+		"rule pattern": {
+			"variants": []interface{}{map[string]interface{}{"name": "control", "weight": 1}},
+			"rules":    []interface{}{map[string]interface{}{"claim": "email", "pattern": "(", "variant": "control"}},
+		},
+		"rule variant": {
+			"variants": []interface{}{map[string]interface{}{"name": "control", "weight": 1}},
+			"rules":    []interface{}{map[string]interface{}{"header": "X-Canary", "values": []interface{}{"1"}, "variant": "canary"}},
+		},
+		"target": {
+			"variants": []interface{}{map[string]interface{}{"name": "control", "weight": 1, "targets": []interface{}{
+				map[string]interface{}{"target": ""},
+			}}},
+		},
+	}
+
+	for name, config := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := validateConfig(config)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -5,47 +5,44 @@ import (
 	"net/http"
 
 	"github.com/hellofresh/janus/pkg/errors"
+	"github.com/hellofresh/janus/pkg/middleware"
+	"github.com/hellofresh/janus/pkg/plugin"
 	log "github.com/sirupsen/logrus"
 )
 
 // NewBasicAuth is a HTTP basic auth middleware
 func NewBasicAuth(repo Repository) func(http.Handler) http.Handler {
-	return func(handler http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			log.Debug("Starting basic auth middleware")
-			logger := log.WithFields(log.Fields{
-				"path":   r.RequestURI,
-				"origin": r.RemoteAddr,
-			})
-
-			username, password, authOK := r.BasicAuth()
-			if !authOK {
-				errors.Handler(w, ErrNotAuthorized)
-				return
-			}
-
-			var found bool
-			users, err := repo.FindAll()
-			if err != nil {
-				log.WithError(err).Error("Error when getting all users")
-				errors.Handler(w, errors.New(http.StatusInternalServerError, "there was an error when looking for users"))
-				return
-			}
+	return plugin.ShortCircuit(func(r *http.Request) (*http.Request, *plugin.Result) {
+		log.Debug("Starting basic auth middleware")
+		logger := middleware.ContextLogger(r.Context()).WithFields(log.Fields{
+			"path":   r.RequestURI,
+			"origin": r.RemoteAddr,
+		})
 
-			for _, u := range users {
-				if username == u.Username && (subtle.ConstantTimeCompare([]byte(password), []byte(u.Password)) == 1) {
-					found = true
-					break
-				}
+		username, password, authOK := r.BasicAuth()
+		if !authOK {
+			return r, plugin.ErrorResult(ErrNotAuthorized, "unauthorized")
+		}
+
+		var found bool
+		users, err := repo.FindAll()
+		if err != nil {
+			log.WithError(err).Error("Error when getting all users")
+			return r, plugin.ErrorResult(errors.New(http.StatusInternalServerError, "there was an error when looking for users"), "users_unavailable")
+		}
+
+		for _, u := range users {
+			if username == u.Username && (subtle.ConstantTimeCompare([]byte(password), []byte(u.Password)) == 1) {
+				found = true
+				break
 			}
+		}
 
-			if !found {
-				logger.Debug("Invalid user/password provided.")
-				errors.Handler(w, ErrNotAuthorized)
-				return
-			}
+		if !found {
+			logger.Debug("Invalid user/password provided.")
+			return r, plugin.ErrorResult(ErrNotAuthorized, "unauthorized")
+		}
 
-			handler.ServeHTTP(w, r)
-		})
-	}
+		return r.WithContext(middleware.WithConsumer(r.Context(), username)), nil
+	})
 }
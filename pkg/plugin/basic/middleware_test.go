@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/hellofresh/janus/pkg/middleware"
 	"github.com/hellofresh/janus/pkg/test"
 	"github.com/stretchr/testify/assert"
 )
@@ -12,6 +13,7 @@ import (
 func TestAuthorizedAccess(t *testing.T) {
 	mw := NewBasicAuth(setupRepo())
 
+	var consumer string
 	w, err := test.Record(
 		"GET",
 		"/",
@@ -19,11 +21,15 @@ func TestAuthorizedAccess(t *testing.T) {
 			"Content-Type":  "application/json",
 			"Authorization": "Basic " + basicAuth("test", "test"),
 		},
-		mw(http.HandlerFunc(test.Ping)),
+		mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			consumer = middleware.ConsumerFromContext(r.Context())
+			test.Ping(w, r)
+		})),
 	)
 	assert.NoError(t, err)
 
 	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "test", consumer)
 	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
 }
 
@@ -3,6 +3,8 @@ package basic
 import (
 	"errors"
 
+	"github.com/hellofresh/janus/pkg/config"
+	"github.com/hellofresh/janus/pkg/jwt"
 	"github.com/hellofresh/janus/pkg/plugin"
 	"github.com/hellofresh/janus/pkg/proxy"
 	"github.com/hellofresh/janus/pkg/router"
@@ -62,8 +64,16 @@ func onStartup(event interface{}) error {
 		return err
 	}
 
+	loadCredentialEndpoints(adminRouter, repo, e.Config.Web.Credentials)
+	return nil
+}
+
+// loadCredentialEndpoints registers the admin endpoints of the credentials behind the admin API authentication
+func loadCredentialEndpoints(router router.Router, repo Repository, cred config.Credentials) {
+	guard := jwt.NewGuard(cred)
 	handlers := NewHandler(repo)
-	group := adminRouter.Group("/credentials/basic_auth")
+	group := router.Group("/credentials/basic_auth")
+	group.Use(jwt.NewMiddleware(guard).Handler, jwt.NewRBACMiddleware(cred.RBAC).Handler)
 	{
 		group.GET("/", handlers.Index())
 		group.POST("/", handlers.Create())
@@ -71,6 +81,4 @@ func onStartup(event interface{}) error {
 		group.PUT("/{username}", handlers.Update())
 		group.DELETE("/{username}", handlers.Delete())
 	}
-
-	return nil
 }
@@ -1,13 +1,18 @@
 package basic
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/globalsign/mgo"
 
+	"github.com/hellofresh/janus/pkg/config"
 	"github.com/hellofresh/janus/pkg/plugin"
 	"github.com/hellofresh/janus/pkg/proxy"
 	"github.com/hellofresh/janus/pkg/router"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -18,7 +23,7 @@ func TestSetup(t *testing.T) {
 	err := onAdminAPIStartup(event1)
 	require.NoError(t, err)
 
-	event2 := plugin.OnStartup{Register: proxy.NewRegister(proxy.WithRouter(router.NewChiRouter())), MongoSession: &mgo.Session{}}
+	event2 := plugin.OnStartup{Register: proxy.NewRegister(proxy.WithRouter(router.NewChiRouter())), MongoSession: &mgo.Session{}, Config: &config.Specification{}}
 	err = onStartup(event2)
 	require.NoError(t, err)
 
@@ -26,6 +31,21 @@ func TestSetup(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestCredentialEndpointsUnauthorized(t *testing.T) {
+	r := router.NewChiRouter()
+	repo := NewInMemoryRepository()
+	loadCredentialEndpoints(r, repo, config.Credentials{Algorithm: "HS256", Secret: "secret"})
+
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, "/credentials/basic_auth/", strings.NewReader(`{"username": "lanister", "password": "pay-your-debt"}`)))
+		assert.Equal(t, http.StatusUnauthorized, w.Code, method)
+	}
+	users, err := repo.FindAll()
+	require.NoError(t, err)
+	assert.Empty(t, users, "the credentials are not changed without a token")
+}
+
 func TestOnStartupMissingMongoSession(t *testing.T) {
 	event := plugin.OnStartup{Register: proxy.NewRegister(proxy.WithRouter(router.NewChiRouter()))}
 	err := onStartup(event)
@@ -1,20 +1,74 @@
 package cb
 
 import (
+	"context"
 	"net/http"
+	"sync"
+	"sync/atomic"
 
 	"github.com/Knetic/govaluate"
 	"github.com/afex/hystrix-go/hystrix"
 	"github.com/felixge/httpsnoop"
 	janusErr "github.com/hellofresh/janus/pkg/errors"
+	obs "github.com/hellofresh/janus/pkg/observability"
+	"github.com/hellofresh/janus/pkg/upstream"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
 )
 
 const (
 	defaultPredicate = "statusCode == 0 || statusCode >= 500"
 )
 
+// Circuit states
+const (
+	StateClosed   = upstream.CircuitClosed
+	StateOpen     = upstream.CircuitOpen
+	StateHalfOpen = upstream.CircuitHalfOpen
+)
+
+var (
+	// ErrServiceUnavailable is thrown when the request is short-circuited and no fallback status is set
+	ErrServiceUnavailable = janusErr.New(http.StatusServiceUnavailable, "service is temporarily unavailable")
+
+	// do runs the command with the circuit breaker, it is replaced in the tests
+	do = func(name string, run func() error, fallback func(error) error) error {
+		return hystrix.Do(name, run, fallback)
+	}
+	// circuitOpen tells if the circuit is open, it is replaced in the tests
+	circuitOpen = func(name string) bool {
+		circuit, _, err := hystrix.GetCircuit(name)
+		return err == nil && circuit.IsOpen()
+	}
+
+	states = &circuitStates{states: make(map[string]string)}
+	// upstreamStates exposes the circuit states with the upstream states, it is set on startup
+	upstreamStates *upstream.States
+)
+
+// Fallback is the response of the short-circuited requests
+type Fallback struct {
+	StatusCode int               `json:"status_code"`
+	Body       string            `json:"body"`
+	Headers    map[string]string `json:"headers"`
+}
+
+// ServeHTTP writes the fallback response
+func (f Fallback) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.StatusCode == 0 {
+		janusErr.Handler(w, ErrServiceUnavailable)
+		return
+	}
+
+	for name, value := range f.Headers {
+		w.Header().Set(name, value)
+	}
+	w.WriteHeader(f.StatusCode)
+	w.Write([]byte(f.Body))
+}
+
 // NewCBMiddleware creates a new cb middleware
 func NewCBMiddleware(cfg Config) func(http.Handler) http.Handler {
 	return func(handler http.Handler) http.Handler {
@@ -38,7 +92,22 @@ func NewCBMiddleware(cfg Config) func(http.Handler) http.Handler {
 				return
 			}
 
-			err = hystrix.Do(cfg.Name, func() error {
+			// claimed is set by the command when it starts, or by the fallback when the command did not
+			// start, so only one of them writes the response
+			var claimed int32
+			done := make(chan struct{})
+			wasOpen := circuitOpen(cfg.Name)
+
+			err = do(cfg.Name, func() error {
+				defer close(done)
+				if !atomic.CompareAndSwapInt32(&claimed, 0, 1) {
+					return nil
+				}
+				if wasOpen {
+					// the circuit lets a request through to probe the upstream once the sleep window elapsed
+					states.transition(r.Context(), cfg.Name, StateHalfOpen)
+				}
+
 				m := httpsnoop.CaptureMetrics(handler, w, r)
 				params := make(map[string]interface{}, 8)
 				params["statusCode"] = m.Code
@@ -56,10 +125,59 @@ func NewCBMiddleware(cfg Config) func(http.Handler) http.Handler {
 				return nil
 			}, nil)
 
-			if err != nil {
-				logger.WithError(err).Error("Request failed on the cb middleware")
-				janusErr.Handler(w, errors.Wrap(err, "request failed"))
+			if circuitOpen(cfg.Name) {
+				states.transition(r.Context(), cfg.Name, StateOpen)
+			} else {
+				states.transition(r.Context(), cfg.Name, StateClosed)
+			}
+
+			if err == nil {
+				return
+			}
+
+			if atomic.CompareAndSwapInt32(&claimed, 0, 2) {
+				logger.WithError(err).Warn("Request short-circuited on the cb middleware")
+				cfg.Fallback.ServeHTTP(w, r)
+				return
 			}
+
+			// the upstream response is written by the command, a timed out command is waited for so it
+			// does not write the response once the handler returned
+			<-done
+			logger.WithError(err).Error("Request failed on the cb middleware")
 		})
 	}
 }
+
+// circuitStates keeps the last known state of the circuits to record their changes
+type circuitStates struct {
+	sync.Mutex
+	states map[string]string
+}
+
+func (s *circuitStates) transition(ctx context.Context, name string, state string) {
+	s.Lock()
+	previous, seen := s.states[name]
+	if !seen {
+		previous = StateClosed
+	}
+	s.states[name] = state
+	s.Unlock()
+
+	if seen && previous == state {
+		return
+	}
+
+	ctx, _ = tag.New(ctx, tag.Upsert(obs.KeyCircuitName, name), tag.Upsert(obs.KeyCircuitState, state))
+	if upstreamStates != nil {
+		upstreamStates.SetCircuit(ctx, state)
+	}
+
+	// the circuits start closed, so the first state seen is only a change when it is not closed
+	if previous == state {
+		return
+	}
+
+	log.WithFields(log.Fields{"name": name, "from": previous, "to": state}).Info("Circuit breaker state changed")
+	stats.Record(ctx, obs.MCircuitTransitions.M(1))
+}
@@ -1,12 +1,21 @@
 package cb
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/afex/hystrix-go/hystrix"
+	"github.com/hellofresh/janus/pkg/api"
+	obs "github.com/hellofresh/janus/pkg/observability"
+	"github.com/hellofresh/janus/pkg/proxy"
 	"github.com/hellofresh/janus/pkg/test"
+	"github.com/hellofresh/janus/pkg/upstream"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
 )
 
 func TestMiddleware(t *testing.T) {
@@ -66,3 +75,75 @@ func testFailedUpstreamRetry(t *testing.T, r *http.Request, w *httptest.Response
 
 	assert.Equal(t, http.StatusBadGateway, w.Code)
 }
+
+// withCircuit replaces the hystrix command runner and circuit state, it returns the function restoring them
+func withCircuit(fakeDo func(string, func() error, func(error) error) error, open func(string) bool) func() {
+	originalDo, originalOpen := do, circuitOpen
+	do, circuitOpen = fakeDo, open
+
+	return func() { do, circuitOpen = originalDo, originalOpen }
+}
+
+func TestMiddlewareFallback(t *testing.T) {
+	defer withCircuit(func(string, func() error, func(error) error) error {
+		return hystrix.ErrCircuitOpen
+	}, func(string) bool { return true })()
+
+	w := httptest.NewRecorder()
+	NewCBMiddleware(Config{Name: "fallback"})(http.HandlerFunc(test.Ping)).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	w = httptest.NewRecorder()
+	mw := NewCBMiddleware(Config{Name: "fallback", Fallback: Fallback{
+		StatusCode: http.StatusOK,
+		Body:       `{"items":[]}`,
+		Headers:    map[string]string{"Content-Type": "application/json"},
+	}})
+	mw(http.HandlerFunc(test.Ping)).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"items":[]}`, w.Body.String())
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+}
+
+func TestMiddlewareStateTransitions(t *testing.T) {
+	require.NoError(t, view.Register(obs.AllViews...))
+	defer view.Unregister(obs.AllViews...)
+
+	// the circuit trips on the first request, and closes once the probe of the second one succeeds
+	open := []bool{false, true, true, false}
+	defer withCircuit(func(name string, run func() error, fallback func(error) error) error {
+		return run()
+	}, func(string) bool {
+		state := open[0]
+		open = open[1:]
+		return state
+	})()
+
+	upstreamStates = upstream.NewStates()
+	defer func() { upstreamStates = nil }()
+
+	mw := NewCBMiddleware(Config{Name: "transitions"})
+	ctx, _ := tag.New(context.Background(), tag.Upsert(obs.KeyAPIName, "transitions"))
+	for i := 0; i < 2; i++ {
+		mw(http.HandlerFunc(test.Ping)).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+	}
+
+	def := api.NewDefinition()
+	def.Name = "transitions"
+	def.Proxy.Upstreams.Targets = proxy.Targets{{Target: "http://upstream"}}
+	assert.Equal(t, StateClosed, upstreamStates.List([]*api.Definition{def})[0].Circuit, "the circuit state is exposed")
+
+	rows, err := view.RetrieveData("plugin_cb_state_transition_total")
+	require.NoError(t, err)
+
+	transitions := make(map[string]int64)
+	for _, row := range rows {
+		for _, tg := range row.Tags {
+			if tg.Key == obs.KeyCircuitState {
+				transitions[tg.Value] = row.Data.(*view.CountData).Value
+			}
+		}
+	}
+	assert.Equal(t, map[string]int64{StateOpen: 1, StateHalfOpen: 1, StateClosed: 1}, transitions)
+}
@@ -19,8 +19,9 @@ const (
 // Config represents the Body Limit configuration
 type Config struct {
 	hystrix.CommandConfig
-	Name      string `json:"name"`
-	Predicate string `json:"predicate"`
+	Name      string   `json:"name"`
+	Predicate string   `json:"predicate"`
+	Fallback  Fallback `json:"fallback"`
 }
 
 func init() {
@@ -29,6 +30,7 @@ func init() {
 	plugin.RegisterPlugin(pluginName, plugin.Plugin{
 		Action:   setupCB,
 		Validate: validateConfig,
+		Stateful: true,
 	})
 }
 
@@ -46,10 +48,11 @@ func setupCB(def *proxy.RouterDefinition, rawConfig plugin.Config) error {
 	}).Debug("Configuring cb plugin")
 
 	hystrix.ConfigureCommand(c.Name, hystrix.CommandConfig{
-		Timeout:               c.Timeout,
-		MaxConcurrentRequests: c.MaxConcurrentRequests,
-		ErrorPercentThreshold: c.ErrorPercentThreshold,
-		SleepWindow:           c.SleepWindow,
+		Timeout:                c.Timeout,
+		MaxConcurrentRequests:  c.MaxConcurrentRequests,
+		ErrorPercentThreshold:  c.ErrorPercentThreshold,
+		RequestVolumeThreshold: c.RequestVolumeThreshold,
+		SleepWindow:            c.SleepWindow,
 	})
 
 	def.AddMiddleware(NewCBMiddleware(c))
@@ -63,6 +66,10 @@ func validateConfig(rawConfig plugin.Config) (bool, error) {
 		return false, err
 	}
 
+	if code := config.Fallback.StatusCode; code != 0 && (code < 100 || code > 599) {
+		return false, errors.Errorf("invalid fallback status code %d", code)
+	}
+
 	return govalidator.ValidateStruct(config)
 }
 
@@ -95,6 +102,7 @@ func onStartup(event interface{}) error {
 	if !ok {
 		return errors.New("Could not convert event to startup type")
 	}
+	upstreamStates = e.Upstreams
 
 	logger.WithField("metrics_dsn", e.Config.Stats.DSN).Debug("Statsd metrics enabled")
 	c, err := plugins.InitializeStatsdCollector(&plugins.StatsdCollectorConfig{
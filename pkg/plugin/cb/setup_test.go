@@ -26,6 +26,10 @@ func TestCbPlugin(t *testing.T) {
 			scenario: "when an incorrect cb configuration is given",
 			function: testSetupWithIncorrectConfig,
 		},
+		{
+			scenario: "when an invalid fallback status code is given",
+			function: testSetupWithInvalidFallback,
+		},
 		{
 			scenario: "when the plugin setup is successful",
 			function: testSetupSuccess,
@@ -109,3 +113,13 @@ func testSetupWithIncorrectConfig(t *testing.T) {
 	assert.False(t, result)
 	require.Error(t, err)
 }
+
+func testSetupWithInvalidFallback(t *testing.T) {
+	rawConfig := map[string]interface{}{
+		"fallback": map[string]interface{}{"status_code": 1000},
+	}
+
+	result, err := validateConfig(rawConfig)
+	assert.False(t, result)
+	require.Error(t, err)
+}
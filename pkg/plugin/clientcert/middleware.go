@@ -0,0 +1,125 @@
+package clientcert
+
+import (
+	"crypto/x509"
+	"net/http"
+
+	"github.com/hellofresh/janus/pkg/errors"
+	"github.com/hellofresh/janus/pkg/middleware"
+	"github.com/hellofresh/janus/pkg/plugin"
+)
+
+var (
+	// ErrCertificateRequired is thrown when the request has no client certificate
+	ErrCertificateRequired = errors.New(http.StatusUnauthorized, "client certificate is required")
+	// ErrCertificateNotAllowed is thrown when the subject and the SANs of the client certificate are not allowed
+	ErrCertificateNotAllowed = errors.New(http.StatusUnauthorized, "client certificate subject is not allowed")
+)
+
+// ClientCert authenticates the requests with the client certificate of their TLS connection. The
+// certificate is verified against the configured certificate authorities, and its subject is the
+// consumer of the request for the next plugins, e.g. the rate limit by consumer.
+type ClientCert struct {
+	roots    *x509.CertPool
+	subjects map[string]bool
+	sans     map[string]bool
+}
+
+// NewClientCert creates a new instance of ClientCert
+func NewClientCert(config Config) (*ClientCert, error) {
+	roots, err := loadCAs(config.CAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ClientCert{roots: roots, subjects: set(config.AllowedSubjects), sans: set(config.AllowedSANs)}, nil
+}
+
+// Handler is the middleware function
+func (c *ClientCert) Handler(handler http.Handler) http.Handler {
+	return plugin.ShortCircuit(c.authenticate)(handler)
+}
+
+// authenticate sets the subject of the verified client certificate as the consumer of the request
+func (c *ClientCert) authenticate(r *http.Request) (*http.Request, *plugin.Result) {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return r, plugin.ErrorResult(ErrCertificateRequired, "unauthorized")
+	}
+
+	cert := r.TLS.PeerCertificates[0]
+	if err := c.verify(r.TLS.PeerCertificates); err != nil {
+		middleware.ContextLogger(r.Context()).WithError(err).WithField("subject", cert.Subject.String()).Debug("Invalid client certificate")
+		return r, plugin.ErrorResult(errors.New(http.StatusUnauthorized, "client certificate is not valid: "+err.Error()), "unauthorized")
+	}
+
+	if !c.allowed(cert) {
+		middleware.ContextLogger(r.Context()).WithField("subject", cert.Subject.String()).Debug("Client certificate is not allowed")
+		return r, plugin.ErrorResult(ErrCertificateNotAllowed, "unauthorized")
+	}
+
+	return r.WithContext(middleware.WithConsumer(r.Context(), Subject(cert))), nil
+}
+
+// verify verifies the certificate chain sent by the client, the certificates following the client
+// certificate are the intermediates
+func (c *ClientCert) verify(chain []*x509.Certificate) error {
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := chain[0].Verify(x509.VerifyOptions{
+		Roots:         c.roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	return err
+}
+
+// allowed tells if the subject or one of the SANs of the certificate is allowed, all the certificates
+// are allowed when no subject nor SAN is configured
+func (c *ClientCert) allowed(cert *x509.Certificate) bool {
+	if len(c.subjects) == 0 && len(c.sans) == 0 {
+		return true
+	}
+
+	if c.subjects[cert.Subject.CommonName] || c.subjects[cert.Subject.String()] {
+		return true
+	}
+
+	var sans []string
+	sans = append(sans, cert.DNSNames...)
+	sans = append(sans, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+	for _, san := range sans {
+		if c.sans[san] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Subject returns the identity of the client certificate, its common name or its distinguished name
+// without one
+func Subject(cert *x509.Certificate) string {
+	if cert.Subject.CommonName != "" {
+		return cert.Subject.CommonName
+	}
+
+	return cert.Subject.String()
+}
+
+func set(values []string) map[string]bool {
+	s := make(map[string]bool, len(values))
+	for _, v := range values {
+		s[v] = true
+	}
+
+	return s
+}
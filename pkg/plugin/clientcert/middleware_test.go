@@ -0,0 +1,154 @@
+package clientcert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hellofresh/janus/pkg/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type authority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newAuthority creates a self signed certificate authority
+func newAuthority(t *testing.T, commonName string) *authority {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &authority{cert: cert, key: key}
+}
+
+// write writes the certificate of the authority to a PEM file of the directory
+func (a *authority) write(t *testing.T, dir string) string {
+	file := filepath.Join(dir, "ca.crt")
+	require.NoError(t, ioutil.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: a.cert.Raw}), 0600))
+
+	return file
+}
+
+// issue issues a client certificate of the subject, valid until notAfter
+func (a *authority) issue(t *testing.T, subject pkix.Name, dnsNames []string, notAfter time.Time) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      subject,
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, a.cert, &key.PublicKey, a.key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return cert
+}
+
+func serve(t *testing.T, clientCert *ClientCert, certs ...*x509.Certificate) (*httptest.ResponseRecorder, string) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if certs != nil {
+		r.TLS = &tls.ConnectionState{PeerCertificates: certs}
+	}
+
+	var consumer string
+	w := httptest.NewRecorder()
+	clientCert.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		consumer = middleware.ConsumerFromContext(r.Context())
+	})).ServeHTTP(w, r)
+
+	return w, consumer
+}
+
+func TestHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clientcert")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ca := newAuthority(t, "partners")
+	clientCert, err := NewClientCert(Config{CAFile: ca.write(t, dir)})
+	require.NoError(t, err)
+
+	w, consumer := serve(t, clientCert, ca.issue(t, pkix.Name{CommonName: "acme"}, nil, time.Now().Add(time.Hour)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "acme", consumer, "the subject is the consumer")
+
+	w, _ = serve(t, clientCert)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "client certificate is required")
+
+	w, _ = serve(t, clientCert, newAuthority(t, "other").issue(t, pkix.Name{CommonName: "acme"}, nil, time.Now().Add(time.Hour)))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "client certificate is not valid")
+
+	w, _ = serve(t, clientCert, ca.issue(t, pkix.Name{CommonName: "acme"}, nil, time.Now().Add(-time.Minute)))
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "the expired certificates are not valid")
+	assert.Contains(t, w.Body.String(), "expired")
+}
+
+func TestHandlerAllowlist(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clientcert")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ca := newAuthority(t, "partners")
+	clientCert, err := NewClientCert(Config{
+		CAFile:          ca.write(t, dir),
+		AllowedSubjects: []string{"acme", "CN=globex,O=Globex"},
+		AllowedSANs:     []string{"initech.example.com"},
+	})
+	require.NoError(t, err)
+
+	allowed := map[string]*x509.Certificate{
+		"common name":        ca.issue(t, pkix.Name{CommonName: "acme", Organization: []string{"ACME"}}, nil, time.Now().Add(time.Hour)),
+		"distinguished name": ca.issue(t, pkix.Name{CommonName: "globex", Organization: []string{"Globex"}}, nil, time.Now().Add(time.Hour)),
+		"SAN":                ca.issue(t, pkix.Name{CommonName: "initech"}, []string{"initech.example.com"}, time.Now().Add(time.Hour)),
+	}
+	for name, cert := range allowed {
+		w, _ := serve(t, clientCert, cert)
+		assert.Equal(t, http.StatusOK, w.Code, name)
+	}
+
+	w, _ := serve(t, clientCert, ca.issue(t, pkix.Name{CommonName: "umbrella"}, []string{"umbrella.example.com"}, time.Now().Add(time.Hour)))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "client certificate subject is not allowed")
+}
+
+func TestSubject(t *testing.T) {
+	assert.Equal(t, "acme", Subject(&x509.Certificate{Subject: pkix.Name{CommonName: "acme"}}))
+	assert.Equal(t, "O=ACME", Subject(&x509.Certificate{Subject: pkix.Name{Organization: []string{"ACME"}}}))
+}
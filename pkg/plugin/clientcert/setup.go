@@ -0,0 +1,82 @@
+package clientcert
+
+import (
+	"crypto/x509"
+	"io/ioutil"
+
+	"github.com/hellofresh/janus/pkg/plugin"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/pkg/errors"
+)
+
+// Config represents the client certificate authentication configuration
+type Config struct {
+	// CAFile is the PEM file of the certificate authorities the client certificates are verified against
+	CAFile string `json:"ca_file"`
+	// AllowedSubjects are the common names or the distinguished names of the allowed certificates
+	AllowedSubjects []string `json:"allowed_subjects"`
+	// AllowedSANs are the DNS names, email addresses, IP addresses and URIs of the allowed certificates
+	AllowedSANs []string `json:"allowed_sans"`
+}
+
+func init() {
+	plugin.RegisterPlugin("client_cert", plugin.Plugin{
+		Action:   setupClientCert,
+		Validate: validateConfig,
+	})
+}
+
+func setupClientCert(def *proxy.RouterDefinition, rawConfig plugin.Config) error {
+	config, err := decodeConfig(rawConfig)
+	if err != nil {
+		return err
+	}
+
+	clientCert, err := NewClientCert(config)
+	if err != nil {
+		return err
+	}
+
+	def.AddMiddleware(clientCert.Handler)
+	return nil
+}
+
+func validateConfig(rawConfig plugin.Config) (bool, error) {
+	config, err := decodeConfig(rawConfig)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := loadCAs(config.CAFile); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func decodeConfig(rawConfig plugin.Config) (Config, error) {
+	var config Config
+	if err := plugin.Decode(rawConfig, &config); err != nil {
+		return config, err
+	}
+
+	if config.CAFile == "" {
+		return config, errors.New("client certificate CA file is not set")
+	}
+
+	return config, nil
+}
+
+func loadCAs(file string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read the client certificate CA file")
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, errors.Errorf("no certificate found in the client certificate CA file %q", file)
+	}
+
+	return roots, nil
+}
@@ -0,0 +1,50 @@
+package clientcert
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hellofresh/janus/pkg/plugin"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clientcert")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	def := proxy.NewRouterDefinition(proxy.NewDefinition())
+	err = setupClientCert(def, plugin.Config{
+		"ca_file":          newAuthority(t, "partners").write(t, dir),
+		"allowed_subjects": []string{"acme"},
+	})
+	require.NoError(t, err)
+	assert.Len(t, def.Middleware(), 1)
+}
+
+func TestValidateConfigInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clientcert")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	notPEM := filepath.Join(dir, "ca.crt")
+	require.NoError(t, ioutil.WriteFile(notPEM, []byte("not a certificate"), 0600))
+
+	invalid := map[string]plugin.Config{
+		"no CA file":      {},
+		"missing CA file": {"ca_file": filepath.Join(dir, "missing.crt")},
+		"not a PEM file":  {"ca_file": notPEM},
+	}
+
+	for name, config := range invalid {
+		t.Run(name, func(t *testing.T) {
+			isValid, err := validateConfig(config)
+			assert.False(t, isValid)
+			assert.Error(t, err)
+		})
+	}
+}
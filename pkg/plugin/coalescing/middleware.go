@@ -0,0 +1,132 @@
+package coalescing
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/hellofresh/janus/pkg/middleware"
+	"github.com/hellofresh/janus/pkg/plugin"
+	"github.com/hellofresh/janus/pkg/proxy"
+	log "github.com/sirupsen/logrus"
+)
+
+// CoalescedHeader is set on the responses shared with the waiting requests
+const CoalescedHeader = "X-Coalesced"
+
+// response is the response of a coalesced call
+type response struct {
+	statusCode int
+	header     http.Header
+	body       []byte
+}
+
+// call is a request in flight to the upstream, the identical requests wait for it
+type call struct {
+	done     chan struct{}
+	response *response
+	waiters  int
+}
+
+// Coalescing collapses the concurrent identical GET and HEAD requests into a single upstream call, the
+// requests arriving while it is in flight wait for its response and get a copy of it. The requests are
+// identical when they have the same method, host, URI and key headers. The responses are not kept once
+// they are sent, so the requests arriving afterwards call the upstream again.
+type Coalescing struct {
+	keyHeaders  []string
+	maxBodySize int64
+
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// NewCoalescing creates a new instance of Coalescing
+func NewCoalescing(keyHeaders []string, maxBodySize int64) *Coalescing {
+	return &Coalescing{keyHeaders: keyHeaders, maxBodySize: maxBodySize, calls: make(map[string]*call)}
+}
+
+// Handler is the middleware function
+func (c *Coalescing) Handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// only the safe requests without a body can share a response
+		if (r.Method != http.MethodGet && r.Method != http.MethodHead) || r.ContentLength > 0 {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		key := c.key(r)
+		c.mu.Lock()
+		if inFlight, ok := c.calls[key]; ok {
+			inFlight.waiters++
+			c.mu.Unlock()
+
+			select {
+			case <-inFlight.done:
+			case <-r.Context().Done():
+				return
+			}
+
+			if inFlight.response != nil {
+				plugin.Respond(w, r, shared(inFlight.response))
+				return
+			}
+
+			middleware.ContextLogger(r.Context()).WithField("path", r.URL.Path).Debug("The coalesced response can not be shared, calling the upstream")
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		inFlight := &call{done: make(chan struct{})}
+		c.calls[key] = inFlight
+		c.mu.Unlock()
+
+		rec := proxy.NewResponseRecorder(c.maxBodySize)
+		defer func() {
+			// the response of a cancelled request, e.g. its client went away, is not the upstream one
+			if r.Context().Err() == nil {
+				inFlight.response = recorded(rec)
+			}
+
+			c.mu.Lock()
+			delete(c.calls, key)
+			waiters := inFlight.waiters
+			c.mu.Unlock()
+			close(inFlight.done)
+
+			if waiters > 0 {
+				middleware.ContextLogger(r.Context()).WithFields(log.Fields{"path": r.URL.Path, "waiters": waiters}).Debug("Requests coalesced")
+			}
+		}()
+
+		handler.ServeHTTP(rec.Wrap(w), r)
+	})
+}
+
+func (c *Coalescing) key(r *http.Request) string {
+	parts := []string{r.Method, r.Host, r.URL.RequestURI()}
+	for _, name := range c.keyHeaders {
+		parts = append(parts, strings.Join(r.Header[name], ","))
+	}
+
+	return strings.Join(parts, "\n")
+}
+
+// shared returns the result answering the waiting request with the shared response
+func shared(response *response) *plugin.Result {
+	header := make(http.Header, len(response.header)+1)
+	for name, values := range response.header {
+		header[name] = values
+	}
+	header.Set(CoalescedHeader, "true")
+
+	return &plugin.Result{StatusCode: response.statusCode, Header: header, Body: response.body, Reason: "coalesced"}
+}
+
+// recorded returns the recorded response, it returns nil when it must not be shared
+func recorded(rec *proxy.ResponseRecorder) *response {
+	if rec.StatusCode() == 0 || rec.Truncated() {
+		return nil
+	}
+
+	return &response{statusCode: rec.StatusCode(), header: rec.Header(), body: rec.Body()}
+}
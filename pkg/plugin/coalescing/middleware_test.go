@@ -0,0 +1,169 @@
+package coalescing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitForWaiters blocks until the given number of requests wait for a call in flight
+func waitForWaiters(t *testing.T, c *Coalescing, waiters int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		count := 0
+		for _, inFlight := range c.calls {
+			count += inFlight.waiters
+		}
+		c.mu.Unlock()
+
+		if count == waiters {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("%d requests are not waiting", waiters)
+}
+
+func TestConcurrentMissesCoalesced(t *testing.T) {
+	const concurrent = 10
+
+	var calls int32
+	release := make(chan struct{})
+	c := NewCoalescing(DefaultKeyHeaders, 1<<20)
+	handler := c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"hot":true}`))
+	}))
+
+	var wg sync.WaitGroup
+	recorders := make([]*httptest.ResponseRecorder, concurrent)
+	for i := range recorders {
+		recorders[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func(w *httptest.ResponseRecorder) {
+			defer wg.Done()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hot?page=1", nil))
+		}(recorders[i])
+	}
+
+	waitForWaiters(t, c, concurrent-1)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls), "the concurrent misses make a single upstream call")
+	coalesced := 0
+	for _, w := range recorders {
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.Equal(t, `{"hot":true}`, w.Body.String())
+		if w.Header().Get(CoalescedHeader) == "true" {
+			coalesced++
+		}
+	}
+	assert.Equal(t, concurrent-1, coalesced)
+	assert.Empty(t, c.calls)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/hot?page=1", nil))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls), "the responses are not kept once sent")
+}
+
+func TestDifferentRequestsNotCoalesced(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	c := NewCoalescing(DefaultKeyHeaders, 1<<20)
+	handler := c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		w.Write([]byte(r.URL.RequestURI()))
+	}))
+
+	requests := []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/hot?page=1", nil),
+		httptest.NewRequest(http.MethodGet, "/hot?page=2", nil),
+		httptest.NewRequest(http.MethodHead, "/hot?page=1", nil),
+		httptest.NewRequest(http.MethodPost, "/hot?page=1", nil),
+		httptest.NewRequest(http.MethodPost, "/hot?page=1", nil),
+		httptest.NewRequest(http.MethodGet, "/hot?page=1", strings.NewReader("body")),
+	}
+	authorized := httptest.NewRequest(http.MethodGet, "/hot?page=1", nil)
+	authorized.Header.Set("Authorization", "Bearer token")
+	requests = append(requests, authorized)
+
+	var wg sync.WaitGroup
+	for _, req := range requests {
+		wg.Add(1)
+		go func(req *http.Request) {
+			defer wg.Done()
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+		}(req)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&calls) < int32(len(requests)) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(len(requests)), atomic.LoadInt32(&calls), "only the identical safe requests are coalesced")
+}
+
+func TestCoalescedResponseTooLarge(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	c := NewCoalescing(DefaultKeyHeaders, 4)
+	handler := c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-release
+		}
+		w.Write([]byte("large body"))
+	}))
+
+	leader := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(leader, httptest.NewRequest(http.MethodGet, "/large", nil))
+		close(done)
+	}()
+	for atomic.LoadInt32(&calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	follower := httptest.NewRecorder()
+	followerDone := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(follower, httptest.NewRequest(http.MethodGet, "/large", nil))
+		close(followerDone)
+	}()
+	waitForWaiters(t, c, 1)
+	close(release)
+	<-done
+	<-followerDone
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls), "the waiting request calls the upstream itself")
+	assert.Equal(t, "large body", leader.Body.String())
+	assert.Equal(t, "large body", follower.Body.String())
+	assert.Empty(t, follower.Header().Get(CoalescedHeader))
+}
+
+func TestDecodeConfig(t *testing.T) {
+	config, maxBodySize, err := decodeConfig(map[string]interface{}{"key_headers": []string{"x-tenant"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"X-Tenant"}, config.KeyHeaders)
+	assert.Equal(t, int64(1<<20), maxBodySize)
+
+	valid, err := validateConfig(map[string]interface{}{"max_body_size": "large"})
+	assert.Error(t, err)
+	assert.False(t, valid)
+}
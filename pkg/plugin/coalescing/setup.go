@@ -0,0 +1,72 @@
+package coalescing
+
+import (
+	"net/http"
+
+	"code.cloudfoundry.org/bytefmt"
+	"github.com/hellofresh/janus/pkg/plugin"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/pkg/errors"
+)
+
+// DefaultMaxBodySize is the size of the largest response body shared with the waiting requests
+const DefaultMaxBodySize = "1MB"
+
+// DefaultKeyHeaders are the request headers telling the coalesced requests apart when none is set, so
+// the responses of a client or of a representation are not shared with the others
+var DefaultKeyHeaders = []string{"Authorization", "Cookie", "Accept", "Accept-Encoding", "Accept-Language"}
+
+// Config represents the request coalescing configuration
+type Config struct {
+	// KeyHeaders are the request headers the coalesced requests must have the same values of
+	KeyHeaders []string `json:"key_headers"`
+	// MaxBodySize is the size of the largest response body shared with the waiting requests, they call
+	// the upstream themselves when the response is larger
+	MaxBodySize string `json:"max_body_size"`
+}
+
+func init() {
+	plugin.RegisterPlugin("request_coalescing", plugin.Plugin{
+		Action:              setupCoalescing,
+		Validate:            validateConfig,
+		Stateful:            true,
+		BuffersResponseBody: true,
+	})
+}
+
+func setupCoalescing(def *proxy.RouterDefinition, rawConfig plugin.Config) error {
+	config, maxBodySize, err := decodeConfig(rawConfig)
+	if err != nil {
+		return err
+	}
+
+	def.AddMiddleware(NewCoalescing(config.KeyHeaders, maxBodySize).Handler)
+	return nil
+}
+
+func validateConfig(rawConfig plugin.Config) (bool, error) {
+	if _, _, err := decodeConfig(rawConfig); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func decodeConfig(rawConfig plugin.Config) (Config, int64, error) {
+	// the defaults are copied, the decoding reuses the slice
+	config := Config{KeyHeaders: append([]string(nil), DefaultKeyHeaders...), MaxBodySize: DefaultMaxBodySize}
+	if err := plugin.Decode(rawConfig, &config); err != nil {
+		return config, 0, err
+	}
+
+	for i, name := range config.KeyHeaders {
+		config.KeyHeaders[i] = http.CanonicalHeaderKey(name)
+	}
+
+	maxBodySize, err := bytefmt.ToBytes(config.MaxBodySize)
+	if err != nil {
+		return config, 0, errors.Wrap(err, "invalid request coalescing max body size")
+	}
+
+	return config, int64(maxBodySize), nil
+}
@@ -0,0 +1,257 @@
+package compression
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// compressedContentTypes are the content types compressed when not excluded, the images, videos and
+// archives are already compressed
+var compressedContentTypes = []string{
+	"text/html",
+	"text/css",
+	"text/plain",
+	"text/javascript",
+	"application/javascript",
+	"application/x-javascript",
+	"application/json",
+	"application/atom+xml",
+	"application/rss+xml",
+}
+
+// Compression compresses the responses of the clients accepting the gzip or deflate encodings
+type Compression struct {
+	minSize      int
+	contentTypes map[string]bool
+	gzipPool     sync.Pool
+	flatePool    sync.Pool
+}
+
+// NewCompression creates a new instance of Compression
+func NewCompression(config Config) *Compression {
+	c := &Compression{minSize: config.MinSize, contentTypes: make(map[string]bool)}
+
+	for _, contentType := range compressedContentTypes {
+		if !isExcluded(contentType, config.ExcludedContentTypes) {
+			c.contentTypes[contentType] = true
+		}
+	}
+
+	level := config.Level
+	c.gzipPool.New = func() interface{} {
+		// the level is validated with the config
+		w, _ := gzip.NewWriterLevel(nil, level)
+		return w
+	}
+	c.flatePool.New = func() interface{} {
+		w, _ := flate.NewWriter(nil, level)
+		return w
+	}
+
+	return c
+}
+
+func isExcluded(contentType string, excluded []string) bool {
+	for _, e := range excluded {
+		if e == contentType || (strings.HasSuffix(e, "/*") && strings.HasPrefix(contentType, e[:len(e)-1])) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Handler is the middleware function
+func (c *Compression) Handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := selectEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, compression: c, encoding: encoding, head: r.Method == http.MethodHead}
+		defer cw.Close()
+
+		handler.ServeHTTP(cw, r)
+	})
+}
+
+// selectEncoding returns the accepted encoding, gzip is preferred over deflate as it is more reliably
+// supported by the clients
+func selectEncoding(acceptEncoding string) string {
+	switch {
+	case strings.Contains(acceptEncoding, "gzip"):
+		return "gzip"
+	case strings.Contains(acceptEncoding, "deflate"):
+		return "deflate"
+	}
+
+	return ""
+}
+
+type compressWriter struct {
+	http.ResponseWriter
+	compression *Compression
+	encoding    string
+	head        bool
+
+	code        int
+	wroteHeader bool
+	// buffering is set while the body is smaller than the min size, so it is not known yet whether
+	// the response is compressed
+	buffering bool
+	buf       []byte
+	writer    io.WriteCloser
+}
+
+// WriteHeader decides whether the response is compressed, it is postponed until the min size is
+// reached when the response size is not known
+func (w *compressWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	if code < http.StatusOK {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.wroteHeader, w.code = true, code
+
+	if !w.compressible() {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	if length, err := strconv.Atoi(w.Header().Get("Content-Length")); err == nil {
+		if length < w.compression.minSize {
+			w.ResponseWriter.WriteHeader(code)
+		} else {
+			w.startCompression()
+		}
+		return
+	}
+
+	// the trailers are sent after a chunked body, so the responses announcing them are not buffered
+	// to be sent with a length
+	if w.compression.minSize > 0 && w.Header().Get("Trailer") == "" {
+		w.buffering = true
+		return
+	}
+	w.startCompression()
+}
+
+func (w *compressWriter) compressible() bool {
+	if w.head || w.code == http.StatusNoContent || w.code == http.StatusNotModified {
+		return false
+	}
+	// already compressed
+	if w.Header().Get("Content-Encoding") != "" {
+		return false
+	}
+
+	contentType := strings.ToLower(strings.TrimSpace(strings.Split(w.Header().Get("Content-Type"), ";")[0]))
+	return w.compression.contentTypes[contentType]
+}
+
+func (w *compressWriter) startCompression() {
+	w.buffering = false
+
+	// the length after the compression is unknown
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Encoding", w.encoding)
+	w.ResponseWriter.WriteHeader(w.code)
+
+	if w.encoding == "gzip" {
+		gw := w.compression.gzipPool.Get().(*gzip.Writer)
+		gw.Reset(w.ResponseWriter)
+		w.writer = gw
+	} else {
+		fw := w.compression.flatePool.Get().(*flate.Writer)
+		fw.Reset(w.ResponseWriter)
+		w.writer = fw
+	}
+
+	if len(w.buf) > 0 {
+		w.writer.Write(w.buf)
+		w.buf = nil
+	}
+}
+
+// Write writes the data to the compressor, or buffers it until the min size is reached
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.buffering {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) >= w.compression.minSize {
+			w.startCompression()
+		}
+		return len(b), nil
+	}
+
+	if w.writer != nil {
+		return w.writer.Write(b)
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush sends the buffered data to the client, the streamed responses are compressed as their size is unknown
+func (w *compressWriter) Flush() {
+	if w.buffering {
+		w.startCompression()
+	}
+
+	if fw, ok := w.writer.(interface{ Flush() error }); ok {
+		fw.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets the caller take over the connection
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hj, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return hj.Hijack()
+	}
+
+	return nil, nil, errors.New("the response writer does not support hijacking")
+}
+
+// Close sends the responses smaller than the min size uncompressed and ends the compressed ones
+func (w *compressWriter) Close() error {
+	if w.buffering {
+		w.buffering = false
+		w.Header().Set("Content-Length", strconv.Itoa(len(w.buf)))
+		w.ResponseWriter.WriteHeader(w.code)
+		_, err := w.ResponseWriter.Write(w.buf)
+		return err
+	}
+
+	if w.writer == nil {
+		return nil
+	}
+
+	err := w.writer.Close()
+	if w.encoding == "gzip" {
+		w.compression.gzipPool.Put(w.writer)
+	} else {
+		w.compression.flatePool.Put(w.writer)
+	}
+	w.writer = nil
+
+	return err
+}
@@ -0,0 +1,141 @@
+package compression
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serve(t *testing.T, config Config, method string, acceptEncoding string, handler http.HandlerFunc) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/", nil)
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	w := httptest.NewRecorder()
+
+	NewCompression(config).Handler(handler).ServeHTTP(w, req)
+	return w
+}
+
+func respond(contentType string, body string, withLength bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		if withLength {
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		}
+		w.WriteHeader(http.StatusOK)
+		for _, chunk := range strings.SplitAfter(body, " ") {
+			w.Write([]byte(chunk))
+		}
+	}
+}
+
+func gunzip(t *testing.T, w *httptest.ResponseRecorder) string {
+	gr, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(gr)
+	require.NoError(t, err)
+
+	return string(body)
+}
+
+func TestCompressionBelowMinSize(t *testing.T) {
+	config := Config{Level: flate.BestSpeed, MinSize: 100}
+
+	for _, withLength := range []bool{true, false} {
+		w := serve(t, config, http.MethodGet, "gzip", respond("application/json", `{"tiny": "response"}`, withLength))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.Equal(t, `{"tiny": "response"}`, w.Body.String(), "the response is unmodified")
+		assert.Equal(t, strconv.Itoa(w.Body.Len()), w.Header().Get("Content-Length"))
+		assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	}
+}
+
+func TestCompressionAboveMinSize(t *testing.T) {
+	body := strings.Repeat("a large response ", 20)
+	config := Config{Level: flate.BestCompression, MinSize: 100}
+
+	for _, withLength := range []bool{true, false} {
+		w := serve(t, config, http.MethodGet, "gzip, deflate", respond("text/plain; charset=utf-8", body, withLength))
+
+		assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+		assert.Empty(t, w.Header().Get("Content-Length"))
+		assert.Equal(t, body, gunzip(t, w))
+	}
+
+	w := serve(t, config, http.MethodGet, "deflate", respond("text/plain", body, false))
+	assert.Equal(t, "deflate", w.Header().Get("Content-Encoding"))
+	inflated, err := ioutil.ReadAll(flate.NewReader(w.Body))
+	require.NoError(t, err)
+	assert.Equal(t, body, string(inflated))
+}
+
+func TestCompressionLevel(t *testing.T) {
+	body := strings.Repeat("compressible response ", 500)
+	fast := serve(t, Config{Level: flate.BestSpeed}, http.MethodGet, "gzip", respond("text/plain", body, true))
+	best := serve(t, Config{Level: flate.BestCompression}, http.MethodGet, "gzip", respond("text/plain", body, true))
+
+	assert.True(t, best.Body.Len() <= fast.Body.Len())
+	assert.Equal(t, body, gunzip(t, fast))
+	assert.Equal(t, body, gunzip(t, best))
+}
+
+func TestCompressionSkipped(t *testing.T) {
+	body := strings.Repeat("response ", 50)
+	config := Config{Level: flate.DefaultCompression, ExcludedContentTypes: []string{"text/html", "application/*"}}
+
+	tests := map[string]*httptest.ResponseRecorder{
+		"excluded":          serve(t, config, http.MethodGet, "gzip", respond("text/html", body, false)),
+		"excluded wildcard": serve(t, config, http.MethodGet, "gzip", respond("application/json", body, false)),
+		"not compressible":  serve(t, config, http.MethodGet, "gzip", respond("image/png", body, false)),
+		"not accepted":      serve(t, config, http.MethodGet, "identity", respond("text/plain", body, false)),
+		"head":              serve(t, config, http.MethodHead, "gzip", respond("text/plain", body, false)),
+		"already compressed": serve(t, config, http.MethodGet, "gzip", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			w.Header().Set("Content-Encoding", "br")
+			w.Write([]byte(body))
+		}),
+	}
+
+	for name, w := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.NotEqual(t, "gzip", w.Header().Get("Content-Encoding"))
+			assert.Equal(t, body, w.Body.String())
+		})
+	}
+}
+
+func TestCompressionFlush(t *testing.T) {
+	w := serve(t, Config{Level: flate.DefaultCompression, MinSize: 1024}, http.MethodGet, "gzip", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("first"))
+		w.(http.Flusher).Flush()
+		w.Write([]byte(" second"))
+	})
+
+	assert.True(t, w.Flushed)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"), "the streamed responses are compressed")
+	assert.Equal(t, "first second", gunzip(t, w))
+}
+
+func TestCompressionTrailers(t *testing.T) {
+	w := serve(t, Config{Level: flate.DefaultCompression, MinSize: 1024}, http.MethodGet, "gzip", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Trailer", "X-Checksum")
+		w.Write([]byte("tiny"))
+		w.Header().Set("X-Checksum", "abc")
+	})
+
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"), "the responses with trailers are not buffered")
+	assert.Empty(t, w.Header().Get("Content-Length"))
+	assert.Equal(t, "tiny", gunzip(t, w))
+	assert.Equal(t, "abc", w.Result().Trailer.Get("X-Checksum"))
+}
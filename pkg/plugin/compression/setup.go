@@ -1,18 +1,71 @@
 package compression
 
 import (
-	"github.com/go-chi/chi/middleware"
+	"compress/flate"
+	"strings"
+
 	"github.com/hellofresh/janus/pkg/plugin"
 	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/pkg/errors"
 )
 
+// Config represents the compression configuration
+type Config struct {
+	// Level is the compression level, from 1 (best speed) to 9 (best compression), the default level
+	// is used when not set
+	Level int `json:"level"`
+	// MinSize is the minimum size in bytes of the compressed responses, the smaller responses are sent
+	// uncompressed
+	MinSize int `json:"min_size"`
+	// ExcludedContentTypes are the content types that are not compressed, e.g. text/html or text/*
+	ExcludedContentTypes []string `json:"excluded_content_types"`
+}
+
 func init() {
 	plugin.RegisterPlugin("compression", plugin.Plugin{
-		Action: setupCompression,
+		Action:               setupCompression,
+		Validate:             validateConfig,
+		ModifiesResponseBody: true,
 	})
 }
 
 func setupCompression(def *proxy.RouterDefinition, rawConfig plugin.Config) error {
-	def.AddMiddleware(middleware.DefaultCompress)
+	config, err := decodeConfig(rawConfig)
+	if err != nil {
+		return err
+	}
+
+	def.AddMiddleware(NewCompression(config).Handler)
 	return nil
 }
+
+func validateConfig(rawConfig plugin.Config) (bool, error) {
+	_, err := decodeConfig(rawConfig)
+	return err == nil, err
+}
+
+func decodeConfig(rawConfig plugin.Config) (Config, error) {
+	var config Config
+	if err := plugin.Decode(rawConfig, &config); err != nil {
+		return config, err
+	}
+
+	if config.Level == 0 {
+		config.Level = flate.DefaultCompression
+	} else if config.Level < flate.BestSpeed || config.Level > flate.BestCompression {
+		return config, errors.Errorf("compression level must be between %d and %d", flate.BestSpeed, flate.BestCompression)
+	}
+
+	if config.MinSize < 0 {
+		return config, errors.New("compression min size can not be negative")
+	}
+
+	for i, contentType := range config.ExcludedContentTypes {
+		if !strings.Contains(contentType, "/") {
+			return config, errors.Errorf("invalid excluded content type %q", contentType)
+		}
+		config.ExcludedContentTypes[i] = strings.ToLower(strings.TrimSpace(contentType))
+	}
+
+	return config, nil
+}
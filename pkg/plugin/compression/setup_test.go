@@ -1,11 +1,13 @@
 package compression
 
 import (
+	"compress/flate"
 	"testing"
 
 	"github.com/hellofresh/janus/pkg/plugin"
 	"github.com/hellofresh/janus/pkg/proxy"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSetup(t *testing.T) {
@@ -15,3 +17,30 @@ func TestSetup(t *testing.T) {
 
 	assert.Len(t, def.Middleware(), 1)
 }
+
+func TestDecodeConfig(t *testing.T) {
+	config, err := decodeConfig(make(plugin.Config))
+	require.NoError(t, err)
+	assert.Equal(t, flate.DefaultCompression, config.Level)
+	assert.Equal(t, 0, config.MinSize)
+
+	config, err = decodeConfig(plugin.Config{"level": 1, "min_size": 1024, "excluded_content_types": []string{" Text/HTML "}})
+	require.NoError(t, err)
+	assert.Equal(t, Config{Level: 1, MinSize: 1024, ExcludedContentTypes: []string{"text/html"}}, config)
+}
+
+func TestDecodeConfigInvalid(t *testing.T) {
+	invalid := map[string]plugin.Config{
+		"level too low":        {"level": -2},
+		"level too high":       {"level": 10},
+		"negative min size":    {"min_size": -1},
+		"invalid content type": {"excluded_content_types": []string{"html"}},
+	}
+
+	for name, config := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := decodeConfig(config)
+			assert.Error(t, err)
+		})
+	}
+}
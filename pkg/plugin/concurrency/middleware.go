@@ -0,0 +1,110 @@
+package concurrency
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/hellofresh/janus/pkg/errors"
+	"github.com/hellofresh/janus/pkg/middleware"
+	obs "github.com/hellofresh/janus/pkg/observability"
+	log "github.com/sirupsen/logrus"
+	"go.opencensus.io/stats"
+)
+
+var (
+	// ErrTooManyRequests is thrown when all the slots are taken and the queue is full
+	ErrTooManyRequests = errors.New(http.StatusServiceUnavailable, "too many concurrent requests")
+)
+
+// Limiter caps the number of the requests of an API definition served at the same time,
+// the requests over the limit wait in a queue for a free slot
+type Limiter struct {
+	slots   chan struct{}
+	queue   chan struct{}
+	timeout time.Duration
+
+	inFlight int64
+	queued   int64
+}
+
+// NewLimiter creates a new instance of Limiter allowing limit requests in flight and queueDepth
+// requests waiting, the queued requests are rejected after the timeout unless it is zero
+func NewLimiter(limit int, queueDepth int, timeout time.Duration) *Limiter {
+	return &Limiter{
+		slots:   make(chan struct{}, limit),
+		queue:   make(chan struct{}, queueDepth),
+		timeout: timeout,
+	}
+}
+
+// Handler is the middleware function
+func (l *Limiter) Handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := l.acquire(r); err != nil {
+			// nobody is waiting for the response of a disconnected client
+			if err == ErrTooManyRequests {
+				errors.Handler(w, err)
+			}
+			return
+		}
+
+		// the slot is released once the request is served, also when the client disconnected
+		// and the upstream request was cancelled
+		defer func() {
+			<-l.slots
+			stats.Record(r.Context(), obs.MConcurrencyInFlight.M(atomic.AddInt64(&l.inFlight, -1)))
+		}()
+		stats.Record(r.Context(), obs.MConcurrencyInFlight.M(atomic.AddInt64(&l.inFlight, 1)))
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// acquire takes a slot, waiting in the queue when all of them are taken
+func (l *Limiter) acquire(r *http.Request) error {
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	select {
+	case l.queue <- struct{}{}:
+	default:
+		return l.reject(r, "queue is full")
+	}
+
+	start := time.Now()
+	stats.Record(r.Context(), obs.MConcurrencyQueued.M(atomic.AddInt64(&l.queued, 1)))
+	defer func() {
+		<-l.queue
+		stats.Record(r.Context(),
+			obs.MConcurrencyQueued.M(atomic.AddInt64(&l.queued, -1)),
+			obs.MConcurrencyQueueWait.M(float64(time.Since(start))/float64(time.Millisecond)),
+		)
+	}()
+
+	var timeout <-chan time.Time
+	if l.timeout > 0 {
+		timer := time.NewTimer(l.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-r.Context().Done():
+		middleware.ContextLogger(r.Context()).WithField("path", r.URL.Path).Debug("Client disconnected while waiting for a concurrency limit slot")
+		return r.Context().Err()
+	case <-timeout:
+		return l.reject(r, "queue timeout")
+	}
+}
+
+func (l *Limiter) reject(r *http.Request, reason string) error {
+	middleware.ContextLogger(r.Context()).WithFields(log.Fields{"path": r.URL.Path, "reason": reason}).Debug("Concurrency limit exceeded")
+	stats.Record(r.Context(), obs.MConcurrencyRejected.M(1))
+	return ErrTooManyRequests
+}
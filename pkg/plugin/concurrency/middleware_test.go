@@ -0,0 +1,119 @@
+package concurrency
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	obs "github.com/hellofresh/janus/pkg/observability"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
+)
+
+// blockingHandler holds the requests until release is closed
+func blockingHandler(started chan<- struct{}, release <-chan struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func serve(handler http.Handler, r *http.Request) <-chan int {
+	code := make(chan int, 1)
+	go func() {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		code <- w.Code
+	}()
+
+	return code
+}
+
+func waitQueued(t *testing.T, l *Limiter, n int) {
+	for i := 0; i < 100 && len(l.queue) != n; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Len(t, l.queue, n)
+}
+
+func TestLimiterQueuesAndRejects(t *testing.T) {
+	started, release := make(chan struct{}, 2), make(chan struct{})
+	l := NewLimiter(1, 1, 0)
+	handler := l.Handler(blockingHandler(started, release))
+
+	first := serve(handler, httptest.NewRequest(http.MethodGet, "/", nil))
+	<-started
+
+	queued := serve(handler, httptest.NewRequest(http.MethodGet, "/", nil))
+	waitQueued(t, l, 1)
+
+	rejected := serve(handler, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, <-rejected)
+
+	close(release)
+	assert.Equal(t, http.StatusOK, <-first)
+	<-started
+	assert.Equal(t, http.StatusOK, <-queued, "the queued request is served once the slot is free")
+	assert.Len(t, l.slots, 0)
+}
+
+func TestLimiterQueueTimeout(t *testing.T) {
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	defer close(release)
+	l := NewLimiter(1, 1, 50*time.Millisecond)
+	handler := l.Handler(blockingHandler(started, release))
+
+	serve(handler, httptest.NewRequest(http.MethodGet, "/", nil))
+	<-started
+
+	assert.Equal(t, http.StatusServiceUnavailable, <-serve(handler, httptest.NewRequest(http.MethodGet, "/", nil)))
+	assert.Len(t, l.queue, 0)
+}
+
+func TestLimiterReleasesOnDisconnect(t *testing.T) {
+	started, release := make(chan struct{}, 2), make(chan struct{})
+	l := NewLimiter(1, 1, 0)
+	handler := l.Handler(blockingHandler(started, release))
+
+	first := serve(handler, httptest.NewRequest(http.MethodGet, "/", nil))
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	queued := serve(handler, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+	waitQueued(t, l, 1)
+
+	cancel()
+	<-queued
+	assert.Len(t, l.queue, 0, "the queue slot of the disconnected client is released")
+
+	close(release)
+	<-first
+	assert.Len(t, l.slots, 0)
+}
+
+func TestLimiterRecordsQueueWait(t *testing.T) {
+	waitView := &view.View{Name: "test_concurrency_limit_queue_wait", Measure: obs.MConcurrencyQueueWait, Aggregation: view.Distribution(0, 1000)}
+	require.NoError(t, view.Register(waitView))
+	defer view.Unregister(waitView)
+
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	defer close(release)
+	l := NewLimiter(1, 1, 50*time.Millisecond)
+	handler := l.Handler(blockingHandler(started, release))
+
+	serve(handler, httptest.NewRequest(http.MethodGet, "/", nil))
+	<-started
+	<-serve(handler, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	rows, err := view.RetrieveData(waitView.Name)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+
+	data := rows[0].Data.(*view.DistributionData)
+	assert.Equal(t, int64(1), data.Count)
+	assert.True(t, data.Min >= 50, "the timed out request waited for the queue timeout")
+}
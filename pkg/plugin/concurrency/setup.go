@@ -0,0 +1,61 @@
+package concurrency
+
+import (
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/hellofresh/janus/pkg/plugin"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/pkg/errors"
+)
+
+// Config represents the Concurrency Limit configuration
+type Config struct {
+	Limit        int            `json:"limit"`
+	QueueDepth   int            `json:"queue_depth"`
+	QueueTimeout proxy.Duration `json:"queue_timeout"`
+}
+
+func init() {
+	plugin.RegisterPlugin("concurrency_limit", plugin.Plugin{
+		Action:   setupConcurrencyLimit,
+		Validate: validateConfig,
+		Stateful: true,
+	})
+}
+
+func setupConcurrencyLimit(def *proxy.RouterDefinition, rawConfig plugin.Config) error {
+	config, err := decodeConfig(rawConfig)
+	if err != nil {
+		return err
+	}
+
+	limiter := NewLimiter(config.Limit, config.QueueDepth, time.Duration(config.QueueTimeout))
+	def.AddMiddleware(limiter.Handler)
+	return nil
+}
+
+func validateConfig(rawConfig plugin.Config) (bool, error) {
+	config, err := decodeConfig(rawConfig)
+	if err != nil {
+		return false, err
+	}
+
+	return govalidator.ValidateStruct(config)
+}
+
+func decodeConfig(rawConfig plugin.Config) (Config, error) {
+	var config Config
+	if err := plugin.Decode(rawConfig, &config); err != nil {
+		return config, err
+	}
+
+	if config.Limit <= 0 {
+		return config, errors.New("concurrency limit must be greater than zero")
+	}
+	if config.QueueDepth < 0 {
+		return config, errors.New("concurrency limit queue depth can not be negative")
+	}
+
+	return config, nil
+}
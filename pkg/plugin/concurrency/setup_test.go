@@ -0,0 +1,29 @@
+package concurrency
+
+import (
+	"testing"
+
+	"github.com/hellofresh/janus/pkg/plugin"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetup(t *testing.T) {
+	def := proxy.NewRouterDefinition(proxy.NewDefinition())
+	err := setupConcurrencyLimit(def, plugin.Config{"limit": 10, "queue_depth": 5, "queue_timeout": "1s"})
+	assert.NoError(t, err)
+
+	assert.Len(t, def.Middleware(), 1)
+}
+
+func TestValidateConfig(t *testing.T) {
+	valid, err := validateConfig(plugin.Config{"limit": 10})
+	assert.NoError(t, err)
+	assert.True(t, valid)
+
+	_, err = validateConfig(plugin.Config{})
+	assert.Error(t, err)
+
+	_, err = validateConfig(plugin.Config{"limit": 10, "queue_depth": -1})
+	assert.Error(t, err)
+}
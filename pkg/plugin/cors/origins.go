@@ -0,0 +1,115 @@
+package cors
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// originMatcher tells the origins allowed by the domains and the domain patterns of the config. The
+// malformed origins, e.g. null or with a path, are never allowed.
+type originMatcher struct {
+	all       bool
+	exact     map[string]bool
+	wildcards []wildcardOrigin
+	patterns  []*regexp.Regexp
+}
+
+// wildcardOrigin is a domain with an asterisk as its leftmost label, it matches one or more labels
+// like the wildcard hostnames of the API definitions. The origins of any scheme match it when it has
+// none.
+type wildcardOrigin struct {
+	scheme string
+	suffix string
+}
+
+func newOriginMatcher(domains []string, patterns []string) (*originMatcher, error) {
+	// all the origins are allowed when none is configured
+	m := &originMatcher{all: len(domains) == 0 && len(patterns) == 0, exact: make(map[string]bool)}
+	for _, domain := range domains {
+		domain = strings.ToLower(domain)
+		switch {
+		case domain == "*":
+			m.all = true
+		case strings.Contains(domain, "*"):
+			w, err := parseWildcardOrigin(domain)
+			if err != nil {
+				return nil, err
+			}
+			m.wildcards = append(m.wildcards, w)
+		default:
+			m.exact[domain] = true
+		}
+	}
+
+	for _, pattern := range patterns {
+		// the patterns are anchored, so they match the whole origin
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, errors.Wrapf(err, "cors domain pattern %q is not valid", pattern)
+		}
+		m.patterns = append(m.patterns, re)
+	}
+
+	return m, nil
+}
+
+func parseWildcardOrigin(domain string) (wildcardOrigin, error) {
+	var w wildcardOrigin
+	host := domain
+	if i := strings.Index(domain, "://"); i >= 0 {
+		w.scheme, host = domain[:i], domain[i+3:]
+	}
+
+	if !strings.HasPrefix(host, "*.") || strings.Contains(host[1:], "*") || len(host) < 3 {
+		return w, errors.Errorf("cors domain %q must have a single asterisk as its leftmost label", domain)
+	}
+	w.suffix = host[1:]
+
+	return w, nil
+}
+
+// allowed tells if the request origin is allowed, it is echoed back to the client then
+func (m *originMatcher) allowed(origin string) bool {
+	scheme, host, ok := parseOrigin(origin)
+	if !ok {
+		return false
+	}
+	if m.all {
+		return true
+	}
+
+	origin = strings.ToLower(origin)
+	if m.exact[origin] {
+		return true
+	}
+
+	for _, w := range m.wildcards {
+		if (w.scheme == "" || w.scheme == scheme) && len(host) > len(w.suffix) && strings.HasSuffix(host, w.suffix) {
+			return true
+		}
+	}
+
+	for _, re := range m.patterns {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseOrigin returns the lower case scheme and host, with the port, of a serialized origin
+func parseOrigin(origin string) (string, string, bool) {
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme == "" || u.Host == "" || u.User != nil || u.Path != "" || u.RawQuery != "" || u.Fragment != "" {
+		return "", "", false
+	}
+	if strings.ContainsAny(u.Host, "*/\\") {
+		return "", "", false
+	}
+
+	return strings.ToLower(u.Scheme), strings.ToLower(u.Host), true
+}
@@ -0,0 +1,137 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOriginMatcher(t *testing.T) {
+	m, err := newOriginMatcher(
+		[]string{"https://app.example.com", "*.preview.example.com", "http://*.local.example.com:8080"},
+		[]string{`https://pr-\d+\.review\.example\.com`},
+	)
+	require.NoError(t, err)
+
+	for _, origin := range []string{
+		"https://app.example.com",
+		"https://APP.example.com",
+		"https://pr-123.preview.example.com",
+		"http://pr-123.preview.example.com",
+		"https://a.b.preview.example.com",
+		"http://dev.local.example.com:8080",
+		"https://pr-42.review.example.com",
+	} {
+		assert.True(t, m.allowed(origin), origin)
+	}
+
+	for _, origin := range []string{
+		"https://example.com",
+		"https://preview.example.com",
+		"https://evilpreview.example.com",
+		"https://pr-123.preview.example.com.evil.com",
+		"https://pr-123.preview.example.com:8443",
+		"https://dev.local.example.com:8080",
+		"http://dev.local.example.com",
+		"https://pr-42.review.example.com.evil.com",
+		"https://evil.com/?https://pr-42.review.example.com",
+		"https://app.example.com/path",
+		"null",
+		"",
+	} {
+		assert.False(t, m.allowed(origin), origin)
+	}
+}
+
+func TestOriginMatcherAll(t *testing.T) {
+	m, err := newOriginMatcher([]string{"*"}, nil)
+	require.NoError(t, err)
+	assert.True(t, m.allowed("https://example.com"))
+	assert.False(t, m.allowed("null"), "the malformed origins are never allowed")
+
+	m, err = newOriginMatcher(nil, nil)
+	require.NoError(t, err)
+	assert.True(t, m.allowed("https://example.com"), "all the origins are allowed when none is configured")
+}
+
+func TestInvalidOrigins(t *testing.T) {
+	for _, domains := range [][]string{{"example.*"}, {"https://*.*.example.com"}, {"*"}} {
+		_, err := newOriginMatcher(domains, []string{"("})
+		assert.Error(t, err)
+	}
+
+	for _, domain := range []string{"example.*", "https://a.*.example.com", "**.example.com", "*."} {
+		valid, err := validateConfig(map[string]interface{}{"domains": []string{domain}})
+		assert.Error(t, err, domain)
+		assert.False(t, valid)
+	}
+
+	valid, err := validateConfig(map[string]interface{}{"domain_patterns": []string{"https://[a-z"}})
+	assert.Error(t, err)
+	assert.False(t, valid)
+}
+
+func serveCors(t *testing.T, rawConfig map[string]interface{}, req *http.Request) *httptest.ResponseRecorder {
+	def := proxy.NewRouterDefinition(proxy.NewDefinition())
+	require.NoError(t, setupCors(def, rawConfig))
+
+	w := httptest.NewRecorder()
+	def.Middleware()[0](http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})).ServeHTTP(w, req)
+
+	return w
+}
+
+func TestCredentialedRequestOrigin(t *testing.T) {
+	rawConfig := map[string]interface{}{
+		"domains":         []string{"https://*.preview.example.com"},
+		"domain_patterns": []string{`https://pr-\d+\.review\.example\.com`},
+		"methods":         []string{"GET", "PUT"},
+	}
+
+	for _, origin := range []string{"https://pr-123.preview.example.com", "https://pr-7.review.example.com"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Cookie", "session=1")
+		w := serveCors(t, rawConfig, req)
+
+		assert.Equal(t, origin, w.Header().Get("Access-Control-Allow-Origin"), "the matched origin is echoed back, not *")
+		assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+		assert.Contains(t, w.Header()["Vary"], "Origin", "the caches key the responses by origin")
+	}
+
+	req := httptest.NewRequest(http.MethodOptions, "/", nil)
+	req.Header.Set("Origin", "https://pr-123.preview.example.com")
+	req.Header.Set("Access-Control-Request-Method", "PUT")
+	w := serveCors(t, rawConfig, req)
+	assert.Equal(t, "https://pr-123.preview.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "PUT", w.Header().Get("Access-Control-Allow-Methods"))
+}
+
+func TestCredentialedRequestUnmatchedOrigin(t *testing.T) {
+	rawConfig := map[string]interface{}{
+		"domains": []string{"https://*.preview.example.com"},
+		"methods": []string{"GET", "PUT"},
+	}
+
+	for _, origin := range []string{"https://pr-123.preview.example.com.evil.com", "http://pr-123.preview.example.com", "null"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Origin", origin)
+		w := serveCors(t, rawConfig, req)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"), origin)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"), origin)
+
+		req = httptest.NewRequest(http.MethodOptions, "/", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", "PUT")
+		w = serveCors(t, rawConfig, req)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"), origin)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Methods"), origin)
+	}
+}
@@ -4,16 +4,23 @@ import (
 	"github.com/asaskevich/govalidator"
 	"github.com/hellofresh/janus/pkg/plugin"
 	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/pkg/errors"
 	"github.com/rs/cors"
 )
 
 // Config represents the CORS configuration
 type Config struct {
-	AllowedOrigins     []string `json:"domains"`
-	AllowedMethods     []string `json:"methods"`
-	AllowedHeaders     []string `json:"request_headers"`
-	ExposedHeaders     []string `json:"exposed_headers"`
-	OptionsPassthrough bool     `json:"options_passthrough"`
+	// AllowedOrigins are the origins allowed to call the API, * allows all of them and a leftmost
+	// wildcard label, e.g. https://*.example.com, allows their subdomains
+	AllowedOrigins []string `json:"domains"`
+	// AllowedOriginPatterns are the regular expressions of the allowed origins, matching the whole origin
+	AllowedOriginPatterns []string `json:"domain_patterns"`
+	AllowedMethods        []string `json:"methods"`
+	AllowedHeaders        []string `json:"request_headers"`
+	ExposedHeaders        []string `json:"exposed_headers"`
+	OptionsPassthrough    bool     `json:"options_passthrough"`
+	// MaxAge is the time in seconds the browsers cache the preflight results for, not cached when zero
+	MaxAge int `json:"max_age"`
 }
 
 func init() {
@@ -31,12 +38,20 @@ func setupCors(def *proxy.RouterDefinition, rawConfig plugin.Config) error {
 		return err
 	}
 
+	origins, err := newOriginMatcher(config.AllowedOrigins, config.AllowedOriginPatterns)
+	if err != nil {
+		return err
+	}
+
+	// the matched origin is echoed back rather than *, which the browsers reject for the credentialed
+	// requests
 	mw := cors.New(cors.Options{
-		AllowedOrigins:     config.AllowedOrigins,
+		AllowOriginFunc:    origins.allowed,
 		AllowedMethods:     config.AllowedMethods,
 		AllowedHeaders:     config.AllowedHeaders,
 		ExposedHeaders:     config.ExposedHeaders,
 		OptionsPassthrough: config.OptionsPassthrough,
+		MaxAge:             config.MaxAge,
 		AllowCredentials:   true,
 	})
 
@@ -51,5 +66,13 @@ func validateConfig(rawConfig plugin.Config) (bool, error) {
 		return false, err
 	}
 
+	if config.MaxAge < 0 {
+		return false, errors.New("cors max_age can not be negative")
+	}
+
+	if _, err := newOriginMatcher(config.AllowedOrigins, config.AllowedOriginPatterns); err != nil {
+		return false, err
+	}
+
 	return govalidator.ValidateStruct(config)
 }
@@ -1,6 +1,8 @@
 package cors
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/hellofresh/janus/pkg/plugin"
@@ -75,3 +77,41 @@ func TestSetup(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Len(t, def.Middleware(), 1)
 }
+
+func TestPreflight(t *testing.T) {
+	rawConfig := map[string]interface{}{
+		"domains":         []string{"*"},
+		"methods":         []string{"GET", "PUT"},
+		"request_headers": []string{"Content-Type"},
+		"max_age":         600,
+	}
+	def := proxy.NewRouterDefinition(proxy.NewDefinition())
+	assert.NoError(t, setupCors(def, rawConfig))
+
+	handler := def.Middleware()[0](http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	req := httptest.NewRequest(http.MethodOptions, "/", nil)
+	req.Header.Set("Origin", "http://example.com")
+	req.Header.Set("Access-Control-Request-Method", "PUT")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code, "the preflight is answered at the gateway")
+	assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+	assert.Equal(t, "PUT", w.Header().Get("Access-Control-Allow-Methods"))
+
+	req = httptest.NewRequest(http.MethodOptions, "/", nil)
+	req.Header.Set("Origin", "http://example.com")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusTeapot, w.Code, "the OPTIONS requests which are not preflights are proxied")
+}
+
+func TestInvalidMaxAge(t *testing.T) {
+	valid, err := validateConfig(map[string]interface{}{"domains": []string{"*"}, "max_age": -1})
+	assert.Error(t, err)
+	assert.False(t, valid)
+}
@@ -0,0 +1,61 @@
+package plugin
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/hellofresh/janus/pkg/middleware"
+	obs "github.com/hellofresh/janus/pkg/observability"
+	log "github.com/sirupsen/logrus"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// DependencyErrorPolicy tells how an authentication plugin answers the requests it can not check because
+// an external dependency failed, e.g. its introspection server is unreachable
+type DependencyErrorPolicy string
+
+const (
+	// FailClosed rejects the requests which can not be checked, it is the default policy
+	FailClosed DependencyErrorPolicy = "fail_closed"
+	// FailOpen lets the requests which can not be checked through unauthenticated, the upstreams stay
+	// available during the failures of the dependency at the cost of serving anyone
+	FailOpen DependencyErrorPolicy = "fail_open"
+)
+
+// Validate checks the policy is known, the empty policy is fail closed
+func (p DependencyErrorPolicy) Validate() error {
+	switch p {
+	case "", FailClosed, FailOpen:
+		return nil
+	}
+
+	return fmt.Errorf("on_dependency_error must be either %q or %q", FailClosed, FailOpen)
+}
+
+// Allows records the failure of the dependency of the plugin for the request and tells if the request is
+// let through. The requests let through are logged as errors, so the risk taken is visible.
+func (p DependencyErrorPolicy) Allows(r *http.Request, name string, err error) bool {
+	policy := p
+	if policy == "" {
+		policy = FailClosed
+	}
+
+	stats.RecordWithTags(r.Context(), []tag.Mutator{
+		tag.Upsert(obs.KeyPluginName, name),
+		tag.Upsert(obs.KeyDependencyPolicy, string(policy)),
+	}, obs.MAuthDependencyErrors.M(1))
+
+	logger := middleware.ContextLogger(r.Context()).WithError(err).WithFields(log.Fields{
+		"plugin": name,
+		"policy": string(policy),
+		"path":   r.URL.Path,
+	})
+	if policy == FailOpen {
+		logger.Error("The request could not be authenticated and it is let through unauthenticated")
+		return true
+	}
+
+	logger.Warn("The request could not be authenticated and it is rejected")
+	return false
+}
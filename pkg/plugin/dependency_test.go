@@ -0,0 +1,50 @@
+package plugin
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	obs "github.com/hellofresh/janus/pkg/observability"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+func TestDependencyErrorPolicyValidate(t *testing.T) {
+	assert.NoError(t, DependencyErrorPolicy("").Validate())
+	assert.NoError(t, FailClosed.Validate())
+	assert.NoError(t, FailOpen.Validate())
+	assert.Error(t, DependencyErrorPolicy("fail_safe").Validate())
+}
+
+func TestDependencyErrorPolicyAllows(t *testing.T) {
+	v := &view.View{
+		Name:        "test_plugin_auth_dependency_error_total",
+		TagKeys:     []tag.Key{obs.KeyPluginName, obs.KeyDependencyPolicy},
+		Measure:     obs.MAuthDependencyErrors,
+		Aggregation: view.Count(),
+	}
+	require.NoError(t, view.Register(v))
+	defer view.Unregister(v)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	err := errors.New("connection refused")
+	assert.False(t, DependencyErrorPolicy("").Allows(r, "oauth2", err), "the requests fail closed by default")
+	assert.False(t, FailClosed.Allows(r, "oauth2", err))
+	assert.True(t, FailOpen.Allows(r, "oauth2", err))
+
+	rows, err := view.RetrieveData(v.Name)
+	require.NoError(t, err)
+	counts := make(map[string]int64)
+	for _, row := range rows {
+		for _, t := range row.Tags {
+			if t.Key == obs.KeyDependencyPolicy {
+				counts[t.Value] = row.Data.(*view.CountData).Value
+			}
+		}
+	}
+	assert.Equal(t, map[string]int64{"fail_closed": 2, "fail_open": 1}, counts)
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/hellofresh/janus/pkg/config"
 	"github.com/hellofresh/janus/pkg/proxy"
 	"github.com/hellofresh/janus/pkg/router"
+	"github.com/hellofresh/janus/pkg/upstream"
 	"github.com/hellofresh/stats-go/client"
 )
 
@@ -26,6 +27,7 @@ type OnStartup struct {
 	Register      *proxy.Register
 	Config        *config.Specification
 	Configuration []*api.Definition
+	Upstreams     *upstream.States
 }
 
 // OnReload represents a event that happens when Janus hot reloads it's configurations
@@ -0,0 +1,138 @@
+package geo
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/hellofresh/janus/pkg/errors"
+	"github.com/hellofresh/janus/pkg/geoip"
+	"github.com/hellofresh/janus/pkg/middleware"
+	"github.com/hellofresh/janus/pkg/proxy"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrLocationDenied is thrown when the request comes from a denied country, or from an unknown
+	// location when they are denied
+	ErrLocationDenied = errors.New(http.StatusForbidden, "access from your location is not allowed")
+
+	privateNetworks = mustParseCIDRs("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7")
+)
+
+// CountryResolver returns the country of the IP addresses, e.g. a geoip.Database
+type CountryResolver interface {
+	LookupCountry(ip net.IP) (geoip.Country, bool, error)
+}
+
+type region struct {
+	name       string
+	countries  map[string]bool
+	continents map[string]bool
+	targets    proxy.Targets
+}
+
+// Geo denies the requests from the listed countries and routes the others to the upstream targets
+// of their region
+type Geo struct {
+	resolver    CountryResolver
+	deny        map[string]bool
+	regions     []region
+	denyUnknown bool
+}
+
+// NewGeo creates a new instance of Geo
+func NewGeo(resolver CountryResolver, config Config) *Geo {
+	regions := make([]region, 0, len(config.Regions))
+	for _, r := range config.Regions {
+		regions = append(regions, region{
+			name:       r.Name,
+			countries:  toSet(r.Countries),
+			continents: toSet(r.Continents),
+			targets:    r.Targets,
+		})
+	}
+
+	return &Geo{
+		resolver:    resolver,
+		deny:        toSet(config.DenyCountries),
+		regions:     regions,
+		denyUnknown: config.DefaultAction == ActionDeny,
+	}
+}
+
+// Handler is the middleware function. The requests from the private addresses, and from the
+// addresses not found in the database, take the default action.
+func (m *Geo) Handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		country, ok := m.lookup(r)
+		if !ok {
+			if m.denyUnknown {
+				errors.Handler(w, ErrLocationDenied)
+				return
+			}
+
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		if m.deny[country.ISOCode] {
+			middleware.ContextLogger(r.Context()).WithFields(log.Fields{
+				"country": country.ISOCode,
+				"origin":  r.RemoteAddr,
+			}).Debug("Request from a denied country")
+			errors.Handler(w, ErrLocationDenied)
+			return
+		}
+
+		for _, region := range m.regions {
+			if region.countries[country.ISOCode] || region.continents[country.Continent] {
+				r = r.WithContext(proxy.WithUpstreamTargets(r.Context(), region.targets))
+				break
+			}
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func (m *Geo) lookup(r *http.Request) (geoip.Country, bool) {
+	ip := middleware.ClientIPFromRequest(r)
+	if ip == nil || isPrivate(ip) {
+		return geoip.Country{}, false
+	}
+
+	country, ok, err := m.resolver.LookupCountry(ip)
+	if err != nil {
+		middleware.ContextLogger(r.Context()).WithError(err).WithField("ip", ip.String()).Warn("Could not look up the country of the request")
+		return geoip.Country{}, false
+	}
+
+	return country, ok
+}
+
+func isPrivate(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() {
+		return true
+	}
+
+	for _, network := range privateNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func mustParseCIDRs(values ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+
+	return networks
+}
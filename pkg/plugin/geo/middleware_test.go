@@ -0,0 +1,96 @@
+package geo
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hellofresh/janus/pkg/geoip"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type staticResolver map[string]geoip.Country
+
+func (r staticResolver) LookupCountry(ip net.IP) (geoip.Country, bool, error) {
+	country, ok := r[ip.String()]
+	return country, ok, nil
+}
+
+var (
+	resolver = staticResolver{
+		"81.2.69.142":   {ISOCode: "DE", Continent: "EU"},
+		"81.2.69.143":   {ISOCode: "FR", Continent: "EU"},
+		"175.16.199.10": {ISOCode: "KP", Continent: "AS"},
+		"203.0.113.7":   {ISOCode: "US", Continent: "NA"},
+	}
+	euTargets = proxy.Targets{{Target: "http://eu.example.com"}}
+	deTargets = proxy.Targets{{Target: "http://de.example.com"}}
+)
+
+func serve(t *testing.T, config Config, remoteAddr string) (int, *http.Request) {
+	var proxied *http.Request
+	handler := NewGeo(resolver, config).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = remoteAddr
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	return w.Code, proxied
+}
+
+func TestGeoDenyCountries(t *testing.T) {
+	config := Config{DenyCountries: []string{"kp"}, DefaultAction: ActionAllow}
+
+	code, _ := serve(t, config, "175.16.199.10:1234")
+	assert.Equal(t, http.StatusForbidden, code)
+
+	code, proxied := serve(t, config, "203.0.113.7:1234")
+	assert.Equal(t, http.StatusOK, code)
+	require.NotNil(t, proxied)
+}
+
+func TestGeoRegions(t *testing.T) {
+	config := Config{
+		Regions: []Region{
+			{Name: "germany", Countries: []string{"DE"}, Targets: deTargets},
+			{Name: "eu", Continents: []string{"eu"}, Targets: euTargets},
+		},
+		DefaultAction: ActionAllow,
+	}
+
+	tests := map[string]proxy.Targets{
+		"81.2.69.142:1234": deTargets,
+		"81.2.69.143:1234": euTargets,
+	}
+	for remoteAddr, expected := range tests {
+		_, proxied := serve(t, config, remoteAddr)
+		require.NotNil(t, proxied)
+
+		targets, ok := proxy.UpstreamTargetsFromContext(proxied.Context())
+		assert.True(t, ok, remoteAddr)
+		assert.Equal(t, expected, targets, remoteAddr)
+	}
+
+	_, proxied := serve(t, config, "203.0.113.7:1234")
+	require.NotNil(t, proxied)
+	_, ok := proxy.UpstreamTargetsFromContext(proxied.Context())
+	assert.False(t, ok, "the requests from the other regions use the API upstreams")
+}
+
+func TestGeoDefaultAction(t *testing.T) {
+	unknown := []string{"198.51.100.1:1234", "10.0.0.1:1234", "[::1]:1234", "[fd00::1]:1234", "invalid"}
+
+	for _, remoteAddr := range unknown {
+		code, _ := serve(t, Config{DefaultAction: ActionAllow}, remoteAddr)
+		assert.Equal(t, http.StatusOK, code, remoteAddr)
+
+		code, _ = serve(t, Config{DefaultAction: ActionDeny}, remoteAddr)
+		assert.Equal(t, http.StatusForbidden, code, remoteAddr)
+	}
+}
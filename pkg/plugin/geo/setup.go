@@ -0,0 +1,133 @@
+package geo
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/hellofresh/janus/pkg/geoip"
+	"github.com/hellofresh/janus/pkg/plugin"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ActionAllow proxies the requests from the unknown locations to the API upstreams
+	ActionAllow = "allow"
+	// ActionDeny rejects the requests from the unknown locations
+	ActionDeny = "deny"
+)
+
+// Region is a group of countries and continents served by their own upstream targets
+type Region struct {
+	Name       string        `json:"name"`
+	Countries  []string      `json:"countries"`
+	Continents []string      `json:"continents"`
+	Targets    proxy.Targets `json:"targets"`
+}
+
+// Config represents the Geo configuration
+type Config struct {
+	Database      string   `json:"database"`
+	DenyCountries []string `json:"deny_countries"`
+	Regions       []Region `json:"regions"`
+	DefaultAction string   `json:"default_action"`
+}
+
+var (
+	databasesMu sync.Mutex
+	// databases are shared by the API definitions using the same file, so it is loaded only once
+	databases = make(map[string]*geoip.Database)
+)
+
+func init() {
+	plugin.RegisterPlugin("geo", plugin.Plugin{
+		Action:   setupGeo,
+		Validate: validateConfig,
+	})
+}
+
+func setupGeo(def *proxy.RouterDefinition, rawConfig plugin.Config) error {
+	config, err := decodeConfig(rawConfig)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDatabase(config.Database)
+	if err != nil {
+		return err
+	}
+
+	def.AddMiddleware(NewGeo(db, config).Handler)
+	return nil
+}
+
+func validateConfig(rawConfig plugin.Config) (bool, error) {
+	config, err := decodeConfig(rawConfig)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := openDatabase(config.Database); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func decodeConfig(rawConfig plugin.Config) (Config, error) {
+	config := Config{DefaultAction: ActionAllow}
+	if err := plugin.Decode(rawConfig, &config); err != nil {
+		return config, err
+	}
+
+	if config.Database == "" {
+		return config, errors.New("geo database path is not set")
+	}
+	if config.DefaultAction != ActionAllow && config.DefaultAction != ActionDeny {
+		return config, errors.Errorf("invalid geo default action %q, it must be %q or %q", config.DefaultAction, ActionAllow, ActionDeny)
+	}
+
+	for _, region := range config.Regions {
+		if len(region.Countries) == 0 && len(region.Continents) == 0 {
+			return config, errors.Errorf("geo region %q has no countries nor continents", region.Name)
+		}
+		if len(region.Targets) == 0 {
+			return config, errors.Errorf("geo region %q has no targets", region.Name)
+		}
+		for _, target := range region.Targets {
+			if _, err := govalidator.ValidateStruct(target); err != nil {
+				return config, errors.Wrapf(err, "invalid target of the geo region %q", region.Name)
+			}
+		}
+	}
+
+	return config, nil
+}
+
+func openDatabase(path string) (*geoip.Database, error) {
+	databasesMu.Lock()
+	defer databasesMu.Unlock()
+
+	if db, ok := databases[path]; ok {
+		return db, nil
+	}
+
+	db, err := geoip.OpenDatabase(path, geoip.DefaultReloadInterval)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not open the geo database")
+	}
+	databases[path] = db
+
+	return db, nil
+}
+
+// toSet returns the upper cased codes, so the configured codes are not case sensitive
+func toSet(codes []string) map[string]bool {
+	set := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		set[strings.ToUpper(code)] = true
+	}
+
+	return set
+}
@@ -0,0 +1,64 @@
+package geo
+
+import (
+	"testing"
+
+	"github.com/hellofresh/janus/pkg/plugin"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeConfig(t *testing.T) {
+	config, err := decodeConfig(plugin.Config{
+		"database":       "/var/lib/GeoIP/GeoLite2-Country.mmdb",
+		"deny_countries": []string{"KP"},
+		"regions": []interface{}{map[string]interface{}{
+			"name":       "eu",
+			"continents": []string{"EU"},
+			"targets":    []interface{}{map[string]interface{}{"target": "http://eu.example.com"}},
+		}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, ActionAllow, config.DefaultAction)
+	assert.Equal(t, []string{"KP"}, config.DenyCountries)
+	require.Len(t, config.Regions, 1)
+	assert.Equal(t, "http://eu.example.com", config.Regions[0].Targets[0].Target)
+}
+
+func TestDecodeConfigInvalid(t *testing.T) {
+	invalid := map[string]plugin.Config{
+		"no database":    {},
+		"default action": {"database": "geo.mmdb", "default_action": "redirect"},
+		"region without locations": {"database": "geo.mmdb", "regions": []interface{}{map[string]interface{}{
+			"name":    "eu",
+			"targets": []interface{}{map[string]interface{}{"target": "http://eu.example.com"}},
+		}}},
+		"region without targets": {"database": "geo.mmdb", "regions": []interface{}{map[string]interface{}{
+			"name":      "eu",
+			"countries": []string{"DE"},
+		}}},
+		"invalid target": {"database": "geo.mmdb", "regions": []interface{}{map[string]interface{}{
+			"name":      "eu",
+			"countries": []string{"DE"},
+			"targets":   []interface{}{map[string]interface{}{"target": ""}},
+		}}},
+	}
+
+	for name, config := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := decodeConfig(config)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSetupMissingDatabase(t *testing.T) {
+	def := proxy.NewRouterDefinition(proxy.NewDefinition())
+	err := setupGeo(def, plugin.Config{"database": "/not/found.mmdb"})
+	assert.Error(t, err)
+
+	_, err = validateConfig(plugin.Config{"database": "/not/found.mmdb"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,243 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/hellofresh/janus/pkg/errors"
+	"github.com/hellofresh/janus/pkg/lock"
+	"github.com/hellofresh/janus/pkg/middleware"
+	"github.com/hellofresh/janus/pkg/plugin"
+	"github.com/hellofresh/janus/pkg/proxy"
+)
+
+const (
+	// maxKeyLength is the maximum length of the idempotency keys
+	maxKeyLength = 255
+	// maxBodySize is the maximum size of the fingerprinted request bodies and of the stored response bodies, the
+	// requests with a larger body are rejected and the responses with a larger body are not replayed
+	maxBodySize = 1 << 20
+	// pollInterval is how often a waiting request checks if the request in flight is completed
+	pollInterval = 50 * time.Millisecond
+
+	// ReplayedHeader is set on the replayed responses
+	ReplayedHeader = "Idempotent-Replayed"
+)
+
+var (
+	// ErrKeyInFlight is thrown when a request with the same key is being processed
+	ErrKeyInFlight = errors.New(http.StatusConflict, "a request with the same idempotency key is in progress")
+	// ErrInvalidKey is thrown when the idempotency key is too long
+	ErrInvalidKey = errors.New(http.StatusBadRequest, "idempotency key is too long")
+	// ErrKeyReused is thrown when the idempotency key was used by a request with a different body
+	ErrKeyReused = errors.New(http.StatusUnprocessableEntity, "idempotency key was used with a different request body")
+	// ErrBodyUnreadable is thrown when the request body could not be read to be fingerprinted
+	ErrBodyUnreadable = errors.New(http.StatusBadRequest, "could not read the request body")
+	// ErrBodyTooLarge is thrown when the request body is too large to be fingerprinted
+	ErrBodyTooLarge = errors.New(http.StatusRequestEntityTooLarge, "request body is too large for an idempotent request")
+)
+
+// Idempotency replays the stored response of the requests already processed with the same key
+type Idempotency struct {
+	store       Store
+	header      string
+	methods     map[string]bool
+	ttl         time.Duration
+	lockTTL     time.Duration
+	wait        bool
+	waitTimeout time.Duration
+}
+
+// NewIdempotency creates a new instance of Idempotency
+func NewIdempotency(store Store, config Config) *Idempotency {
+	methods := make(map[string]bool, len(config.Methods))
+	for _, method := range config.Methods {
+		methods[method] = true
+	}
+
+	return &Idempotency{
+		store:       store,
+		header:      config.Header,
+		methods:     methods,
+		ttl:         time.Duration(config.TTL),
+		lockTTL:     time.Duration(config.LockTTL),
+		wait:        config.Concurrent == ConcurrentWait,
+		waitTimeout: time.Duration(config.WaitTimeout),
+	}
+}
+
+// Handler is the middleware function. The requests of the configured methods with the key header are
+// proxied once per consumer, key and path, the following ones get the response of the first one until
+// the ttl expires, or ErrKeyReused when their body differs. The responses with a 5xx status are not
+// stored, so the request can be retried.
+func (m *Idempotency) Handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(m.header)
+		if key == "" || !m.methods[r.Method] {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxKeyLength {
+			plugin.Respond(w, r, plugin.ErrorResult(ErrInvalidKey, "invalid_idempotency_key"))
+			return
+		}
+
+		fingerprint, err := fingerprintBody(r)
+		if err == ErrBodyTooLarge {
+			plugin.Respond(w, r, plugin.ErrorResult(err, "request_body_too_large"))
+			return
+		}
+		if err != nil {
+			plugin.Respond(w, r, plugin.ErrorResult(ErrBodyUnreadable, "invalid_request_body"))
+			return
+		}
+
+		key = scope(r) + ":" + r.Method + ":" + r.URL.Path + ":" + key
+		logger := middleware.ContextLogger(r.Context()).WithField("idempotency_key", key)
+
+		response, token, err := m.acquire(r.Context(), key)
+		if err == ErrKeyInFlight {
+			plugin.Respond(w, r, plugin.ErrorResult(err, "idempotency_key_in_flight"))
+			return
+		}
+		if err != nil {
+			// the store is unavailable, the request is proxied without the idempotency guarantee
+			logger.WithError(err).Error("Could not look up the idempotency key")
+			handler.ServeHTTP(w, r)
+			return
+		}
+		if response != nil && response.Fingerprint != fingerprint {
+			plugin.Respond(w, r, plugin.ErrorResult(ErrKeyReused, "idempotency_key_reused"))
+			return
+		}
+		if response != nil {
+			plugin.Respond(w, r, replayed(response))
+			return
+		}
+
+		defer func() {
+			err := m.store.Unlock(token)
+			if err == lock.ErrNotHeld {
+				logger.Warn("The idempotency key lock expired before the request was completed")
+			} else if err != nil {
+				logger.WithError(err).Error("Could not release the idempotency key")
+			}
+		}()
+
+		rec := proxy.NewResponseRecorder(maxBodySize)
+		handler.ServeHTTP(rec.Wrap(w), r)
+
+		if response := recorded(rec); response != nil {
+			response.Fingerprint = fingerprint
+			if err := m.store.Set(key, response, m.ttl); err != nil {
+				logger.WithError(err).Error("Could not store the idempotent response")
+			}
+		}
+	})
+}
+
+// acquire returns the stored response of the key, or locks the key when there is none so the
+// request is proxied with the returned token. The concurrent requests with the key wait for the
+// request in flight or are rejected with ErrKeyInFlight.
+func (m *Idempotency) acquire(ctx context.Context, key string) (*Response, *lock.Token, error) {
+	deadline := time.Now().Add(m.waitTimeout)
+	for {
+		response, err := m.store.Get(key)
+		if response != nil || err != nil {
+			return response, nil, err
+		}
+
+		token, err := m.store.Lock(key, m.lockTTL)
+		if err != nil {
+			return nil, nil, err
+		}
+		if token != nil {
+			// the request in flight may have completed between the lookup and the lock
+			response, err := m.store.Get(key)
+			if response != nil || err != nil {
+				m.store.Unlock(token)
+				return response, nil, err
+			}
+			return nil, token, nil
+		}
+
+		if !m.wait || time.Now().After(deadline) {
+			return nil, nil, ErrKeyInFlight
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, nil, ErrKeyInFlight
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// scope returns the scope of the keys of the request, so the clients cannot replay the responses of
+// each other: the authenticated consumer, or the hash of the Authorization header when the request is
+// not authenticated by a plugin
+func scope(r *http.Request) string {
+	if consumer := middleware.ConsumerFromContext(r.Context()); consumer != "" {
+		return "consumer=" + consumer
+	}
+	if authorization := r.Header.Get("Authorization"); authorization != "" {
+		sum := sha256.Sum256([]byte(authorization))
+		return "authorization=" + hex.EncodeToString(sum[:])
+	}
+
+	return "anonymous"
+}
+
+// fingerprintBody returns the hash of the request body, the body is read and set back on the request. It
+// returns ErrBodyTooLarge when the body is larger than maxBodySize, the body is not read in full then.
+func fingerprintBody(r *http.Request) (string, error) {
+	var body []byte
+	if r.Body != nil {
+		if r.ContentLength > maxBodySize {
+			return "", ErrBodyTooLarge
+		}
+
+		var err error
+		if body, err = ioutil.ReadAll(io.LimitReader(r.Body, maxBodySize+1)); err != nil {
+			return "", err
+		}
+		if len(body) > maxBodySize {
+			return "", ErrBodyTooLarge
+		}
+		r.Body.Close()
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// replayed returns the result answering the request with the stored response
+func replayed(response *Response) *plugin.Result {
+	header := make(http.Header, len(response.Header)+1)
+	for name, values := range response.Header {
+		header[name] = values
+	}
+	header.Set(ReplayedHeader, "true")
+
+	return &plugin.Result{StatusCode: response.StatusCode, Header: header, Body: response.Body, Reason: "replayed"}
+}
+
+// recorded returns the recorded response, it returns nil when it must not be replayed
+func recorded(rec *proxy.ResponseRecorder) *Response {
+	statusCode, header := rec.StatusCode(), rec.Header()
+	if statusCode == 0 {
+		statusCode, header = http.StatusOK, make(http.Header)
+	}
+	if statusCode >= http.StatusInternalServerError || rec.Truncated() {
+		return nil
+	}
+
+	return &Response{StatusCode: statusCode, Header: header, Body: rec.Body()}
+}
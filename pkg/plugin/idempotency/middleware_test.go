@@ -0,0 +1,264 @@
+package idempotency
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hellofresh/janus/pkg/middleware"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestIdempotency(concurrent string) *Idempotency {
+	return NewIdempotency(NewMemoryStore(), Config{
+		Header:      DefaultHeader,
+		Methods:     []string{http.MethodPost},
+		TTL:         proxy.Duration(time.Hour),
+		LockTTL:     proxy.Duration(time.Minute),
+		Concurrent:  concurrent,
+		WaitTimeout: proxy.Duration(time.Second),
+	})
+}
+
+func newRequest(method string, path string, key string) *http.Request {
+	r := httptest.NewRequest(method, path, nil)
+	if key != "" {
+		r.Header.Set(DefaultHeader, key)
+	}
+
+	return r
+}
+
+// countingHandler counts the proxied requests and responds with the status
+func countingHandler(calls *int32, status int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(calls, 1)
+		w.Header().Set("X-Call", strconv.Itoa(int(n)))
+		w.WriteHeader(status)
+		w.Write([]byte("response"))
+	})
+}
+
+func TestIdempotencyReplaysResponse(t *testing.T) {
+	var calls int32
+	handler := newTestIdempotency(ConcurrentReject).Handler(countingHandler(&calls, http.StatusCreated))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, newRequest(http.MethodPost, "/orders", "abc"))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Empty(t, w.Header().Get(ReplayedHeader))
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, newRequest(http.MethodPost, "/orders", "abc"))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "response", w.Body.String())
+	assert.Equal(t, "1", w.Header().Get("X-Call"), "the headers of the first response are replayed")
+	assert.Equal(t, "true", w.Header().Get(ReplayedHeader))
+	assert.Equal(t, int32(1), calls)
+
+	handler.ServeHTTP(httptest.NewRecorder(), newRequest(http.MethodPost, "/orders", "def"))
+	handler.ServeHTTP(httptest.NewRecorder(), newRequest(http.MethodPost, "/payments", "abc"))
+	assert.Equal(t, int32(3), calls, "the keys are scoped to the path")
+}
+
+func TestIdempotencyReplayAccessLog(t *testing.T) {
+	var (
+		calls int32
+		out   bytes.Buffer
+	)
+	accessLog := middleware.NewAccessLog([]string{middleware.AccessLogStatus, middleware.AccessLogBytes, middleware.AccessLogShortCircuit}, &out)
+	handler := accessLog.Handler(newTestIdempotency(ConcurrentReject).Handler(countingHandler(&calls, http.StatusCreated)))
+
+	handler.ServeHTTP(httptest.NewRecorder(), newRequest(http.MethodPost, "/orders", "abc"))
+	handler.ServeHTTP(httptest.NewRecorder(), newRequest(http.MethodPost, "/orders", "abc"))
+	require.Equal(t, int32(1), calls)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2, "the replayed responses are logged")
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "", entry[middleware.AccessLogShortCircuit])
+
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &entry))
+	assert.Equal(t, float64(http.StatusCreated), entry[middleware.AccessLogStatus])
+	assert.Equal(t, float64(len("response")), entry[middleware.AccessLogBytes])
+	assert.Equal(t, "replayed", entry[middleware.AccessLogShortCircuit])
+}
+
+func TestIdempotencySkipsRequests(t *testing.T) {
+	var calls int32
+	handler := newTestIdempotency(ConcurrentReject).Handler(countingHandler(&calls, http.StatusOK))
+
+	for i := 0; i < 2; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), newRequest(http.MethodPost, "/orders", ""))
+		handler.ServeHTTP(httptest.NewRecorder(), newRequest(http.MethodGet, "/orders", "abc"))
+	}
+	assert.Equal(t, int32(4), calls, "the requests without key and of the other methods are proxied")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, newRequest(http.MethodPost, "/orders", strings.Repeat("a", maxKeyLength+1)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestIdempotencyDoesNotStoreServerErrors(t *testing.T) {
+	var calls int32
+	handler := newTestIdempotency(ConcurrentReject).Handler(countingHandler(&calls, http.StatusBadGateway))
+
+	handler.ServeHTTP(httptest.NewRecorder(), newRequest(http.MethodPost, "/orders", "abc"))
+	handler.ServeHTTP(httptest.NewRecorder(), newRequest(http.MethodPost, "/orders", "abc"))
+	assert.Equal(t, int32(2), calls)
+}
+
+func TestIdempotencyConcurrentRequests(t *testing.T) {
+	for _, concurrent := range []string{ConcurrentReject, ConcurrentWait} {
+		t.Run(concurrent, func(t *testing.T) {
+			var calls int32
+			started, release := make(chan struct{}), make(chan struct{})
+			handler := newTestIdempotency(concurrent).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&calls, 1)
+				close(started)
+				<-release
+				w.WriteHeader(http.StatusCreated)
+			}))
+
+			first := make(chan int)
+			go func() {
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, newRequest(http.MethodPost, "/orders", "abc"))
+				first <- w.Code
+			}()
+			<-started
+
+			second := make(chan int)
+			go func() {
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, newRequest(http.MethodPost, "/orders", "abc"))
+				second <- w.Code
+			}()
+
+			if concurrent == ConcurrentReject {
+				assert.Equal(t, http.StatusConflict, <-second)
+				close(release)
+				assert.Equal(t, http.StatusCreated, <-first)
+			} else {
+				time.Sleep(2 * pollInterval)
+				close(release)
+				assert.Equal(t, http.StatusCreated, <-first)
+				assert.Equal(t, http.StatusCreated, <-second, "the waiting request gets the first response")
+			}
+			assert.Equal(t, int32(1), calls)
+		})
+	}
+}
+
+func TestRecordedResponse(t *testing.T) {
+	rec := proxy.NewResponseRecorder(maxBodySize)
+	rw := rec.Wrap(httptest.NewRecorder())
+	rw.Header().Set("Content-Type", "text/plain")
+	rw.Write([]byte("hello"))
+
+	response := recorded(rec)
+	require.NotNil(t, response)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "text/plain", response.Header.Get("Content-Type"))
+	assert.Equal(t, "hello", string(response.Body))
+
+	rec = proxy.NewResponseRecorder(maxBodySize)
+	rec.Wrap(httptest.NewRecorder()).Write(make([]byte, maxBodySize+1))
+	assert.Nil(t, recorded(rec), "the large responses are not stored")
+
+	rec = proxy.NewResponseRecorder(maxBodySize)
+	rec.Wrap(httptest.NewRecorder()).WriteHeader(http.StatusBadGateway)
+	assert.Nil(t, recorded(rec), "the server errors are not stored")
+}
+
+func TestIdempotencyScopesKeysToConsumers(t *testing.T) {
+	var calls int32
+	handler := newTestIdempotency(ConcurrentReject).Handler(countingHandler(&calls, http.StatusCreated))
+
+	serve := func(consumer string, authorization string) *httptest.ResponseRecorder {
+		r := newRequest(http.MethodPost, "/orders", "abc")
+		if consumer != "" {
+			r = r.WithContext(middleware.WithConsumer(r.Context(), consumer))
+		}
+		if authorization != "" {
+			r.Header.Set("Authorization", authorization)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	serve("alice", "")
+	assert.Equal(t, "1", serve("alice", "").Header().Get("X-Call"))
+	assert.Equal(t, "2", serve("bob", "").Header().Get("X-Call"), "the keys are scoped to the consumer")
+	assert.Equal(t, "3", serve("", "Bearer alice").Header().Get("X-Call"))
+	assert.Equal(t, "3", serve("", "Bearer alice").Header().Get("X-Call"))
+	assert.Equal(t, "4", serve("", "Bearer bob").Header().Get("X-Call"), "the keys are scoped to the Authorization header")
+	assert.Equal(t, "5", serve("", "").Header().Get("X-Call"))
+	assert.Equal(t, int32(5), calls)
+}
+
+func TestIdempotencyRejectsReusedKeys(t *testing.T) {
+	var calls int32
+	handler := newTestIdempotency(ConcurrentReject).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		body, _ := ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	}))
+
+	serve := func(body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+		r.Header.Set(DefaultHeader, "abc")
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve(`{"amount": 10}`)
+	assert.Equal(t, `{"amount": 10}`, w.Body.String(), "the body is proxied once fingerprinted")
+
+	w = serve(`{"amount": 10}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "true", w.Header().Get(ReplayedHeader))
+
+	w = serve(`{"amount": 20}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code, "the key is reused with a different body")
+	assert.Empty(t, w.Header().Get(ReplayedHeader))
+	assert.Equal(t, int32(1), calls)
+}
+
+func TestIdempotencyRejectsLargeBodies(t *testing.T) {
+	var calls int32
+	handler := newTestIdempotency(ConcurrentReject).Handler(countingHandler(&calls, http.StatusCreated))
+
+	body := strings.Repeat("a", maxBodySize+1)
+	for scenario, contentLength := range map[string]int64{"content length": int64(len(body)), "chunked": -1} {
+		r := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+		r.ContentLength = contentLength
+		r.Header.Set(DefaultHeader, "abc")
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code, scenario)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusCreated, w.Code, "the requests without a key are not limited")
+	assert.Equal(t, int32(1), calls)
+}
@@ -0,0 +1,48 @@
+package idempotency
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/hellofresh/janus/pkg/lock"
+)
+
+// redisStore keeps the keys in redis, so they are shared by the Janus instances
+type redisStore struct {
+	lock.Locker
+
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore creates a Store shared by the Janus instances using the redis client
+func NewRedisStore(client *redis.Client, prefix string) Store {
+	return &redisStore{Locker: lock.NewRedisLocker(client, prefix+":lock:"), client: client, prefix: prefix}
+}
+
+func (s *redisStore) Get(key string) (*Response, error) {
+	data, err := s.client.Get(s.prefix + ":response:" + key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var response Response
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+func (s *redisStore) Set(key string, response *Response, ttl time.Duration) error {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+
+	return s.client.Set(s.prefix+":response:"+key, data, ttl).Err()
+}
@@ -0,0 +1,120 @@
+package idempotency
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/go-redis/redis"
+	"github.com/hellofresh/janus/pkg/plugin"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultHeader is the request header holding the idempotency key when none is set
+	DefaultHeader = "Idempotency-Key"
+	// DefaultTTL is how long the responses are replayed when no ttl is set
+	DefaultTTL = 24 * time.Hour
+	// DefaultLockTTL is how long a key stays in flight when no lock ttl is set, so the key is
+	// released when the instance proxying the request dies
+	DefaultLockTTL = time.Minute
+	// DefaultWaitTimeout is how long the concurrent requests wait when no wait timeout is set
+	DefaultWaitTimeout = 10 * time.Second
+	// DefaultPrefix is the default prefix of the keys in redis
+	DefaultPrefix = "idempotency"
+
+	// ConcurrentReject rejects the concurrent requests with the same key with 409 Conflict
+	ConcurrentReject = "reject"
+	// ConcurrentWait makes the concurrent requests with the same key wait for the response of the first one
+	ConcurrentWait = "wait"
+)
+
+// Config represents the Idempotency configuration
+type Config struct {
+	Header      string         `json:"header"`
+	Methods     []string       `json:"methods"`
+	TTL         proxy.Duration `json:"ttl"`
+	LockTTL     proxy.Duration `json:"lock_ttl"`
+	Concurrent  string         `json:"concurrent" valid:"in(reject|wait)~concurrent must be one of reject or wait"`
+	WaitTimeout proxy.Duration `json:"wait_timeout"`
+	Policy      string         `json:"policy" valid:"in(local|redis)~policy must be one of local or redis"`
+	RedisConfig redisConfig    `json:"redis"`
+}
+
+type redisConfig struct {
+	DSN    string `json:"dsn"`
+	Prefix string `json:"prefix"`
+}
+
+func init() {
+	plugin.RegisterPlugin("idempotency", plugin.Plugin{
+		Action:              setupIdempotency,
+		Validate:            validateConfig,
+		Stateful:            true,
+		BuffersResponseBody: true,
+	})
+}
+
+func setupIdempotency(def *proxy.RouterDefinition, rawConfig plugin.Config) error {
+	config, err := decodeConfig(rawConfig)
+	if err != nil {
+		return err
+	}
+
+	store, err := newStore(config)
+	if err != nil {
+		return err
+	}
+
+	def.AddMiddleware(NewIdempotency(store, config).Handler)
+	return nil
+}
+
+func validateConfig(rawConfig plugin.Config) (bool, error) {
+	config, err := decodeConfig(rawConfig)
+	if err != nil {
+		return false, err
+	}
+
+	return govalidator.ValidateStruct(config)
+}
+
+func decodeConfig(rawConfig plugin.Config) (Config, error) {
+	config := Config{
+		Header:      DefaultHeader,
+		Methods:     []string{http.MethodPost, http.MethodPatch},
+		TTL:         proxy.Duration(DefaultTTL),
+		LockTTL:     proxy.Duration(DefaultLockTTL),
+		Concurrent:  ConcurrentReject,
+		WaitTimeout: proxy.Duration(DefaultWaitTimeout),
+		Policy:      "local",
+		RedisConfig: redisConfig{Prefix: DefaultPrefix},
+	}
+	if err := plugin.Decode(rawConfig, &config); err != nil {
+		return config, err
+	}
+
+	for i, method := range config.Methods {
+		config.Methods[i] = strings.ToUpper(method)
+	}
+	if config.TTL <= 0 || config.LockTTL <= 0 || config.WaitTimeout <= 0 {
+		return config, errors.New("idempotency ttl, lock ttl and wait timeout must be greater than zero")
+	}
+
+	return config, nil
+}
+
+func newStore(config Config) (Store, error) {
+	if config.Policy != "redis" {
+		return NewMemoryStore(), nil
+	}
+
+	option, err := redis.ParseURL(config.RedisConfig.DSN)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid idempotency redis DSN")
+	}
+
+	return NewRedisStore(redis.NewClient(option), config.RedisConfig.Prefix), nil
+}
@@ -0,0 +1,50 @@
+package idempotency
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hellofresh/janus/pkg/plugin"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetup(t *testing.T) {
+	def := proxy.NewRouterDefinition(proxy.NewDefinition())
+	err := setupIdempotency(def, plugin.Config{"ttl": "1h"})
+	assert.NoError(t, err)
+
+	assert.Len(t, def.Middleware(), 1)
+}
+
+func TestDecodeConfig(t *testing.T) {
+	config, err := decodeConfig(plugin.Config{})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultHeader, config.Header)
+	assert.Equal(t, []string{http.MethodPost, http.MethodPatch}, config.Methods)
+	assert.Equal(t, proxy.Duration(DefaultTTL), config.TTL)
+	assert.Equal(t, ConcurrentReject, config.Concurrent)
+
+	config, err = decodeConfig(plugin.Config{"header": "X-Request-Key", "methods": []string{"put"}, "ttl": "10m"})
+	require.NoError(t, err)
+	assert.Equal(t, "X-Request-Key", config.Header)
+	assert.Equal(t, []string{http.MethodPut}, config.Methods)
+	assert.Equal(t, proxy.Duration(10*time.Minute), config.TTL)
+}
+
+func TestValidateConfig(t *testing.T) {
+	valid, err := validateConfig(plugin.Config{"concurrent": "wait"})
+	assert.NoError(t, err)
+	assert.True(t, valid)
+
+	_, err = validateConfig(plugin.Config{"concurrent": "queue"})
+	assert.Error(t, err)
+
+	_, err = validateConfig(plugin.Config{"policy": "memcached"})
+	assert.Error(t, err)
+
+	_, err = validateConfig(plugin.Config{"ttl": "-1s"})
+	assert.Error(t, err)
+}
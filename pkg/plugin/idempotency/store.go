@@ -0,0 +1,91 @@
+package idempotency
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hellofresh/janus/pkg/lock"
+)
+
+// sweepInterval is how often the memory store removes the expired keys
+const sweepInterval = time.Minute
+
+// Response is the upstream response replayed to the retried requests, with the fingerprint of the body
+// of the request it answered
+type Response struct {
+	StatusCode  int         `json:"status_code"`
+	Header      http.Header `json:"header"`
+	Body        []byte      `json:"body"`
+	Fingerprint string      `json:"fingerprint"`
+}
+
+// Store keeps the responses of the processed keys and the keys of the requests in flight, the keys are
+// locked while their request is in flight until they are unlocked or the lock ttl expires
+type Store interface {
+	lock.Locker
+
+	// Get returns the response stored for the key, it returns nil when there is none
+	Get(key string) (*Response, error)
+	// Set stores the response of the key for the ttl
+	Set(key string, response *Response, ttl time.Duration) error
+}
+
+type memoryEntry struct {
+	response  *Response
+	expiresAt time.Time
+}
+
+// memoryStore keeps the keys in the memory of the Janus instance
+type memoryStore struct {
+	lock.Locker
+
+	mu        sync.Mutex
+	responses map[string]memoryEntry
+	swept     time.Time
+}
+
+// NewMemoryStore creates a Store local to the Janus instance
+func NewMemoryStore() Store {
+	return &memoryStore{
+		Locker:    lock.NewMemoryLocker(),
+		responses: make(map[string]memoryEntry),
+		swept:     time.Now(),
+	}
+}
+
+func (s *memoryStore) Get(key string) (*Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.responses[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, nil
+	}
+
+	return entry.response, nil
+}
+
+func (s *memoryStore) Set(key string, response *Response, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep()
+	s.responses[key] = memoryEntry{response: response, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+// sweep removes the expired keys, it must be called with the store locked
+func (s *memoryStore) sweep() {
+	now := time.Now()
+	if now.Sub(s.swept) < sweepInterval {
+		return
+	}
+	s.swept = now
+
+	for key, entry := range s.responses {
+		if now.After(entry.expiresAt) {
+			delete(s.responses, key)
+		}
+	}
+}
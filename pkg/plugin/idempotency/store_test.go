@@ -0,0 +1,63 @@
+package idempotency
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hellofresh/janus/pkg/lock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+
+	response, err := store.Get("key")
+	require.NoError(t, err)
+	assert.Nil(t, response)
+
+	expected := &Response{StatusCode: http.StatusCreated, Body: []byte("created")}
+	require.NoError(t, store.Set("key", expected, time.Hour))
+	response, err = store.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, expected, response)
+
+	require.NoError(t, store.Set("expired", expected, -time.Second))
+	response, err = store.Get("expired")
+	require.NoError(t, err)
+	assert.Nil(t, response)
+}
+
+func TestMemoryStoreLock(t *testing.T) {
+	store := NewMemoryStore()
+
+	token, err := store.Lock("key", time.Hour)
+	require.NoError(t, err)
+	assert.NotNil(t, token)
+
+	other, err := store.Lock("key", time.Hour)
+	require.NoError(t, err)
+	assert.Nil(t, other, "the key is in flight")
+
+	require.NoError(t, store.Unlock(token))
+	token, err = store.Lock("key", time.Millisecond)
+	require.NoError(t, err)
+	assert.NotNil(t, token)
+
+	time.Sleep(2 * time.Millisecond)
+	other, err = store.Lock("key", time.Hour)
+	require.NoError(t, err)
+	assert.NotNil(t, other, "the expired lock is released")
+	assert.Equal(t, lock.ErrNotHeld, store.Unlock(token), "the expired lock of the first request is not released")
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	store := NewMemoryStore().(*memoryStore)
+	store.Set("expired", &Response{}, -time.Second)
+
+	store.swept = time.Now().Add(-2 * sweepInterval)
+	store.Set("key", &Response{}, time.Hour)
+
+	assert.Len(t, store.responses, 1)
+}
@@ -0,0 +1,142 @@
+package negotiation
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/hellofresh/janus/pkg/errors"
+	"github.com/hellofresh/janus/pkg/middleware"
+	"github.com/hellofresh/janus/pkg/proxy"
+)
+
+// ErrNotAcceptable is thrown when none of the supported media types is accepted by the client
+var ErrNotAcceptable = errors.New(http.StatusNotAcceptable, "none of the acceptable media types can be served")
+
+type mediaType struct {
+	value   string
+	main    string
+	sub     string
+	targets proxy.Targets
+}
+
+// accepted is a media range of the Accept header with its quality value
+type accepted struct {
+	main    string
+	sub     string
+	quality float64
+}
+
+// Negotiation picks the media type of the response among the supported ones, from the client Accept
+// header. The upstreams receive the chosen type as the only accepted one, and the requests are sent
+// to the upstream targets of the type when it has its own.
+type Negotiation struct {
+	mediaTypes []mediaType
+}
+
+// NewNegotiation creates a new instance of Negotiation
+func NewNegotiation(config Config) *Negotiation {
+	mediaTypes := make([]mediaType, 0, len(config.MediaTypes))
+	for _, m := range config.MediaTypes {
+		value, _, _ := mime.ParseMediaType(m.Type)
+		parts := strings.SplitN(value, "/", 2)
+		mediaTypes = append(mediaTypes, mediaType{value: m.Type, main: parts[0], sub: parts[1], targets: m.Targets})
+	}
+
+	return &Negotiation{mediaTypes: mediaTypes}
+}
+
+// Handler is the middleware function
+func (m *Negotiation) Handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+
+		chosen, ok := m.negotiate(r.Header.Get("Accept"))
+		if !ok {
+			middleware.ContextLogger(r.Context()).WithField("accept", r.Header.Get("Accept")).Debug("No acceptable media type")
+			errors.Handler(w, ErrNotAcceptable)
+			return
+		}
+
+		r.Header.Set("Accept", chosen.value)
+		if len(chosen.targets) > 0 {
+			r = r.WithContext(proxy.WithUpstreamTargets(r.Context(), chosen.targets))
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// negotiate returns the supported media type with the highest quality value, the first supported one
+// wins the ties. All the media types are acceptable without the Accept header.
+func (m *Negotiation) negotiate(header string) (mediaType, bool) {
+	if strings.TrimSpace(header) == "" {
+		return m.mediaTypes[0], true
+	}
+
+	ranges := parseAccept(header)
+
+	var (
+		best        mediaType
+		bestQuality float64
+	)
+	for _, mediaType := range m.mediaTypes {
+		if quality := qualityOf(ranges, mediaType); quality > bestQuality {
+			best, bestQuality = mediaType, quality
+		}
+	}
+
+	return best, bestQuality > 0
+}
+
+// qualityOf returns the quality value of the most specific media range matching the media type
+func qualityOf(ranges []accepted, m mediaType) float64 {
+	quality, specificity := 0.0, -1
+	for _, r := range ranges {
+		var s int
+		switch {
+		case r.main == m.main && r.sub == m.sub:
+			s = 2
+		case r.main == m.main && r.sub == "*":
+			s = 1
+		case r.main == "*" && r.sub == "*":
+			s = 0
+		default:
+			continue
+		}
+
+		if s > specificity {
+			quality, specificity = r.quality, s
+		}
+	}
+
+	return quality
+}
+
+// parseAccept parses the media ranges of the Accept header, the invalid ones are skipped
+func parseAccept(header string) []accepted {
+	var ranges []accepted
+	for _, part := range strings.Split(header, ",") {
+		value, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		parts := strings.SplitN(value, "/", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil || quality < 0 || quality > 1 {
+				continue
+			}
+		}
+
+		ranges = append(ranges, accepted{main: parts[0], sub: parts[1], quality: quality})
+	}
+
+	return ranges
+}
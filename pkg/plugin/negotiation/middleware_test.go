@@ -0,0 +1,75 @@
+package negotiation
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestNegotiation() *Negotiation {
+	return NewNegotiation(Config{MediaTypes: []MediaType{
+		{Type: "application/json"},
+		{Type: "application/xml", Targets: proxy.Targets{{Target: "http://xml.example.com"}}},
+	}})
+}
+
+func TestNegotiationAccept(t *testing.T) {
+	tests := []struct {
+		accept   string
+		expected string
+	}{
+		{accept: "", expected: "application/json"},
+		{accept: "*/*", expected: "application/json"},
+		{accept: "application/xml", expected: "application/xml"},
+		{accept: "application/json;q=0.5, application/xml", expected: "application/xml"},
+		{accept: "application/*;q=0.8, application/json;q=0.2", expected: "application/xml"},
+		{accept: "text/html, application/xml;q=0.9, */*;q=0.1", expected: "application/xml"},
+		{accept: "application/xml;q=0.5, application/json;q=0.5", expected: "application/json"},
+		{accept: "application/json;q=invalid, application/xml", expected: "application/xml"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.accept, func(t *testing.T) {
+			var accept string
+			var targets proxy.Targets
+			handler := newTestNegotiation().Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				accept = r.Header.Get("Accept")
+				targets, _ = proxy.UpstreamTargetsFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept", test.accept)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, test.expected, accept)
+			assert.Equal(t, "Accept", w.Header().Get("Vary"))
+			if test.expected == "application/xml" {
+				assert.Len(t, targets, 1, "the media type targets are used")
+			} else {
+				assert.Empty(t, targets)
+			}
+		})
+	}
+}
+
+func TestNegotiationNotAcceptable(t *testing.T) {
+	for _, accept := range []string{"text/html", "application/json;q=0, application/xml;q=0", "*/*;q=0"} {
+		t.Run(accept, func(t *testing.T) {
+			handler := newTestNegotiation().Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				t.Fatal("the request must not be proxied")
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept", accept)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusNotAcceptable, w.Code)
+		})
+	}
+}
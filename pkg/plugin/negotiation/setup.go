@@ -0,0 +1,72 @@
+package negotiation
+
+import (
+	"mime"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/hellofresh/janus/pkg/plugin"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/pkg/errors"
+)
+
+// MediaType is a media type served by the API, optionally by its own upstream targets
+type MediaType struct {
+	Type    string        `json:"type"`
+	Targets proxy.Targets `json:"targets"`
+}
+
+// Config represents the content negotiation configuration
+type Config struct {
+	// MediaTypes are the supported media types, in the order of preference of the API
+	MediaTypes []MediaType `json:"media_types"`
+}
+
+func init() {
+	plugin.RegisterPlugin("content_negotiation", plugin.Plugin{
+		Action:   setupNegotiation,
+		Validate: validateConfig,
+	})
+}
+
+func setupNegotiation(def *proxy.RouterDefinition, rawConfig plugin.Config) error {
+	config, err := decodeConfig(rawConfig)
+	if err != nil {
+		return err
+	}
+
+	def.AddMiddleware(NewNegotiation(config).Handler)
+	return nil
+}
+
+func validateConfig(rawConfig plugin.Config) (bool, error) {
+	if _, err := decodeConfig(rawConfig); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func decodeConfig(rawConfig plugin.Config) (Config, error) {
+	var config Config
+	if err := plugin.Decode(rawConfig, &config); err != nil {
+		return config, err
+	}
+
+	if len(config.MediaTypes) == 0 {
+		return config, errors.New("content negotiation media types are not set")
+	}
+
+	for _, mediaType := range config.MediaTypes {
+		parsed, _, err := mime.ParseMediaType(mediaType.Type)
+		if err != nil || parsed == "*/*" || !govalidator.Matches(parsed, `^[a-z0-9!#$&^_.+-]+/[a-z0-9!#$&^_.+-]+$`) {
+			return config, errors.Errorf("invalid content negotiation media type %q", mediaType.Type)
+		}
+		for _, target := range mediaType.Targets {
+			if _, err := govalidator.ValidateStruct(target); err != nil {
+				return config, errors.Wrapf(err, "invalid target of the media type %q", mediaType.Type)
+			}
+		}
+	}
+
+	return config, nil
+}
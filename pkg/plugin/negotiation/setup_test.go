@@ -0,0 +1,44 @@
+package negotiation
+
+import (
+	"testing"
+
+	"github.com/hellofresh/janus/pkg/plugin"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetup(t *testing.T) {
+	def := proxy.NewRouterDefinition(proxy.NewDefinition())
+	err := setupNegotiation(def, plugin.Config{
+		"media_types": []interface{}{
+			map[string]interface{}{"type": "application/json"},
+			map[string]interface{}{
+				"type":    "application/xml",
+				"targets": []interface{}{map[string]interface{}{"target": "http://xml.example.com"}},
+			},
+		},
+	})
+	require.NoError(t, err)
+	assert.Len(t, def.Middleware(), 1)
+}
+
+func TestDecodeConfigInvalid(t *testing.T) {
+	invalid := map[string]plugin.Config{
+		"no media types": {},
+		"wildcard":       {"media_types": []interface{}{map[string]interface{}{"type": "*/*"}}},
+		"no subtype":     {"media_types": []interface{}{map[string]interface{}{"type": "application"}}},
+		"invalid target": {"media_types": []interface{}{map[string]interface{}{
+			"type":    "application/json",
+			"targets": []interface{}{map[string]interface{}{"target": ""}},
+		}}},
+	}
+
+	for name, config := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := decodeConfig(config)
+			assert.Error(t, err)
+		})
+	}
+}
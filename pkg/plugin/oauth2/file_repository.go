@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"sync"
 
+	"github.com/hellofresh/janus/pkg/config"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -35,6 +36,12 @@ func NewFileSystemRepository(dir string) (*FileSystemRepository, error) {
 			}
 
 			oauthServer := repo.parseOAuthServer(oauthServerRaw)
+			// the oauth server files may refer to the environment variables, e.g. for the client secrets
+			if err = config.ExpandEnv(oauthServer); err != nil {
+				log.WithError(err).WithField("path", filePath).Error("Couldn't interpolate the oauth server file")
+				return nil, err
+			}
+
 			if err = repo.Add(oauthServer); err != nil {
 				log.WithError(err).Error("Can't add the definition to the repository")
 				return nil, err
@@ -41,6 +41,13 @@ type Manager interface {
 	IsKeyAuthorized(ctx context.Context, accessToken string) bool
 }
 
+// DependentManager is a manager checking the tokens with an external dependency, it tells the tokens
+// which could not be checked because the dependency failed apart from the unauthorized ones
+type DependentManager interface {
+	Manager
+	CheckKey(ctx context.Context, accessToken string) (bool, error)
+}
+
 // ManagerFactory is used for creating a new manager
 type ManagerFactory struct {
 	oAuthServer *OAuth
@@ -7,7 +7,9 @@ import (
 
 	"github.com/hellofresh/janus/pkg/errors"
 	"github.com/hellofresh/janus/pkg/metrics"
+	"github.com/hellofresh/janus/pkg/middleware"
 	obs "github.com/hellofresh/janus/pkg/observability"
+	"github.com/hellofresh/janus/pkg/plugin"
 	"github.com/hellofresh/stats-go/bucket"
 	log "github.com/sirupsen/logrus"
 	"go.opencensus.io/stats"
@@ -28,6 +30,9 @@ var (
 	ErrBearerMalformed = errors.New(http.StatusBadRequest, "bearer token malformed")
 	// ErrAccessTokenNotAuthorized is used when the access token is not found on the storage
 	ErrAccessTokenNotAuthorized = errors.New(http.StatusUnauthorized, "access token not authorized")
+	// ErrAuthProviderUnavailable is used when the access token could not be checked because the
+	// authentication provider failed, and the requests are failed closed
+	ErrAuthProviderUnavailable = errors.New(http.StatusServiceUnavailable, "authentication provider unavailable")
 )
 
 // ContextKey is used to create context keys that are concurrent safe
@@ -37,60 +42,71 @@ func (c ContextKey) String() string {
 	return "janus." + string(c)
 }
 
-// NewKeyExistsMiddleware creates a new instance of KeyExistsMiddleware
-func NewKeyExistsMiddleware(manager Manager) func(http.Handler) http.Handler {
-	return func(handler http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			log.Debug("Starting Oauth2KeyExists middleware")
-			statsClient := metrics.WithContext(r.Context())
+// NewKeyExistsMiddleware creates a new instance of KeyExistsMiddleware, the requests whose token could
+// not be checked by a dependent manager are answered by the dependency error policy
+func NewKeyExistsMiddleware(manager Manager, onDependencyError plugin.DependencyErrorPolicy) func(http.Handler) http.Handler {
+	return plugin.ShortCircuit(func(r *http.Request) (*http.Request, *plugin.Result) {
+		log.Debug("Starting Oauth2KeyExists middleware")
+		statsClient := metrics.WithContext(r.Context())
 
-			logger := log.WithFields(log.Fields{
+		logger := middleware.ContextLogger(r.Context()).WithFields(log.Fields{
+			"path":   r.RequestURI,
+			"origin": r.RemoteAddr,
+		})
+
+		// We're using OAuth, start checking for access keys
+		authHeaderValue := r.Header.Get("Authorization")
+		parts := strings.Split(authHeaderValue, " ")
+		if len(parts) < 2 {
+			logger.Warn("Attempted access with malformed header, no auth header found.")
+			statsClient.TrackOperation(tokensSection, bucket.MetricOperation{"key-exists", "header"}, nil, false)
+			stats.Record(r.Context(), obs.MOAuth2MissingHeader.M(1))
+			return r, plugin.ErrorResult(ErrAuthorizationFieldNotFound, "unauthorized")
+		}
+		statsClient.TrackOperation(tokensSection, bucket.MetricOperation{"key-exists", "header"}, nil, true)
+
+		if strings.ToLower(parts[0]) != "bearer" {
+			logger.Warn("Bearer token malformed")
+			statsClient.TrackOperation(tokensSection, bucket.MetricOperation{"key-exists", "malformed"}, nil, false)
+			stats.Record(r.Context(), obs.MOAuth2MalformedHeader.M(1))
+			return r, plugin.ErrorResult(ErrBearerMalformed, "unauthorized")
+		}
+		statsClient.TrackOperation(tokensSection, bucket.MetricOperation{"key-exists", "malformed"}, nil, true)
+
+		accessToken := parts[1]
+		keyExists, err := checkKey(r.Context(), manager, accessToken)
+		if err != nil {
+			if onDependencyError.Allows(r, "oauth2", err) {
+				return r, nil
+			}
+			return r, plugin.ErrorResult(ErrAuthProviderUnavailable, "dependency_error")
+		}
+		statsClient.TrackOperation(tokensSection, bucket.MetricOperation{"key-exists", "authorized"}, nil, keyExists)
+		if keyExists {
+			stats.Record(r.Context(), obs.MOAuth2Authorized.M(1))
+		} else {
+			stats.Record(r.Context(), obs.MOAuth2Unauthorized.M(1))
+		}
+
+		if !keyExists {
+			middleware.ContextLogger(r.Context()).WithFields(log.Fields{
 				"path":   r.RequestURI,
 				"origin": r.RemoteAddr,
-			})
-
-			// We're using OAuth, start checking for access keys
-			authHeaderValue := r.Header.Get("Authorization")
-			parts := strings.Split(authHeaderValue, " ")
-			if len(parts) < 2 {
-				logger.Warn("Attempted access with malformed header, no auth header found.")
-				statsClient.TrackOperation(tokensSection, bucket.MetricOperation{"key-exists", "header"}, nil, false)
-				stats.Record(r.Context(), obs.MOAuth2MissingHeader.M(1))
-				errors.Handler(w, ErrAuthorizationFieldNotFound)
-				return
-			}
-			statsClient.TrackOperation(tokensSection, bucket.MetricOperation{"key-exists", "header"}, nil, true)
-
-			if strings.ToLower(parts[0]) != "bearer" {
-				logger.Warn("Bearer token malformed")
-				statsClient.TrackOperation(tokensSection, bucket.MetricOperation{"key-exists", "malformed"}, nil, false)
-				stats.Record(r.Context(), obs.MOAuth2MalformedHeader.M(1))
-				errors.Handler(w, ErrBearerMalformed)
-				return
-			}
-			statsClient.TrackOperation(tokensSection, bucket.MetricOperation{"key-exists", "malformed"}, nil, true)
-
-			accessToken := parts[1]
-			keyExists := manager.IsKeyAuthorized(r.Context(), accessToken)
-			statsClient.TrackOperation(tokensSection, bucket.MetricOperation{"key-exists", "authorized"}, nil, keyExists)
-			if keyExists {
-				stats.Record(r.Context(), obs.MOAuth2Authorized.M(1))
-			} else {
-				stats.Record(r.Context(), obs.MOAuth2Unauthorized.M(1))
-			}
+				"key":    accessToken,
+			}).Debug("Attempted access with invalid key.")
+			return r, plugin.ErrorResult(ErrAccessTokenNotAuthorized, "unauthorized")
+		}
 
-			if !keyExists {
-				log.WithFields(log.Fields{
-					"path":   r.RequestURI,
-					"origin": r.RemoteAddr,
-					"key":    accessToken,
-				}).Debug("Attempted access with invalid key.")
-				errors.Handler(w, ErrAccessTokenNotAuthorized)
-				return
-			}
+		ctx := context.WithValue(r.Context(), AuthHeaderValue, accessToken)
+		return r.WithContext(ctx), nil
+	})
+}
 
-			ctx := context.WithValue(r.Context(), AuthHeaderValue, accessToken)
-			handler.ServeHTTP(w, r.WithContext(ctx))
-		})
+// checkKey checks the access token with the manager, the error tells the token could not be checked
+func checkKey(ctx context.Context, manager Manager, accessToken string) (bool, error) {
+	if dependent, ok := manager.(DependentManager); ok {
+		return dependent.CheckKey(ctx, accessToken)
 	}
+
+	return manager.IsKeyAuthorized(ctx, accessToken), nil
 }
@@ -4,46 +4,41 @@ import (
 	"net/http"
 
 	"github.com/hellofresh/janus/pkg/jwt"
+	"github.com/hellofresh/janus/pkg/plugin"
 	log "github.com/sirupsen/logrus"
 )
 
-// NewRevokeRulesMiddleware creates a new revoke rules middleware
+// NewRevokeRulesMiddleware creates a new revoke rules middleware, the requests are revoked when a rule
+// does not allow their token claims
 func NewRevokeRulesMiddleware(parser *jwt.Parser, accessRules []*AccessRule) func(http.Handler) http.Handler {
-	return func(handler http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			log.WithField("rules", len(accessRules)).Debug("Starting revoke rules middleware")
-
-			// If no rules are set then lets not parse the token to avoid performance issues
-			if len(accessRules) < 1 {
-				handler.ServeHTTP(w, r)
-				return
-			}
-
-			token, err := parser.ParseFromRequest(r)
-			if err != nil {
-				log.WithError(err).Debug("Could not parse the JWT")
-				handler.ServeHTTP(w, r)
-				return
-			}
+	return plugin.ShortCircuit(func(r *http.Request) (*http.Request, *plugin.Result) {
+		log.WithField("rules", len(accessRules)).Debug("Starting revoke rules middleware")
+
+		// If no rules are set then lets not parse the token to avoid performance issues
+		if len(accessRules) < 1 {
+			return r, nil
+		}
+
+		token, err := parser.ParseFromRequest(r)
+		if err != nil {
+			log.WithError(err).Debug("Could not parse the JWT")
+			return r, nil
+		}
+
+		if claims, ok := parser.GetMapClaims(token); ok && token.Valid {
+			for _, rule := range accessRules {
+				allowed, err := rule.IsAllowed(claims)
+				if err != nil {
+					log.WithError(err).Debug("Rule is not allowed")
+					continue
+				}
 
-			if claims, ok := parser.GetMapClaims(token); ok && token.Valid {
-				for _, rule := range accessRules {
-					allowed, err := rule.IsAllowed(claims)
-					if err != nil {
-						log.WithError(err).Debug("Rule is not allowed")
-						continue
-					}
-
-					if allowed {
-						handler.ServeHTTP(w, r)
-					} else {
-						w.WriteHeader(http.StatusUnauthorized)
-						return
-					}
+				if !allowed {
+					return r, &plugin.Result{StatusCode: http.StatusUnauthorized, Reason: "revoked"}
 				}
 			}
+		}
 
-			handler.ServeHTTP(w, r)
-		})
-	}
+		return r, nil
+	})
 }
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/hellofresh/janus/pkg/plugin"
 	"github.com/hellofresh/janus/pkg/test"
 	"github.com/stretchr/testify/assert"
 )
@@ -25,7 +26,7 @@ func TestContextKey(t *testing.T) {
 
 func TestValidKeyStorage(t *testing.T) {
 	manager := &mockManager{true}
-	mw := NewKeyExistsMiddleware(manager)
+	mw := NewKeyExistsMiddleware(manager, plugin.FailClosed)
 
 	w, err := test.Record(
 		"GET",
@@ -44,7 +45,7 @@ func TestValidKeyStorage(t *testing.T) {
 
 func TestWrongAuthHeader(t *testing.T) {
 	manager := &mockManager{false}
-	mw := NewKeyExistsMiddleware(manager)
+	mw := NewKeyExistsMiddleware(manager, plugin.FailClosed)
 
 	w, err := test.Record(
 		"GET",
@@ -63,7 +64,7 @@ func TestWrongAuthHeader(t *testing.T) {
 
 func TestMissingAuthHeader(t *testing.T) {
 	manager := &mockManager{false}
-	mw := NewKeyExistsMiddleware(manager)
+	mw := NewKeyExistsMiddleware(manager, plugin.FailClosed)
 
 	w, err := test.Record(
 		"GET",
@@ -81,7 +82,7 @@ func TestMissingAuthHeader(t *testing.T) {
 
 func TestMissingKeyStorage(t *testing.T) {
 	manager := &mockManager{false}
-	mw := NewKeyExistsMiddleware(manager)
+	mw := NewKeyExistsMiddleware(manager, plugin.FailClosed)
 
 	w, err := test.Record(
 		"GET",
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/hellofresh/janus/pkg/proxy"
 	"github.com/hellofresh/janus/pkg/proxy/balancer"
@@ -13,6 +14,9 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// introspectionTimeout bounds the token checks, so the requests do not hang on an unresponsive provider
+const introspectionTimeout = 5 * time.Second
+
 type oAuthResponse struct {
 	Active bool `json:"active"`
 }
@@ -23,6 +27,7 @@ type IntrospectionManager struct {
 	balancer balancer.Balancer
 	urls     proxy.Targets
 	settings *IntrospectionSettings
+	client   *http.Client
 }
 
 // NewIntrospectionManager creates a new instance of Introspection
@@ -32,32 +37,38 @@ func NewIntrospectionManager(def *proxy.Definition, settings *IntrospectionSetti
 		return nil, errors.Wrap(err, "Could not create a balancer")
 	}
 
-	return &IntrospectionManager{balancer, def.Upstreams.Targets, settings}, nil
+	return &IntrospectionManager{balancer, def.Upstreams.Targets, settings, &http.Client{Timeout: introspectionTimeout}}, nil
 }
 
-// IsKeyAuthorized checks if the access token is valid
+// IsKeyAuthorized checks if the access token is valid, the tokens which could not be checked are not
 func (o *IntrospectionManager) IsKeyAuthorized(ctx context.Context, accessToken string) bool {
-	resp, err := o.doStatusRequest(accessToken)
-	defer resp.Body.Close()
+	authorized, err := o.CheckKey(ctx, accessToken)
+	return authorized && err == nil
+}
 
+// CheckKey checks if the access token is active, the error tells the authentication provider did not
+// answer, e.g. it is unreachable or it failed with a 5xx status
+func (o *IntrospectionManager) CheckKey(ctx context.Context, accessToken string) (bool, error) {
+	resp, err := o.doStatusRequest(accessToken)
 	if err != nil {
-		log.WithError(err).
-			Error("Error making a request to the authentication provider")
+		return false, err
 	}
+	defer resp.Body.Close()
 
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return false, fmt.Errorf("unexpected introspection response status %d", resp.StatusCode)
+	}
 	if resp.StatusCode != http.StatusOK {
 		log.Info("The token check was invalid")
-		return false
+		return false, nil
 	}
 
 	var oauthResp oAuthResponse
-	decoder := json.NewDecoder(resp.Body)
-	err = decoder.Decode(&oauthResp)
-	if err != nil {
-		return false
+	if err := json.NewDecoder(resp.Body).Decode(&oauthResp); err != nil {
+		return false, errors.Wrap(err, "could not decode the introspection response")
 	}
 
-	return oauthResp.Active
+	return oauthResp.Active, nil
 }
 
 func (o *IntrospectionManager) doStatusRequest(accessToken string) (*http.Response, error) {
@@ -84,9 +95,9 @@ func (o *IntrospectionManager) doStatusRequest(accessToken string) (*http.Respon
 	// Inform to close the connection after the transaction is complete
 	req.Header.Set("Connection", "close")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := o.client.Do(req)
 	if err != nil {
-		log.WithError(err).Error("Making the request for the health check failed")
+		log.WithError(err).Error("Making the request to the authentication provider failed")
 		return resp, err
 	}
 
@@ -0,0 +1,79 @@
+package oauth2
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hellofresh/janus/pkg/plugin"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/hellofresh/janus/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newIntrospectionManager(t *testing.T, url string) *IntrospectionManager {
+	def := proxy.NewDefinition()
+	def.Upstreams = &proxy.Upstreams{Balancing: "roundrobin", Targets: []*proxy.Target{{Target: url}}}
+
+	manager, err := NewIntrospectionManager(def, &IntrospectionSettings{UseAuthHeader: true, AuthHeaderType: "Bearer"})
+	require.NoError(t, err)
+	return manager
+}
+
+func newIntrospectionServer(status int, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+}
+
+func TestIntrospectionManagerCheckKey(t *testing.T) {
+	server := newIntrospectionServer(http.StatusOK, `{"active":true}`)
+	defer server.Close()
+	authorized, err := newIntrospectionManager(t, server.URL).CheckKey(context.Background(), "token")
+	assert.NoError(t, err)
+	assert.True(t, authorized)
+
+	server = newIntrospectionServer(http.StatusUnauthorized, `{}`)
+	defer server.Close()
+	authorized, err = newIntrospectionManager(t, server.URL).CheckKey(context.Background(), "token")
+	assert.NoError(t, err, "the rejected tokens are not dependency errors")
+	assert.False(t, authorized)
+
+	server = newIntrospectionServer(http.StatusBadGateway, `{}`)
+	defer server.Close()
+	authorized, err = newIntrospectionManager(t, server.URL).CheckKey(context.Background(), "token")
+	assert.Error(t, err)
+	assert.False(t, authorized)
+
+	server = newIntrospectionServer(http.StatusOK, `<html>`)
+	defer server.Close()
+	_, err = newIntrospectionManager(t, server.URL).CheckKey(context.Background(), "token")
+	assert.Error(t, err)
+}
+
+func TestKeyExistsMiddlewareDependencyError(t *testing.T) {
+	// the introspection server is unreachable
+	server := newIntrospectionServer(http.StatusOK, `{"active":true}`)
+	server.Close()
+	manager := newIntrospectionManager(t, server.URL)
+
+	record := func(policy plugin.DependencyErrorPolicy) int {
+		w, err := test.Record(
+			"GET",
+			"/",
+			map[string]string{"Authorization": "Bearer 1234"},
+			NewKeyExistsMiddleware(manager, policy)(http.HandlerFunc(test.Ping)),
+		)
+		require.NoError(t, err)
+		return w.Code
+	}
+
+	assert.False(t, manager.IsKeyAuthorized(context.Background(), "1234"))
+	assert.Equal(t, http.StatusServiceUnavailable, record(""), "the requests fail closed by default")
+	assert.Equal(t, http.StatusServiceUnavailable, record(plugin.FailClosed))
+	assert.Equal(t, http.StatusOK, record(plugin.FailOpen), "the requests are let through when they fail open")
+}
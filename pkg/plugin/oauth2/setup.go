@@ -39,6 +39,9 @@ func init() {
 // Config represents the oauth configuration
 type Config struct {
 	ServerName string `json:"server_name"`
+	// OnDependencyError is the policy of the requests whose token could not be checked because the
+	// introspection server failed, fail_closed by default
+	OnDependencyError plugin.DependencyErrorPolicy `json:"on_dependency_error"`
 }
 
 func onAdminAPIStartup(event interface{}) error {
@@ -136,7 +139,7 @@ func setupOAuth2(def *proxy.RouterDefinition, rawConfig plugin.Config) error {
 		return err
 	}
 
-	def.AddMiddleware(NewKeyExistsMiddleware(manager))
+	def.AddMiddleware(NewKeyExistsMiddleware(manager, config.OnDependencyError))
 	def.AddMiddleware(NewRevokeRulesMiddleware(jwt.NewParser(jwt.NewParserConfig(oauthServer.TokenStrategy.Leeway, signingMethods...)), oauthServer.AccessRules))
 
 	return nil
@@ -149,6 +152,10 @@ func validateConfig(rawConfig plugin.Config) (bool, error) {
 		return false, err
 	}
 
+	if err := config.OnDependencyError.Validate(); err != nil {
+		return false, err
+	}
+
 	return govalidator.ValidateStruct(config)
 }
 
@@ -168,7 +175,7 @@ func loadOAuthEndpoints(router router.Router, repo Repository, cred config.Crede
 	guard := jwt.NewGuard(cred)
 	oAuthHandler := NewController(repo)
 	oauthGroup := router.Group("/oauth/servers")
-	oauthGroup.Use(jwt.NewMiddleware(guard).Handler)
+	oauthGroup.Use(jwt.NewMiddleware(guard).Handler, jwt.NewRBACMiddleware(cred.RBAC).Handler)
 	{
 		oauthGroup.GET("/", oAuthHandler.Get())
 		oauthGroup.GET("/{name}", oAuthHandler.GetBy())
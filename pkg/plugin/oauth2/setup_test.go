@@ -18,3 +18,13 @@ func TestOAuth2Config(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, "test", config.ServerName)
 }
+
+func TestValidateConfigDependencyErrorPolicy(t *testing.T) {
+	valid, err := validateConfig(map[string]interface{}{"server_name": "test", "on_dependency_error": "fail_open"})
+	assert.True(t, valid)
+	assert.NoError(t, err)
+
+	valid, err = validateConfig(map[string]interface{}{"server_name": "test", "on_dependency_error": "ignore"})
+	assert.False(t, valid)
+	assert.Error(t, err)
+}
@@ -3,6 +3,7 @@ package plugin
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"sync"
 
 	"github.com/hellofresh/janus/pkg/proxy"
@@ -36,6 +37,60 @@ type Config map[string]interface{}
 type Plugin struct {
 	Action   SetupFunc
 	Validate ValidateFunc
+	// ModifiesResponseBody tells the plugin rewrites the response bodies, so it is not applied to the
+	// APIs streaming their responses
+	ModifiesResponseBody bool
+	// DecodesResponseBody tells the plugin reads the response bodies decoded, so the compressed upstream
+	// responses of the APIs it is enabled for are decompressed before it
+	DecodesResponseBody bool
+	// BuffersResponseBody tells the plugin keeps the response bodies in memory, e.g. to share or replay
+	// them, so the max response body size of the APIs it is enabled for is enforced
+	BuffersResponseBody bool
+	// Stateful tells the middleware of the plugin keeps state, e.g. the rate limit counters, so the
+	// reloads keep the middleware of the APIs whose plugin config is unchanged instead of setting it up
+	// again
+	Stateful bool
+}
+
+// PluginFactory creates a plugin when it is registered, it is either a Plugin, setting up the router
+// definition itself, or a MiddlewareFactory
+type PluginFactory interface {
+	plugin() Plugin
+}
+
+func (p Plugin) plugin() Plugin {
+	return p
+}
+
+// MiddlewareFactory creates the middleware of a plugin from its raw config. It is the way to plug in
+// the plugins maintained out of the Janus tree, the factory is called for every API definition the
+// plugin is enabled for and its middleware is added to the chain of the definition:
+//
+//	plugin.RegisterPlugin("my_plugin", plugin.MiddlewareFactory(newMyPlugin))
+//
+// The raw config is valid when the factory returns no error, so it should not connect to external
+// services before the first request.
+type MiddlewareFactory func(rawConfig Config) (func(http.Handler) http.Handler, error)
+
+func (f MiddlewareFactory) plugin() Plugin {
+	return Plugin{
+		Action: func(def *proxy.RouterDefinition, rawConfig Config) error {
+			mw, err := f(rawConfig)
+			if err != nil {
+				return err
+			}
+
+			def.AddMiddleware(mw)
+			return nil
+		},
+		Validate: func(rawConfig Config) (bool, error) {
+			if _, err := f(rawConfig); err != nil {
+				return false, err
+			}
+
+			return true, nil
+		},
+	}
 }
 
 // RegisterPlugin plugs in plugin. All plugins should register
@@ -47,17 +102,20 @@ type Plugin struct {
 // If this plugin has an action, it must be the name of
 // the directive that invokes it. A name is always required
 // and must be unique for the server type.
-func RegisterPlugin(name string, plugin Plugin) error {
+func RegisterPlugin(name string, factory PluginFactory) error {
 	lock.Lock()
 	defer lock.Unlock()
 
 	if name == "" {
 		return errors.New("plugin must have a name")
 	}
+	if factory == nil {
+		return fmt.Errorf("plugin named %s has no factory", name)
+	}
 	if _, dup := plugins[name]; dup {
 		return fmt.Errorf("plugin named %s  already registered", name)
 	}
-	plugins[name] = plugin
+	plugins[name] = factory.plugin()
 	return nil
 }
 
@@ -139,6 +197,38 @@ func DirectiveAction(name string) (SetupFunc, error) {
 	return nil, fmt.Errorf("plugin %q not found", name)
 }
 
+// ModifiesResponseBody tells if the plugin rewrites the response bodies
+func ModifiesResponseBody(name string) bool {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return plugins[name].ModifiesResponseBody
+}
+
+// DecodesResponseBody tells if the plugin reads the decoded response bodies
+func DecodesResponseBody(name string) bool {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return plugins[name].DecodesResponseBody
+}
+
+// BuffersResponseBody tells if the plugin keeps the response bodies in memory
+func BuffersResponseBody(name string) bool {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return plugins[name].BuffersResponseBody
+}
+
+// Stateful tells if the middleware of the plugin keeps state across the reloads
+func Stateful(name string) bool {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return plugins[name].Stateful
+}
+
 // Decode decodes a map string interface into a struct
 // for some reasons mapstructure.Decode() gives empty arrays for all resulting config fields
 // this is quick workaround hack t make it work
@@ -2,11 +2,16 @@ package plugin
 
 import (
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/asaskevich/govalidator"
 	"github.com/hellofresh/janus/pkg/api"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type TestPluginConfig struct {
@@ -21,6 +26,14 @@ func init() {
 	RegisterPlugin("test_plugin_without_validation_function", Plugin{})
 }
 
+// unregisterPlugin removes a plugin registered by a test, so the test can run again with -count
+func unregisterPlugin(name string) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	delete(plugins, name)
+}
+
 func validateConfig(rawConfig Config) (bool, error) {
 	var config TestPluginConfig
 	err := Decode(rawConfig, &config)
@@ -64,6 +77,55 @@ func TestPluginValidationWithoutValidationFunction(t *testing.T) {
 	}
 }
 
+func newHeaderMiddleware(rawConfig Config) (func(http.Handler) http.Handler, error) {
+	value, _ := rawConfig["value"].(string)
+	if value == "" {
+		return nil, errors.New("value is not set")
+	}
+
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Test", value)
+			handler.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+func TestRegisterMiddlewareFactory(t *testing.T) {
+	require.NoError(t, RegisterPlugin("test_middleware_factory", MiddlewareFactory(newHeaderMiddleware)))
+	defer unregisterPlugin("test_middleware_factory")
+
+	result, err := ValidateConfig("test_middleware_factory", Config{"value": "janus"})
+	assert.NoError(t, err)
+	assert.True(t, result)
+
+	result, err = ValidateConfig("test_middleware_factory", Config{})
+	assert.Error(t, err)
+	assert.False(t, result)
+
+	setup, err := DirectiveAction("test_middleware_factory")
+	require.NoError(t, err)
+
+	def := proxy.NewRouterDefinition(proxy.NewDefinition())
+	require.NoError(t, setup(def, Config{"value": "janus"}))
+	require.Len(t, def.Middleware(), 1)
+
+	w := httptest.NewRecorder()
+	def.Middleware()[0](http.NotFoundHandler()).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, "janus", w.Header().Get("X-Test"))
+
+	assert.Error(t, setup(proxy.NewRouterDefinition(proxy.NewDefinition()), Config{}))
+}
+
+func TestRegisterPluginInvalid(t *testing.T) {
+	assert.Error(t, RegisterPlugin("", Plugin{}))
+	assert.Error(t, RegisterPlugin("test_nil_factory", nil))
+	assert.Error(t, RegisterPlugin("test_plugin", MiddlewareFactory(newHeaderMiddleware)), "the names are unique")
+
+	_, err := DirectiveAction("test_unknown")
+	assert.Error(t, err)
+}
+
 const (
 	validDefinition = `{
     "name" : "users",
@@ -195,3 +257,14 @@ const (
     }
 }`
 )
+
+func TestModifiesResponseBody(t *testing.T) {
+	require.NoError(t, RegisterPlugin("test_body_plugin", Plugin{ModifiesResponseBody: true}))
+	defer unregisterPlugin("test_body_plugin")
+	require.NoError(t, RegisterPlugin("test_header_plugin", Plugin{}))
+	defer unregisterPlugin("test_header_plugin")
+
+	assert.True(t, ModifiesResponseBody("test_body_plugin"))
+	assert.False(t, ModifiesResponseBody("test_header_plugin"))
+	assert.False(t, ModifiesResponseBody("test_unknown_plugin"))
+}
@@ -0,0 +1,134 @@
+package quota
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hellofresh/janus/pkg/errors"
+	"github.com/hellofresh/janus/pkg/group"
+	"github.com/hellofresh/janus/pkg/middleware"
+)
+
+// Quota headers
+const (
+	HeaderLimit     = "X-Quota-Limit"
+	HeaderRemaining = "X-Quota-Remaining"
+	HeaderReset     = "X-Quota-Reset"
+)
+
+// Quota caps the number of the requests of a consumer over a day or a month
+type Quota struct {
+	store      Store
+	prefix     string
+	window     window
+	limit      int64
+	consumers  map[string]int64
+	statusCode int
+
+	now       func() time.Time
+	findGroup func(consumer string) *group.Group
+}
+
+// NewQuota creates a new instance of Quota
+func NewQuota(store Store, config Config) *Quota {
+	return &Quota{
+		store:      store,
+		prefix:     config.Prefix,
+		window:     window{name: config.Window, rolling: config.Mode == ModeRolling},
+		limit:      config.Limit,
+		consumers:  config.Consumers,
+		statusCode: config.StatusCode,
+		now:        time.Now,
+		findGroup:  group.Find,
+	}
+}
+
+// Handler is the middleware function. The requests are counted per authenticated consumer, and per
+// client IP address when the request is not authenticated, so the plugin must follow the
+// authentication plugin of the API definition.
+func (q *Quota) Handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		consumer := middleware.ConsumerFromContext(r.Context())
+		limit := q.limitOf(consumer)
+		if consumer == "" {
+			consumer = middleware.ClientKeyFromRequest(r)
+		}
+
+		now := q.now()
+		current := q.window.bucket(now)
+		used, err := q.consume(consumer, current, now)
+		if err != nil {
+			// the store is unavailable, the request is not counted rather than rejected
+			middleware.ContextLogger(r.Context()).WithError(err).WithField("consumer", consumer).Error("Could not count the request quota")
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		remaining := limit - used
+		exceeded := remaining < 0
+		if exceeded {
+			// the rejected requests do not consume the quota
+			if _, err := q.store.Increment(current.key(q.prefix, consumer), -1, q.ttl(current)); err != nil {
+				middleware.ContextLogger(r.Context()).WithError(err).WithField("consumer", consumer).Error("Could not release the request quota")
+			}
+			remaining = 0
+		}
+
+		w.Header().Set(HeaderLimit, strconv.FormatInt(limit, 10))
+		w.Header().Set(HeaderRemaining, strconv.FormatInt(remaining, 10))
+		w.Header().Set(HeaderReset, strconv.FormatInt(current.end.Unix(), 10))
+
+		if exceeded {
+			errors.Handler(w, errors.New(q.statusCode, "quota exceeded"))
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// limitOf returns the limit set for the consumer in the configuration, the quota of its consumer
+// group, or the configured limit
+func (q *Quota) limitOf(consumer string) int64 {
+	if limit, ok := q.consumers[consumer]; ok {
+		return limit
+	}
+
+	if consumer != "" {
+		if g := q.findGroup(consumer); g != nil && g.Quota > 0 {
+			return g.Quota
+		}
+	}
+
+	return q.limit
+}
+
+// consume counts the request and returns the number of the requests of the consumer in the window,
+// the requests of the previous period of a rolling window are weighted by the share of the
+// period still in the window
+func (q *Quota) consume(consumer string, current bucket, now time.Time) (int64, error) {
+	used, err := q.store.Increment(current.key(q.prefix, consumer), 1, q.ttl(current))
+	if err != nil || !q.window.rolling {
+		return used, err
+	}
+
+	previous := current.previous()
+	count, err := q.store.Get(previous.key(q.prefix, consumer))
+	if err != nil {
+		return 0, err
+	}
+
+	return used + int64(math.Floor(float64(count)*previous.weight(now))), nil
+}
+
+// ttl keeps the counters of the rolling windows for the next period, where they are weighted
+func (q *Quota) ttl(b bucket) time.Duration {
+	ttl := b.end.Sub(q.now())
+	if q.window.rolling {
+		ttl += b.end.Sub(b.start)
+	}
+
+	return ttl + time.Minute
+}
@@ -0,0 +1,128 @@
+package quota
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hellofresh/janus/pkg/group"
+	"github.com/hellofresh/janus/pkg/middleware"
+	"github.com/hellofresh/janus/pkg/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestQuota(config Config, now time.Time) *Quota {
+	if config.Window == "" {
+		config.Window = WindowDay
+	}
+	config.Prefix = DefaultPrefix
+	config.StatusCode = http.StatusTooManyRequests
+
+	q := NewQuota(NewMemoryStore(), config)
+	q.now = func() time.Time { return now }
+
+	return q
+}
+
+func serve(q *Quota, consumer string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if consumer != "" {
+		r = r.WithContext(middleware.WithConsumer(r.Context(), consumer))
+	}
+
+	w := httptest.NewRecorder()
+	q.Handler(http.HandlerFunc(test.Ping)).ServeHTTP(w, r)
+
+	return w
+}
+
+func TestQuotaExhausted(t *testing.T) {
+	now := time.Date(2018, time.February, 28, 12, 0, 0, 0, time.UTC)
+	q := newTestQuota(Config{Limit: 2}, now)
+
+	for i := 1; i >= 0; i-- {
+		w := serve(q, "jane")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "2", w.Header().Get(HeaderLimit))
+		assert.Equal(t, strconv.Itoa(i), w.Header().Get(HeaderRemaining))
+	}
+
+	for i := 0; i < 2; i++ {
+		w := serve(q, "jane")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "0", w.Header().Get(HeaderRemaining))
+		assert.Equal(t, strconv.FormatInt(time.Date(2018, time.March, 1, 0, 0, 0, 0, time.UTC).Unix(), 10), w.Header().Get(HeaderReset))
+	}
+
+	assert.Equal(t, http.StatusOK, serve(q, "john").Code, "the quotas are per consumer")
+
+	used, _ := q.store.Get(q.window.bucket(now).key(DefaultPrefix, "jane"))
+	assert.Equal(t, int64(2), used, "the rejected requests do not consume the quota")
+
+	q.now = func() time.Time { return now.Add(12 * time.Hour) }
+	assert.Equal(t, http.StatusOK, serve(q, "jane").Code, "the quota is reset the next day")
+}
+
+func TestQuotaConsumerLimits(t *testing.T) {
+	q := newTestQuota(Config{Limit: 1, Consumers: map[string]int64{"partner": 3, "blocked": 0}}, time.Now())
+
+	assert.Equal(t, "3", serve(q, "partner").Header().Get(HeaderLimit))
+	assert.Equal(t, http.StatusTooManyRequests, serve(q, "blocked").Code)
+
+	w := serve(q, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get(HeaderLimit), "the requests without consumer get the default limit")
+	assert.Equal(t, http.StatusTooManyRequests, serve(q, "").Code, "the requests without consumer are counted by client IP")
+}
+
+func TestQuotaGroupLimits(t *testing.T) {
+	groups := map[string]*group.Group{
+		"partner": {Name: "gold", Quota: 3},
+		"jane":    {Name: group.DefaultGroup},
+	}
+	q := newTestQuota(Config{Limit: 1, Consumers: map[string]int64{"blocked": 0}}, time.Now())
+	q.findGroup = func(consumer string) *group.Group { return groups[consumer] }
+
+	assert.Equal(t, "3", serve(q, "partner").Header().Get(HeaderLimit))
+	groups["partner"].Quota = 5
+	assert.Equal(t, "5", serve(q, "partner").Header().Get(HeaderLimit), "the group changes apply to the next request")
+	assert.Equal(t, "1", serve(q, "jane").Header().Get(HeaderLimit), "a group without quota keeps the configured limit")
+
+	groups["blocked"] = groups["partner"]
+	assert.Equal(t, http.StatusTooManyRequests, serve(q, "blocked").Code, "the consumer limits take precedence")
+}
+
+func TestQuotaRollingWindow(t *testing.T) {
+	start := time.Date(2018, time.February, 28, 0, 0, 0, 0, time.UTC)
+	q := newTestQuota(Config{Limit: 10, Mode: ModeRolling}, start.Add(-time.Hour))
+
+	for i := 0; i < 8; i++ {
+		serve(q, "jane")
+	}
+
+	// half of the previous day is still in the window, it counts for 4 requests
+	q.now = func() time.Time { return start.Add(12 * time.Hour) }
+	w := serve(q, "jane")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "5", w.Header().Get(HeaderRemaining))
+}
+
+func TestQuotaUnknownClientAddress(t *testing.T) {
+	q := newTestQuota(Config{Limit: 1}, time.Now())
+	handler := q.Handler(http.HandlerFunc(test.Ping))
+
+	codes := make([]int, 0, 2)
+	for i := 0; i < 2; i++ {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "@"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		codes = append(codes, w.Code)
+		assert.Equal(t, "1", w.Header().Get(HeaderLimit))
+	}
+
+	assert.Equal(t, []int{http.StatusOK, http.StatusTooManyRequests}, codes, "the clients without address share a quota")
+}
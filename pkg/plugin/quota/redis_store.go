@@ -0,0 +1,42 @@
+package quota
+
+import (
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// redisStore keeps the counters in redis, so they are shared by the Janus instances and survive
+// their restarts
+type redisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore creates a Store shared by the Janus instances using the redis client
+func NewRedisStore(client *redis.Client) Store {
+	return &redisStore{client: client}
+}
+
+func (s *redisStore) Increment(key string, n int64, ttl time.Duration) (int64, error) {
+	var incr *redis.IntCmd
+	_, err := s.client.TxPipelined(func(pipe redis.Pipeliner) error {
+		incr = pipe.IncrBy(key, n)
+		// the expiration is only set on the new counters, so it is not pushed back by each request
+		pipe.Eval("if redis.call('TTL', KEYS[1]) < 0 then return redis.call('EXPIRE', KEYS[1], ARGV[1]) end return 0", []string{key}, int64(ttl/time.Second))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return incr.Val(), nil
+}
+
+func (s *redisStore) Get(key string) (int64, error) {
+	value, err := s.client.Get(key).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+
+	return value, err
+}
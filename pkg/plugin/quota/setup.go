@@ -0,0 +1,122 @@
+package quota
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/go-redis/redis"
+	"github.com/hellofresh/janus/pkg/plugin"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/pkg/errors"
+)
+
+// DefaultPrefix is the default prefix of the counter keys
+const DefaultPrefix = "quota"
+
+var (
+	// localStore is shared by the API definitions, so the counters are kept when the routes are reloaded
+	localStore = NewMemoryStore()
+
+	redisClientsMu sync.Mutex
+	redisClients   = make(map[string]*redis.Client)
+)
+
+// Config represents the Quota configuration
+type Config struct {
+	Window      string           `json:"window" valid:"in(day|month)~window must be one of day or month"`
+	Mode        string           `json:"mode" valid:"in(calendar|rolling)~mode must be one of calendar or rolling"`
+	Limit       int64            `json:"limit"`
+	Consumers   map[string]int64 `json:"consumers"`
+	StatusCode  int              `json:"status_code"`
+	Prefix      string           `json:"prefix"`
+	Policy      string           `json:"policy" valid:"in(local|redis)~policy must be one of local or redis"`
+	RedisConfig redisConfig      `json:"redis"`
+}
+
+type redisConfig struct {
+	DSN string `json:"dsn"`
+}
+
+func init() {
+	plugin.RegisterPlugin("quota", plugin.Plugin{
+		Action:   setupQuota,
+		Validate: validateConfig,
+		Stateful: true,
+	})
+}
+
+func setupQuota(def *proxy.RouterDefinition, rawConfig plugin.Config) error {
+	config, err := decodeConfig(rawConfig)
+	if err != nil {
+		return err
+	}
+
+	store, err := getStore(config)
+	if err != nil {
+		return err
+	}
+
+	def.AddMiddleware(NewQuota(store, config).Handler)
+	return nil
+}
+
+func validateConfig(rawConfig plugin.Config) (bool, error) {
+	config, err := decodeConfig(rawConfig)
+	if err != nil {
+		return false, err
+	}
+
+	return govalidator.ValidateStruct(config)
+}
+
+func decodeConfig(rawConfig plugin.Config) (Config, error) {
+	config := Config{
+		Window:     WindowDay,
+		Mode:       ModeCalendar,
+		StatusCode: http.StatusTooManyRequests,
+		Prefix:     DefaultPrefix,
+		Policy:     "local",
+	}
+	if err := plugin.Decode(rawConfig, &config); err != nil {
+		return config, err
+	}
+
+	if config.Limit <= 0 {
+		return config, errors.New("quota limit must be greater than zero")
+	}
+	for consumer, limit := range config.Consumers {
+		if limit < 0 {
+			return config, errors.Errorf("quota limit of the consumer %q can not be negative", consumer)
+		}
+	}
+	if config.StatusCode != http.StatusTooManyRequests && config.StatusCode != http.StatusForbidden {
+		return config, errors.New("quota status code must be one of 403 or 429")
+	}
+
+	return config, nil
+}
+
+// getStore returns the counters store, the redis clients are shared by the API definitions using the
+// same DSN
+func getStore(config Config) (Store, error) {
+	if config.Policy != "redis" {
+		return localStore, nil
+	}
+
+	redisClientsMu.Lock()
+	defer redisClientsMu.Unlock()
+
+	client, ok := redisClients[config.RedisConfig.DSN]
+	if !ok {
+		option, err := redis.ParseURL(config.RedisConfig.DSN)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid quota redis DSN")
+		}
+
+		client = redis.NewClient(option)
+		redisClients[config.RedisConfig.DSN] = client
+	}
+
+	return NewRedisStore(client), nil
+}
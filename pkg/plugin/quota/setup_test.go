@@ -0,0 +1,47 @@
+package quota
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/hellofresh/janus/pkg/plugin"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetup(t *testing.T) {
+	def := proxy.NewRouterDefinition(proxy.NewDefinition())
+	err := setupQuota(def, plugin.Config{"limit": 1000, "window": "month"})
+	assert.NoError(t, err)
+
+	assert.Len(t, def.Middleware(), 1)
+}
+
+func TestDecodeConfig(t *testing.T) {
+	config, err := decodeConfig(plugin.Config{"limit": 1000, "consumers": map[string]interface{}{"partner": 5000}})
+	require.NoError(t, err)
+
+	assert.Equal(t, WindowDay, config.Window)
+	assert.Equal(t, ModeCalendar, config.Mode)
+	assert.Equal(t, http.StatusTooManyRequests, config.StatusCode)
+	assert.Equal(t, map[string]int64{"partner": 5000}, config.Consumers)
+}
+
+func TestValidateConfig(t *testing.T) {
+	valid, err := validateConfig(plugin.Config{"limit": 1000, "mode": "rolling", "status_code": 403})
+	assert.NoError(t, err)
+	assert.True(t, valid)
+
+	invalid := []plugin.Config{
+		{},
+		{"limit": 1000, "window": "week"},
+		{"limit": 1000, "mode": "sliding"},
+		{"limit": 1000, "status_code": 503},
+		{"limit": 1000, "consumers": map[string]interface{}{"partner": -1}},
+	}
+	for _, config := range invalid {
+		_, err := validateConfig(config)
+		assert.Error(t, err, "%v", config)
+	}
+}
@@ -0,0 +1,78 @@
+package quota
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the memory store removes the expired counters
+const sweepInterval = time.Minute
+
+// Store keeps the request counters of the consumers
+type Store interface {
+	// Increment adds n to the counter of the key and returns its new value, the counter expires
+	// after the ttl set when it is created
+	Increment(key string, n int64, ttl time.Duration) (int64, error)
+	// Get returns the counter of the key, it returns zero when there is none
+	Get(key string) (int64, error)
+}
+
+type memoryCounter struct {
+	value     int64
+	expiresAt time.Time
+}
+
+// memoryStore keeps the counters in the memory of the Janus instance
+type memoryStore struct {
+	sync.Mutex
+	counters map[string]memoryCounter
+	swept    time.Time
+}
+
+// NewMemoryStore creates a Store local to the Janus instance
+func NewMemoryStore() Store {
+	return &memoryStore{counters: make(map[string]memoryCounter), swept: time.Now()}
+}
+
+func (s *memoryStore) Increment(key string, n int64, ttl time.Duration) (int64, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	counter, ok := s.counters[key]
+	if !ok || now.After(counter.expiresAt) {
+		counter = memoryCounter{expiresAt: now.Add(ttl)}
+	}
+	counter.value += n
+	s.counters[key] = counter
+
+	return counter.value, nil
+}
+
+func (s *memoryStore) Get(key string) (int64, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	counter, ok := s.counters[key]
+	if !ok || time.Now().After(counter.expiresAt) {
+		return 0, nil
+	}
+
+	return counter.value, nil
+}
+
+// sweep removes the expired counters, it must be called with the store locked
+func (s *memoryStore) sweep(now time.Time) {
+	if now.Sub(s.swept) < sweepInterval {
+		return
+	}
+	s.swept = now
+
+	for key, counter := range s.counters {
+		if now.After(counter.expiresAt) {
+			delete(s.counters, key)
+		}
+	}
+}
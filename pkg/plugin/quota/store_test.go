@@ -0,0 +1,34 @@
+package quota
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+
+	value, err := store.Increment("key", 1, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), value)
+
+	value, err = store.Increment("key", 2, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), value)
+
+	value, err = store.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), value)
+
+	store.Increment("expired", 1, -time.Second)
+	value, err = store.Get("expired")
+	require.NoError(t, err)
+	assert.Zero(t, value)
+
+	value, err = store.Increment("expired", 1, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), value, "the expired counter starts over")
+}
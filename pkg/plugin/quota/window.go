@@ -0,0 +1,78 @@
+package quota
+
+import (
+	"strconv"
+	"time"
+)
+
+// Windows
+const (
+	WindowDay   = "day"
+	WindowMonth = "month"
+)
+
+// Modes
+const (
+	// ModeCalendar resets the quota at the start of the day or the month, in UTC
+	ModeCalendar = "calendar"
+	// ModeRolling counts the requests of the last 24 hours or 30 days
+	ModeRolling = "rolling"
+)
+
+// rollingMonth is the length of the rolling month window
+const rollingMonth = 30 * 24 * time.Hour
+
+// window is the period the requests are counted over
+type window struct {
+	name    string
+	rolling bool
+}
+
+// bucket is the counter of a window period
+type bucket struct {
+	start time.Time
+	end   time.Time
+}
+
+// bucket returns the counter period of the time, the calendar buckets are the days or the months
+// and the rolling ones are fixed periods of the window length
+func (w window) bucket(now time.Time) bucket {
+	now = now.UTC()
+	if w.rolling {
+		length := w.length()
+		start := now.Truncate(length)
+		return bucket{start: start, end: start.Add(length)}
+	}
+
+	if w.name == WindowMonth {
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return bucket{start: start, end: start.AddDate(0, 1, 0)}
+	}
+
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return bucket{start: start, end: start.AddDate(0, 0, 1)}
+}
+
+func (w window) length() time.Duration {
+	if w.name == WindowMonth {
+		return rollingMonth
+	}
+
+	return 24 * time.Hour
+}
+
+// previous returns the bucket before b, it is used by the rolling windows only
+func (b bucket) previous() bucket {
+	length := b.end.Sub(b.start)
+	return bucket{start: b.start.Add(-length), end: b.start}
+}
+
+// weight is the share of the bucket still in the rolling window ending at the time
+func (b bucket) weight(now time.Time) float64 {
+	length := b.end.Sub(b.start)
+	return 1 - float64(now.Sub(b.end))/float64(length)
+}
+
+func (b bucket) key(prefix string, consumer string) string {
+	return prefix + ":" + consumer + ":" + strconv.FormatInt(b.start.Unix(), 10)
+}
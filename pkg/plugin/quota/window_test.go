@@ -0,0 +1,32 @@
+package quota
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWindowBucket(t *testing.T) {
+	now := time.Date(2018, time.February, 28, 22, 30, 0, 0, time.FixedZone("CET", 3600))
+
+	day := window{name: WindowDay}.bucket(now)
+	assert.Equal(t, time.Date(2018, time.February, 28, 0, 0, 0, 0, time.UTC), day.start, "the calendar windows are in UTC")
+	assert.Equal(t, time.Date(2018, time.March, 1, 0, 0, 0, 0, time.UTC), day.end)
+
+	month := window{name: WindowMonth}.bucket(now)
+	assert.Equal(t, time.Date(2018, time.February, 1, 0, 0, 0, 0, time.UTC), month.start)
+	assert.Equal(t, time.Date(2018, time.March, 1, 0, 0, 0, 0, time.UTC), month.end)
+
+	rolling := window{name: WindowMonth, rolling: true}.bucket(now)
+	assert.Equal(t, rollingMonth, rolling.end.Sub(rolling.start))
+	assert.Equal(t, rolling.start, rolling.previous().end)
+}
+
+func TestBucketWeight(t *testing.T) {
+	now := time.Date(2018, time.February, 28, 6, 0, 0, 0, time.UTC)
+	current := window{name: WindowDay, rolling: true}.bucket(now)
+
+	assert.Equal(t, 0.75, current.previous().weight(now), "a quarter of the previous day is out of the window")
+	assert.Equal(t, "quota:jane:1519776000", current.key("quota", "jane"))
+}
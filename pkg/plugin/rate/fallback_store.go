@@ -0,0 +1,140 @@
+package rate
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hellofresh/janus/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/ulule/limiter"
+)
+
+const (
+	// FallbackLocal limits the requests with in-memory counters of the node while the store is unavailable
+	FallbackLocal = "local"
+	// FallbackOpen lets all the requests through while the store is unavailable
+	FallbackOpen = "open"
+	// FallbackClosed rejects all the requests while the store is unavailable
+	FallbackClosed = "closed"
+
+	// fallbackRetryInterval is how often the unavailable store is checked for recovery
+	fallbackRetryInterval = 5 * time.Second
+)
+
+// ErrStoreUnavailable is used when the rate limit store is unavailable and the fallback policy is closed
+var ErrStoreUnavailable = errors.New(http.StatusServiceUnavailable, "rate limit store is unavailable")
+
+// fallbackStore uses the shared store while it is available and degrades to the fallback policy
+// when it is not. The shared store is used again as soon as it recovers.
+type fallbackStore struct {
+	connect      func() (limiter.Store, error)
+	sharedPolicy string
+	policy       string
+	local        limiter.Store
+
+	sync.Mutex
+	shared     limiter.Store
+	degradedAt time.Time
+}
+
+func newFallbackStore(sharedPolicy string, policy string, local limiter.Store, connect func() (limiter.Store, error)) *fallbackStore {
+	s := &fallbackStore{connect: connect, sharedPolicy: sharedPolicy, policy: policy, local: local}
+
+	shared, err := connect()
+	if err != nil {
+		s.degrade(err)
+	}
+	s.shared = shared
+
+	return s
+}
+
+// Get returns the limit for given identifier.
+func (s *fallbackStore) Get(ctx context.Context, key string, rate limiter.Rate) (limiter.Context, error) {
+	return s.do(ctx, rate, func(store limiter.Store) (limiter.Context, error) {
+		return store.Get(ctx, key, rate)
+	})
+}
+
+// Peek returns the limit for given identifier, without modification on current values.
+func (s *fallbackStore) Peek(ctx context.Context, key string, rate limiter.Rate) (limiter.Context, error) {
+	return s.do(ctx, rate, func(store limiter.Store) (limiter.Context, error) {
+		return store.Peek(ctx, key, rate)
+	})
+}
+
+func (s *fallbackStore) do(ctx context.Context, rate limiter.Rate, op func(limiter.Store) (limiter.Context, error)) (limiter.Context, error) {
+	if shared := s.available(); shared != nil {
+		lctx, err := op(shared)
+		if err == nil {
+			s.restore()
+			return lctx, nil
+		}
+		s.degrade(err)
+	} else {
+		recordStoreLookup(ctx, s.sharedPolicy, false)
+	}
+
+	switch s.policy {
+	case FallbackLocal:
+		return op(s.local)
+	case FallbackOpen:
+		return limiter.Context{
+			Limit:     rate.Limit,
+			Remaining: rate.Limit,
+			Reset:     unixMilli(time.Now().Add(rate.Period)),
+		}, nil
+	default:
+		return limiter.Context{}, ErrStoreUnavailable
+	}
+}
+
+// available returns the shared store when it is healthy, or when it is time to check if it has
+// recovered. Only one request checks the recovery during every retry interval.
+func (s *fallbackStore) available() limiter.Store {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.degradedAt.IsZero() {
+		return s.shared
+	}
+
+	if time.Since(s.degradedAt) < fallbackRetryInterval {
+		return nil
+	}
+	s.degradedAt = time.Now()
+
+	if s.shared == nil {
+		shared, err := s.connect()
+		if err != nil {
+			log.WithError(err).Debug("Rate limit store is still unavailable")
+			return nil
+		}
+		s.shared = shared
+	}
+
+	return s.shared
+}
+
+func (s *fallbackStore) degrade(err error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.degradedAt.IsZero() {
+		log.WithError(err).WithField("fallback", s.policy).
+			Error("Rate limit store is unavailable, rate limiting falls back to the fallback policy")
+	}
+	s.degradedAt = time.Now()
+}
+
+func (s *fallbackStore) restore() {
+	s.Lock()
+	defer s.Unlock()
+
+	if !s.degradedAt.IsZero() {
+		log.Warn("Rate limit store has recovered, using the shared counters again")
+		s.degradedAt = time.Time{}
+	}
+}
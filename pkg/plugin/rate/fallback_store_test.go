@@ -0,0 +1,118 @@
+package rate
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulule/limiter"
+	storeMemory "github.com/ulule/limiter/drivers/store/memory"
+)
+
+var errTestStoreDown = errors.New("store down")
+
+// toggleStore is a shared store that can be taken down and brought back
+type toggleStore struct {
+	limiter.Store
+	down bool
+}
+
+func (s *toggleStore) Get(ctx context.Context, key string, rate limiter.Rate) (limiter.Context, error) {
+	if s.down {
+		return limiter.Context{}, errTestStoreDown
+	}
+	return s.Store.Get(ctx, key, rate)
+}
+
+func (s *toggleStore) Peek(ctx context.Context, key string, rate limiter.Rate) (limiter.Context, error) {
+	if s.down {
+		return limiter.Context{}, errTestStoreDown
+	}
+	return s.Store.Peek(ctx, key, rate)
+}
+
+func newTestFallbackStore(policy string) (*fallbackStore, *toggleStore) {
+	shared := &toggleStore{Store: storeMemory.NewStore()}
+	return newFallbackStore("redis", policy, storeMemory.NewStore(), func() (limiter.Store, error) { return shared, nil }), shared
+}
+
+func TestFallbackStorePolicies(t *testing.T) {
+	rate, err := limiter.NewRateFromFormatted("10-M")
+	require.NoError(t, err)
+
+	tests := []struct {
+		policy    string
+		err       error
+		remaining int64
+	}{
+		{policy: FallbackLocal, remaining: 9},
+		{policy: FallbackOpen, remaining: 10},
+		{policy: FallbackClosed, err: ErrStoreUnavailable},
+	}
+
+	for _, test := range tests {
+		t.Run(test.policy, func(t *testing.T) {
+			store, shared := newTestFallbackStore(test.policy)
+			shared.down = true
+
+			lctx, err := store.Get(context.Background(), "client", rate)
+			assert.Equal(t, test.err, err)
+			assert.Equal(t, test.remaining, lctx.Remaining)
+		})
+	}
+}
+
+func TestFallbackStoreRecovery(t *testing.T) {
+	rate, err := limiter.NewRateFromFormatted("10-M")
+	require.NoError(t, err)
+
+	store, shared := newTestFallbackStore(FallbackLocal)
+
+	lctx, err := store.Get(context.Background(), "client", rate)
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), lctx.Remaining)
+
+	// the local counters are used while the shared store is down
+	shared.down = true
+	lctx, err = store.Get(context.Background(), "client", rate)
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), lctx.Remaining)
+
+	// the shared store is not checked again before the retry interval is over
+	shared.down = false
+	lctx, err = store.Get(context.Background(), "client", rate)
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), lctx.Remaining)
+
+	store.degradedAt = time.Now().Add(-fallbackRetryInterval)
+	lctx, err = store.Get(context.Background(), "client", rate)
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), lctx.Remaining)
+	assert.True(t, store.degradedAt.IsZero())
+}
+
+func TestFallbackStoreUnavailableOnStartup(t *testing.T) {
+	rate, err := limiter.NewRateFromFormatted("10-M")
+	require.NoError(t, err)
+
+	shared := &toggleStore{Store: storeMemory.NewStore()}
+	connected := false
+	store := newFallbackStore("redis", FallbackClosed, storeMemory.NewStore(), func() (limiter.Store, error) {
+		if !connected {
+			return nil, errTestStoreDown
+		}
+		return shared, nil
+	})
+
+	_, err = store.Get(context.Background(), "client", rate)
+	assert.Equal(t, ErrStoreUnavailable, err)
+
+	connected = true
+	store.degradedAt = time.Now().Add(-fallbackRetryInterval)
+	lctx, err := store.Get(context.Background(), "client", rate)
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), lctx.Remaining)
+}
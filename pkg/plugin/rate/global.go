@@ -0,0 +1,83 @@
+package rate
+
+import (
+	"net/http"
+
+	obs "github.com/hellofresh/janus/pkg/observability"
+	"github.com/hellofresh/janus/pkg/plugin"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/ulule/limiter"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// globalKeyPrefix is the prefix of the store key counting all the requests of an API
+const globalKeyPrefix = "global:"
+
+// GlobalRateLimit limits the requests of an API with the configured rate regardless of the clients
+// sending them. The requests are counted per API definition in the shared store, so the rate caps the
+// sum of the requests served by all the nodes.
+type GlobalRateLimit struct {
+	limiter *limiter.Limiter
+}
+
+// NewGlobalRateLimit creates a new instance of GlobalRateLimit
+func NewGlobalRateLimit(lmt *limiter.Limiter) *GlobalRateLimit {
+	return &GlobalRateLimit{limiter: lmt}
+}
+
+// Handler is the middleware function
+func (l *GlobalRateLimit) Handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, _ := tag.FromContext(r.Context()).Value(obs.KeyAPIName)
+
+		context, err := l.limiter.Get(r.Context(), globalKeyPrefix+name)
+		if err != nil {
+			onLimiterError(w, r, err)
+			return
+		}
+
+		if context.Limit > 0 {
+			stats.Record(r.Context(), obs.MGlobalRateLimitUtilization.M(float64(context.Limit-context.Remaining)/float64(context.Limit)))
+		}
+
+		if context.Reached {
+			onLimitReached(w, r, context.Reset)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func validateGlobalConfig(rawConfig plugin.Config) (bool, error) {
+	var config Config
+	if err := plugin.Decode(rawConfig, &config); err != nil {
+		return false, err
+	}
+
+	if !isSharedPolicy(config.Policy) {
+		return false, ErrInvalidGlobalPolicy
+	}
+
+	return validateConfig(rawConfig)
+}
+
+func setupGlobalRateLimit(def *proxy.RouterDefinition, rawConfig plugin.Config) error {
+	var config Config
+	if err := plugin.Decode(rawConfig, &config); err != nil {
+		return err
+	}
+
+	if !isSharedPolicy(config.Policy) {
+		return ErrInvalidGlobalPolicy
+	}
+
+	limiterInstance, _, err := newLimiter(config)
+	if err != nil {
+		return err
+	}
+
+	def.AddMiddleware(NewGlobalRateLimit(limiterInstance).Handler)
+	return nil
+}
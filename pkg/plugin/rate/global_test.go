@@ -0,0 +1,65 @@
+package rate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	obs "github.com/hellofresh/janus/pkg/observability"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/hellofresh/janus/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/tag"
+)
+
+func serveGlobalRateLimit(handler http.Handler, api string, remoteAddr string) int {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = remoteAddr
+	ctx, _ := tag.New(r.Context(), tag.Upsert(obs.KeyAPIName, api))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r.WithContext(ctx))
+
+	return w.Code
+}
+
+func TestGlobalRateLimit(t *testing.T) {
+	server := newMemcachedServer(t)
+	defer server.listener.Close()
+
+	rawConfig := map[string]interface{}{
+		"limit":     "2-M",
+		"policy":    "memcached",
+		"memcached": map[string]interface{}{"servers": []string{server.addr()}},
+	}
+
+	def := proxy.NewRouterDefinition(proxy.NewDefinition())
+	require.NoError(t, setupGlobalRateLimit(def, rawConfig))
+	require.Len(t, def.Middleware(), 1)
+	handler := def.Middleware()[0](http.HandlerFunc(test.Ping))
+
+	assert.Equal(t, http.StatusOK, serveGlobalRateLimit(handler, "example", "10.0.0.1:1234"))
+	assert.Equal(t, http.StatusOK, serveGlobalRateLimit(handler, "example", "10.0.0.2:1234"))
+	assert.Equal(t, http.StatusTooManyRequests, serveGlobalRateLimit(handler, "example", "10.0.0.3:1234"), "the requests of all the clients are counted")
+	assert.Equal(t, http.StatusOK, serveGlobalRateLimit(handler, "other", "10.0.0.1:1234"), "the requests are counted per API")
+
+	// another node shares the counters of the store
+	other := proxy.NewRouterDefinition(proxy.NewDefinition())
+	require.NoError(t, setupGlobalRateLimit(other, rawConfig))
+	assert.Equal(t, http.StatusTooManyRequests, serveGlobalRateLimit(other.Middleware()[0](http.HandlerFunc(test.Ping)), "example", "10.0.0.4:1234"))
+}
+
+func TestGlobalRateLimitConfigInvalidPolicy(t *testing.T) {
+	rawConfig := map[string]interface{}{
+		"limit":  "10-S",
+		"policy": "local",
+	}
+
+	isValid, err := validateGlobalConfig(rawConfig)
+	assert.False(t, isValid)
+	assert.Equal(t, ErrInvalidGlobalPolicy, err)
+
+	def := proxy.NewRouterDefinition(proxy.NewDefinition())
+	assert.Equal(t, ErrInvalidGlobalPolicy, setupGlobalRateLimit(def, rawConfig))
+}
@@ -0,0 +1,239 @@
+package rate
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/pkg/errors"
+	"github.com/ulule/limiter"
+)
+
+const (
+	memcachedTimeout  = time.Second
+	memcachedPoolSize = 3
+	// memcachedMaxRelativeExpiration is the longest expiration memcached accepts in seconds,
+	// longer expirations must be given as unix timestamps
+	memcachedMaxRelativeExpiration = 30 * 24 * time.Hour
+)
+
+// memcachedStore keeps the rate limit counters in memcached. Memcached can not tell the expiration of
+// a key, so the counters are kept per fixed window: the key includes the window start and expires
+// together with the window.
+type memcachedStore struct {
+	prefix string
+	client *memcache.Client
+}
+
+func newMemcachedStore(config memcachedConfig) (limiter.Store, error) {
+	if len(config.Servers) == 0 {
+		return nil, errors.New("memcached servers are required")
+	}
+
+	if config.Prefix == "" {
+		config.Prefix = DefaultPrefix
+	}
+
+	client, err := newMemcachedClient(config.Servers)
+	if err != nil {
+		return nil, errors.Wrap(err, "limiter: cannot ping memcached server")
+	}
+
+	return &memcachedStore{prefix: config.Prefix, client: client}, nil
+}
+
+// Get returns the limit for given identifier.
+func (s *memcachedStore) Get(ctx context.Context, key string, rate limiter.Rate) (limiter.Context, error) {
+	now := time.Now()
+	key, expiration := s.windowKey(key, now, rate)
+
+	// incr does not create missing keys in memcached, so the counter is added on the first hit
+	count, err := s.client.Increment(key, 1)
+	if err == memcache.ErrCacheMiss {
+		count = 1
+		err = s.client.Add(&memcache.Item{Key: key, Value: []byte("1"), Expiration: memcachedExpiration(now, expiration)})
+		if err == memcache.ErrNotStored {
+			// the counter was added by another node in the meantime
+			count, err = s.client.Increment(key, 1)
+		}
+	}
+	if err != nil {
+		return limiter.Context{}, err
+	}
+
+	return windowContext(rate, expiration, int64(count)), nil
+}
+
+// Peek returns the limit for given identifier, without modification on current values.
+func (s *memcachedStore) Peek(ctx context.Context, key string, rate limiter.Rate) (limiter.Context, error) {
+	now := time.Now()
+	key, expiration := s.windowKey(key, now, rate)
+
+	item, err := s.client.Get(key)
+	if err == memcache.ErrCacheMiss {
+		return windowContext(rate, expiration, 0), nil
+	}
+	if err != nil {
+		return limiter.Context{}, err
+	}
+
+	count, err := strconv.ParseInt(string(item.Value), 10, 64)
+	if err != nil {
+		return limiter.Context{}, errors.Wrap(err, "limiter: invalid memcached counter")
+	}
+
+	return windowContext(rate, expiration, count), nil
+}
+
+func (s *memcachedStore) windowKey(key string, now time.Time, rate limiter.Rate) (string, time.Time) {
+	window := now.Truncate(rate.Period)
+	return fmt.Sprintf("%s:%s:%d", s.prefix, key, window.Unix()), window.Add(rate.Period)
+}
+
+func memcachedExpiration(now time.Time, expiration time.Time) int32 {
+	ttl := expiration.Sub(now)
+	if ttl > memcachedMaxRelativeExpiration {
+		return int32(expiration.Unix())
+	}
+
+	// round up so the key never expires before the window ends
+	return int32((ttl + time.Second - 1) / time.Second)
+}
+
+// memcachedBucketStore keeps the token buckets in memcached. The buckets are updated with check and
+// set, so the concurrent updates of the nodes are retried instead of overwriting each other. Memcached
+// has no clock, so the buckets are refilled with the clocks of the nodes.
+type memcachedBucketStore struct {
+	prefix string
+	burst  int64
+	client *memcache.Client
+}
+
+func newMemcachedBucketStore(config memcachedConfig, burst int64) (limiter.Store, error) {
+	store, err := newMemcachedStore(config)
+	if err != nil {
+		return nil, err
+	}
+
+	s := store.(*memcachedStore)
+	return &memcachedBucketStore{prefix: s.prefix, burst: burst, client: s.client}, nil
+}
+
+// Get takes a token from the bucket of the given identifier.
+func (s *memcachedBucketStore) Get(ctx context.Context, key string, rate limiter.Rate) (limiter.Context, error) {
+	key = fmt.Sprintf("%s:bucket:%s", s.prefix, key)
+	capacity := bucketCapacity(s.burst, rate)
+
+	for i := 0; i < limiter.DefaultMaxRetry; i++ {
+		current, item, err := s.bucket(key)
+		if err != nil {
+			return limiter.Context{}, err
+		}
+
+		now := time.Now()
+		b, allowed := current.take(now, rate, capacity, true)
+		if !allowed {
+			return bucketContext(b, rate, capacity, false), nil
+		}
+
+		value := []byte(formatMemcachedBucket(b))
+		expiration := memcachedExpiration(now, b.full(rate, capacity))
+		if item != nil {
+			item.Value, item.Expiration = value, expiration
+			err = s.client.CompareAndSwap(item)
+		} else {
+			err = s.client.Add(&memcache.Item{Key: key, Value: value, Expiration: expiration})
+		}
+
+		switch err {
+		case nil:
+			return bucketContext(b, rate, capacity, true), nil
+		case memcache.ErrCASConflict, memcache.ErrNotStored, memcache.ErrCacheMiss:
+			// the bucket was updated by another node in the meantime
+		default:
+			return limiter.Context{}, err
+		}
+	}
+
+	return limiter.Context{}, errors.New("limiter: retry count exceeded")
+}
+
+// Peek returns the tokens left in the bucket of the given identifier, without taking one.
+func (s *memcachedBucketStore) Peek(ctx context.Context, key string, rate limiter.Rate) (limiter.Context, error) {
+	current, _, err := s.bucket(fmt.Sprintf("%s:bucket:%s", s.prefix, key))
+	if err != nil {
+		return limiter.Context{}, err
+	}
+
+	capacity := bucketCapacity(s.burst, rate)
+	b, allowed := current.take(time.Now(), rate, capacity, false)
+
+	return bucketContext(b, rate, capacity, allowed), nil
+}
+
+// bucket returns the bucket stored with the key and its item, the item is nil when the bucket is not stored yet
+func (s *memcachedBucketStore) bucket(key string) (tokenBucket, *memcache.Item, error) {
+	item, err := s.client.Get(key)
+	if err == memcache.ErrCacheMiss {
+		return tokenBucket{}, nil, nil
+	}
+	if err != nil {
+		return tokenBucket{}, nil, err
+	}
+
+	b, err := parseMemcachedBucket(string(item.Value))
+	return b, item, err
+}
+
+// formatMemcachedBucket encodes the bucket as the tokens left and the update time in milliseconds
+func formatMemcachedBucket(b tokenBucket) string {
+	return strconv.FormatFloat(b.tokens, 'g', -1, 64) + " " + strconv.FormatInt(b.updated.UnixNano()/int64(time.Millisecond), 10)
+}
+
+func parseMemcachedBucket(data string) (tokenBucket, error) {
+	fields := strings.Fields(data)
+	if len(fields) != 2 {
+		return tokenBucket{}, errors.Errorf("limiter: invalid memcached bucket %q", data)
+	}
+
+	tokens, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return tokenBucket{}, errors.Wrap(err, "limiter: invalid memcached bucket tokens")
+	}
+	updated, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return tokenBucket{}, errors.Wrap(err, "limiter: invalid memcached bucket update time")
+	}
+
+	return tokenBucket{tokens: tokens, updated: time.Unix(0, updated*int64(time.Millisecond))}, nil
+}
+
+// newMemcachedClient creates a memcached client distributing the keys over the servers by their hash. The
+// client does not connect until the first command, so the servers are dialed once to fail early.
+func newMemcachedClient(servers []string) (*memcache.Client, error) {
+	var selector memcache.ServerList
+	if err := selector.SetServers(servers...); err != nil {
+		return nil, err
+	}
+
+	err := selector.Each(func(addr net.Addr) error {
+		conn, err := net.DialTimeout(addr.Network(), addr.String(), memcachedTimeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	client := memcache.NewFromSelector(&selector)
+	client.Timeout = memcachedTimeout
+	client.MaxIdleConns = memcachedPoolSize
+
+	return client, nil
+}
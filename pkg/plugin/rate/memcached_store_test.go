@@ -0,0 +1,212 @@
+package rate
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulule/limiter"
+)
+
+// memcachedServer is a minimal memcached text protocol server implementing the commands the memcached
+// client sends for the memcached store
+type memcachedServer struct {
+	listener net.Listener
+
+	sync.Mutex
+	values  map[string]string
+	expires map[string]time.Time
+	cas     map[string]uint64
+	nextCAS uint64
+}
+
+func newMemcachedServer(t *testing.T) *memcachedServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &memcachedServer{listener: listener, values: make(map[string]string), expires: make(map[string]time.Time), cas: make(map[string]uint64)}
+	go s.serve()
+
+	return s
+}
+
+func (s *memcachedServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			defer conn.Close()
+			reader := bufio.NewReader(conn)
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+
+				args := strings.Fields(line)
+				if len(args) == 0 {
+					fmt.Fprint(conn, "ERROR\r\n")
+					continue
+				}
+
+				var data string
+				if args[0] == "add" || args[0] == "cas" {
+					if data, err = reader.ReadString('\n'); err != nil {
+						return
+					}
+				}
+
+				fmt.Fprint(conn, s.exec(args, strings.TrimSuffix(data, "\r\n")))
+			}
+		}()
+	}
+}
+
+func (s *memcachedServer) exec(args []string, data string) string {
+	s.Lock()
+	defer s.Unlock()
+
+	if len(args) > 1 {
+		if expire, ok := s.expires[args[1]]; ok && time.Now().After(expire) {
+			delete(s.values, args[1])
+			delete(s.expires, args[1])
+		}
+	}
+
+	switch args[0] {
+	case "gets":
+		value, ok := s.values[args[1]]
+		if !ok {
+			return "END\r\n"
+		}
+		return fmt.Sprintf("VALUE %s 0 %d %d\r\n%s\r\nEND\r\n", args[1], len(value), s.cas[args[1]], value)
+	case "add":
+		if _, ok := s.values[args[1]]; ok {
+			return "NOT_STORED\r\n"
+		}
+		return s.store(args, data)
+	case "cas":
+		if _, ok := s.values[args[1]]; !ok {
+			return "NOT_FOUND\r\n"
+		}
+		if cas, _ := strconv.ParseUint(args[5], 10, 64); cas != s.cas[args[1]] {
+			return "EXISTS\r\n"
+		}
+		return s.store(args, data)
+	case "incr":
+		value, ok := s.values[args[1]]
+		if !ok {
+			return "NOT_FOUND\r\n"
+		}
+		counter, _ := strconv.ParseInt(value, 10, 64)
+		delta, _ := strconv.ParseInt(args[2], 10, 64)
+		s.values[args[1]] = strconv.FormatInt(counter+delta, 10)
+		return s.values[args[1]] + "\r\n"
+	default:
+		return "ERROR\r\n"
+	}
+}
+
+func (s *memcachedServer) store(args []string, data string) string {
+	ttl, _ := strconv.Atoi(args[3])
+	s.nextCAS++
+	s.values[args[1]] = data
+	s.expires[args[1]] = time.Now().Add(time.Duration(ttl) * time.Second)
+	s.cas[args[1]] = s.nextCAS
+	return "STORED\r\n"
+}
+
+func (s *memcachedServer) addr() string {
+	return s.listener.Addr().String()
+}
+
+func TestMemcachedStore(t *testing.T) {
+	server := newMemcachedServer(t)
+	defer server.listener.Close()
+
+	store, err := newMemcachedStore(memcachedConfig{Servers: []string{server.addr()}})
+	require.NoError(t, err)
+
+	rate, err := limiter.NewRateFromFormatted("3-M")
+	require.NoError(t, err)
+	limiterInstance := limiter.New(store, rate)
+
+	lctx, err := limiterInstance.Peek(context.Background(), "client")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), lctx.Remaining)
+
+	for i := 1; i <= 4; i++ {
+		lctx, err = limiterInstance.Get(context.Background(), "client")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int64(0), lctx.Remaining)
+	assert.True(t, lctx.Reached)
+	assert.True(t, lctx.Reset <= unixMilli(time.Now().Add(time.Minute)))
+
+	lctx, err = limiterInstance.Peek(context.Background(), "other")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), lctx.Remaining)
+}
+
+func TestMemcachedStoreMultipleServers(t *testing.T) {
+	first := newMemcachedServer(t)
+	defer first.listener.Close()
+	second := newMemcachedServer(t)
+	defer second.listener.Close()
+
+	store, err := newMemcachedStore(memcachedConfig{Servers: []string{first.addr(), second.addr()}, Prefix: "test"})
+	require.NoError(t, err)
+
+	rate, err := limiter.NewRateFromFormatted("10-H")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		lctx, err := store.Get(context.Background(), fmt.Sprintf("client-%d", i), rate)
+		require.NoError(t, err)
+		assert.Equal(t, int64(9), lctx.Remaining)
+	}
+
+	assert.NotEmpty(t, first.values)
+	assert.NotEmpty(t, second.values)
+}
+
+func TestMemcachedStoreUnavailable(t *testing.T) {
+	server := newMemcachedServer(t)
+	addr := server.addr()
+	server.listener.Close()
+
+	_, err := newMemcachedStore(memcachedConfig{Servers: []string{addr}})
+	assert.Error(t, err)
+
+	_, err = newMemcachedStore(memcachedConfig{})
+	assert.Error(t, err)
+}
+
+func TestMemcachedExpiration(t *testing.T) {
+	now := time.Now()
+
+	assert.Equal(t, int32(60), memcachedExpiration(now, now.Add(time.Minute)))
+	assert.Equal(t, int32(2), memcachedExpiration(now, now.Add(1500*time.Millisecond)))
+
+	month := now.Add(31 * 24 * time.Hour)
+	assert.Equal(t, int32(month.Unix()), memcachedExpiration(now, month))
+}
+
+func TestRateLimitPluginMemcachedPolicy(t *testing.T) {
+	server := newMemcachedServer(t)
+	defer server.listener.Close()
+
+	_, err := getLimiterStore(Config{Policy: "memcached", MemcachedConfig: memcachedConfig{Servers: []string{server.addr()}}})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,93 @@
+package rate
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/hellofresh/janus/pkg/group"
+	"github.com/hellofresh/janus/pkg/middleware"
+	log "github.com/sirupsen/logrus"
+	"github.com/ulule/limiter"
+)
+
+// RateLimit limits the requests per client IP address with the configured rate, and the requests of
+// the authenticated consumers with the rate of their consumer group
+type RateLimit struct {
+	store   limiter.Store
+	limiter *limiter.Limiter
+
+	mu       sync.Mutex
+	limiters map[string]*limiter.Limiter
+
+	findGroup func(consumer string) *group.Group
+}
+
+// NewRateLimit creates a new instance of RateLimit
+func NewRateLimit(store limiter.Store, lmt *limiter.Limiter) *RateLimit {
+	return &RateLimit{
+		store:     store,
+		limiter:   lmt,
+		limiters:  make(map[string]*limiter.Limiter),
+		findGroup: group.Find,
+	}
+}
+
+// Handler is the middleware function. The consumer is set by the authentication plugins, so the
+// plugin must follow the authentication plugin of the API definition for the group rates to apply.
+func (l *RateLimit) Handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lmt, key := l.limiterOf(r)
+		context, err := lmt.Get(r.Context(), key)
+		if err != nil {
+			onLimiterError(w, r, err)
+			return
+		}
+
+		// the reset is the Unix time in milliseconds the window tracked by the store expires, or the
+		// bucket is full again
+		w.Header().Add("X-RateLimit-Limit", strconv.FormatInt(context.Limit, 10))
+		w.Header().Add("X-RateLimit-Remaining", strconv.FormatInt(context.Remaining, 10))
+		w.Header().Add("X-RateLimit-Reset", strconv.FormatInt(context.Reset, 10))
+
+		if context.Reached {
+			onLimitReached(w, r, context.Reset)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// limiterOf returns the limiter and the key of the request, the members of a group with a rate are
+// counted per consumer. The requests without a client address share the middleware.UnknownClient key.
+func (l *RateLimit) limiterOf(r *http.Request) (*limiter.Limiter, string) {
+	ipKey := middleware.ClientKeyFromRequest(r)
+
+	consumer := middleware.ConsumerFromContext(r.Context())
+	if consumer == "" {
+		return l.limiter, ipKey
+	}
+
+	g := l.findGroup(consumer)
+	if g == nil || g.RateLimit == "" {
+		return l.limiter, ipKey
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lmt, ok := l.limiters[g.RateLimit]
+	if !ok {
+		rate, err := limiter.NewRateFromFormatted(g.RateLimit)
+		if err != nil {
+			log.WithError(err).WithField("group", g.Name).Error("Invalid consumer group rate limit")
+			return l.limiter, ipKey
+		}
+
+		lmt = limiter.New(l.store, rate)
+		l.limiters[g.RateLimit] = lmt
+	}
+
+	return lmt, "consumer:" + g.Name + ":" + consumer
+}
@@ -0,0 +1,94 @@
+package rate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hellofresh/janus/pkg/errors"
+	"github.com/hellofresh/janus/pkg/group"
+	"github.com/hellofresh/janus/pkg/middleware"
+	"github.com/hellofresh/janus/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/ulule/limiter"
+	smemory "github.com/ulule/limiter/drivers/store/memory"
+)
+
+func serveRateLimit(l *RateLimit, consumer string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if consumer != "" {
+		r = r.WithContext(middleware.WithConsumer(r.Context(), consumer))
+	}
+
+	w := httptest.NewRecorder()
+	l.Handler(http.HandlerFunc(test.Ping)).ServeHTTP(w, r)
+
+	return w
+}
+
+func TestRateLimitGroups(t *testing.T) {
+	groups := map[string]*group.Group{
+		"alice": {Name: "gold", RateLimit: "3-M"},
+		"bob":   {Name: "gold", RateLimit: "3-M"},
+		"carol": {Name: group.DefaultGroup},
+	}
+
+	store := smemory.NewStore()
+	rate, _ := limiter.NewRateFromFormatted("1-M")
+	l := NewRateLimit(store, limiter.New(store, rate))
+	l.findGroup = func(consumer string) *group.Group { return groups[consumer] }
+
+	for i := 0; i < 3; i++ {
+		w := serveRateLimit(l, "alice")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "3", w.Header().Get("X-RateLimit-Limit"))
+	}
+	assert.Equal(t, http.StatusTooManyRequests, serveRateLimit(l, "alice").Code)
+	assert.Equal(t, http.StatusOK, serveRateLimit(l, "bob").Code, "the group rate applies to each member")
+
+	assert.Equal(t, http.StatusOK, serveRateLimit(l, "carol").Code)
+	assert.Equal(t, http.StatusTooManyRequests, serveRateLimit(l, "").Code, "a group without rate keeps the configured limit")
+
+	groups["bob"].RateLimit = "10-M"
+	assert.Equal(t, "10", serveRateLimit(l, "bob").Header().Get("X-RateLimit-Limit"), "the group changes apply to the next request")
+}
+
+func TestRateLimitProblemDetails(t *testing.T) {
+	store := smemory.NewStore()
+	rate, _ := limiter.NewRateFromFormatted("1-M")
+	l := NewRateLimit(store, limiter.New(store, rate))
+	handler := errors.ProblemDetails(l.Handler(http.HandlerFunc(test.Ping)))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, errors.ProblemContentType, w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `"detail":"limit exceeded"`)
+
+	w = serveRateLimit(l, "")
+	assert.Equal(t, "Limit exceeded\n", w.Body.String(), "the limiter text is kept without problem details")
+}
+
+func TestRateLimitUnknownClientAddress(t *testing.T) {
+	store := smemory.NewStore()
+	rate, _ := limiter.NewRateFromFormatted("1-M")
+	l := NewRateLimit(store, limiter.New(store, rate))
+	handler := l.Handler(http.HandlerFunc(test.Ping))
+
+	codes := make([]int, 0, 2)
+	for i := 0; i < 2; i++ {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "@"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		codes = append(codes, w.Code)
+		assert.Equal(t, "1", w.Header().Get("X-RateLimit-Limit"))
+	}
+
+	assert.Equal(t, []int{http.StatusOK, http.StatusTooManyRequests}, codes, "the clients without address share a limit")
+}
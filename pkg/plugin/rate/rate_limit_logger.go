@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"github.com/felixge/httpsnoop"
+	"github.com/hellofresh/janus/pkg/middleware"
 	"github.com/hellofresh/stats-go/bucket"
 	"github.com/hellofresh/stats-go/client"
 	log "github.com/sirupsen/logrus"
@@ -25,7 +26,7 @@ func NewRateLimitLogger(lmt *limiter.Limiter, statsClient client.Client) func(ha
 
 			m := httpsnoop.CaptureMetrics(handler, w, r)
 
-			limiterIP := limiter.GetIP(r)
+			limiterIP := middleware.ClientIPFromRequest(r)
 			if m.Code == http.StatusTooManyRequests {
 				log.WithFields(log.Fields{
 					"ip_address":  limiterIP.String(),
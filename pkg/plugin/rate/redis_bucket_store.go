@@ -0,0 +1,129 @@
+package rate
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/pkg/errors"
+	"github.com/ulule/limiter"
+)
+
+// bucketScript refills the bucket for the time elapsed since its last update and takes a token from it,
+// atomically on the redis server. The redis clock is used so the nodes agree on the elapsed time. The
+// bucket expires once it is full again, a missing bucket is full.
+//
+// KEYS[1] is the bucket key, ARGV are the capacity, the refill rate in tokens per millisecond and 1 to
+// take a token or 0 to peek. It returns 1 when a token was left, the tokens left and the redis time in
+// milliseconds.
+var bucketScript = redis.NewScript(`
+if redis.replicate_commands then
+	redis.replicate_commands()
+end
+
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(bucket[1])
+local updated = tonumber(bucket[2])
+if tokens == nil or updated == nil then
+	tokens = capacity
+elseif now > updated then
+	tokens = math.min(capacity, tokens + (now - updated) * rate)
+end
+
+local allowed = 0
+if tokens >= 1 then
+	allowed = 1
+	if ARGV[3] == '1' then
+		tokens = tokens - 1
+	end
+end
+
+if ARGV[3] == '1' then
+	redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'updated', string.format('%d', now))
+	redis.call('PEXPIRE', KEYS[1], string.format('%d', math.ceil((capacity - tokens) / rate) + 1))
+end
+
+return {allowed, tostring(tokens), now}
+`)
+
+// redisBucketStore keeps the token buckets on a redis server, shared by the nodes
+type redisBucketStore struct {
+	client *redis.Client
+	prefix string
+	burst  int64
+}
+
+func newRedisBucketStore(client *redis.Client, prefix string, burst int64) (limiter.Store, error) {
+	if err := client.Ping().Err(); err != nil {
+		return nil, errors.Wrap(err, "limiter: cannot ping redis server")
+	}
+
+	return &redisBucketStore{client: client, prefix: prefix, burst: burst}, nil
+}
+
+// Get takes a token from the bucket of the given identifier.
+func (s *redisBucketStore) Get(ctx context.Context, key string, rate limiter.Rate) (limiter.Context, error) {
+	return s.do(key, rate, true)
+}
+
+// Peek returns the tokens left in the bucket of the given identifier, without taking one.
+func (s *redisBucketStore) Peek(ctx context.Context, key string, rate limiter.Rate) (limiter.Context, error) {
+	return s.do(key, rate, false)
+}
+
+func (s *redisBucketStore) do(key string, rate limiter.Rate, take bool) (limiter.Context, error) {
+	capacity := bucketCapacity(s.burst, rate)
+	perMillisecond := refillRate(rate) * float64(time.Millisecond)
+
+	takeArg := "0"
+	if take {
+		takeArg = "1"
+	}
+
+	result, err := bucketScript.Run(s.client, []string{s.prefix + ":bucket:" + key},
+		capacity, strconv.FormatFloat(perMillisecond, 'g', -1, 64), takeArg).Result()
+	if err != nil {
+		return limiter.Context{}, err
+	}
+
+	b, allowed, err := parseBucketResult(result)
+	if err != nil {
+		return limiter.Context{}, err
+	}
+
+	return bucketContext(b, rate, capacity, allowed), nil
+}
+
+func parseBucketResult(result interface{}) (tokenBucket, bool, error) {
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 3 {
+		return tokenBucket{}, false, errors.Errorf("limiter: unexpected bucket script result %v", result)
+	}
+
+	allowed, ok := values[0].(int64)
+	if !ok {
+		return tokenBucket{}, false, errors.Errorf("limiter: unexpected bucket script result %v", result)
+	}
+
+	raw, ok := values[1].(string)
+	if !ok {
+		return tokenBucket{}, false, errors.Errorf("limiter: unexpected bucket script result %v", result)
+	}
+	tokens, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return tokenBucket{}, false, errors.Wrap(err, "limiter: invalid bucket tokens")
+	}
+
+	now, ok := values[2].(int64)
+	if !ok {
+		return tokenBucket{}, false, errors.Errorf("limiter: unexpected bucket script result %v", result)
+	}
+
+	return tokenBucket{tokens: tokens, updated: time.Unix(0, now*int64(time.Millisecond))}, allowed == 1, nil
+}
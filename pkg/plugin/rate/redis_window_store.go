@@ -0,0 +1,86 @@
+package rate
+
+import (
+	"context"
+
+	"github.com/go-redis/redis"
+	"github.com/pkg/errors"
+	"github.com/ulule/limiter"
+)
+
+// redisWindowStore keeps the windows on a redis server, shared by the nodes. The reset of a window is
+// the expiry of its counter on the redis clock, so the nodes agree on it whatever their own clocks.
+type redisWindowStore struct {
+	client *redis.Client
+	prefix string
+}
+
+func newRedisWindowStore(client *redis.Client, prefix string) (limiter.Store, error) {
+	if err := client.Ping().Err(); err != nil {
+		return nil, errors.Wrap(err, "limiter: cannot ping redis server")
+	}
+
+	return &redisWindowStore{client: client, prefix: prefix}, nil
+}
+
+// Get increments the counter of the window of the given identifier, the counter is created with the
+// expiration of the window on the first request.
+func (s *redisWindowStore) Get(ctx context.Context, key string, rate limiter.Rate) (limiter.Context, error) {
+	key = s.prefix + ":" + key
+
+	var (
+		count *redis.IntCmd
+		ttl   *redis.DurationCmd
+		now   *redis.TimeCmd
+	)
+	_, err := s.client.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.SetNX(key, 0, rate.Period)
+		count = pipe.Incr(key)
+		ttl = pipe.PTTL(key)
+		now = pipe.Time()
+		return nil
+	})
+	if err != nil {
+		return limiter.Context{}, err
+	}
+
+	expiration := now.Val().Add(ttl.Val())
+	if ttl.Val() < 0 {
+		// the counters created without an expiration, e.g. by an older version, expire with this window
+		if err := s.client.PExpire(key, rate.Period).Err(); err != nil {
+			return limiter.Context{}, err
+		}
+		expiration = now.Val().Add(rate.Period)
+	}
+
+	return windowContext(rate, expiration, count.Val()), nil
+}
+
+// Peek returns the counter of the window of the given identifier, without incrementing it.
+func (s *redisWindowStore) Peek(ctx context.Context, key string, rate limiter.Rate) (limiter.Context, error) {
+	key = s.prefix + ":" + key
+
+	var (
+		count *redis.StringCmd
+		ttl   *redis.DurationCmd
+		now   *redis.TimeCmd
+	)
+	_, err := s.client.Pipelined(func(pipe redis.Pipeliner) error {
+		count = pipe.Get(key)
+		ttl = pipe.PTTL(key)
+		now = pipe.Time()
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return limiter.Context{}, err
+	}
+
+	// the missing counter is the one of a window starting now
+	expiration := now.Val().Add(ttl.Val())
+	if ttl.Val() < 0 {
+		expiration = now.Val().Add(rate.Period)
+	}
+
+	n, _ := count.Int64()
+	return windowContext(rate, expiration, n), nil
+}
@@ -12,31 +12,60 @@ import (
 	"github.com/hellofresh/stats-go/client"
 	"github.com/ulule/limiter"
 	"github.com/ulule/limiter/drivers/middleware/stdlib"
-	storeMemory "github.com/ulule/limiter/drivers/store/memory"
-	storeRedis "github.com/ulule/limiter/drivers/store/redis"
 )
 
 var (
 	statsClient client.Client
 	// ErrInvalidPolicy is used when an invalid policy was provided
 	ErrInvalidPolicy = errors.New(http.StatusBadRequest, "policy is not supported")
+	// ErrInvalidBurst is used when a negative token bucket burst was provided
+	ErrInvalidBurst = errors.New(http.StatusBadRequest, "burst must not be negative")
+	// ErrInvalidGlobalPolicy is used when the global rate limit does not use a store shared by the nodes
+	ErrInvalidGlobalPolicy = errors.New(http.StatusBadRequest, "global rate limit policy must be redis or memcached")
+	// ErrLimitExceeded is used when the request is over the rate limit and the errors are rendered as
+	// problem details
+	ErrLimitExceeded = errors.New(http.StatusTooManyRequests, "limit exceeded")
 )
 
 const (
 	// DefaultPrefix is the default prefix to use for the key in the store.
 	DefaultPrefix = "limiter"
+
+	redisPoolSize    = 3
+	redisIdleTimeout = 240 * time.Second
 )
 
 // Config represents a rate limit config
 type Config struct {
-	Limit       string      `json:"limit"`
-	Policy      string      `json:"policy"`
+	Limit  string `json:"limit"`
+	Policy string `json:"policy"`
+	// Mode is how the requests are limited: window counts the requests per period of the limit,
+	// token_bucket refills a bucket of burst tokens with the limit
+	Mode string `json:"mode" valid:"in(window|token_bucket)~mode must be one of window or token_bucket"`
+	// Burst is the capacity of the token bucket, it defaults to the requests of the limit
+	Burst       int64       `json:"burst"`
 	RedisConfig redisConfig `json:"redis"`
+	// MemcachedConfig is used by the memcached policy
+	MemcachedConfig memcachedConfig `json:"memcached"`
+	// Fallback is the policy used while the shared store is unavailable: local, open or closed
+	Fallback string `json:"fallback" valid:"in(local|open|closed)~fallback must be one of local, open or closed"`
 }
 
 type redisConfig struct {
 	DSN    string `json:"dsn"`
 	Prefix string `json:"prefix"`
+	// MasterName and SentinelAddrs enable Redis Sentinel, the master is discovered by the
+	// sentinels and followed on failover. DSN is ignored in that case.
+	MasterName    string   `json:"master_name"`
+	SentinelAddrs []string `json:"sentinel_addrs"`
+	Password      string   `json:"password"`
+	DB            int      `json:"db"`
+}
+
+type memcachedConfig struct {
+	// Servers are the memcached server addresses, the counters are distributed over them by key
+	Servers []string `json:"servers"`
+	Prefix  string   `json:"prefix"`
 }
 
 func init() {
@@ -44,6 +73,12 @@ func init() {
 	plugin.RegisterPlugin("rate_limit", plugin.Plugin{
 		Action:   setupRateLimit,
 		Validate: validateConfig,
+		Stateful: true,
+	})
+	plugin.RegisterPlugin("global_rate_limit", plugin.Plugin{
+		Action:   setupGlobalRateLimit,
+		Validate: validateGlobalConfig,
+		Stateful: true,
 	})
 }
 
@@ -64,6 +99,10 @@ func validateConfig(rawConfig plugin.Config) (bool, error) {
 		return false, err
 	}
 
+	if config.Burst < 0 {
+		return false, ErrInvalidBurst
+	}
+
 	return govalidator.ValidateStruct(config)
 }
 
@@ -74,47 +113,131 @@ func setupRateLimit(def *proxy.RouterDefinition, rawConfig plugin.Config) error
 		return err
 	}
 
-	rate, err := limiter.NewRateFromFormatted(config.Limit)
+	limiterInstance, limiterStore, err := newLimiter(config)
 	if err != nil {
 		return err
 	}
 
-	limiterStore, err := getLimiterStore(config.Policy, config.RedisConfig)
+	def.AddMiddleware(NewRateLimitLogger(limiterInstance, statsClient))
+	def.AddMiddleware(NewRateLimit(limiterStore, limiterInstance).Handler)
+
+	return nil
+}
+
+// newLimiter creates the limiter of the configured rate and the store it counts the requests in
+func newLimiter(config Config) (*limiter.Limiter, limiter.Store, error) {
+	rate, err := limiter.NewRateFromFormatted(config.Limit)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	limiterInstance := limiter.New(limiterStore, rate)
-	def.AddMiddleware(NewRateLimitLogger(limiterInstance, statsClient))
-	def.AddMiddleware(stdlib.NewMiddleware(limiterInstance).Handler)
+	var limiterStore limiter.Store
+	if isSharedPolicy(config.Policy) && config.Fallback != "" {
+		limiterStore = newFallbackStore(config.Policy, config.Fallback, newLocalStore(config), func() (limiter.Store, error) {
+			return getSharedStore(config)
+		})
+	} else if limiterStore, err = getSharedStore(config); err != nil {
+		return nil, nil, err
+	}
 
-	return nil
+	return limiter.New(limiterStore, rate), limiterStore, nil
+}
+
+func onLimiterError(w http.ResponseWriter, r *http.Request, err error) {
+	errors.Handler(w, err)
+}
+
+// onLimitReached answers the requests over the limit with the plain text of the limiter, or with the
+// problem details when they are enabled. The Retry-After header tells the seconds left until the reset.
+func onLimitReached(w http.ResponseWriter, r *http.Request, reset int64) {
+	setRetryAfter(w, reset)
+	if errors.ProblemDetailsEnabled(w) {
+		errors.Handler(w, ErrLimitExceeded)
+		return
+	}
+
+	stdlib.DefaultLimitReachedHandler(w, r)
 }
 
-func getLimiterStore(policy string, config redisConfig) (limiter.Store, error) {
-	switch policy {
+func isSharedPolicy(policy string) bool {
+	return policy == "redis" || policy == "memcached"
+}
+
+// getSharedStore returns the limiter store, the lookups of the stores shared by the nodes are recorded
+func getSharedStore(config Config) (limiter.Store, error) {
+	store, err := getLimiterStore(config)
+	if err != nil || !isSharedPolicy(config.Policy) {
+		return store, err
+	}
+
+	return newStatsStore(config.Policy, store), nil
+}
+
+func getLimiterStore(config Config) (limiter.Store, error) {
+	switch config.Policy {
 	case "redis":
-		option, err := redis.ParseURL(config.DSN)
+		redisClient, err := newRedisClient(config.RedisConfig)
 		if err != nil {
 			return nil, err
 		}
-		option.PoolSize = 3
-		option.IdleTimeout = 240 * time.Second
-		redisClient := redis.NewClient(option)
 
-		if config.Prefix == "" {
-			config.Prefix = DefaultPrefix
+		prefix := config.RedisConfig.Prefix
+		if prefix == "" {
+			prefix = DefaultPrefix
 		}
 
-		return storeRedis.NewStoreWithOptions(redisClient, limiter.StoreOptions{
-			Prefix:   config.Prefix,
-			MaxRetry: limiter.DefaultMaxRetry,
-		})
+		var store limiter.Store
+		if config.Mode == ModeTokenBucket {
+			store, err = newRedisBucketStore(redisClient, prefix, config.Burst)
+		} else {
+			store, err = newRedisWindowStore(redisClient, prefix)
+		}
+		if err != nil {
+			redisClient.Close()
+		}
+
+		return store, err
+
+	case "memcached":
+		if config.Mode == ModeTokenBucket {
+			return newMemcachedBucketStore(config.MemcachedConfig, config.Burst)
+		}
+		return newMemcachedStore(config.MemcachedConfig)
 
 	case "local":
-		return storeMemory.NewStore(), nil
+		return newLocalStore(config), nil
 
 	default:
 		return nil, ErrInvalidPolicy
 	}
 }
+
+// newLocalStore returns the in-memory store of the mode
+func newLocalStore(config Config) limiter.Store {
+	if config.Mode == ModeTokenBucket {
+		return newMemoryBucketStore(config.Burst)
+	}
+	return newMemoryWindowStore()
+}
+
+func newRedisClient(config redisConfig) (*redis.Client, error) {
+	if config.MasterName != "" {
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:    config.MasterName,
+			SentinelAddrs: config.SentinelAddrs,
+			Password:      config.Password,
+			DB:            config.DB,
+			PoolSize:      redisPoolSize,
+			IdleTimeout:   redisIdleTimeout,
+		}), nil
+	}
+
+	option, err := redis.ParseURL(config.DSN)
+	if err != nil {
+		return nil, err
+	}
+	option.PoolSize = redisPoolSize
+	option.IdleTimeout = redisIdleTimeout
+
+	return redis.NewClient(option), nil
+}
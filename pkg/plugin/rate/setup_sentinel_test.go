@@ -0,0 +1,346 @@
+package rate
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulule/limiter"
+	storeRedis "github.com/ulule/limiter/drivers/store/redis"
+)
+
+const testMasterName = "mymaster"
+
+// respStorage is the keyspace shared by the fake redis masters, it simulates the replication
+// between the old and the promoted master
+type respStorage struct {
+	sync.Mutex
+	values  map[string]int64
+	expires map[string]time.Time
+}
+
+type respConn struct {
+	sync.Mutex
+	net.Conn
+	subscribed bool
+	multi      [][]string
+}
+
+func (c *respConn) write(reply interface{}) {
+	c.Lock()
+	defer c.Unlock()
+	fmt.Fprint(c.Conn, encodeReply(reply))
+}
+
+// respServer is a minimal RESP server implementing the commands used by the limiter store and
+// the sentinel failover client
+type respServer struct {
+	listener net.Listener
+	handle   func(c *respConn, args []string) interface{}
+
+	sync.Mutex
+	conns []*respConn
+}
+
+func newRespServer(t *testing.T, handle func(c *respConn, args []string) interface{}) *respServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &respServer{listener: listener, handle: handle}
+	go s.serve()
+
+	return s
+}
+
+func (s *respServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		c := &respConn{Conn: conn}
+		s.Lock()
+		s.conns = append(s.conns, c)
+		s.Unlock()
+
+		go func() {
+			reader := bufio.NewReader(conn)
+			for {
+				args, err := readCommand(reader)
+				if err != nil {
+					conn.Close()
+					return
+				}
+				c.write(s.handle(c, args))
+			}
+		}()
+	}
+}
+
+func (s *respServer) addr() (string, string) {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return host, port
+}
+
+func (s *respServer) Close() {
+	s.listener.Close()
+
+	s.Lock()
+	defer s.Unlock()
+	for _, c := range s.conns {
+		c.Close()
+	}
+}
+
+func (s *respServer) publish(channel, message string) {
+	s.Lock()
+	defer s.Unlock()
+	for _, c := range s.conns {
+		if c.subscribed {
+			c.write([]interface{}{"message", channel, message})
+		}
+	}
+}
+
+func newFakeMaster(t *testing.T, storage *respStorage) *respServer {
+	return newRespServer(t, func(c *respConn, args []string) interface{} {
+		cmd := strings.ToUpper(args[0])
+		switch {
+		case cmd == "MULTI":
+			c.multi = [][]string{}
+			return "+OK"
+		case cmd == "EXEC":
+			replies := make([]interface{}, 0, len(c.multi))
+			for _, queued := range c.multi {
+				replies = append(replies, storage.exec(queued))
+			}
+			c.multi = nil
+			return replies
+		case c.multi != nil:
+			c.multi = append(c.multi, args)
+			return "+QUEUED"
+		default:
+			return storage.exec(args)
+		}
+	})
+}
+
+func newFakeSentinel(t *testing.T, master *respServer) (*respServer, func(*respServer)) {
+	var mu sync.Mutex
+	sentinel := newRespServer(t, func(c *respConn, args []string) interface{} {
+		switch strings.ToUpper(args[0]) {
+		case "SENTINEL":
+			if strings.ToLower(args[1]) == "sentinels" {
+				return []interface{}{}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			host, port := master.addr()
+			return []interface{}{host, port}
+		case "SUBSCRIBE":
+			c.subscribed = true
+			return []interface{}{"subscribe", args[1], int64(1)}
+		case "PING":
+			if c.subscribed {
+				return []interface{}{"pong", ""}
+			}
+			return "+PONG"
+		default:
+			return fmt.Errorf("unknown command %s", args[0])
+		}
+	})
+
+	failover := func(promoted *respServer) {
+		mu.Lock()
+		oldHost, oldPort := master.addr()
+		master = promoted
+		mu.Unlock()
+
+		newHost, newPort := promoted.addr()
+		sentinel.publish("+switch-master", strings.Join([]string{testMasterName, oldHost, oldPort, newHost, newPort}, " "))
+	}
+
+	return sentinel, failover
+}
+
+func (s *respStorage) exec(args []string) interface{} {
+	s.Lock()
+	defer s.Unlock()
+
+	key := ""
+	if len(args) > 1 {
+		key = args[1]
+	}
+	if expire, ok := s.expires[key]; ok && time.Now().After(expire) {
+		delete(s.values, key)
+		delete(s.expires, key)
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG"
+	case "WATCH", "UNWATCH":
+		return "+OK"
+	case "GET":
+		if value, ok := s.values[key]; ok {
+			return strconv.FormatInt(value, 10)
+		}
+		return nil
+	case "SET":
+		if _, ok := s.values[key]; ok {
+			return nil
+		}
+		value, _ := strconv.ParseInt(args[2], 10, 64)
+		s.values[key] = value
+		for i := 3; i < len(args)-1; i++ {
+			ttl, _ := strconv.Atoi(args[i+1])
+			switch strings.ToUpper(args[i]) {
+			case "EX":
+				s.expires[key] = time.Now().Add(time.Duration(ttl) * time.Second)
+			case "PX":
+				s.expires[key] = time.Now().Add(time.Duration(ttl) * time.Millisecond)
+			}
+		}
+		return "+OK"
+	case "INCR":
+		s.values[key]++
+		return s.values[key]
+	case "PTTL":
+		expire, ok := s.expires[key]
+		if !ok {
+			return int64(-1)
+		}
+		return int64(time.Until(expire) / time.Millisecond)
+	case "EXPIRE":
+		ttl, _ := strconv.Atoi(args[2])
+		s.expires[key] = time.Now().Add(time.Duration(ttl) * time.Second)
+		return int64(1)
+	case "PEXPIRE":
+		ttl, _ := strconv.Atoi(args[2])
+		s.expires[key] = time.Now().Add(time.Duration(ttl) * time.Millisecond)
+		return int64(1)
+	case "TIME":
+		now := time.Now()
+		return []interface{}{strconv.FormatInt(now.Unix(), 10), strconv.Itoa(now.Nanosecond() / int(time.Microsecond))}
+	default:
+		return fmt.Errorf("unknown command %s", args[0])
+	}
+}
+
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		if _, err := reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, strings.TrimSuffix(arg, "\r\n"))
+	}
+
+	return args, nil
+}
+
+func encodeReply(reply interface{}) string {
+	switch r := reply.(type) {
+	case nil:
+		return "$-1\r\n"
+	case error:
+		return fmt.Sprintf("-ERR %s\r\n", r)
+	case int64:
+		return fmt.Sprintf(":%d\r\n", r)
+	case string:
+		if strings.HasPrefix(r, "+") {
+			return r + "\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(r), r)
+	case []interface{}:
+		encoded := fmt.Sprintf("*%d\r\n", len(r))
+		for _, item := range r {
+			encoded += encodeReply(item)
+		}
+		return encoded
+	default:
+		panic(fmt.Sprintf("unsupported reply %T", reply))
+	}
+}
+
+func TestRateLimitRedisSentinelFailover(t *testing.T) {
+	storage := &respStorage{values: make(map[string]int64), expires: make(map[string]time.Time)}
+	master := newFakeMaster(t, storage)
+	defer master.Close()
+	replica := newFakeMaster(t, storage)
+	defer replica.Close()
+	sentinel, failover := newFakeSentinel(t, master)
+	defer sentinel.Close()
+
+	client, err := newRedisClient(redisConfig{
+		MasterName:    testMasterName,
+		SentinelAddrs: []string{sentinel.listener.Addr().String()},
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	store, err := storeRedis.NewStoreWithOptions(client, limiter.StoreOptions{Prefix: "test", MaxRetry: limiter.DefaultMaxRetry})
+	require.NoError(t, err)
+
+	rate, err := limiter.NewRateFromFormatted("10-M")
+	require.NoError(t, err)
+	limiterInstance := limiter.New(store, rate)
+
+	for i := 1; i <= 5; i++ {
+		lctx, err := limiterInstance.Get(context.Background(), "client")
+		require.NoError(t, err)
+		assert.Equal(t, int64(10-i), lctx.Remaining)
+	}
+
+	failover(replica)
+	master.Close()
+
+	// requests in flight during the promotion may fail until the client follows the new master
+	var lctx limiter.Context
+	for i := 0; i < 50; i++ {
+		if lctx, err = limiterInstance.Get(context.Background(), "client"); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), lctx.Remaining)
+}
+
+func TestRateLimitPluginRedisSentinelPolicy(t *testing.T) {
+	storage := &respStorage{values: make(map[string]int64), expires: make(map[string]time.Time)}
+	master := newFakeMaster(t, storage)
+	defer master.Close()
+	sentinel, _ := newFakeSentinel(t, master)
+	defer sentinel.Close()
+
+	_, err := getLimiterStore(Config{Policy: "redis", RedisConfig: redisConfig{
+		MasterName:    testMasterName,
+		SentinelAddrs: []string{sentinel.listener.Addr().String()},
+	}})
+	assert.NoError(t, err)
+}
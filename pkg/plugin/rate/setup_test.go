@@ -6,6 +6,7 @@ import (
 	"github.com/hellofresh/janus/pkg/plugin"
 	"github.com/hellofresh/janus/pkg/proxy"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRateLimitConfig(t *testing.T) {
@@ -68,3 +69,62 @@ func TestRateLimitPluginInvalidPolicy(t *testing.T) {
 
 	assert.Error(t, err)
 }
+
+func TestRateLimitPluginRedisPolicyWithFallback(t *testing.T) {
+	rawConfig := map[string]interface{}{
+		"limit":    "10-S",
+		"policy":   "redis",
+		"fallback": "local",
+	}
+
+	def := proxy.NewRouterDefinition(proxy.NewDefinition())
+	err := setupRateLimit(def, rawConfig)
+
+	assert.NoError(t, err)
+	assert.Len(t, def.Middleware(), 2)
+}
+
+func TestRateLimitConfigInvalidFallback(t *testing.T) {
+	isValid, err := validateConfig(map[string]interface{}{
+		"limit":    "10-S",
+		"policy":   "redis",
+		"fallback": "wrong",
+	})
+
+	assert.False(t, isValid)
+	assert.Error(t, err)
+}
+
+func TestRateLimitPluginTokenBucketMode(t *testing.T) {
+	rawConfig := map[string]interface{}{
+		"limit":  "10-S",
+		"policy": "local",
+		"mode":   "token_bucket",
+		"burst":  50,
+	}
+
+	def := proxy.NewRouterDefinition(proxy.NewDefinition())
+	err := setupRateLimit(def, rawConfig)
+
+	assert.NoError(t, err)
+	assert.Len(t, def.Middleware(), 2)
+
+	var config Config
+	require.NoError(t, plugin.Decode(rawConfig, &config))
+	assert.IsType(t, &memoryBucketStore{}, newLocalStore(config))
+}
+
+func TestRateLimitConfigInvalidMode(t *testing.T) {
+	invalid := map[string]map[string]interface{}{
+		"mode":  {"limit": "10-S", "policy": "local", "mode": "wrong"},
+		"burst": {"limit": "10-S", "policy": "local", "mode": "token_bucket", "burst": -1},
+	}
+
+	for name, rawConfig := range invalid {
+		t.Run(name, func(t *testing.T) {
+			isValid, err := validateConfig(rawConfig)
+			assert.False(t, isValid)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package rate
+
+import (
+	"context"
+
+	obs "github.com/hellofresh/janus/pkg/observability"
+	"github.com/ulule/limiter"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// statsStore records the lookups of a shared store, a lookup misses when the store fails
+type statsStore struct {
+	limiter.Store
+	policy string
+}
+
+func newStatsStore(policy string, store limiter.Store) *statsStore {
+	return &statsStore{Store: store, policy: policy}
+}
+
+// Get returns the limit for given identifier.
+func (s *statsStore) Get(ctx context.Context, key string, rate limiter.Rate) (limiter.Context, error) {
+	lctx, err := s.Store.Get(ctx, key, rate)
+	recordStoreLookup(ctx, s.policy, err == nil)
+	return lctx, err
+}
+
+// Peek returns the limit for given identifier, without modification on current values.
+func (s *statsStore) Peek(ctx context.Context, key string, rate limiter.Rate) (limiter.Context, error) {
+	lctx, err := s.Store.Peek(ctx, key, rate)
+	recordStoreLookup(ctx, s.policy, err == nil)
+	return lctx, err
+}
+
+func recordStoreLookup(ctx context.Context, policy string, hit bool) {
+	result := obs.StoreHit
+	if !hit {
+		result = obs.StoreMiss
+	}
+
+	stats.RecordWithTags(ctx, []tag.Mutator{
+		tag.Upsert(obs.KeyRateLimitPolicy, policy),
+		tag.Upsert(obs.KeyRateLimitResult, result),
+	}, obs.MRateLimitStoreRequests.M(1))
+}
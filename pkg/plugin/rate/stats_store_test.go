@@ -0,0 +1,46 @@
+package rate
+
+import (
+	"context"
+	"testing"
+
+	obs "github.com/hellofresh/janus/pkg/observability"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulule/limiter"
+	storeMemory "github.com/ulule/limiter/drivers/store/memory"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+func TestStatsStoreRecordsLookups(t *testing.T) {
+	require.NoError(t, view.Register(obs.AllViews...))
+	defer view.Unregister(obs.AllViews...)
+
+	shared := &toggleStore{Store: storeMemory.NewStore()}
+	store := newStatsStore("redis", shared)
+	rate, err := limiter.NewRateFromFormatted("10-M")
+	require.NoError(t, err)
+
+	_, err = store.Get(context.Background(), "client", rate)
+	require.NoError(t, err)
+
+	shared.down = true
+	_, err = store.Peek(context.Background(), "client", rate)
+	require.Error(t, err)
+
+	rows, err := view.RetrieveData("plugin_rate_limit_store_request_total")
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+
+	results := make(map[string]int64)
+	for _, row := range rows {
+		assert.Contains(t, row.Tags, tag.Tag{Key: obs.KeyRateLimitPolicy, Value: "redis"})
+		for _, tg := range row.Tags {
+			if tg.Key == obs.KeyRateLimitResult {
+				results[tg.Value] = row.Data.(*view.CountData).Value
+			}
+		}
+	}
+	assert.Equal(t, map[string]int64{obs.StoreHit: 1, obs.StoreMiss: 1}, results)
+}
@@ -0,0 +1,138 @@
+package rate
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ulule/limiter"
+)
+
+const (
+	// ModeWindow counts the requests of every period of the rate
+	ModeWindow = "window"
+	// ModeTokenBucket takes a token from a bucket for every request, the bucket holds up to the burst
+	// tokens and is refilled with the rate
+	ModeTokenBucket = "token_bucket"
+
+	// bucketCleanupInterval is how often the full buckets are removed from the memory store
+	bucketCleanupInterval = time.Minute
+)
+
+// tokenBucket is the state of a bucket: the tokens left at the time of its last update
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// bucketCapacity returns the tokens a bucket holds, the limit of the rate when no burst is set
+func bucketCapacity(burst int64, rate limiter.Rate) int64 {
+	if burst > 0 {
+		return burst
+	}
+	return rate.Limit
+}
+
+// refillRate returns the tokens added to a bucket per nanosecond
+func refillRate(rate limiter.Rate) float64 {
+	return float64(rate.Limit) / float64(rate.Period)
+}
+
+// take refills the bucket for the time elapsed since its last update and takes a token from it when
+// take is set. A bucket never updated before is full.
+func (b tokenBucket) take(now time.Time, rate limiter.Rate, capacity int64, take bool) (tokenBucket, bool) {
+	if b.updated.IsZero() {
+		b.tokens = float64(capacity)
+	} else if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens = math.Min(float64(capacity), b.tokens+float64(elapsed)*refillRate(rate))
+	}
+	b.updated = now
+
+	if b.tokens < 1 {
+		return b, false
+	}
+	if take {
+		b.tokens--
+	}
+
+	return b, true
+}
+
+// full returns when the bucket is refilled up to the capacity
+func (b tokenBucket) full(rate limiter.Rate, capacity int64) time.Time {
+	missing := float64(capacity) - b.tokens
+	if missing <= 0 {
+		return b.updated
+	}
+
+	return b.updated.Add(time.Duration(math.Ceil(missing / refillRate(rate))))
+}
+
+// bucketContext returns the limiter context of the bucket, the limit is the capacity of the bucket
+// and the reset is the time the bucket is full again
+func bucketContext(b tokenBucket, rate limiter.Rate, capacity int64, allowed bool) limiter.Context {
+	return limiter.Context{
+		Limit:     capacity,
+		Remaining: int64(math.Floor(b.tokens)),
+		Reset:     unixMilli(b.full(rate, capacity)),
+		Reached:   !allowed,
+	}
+}
+
+// memoryBucketStore keeps the token buckets in-memory on the node
+type memoryBucketStore struct {
+	burst int64
+
+	sync.Mutex
+	buckets map[string]memoryBucket
+	cleaned time.Time
+}
+
+type memoryBucket struct {
+	tokenBucket
+	full time.Time
+}
+
+func newMemoryBucketStore(burst int64) *memoryBucketStore {
+	return &memoryBucketStore{burst: burst, buckets: make(map[string]memoryBucket), cleaned: time.Now()}
+}
+
+// Get takes a token from the bucket of the given identifier.
+func (s *memoryBucketStore) Get(ctx context.Context, key string, rate limiter.Rate) (limiter.Context, error) {
+	return s.do(key, rate, true), nil
+}
+
+// Peek returns the tokens left in the bucket of the given identifier, without taking one.
+func (s *memoryBucketStore) Peek(ctx context.Context, key string, rate limiter.Rate) (limiter.Context, error) {
+	return s.do(key, rate, false), nil
+}
+
+func (s *memoryBucketStore) do(key string, rate limiter.Rate, take bool) limiter.Context {
+	s.Lock()
+	defer s.Unlock()
+
+	now := time.Now()
+	capacity := bucketCapacity(s.burst, rate)
+
+	b, allowed := s.buckets[key].take(now, rate, capacity, take)
+	if take {
+		s.buckets[key] = memoryBucket{tokenBucket: b, full: b.full(rate, capacity)}
+	}
+
+	if now.Sub(s.cleaned) >= bucketCleanupInterval {
+		s.cleanup(now)
+	}
+
+	return bucketContext(b, rate, capacity, allowed)
+}
+
+// cleanup removes the buckets that are full by now, they are the same as the missing ones
+func (s *memoryBucketStore) cleanup(now time.Time) {
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+	s.cleaned = now
+}
@@ -0,0 +1,142 @@
+package rate
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulule/limiter"
+)
+
+func TestTokenBucketTake(t *testing.T) {
+	rate, err := limiter.NewRateFromFormatted("2-S")
+	require.NoError(t, err)
+	now := time.Now()
+
+	b, allowed := tokenBucket{}.take(now, rate, 5, true)
+	assert.True(t, allowed)
+	assert.Equal(t, float64(4), b.tokens, "a new bucket is full")
+
+	b.tokens = 0.5
+	b, allowed = b.take(now, rate, 5, true)
+	assert.False(t, allowed)
+	assert.Equal(t, 0.5, b.tokens)
+
+	b, allowed = b.take(now.Add(time.Second), rate, 5, true)
+	assert.True(t, allowed)
+	assert.Equal(t, 1.5, b.tokens, "the bucket is refilled with the rate")
+
+	b, _ = b.take(now.Add(time.Hour), rate, 5, false)
+	assert.Equal(t, float64(5), b.tokens, "the bucket is refilled up to the capacity")
+	assert.Equal(t, now.Add(time.Hour), b.full(rate, 5))
+
+	b.tokens = 3
+	assert.Equal(t, now.Add(time.Hour+time.Second), b.full(rate, 5))
+}
+
+func TestMemoryBucketStore(t *testing.T) {
+	rate, err := limiter.NewRateFromFormatted("1-M")
+	require.NoError(t, err)
+	store := newMemoryBucketStore(3)
+
+	lctx, err := store.Peek(context.Background(), "client", rate)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), lctx.Limit)
+	assert.Equal(t, int64(3), lctx.Remaining)
+
+	for i := 2; i >= 0; i-- {
+		lctx, err = store.Get(context.Background(), "client", rate)
+		require.NoError(t, err)
+		assert.False(t, lctx.Reached)
+		assert.Equal(t, int64(i), lctx.Remaining)
+	}
+
+	lctx, err = store.Get(context.Background(), "client", rate)
+	require.NoError(t, err)
+	assert.True(t, lctx.Reached, "the burst is used up")
+	assert.True(t, lctx.Reset > unixMilli(time.Now().Add(2*time.Minute)))
+
+	lctx, err = store.Get(context.Background(), "other", rate)
+	require.NoError(t, err)
+	assert.False(t, lctx.Reached)
+
+	store.cleaned = time.Now().Add(-bucketCleanupInterval)
+	store.buckets["other"] = memoryBucket{full: time.Now().Add(-time.Second)}
+	_, err = store.Peek(context.Background(), "client", rate)
+	require.NoError(t, err)
+	assert.Len(t, store.buckets, 1, "the full buckets are removed")
+}
+
+func TestMemoryBucketStoreDefaultBurst(t *testing.T) {
+	rate, err := limiter.NewRateFromFormatted("2-H")
+	require.NoError(t, err)
+	store := newMemoryBucketStore(0)
+
+	lctx, err := store.Get(context.Background(), "client", rate)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), lctx.Limit)
+	assert.Equal(t, int64(1), lctx.Remaining)
+}
+
+func TestRateLimitTokenBucketHeaders(t *testing.T) {
+	store := newMemoryBucketStore(2)
+	rate, _ := limiter.NewRateFromFormatted("1-M")
+	l := NewRateLimit(store, limiter.New(store, rate))
+
+	w := serveRateLimit(l, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Remaining"))
+
+	assert.Equal(t, http.StatusOK, serveRateLimit(l, "").Code)
+	w = serveRateLimit(l, "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+}
+
+func TestMemcachedBucketStore(t *testing.T) {
+	server := newMemcachedServer(t)
+	defer server.listener.Close()
+
+	store, err := newMemcachedBucketStore(memcachedConfig{Servers: []string{server.addr()}}, 2)
+	require.NoError(t, err)
+
+	rate, err := limiter.NewRateFromFormatted("1-M")
+	require.NoError(t, err)
+
+	lctx, err := store.Peek(context.Background(), "client", rate)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), lctx.Remaining)
+
+	for i := 1; i >= 0; i-- {
+		lctx, err = store.Get(context.Background(), "client", rate)
+		require.NoError(t, err)
+		assert.False(t, lctx.Reached)
+		assert.Equal(t, int64(i), lctx.Remaining)
+	}
+
+	lctx, err = store.Get(context.Background(), "client", rate)
+	require.NoError(t, err)
+	assert.True(t, lctx.Reached)
+
+	lctx, err = store.Peek(context.Background(), "client", rate)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), lctx.Remaining)
+	assert.Contains(t, server.values, "limiter:bucket:client")
+}
+
+func TestParseBucketResult(t *testing.T) {
+	b, allowed, err := parseBucketResult([]interface{}{int64(1), "2.5", int64(1500)})
+	require.NoError(t, err)
+	assert.True(t, allowed)
+	assert.Equal(t, 2.5, b.tokens)
+	assert.Equal(t, time.Unix(1, 500*int64(time.Millisecond)), b.updated)
+
+	_, _, err = parseBucketResult([]interface{}{int64(1), "2.5"})
+	assert.Error(t, err)
+	_, _, err = parseBucketResult([]interface{}{int64(1), "tokens", int64(1500)})
+	assert.Error(t, err)
+}
@@ -0,0 +1,102 @@
+package rate
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ulule/limiter"
+)
+
+// windowCleanupInterval is how often the expired windows are removed from the memory store
+const windowCleanupInterval = time.Minute
+
+// unixMilli returns the Unix time of t in milliseconds, the unit of the reset of the limiter contexts
+func unixMilli(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// windowContext returns the limiter context of a window counting the requests until its expiration,
+// the reset is the expiration of the window tracked by the store
+func windowContext(rate limiter.Rate, expiration time.Time, count int64) limiter.Context {
+	lctx := limiter.Context{Limit: rate.Limit, Reset: unixMilli(expiration)}
+	if count <= rate.Limit {
+		lctx.Remaining = rate.Limit - count
+	} else {
+		lctx.Reached = true
+	}
+
+	return lctx
+}
+
+// setRetryAfter sets the Retry-After header of the request over the limit, the seconds left until the
+// reset are rounded up so the clients do not retry before it
+func setRetryAfter(w http.ResponseWriter, reset int64) {
+	left := time.Duration(reset-unixMilli(time.Now())) * time.Millisecond
+	if left < 0 {
+		left = 0
+	}
+
+	w.Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(left.Seconds())), 10))
+}
+
+// memoryWindowStore keeps the windows in-memory on the node, a window starts with the first request
+// of its key and expires after the period of the rate
+type memoryWindowStore struct {
+	sync.Mutex
+	windows map[string]window
+	cleaned time.Time
+}
+
+type window struct {
+	count      int64
+	expiration time.Time
+}
+
+func newMemoryWindowStore() *memoryWindowStore {
+	return &memoryWindowStore{windows: make(map[string]window), cleaned: time.Now()}
+}
+
+// Get increments the counter of the window of the given identifier.
+func (s *memoryWindowStore) Get(ctx context.Context, key string, rate limiter.Rate) (limiter.Context, error) {
+	return s.do(key, rate, true), nil
+}
+
+// Peek returns the counter of the window of the given identifier, without incrementing it.
+func (s *memoryWindowStore) Peek(ctx context.Context, key string, rate limiter.Rate) (limiter.Context, error) {
+	return s.do(key, rate, false), nil
+}
+
+func (s *memoryWindowStore) do(key string, rate limiter.Rate, increment bool) limiter.Context {
+	s.Lock()
+	defer s.Unlock()
+
+	now := time.Now()
+	w, ok := s.windows[key]
+	if !ok || !now.Before(w.expiration) {
+		w = window{expiration: now.Add(rate.Period)}
+	}
+	if increment {
+		w.count++
+		s.windows[key] = w
+	}
+
+	if now.Sub(s.cleaned) >= windowCleanupInterval {
+		s.cleanup(now)
+	}
+
+	return windowContext(rate, w.expiration, w.count)
+}
+
+// cleanup removes the expired windows, they are the same as the missing ones
+func (s *memoryWindowStore) cleanup(now time.Time) {
+	for key, w := range s.windows {
+		if !now.Before(w.expiration) {
+			delete(s.windows, key)
+		}
+	}
+	s.cleaned = now
+}
@@ -0,0 +1,118 @@
+package rate
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulule/limiter"
+)
+
+func TestMemoryWindowStore(t *testing.T) {
+	store := newMemoryWindowStore()
+	rate := limiter.Rate{Period: 1500 * time.Millisecond, Limit: 2}
+
+	before := time.Now()
+	lctx, err := store.Get(context.Background(), "client", rate)
+	require.NoError(t, err)
+	after := time.Now()
+
+	assert.Equal(t, int64(1), lctx.Remaining)
+	assert.True(t, lctx.Reset >= unixMilli(before.Add(rate.Period)) && lctx.Reset <= unixMilli(after.Add(rate.Period)),
+		"the reset is the expiry of the window started by the first request")
+
+	expiry := store.windows["client"].expiration
+	for i := 0; i < 2; i++ {
+		lctx, err = store.Get(context.Background(), "client", rate)
+		require.NoError(t, err)
+		assert.Equal(t, unixMilli(expiry), lctx.Reset, "the requests of the window share its reset")
+	}
+	assert.True(t, lctx.Reached)
+
+	lctx, err = store.Peek(context.Background(), "client", rate)
+	require.NoError(t, err)
+	assert.Equal(t, unixMilli(expiry), lctx.Reset)
+
+	store.windows["client"] = window{count: 3, expiration: time.Now().Add(-time.Millisecond)}
+	lctx, err = store.Get(context.Background(), "client", rate)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), lctx.Remaining, "the expired window is replaced by a new one")
+	assert.Equal(t, unixMilli(store.windows["client"].expiration), lctx.Reset)
+}
+
+func TestRedisWindowStore(t *testing.T) {
+	storage := &respStorage{values: make(map[string]int64), expires: make(map[string]time.Time)}
+	master := newFakeMaster(t, storage)
+	defer master.Close()
+
+	client, err := newRedisClient(redisConfig{DSN: "redis://" + master.listener.Addr().String()})
+	require.NoError(t, err)
+	defer client.Close()
+
+	store, err := newRedisWindowStore(client, "test")
+	require.NoError(t, err)
+	rate := limiter.Rate{Period: 1500 * time.Millisecond, Limit: 2}
+
+	lctx, err := store.Peek(context.Background(), "client", rate)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), lctx.Remaining)
+
+	for i := 1; i <= 3; i++ {
+		lctx, err = store.Get(context.Background(), "client", rate)
+		require.NoError(t, err)
+	}
+	assert.True(t, lctx.Reached)
+	assert.Equal(t, int64(3), storage.values["test:client"])
+
+	expiry := unixMilli(storage.expires["test:client"])
+	assert.InDelta(t, expiry, lctx.Reset, 5, "the reset is the expiry of the counter in the store")
+
+	lctx, err = store.Peek(context.Background(), "client", rate)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), lctx.Remaining)
+	assert.InDelta(t, expiry, lctx.Reset, 5)
+}
+
+func TestRateLimitResetHeaders(t *testing.T) {
+	store := newMemoryWindowStore()
+	rate := limiter.Rate{Period: 1500 * time.Millisecond, Limit: 1}
+	l := NewRateLimit(store, limiter.New(store, rate))
+
+	w := serveRateLimit(l, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Retry-After"))
+
+	require.Len(t, store.windows, 1)
+	var expiry int64
+	for _, w := range store.windows {
+		expiry = unixMilli(w.expiration)
+	}
+	assert.Equal(t, strconv.FormatInt(expiry, 10), w.Header().Get("X-RateLimit-Reset"), "the reset is the window expiry in milliseconds")
+
+	w = serveRateLimit(l, "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, strconv.FormatInt(expiry, 10), w.Header().Get("X-RateLimit-Reset"))
+	assert.Equal(t, "2", w.Header().Get("Retry-After"), "the seconds left are rounded up")
+}
+
+func TestSetRetryAfter(t *testing.T) {
+	for left, expected := range map[time.Duration]string{
+		-time.Second:           "0",
+		100 * time.Millisecond: "1",
+		time.Minute:            "60",
+	} {
+		w := httptest.NewRecorder()
+		setRetryAfter(w, unixMilli(time.Now().Add(left)))
+		assert.Equal(t, expected, w.Header().Get("Retry-After"), left.String())
+	}
+
+	w := httptest.NewRecorder()
+	onLimitReached(w, httptest.NewRequest(http.MethodGet, "/", nil), unixMilli(time.Now().Add(time.Minute)))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+}
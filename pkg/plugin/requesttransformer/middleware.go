@@ -3,6 +3,8 @@ package requesttransformer
 import (
 	"net/http"
 	"net/url"
+
+	"github.com/hellofresh/janus/pkg/router"
 )
 
 type headerFn func(headerName string, headerValue string)
@@ -27,17 +29,17 @@ func NewRequestTransformer(config Config) func(http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			query := r.URL.Query()
 
-			transform(config.Remove.Headers, removeHeaders(r))
-			transform(config.Remove.QueryString, removeQueryString(query))
+			transform(r, config.Remove.Headers, removeHeaders(r))
+			transform(r, config.Remove.QueryString, removeQueryString(query))
 
-			transform(config.Replace.Headers, replaceHeaders(r))
-			transform(config.Replace.QueryString, replaceQueryString(query))
+			transform(r, config.Replace.Headers, replaceHeaders(r))
+			transform(r, config.Replace.QueryString, replaceQueryString(query))
 
-			transform(config.Add.Headers, addHeaders(r))
-			transform(config.Add.QueryString, addQueryString(query))
+			transform(r, config.Add.Headers, addHeaders(r))
+			transform(r, config.Add.QueryString, addQueryString(query))
 
-			transform(config.Append.Headers, appendHeaders(r))
-			transform(config.Append.QueryString, appendQueryString(query))
+			transform(r, config.Append.Headers, appendHeaders(r))
+			transform(r, config.Append.QueryString, appendQueryString(query))
 
 			r.URL.RawQuery = query.Encode()
 
@@ -78,13 +80,15 @@ func replaceHeaders(r *http.Request) headerFn {
 	}
 }
 
-func transform(values map[string]string, fn headerFn) {
+// transform applies the function to all the given values, {param} placeholders in the values
+// are replaced with the request path parameters
+func transform(r *http.Request, values map[string]string, fn headerFn) {
 	if len(values) <= 0 {
 		return
 	}
 
 	for name, value := range values {
-		fn(name, value)
+		fn(name, router.ExpandURLParams(r, value))
 	}
 }
 
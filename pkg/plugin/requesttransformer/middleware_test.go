@@ -5,6 +5,7 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/hellofresh/janus/pkg/router"
 	"github.com/hellofresh/janus/pkg/test"
 	"github.com/stretchr/testify/assert"
 )
@@ -252,3 +253,27 @@ func TestRemoveQueryStringthatDoesntExists(t *testing.T) {
 	NewRequestTransformer(config)(http.HandlerFunc(test.Ping)).ServeHTTP(w, req)
 	assert.Equal(t, "", req.URL.Query().Get("test"))
 }
+
+func TestAddHeaderWithPathParameter(t *testing.T) {
+	config := Config{
+		Add: Options{
+			Headers: map[string]string{
+				"X-User-ID": "{userId}",
+			},
+			QueryString: map[string]string{
+				"order": "{orderId}",
+			},
+		},
+	}
+
+	r := router.NewChiRouter()
+	r.GET("/users/{userId}/orders/{orderId}", NewRequestTransformer(config)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "42", req.Header.Get("X-User-ID"))
+		assert.Equal(t, "7", req.URL.Query().Get("order"))
+		w.WriteHeader(http.StatusNoContent)
+	})).ServeHTTP)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/42/orders/7", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
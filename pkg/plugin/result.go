@@ -0,0 +1,78 @@
+package plugin
+
+import (
+	"net/http"
+
+	httpErrors "github.com/hellofresh/janus/pkg/errors"
+	"github.com/hellofresh/janus/pkg/middleware"
+)
+
+// Result is the response a plugin answers a request with instead of passing it on to the next handlers,
+// e.g. an authentication failure or a stored response. The chain still logs, measures and traces the
+// short-circuited requests as the proxied ones.
+type Result struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	// Err answers the request instead of the status code and the body when it is set, it is rendered as
+	// the other errors of Janus, with the problem details or the error templates when they are enabled
+	Err error
+	// Reason tells why the plugin answered the request, e.g. replayed or unauthorized, it is written in
+	// the short_circuit field of the access log and tagged on the span of the plugin
+	Reason string
+}
+
+// ErrorResult returns the result answering the request with the error
+func ErrorResult(err error, reason string) *Result {
+	return &Result{Err: err, Reason: reason}
+}
+
+// ShortCircuitFunc handles a request for a plugin, it returns the result answering the request to stop
+// the chain, or no result to pass the returned request on to the next handlers
+type ShortCircuitFunc func(r *http.Request) (*http.Request, *Result)
+
+// ShortCircuit returns the middleware of the plugin answering the requests with the results of the
+// function
+func ShortCircuit(fn ShortCircuitFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req, result := fn(r)
+			if result != nil {
+				Respond(w, r, result)
+				return
+			}
+
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+// Respond answers the request with the result, for the plugins wrapping the response writer of the
+// requests they pass on. The headers already set for the request, e.g. its request ID, are kept.
+func Respond(w http.ResponseWriter, r *http.Request, result *Result) {
+	reason := result.Reason
+	if reason == "" {
+		reason = "plugin"
+	}
+	middleware.SetAccessLogShortCircuit(r.Context(), reason)
+	middleware.SetPluginShortCircuit(r.Context(), reason)
+
+	header := w.Header()
+	for name, values := range result.Header {
+		if _, ok := header[name]; !ok {
+			header[name] = values
+		}
+	}
+
+	if result.Err != nil {
+		httpErrors.Handler(w, result.Err)
+		return
+	}
+
+	statusCode := result.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	w.WriteHeader(statusCode)
+	w.Write(result.Body)
+}
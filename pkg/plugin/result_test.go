@@ -0,0 +1,53 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	httpErrors "github.com/hellofresh/janus/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type testKey struct{}
+
+func TestShortCircuit(t *testing.T) {
+	var passed *http.Request
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		passed = r
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	handler := ShortCircuit(func(r *http.Request) (*http.Request, *Result) {
+		switch r.URL.Path {
+		case "/stored":
+			return r, &Result{StatusCode: http.StatusCreated, Header: http.Header{"X-Stored": {"true"}, "X-Request-Id": {"stored"}}, Body: []byte("stored"), Reason: "replayed"}
+		case "/denied":
+			return r, ErrorResult(httpErrors.New(http.StatusUnauthorized, "denied"), "unauthorized")
+		}
+		return r.WithContext(context.WithValue(r.Context(), testKey{}, "value")), nil
+	})(next)
+
+	w := httptest.NewRecorder()
+	w.Header().Set("X-Request-Id", "request")
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stored", nil))
+	assert.Nil(t, passed, "the short-circuited requests are not passed on")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "stored", w.Body.String())
+	assert.Equal(t, "true", w.Header().Get("X-Stored"))
+	assert.Equal(t, "request", w.Header().Get("X-Request-Id"), "the headers already set are kept")
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/denied", nil))
+	assert.Nil(t, passed)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "denied")
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	if assert.NotNil(t, passed) {
+		assert.Equal(t, "value", passed.Context().Value(testKey{}), "the returned request is passed on")
+	}
+}
@@ -0,0 +1,148 @@
+package retry
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// DefaultMaxResponseBodySize is the size of the largest response body buffered to check the response
+// body condition
+const DefaultMaxResponseBodySize = "1MB"
+
+// idempotentMethods are the methods of the requests retried on their response body, the upstream may
+// have processed the request whose response is an error envelope
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+	http.MethodTrace:   true,
+}
+
+// BodyCondition is a condition on the JSON body of the upstream responses, it matches the responses
+// whose field is equal to the value, e.g. the error envelopes some upstreams send with a 200 status
+type BodyCondition struct {
+	// Field is the path of the field in the body, the names of the nested objects are separated by dots
+	Field string      `json:"field"`
+	Value interface{} `json:"value"`
+}
+
+// matches tells if the field of the JSON body is equal to the value, the bodies which are not JSON
+// objects do not match
+func (c *BodyCondition) matches(body []byte) bool {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return false
+	}
+
+	for _, name := range strings.Split(c.Field, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if value, ok = object[name]; !ok {
+			return false
+		}
+	}
+
+	// the values are compared encoded, so the numbers decoded to different types are equal
+	actual, err := json.Marshal(value)
+	if err != nil {
+		return false
+	}
+	expected, err := json.Marshal(c.Value)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(actual, expected)
+}
+
+// bufferedResponse holds the response of an attempt until it is known whether the request is retried.
+// The responses larger than the max size are sent to the client as they are written, and can not be
+// retried anymore.
+type bufferedResponse struct {
+	w        http.ResponseWriter
+	header   http.Header
+	code     int
+	body     bytes.Buffer
+	maxSize  int64
+	streamed bool
+}
+
+func newBufferedResponse(w http.ResponseWriter, maxSize int64) *bufferedResponse {
+	header := make(http.Header, len(w.Header()))
+	for name, values := range w.Header() {
+		header[name] = append([]string(nil), values...)
+	}
+
+	return &bufferedResponse{w: w, header: header, maxSize: maxSize}
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	if b.streamed {
+		return b.w.Header()
+	}
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(code int) {
+	if b.code != 0 {
+		return
+	}
+	b.code = code
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+	if !b.streamed && int64(b.body.Len()+len(p)) > b.maxSize {
+		b.send()
+	}
+	if b.streamed {
+		return b.w.Write(p)
+	}
+
+	return b.body.Write(p)
+}
+
+// Flush flushes the streamed responses, the buffered ones are sent once the attempt is done
+func (b *bufferedResponse) Flush() {
+	if f, ok := b.w.(http.Flusher); ok && b.streamed {
+		f.Flush()
+	}
+}
+
+// matches tells if the buffered body matches the condition, the encoded bodies are not decoded
+func (b *bufferedResponse) matches(condition *BodyCondition) bool {
+	if b.streamed || b.header.Get("Content-Encoding") != "" {
+		return false
+	}
+
+	return condition.matches(b.body.Bytes())
+}
+
+// send writes the buffered response to the client
+func (b *bufferedResponse) send() {
+	if b.streamed {
+		return
+	}
+	b.streamed = true
+
+	header := b.w.Header()
+	for name := range header {
+		delete(header, name)
+	}
+	for name, values := range b.header {
+		header[name] = values
+	}
+
+	if b.code == 0 {
+		b.code = http.StatusOK
+	}
+	b.w.WriteHeader(b.code)
+	b.w.Write(b.body.Bytes())
+	b.body.Reset()
+}
@@ -0,0 +1,124 @@
+package retry
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBudgetWindow is the sliding window the retries are budgeted over
+	DefaultBudgetWindow = 10 * time.Second
+	// DefaultBudgetMinRetries is the number of retries allowed per window regardless of the traffic,
+	// so the APIs with a low traffic can still retry
+	DefaultBudgetMinRetries = 10
+
+	budgetBuckets = 10
+)
+
+// BudgetConfig represents the retry budget configuration
+type BudgetConfig struct {
+	// Percent is the share of the requests that can be retried, the budget is disabled when it is zero
+	Percent    float64  `json:"percent"`
+	MinRetries int      `json:"min_retries"`
+	Window     Duration `json:"window"`
+}
+
+type budgetBucket struct {
+	start    time.Time
+	requests int64
+	retries  int64
+}
+
+// Budget limits the retries to a share of the requests over a sliding window. Each request deposits
+// a share of a token and each retry withdraws a token, so the retries are throttled when a broad
+// upstream failure makes every request retry.
+type Budget struct {
+	mu         sync.Mutex
+	percent    float64
+	minRetries int64
+	bucketSize time.Duration
+	buckets    [budgetBuckets]budgetBucket
+
+	now func() time.Time
+}
+
+// NewBudget creates a new instance of Budget
+func NewBudget(config BudgetConfig) *Budget {
+	window := time.Duration(config.Window)
+	if window <= 0 {
+		window = DefaultBudgetWindow
+	}
+
+	return &Budget{
+		percent:    config.Percent,
+		minRetries: int64(config.MinRetries),
+		bucketSize: window / budgetBuckets,
+		now:        time.Now,
+	}
+}
+
+// Deposit counts a request
+func (b *Budget) Deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.current().requests++
+}
+
+// Withdraw takes a token for a retry, it returns false when the budget is exhausted
+func (b *Budget) Withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	current := b.current()
+	requests, retries := b.sum()
+	if float64(retries+1) > b.allowed(requests) {
+		return false
+	}
+
+	current.retries++
+	return true
+}
+
+// Utilization returns the share of the budget used by the retries in the window
+func (b *Budget) Utilization() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.current()
+	requests, retries := b.sum()
+	allowed := b.allowed(requests)
+	if allowed <= 0 {
+		return 1
+	}
+
+	return float64(retries) / allowed
+}
+
+func (b *Budget) allowed(requests int64) float64 {
+	return float64(b.minRetries) + float64(requests)*b.percent/100
+}
+
+// current returns the bucket of the current time, the expired buckets are reset
+func (b *Budget) current() *budgetBucket {
+	start := b.now().Truncate(b.bucketSize)
+	bucket := &b.buckets[(start.UnixNano()/int64(b.bucketSize))%budgetBuckets]
+	if !bucket.start.Equal(start) {
+		*bucket = budgetBucket{start: start}
+	}
+
+	return bucket
+}
+
+// sum returns the requests and the retries of the buckets in the window
+func (b *Budget) sum() (requests int64, retries int64) {
+	oldest := b.now().Add(-b.bucketSize * budgetBuckets)
+	for _, bucket := range b.buckets {
+		if bucket.start.After(oldest) {
+			requests += bucket.requests
+			retries += bucket.retries
+		}
+	}
+
+	return requests, retries
+}
@@ -0,0 +1,39 @@
+package retry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBudget(t *testing.T) {
+	now := time.Date(2018, time.February, 28, 12, 0, 0, 0, time.UTC)
+	b := NewBudget(BudgetConfig{Percent: 20, MinRetries: 1, Window: Duration(10 * time.Second)})
+	b.now = func() time.Time { return now }
+
+	for i := 0; i < 10; i++ {
+		b.Deposit()
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, b.Withdraw(), "the minimum and 20%% of the requests can be retried")
+	}
+	assert.False(t, b.Withdraw())
+	assert.Equal(t, float64(1), b.Utilization())
+
+	now = now.Add(5 * time.Second)
+	assert.False(t, b.Withdraw(), "the window slides over the previous requests and retries")
+
+	now = now.Add(5 * time.Second)
+	assert.True(t, b.Withdraw(), "the expired retries give their tokens back")
+	assert.Equal(t, float64(1), b.Utilization(), "only the minimum is left without requests")
+}
+
+func TestBudgetMinRetries(t *testing.T) {
+	b := NewBudget(BudgetConfig{Percent: 10})
+	assert.False(t, b.Withdraw(), "no retries are allowed without requests nor minimum")
+
+	b.Deposit()
+	assert.False(t, b.Withdraw(), "a share of a token is not enough for a retry")
+}
@@ -4,22 +4,57 @@ import (
 	"net/http"
 	"time"
 
+	"code.cloudfoundry.org/bytefmt"
 	"github.com/Knetic/govaluate"
 	"github.com/felixge/httpsnoop"
 	janusErr "github.com/hellofresh/janus/pkg/errors"
 	"github.com/hellofresh/janus/pkg/metrics"
+	obs "github.com/hellofresh/janus/pkg/observability"
+	"github.com/hellofresh/janus/pkg/proxy"
 	"github.com/pkg/errors"
 	"github.com/rafaeljesus/retry-go"
 	log "github.com/sirupsen/logrus"
+	"go.opencensus.io/stats"
 )
 
+// ErrBudgetExhausted is used when a failed request is not retried because of the retry budget
+var ErrBudgetExhausted = errors.New("retry budget exhausted")
+
+// ErrInvalidBody is used when the request body can not be read to be buffered for the retries
+var ErrInvalidBody = janusErr.New(http.StatusBadRequest, "could not read the request body")
+
 const (
 	defaultPredicate = "statusCode == 0 || statusCode >= 500"
 	proxySection     = "proxy"
 )
 
-// NewRetryMiddleware creates a new retry middleware
+// NewRetryMiddleware creates a new retry middleware, the retries of the requests it serves share the
+// retry budget when one is configured. The request bodies are buffered to be sent again, the bodies
+// larger than the max body size are streamed to the upstream and the request is not retried. When the
+// response body condition is set, the responses of the idempotent requests are buffered up to the max
+// response body size to check it, and sent to the client once they are not retried.
 func NewRetryMiddleware(cfg Config) func(http.Handler) http.Handler {
+	var budget *Budget
+	if cfg.Budget.Percent > 0 {
+		budget = NewBudget(cfg.Budget)
+	}
+
+	if cfg.MaxBodySize == "" {
+		cfg.MaxBodySize = DefaultMaxBodySize
+	}
+	maxBodySize, err := bytefmt.ToBytes(cfg.MaxBodySize)
+	if err != nil {
+		log.WithError(err).WithField("max_body_size", cfg.MaxBodySize).Error("invalid retry max body size")
+	}
+
+	if cfg.MaxResponseBodySize == "" {
+		cfg.MaxResponseBodySize = DefaultMaxResponseBodySize
+	}
+	maxResponseBodySize, err := bytefmt.ToBytes(cfg.MaxResponseBodySize)
+	if err != nil {
+		log.WithError(err).WithField("max_response_body_size", cfg.MaxResponseBodySize).Error("invalid retry max response body size")
+	}
+
 	return func(handler http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			log.WithFields(log.Fields{
@@ -38,8 +73,44 @@ func NewRetryMiddleware(cfg Config) func(http.Handler) http.Handler {
 				return
 			}
 
+			buffered, err := proxy.BufferBody(r, int64(maxBodySize))
+			if err != nil {
+				janusErr.Handler(w, ErrInvalidBody)
+				return
+			}
+			if !buffered {
+				log.WithField("max_body_size", cfg.MaxBodySize).Debug("The request body is too large to be retried")
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			if budget != nil {
+				budget.Deposit()
+				defer func() { stats.Record(r.Context(), obs.MRetryBudgetUtilization.M(budget.Utilization())) }()
+			}
+
+			bodyChecked := cfg.ResponseBody != nil && idempotentMethods[r.Method]
+			attempt := 0
+			exhausted := false
 			if err := retry.Do(func() error {
-				m := httpsnoop.CaptureMetrics(handler, w, r)
+				attempt++
+				if attempt > 1 {
+					if err := proxy.RewindBody(r); err != nil {
+						return err
+					}
+				}
+
+				rw := w
+				var response *bufferedResponse
+				if bodyChecked {
+					response = newBufferedResponse(w, int64(maxResponseBodySize))
+					rw = response
+				}
+				m := httpsnoop.CaptureMetrics(handler, rw, r)
+				// the responses larger than the max response body size are already sent
+				if response != nil && response.streamed {
+					return nil
+				}
 
 				params := make(map[string]interface{}, 8)
 				params["statusCode"] = m.Code
@@ -50,12 +121,27 @@ func NewRetryMiddleware(cfg Config) func(http.Handler) http.Handler {
 					return errors.New("cannot evaluate the expression")
 				}
 
-				if result.(bool) {
-					return errors.Errorf("%s %s request failed", r.Method, r.URL)
+				if !result.(bool) && (response == nil || !response.matches(cfg.ResponseBody)) {
+					if response != nil {
+						response.send()
+					}
+					return nil
+				}
+
+				// the request fails fast when the budget is exhausted, returning nil stops the retries
+				// without waiting for the backoff
+				if budget != nil && attempt < cfg.Attempts && !budget.Withdraw() {
+					exhausted = true
+					return nil
+				}
+
+				return errors.Errorf("%s %s request failed", r.Method, r.URL)
+			}, cfg.Attempts, time.Duration(cfg.Backoff)); err != nil || exhausted {
+				if exhausted {
+					stats.Record(r.Context(), obs.MRetriesDropped.M(1))
+					err = ErrBudgetExhausted
 				}
 
-				return nil
-			}, cfg.Attempts, time.Duration(cfg.Backoff)); err != nil {
 				statsClient := metrics.WithContext(r.Context())
 				statsClient.SetHTTPRequestSection(proxySection).TrackRequest(r, nil, false).ResetHTTPRequestSection()
 				janusErr.Handler(w, errors.Wrap(err, "request failed too many times"))
@@ -1,8 +1,11 @@
 package retry
 
 import (
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -66,3 +69,105 @@ func testFailedUpstreamRetry(t *testing.T, r *http.Request, w *httptest.Response
 
 	assert.Equal(t, http.StatusBadGateway, w.Code)
 }
+
+func TestMiddlewareRetryBudget(t *testing.T) {
+	attempts := 0
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	mw := NewRetryMiddleware(Config{Attempts: 3, Budget: BudgetConfig{Percent: 50, MinRetries: 1, Window: Duration(time.Minute)}})
+	handler := mw(upstream)
+
+	// the budget allows one retry plus half of the requests
+	for _, expected := range []int{2, 2, 1, 2} {
+		attempts = 0
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assert.Equal(t, http.StatusBadGateway, w.Code)
+		assert.Equal(t, expected, attempts)
+	}
+}
+
+func TestMiddlewareRetryBody(t *testing.T) {
+	var bodies []string
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	handler := NewRetryMiddleware(Config{Attempts: 2, MaxBodySize: "5B"})(upstream)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello")))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Equal(t, []string{"hello", "hello"}, bodies, "the body is sent again")
+
+	bodies = nil
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello world")))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Equal(t, []string{"hello world"}, bodies, "the larger bodies are streamed once")
+}
+
+func TestMiddlewareRetryResponseBody(t *testing.T) {
+	var calls int
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("X-Call", strconv.Itoa(calls))
+		if calls == 1 || r.URL.Path == "/unavailable" {
+			w.Write([]byte(`{"error":"temporarily_unavailable"}`))
+			return
+		}
+		if r.URL.Path == "/large" {
+			w.Write([]byte(`{"error":"temporarily_unavailable","padding":"` + strings.Repeat("a", 64) + `"}`))
+			return
+		}
+		w.Write([]byte(`{"data":"ok"}`))
+	})
+	handler := NewRetryMiddleware(Config{
+		Attempts:            3,
+		ResponseBody:        &BodyCondition{Field: "error", Value: "temporarily_unavailable"},
+		MaxResponseBodySize: "64B",
+	})(upstream)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, 2, calls, "the matching response is retried")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"data":"ok"}`, w.Body.String(), "only the response not retried is sent")
+	assert.Equal(t, "2", w.Header().Get("X-Call"))
+
+	calls = 0
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", nil))
+	assert.Equal(t, 1, calls, "the non idempotent requests are not retried on their response body")
+	assert.Equal(t, `{"error":"temporarily_unavailable"}`, w.Body.String())
+
+	calls = 1
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/large", nil))
+	assert.Equal(t, 2, calls, "the responses larger than the max response body size are not retried")
+	assert.Contains(t, w.Body.String(), strings.Repeat("a", 64), "the larger responses are sent as they are written")
+
+	calls = 1
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/unavailable", nil))
+	assert.Equal(t, 4, calls)
+	assert.Equal(t, http.StatusInternalServerError, w.Code, "the request fails once the attempts are exhausted")
+	assert.NotContains(t, w.Body.String(), "temporarily_unavailable")
+}
+
+func TestBodyCondition(t *testing.T) {
+	condition := &BodyCondition{Field: "error.code", Value: 503}
+
+	assert.True(t, condition.matches([]byte(`{"error":{"code":503}}`)))
+	assert.True(t, condition.matches([]byte(`{"error":{"code":503.0}}`)), "the numbers are compared by value")
+	assert.False(t, condition.matches([]byte(`{"error":{"code":"503"}}`)))
+	assert.False(t, condition.matches([]byte(`{"error":"unavailable"}`)))
+	assert.False(t, condition.matches([]byte(`[{"error":{"code":503}}]`)))
+	assert.False(t, condition.matches([]byte(`not json`)))
+}
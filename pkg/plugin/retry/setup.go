@@ -4,6 +4,7 @@ import (
 	"strconv"
 	"time"
 
+	"code.cloudfoundry.org/bytefmt"
 	"github.com/asaskevich/govalidator"
 	"github.com/hellofresh/janus/pkg/plugin"
 	"github.com/hellofresh/janus/pkg/proxy"
@@ -12,6 +13,9 @@ import (
 
 const (
 	strNull = "null"
+
+	// DefaultMaxBodySize is the size of the largest request body buffered to be retried
+	DefaultMaxBodySize = "1MB"
 )
 
 type (
@@ -20,6 +24,18 @@ type (
 		Attempts  int      `json:"attempts"`
 		Backoff   Duration `json:"backoff"`
 		Predicate string   `json:"predicate"`
+		// Budget limits the retries to a share of the requests, so an upstream failing broadly is not
+		// flooded with retries
+		Budget BudgetConfig `json:"budget"`
+		// MaxBodySize is the size of the largest request body buffered to be sent again, the requests
+		// with a larger body are not retried
+		MaxBodySize string `json:"max_body_size"`
+		// ResponseBody retries the idempotent requests whose response has a JSON body matching the
+		// condition, in addition to the predicate. The responses are buffered to check their body.
+		ResponseBody *BodyCondition `json:"response_body"`
+		// MaxResponseBodySize is the size of the largest response body buffered to check the response body
+		// condition, the larger responses are sent as they are written and not retried
+		MaxResponseBodySize string `json:"max_response_body_size"`
 	}
 
 	// Duration is a wrapper for time.Duration so we can use human readable configs
@@ -63,8 +79,7 @@ func init() {
 }
 
 func setupRetry(def *proxy.RouterDefinition, rawConfig plugin.Config) error {
-	var config Config
-	err := plugin.Decode(rawConfig, &config)
+	config, err := decodeConfig(rawConfig)
 	if err != nil {
 		return err
 	}
@@ -74,11 +89,45 @@ func setupRetry(def *proxy.RouterDefinition, rawConfig plugin.Config) error {
 }
 
 func validateConfig(rawConfig plugin.Config) (bool, error) {
-	var config Config
-	err := plugin.Decode(rawConfig, &config)
+	config, err := decodeConfig(rawConfig)
 	if err != nil {
 		return false, err
 	}
 
 	return govalidator.ValidateStruct(config)
 }
+
+func decodeConfig(rawConfig plugin.Config) (Config, error) {
+	config := Config{
+		Budget: BudgetConfig{
+			MinRetries: DefaultBudgetMinRetries,
+			Window:     Duration(DefaultBudgetWindow),
+		},
+		MaxBodySize:         DefaultMaxBodySize,
+		MaxResponseBodySize: DefaultMaxResponseBodySize,
+	}
+	if err := plugin.Decode(rawConfig, &config); err != nil {
+		return config, err
+	}
+
+	if config.Budget.Percent < 0 || config.Budget.Percent > 100 {
+		return config, errors.New("retry budget percent must be between 0 and 100")
+	}
+	if config.Budget.MinRetries < 0 {
+		return config, errors.New("retry budget min_retries can not be negative")
+	}
+	if time.Duration(config.Budget.Window) < time.Second {
+		return config, errors.New("retry budget window must be at least 1s")
+	}
+	if _, err := bytefmt.ToBytes(config.MaxBodySize); err != nil {
+		return config, errors.Wrap(err, "invalid retry max_body_size")
+	}
+	if config.ResponseBody != nil && config.ResponseBody.Field == "" {
+		return config, errors.New("retry response_body field is required")
+	}
+	if _, err := bytefmt.ToBytes(config.MaxResponseBodySize); err != nil {
+		return config, errors.Wrap(err, "invalid retry max_response_body_size")
+	}
+
+	return config, nil
+}
@@ -15,3 +15,23 @@ func TestSetup(t *testing.T) {
 
 	assert.Len(t, def.Middleware(), 1)
 }
+
+func TestValidateConfigInvalidMaxBodySize(t *testing.T) {
+	isValid, err := validateConfig(plugin.Config{"max_body_size": "large"})
+	assert.False(t, isValid)
+	assert.Error(t, err)
+}
+
+func TestValidateConfigResponseBody(t *testing.T) {
+	isValid, err := validateConfig(plugin.Config{"response_body": map[string]interface{}{"field": "error", "value": "temporarily_unavailable"}})
+	assert.True(t, isValid)
+	assert.NoError(t, err)
+
+	isValid, err = validateConfig(plugin.Config{"response_body": map[string]interface{}{"value": "temporarily_unavailable"}})
+	assert.False(t, isValid)
+	assert.Error(t, err, "the field is required")
+
+	isValid, err = validateConfig(plugin.Config{"max_response_body_size": "large"})
+	assert.False(t, isValid)
+	assert.Error(t, err)
+}
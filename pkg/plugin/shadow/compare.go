@@ -0,0 +1,142 @@
+package shadow
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Comparison results, the mirror errors when it can not be reached or does not respond in time
+const (
+	ResultMatch    = "match"
+	ResultMismatch = "mismatch"
+	ResultError    = "error"
+)
+
+// Diff is the structured difference of the primary and mirror responses of a request
+type Diff struct {
+	Time    time.Time   `json:"time"`
+	API     string      `json:"api,omitempty"`
+	Method  string      `json:"method"`
+	Path    string      `json:"path"`
+	Result  string      `json:"result"`
+	Status  StatusDiff  `json:"status"`
+	Latency LatencyDiff `json:"latency"`
+	// Fields are the selected JSON fields having different values
+	Fields []FieldDiff `json:"fields,omitempty"`
+	// Truncated tells a response body was larger than the max body size, its fields are not compared
+	Truncated bool   `json:"truncated,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// StatusDiff holds the status codes of the responses
+type StatusDiff struct {
+	Primary int `json:"primary"`
+	Mirror  int `json:"mirror"`
+}
+
+// LatencyDiff holds the latencies of the responses in milliseconds
+type LatencyDiff struct {
+	Primary float64 `json:"primary_ms"`
+	Mirror  float64 `json:"mirror_ms"`
+}
+
+// FieldDiff holds the values of a JSON field in the responses, a field missing in a response is null
+type FieldDiff struct {
+	Path    string      `json:"path"`
+	Primary interface{} `json:"primary"`
+	Mirror  interface{} `json:"mirror"`
+}
+
+// response is the part of a response kept for the comparison
+type response struct {
+	statusCode int
+	body       []byte
+	truncated  bool
+	latency    time.Duration
+	err        error
+}
+
+// compare diffs the responses, the fields are compared only when both bodies were read whole
+func compare(primary, mirror *response, fields []string) Diff {
+	diff := Diff{
+		Status:  StatusDiff{Primary: primary.statusCode, Mirror: mirror.statusCode},
+		Latency: LatencyDiff{Primary: milliseconds(primary.latency), Mirror: milliseconds(mirror.latency)},
+	}
+
+	if mirror.err != nil {
+		diff.Result = ResultError
+		diff.Error = mirror.err.Error()
+		return diff
+	}
+
+	diff.Result = ResultMatch
+	if primary.statusCode != mirror.statusCode {
+		diff.Result = ResultMismatch
+	}
+
+	if primary.truncated || mirror.truncated {
+		diff.Truncated = len(fields) > 0
+		return diff
+	}
+
+	if len(fields) > 0 {
+		primaryDoc, mirrorDoc := decodeJSON(primary.body), decodeJSON(mirror.body)
+		for _, field := range fields {
+			primaryValue, inPrimary := lookup(primaryDoc, field)
+			mirrorValue, inMirror := lookup(mirrorDoc, field)
+			if inPrimary == inMirror && reflect.DeepEqual(primaryValue, mirrorValue) {
+				continue
+			}
+
+			diff.Result = ResultMismatch
+			diff.Fields = append(diff.Fields, FieldDiff{Path: field, Primary: primaryValue, Mirror: mirrorValue})
+		}
+	}
+
+	return diff
+}
+
+// decodeJSON decodes the body keeping the numbers as they are written, it returns nil when it is not JSON
+func decodeJSON(body []byte) interface{} {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil
+	}
+
+	return doc
+}
+
+// lookup returns the value of the dot separated path, the array elements are selected by their index
+func lookup(doc interface{}, path string) (interface{}, bool) {
+	for _, part := range strings.Split(path, ".") {
+		switch v := doc.(type) {
+		case map[string]interface{}:
+			value, ok := v[part]
+			if !ok {
+				return nil, false
+			}
+			doc = value
+		case []interface{}:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			doc = v[i]
+		default:
+			return nil, false
+		}
+	}
+
+	return doc, true
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,45 @@
+package shadow
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompare(t *testing.T) {
+	fields := []string{"data.id", "data.items.1.name", "meta.total", "missing"}
+	primary := &response{statusCode: http.StatusOK, body: []byte(`{"data": {"id": 42, "items": [{"name": "a"}, {"name": "b"}]}, "meta": {"total": 2}}`)}
+
+	diff := compare(primary, &response{statusCode: http.StatusOK, body: []byte(`{"data": {"id": 42, "items": [{"name": "a"}, {"name": "b"}], "new": true}, "meta": {"total": 2}}`)}, fields)
+	assert.Equal(t, ResultMatch, diff.Result)
+	assert.Empty(t, diff.Fields)
+
+	diff = compare(primary, &response{statusCode: http.StatusOK, body: []byte(`{"data": {"id": 42.0, "items": [{"name": "a"}]}, "meta": {"total": 2}}`)}, fields)
+	assert.Equal(t, ResultMismatch, diff.Result)
+	assert.Equal(t, []FieldDiff{
+		{Path: "data.id", Primary: json.Number("42"), Mirror: json.Number("42.0")},
+		{Path: "data.items.1.name", Primary: "b", Mirror: nil},
+	}, diff.Fields)
+
+	diff = compare(primary, &response{statusCode: http.StatusInternalServerError, body: []byte(`not json`)}, nil)
+	assert.Equal(t, ResultMismatch, diff.Result, "the status codes are compared without the fields")
+	assert.Equal(t, StatusDiff{Primary: http.StatusOK, Mirror: http.StatusInternalServerError}, diff.Status)
+}
+
+func TestCompareTruncated(t *testing.T) {
+	primary := &response{statusCode: http.StatusOK, body: []byte(`{"id": 1}`)}
+
+	diff := compare(primary, &response{statusCode: http.StatusOK, truncated: true}, []string{"id"})
+	assert.Equal(t, ResultMatch, diff.Result, "only the status codes are compared")
+	assert.True(t, diff.Truncated)
+	assert.Empty(t, diff.Fields)
+}
+
+func TestCompareMirrorError(t *testing.T) {
+	diff := compare(&response{statusCode: http.StatusOK}, &response{err: errors.New("timeout")}, []string{"id"})
+	assert.Equal(t, ResultError, diff.Result)
+	assert.Equal(t, "timeout", diff.Error)
+}
@@ -0,0 +1,251 @@
+package shadow
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/bytefmt"
+	"github.com/hellofresh/janus/pkg/middleware"
+	obs "github.com/hellofresh/janus/pkg/observability"
+	"github.com/hellofresh/janus/pkg/proxy"
+	log "github.com/sirupsen/logrus"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// Header is set on the requests sent to the mirror
+const Header = "X-Shadow"
+
+// Shadow sends a copy of the requests to a mirror upstream, e.g. a new version of a service, and drops
+// its responses. The mirror is called alongside the primary upstream and never delays nor changes the
+// response sent to the client. In the compare mode the responses of a sample of the mirrored requests
+// are diffed once both are received, and the diffs are written to the sink.
+type Shadow struct {
+	target      *url.URL
+	client      *http.Client
+	timeout     time.Duration
+	sampleRate  float64
+	maxBodySize int64
+
+	compare            bool
+	compareRate        float64
+	compareMaxBodySize int64
+	fields             []string
+	sink               Sink
+
+	float64 func() float64
+}
+
+// NewShadow creates a new instance of Shadow
+func NewShadow(config Config) (*Shadow, error) {
+	target, err := url.Parse(config.Target)
+	if err != nil {
+		return nil, err
+	}
+	maxBodySize, err := bytefmt.ToBytes(config.MaxBodySize)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Shadow{
+		target: target,
+		client: &http.Client{
+			// the redirects are not followed, as the proxy does not follow the primary ones
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		timeout:     time.Duration(config.Timeout),
+		sampleRate:  sampleRate(config.SampleRate),
+		maxBodySize: int64(maxBodySize),
+		float64:     rand.Float64,
+	}
+
+	if config.Compare.Enabled {
+		compareMaxBodySize, err := bytefmt.ToBytes(config.Compare.MaxBodySize)
+		if err != nil {
+			return nil, err
+		}
+		if s.sink, err = NewSink(config.Compare); err != nil {
+			return nil, err
+		}
+
+		s.compare = true
+		s.compareRate = sampleRate(config.Compare.SampleRate)
+		s.compareMaxBodySize = int64(compareMaxBodySize)
+		s.fields = config.Compare.JSONFields
+	}
+
+	return s, nil
+}
+
+// Handler is the middleware function
+func (s *Shadow) Handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.float64() >= s.sampleRate {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		body, ok := s.copyBody(r)
+		if !ok {
+			middleware.ContextLogger(r.Context()).WithField("path", r.URL.Path).Debug("The request body is too large to be mirrored")
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		// the mirror outlives the request, its context keeps only the tags labeling the metrics
+		ctx := tag.NewContext(context.Background(), tag.FromContext(r.Context()))
+		ctx, cancel := context.WithTimeout(ctx, s.timeout)
+
+		// the request is copied before the primary one is served, the next middleware may change it
+		req, err := s.newRequest(ctx, r, body)
+		if err != nil {
+			cancel()
+			middleware.ContextLogger(r.Context()).WithError(err).Warn("Could not create the shadow request")
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		compared := s.compare && s.float64() < s.compareRate
+		var mirrored chan *response
+		if compared {
+			mirrored = make(chan *response, 1)
+		}
+		go func() {
+			defer cancel()
+
+			resp := s.send(req, compared)
+			if mirrored != nil {
+				mirrored <- resp
+			} else if resp.err != nil {
+				middleware.ContextLogger(r.Context()).WithError(resp.err).WithField("path", r.URL.Path).Debug("The shadow request failed")
+			}
+		}()
+
+		if !compared {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		rec := proxy.NewResponseRecorder(s.compareMaxBodySize)
+		start := time.Now()
+		handler.ServeHTTP(rec.Wrap(w), r)
+		primary := recorded(rec, time.Since(start))
+
+		go s.record(ctx, r.Method, r.URL.Path, primary, mirrored)
+	})
+}
+
+// copyBody reads the request body for the mirror and puts it back for the primary upstream, it returns
+// false when the body is larger than the max body size
+func (s *Shadow) copyBody(r *http.Request) ([]byte, bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true
+	}
+	if r.ContentLength > s.maxBodySize {
+		return nil, false
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, s.maxBodySize+1))
+	// the bytes read are served to the primary upstream, followed by the ones left in the body
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+
+	if err != nil || int64(len(body)) > s.maxBodySize {
+		return nil, false
+	}
+
+	return body, true
+}
+
+func (s *Shadow) newRequest(ctx context.Context, r *http.Request, body []byte) (*http.Request, error) {
+	target := *s.target
+	target.Path = strings.TrimSuffix(target.Path, "/") + r.URL.Path
+	target.RawPath = ""
+	switch {
+	case target.RawQuery == "":
+		target.RawQuery = r.URL.RawQuery
+	case r.URL.RawQuery != "":
+		target.RawQuery = target.RawQuery + "&" + r.URL.RawQuery
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequest(r.Method, target.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, values := range r.Header {
+		req.Header[name] = append([]string(nil), values...)
+	}
+	req.Header.Set(Header, "true")
+
+	return req.WithContext(ctx), nil
+}
+
+// send calls the mirror, the response body is kept only when it is compared
+func (s *Shadow) send(req *http.Request, keepBody bool) *response {
+	start := time.Now()
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return &response{err: err, latency: time.Since(start)}
+	}
+	defer resp.Body.Close()
+
+	result := &response{statusCode: resp.StatusCode}
+	if keepBody {
+		result.body, result.truncated, result.err = readBody(resp.Body, s.compareMaxBodySize)
+	}
+	result.latency = time.Since(start)
+
+	return result
+}
+
+// record diffs the responses once the mirror one is received, and counts the result by API
+func (s *Shadow) record(ctx context.Context, method, path string, primary *response, mirrored <-chan *response) {
+	diff := compare(primary, <-mirrored, s.fields)
+	diff.Time = time.Now()
+	diff.Method = method
+	diff.Path = path
+	diff.API, _ = tag.FromContext(ctx).Value(obs.KeyAPIName)
+
+	stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(obs.KeyShadowResult, diff.Result)}, obs.MShadowComparisons.M(1))
+
+	if err := s.sink.Write(diff); err != nil {
+		log.WithError(err).Warn("Could not write the shadow diff")
+	}
+}
+
+func readBody(body io.Reader, maxBodySize int64) ([]byte, bool, error) {
+	b, err := ioutil.ReadAll(io.LimitReader(body, maxBodySize+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(b)) > maxBodySize {
+		return nil, true, nil
+	}
+
+	return b, false, nil
+}
+
+// recorded returns the response written to the client
+func recorded(rec *proxy.ResponseRecorder, latency time.Duration) *response {
+	statusCode := rec.StatusCode()
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+
+	return &response{statusCode: statusCode, body: rec.Body(), truncated: rec.Truncated(), latency: latency}
+}
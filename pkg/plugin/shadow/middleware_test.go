@@ -0,0 +1,226 @@
+package shadow
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	obs "github.com/hellofresh/janus/pkg/observability"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+// mirrored is a request received by the mirror
+type mirrored struct {
+	method, uri, body, shadow string
+}
+
+func newMirror(status int, body string) (*httptest.Server, <-chan mirrored) {
+	requests := make(chan mirrored, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		requests <- mirrored{method: r.Method, uri: r.URL.RequestURI(), body: string(b), shadow: r.Header.Get(Header)}
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+
+	return server, requests
+}
+
+// primary echoes the request body
+var primary = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	b, _ := ioutil.ReadAll(r.Body)
+	w.WriteHeader(http.StatusCreated)
+	w.Write(b)
+})
+
+func newShadow(t *testing.T, config Config) *Shadow {
+	if config.Timeout == 0 {
+		config.Timeout = proxy.Duration(DefaultTimeout)
+	}
+	if config.MaxBodySize == "" {
+		config.MaxBodySize = DefaultMaxBodySize
+	}
+	if config.Compare.MaxBodySize == "" {
+		config.Compare.MaxBodySize = DefaultMaxBodySize
+	}
+
+	s, err := NewShadow(config)
+	require.NoError(t, err)
+	return s
+}
+
+func TestShadowMirrorsRequests(t *testing.T) {
+	mirror, requests := newMirror(http.StatusOK, "mirror")
+	defer mirror.Close()
+
+	handler := newShadow(t, Config{Target: mirror.URL + "/v2?source=janus"}).Handler(primary)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/users?page=2", strings.NewReader(`{"name": "jane"}`)))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, `{"name": "jane"}`, w.Body.String(), "the primary upstream gets the whole body")
+
+	select {
+	case req := <-requests:
+		assert.Equal(t, mirrored{method: http.MethodPost, uri: "/v2/users?source=janus&page=2", body: `{"name": "jane"}`, shadow: "true"}, req)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the request was not mirrored")
+	}
+}
+
+func TestShadowDoesNotDelayTheClient(t *testing.T) {
+	release := make(chan struct{})
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer mirror.Close()
+	defer close(release)
+
+	dir, err := ioutil.TempDir("", "shadow")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	handler := newShadow(t, Config{
+		Target:  mirror.URL,
+		Compare: CompareConfig{Enabled: true, Sink: fileSink, File: filepath.Join(dir, "diffs.log")},
+	}).Handler(primary)
+
+	done := make(chan int)
+	go func() {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		done <- w.Code
+	}()
+
+	select {
+	case code := <-done:
+		assert.Equal(t, http.StatusCreated, code)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the client waited for the mirror")
+	}
+}
+
+func TestShadowSkipsLargeBodies(t *testing.T) {
+	mirror, requests := newMirror(http.StatusOK, "")
+	defer mirror.Close()
+
+	handler := newShadow(t, Config{Target: mirror.URL, MaxBodySize: "4B"}).Handler(primary)
+
+	for _, body := range []string{"large body", "still large"} {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		// the size of a streamed body is unknown until it is read
+		req.ContentLength = -1
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, body, w.Body.String(), "the primary upstream gets the whole body")
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader("tiny")))
+	assert.Equal(t, "tiny", (<-requests).body)
+	assert.Empty(t, requests)
+}
+
+func TestShadowSampling(t *testing.T) {
+	mirror, requests := newMirror(http.StatusOK, "")
+	defer mirror.Close()
+
+	rate := 0.5
+	s := newShadow(t, Config{Target: mirror.URL, SampleRate: &rate})
+	s.float64 = func() float64 { return 0.7 }
+	s.Handler(primary).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/skipped", nil))
+
+	s.float64 = func() float64 { return 0.2 }
+	s.Handler(primary).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/mirrored", nil))
+
+	assert.Equal(t, "/mirrored", (<-requests).uri)
+	assert.Empty(t, requests)
+}
+
+func TestShadowCompare(t *testing.T) {
+	require.NoError(t, view.Register(obs.AllViews...))
+	defer view.Unregister(obs.AllViews...)
+
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		if r.URL.Path == "/same" {
+			w.Write([]byte(`{"id": 1, "name": "jane"}`))
+		} else {
+			w.Write([]byte(`{"id": 2, "name": "jane"}`))
+		}
+	}))
+	defer mirror.Close()
+
+	dir, err := ioutil.TempDir("", "shadow")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "diffs.log")
+
+	handler := newShadow(t, Config{
+		Target:  mirror.URL,
+		Compare: CompareConfig{Enabled: true, JSONFields: []string{"id", "name"}, Sink: fileSink, File: file},
+	}).Handler(primary)
+
+	ctx, err := tag.New(context.Background(), tag.Upsert(obs.KeyAPIName, "users"))
+	require.NoError(t, err)
+	for _, path := range []string{"/same", "/different"} {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"id": 1, "name": "jane"}`)).WithContext(ctx)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	var diffs map[string]Diff
+	require.Eventually(t, func() bool {
+		diffs = readDiffs(t, file)
+		return len(diffs) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, ResultMatch, diffs["/same"].Result)
+	assert.Equal(t, "users", diffs["/same"].API)
+	assert.Equal(t, StatusDiff{Primary: http.StatusCreated, Mirror: http.StatusCreated}, diffs["/same"].Status)
+	assert.True(t, diffs["/same"].Latency.Mirror > 0)
+
+	assert.Equal(t, ResultMismatch, diffs["/different"].Result)
+	assert.Equal(t, []FieldDiff{{Path: "id", Primary: float64(1), Mirror: float64(2)}}, diffs["/different"].Fields)
+
+	rows, err := view.RetrieveData("plugin_shadow_comparison_total")
+	require.NoError(t, err)
+
+	results := make(map[string]int64)
+	for _, row := range rows {
+		assert.Contains(t, row.Tags, tag.Tag{Key: obs.KeyAPIName, Value: "users"})
+		for _, tg := range row.Tags {
+			if tg.Key == obs.KeyShadowResult {
+				results[tg.Value] = row.Data.(*view.CountData).Value
+			}
+		}
+	}
+	assert.Equal(t, map[string]int64{ResultMatch: 1, ResultMismatch: 1}, results)
+}
+
+// readDiffs returns the diffs written to the file by path
+func readDiffs(t *testing.T, file string) map[string]Diff {
+	f, err := os.Open(file)
+	require.NoError(t, err)
+	defer f.Close()
+
+	diffs := make(map[string]Diff)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var diff Diff
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &diff))
+		diffs[diff.Path] = diff
+	}
+
+	return diffs
+}
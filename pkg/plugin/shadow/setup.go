@@ -0,0 +1,154 @@
+package shadow
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/bytefmt"
+	"github.com/hellofresh/janus/pkg/plugin"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultTimeout is the time the mirror has to respond when none is set
+	DefaultTimeout = 5 * time.Second
+	// DefaultMaxBodySize is the size of the largest request body copied to the mirror, and of the largest
+	// response body diffed by the compare mode, when none is set
+	DefaultMaxBodySize = "1MB"
+)
+
+// Config represents the shadow configuration
+type Config struct {
+	// Target is the URL of the mirror, the request URI is appended to its path
+	Target string `json:"target"`
+	// SampleRate is the share of the requests sent to the mirror, from 0 to 1
+	SampleRate *float64 `json:"sample_rate"`
+	// Timeout is the time the mirror has to respond
+	Timeout proxy.Duration `json:"timeout"`
+	// MaxBodySize is the size of the largest request body copied to the mirror, the requests with a
+	// larger body are not mirrored
+	MaxBodySize string        `json:"max_body_size"`
+	Compare     CompareConfig `json:"compare"`
+}
+
+// CompareConfig represents the configuration of the comparison of the mirror and primary responses
+type CompareConfig struct {
+	Enabled bool `json:"enabled"`
+	// SampleRate is the share of the mirrored requests compared, from 0 to 1
+	SampleRate *float64 `json:"sample_rate"`
+	// MaxBodySize is the size of the largest response body diffed, only the status codes are compared
+	// when a response is larger
+	MaxBodySize string `json:"max_body_size"`
+	// JSONFields are the dot separated paths of the response fields compared, e.g. data.items.0.id
+	JSONFields []string `json:"json_fields"`
+	// Sink is where the diffs are written, "log" or "file"
+	Sink string `json:"sink"`
+	// File is the path of the file sink
+	File string `json:"file"`
+}
+
+func init() {
+	plugin.RegisterPlugin("shadow", plugin.Plugin{
+		Action:              setupShadow,
+		Validate:            validateConfig,
+		Stateful:            true,
+		BuffersResponseBody: true,
+	})
+}
+
+func setupShadow(def *proxy.RouterDefinition, rawConfig plugin.Config) error {
+	config, err := decodeConfig(rawConfig)
+	if err != nil {
+		return err
+	}
+
+	shadow, err := NewShadow(config)
+	if err != nil {
+		return err
+	}
+
+	def.AddMiddleware(shadow.Handler)
+	return nil
+}
+
+func validateConfig(rawConfig plugin.Config) (bool, error) {
+	if _, err := decodeConfig(rawConfig); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func decodeConfig(rawConfig plugin.Config) (Config, error) {
+	config := Config{
+		Timeout:     proxy.Duration(DefaultTimeout),
+		MaxBodySize: DefaultMaxBodySize,
+		Compare:     CompareConfig{MaxBodySize: DefaultMaxBodySize, Sink: logSink},
+	}
+	if err := plugin.Decode(rawConfig, &config); err != nil {
+		return config, err
+	}
+
+	target, err := url.Parse(config.Target)
+	if err != nil {
+		return config, errors.Wrap(err, "invalid shadow target")
+	}
+	if (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return config, errors.Errorf("shadow target %q must be an absolute http or https URL", config.Target)
+	}
+	if config.Timeout <= 0 {
+		return config, errors.New("shadow timeout must be greater than zero")
+	}
+	if err := validateSampleRate(config.SampleRate); err != nil {
+		return config, err
+	}
+	if _, err := bytefmt.ToBytes(config.MaxBodySize); err != nil {
+		return config, errors.Wrap(err, "invalid shadow max body size")
+	}
+
+	if !config.Compare.Enabled {
+		return config, nil
+	}
+
+	if err := validateSampleRate(config.Compare.SampleRate); err != nil {
+		return config, errors.Wrap(err, "compare")
+	}
+	if _, err := bytefmt.ToBytes(config.Compare.MaxBodySize); err != nil {
+		return config, errors.Wrap(err, "invalid shadow compare max body size")
+	}
+	for _, field := range config.Compare.JSONFields {
+		if field == "" || strings.HasPrefix(field, ".") || strings.HasSuffix(field, ".") || strings.Contains(field, "..") {
+			return config, errors.Errorf("invalid shadow compare JSON field %q", field)
+		}
+	}
+	switch config.Compare.Sink {
+	case logSink:
+	case fileSink:
+		if config.Compare.File == "" {
+			return config, errors.New("shadow compare file sink requires the file path")
+		}
+	default:
+		return config, errors.Errorf("unknown shadow compare sink %q", config.Compare.Sink)
+	}
+
+	return config, nil
+}
+
+func validateSampleRate(rate *float64) error {
+	if rate != nil && (*rate < 0 || *rate > 1) {
+		return errors.Errorf("shadow sample rate %v must be between 0 and 1", *rate)
+	}
+
+	return nil
+}
+
+// sampleRate returns the configured rate, all the requests are sampled when none is set
+func sampleRate(rate *float64) float64 {
+	if rate == nil {
+		return 1
+	}
+
+	return *rate
+}
@@ -0,0 +1,43 @@
+package shadow
+
+import (
+	"testing"
+
+	"github.com/hellofresh/janus/pkg/plugin"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetup(t *testing.T) {
+	def := proxy.NewRouterDefinition(proxy.NewDefinition())
+	err := setupShadow(def, plugin.Config{
+		"target":  "http://mirror.example.com",
+		"compare": map[string]interface{}{"enabled": true, "json_fields": []string{"data.id"}},
+	})
+	assert.NoError(t, err)
+
+	assert.Len(t, def.Middleware(), 1)
+}
+
+func TestValidateConfig(t *testing.T) {
+	valid, err := validateConfig(plugin.Config{"target": "http://mirror.example.com", "sample_rate": 0.5, "timeout": "1s"})
+	assert.NoError(t, err)
+	assert.True(t, valid)
+
+	for name, config := range map[string]plugin.Config{
+		"no target":             {},
+		"relative target":       {"target": "/mirror"},
+		"unsupported scheme":    {"target": "ftp://mirror.example.com"},
+		"sample rate":           {"target": "http://mirror.example.com", "sample_rate": 1.5},
+		"timeout":               {"target": "http://mirror.example.com", "timeout": "-1s"},
+		"max body size":         {"target": "http://mirror.example.com", "max_body_size": "large"},
+		"compare sample rate":   {"target": "http://mirror.example.com", "compare": map[string]interface{}{"enabled": true, "sample_rate": -0.1}},
+		"compare max body size": {"target": "http://mirror.example.com", "compare": map[string]interface{}{"enabled": true, "max_body_size": "large"}},
+		"compare field":         {"target": "http://mirror.example.com", "compare": map[string]interface{}{"enabled": true, "json_fields": []string{"data..id"}}},
+		"compare sink":          {"target": "http://mirror.example.com", "compare": map[string]interface{}{"enabled": true, "sink": "kafka"}},
+		"compare file":          {"target": "http://mirror.example.com", "compare": map[string]interface{}{"enabled": true, "sink": "file"}},
+	} {
+		_, err = validateConfig(config)
+		assert.Error(t, err, name)
+	}
+}
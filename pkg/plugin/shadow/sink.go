@@ -0,0 +1,108 @@
+package shadow
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	logSink  = "log"
+	fileSink = "file"
+)
+
+// Sink writes the diffs of the compared responses
+type Sink interface {
+	Write(diff Diff) error
+}
+
+var (
+	fileSinksMu sync.Mutex
+	// fileSinks are shared by the APIs and the reloads writing to the same file, so it is opened once
+	fileSinks = make(map[string]*FileSink)
+)
+
+// NewSink creates the sink configured for the diffs
+func NewSink(cfg CompareConfig) (Sink, error) {
+	switch cfg.Sink {
+	case "", logSink:
+		return &LogSink{}, nil
+	case fileSink:
+		return NewFileSink(cfg.File)
+	default:
+		return nil, errors.Errorf("unknown shadow compare sink %q", cfg.Sink)
+	}
+}
+
+// LogSink writes the diffs to the application log
+type LogSink struct{}
+
+// Write writes the diff
+func (s *LogSink) Write(diff Diff) error {
+	entry := log.WithFields(log.Fields{
+		"api":               diff.API,
+		"method":            diff.Method,
+		"path":              diff.Path,
+		"primary_status":    diff.Status.Primary,
+		"mirror_status":     diff.Status.Mirror,
+		"primary_latency":   diff.Latency.Primary,
+		"mirror_latency":    diff.Latency.Mirror,
+		"fields":            diff.Fields,
+		"truncated":         diff.Truncated,
+		"mirror_error":      diff.Error,
+		"shadow_comparison": diff.Result,
+	})
+	if diff.Result == ResultMatch {
+		entry.Debug("Shadow responses match")
+	} else {
+		entry.Info("Shadow responses differ")
+	}
+
+	return nil
+}
+
+// FileSink appends the diffs to the file as JSON lines
+type FileSink struct {
+	sync.Mutex
+	file *os.File
+}
+
+// NewFileSink returns the FileSink of the path, the file is created if it does not exist
+func NewFileSink(path string) (*FileSink, error) {
+	if path == "" {
+		return nil, errors.New("shadow compare file path is not set")
+	}
+
+	fileSinksMu.Lock()
+	defer fileSinksMu.Unlock()
+
+	if sink, ok := fileSinks[path]; ok {
+		return sink, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not open the shadow compare file")
+	}
+
+	sink := &FileSink{file: file}
+	fileSinks[path] = sink
+	return sink, nil
+}
+
+// Write writes the diff
+func (s *FileSink) Write(diff Diff) error {
+	b, err := json.Marshal(diff)
+	if err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	_, err = s.file.Write(append(b, '\n'))
+	return err
+}
@@ -0,0 +1,118 @@
+package statusmap
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/felixge/httpsnoop"
+	"github.com/hellofresh/janus/pkg/middleware"
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultContentType = "application/json"
+
+// StatusMapping maps the upstream response status codes to the ones sent to the clients, the
+// responses with an unmapped status code are left untouched
+type StatusMapping struct {
+	rules map[int]Rule
+}
+
+// NewStatusMapping creates a new instance of StatusMapping
+func NewStatusMapping(config Config) *StatusMapping {
+	rules := make(map[int]Rule, len(config.Rules))
+	for _, rule := range config.Rules {
+		if rule.Body != "" && rule.ContentType == "" {
+			rule.ContentType = defaultContentType
+		}
+		rules[rule.From] = rule
+	}
+
+	return &StatusMapping{rules: rules}
+}
+
+// Handler is the middleware function
+func (m *StatusMapping) Handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(m.wrap(w, r), r)
+	})
+}
+
+// wrap returns the response writer mapping the status code, the upstream body is discarded when the
+// rule replaces it
+func (m *StatusMapping) wrap(w http.ResponseWriter, r *http.Request) http.ResponseWriter {
+	var (
+		wroteHeader bool
+		discard     bool
+		wrapped     http.ResponseWriter
+	)
+
+	wrapped = httpsnoop.Wrap(w, httpsnoop.Hooks{
+		WriteHeader: func(next httpsnoop.WriteHeaderFunc) httpsnoop.WriteHeaderFunc {
+			return func(code int) {
+				if wroteHeader {
+					return
+				}
+				if code < http.StatusOK {
+					// the informational responses are followed by the final one
+					next(code)
+					return
+				}
+				wroteHeader = true
+
+				rule, ok := m.rules[code]
+				if !ok {
+					next(code)
+					return
+				}
+
+				middleware.ContextLogger(r.Context()).WithFields(log.Fields{"path": r.URL.Path, "from": rule.From, "to": rule.To}).Debug("Mapping the response status code")
+				if rule.Body == "" {
+					next(rule.To)
+					return
+				}
+
+				// the trailers of the upstream describe the discarded body
+				header := w.Header()
+				header.Del("Content-Encoding")
+				header.Del("ETag")
+				header.Del("Trailer")
+				header.Set("Content-Type", rule.ContentType)
+				header.Set("Content-Length", strconv.Itoa(len(rule.Body)))
+				next(rule.To)
+
+				if r.Method != http.MethodHead {
+					io.WriteString(w, rule.Body)
+				}
+				discard = true
+			}
+		},
+		Write: func(next httpsnoop.WriteFunc) httpsnoop.WriteFunc {
+			return func(b []byte) (int, error) {
+				if !wroteHeader {
+					wrapped.WriteHeader(http.StatusOK)
+				}
+				if discard {
+					return len(b), nil
+				}
+
+				return next(b)
+			}
+		},
+		ReadFrom: func(next httpsnoop.ReadFromFunc) httpsnoop.ReadFromFunc {
+			return func(src io.Reader) (int64, error) {
+				if !wroteHeader {
+					wrapped.WriteHeader(http.StatusOK)
+				}
+				if discard {
+					return io.Copy(ioutil.Discard, src)
+				}
+
+				return next(src)
+			}
+		},
+	})
+
+	return wrapped
+}
@@ -0,0 +1,79 @@
+package statusmap
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestHandler(code int) http.Handler {
+	return NewStatusMapping(Config{Rules: []Rule{
+		{From: http.StatusUnprocessableEntity, To: http.StatusBadRequest},
+		{From: http.StatusTeapot, To: http.StatusServiceUnavailable, Body: `{"error":"service unavailable"}`},
+		{From: http.StatusOK, To: http.StatusAccepted},
+	}}).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Content-Length", "8")
+		w.Header().Set("Location", "/elsewhere")
+		if code != http.StatusOK {
+			w.WriteHeader(code)
+		}
+		w.Write([]byte("upstream"))
+	}))
+}
+
+func TestStatusMapping(t *testing.T) {
+	tests := []struct {
+		scenario    string
+		upstream    int
+		code        int
+		body        string
+		contentType string
+	}{
+		{scenario: "mapped", upstream: http.StatusUnprocessableEntity, code: http.StatusBadRequest, body: "upstream", contentType: "text/plain"},
+		{scenario: "mapped with body", upstream: http.StatusTeapot, code: http.StatusServiceUnavailable, body: `{"error":"service unavailable"}`, contentType: "application/json"},
+		{scenario: "implicit status", upstream: http.StatusOK, code: http.StatusAccepted, body: "upstream", contentType: "text/plain"},
+		{scenario: "unmapped", upstream: http.StatusNotFound, code: http.StatusNotFound, body: "upstream", contentType: "text/plain"},
+		{scenario: "redirect", upstream: http.StatusFound, code: http.StatusFound, body: "upstream", contentType: "text/plain"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			newTestHandler(test.upstream).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, test.code, w.Code)
+			assert.Equal(t, test.body, w.Body.String())
+			assert.Equal(t, test.contentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, "/elsewhere", w.Header().Get("Location"), "the other headers are kept")
+		})
+	}
+}
+
+func TestStatusMappingBodyLength(t *testing.T) {
+	w := httptest.NewRecorder()
+	newTestHandler(http.StatusTeapot).ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "31", w.Header().Get("Content-Length"))
+	assert.Empty(t, w.Body.String(), "the HEAD responses have no body")
+}
+
+func TestStatusMappingTrailers(t *testing.T) {
+	handler := NewStatusMapping(Config{Rules: []Rule{
+		{From: http.StatusTeapot, To: http.StatusServiceUnavailable, Body: `{"error":"service unavailable"}`},
+	}}).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Checksum")
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("upstream"))
+		w.Header().Set("X-Checksum", "abc")
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, `{"error":"service unavailable"}`, w.Body.String())
+	assert.Empty(t, w.Result().Trailer, "the trailers of the replaced body are dropped")
+}
@@ -0,0 +1,86 @@
+package statusmap
+
+import (
+	"net/http"
+
+	"github.com/hellofresh/janus/pkg/plugin"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/pkg/errors"
+)
+
+// Rule maps an upstream status code to the status code sent to the clients
+type Rule struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+	// Body replaces the upstream response body when it is set
+	Body string `json:"body"`
+	// ContentType is the content type of the replaced body, defaults to application/json
+	ContentType string `json:"content_type"`
+}
+
+// Config represents the status mapping configuration
+type Config struct {
+	Rules []Rule `json:"rules"`
+}
+
+func init() {
+	plugin.RegisterPlugin("status_mapping", plugin.Plugin{
+		Action:               setupStatusMapping,
+		Validate:             validateConfig,
+		ModifiesResponseBody: true,
+	})
+}
+
+func setupStatusMapping(def *proxy.RouterDefinition, rawConfig plugin.Config) error {
+	config, err := decodeConfig(rawConfig)
+	if err != nil {
+		return err
+	}
+
+	def.AddMiddleware(NewStatusMapping(config).Handler)
+	return nil
+}
+
+func validateConfig(rawConfig plugin.Config) (bool, error) {
+	if _, err := decodeConfig(rawConfig); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func decodeConfig(rawConfig plugin.Config) (Config, error) {
+	var config Config
+	if err := plugin.Decode(rawConfig, &config); err != nil {
+		return config, err
+	}
+
+	if len(config.Rules) == 0 {
+		return config, errors.New("status mapping rules are not set")
+	}
+
+	mapped := make(map[int]bool, len(config.Rules))
+	for _, rule := range config.Rules {
+		// the informational, e.g. the protocol switch, and the redirect semantics can not be mapped
+		if !isMappable(rule.From) || !isMappable(rule.To) {
+			return config, errors.Errorf("status mapping %d -> %d is not supported, the codes must be 2xx, 4xx or 5xx", rule.From, rule.To)
+		}
+		if rule.Body != "" && !bodyAllowed(rule.To) {
+			return config, errors.Errorf("status mapping %d -> %d can not have a body", rule.From, rule.To)
+		}
+		if mapped[rule.From] {
+			return config, errors.Errorf("status %d is mapped more than once", rule.From)
+		}
+		mapped[rule.From] = true
+	}
+
+	return config, nil
+}
+
+func isMappable(code int) bool {
+	return (code >= 200 && code < 300) || (code >= 400 && code < 600)
+}
+
+func bodyAllowed(code int) bool {
+	return code != http.StatusNoContent && code != http.StatusResetContent
+}
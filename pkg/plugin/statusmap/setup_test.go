@@ -0,0 +1,41 @@
+package statusmap
+
+import (
+	"testing"
+
+	"github.com/hellofresh/janus/pkg/plugin"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetup(t *testing.T) {
+	def := proxy.NewRouterDefinition(proxy.NewDefinition())
+	err := setupStatusMapping(def, plugin.Config{
+		"rules": []interface{}{
+			map[string]interface{}{"from": 422, "to": 400},
+			map[string]interface{}{"from": 418, "to": 503, "body": `{"error":"unavailable"}`},
+		},
+	})
+	require.NoError(t, err)
+	assert.Len(t, def.Middleware(), 1)
+}
+
+func TestDecodeConfigInvalid(t *testing.T) {
+	invalid := map[string]plugin.Config{
+		"no rules":      {},
+		"redirect":      {"rules": []interface{}{map[string]interface{}{"from": 301, "to": 200}}},
+		"to redirect":   {"rules": []interface{}{map[string]interface{}{"from": 404, "to": 302}}},
+		"informational": {"rules": []interface{}{map[string]interface{}{"from": 101, "to": 200}}},
+		"invalid code":  {"rules": []interface{}{map[string]interface{}{"from": 422, "to": 999}}},
+		"no content":    {"rules": []interface{}{map[string]interface{}{"from": 422, "to": 204, "body": "{}"}}},
+		"mapped twice":  {"rules": []interface{}{map[string]interface{}{"from": 422, "to": 400}, map[string]interface{}{"from": 422, "to": 500}}},
+	}
+
+	for name, config := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := decodeConfig(config)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package transcoding
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/bytefmt"
+	"github.com/hellofresh/janus/pkg/errors"
+	"github.com/hellofresh/janus/pkg/middleware"
+	"github.com/hellofresh/janus/pkg/proxy"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+var (
+	// ErrInvalidBody is thrown when the request body can not be translated to the gRPC request message
+	ErrInvalidBody = errors.New(http.StatusBadRequest, "request body does not match the grpc request message")
+	// ErrRequestEntityTooLarge is thrown when the request body is larger than the max body size
+	ErrRequestEntityTooLarge = errors.New(http.StatusRequestEntityTooLarge, http.StatusText(http.StatusRequestEntityTooLarge))
+
+	// httpStatuses are the HTTP status codes of the gRPC status codes
+	httpStatuses = map[codes.Code]int{
+		codes.OK:                 http.StatusOK,
+		codes.Canceled:           499,
+		codes.Unknown:            http.StatusInternalServerError,
+		codes.InvalidArgument:    http.StatusBadRequest,
+		codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+		codes.NotFound:           http.StatusNotFound,
+		codes.AlreadyExists:      http.StatusConflict,
+		codes.PermissionDenied:   http.StatusForbidden,
+		codes.ResourceExhausted:  http.StatusTooManyRequests,
+		codes.FailedPrecondition: http.StatusBadRequest,
+		codes.Aborted:            http.StatusConflict,
+		codes.OutOfRange:         http.StatusBadRequest,
+		codes.Unimplemented:      http.StatusNotImplemented,
+		codes.Internal:           http.StatusInternalServerError,
+		codes.Unavailable:        http.StatusServiceUnavailable,
+		codes.DataLoss:           http.StatusInternalServerError,
+		codes.Unauthenticated:    http.StatusUnauthorized,
+	}
+
+	// skippedHeaders are not sent as the gRPC metadata
+	skippedHeaders = map[string]bool{
+		"Connection": true, "Content-Length": true, "Content-Type": true, "Accept-Encoding": true,
+		"Keep-Alive": true, "Te": true, "Trailer": true, "Transfer-Encoding": true, "Upgrade": true,
+	}
+)
+
+type route struct {
+	method   string
+	segments []string
+	fullName string
+	grpc     protoreflect.MethodDescriptor
+}
+
+// match returns the path parameters when the request matches the route
+func (rt *route) match(r *http.Request) (map[string]string, bool) {
+	if r.Method != rt.method {
+		return nil, false
+	}
+
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(segments) != len(rt.segments) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	for i, segment := range rt.segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			params[segment[1:len(segment)-1]] = segments[i]
+		} else if segment != segments[i] {
+			return nil, false
+		}
+	}
+
+	return params, true
+}
+
+// Transcoder translates the JSON requests of the routes to gRPC calls, and the gRPC responses back to
+// JSON. The requests not matching any route are proxied to the API upstreams.
+type Transcoder struct {
+	conn        *grpc.ClientConn
+	routes      []*route
+	timeout     time.Duration
+	maxBodySize int64
+}
+
+// newTranscoder creates a new instance of Transcoder
+func newTranscoder(conn *grpc.ClientConn, routes []*route, config Config) *Transcoder {
+	if config.MaxBodySize == "" {
+		config.MaxBodySize = DefaultMaxBodySize
+	}
+	maxBodySize, err := bytefmt.ToBytes(config.MaxBodySize)
+	if err != nil {
+		log.WithError(err).WithField("max_body_size", config.MaxBodySize).Error("invalid grpc transcoding max body size")
+	}
+
+	return &Transcoder{conn: conn, routes: routes, timeout: time.Duration(config.Timeout), maxBodySize: int64(maxBodySize)}
+}
+
+// Handler is the middleware function
+func (t *Transcoder) Handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, rt := range t.routes {
+			if params, ok := rt.match(r); ok {
+				t.call(w, r, rt, params)
+				return
+			}
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func (t *Transcoder) call(w http.ResponseWriter, r *http.Request, rt *route, params map[string]string) {
+	// the whole body is needed to decode the request message, it is buffered up to the max body size and
+	// the request is rejected otherwise, so the body decoded is never read past it
+	buffered, err := proxy.BufferBody(r, t.maxBodySize)
+	if err != nil {
+		middleware.ContextLogger(r.Context()).WithError(err).WithField("grpc_method", rt.fullName).Debug("Could not read the grpc transcoding request body")
+		errors.Handler(w, ErrInvalidBody)
+		return
+	}
+	if !buffered {
+		errors.Handler(w, ErrRequestEntityTooLarge)
+		return
+	}
+
+	req := dynamicpb.NewMessage(rt.grpc.Input())
+	if err := decodeRequest(r, req, params); err != nil {
+		middleware.ContextLogger(r.Context()).WithError(err).WithField("grpc_method", rt.fullName).Debug("Invalid grpc transcoding request")
+		errors.Handler(w, ErrInvalidBody)
+		return
+	}
+
+	ctx := metadata.NewOutgoingContext(r.Context(), requestMetadata(r))
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+	}
+
+	var header metadata.MD
+	resp := dynamicpb.NewMessage(rt.grpc.Output())
+	if err := t.conn.Invoke(ctx, rt.fullName, req, resp, grpc.Header(&header)); err != nil {
+		st := status.Convert(err)
+		middleware.ContextLogger(r.Context()).WithFields(log.Fields{"grpc_method": rt.fullName, "grpc_code": st.Code().String()}).
+			Debug("The grpc call failed")
+		errors.Handler(w, errors.New(httpStatus(st.Code()), st.Message()))
+		return
+	}
+
+	body, err := protojson.Marshal(resp)
+	if err != nil {
+		errors.Handler(w, err)
+		return
+	}
+
+	for name, values := range header {
+		if name != "content-type" {
+			w.Header()[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// decodeRequest sets the request message from the JSON body, the path parameters and, for the
+// requests without a body, the query parameters
+func decodeRequest(r *http.Request, msg *dynamicpb.Message, params map[string]string) error {
+	fields := make(map[string]interface{})
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if len(strings.TrimSpace(string(body))) > 0 {
+		if err := json.Unmarshal(body, &fields); err != nil {
+			return err
+		}
+	} else {
+		for name, values := range r.URL.Query() {
+			if err := setField(fields, msg.Descriptor(), name, values[0]); err != nil {
+				return err
+			}
+		}
+	}
+
+	for name, value := range params {
+		if err := setField(fields, msg.Descriptor(), name, value); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+
+	return protojson.Unmarshal(data, msg)
+}
+
+// setField sets the scalar field of the path, e.g. user.id, to the value
+func setField(fields map[string]interface{}, desc protoreflect.MessageDescriptor, path, value string) error {
+	names := strings.Split(path, ".")
+	for i, name := range names {
+		field := desc.Fields().ByJSONName(name)
+		if field == nil {
+			field = desc.Fields().ByName(protoreflect.Name(name))
+		}
+		if field == nil {
+			return errors.New(http.StatusBadRequest, "unknown field "+path)
+		}
+
+		if i < len(names)-1 {
+			if field.Kind() != protoreflect.MessageKind || field.IsList() || field.IsMap() {
+				return errors.New(http.StatusBadRequest, "invalid field "+path)
+			}
+			nested, ok := fields[name].(map[string]interface{})
+			if !ok {
+				nested = make(map[string]interface{})
+				fields[name] = nested
+			}
+			fields, desc = nested, field.Message()
+			continue
+		}
+
+		if field.Kind() == protoreflect.BoolKind {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return err
+			}
+			fields[name] = b
+		} else {
+			// protojson accepts the numbers, the enums and the bytes as strings
+			fields[name] = value
+		}
+	}
+
+	return nil
+}
+
+func requestMetadata(r *http.Request) metadata.MD {
+	md := metadata.MD{}
+	for name, values := range r.Header {
+		if !skippedHeaders[name] {
+			md[strings.ToLower(name)] = values
+		}
+	}
+
+	return md
+}
+
+func httpStatus(code codes.Code) int {
+	if status, ok := httpStatuses[code]; ok {
+		return status
+	}
+
+	return http.StatusInternalServerError
+}
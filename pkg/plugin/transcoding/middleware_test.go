@@ -0,0 +1,115 @@
+package transcoding
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func newTestTranscoder(t *testing.T, target string) *Transcoder {
+	dir, err := ioutil.TempDir("", "transcoding")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	config := Config{
+		DescriptorSet: writeDescriptorSet(t, dir),
+		Target:        target,
+		Timeout:       proxy.Duration(time.Second),
+		MaxBodySize:   "1KB",
+		Routes: []Route{
+			{Method: "GET", Path: "/users/{id}", GRPCMethod: "users.v1.Users/GetUser"},
+			{Method: "POST", Path: "/users", GRPCMethod: "users.v1.Users/CreateUser"},
+		},
+	}
+	routes, err := newRoutes(config)
+	require.NoError(t, err)
+
+	conn, err := grpc.Dial(target, grpc.WithInsecure())
+	require.NoError(t, err)
+
+	return newTranscoder(conn, routes, config)
+}
+
+func TestTranscoder(t *testing.T) {
+	server, target := startTestServer(t)
+	defer server.Stop()
+
+	transcoder := newTestTranscoder(t, target)
+	defer transcoder.conn.Close()
+
+	handler := transcoder.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	tests := []struct {
+		name     string
+		method   string
+		url      string
+		body     string
+		streamed bool
+		code     int
+		response string
+	}{
+		{name: "path parameter", method: "GET", url: "/users/42", code: http.StatusOK, response: `{"id":"42", "name":"janus"}`},
+		{name: "query parameter", method: "GET", url: "/users/42?verbose=true", code: http.StatusOK, response: `{"id":"42", "name":"janus (verbose)"}`},
+		{name: "json body", method: "POST", url: "/users", body: `{"id":"7","name":"ignored"}`, code: http.StatusOK, response: `{"id":"7", "name":"janus"}`},
+		{name: "invalid body", method: "POST", url: "/users", body: `{"unknown":1}`, code: http.StatusBadRequest},
+		{name: "body too large", method: "POST", url: "/users", body: `{"id":"` + strings.Repeat("7", 1024) + `"}`, code: http.StatusRequestEntityTooLarge},
+		{name: "streamed body too large", method: "POST", url: "/users", body: `{"id":"` + strings.Repeat("7", 1024) + `"}`, streamed: true, code: http.StatusRequestEntityTooLarge},
+		{name: "streamed json body", method: "POST", url: "/users", body: `{"id":"7"}`, streamed: true, code: http.StatusOK, response: `{"id":"7", "name":"janus"}`},
+		{name: "invalid query parameter", method: "GET", url: "/users/42?verbose=maybe", code: http.StatusBadRequest},
+		{name: "not found", method: "POST", url: "/users", body: `{}`, code: http.StatusNotFound},
+		{name: "not a route", method: "GET", url: "/groups/42", code: http.StatusTeapot},
+		{name: "not a route method", method: "DELETE", url: "/users/42", code: http.StatusTeapot},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, test.url, strings.NewReader(test.body))
+			if test.streamed {
+				req.ContentLength = -1
+			}
+			req.Header.Set("X-Name", "janus")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, test.code, w.Code)
+			if test.response != "" {
+				assert.JSONEq(t, test.response, w.Body.String())
+				assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+				assert.NotEmpty(t, w.Header().Get("X-User-Id"), "the response metadata is sent as headers")
+			}
+		})
+	}
+}
+
+func TestTranscoderUnavailable(t *testing.T) {
+	server, target := startTestServer(t)
+	server.Stop()
+
+	transcoder := newTestTranscoder(t, target)
+	defer transcoder.conn.Close()
+
+	w := httptest.NewRecorder()
+	transcoder.Handler(http.NotFoundHandler()).ServeHTTP(w, httptest.NewRequest("GET", "/users/42", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestHTTPStatus(t *testing.T) {
+	assert.Equal(t, http.StatusOK, httpStatus(codes.OK))
+	assert.Equal(t, http.StatusBadRequest, httpStatus(codes.InvalidArgument))
+	assert.Equal(t, http.StatusGatewayTimeout, httpStatus(codes.DeadlineExceeded))
+	assert.Equal(t, http.StatusUnauthorized, httpStatus(codes.Unauthenticated))
+	assert.Equal(t, http.StatusTooManyRequests, httpStatus(codes.ResourceExhausted))
+	assert.Equal(t, http.StatusInternalServerError, httpStatus(codes.Code(42)))
+}
@@ -0,0 +1,227 @@
+package transcoding
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"code.cloudfoundry.org/bytefmt"
+	"github.com/hellofresh/janus/pkg/plugin"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// Route maps a REST route to a gRPC method
+type Route struct {
+	// Method is the HTTP method of the route
+	Method string `json:"method"`
+	// Path is the path template of the route, e.g. /users/{id}, the path parameters set the request
+	// message fields of the same name
+	Path string `json:"path"`
+	// GRPCMethod is the full name of the gRPC method, e.g. users.v1.Users/GetUser
+	GRPCMethod string `json:"grpc_method"`
+}
+
+// Config represents the gRPC transcoding configuration
+type Config struct {
+	// DescriptorSet is the path of the descriptor set of the services, generated with
+	// protoc --include_imports --descriptor_set_out
+	DescriptorSet string `json:"descriptor_set"`
+	// Target is the address of the gRPC server
+	Target string `json:"target"`
+	// TLS enables TLS on the connection to the gRPC server
+	TLS     bool           `json:"tls"`
+	Timeout proxy.Duration `json:"timeout"`
+	Routes  []Route        `json:"routes"`
+	// MaxBodySize is the size of the largest JSON request body, the body is buffered to be decoded
+	MaxBodySize string `json:"max_body_size"`
+}
+
+// DefaultMaxBodySize is the size of the largest JSON request body when none is configured
+const DefaultMaxBodySize = "4MB"
+
+var (
+	connsMu sync.Mutex
+	// conns are shared by the API definitions calling the same server, so the definitions reloads do
+	// not open new connections
+	conns = make(map[string]*grpc.ClientConn)
+)
+
+func init() {
+	plugin.RegisterPlugin("grpc_transcoding", plugin.Plugin{
+		Action:   setupTranscoding,
+		Validate: validateConfig,
+	})
+}
+
+func setupTranscoding(def *proxy.RouterDefinition, rawConfig plugin.Config) error {
+	config, err := decodeConfig(rawConfig)
+	if err != nil {
+		return err
+	}
+
+	routes, err := newRoutes(config)
+	if err != nil {
+		return err
+	}
+
+	conn, err := dial(config.Target, config.TLS)
+	if err != nil {
+		return err
+	}
+
+	def.AddMiddleware(newTranscoder(conn, routes, config).Handler)
+	return nil
+}
+
+func validateConfig(rawConfig plugin.Config) (bool, error) {
+	config, err := decodeConfig(rawConfig)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := newRoutes(config); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func decodeConfig(rawConfig plugin.Config) (Config, error) {
+	config := Config{MaxBodySize: DefaultMaxBodySize}
+	if err := plugin.Decode(rawConfig, &config); err != nil {
+		return config, err
+	}
+
+	if config.DescriptorSet == "" {
+		return config, errors.New("grpc transcoding descriptor set is not set")
+	}
+	if config.Target == "" {
+		return config, errors.New("grpc transcoding target is not set")
+	}
+	if len(config.Routes) == 0 {
+		return config, errors.New("grpc transcoding routes are not set")
+	}
+	if _, err := bytefmt.ToBytes(config.MaxBodySize); err != nil {
+		return config, errors.Wrap(err, "invalid grpc transcoding max_body_size")
+	}
+
+	return config, nil
+}
+
+// newRoutes resolves the gRPC methods of the routes in the descriptor set
+func newRoutes(config Config) ([]*route, error) {
+	files, err := loadDescriptorSet(config.DescriptorSet)
+	if err != nil {
+		return nil, err
+	}
+
+	routes := make([]*route, 0, len(config.Routes))
+	for _, r := range config.Routes {
+		method, err := findMethod(files, r.GRPCMethod)
+		if err != nil {
+			return nil, err
+		}
+		if method.IsStreamingClient() || method.IsStreamingServer() {
+			return nil, errors.Errorf("grpc method %q is not unary, only the unary methods are supported", r.GRPCMethod)
+		}
+
+		if !strings.HasPrefix(r.Path, "/") {
+			return nil, errors.Errorf("grpc transcoding route path %q must begin with '/'", r.Path)
+		}
+		httpMethod := strings.ToUpper(r.Method)
+		if httpMethod == "" {
+			httpMethod = http.MethodPost
+		}
+
+		routes = append(routes, &route{
+			method:   httpMethod,
+			segments: strings.Split(strings.Trim(r.Path, "/"), "/"),
+			fullName: "/" + string(method.Parent().FullName()) + "/" + string(method.Name()),
+			grpc:     method,
+		})
+	}
+
+	return routes, nil
+}
+
+func loadDescriptorSet(path string) (*protoregistry.Files, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read the grpc descriptor set")
+	}
+
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, errors.Wrap(err, "could not parse the grpc descriptor set")
+	}
+
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid grpc descriptor set, it must include the imports")
+	}
+
+	return files, nil
+}
+
+// findMethod returns the method of its full name, either package.Service/Method or package.Service.Method
+func findMethod(files *protoregistry.Files, name string) (protoreflect.MethodDescriptor, error) {
+	name = strings.TrimPrefix(name, "/")
+	i := strings.LastIndexAny(name, "/.")
+	if i < 0 {
+		return nil, errors.Errorf("invalid grpc method %q, it must be package.Service/Method", name)
+	}
+
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(name[:i]))
+	if err != nil {
+		return nil, errors.Errorf("grpc service of the method %q is not found in the descriptor set", name)
+	}
+
+	service, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, errors.Errorf("%q is not a grpc service", name[:i])
+	}
+
+	method := service.Methods().ByName(protoreflect.Name(name[i+1:]))
+	if method == nil {
+		return nil, errors.Errorf("grpc method %q is not found in the descriptor set", name)
+	}
+
+	return method, nil
+}
+
+func dial(target string, useTLS bool) (*grpc.ClientConn, error) {
+	connsMu.Lock()
+	defer connsMu.Unlock()
+
+	key := target
+	if useTLS {
+		key = "tls://" + target
+	}
+	if conn, ok := conns[key]; ok {
+		return conn, nil
+	}
+
+	option := grpc.WithInsecure()
+	if useTLS {
+		option = grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{}))
+	}
+
+	// the connection is established in the background and re-established when it is lost
+	conn, err := grpc.Dial(target, option)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not connect to the grpc server")
+	}
+	conns[key] = conn
+
+	return conn, nil
+}
@@ -0,0 +1,200 @@
+package transcoding
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hellofresh/janus/pkg/plugin"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func testFile() *descriptorpb.FileDescriptorProto {
+	field := func(name string, number int32, kind descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(number),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     kind.Enum(),
+		}
+	}
+
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("users.proto"),
+		Package: proto.String("users.v1"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("GetUserRequest"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("id", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+					field("verbose", 2, descriptorpb.FieldDescriptorProto_TYPE_BOOL),
+				},
+			},
+			{
+				Name: proto.String("User"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("id", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+					field("name", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+				},
+			},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Users"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				{
+					Name:       proto.String("GetUser"),
+					InputType:  proto.String(".users.v1.GetUserRequest"),
+					OutputType: proto.String(".users.v1.User"),
+				},
+				{
+					Name:       proto.String("CreateUser"),
+					InputType:  proto.String(".users.v1.User"),
+					OutputType: proto.String(".users.v1.User"),
+				},
+				{
+					Name:            proto.String("WatchUsers"),
+					InputType:       proto.String(".users.v1.GetUserRequest"),
+					OutputType:      proto.String(".users.v1.User"),
+					ServerStreaming: proto.Bool(true),
+				},
+			},
+		}},
+	}
+}
+
+// writeDescriptorSet writes the descriptor set of the test service and returns its path
+func writeDescriptorSet(t *testing.T, dir string) string {
+	data, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{testFile()}})
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "users.pb")
+	require.NoError(t, ioutil.WriteFile(path, data, 0644))
+
+	return path
+}
+
+// startTestServer starts the gRPC server of the test service, GetUser returns the user of the request
+// id with the value of the x-name metadata as its name, the users not found are the ones with an empty id
+func startTestServer(t *testing.T) (*grpc.Server, string) {
+	file, err := protodesc.NewFile(testFile(), nil)
+	require.NoError(t, err)
+	service := file.Services().ByName("Users")
+
+	server := grpc.NewServer(grpc.UnknownServiceHandler(func(srv interface{}, stream grpc.ServerStream) error {
+		name, _ := grpc.MethodFromServerStream(stream)
+		method := service.Methods().ByName(protoreflect.Name(filepath.Base(name)))
+
+		req := dynamicpb.NewMessage(method.Input())
+		if err := stream.RecvMsg(req); err != nil {
+			return err
+		}
+
+		id := req.Get(method.Input().Fields().ByName("id")).String()
+		if id == "" {
+			return status.Error(codes.NotFound, "user not found")
+		}
+
+		md, _ := metadata.FromIncomingContext(stream.Context())
+		stream.SetHeader(metadata.Pairs("x-user-id", id))
+
+		user := dynamicpb.NewMessage(method.Output())
+		user.Set(user.Descriptor().Fields().ByName("id"), protoreflect.ValueOfString(id))
+		name = strings.Join(md.Get("x-name"), ",")
+		if verbose := method.Input().Fields().ByName("verbose"); verbose != nil && req.Get(verbose).Bool() {
+			name += " (verbose)"
+		}
+		user.Set(user.Descriptor().Fields().ByName("name"), protoreflect.ValueOfString(name))
+
+		return stream.SendMsg(user)
+	}))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go server.Serve(listener)
+
+	return server, listener.Addr().String()
+}
+
+func TestSetup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "transcoding")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	def := proxy.NewRouterDefinition(proxy.NewDefinition())
+	err = setupTranscoding(def, plugin.Config{
+		"descriptor_set": writeDescriptorSet(t, dir),
+		"target":         "127.0.0.1:50051",
+		"routes": []interface{}{
+			map[string]interface{}{"method": "GET", "path": "/users/{id}", "grpc_method": "users.v1.Users/GetUser"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Len(t, def.Middleware(), 1)
+
+	conn, err := dial("127.0.0.1:50051", false)
+	require.NoError(t, err)
+	assert.Equal(t, conns["127.0.0.1:50051"], conn, "the connections are shared")
+	conn.Close()
+	delete(conns, "127.0.0.1:50051")
+}
+
+func TestValidateConfigInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "transcoding")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	descriptorSet := writeDescriptorSet(t, dir)
+
+	route := func(grpcMethod, path string) []interface{} {
+		return []interface{}{map[string]interface{}{"method": "GET", "path": path, "grpc_method": grpcMethod}}
+	}
+	invalid := map[string]plugin.Config{
+		"no descriptor set":   {"target": "localhost:50051", "routes": route("users.v1.Users/GetUser", "/users")},
+		"no target":           {"descriptor_set": descriptorSet, "routes": route("users.v1.Users/GetUser", "/users")},
+		"no routes":           {"descriptor_set": descriptorSet, "target": "localhost:50051"},
+		"missing file":        {"descriptor_set": filepath.Join(dir, "missing.pb"), "target": "localhost:50051", "routes": route("users.v1.Users/GetUser", "/users")},
+		"unknown service":     {"descriptor_set": descriptorSet, "target": "localhost:50051", "routes": route("users.v1.Groups/GetUser", "/users")},
+		"unknown method":      {"descriptor_set": descriptorSet, "target": "localhost:50051", "routes": route("users.v1.Users/DeleteUser", "/users")},
+		"not a service":       {"descriptor_set": descriptorSet, "target": "localhost:50051", "routes": route("users.v1.User/GetUser", "/users")},
+		"streaming method":    {"descriptor_set": descriptorSet, "target": "localhost:50051", "routes": route("users.v1.Users/WatchUsers", "/users")},
+		"relative route path": {"descriptor_set": descriptorSet, "target": "localhost:50051", "routes": route("users.v1.Users/GetUser", "users")},
+		"max body size":       {"descriptor_set": descriptorSet, "target": "localhost:50051", "routes": route("users.v1.Users/GetUser", "/users"), "max_body_size": "large"},
+	}
+
+	for name, config := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := validateConfig(config)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestFindMethod(t *testing.T) {
+	dir, err := ioutil.TempDir("", "transcoding")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	files, err := loadDescriptorSet(writeDescriptorSet(t, dir))
+	require.NoError(t, err)
+
+	for _, name := range []string{"users.v1.Users/GetUser", "/users.v1.Users/GetUser", "users.v1.Users.GetUser"} {
+		method, err := findMethod(files, name)
+		require.NoError(t, err)
+		assert.Equal(t, protoreflect.FullName("users.v1.Users.GetUser"), method.FullName())
+	}
+}
@@ -0,0 +1,88 @@
+package useragent
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/hellofresh/janus/pkg/errors"
+	"github.com/hellofresh/janus/pkg/middleware"
+	"github.com/hellofresh/janus/pkg/proxy"
+	log "github.com/sirupsen/logrus"
+)
+
+type matcher struct {
+	contains string
+	regex    *regexp.Regexp
+}
+
+func (m matcher) match(userAgent, lowered string) bool {
+	if m.regex != nil {
+		return m.regex.MatchString(userAgent)
+	}
+
+	return strings.Contains(lowered, m.contains)
+}
+
+// UserAgentBlock blocks the requests of the matching user agents, e.g. known scrapers, or sends them
+// to the tarpit upstream
+type UserAgentBlock struct {
+	matchers   []matcher
+	err        *errors.Error
+	tarpit     proxy.Targets
+	blockEmpty bool
+}
+
+// NewUserAgentBlock creates a new instance of UserAgentBlock
+func NewUserAgentBlock(config Config) (*UserAgentBlock, error) {
+	matchers, err := compilePatterns(config.Patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserAgentBlock{
+		matchers:   matchers,
+		err:        errors.New(config.Status, http.StatusText(config.Status)),
+		tarpit:     config.Tarpit,
+		blockEmpty: config.Empty == EmptyBlock,
+	}, nil
+}
+
+// Handler is the middleware function
+func (m *UserAgentBlock) Handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent := r.UserAgent()
+		if !m.blocked(userAgent) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		middleware.ContextLogger(r.Context()).WithFields(log.Fields{
+			"user_agent":  userAgent,
+			"request_uri": r.RequestURI,
+			"tarpit":      len(m.tarpit) > 0,
+		}).Debug("Blocked user agent")
+
+		if len(m.tarpit) > 0 {
+			handler.ServeHTTP(w, r.WithContext(proxy.WithUpstreamTargets(r.Context(), m.tarpit)))
+			return
+		}
+
+		errors.Handler(w, m.err)
+	})
+}
+
+func (m *UserAgentBlock) blocked(userAgent string) bool {
+	if strings.TrimSpace(userAgent) == "" {
+		return m.blockEmpty
+	}
+
+	lowered := strings.ToLower(userAgent)
+	for _, matcher := range m.matchers {
+		if matcher.match(userAgent, lowered) {
+			return true
+		}
+	}
+
+	return false
+}
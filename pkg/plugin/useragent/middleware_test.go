@@ -0,0 +1,85 @@
+package useragent
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serve(t *testing.T, config Config, userAgent string) (int, *http.Request) {
+	block, err := NewUserAgentBlock(config)
+	require.NoError(t, err)
+
+	var proxied *http.Request
+	handler := block.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("User-Agent", userAgent)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	return w.Code, proxied
+}
+
+func TestUserAgentBlockPatterns(t *testing.T) {
+	config := Config{
+		Patterns: []Pattern{{Contains: "Scrapy"}, {Regex: `^python-requests/\d`}},
+		Status:   http.StatusForbidden,
+		Empty:    EmptyAllow,
+	}
+
+	code, proxied := serve(t, config, "Mozilla/5.0 (X11; Linux x86_64)")
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotNil(t, proxied)
+
+	code, proxied = serve(t, config, "scrapy/2.5 (+https://scrapy.org)")
+	assert.Equal(t, http.StatusForbidden, code, "the substrings match case-insensitively")
+	assert.Nil(t, proxied)
+
+	code, _ = serve(t, config, "python-requests/2.25.1")
+	assert.Equal(t, http.StatusForbidden, code)
+
+	code, _ = serve(t, config, "Python-Requests/2.25.1")
+	assert.Equal(t, http.StatusOK, code, "the regular expressions match as written")
+}
+
+func TestUserAgentBlockStatus(t *testing.T) {
+	config := Config{Patterns: []Pattern{{Contains: "curl"}}, Status: http.StatusTooManyRequests, Empty: EmptyAllow}
+
+	code, _ := serve(t, config, "curl/7.64.1")
+	assert.Equal(t, http.StatusTooManyRequests, code)
+}
+
+func TestUserAgentBlockEmpty(t *testing.T) {
+	config := Config{Patterns: []Pattern{{Contains: "curl"}}, Status: http.StatusForbidden, Empty: EmptyAllow}
+
+	code, _ := serve(t, config, "")
+	assert.Equal(t, http.StatusOK, code)
+
+	config.Empty = EmptyBlock
+	code, _ = serve(t, config, " ")
+	assert.Equal(t, http.StatusForbidden, code)
+}
+
+func TestUserAgentBlockTarpit(t *testing.T) {
+	tarpit := proxy.Targets{{Target: "http://tarpit.example.com"}}
+	config := Config{Patterns: []Pattern{{Contains: "curl"}}, Status: http.StatusForbidden, Tarpit: tarpit, Empty: EmptyAllow}
+
+	code, proxied := serve(t, config, "curl/7.64.1")
+	assert.Equal(t, http.StatusOK, code)
+	require.NotNil(t, proxied)
+	targets, ok := proxy.UpstreamTargetsFromContext(proxied.Context())
+	assert.True(t, ok)
+	assert.Equal(t, tarpit, targets)
+
+	_, proxied = serve(t, config, "Mozilla/5.0")
+	require.NotNil(t, proxied)
+	_, ok = proxy.UpstreamTargetsFromContext(proxied.Context())
+	assert.False(t, ok, "the allowed requests keep the upstreams of the API")
+}
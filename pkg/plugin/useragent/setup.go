@@ -0,0 +1,116 @@
+package useragent
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/hellofresh/janus/pkg/plugin"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/pkg/errors"
+)
+
+const (
+	// EmptyAllow lets the requests without a user agent through
+	EmptyAllow = "allow"
+	// EmptyBlock blocks the requests without a user agent like the matching ones
+	EmptyBlock = "block"
+)
+
+// Pattern is a user agent rule, the user agent must contain the value, case-insensitively, or
+// match the regular expression
+type Pattern struct {
+	Contains string `json:"contains"`
+	Regex    string `json:"regex"`
+}
+
+// Config represents the user agent blocking configuration
+type Config struct {
+	Patterns []Pattern `json:"patterns"`
+	// Status is the status code of the blocked requests, 403 or 429
+	Status int `json:"status"`
+	// Tarpit are the upstream targets the blocked requests are proxied to instead of being rejected
+	Tarpit proxy.Targets `json:"tarpit"`
+	// Empty is the policy of the requests with an empty or missing user agent, allow or block
+	Empty string `json:"empty"`
+}
+
+func init() {
+	plugin.RegisterPlugin("user_agent_block", plugin.Plugin{
+		Action:   setupUserAgentBlock,
+		Validate: validateConfig,
+	})
+}
+
+func setupUserAgentBlock(def *proxy.RouterDefinition, rawConfig plugin.Config) error {
+	config, err := decodeConfig(rawConfig)
+	if err != nil {
+		return err
+	}
+
+	block, err := NewUserAgentBlock(config)
+	if err != nil {
+		return err
+	}
+
+	def.AddMiddleware(block.Handler)
+	return nil
+}
+
+func validateConfig(rawConfig plugin.Config) (bool, error) {
+	config, err := decodeConfig(rawConfig)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := compilePatterns(config.Patterns); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func decodeConfig(rawConfig plugin.Config) (Config, error) {
+	config := Config{Status: http.StatusForbidden, Empty: EmptyAllow}
+	if err := plugin.Decode(rawConfig, &config); err != nil {
+		return config, err
+	}
+
+	if config.Status != http.StatusForbidden && config.Status != http.StatusTooManyRequests {
+		return config, errors.Errorf("user agent block status must be 403 or 429, got %d", config.Status)
+	}
+	if config.Empty != EmptyAllow && config.Empty != EmptyBlock {
+		return config, errors.Errorf("user agent empty policy must be allow or block, got %q", config.Empty)
+	}
+
+	for _, target := range config.Tarpit {
+		if _, err := govalidator.ValidateStruct(target); err != nil {
+			return config, errors.Wrap(err, "invalid tarpit target")
+		}
+	}
+
+	return config, nil
+}
+
+func compilePatterns(patterns []Pattern) ([]matcher, error) {
+	matchers := make([]matcher, 0, len(patterns))
+	for _, pattern := range patterns {
+		if (pattern.Contains == "") == (pattern.Regex == "") {
+			return nil, errors.New("user agent pattern must set one of contains or regex")
+		}
+
+		if pattern.Regex == "" {
+			matchers = append(matchers, matcher{contains: strings.ToLower(pattern.Contains)})
+			continue
+		}
+
+		regex, err := regexp.Compile(pattern.Regex)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not compile the user agent regular expression %q", pattern.Regex)
+		}
+		matchers = append(matchers, matcher{regex: regex})
+	}
+
+	return matchers, nil
+}
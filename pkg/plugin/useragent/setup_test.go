@@ -0,0 +1,52 @@
+package useragent
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/hellofresh/janus/pkg/plugin"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeConfig(t *testing.T) {
+	config, err := decodeConfig(plugin.Config{
+		"patterns": []interface{}{map[string]interface{}{"contains": "curl"}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusForbidden, config.Status)
+	assert.Equal(t, EmptyAllow, config.Empty)
+	require.Len(t, config.Patterns, 1)
+	assert.Equal(t, "curl", config.Patterns[0].Contains)
+}
+
+func TestValidateConfigInvalid(t *testing.T) {
+	invalid := map[string]plugin.Config{
+		"status":        {"status": 404},
+		"empty policy":  {"empty": "deny"},
+		"empty pattern": {"patterns": []interface{}{map[string]interface{}{}}},
+		"both patterns": {"patterns": []interface{}{map[string]interface{}{"contains": "curl", "regex": "curl"}}},
+		"regex":         {"patterns": []interface{}{map[string]interface{}{"regex": "("}}},
+		"tarpit":        {"tarpit": []interface{}{map[string]interface{}{"target": ""}}},
+	}
+
+	for name, config := range invalid {
+		t.Run(name, func(t *testing.T) {
+			isValid, err := validateConfig(config)
+			assert.False(t, isValid)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSetup(t *testing.T) {
+	def := proxy.NewRouterDefinition(proxy.NewDefinition())
+	err := setupUserAgentBlock(def, plugin.Config{
+		"patterns": []interface{}{map[string]interface{}{"regex": "(?i)bot"}},
+		"status":   429,
+	})
+	require.NoError(t, err)
+	assert.Len(t, def.Middleware(), 1)
+}
@@ -0,0 +1,112 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	httpErrors "github.com/hellofresh/janus/pkg/errors"
+)
+
+// ErrRequestBodyTooLarge answers the requests whose body is larger than the request body size buffered
+// by their API
+var ErrRequestBodyTooLarge = httpErrors.New(http.StatusRequestEntityTooLarge, "request body is larger than the max size")
+
+// streamedBody is a request body partly read while trying to buffer it, the bytes read are sent first
+// and the rest is streamed from the original body
+type streamedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// BufferBody reads the request body in memory when it is not larger than the limit, so it can be read
+// again with RewindBody. The proxy streams the request bodies to the upstreams, the plugins needing
+// the whole body, e.g. to send it again, opt in to buffering it with this function. The larger bodies
+// are left to be streamed, the bytes read so far included, and false is returned. The requests without
+// a body are reported as buffered.
+func BufferBody(r *http.Request, limit int64) (bool, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return true, nil
+	}
+	if r.ContentLength > limit {
+		return false, nil
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		return false, err
+	}
+
+	if int64(len(body)) > limit {
+		r.Body = &streamedBody{Reader: io.MultiReader(bytes.NewReader(body), r.Body), Closer: r.Body}
+		return false, nil
+	}
+
+	r.Body.Close()
+	r.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	r.Body, _ = r.GetBody()
+
+	return true, nil
+}
+
+// RewindBody resets the request body buffered with BufferBody to its beginning
+func RewindBody(r *http.Request) error {
+	if r.GetBody == nil {
+		return nil
+	}
+
+	body, err := r.GetBody()
+	if err != nil {
+		return err
+	}
+	r.Body = body
+
+	return nil
+}
+
+// BufferRequests sends the request bodies with a Content-Length, for the upstreams rejecting the chunked
+// transfer encoding. The bodies without a Content-Length are read in memory up to the max size and the
+// larger bodies are answered with 413 Request Entity Too Large, the loader adds it to the APIs setting
+// a request body size to buffer.
+func BufferRequests(maxSize int64) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > maxSize {
+				httpErrors.Handler(w, ErrRequestBodyTooLarge)
+				return
+			}
+			if r.ContentLength >= 0 || r.Body == nil || r.Body == http.NoBody {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxSize+1))
+			r.Body.Close()
+			// the client failed to send its body, e.g. it closed the connection
+			if err != nil {
+				httpErrors.Handler(w, httpErrors.New(http.StatusBadRequest, err.Error()))
+				return
+			}
+			if int64(len(body)) > maxSize {
+				httpErrors.Handler(w, ErrRequestBodyTooLarge)
+				return
+			}
+
+			r.ContentLength = int64(len(body))
+			r.TransferEncoding = nil
+			r.GetBody = func() (io.ReadCloser, error) {
+				return ioutil.NopCloser(bytes.NewReader(body)), nil
+			}
+			if len(body) == 0 {
+				r.Body, r.GetBody = http.NoBody, nil
+			} else {
+				r.Body, _ = r.GetBody()
+			}
+
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/hellofresh/janus/pkg/router"
+	"github.com/hellofresh/stats-go/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// zeroReader is an endless body that does not hold its bytes in memory
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func TestBufferBody(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello"))
+
+	buffered, err := BufferBody(r, 5)
+	require.NoError(t, err)
+	assert.True(t, buffered)
+
+	for i := 0; i < 2; i++ {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "hello", string(body))
+		require.NoError(t, RewindBody(r))
+	}
+}
+
+func TestBufferBodyTooLarge(t *testing.T) {
+	// the content length is unknown, the body is read up to the limit
+	r := httptest.NewRequest(http.MethodPost, "/", ioutil.NopCloser(strings.NewReader("hello world")))
+	r.ContentLength = -1
+
+	buffered, err := BufferBody(r, 5)
+	require.NoError(t, err)
+	assert.False(t, buffered)
+	assert.Nil(t, r.GetBody)
+
+	body, err := ioutil.ReadAll(r.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(body), "the bytes read are streamed with the rest of the body")
+
+	r = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString("hello world"))
+	buffered, err = BufferBody(r, 5)
+	require.NoError(t, err)
+	assert.False(t, buffered, "the content length is over the limit")
+}
+
+func TestBufferBodyEmpty(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	buffered, err := BufferBody(r, 5)
+	require.NoError(t, err)
+	assert.True(t, buffered)
+	assert.NoError(t, RewindBody(r))
+}
+
+func TestProxyStreamsRequestBody(t *testing.T) {
+	if testing.Short() {
+		t.Skip("the upload is slow, skipped in short mode")
+	}
+
+	const size = 300 << 20
+
+	var received int64
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = io.Copy(ioutil.Discard, r.Body)
+	}))
+	defer upstream.Close()
+
+	r := router.NewChiRouter()
+	register := NewRegister(WithRouter(r), WithStatsClient(client.NewNoop()))
+	def := NewDefinition()
+	def.ListenPath = "/upload"
+	def.Methods = []string{http.MethodPost}
+	def.Upstreams = &Upstreams{Balancing: "roundrobin", Targets: []*Target{{Target: upstream.URL}}}
+	require.NoError(t, register.Add(NewRouterDefinition(def)))
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", ioutil.NopCloser(io.LimitReader(zeroReader{}, size)))
+	req.ContentLength = size
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	runtime.ReadMemStats(&after)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int64(size), received)
+	// the upload and the upstream server allocate their copy buffers, far less than the body
+	assert.True(t, after.TotalAlloc-before.TotalAlloc < 32<<20, "%d bytes allocated to proxy the body", after.TotalAlloc-before.TotalAlloc)
+}
+
+func TestBufferRequests(t *testing.T) {
+	// the legacy upstream requires a Content-Length, as the servers answering 411 Length Required to
+	// the chunked bodies
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength < 0 || len(r.TransferEncoding) > 0 {
+			w.WriteHeader(http.StatusLengthRequired)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("X-Content-Length", r.Header.Get("Content-Length"))
+		w.Write(body)
+	}))
+	defer upstream.Close()
+
+	newGateway := func(maxSize int64) http.Handler {
+		r := router.NewChiRouter()
+		register := NewRegister(WithRouter(r), WithStatsClient(client.NewNoop()))
+		def := NewDefinition()
+		def.ListenPath = "/upload"
+		def.Methods = []string{http.MethodPost}
+		def.Upstreams = &Upstreams{Balancing: "roundrobin", Targets: []*Target{{Target: upstream.URL}}}
+		routerDef := NewRouterDefinition(def)
+		if maxSize > 0 {
+			routerDef.AddMiddleware(BufferRequests(maxSize))
+		}
+		require.NoError(t, register.Add(routerDef))
+
+		return r
+	}
+	post := func(gateway http.Handler, body string, chunked bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/upload", ioutil.NopCloser(strings.NewReader(body)))
+		req.ContentLength = int64(len(body))
+		if chunked {
+			req.ContentLength = -1
+			req.TransferEncoding = []string{"chunked"}
+		}
+		w := httptest.NewRecorder()
+		gateway.ServeHTTP(w, req)
+		return w
+	}
+
+	gateway := newGateway(0)
+	assert.Equal(t, http.StatusLengthRequired, post(gateway, "hello", true).Code, "the bodies are streamed by default")
+	assert.Equal(t, http.StatusOK, post(gateway, "hello", false).Code)
+
+	gateway = newGateway(1024)
+	w := post(gateway, "hello", true)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "hello", w.Body.String())
+	assert.Equal(t, "5", w.Header().Get("X-Content-Length"))
+
+	w = post(gateway, "", true)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0", w.Header().Get("X-Content-Length"))
+
+	assert.Equal(t, http.StatusOK, post(gateway, strings.Repeat("a", 1024), true).Code)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, post(gateway, strings.Repeat("a", 1025), true).Code)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, post(gateway, strings.Repeat("a", 1025), false).Code)
+}
@@ -0,0 +1,121 @@
+package proxy
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Buffering represents how the requests and the responses of an API are buffered between the gateway
+// and the upstreams, the zero values fall back to the global defaults
+type Buffering struct {
+	// ReadBufferSize is the size in bytes of the buffer the upstream connections are read with
+	ReadBufferSize int `bson:"read_buffer_size" json:"read_buffer_size,omitempty"`
+	// FlushBufferSize is the size in bytes of the buffer the upstream response bodies are copied to the
+	// clients with
+	FlushBufferSize int `bson:"flush_buffer_size" json:"flush_buffer_size,omitempty"`
+	// FlushInterval is the interval the buffered response bodies are flushed to the clients at
+	FlushInterval Duration `bson:"flush_interval" json:"flush_interval,omitempty"`
+	// Stream disables the response buffering, the bodies are flushed to the clients after each write.
+	// The plugins modifying the response bodies are not applied to the streamed APIs.
+	Stream *bool `bson:"stream" json:"stream,omitempty"`
+	// MaxResponseBodySize is the largest upstream response body in bytes, it is enforced when a plugin
+	// of the API buffers the bodies and only logged for the other APIs. The bodies are not capped when
+	// it is not set.
+	MaxResponseBodySize int64 `bson:"max_response_body_size" json:"max_response_body_size,omitempty"`
+	// BufferRequestBodySize is the largest request body in bytes buffered to send the chunked bodies with
+	// a Content-Length, for the upstreams rejecting the chunked transfer encoding. The larger bodies are
+	// rejected, and the bodies are streamed when it is not set.
+	BufferRequestBodySize int64 `bson:"buffer_request_body_size" json:"buffer_request_body_size,omitempty"`
+}
+
+func (b Buffering) validate() error {
+	if b.ReadBufferSize < 0 {
+		return errors.New("proxy.buffering read_buffer_size can not be negative")
+	}
+	if b.FlushBufferSize < 0 {
+		return errors.New("proxy.buffering flush_buffer_size can not be negative")
+	}
+	if b.FlushInterval < 0 {
+		return errors.New("proxy.buffering flush_interval can not be negative")
+	}
+	if b.MaxResponseBodySize < 0 {
+		return errors.New("proxy.buffering max_response_body_size can not be negative")
+	}
+	if b.BufferRequestBodySize < 0 {
+		return errors.New("proxy.buffering buffer_request_body_size can not be negative")
+	}
+
+	return nil
+}
+
+// merge returns the settings of the definition with the unset ones taken from the defaults
+func (b Buffering) merge(defaults Buffering) Buffering {
+	if b.ReadBufferSize == 0 {
+		b.ReadBufferSize = defaults.ReadBufferSize
+	}
+	if b.FlushBufferSize == 0 {
+		b.FlushBufferSize = defaults.FlushBufferSize
+	}
+	if b.FlushInterval == 0 {
+		b.FlushInterval = defaults.FlushInterval
+	}
+	if b.Stream == nil {
+		b.Stream = defaults.Stream
+	}
+	if b.MaxResponseBodySize == 0 {
+		b.MaxResponseBodySize = defaults.MaxResponseBodySize
+	}
+	if b.BufferRequestBodySize == 0 {
+		b.BufferRequestBodySize = defaults.BufferRequestBodySize
+	}
+
+	return b
+}
+
+// Streamed tells if the response buffering is disabled
+func (b Buffering) Streamed() bool {
+	return b.Stream != nil && *b.Stream
+}
+
+// flushInterval returns the flush interval of the reverse proxy, a negative interval flushes after
+// each write
+func (b Buffering) flushInterval() time.Duration {
+	if b.Streamed() {
+		return -1
+	}
+	return time.Duration(b.FlushInterval)
+}
+
+// bufferPool is the httputil.BufferPool of the buffers the response bodies are copied with
+type bufferPool struct {
+	pool sync.Pool
+}
+
+var (
+	bufferPoolsMu sync.Mutex
+	bufferPools   = make(map[int]*bufferPool)
+)
+
+// getBufferPool returns the pool of the buffers of the size, the APIs with the same size share it
+func getBufferPool(size int) *bufferPool {
+	bufferPoolsMu.Lock()
+	defer bufferPoolsMu.Unlock()
+
+	p, ok := bufferPools[size]
+	if !ok {
+		p = &bufferPool{pool: sync.Pool{New: func() interface{} { return make([]byte, size) }}}
+		bufferPools[size] = p
+	}
+	return p
+}
+
+// Get returns a buffer from the pool
+func (p *bufferPool) Get() []byte {
+	return p.pool.Get().([]byte)
+}
+
+// Put returns the buffer to the pool
+func (p *bufferPool) Put(b []byte) {
+	p.pool.Put(b)
+}
@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hellofresh/janus/pkg/router"
+	"github.com/hellofresh/stats-go/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBufferingValidate(t *testing.T) {
+	assert.NoError(t, Buffering{ReadBufferSize: 8192, FlushBufferSize: 65536, FlushInterval: Duration(time.Second)}.validate())
+	assert.Error(t, Buffering{ReadBufferSize: -1}.validate())
+	assert.Error(t, Buffering{FlushBufferSize: -1}.validate())
+	assert.Error(t, Buffering{FlushInterval: Duration(-time.Second)}.validate())
+	assert.Error(t, Buffering{MaxResponseBodySize: -1}.validate())
+	assert.Error(t, Buffering{BufferRequestBodySize: -1}.validate())
+
+	def := NewDefinition()
+	def.ListenPath = "/example"
+	def.Buffering.FlushBufferSize = -1
+	ok, err := def.Validate()
+	assert.False(t, ok)
+	assert.Error(t, err)
+}
+
+func TestRegisterBuffering(t *testing.T) {
+	stream := true
+	register := NewRegister(
+		WithFlushInterval(20*time.Millisecond),
+		WithBuffering(Buffering{ReadBufferSize: 8192, FlushBufferSize: 65536, MaxResponseBodySize: 1 << 20, BufferRequestBodySize: 1 << 10}),
+	)
+
+	def := NewDefinition()
+	buffering := register.Buffering(def)
+	assert.Equal(t, 8192, buffering.ReadBufferSize)
+	assert.Equal(t, 65536, buffering.FlushBufferSize)
+	assert.Equal(t, int64(1<<20), buffering.MaxResponseBodySize)
+	assert.Equal(t, int64(1<<10), buffering.BufferRequestBodySize)
+	assert.Equal(t, Duration(20*time.Millisecond), buffering.FlushInterval, "the global flush interval is the default")
+	assert.False(t, buffering.Streamed())
+	assert.Equal(t, 20*time.Millisecond, buffering.flushInterval())
+
+	def.Buffering = Buffering{ReadBufferSize: 1024, FlushInterval: Duration(time.Second), Stream: &stream}
+	buffering = register.Buffering(def)
+	assert.Equal(t, 1024, buffering.ReadBufferSize)
+	assert.Equal(t, 65536, buffering.FlushBufferSize)
+	assert.True(t, buffering.Streamed())
+	assert.Equal(t, time.Duration(-1), buffering.flushInterval(), "the streamed responses are flushed after each write")
+
+	global, stream := true, false
+	buffering = NewRegister(WithBuffering(Buffering{Stream: &global})).Buffering(def)
+	assert.False(t, buffering.Streamed(), "the definition overrides the global stream setting")
+}
+
+func TestBufferPool(t *testing.T) {
+	pool := getBufferPool(1024)
+	assert.Same(t, pool, getBufferPool(1024), "the pools are shared by the sizes")
+
+	b := pool.Get()
+	assert.Len(t, b, 1024)
+	pool.Put(b)
+}
+
+func TestStreamedResponse(t *testing.T) {
+	read := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "12")
+		w.Write([]byte("first"))
+		w.(http.Flusher).Flush()
+		<-read
+		w.Write([]byte(" second"))
+	}))
+	defer upstream.Close()
+
+	stream := true
+	r := router.NewChiRouter()
+	register := NewRegister(WithRouter(r), WithStatsClient(client.NewNoop()), WithFlushInterval(time.Hour))
+	def := NewDefinition()
+	def.ListenPath = "/stream"
+	def.Upstreams = &Upstreams{Balancing: "roundrobin", Targets: []*Target{{Target: upstream.URL}}}
+	def.Buffering = Buffering{FlushBufferSize: 4096, Stream: &stream}
+	require.NoError(t, register.Add(NewRouterDefinition(def)))
+
+	gateway := httptest.NewServer(r)
+	defer gateway.Close()
+
+	httpClient := &http.Client{Timeout: 5 * time.Second}
+	res, err := httpClient.Get(gateway.URL + "/stream")
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	first := make([]byte, 5)
+	_, err = io.ReadFull(res.Body, first)
+	require.NoError(t, err, "the first write is flushed before the upstream response is complete")
+	assert.Equal(t, "first", string(first))
+	close(read)
+
+	rest, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Equal(t, " second", string(rest))
+}
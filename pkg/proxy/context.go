@@ -0,0 +1,57 @@
+package proxy
+
+import "context"
+
+type contextKey int
+
+const (
+	upstreamTargetsKey contextKey = iota
+	variantKey
+	electedKey
+	webSocketKey
+	decompressionKey
+	listenPathKey
+	electionErrorKey
+	responseLimitKey
+	listenerKey
+)
+
+// WithUpstreamTargets returns a copy of the context overriding the upstream targets the request is
+// balanced between, e.g. the targets of an experiment variant chosen by a plugin
+func WithUpstreamTargets(ctx context.Context, targets Targets) context.Context {
+	return context.WithValue(ctx, upstreamTargetsKey, targets)
+}
+
+// UpstreamTargetsFromContext returns the upstream targets overridden by WithUpstreamTargets
+func UpstreamTargetsFromContext(ctx context.Context) (Targets, bool) {
+	targets, ok := ctx.Value(upstreamTargetsKey).(Targets)
+	return targets, ok && len(targets) > 0
+}
+
+// WithVariant returns a copy of the context holding the experiment variant of the request, it is
+// added to the proxied request trace
+func WithVariant(ctx context.Context, variant string) context.Context {
+	return context.WithValue(ctx, variantKey, variant)
+}
+
+// VariantFromContext returns the experiment variant set by WithVariant
+func VariantFromContext(ctx context.Context) string {
+	variant, _ := ctx.Value(variantKey).(string)
+	return variant
+}
+
+// WithListener returns a copy of the context holding the name of the proxy listener the request was
+// received on, only the routes of the definitions bound to it match the request
+func WithListener(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, listenerKey, name)
+}
+
+// ListenerFromContext returns the listener set by WithListener, the requests without one were received
+// on the default listener
+func ListenerFromContext(ctx context.Context) string {
+	if name, ok := ctx.Value(listenerKey).(string); ok {
+		return name
+	}
+
+	return DefaultListener
+}
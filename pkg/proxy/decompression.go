@@ -0,0 +1,211 @@
+package proxy
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hellofresh/janus/pkg/middleware"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultDecompressionMaxSize is the largest decoded response body when the API does not set one
+const DefaultDecompressionMaxSize = 10 << 20
+
+// ErrDecodedBodyTooLarge is returned by the decoded response bodies larger than the max size, the
+// response is aborted as its headers are already sent
+var ErrDecodedBodyTooLarge = errors.New("decoded response body is larger than the max size")
+
+// Decompression represents how the compressed upstream responses of an API are decoded before the
+// plugins reading their bodies, e.g. to rewrite them. The responses are decoded when it is enabled or
+// when an enabled plugin of the API needs the decoded bodies, and are sent to the clients decoded, the
+// compression plugin encodes them again.
+type Decompression struct {
+	// Enabled decodes the responses even when no plugin of the API needs the decoded bodies
+	Enabled bool `bson:"enabled" json:"enabled"`
+	// MaxSize is the largest decoded body in bytes, it bounds the bodies inflating far beyond their
+	// encoded size
+	MaxSize int64 `bson:"max_size" json:"max_size,omitempty"`
+}
+
+func (d Decompression) validate() error {
+	if d.MaxSize < 0 {
+		return errors.New("proxy.decompression max_size can not be negative")
+	}
+
+	return nil
+}
+
+func (d Decompression) maxSize() int64 {
+	if d.MaxSize == 0 {
+		return DefaultDecompressionMaxSize
+	}
+	return d.MaxSize
+}
+
+// Decoder creates the reader decoding a response body compressed with a content encoding
+type Decoder func(body io.Reader) (io.ReadCloser, error)
+
+var (
+	decodersMu sync.RWMutex
+	decoders   = map[string]Decoder{
+		"gzip":    newGzipDecoder,
+		"deflate": newDeflateDecoder,
+	}
+)
+
+// RegisterDecoder registers the decoder of the content encoding, e.g. br. The responses with an encoding
+// having no decoder are passed to the plugins and the clients encoded.
+func RegisterDecoder(encoding string, decoder Decoder) {
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+
+	decoders[strings.ToLower(encoding)] = decoder
+}
+
+func decoderOf(encoding string) (Decoder, bool) {
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+
+	decoder, ok := decoders[encoding]
+	return decoder, ok
+}
+
+// acceptedEncodings returns the Accept-Encoding header of the upstream requests of the decoding APIs,
+// the upstreams are asked only for the encodings that can be decoded
+func acceptedEncodings() string {
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+
+	encodings := make([]string, 0, len(decoders))
+	for encoding := range decoders {
+		encodings = append(encodings, encoding)
+	}
+	sort.Strings(encodings)
+
+	return strings.Join(encodings, ", ")
+}
+
+func newGzipDecoder(body io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(body)
+}
+
+// newDeflateDecoder decodes the zlib format the deflate encoding stands for, the raw deflate streams sent
+// by some servers are decoded as well
+func newDeflateDecoder(body io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(body)
+	header, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// DecodeResponses decodes the compressed upstream responses of the requests, the loader adds it to the
+// APIs needing the decoded bodies
+func DecodeResponses(config Decompression) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), decompressionKey, config)
+			handler.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func decompressionFromContext(ctx context.Context) (Decompression, bool) {
+	config, ok := ctx.Value(decompressionKey).(Decompression)
+	return config, ok
+}
+
+// decodeResponse is the reverse proxy response modifier replacing the compressed body of the response
+// with the decoded one. The responses with several encodings, or one without a decoder, are left encoded.
+func decodeResponse(resp *http.Response) error {
+	config, ok := decompressionFromContext(resp.Request.Context())
+	if !ok || !hasBody(resp) {
+		return nil
+	}
+
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if encoding == "" || encoding == "identity" {
+		return nil
+	}
+
+	decoder, ok := decoderOf(encoding)
+	if !ok {
+		middleware.ContextLogger(resp.Request.Context()).WithField("encoding", encoding).Debug("No decoder for the response encoding, passing it encoded")
+		return nil
+	}
+
+	decoded, err := decoder(resp.Body)
+	switch {
+	case err == io.EOF:
+		// the empty body is not encoded
+		resp.Body.Close()
+		resp.Body = http.NoBody
+	case err != nil:
+		resp.Body.Close()
+		return err
+	default:
+		resp.Body = &decodedBody{decoded: decoded, body: resp.Body, remaining: config.maxSize()}
+	}
+
+	log.WithField("encoding", encoding).Debug("Decoding the upstream response")
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	// the strong validator of the encoded body does not match the decoded one
+	if etag := resp.Header.Get("ETag"); strings.HasPrefix(etag, `"`) {
+		resp.Header.Set("ETag", "W/"+etag)
+	}
+
+	return nil
+}
+
+func hasBody(resp *http.Response) bool {
+	if resp.Request.Method == http.MethodHead || resp.Body == nil || resp.Body == http.NoBody {
+		return false
+	}
+
+	return resp.StatusCode >= http.StatusOK && resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotModified
+}
+
+// decodedBody reads the decoded upstream body, and fails once more than the max size is decoded
+type decodedBody struct {
+	decoded   io.ReadCloser
+	body      io.Closer
+	remaining int64
+}
+
+func (b *decodedBody) Read(p []byte) (int, error) {
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+
+	n, err := b.decoded.Read(p)
+	if int64(n) > b.remaining {
+		n = int(b.remaining)
+		b.remaining = 0
+		return n, ErrDecodedBodyTooLarge
+	}
+	b.remaining -= int64(n)
+
+	return n, err
+}
+
+func (b *decodedBody) Close() error {
+	b.decoded.Close()
+	return b.body.Close()
+}
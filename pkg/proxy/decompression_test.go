@@ -0,0 +1,176 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hellofresh/janus/pkg/router"
+	"github.com/hellofresh/stats-go/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encode(t *testing.T, encoding, body string) []byte {
+	var (
+		buf bytes.Buffer
+		w   io.WriteCloser
+	)
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	default:
+		return []byte(body)
+	}
+
+	_, err := io.WriteString(w, body)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	return buf.Bytes()
+}
+
+func TestDecompressionValidate(t *testing.T) {
+	assert.NoError(t, Decompression{Enabled: true, MaxSize: 1024}.validate())
+	assert.Error(t, Decompression{MaxSize: -1}.validate())
+	assert.Equal(t, int64(DefaultDecompressionMaxSize), Decompression{}.maxSize())
+	assert.Equal(t, int64(1024), Decompression{MaxSize: 1024}.maxSize())
+
+	def := NewDefinition()
+	def.ListenPath = "/example"
+	def.Decompression.MaxSize = -1
+	ok, err := def.Validate()
+	assert.False(t, ok)
+	assert.Error(t, err)
+}
+
+func TestDecodedResponses(t *testing.T) {
+	var acceptEncoding string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptEncoding = r.Header.Get("Accept-Encoding")
+
+		encoding := strings.TrimPrefix(r.URL.Path, "/")
+		body := encode(t, encoding, "hello world")
+		if encoding == "raw-deflate" {
+			encoding = "deflate"
+		}
+		if encoding != "identity" {
+			w.Header().Set("Content-Encoding", encoding)
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write(body)
+	}))
+	defer upstream.Close()
+
+	newGateway := func(decoded bool) *httptest.Server {
+		r := router.NewChiRouter()
+		register := NewRegister(WithRouter(r), WithStatsClient(client.NewNoop()))
+		def := NewDefinition()
+		def.ListenPath = "/api/*"
+		def.StripPath = true
+		def.Upstreams = &Upstreams{Balancing: "roundrobin", Targets: []*Target{{Target: upstream.URL}}}
+		routerDef := NewRouterDefinition(def)
+		if decoded {
+			routerDef.AddMiddleware(DecodeResponses(def.Decompression))
+		}
+		require.NoError(t, register.Add(routerDef))
+
+		return httptest.NewServer(r)
+	}
+
+	httpClient := &http.Client{Timeout: 5 * time.Second, Transport: &http.Transport{DisableCompression: true}}
+	get := func(url string) (*http.Response, string) {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept-Encoding", "gzip, deflate, br")
+
+		res, err := httpClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		body, err := ioutil.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res, string(body)
+	}
+
+	gateway := newGateway(true)
+	defer gateway.Close()
+
+	for _, encoding := range []string{"gzip", "deflate", "raw-deflate", "identity"} {
+		t.Run(encoding, func(t *testing.T) {
+			res, body := get(gateway.URL + "/api/" + encoding)
+			assert.Equal(t, "hello world", body)
+			assert.Empty(t, res.Header.Get("Content-Encoding"))
+			assert.Equal(t, "deflate, gzip", acceptEncoding, "the upstream is asked only for the encodings that can be decoded")
+		})
+	}
+
+	res, _ := get(gateway.URL + "/api/gzip")
+	assert.Equal(t, `W/"v1"`, res.Header.Get("ETag"), "the validator of the encoded body is weakened")
+
+	res, body := get(gateway.URL + "/api/br")
+	assert.Equal(t, "br", res.Header.Get("Content-Encoding"), "the encodings without a decoder are passed encoded")
+	assert.Equal(t, "hello world", body)
+
+	passthrough := newGateway(false)
+	defer passthrough.Close()
+
+	res, body = get(passthrough.URL + "/api/gzip")
+	assert.Equal(t, "gzip", res.Header.Get("Content-Encoding"), "the APIs not decoding the responses pass them untouched")
+	assert.Equal(t, string(encode(t, "gzip", "hello world")), body)
+	assert.Equal(t, "gzip, deflate, br", acceptEncoding)
+	assert.Equal(t, `"v1"`, res.Header.Get("ETag"))
+}
+
+func TestDecodeResponseWithoutBody(t *testing.T) {
+	req := httptest.NewRequest(http.MethodHead, "/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), decompressionKey, Decompression{}))
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Encoding": {"gzip"}}, Body: http.NoBody, Request: req}
+
+	require.NoError(t, decodeResponse(resp))
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"), "the responses without a body are left as they are")
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), decompressionKey, Decompression{}))
+	resp = &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Encoding": {"gzip"}}, Body: ioutil.NopCloser(strings.NewReader("")), Request: req}
+
+	require.NoError(t, decodeResponse(resp))
+	assert.Equal(t, http.NoBody, resp.Body, "the empty bodies are not encoded")
+	assert.Empty(t, resp.Header.Get("Content-Encoding"))
+
+	resp = &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Encoding": {"gzip"}}, Body: ioutil.NopCloser(strings.NewReader("not gzip")), Request: req}
+	assert.Error(t, decodeResponse(resp), "the invalid encoded bodies fail the upstream call")
+}
+
+func TestDecodedBodyMaxSize(t *testing.T) {
+	encoded := encode(t, "gzip", strings.Repeat("a", 4096))
+	decoded, err := newGzipDecoder(bytes.NewReader(encoded))
+	require.NoError(t, err)
+
+	body := &decodedBody{decoded: decoded, body: ioutil.NopCloser(nil), remaining: 1024}
+	b, err := ioutil.ReadAll(body)
+	assert.Equal(t, ErrDecodedBodyTooLarge, err)
+	assert.Len(t, b, 1024)
+	assert.NoError(t, body.Close())
+
+	decoded, err = newGzipDecoder(bytes.NewReader(encoded))
+	require.NoError(t, err)
+
+	body = &decodedBody{decoded: decoded, body: ioutil.NopCloser(nil), remaining: 4096}
+	b, err = ioutil.ReadAll(body)
+	assert.NoError(t, err, "the bodies of the max size are decoded")
+	assert.Len(t, b, 4096)
+}
@@ -1,7 +1,11 @@
 package proxy
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -12,17 +16,42 @@ import (
 	"github.com/hellofresh/janus/pkg/router"
 )
 
+const (
+	// MatchingModeLiteral matches the listen path as a router pattern, e.g. /users/*
+	MatchingModeLiteral = "literal"
+	// MatchingModeRegex matches the listen path as a regular expression anchored at the path start
+	MatchingModeRegex = "regex"
+)
+
 // Definition defines proxy rules for a route
 type Definition struct {
 	PreserveHost       bool               `bson:"preserve_host" json:"preserve_host" mapstructure:"preserve_host"`
 	ListenPath         string             `bson:"listen_path" json:"listen_path" mapstructure:"listen_path" valid:"required~proxy.listen_path is required,urlpath"`
+	ListenPathAliases  []string           `bson:"listen_path_aliases" json:"listen_path_aliases,omitempty" mapstructure:"listen_path_aliases"`
 	Upstreams          *Upstreams         `bson:"upstreams" json:"upstreams" mapstructure:"upstreams"`
 	InsecureSkipVerify bool               `bson:"insecure_skip_verify" json:"insecure_skip_verify" mapstructure:"insecure_skip_verify"`
+	MatchingMode       string             `bson:"matching_mode" json:"matching_mode" mapstructure:"matching_mode"`
 	StripPath          bool               `bson:"strip_path" json:"strip_path" mapstructure:"strip_path"`
 	AppendPath         bool               `bson:"append_path" json:"append_path" mapstructure:"append_path"`
+	PathRewrites       []PathRewrite      `bson:"path_rewrites" json:"path_rewrites,omitempty" mapstructure:"path_rewrites"`
 	Methods            []string           `bson:"methods" json:"methods"`
 	Hosts              []string           `bson:"hosts" json:"hosts"`
+	Headers            []HeaderMatch      `bson:"headers" json:"headers"`
+	Listeners          []string           `bson:"listeners" json:"listeners,omitempty" mapstructure:"listeners"`
 	ForwardingTimeouts ForwardingTimeouts `bson:"forwarding_timeouts" json:"forwarding_timeouts" mapstructure:"forwarding_timeouts"`
+	Hedging            Hedging            `bson:"hedging" json:"hedging" mapstructure:"hedging"`
+	WebSocket          WebSocket          `bson:"websocket" json:"websocket" mapstructure:"websocket"`
+	Buffering          Buffering          `bson:"buffering" json:"buffering" mapstructure:"buffering"`
+	Decompression      Decompression      `bson:"decompression" json:"decompression" mapstructure:"decompression"`
+	GRPC               GRPC               `bson:"grpc" json:"grpc" mapstructure:"grpc"`
+}
+
+// HeaderMatch is a request header condition, the header must be equal to the value or match the
+// regular expression
+type HeaderMatch struct {
+	Name  string `bson:"name" json:"name"`
+	Value string `bson:"value" json:"value"`
+	Regex string `bson:"regex" json:"regex"`
 }
 
 // RouterDefinition represents an API that you want to proxy with internal router routines
@@ -35,6 +64,9 @@ type RouterDefinition struct {
 type Upstreams struct {
 	Balancing string  `bson:"balancing" json:"balancing"`
 	Targets   Targets `bson:"targets" json:"targets"`
+	// SlowStart is the time the weight of a target joining the upstreams is ramped up for, the targets
+	// get their full weight at once when it is not set
+	SlowStart Duration `bson:"slow_start" json:"slow_start,omitempty"`
 }
 
 // Target is an ip address/hostname with a port that identifies an instance of a backend service
@@ -114,6 +146,7 @@ func NewDefinition() *Definition {
 	return &Definition{
 		Methods: []string{"GET"},
 		Hosts:   make([]string, 0),
+		Headers: make([]HeaderMatch, 0),
 		Upstreams: &Upstreams{
 			Targets: make([]*Target, 0),
 		},
@@ -137,7 +170,170 @@ func (d *RouterDefinition) AddMiddleware(m router.Constructor) {
 
 // Validate validates proxy data
 func (d *Definition) Validate() (bool, error) {
-	return govalidator.ValidateStruct(d)
+	if ok, err := govalidator.ValidateStruct(d); !ok {
+		return ok, err
+	}
+
+	listenPaths := map[string]bool{d.ListenPath: true}
+	for _, alias := range d.ListenPathAliases {
+		if !strings.HasPrefix(alias, "/") {
+			return false, fmt.Errorf("proxy.listen_path_aliases %q must begin with '/'", alias)
+		}
+		if listenPaths[alias] {
+			return false, fmt.Errorf("proxy.listen_path_aliases %q is duplicated", alias)
+		}
+		listenPaths[alias] = true
+	}
+
+	switch d.MatchingMode {
+	case "", MatchingModeLiteral:
+	case MatchingModeRegex:
+		for _, listenPath := range d.ListenPaths() {
+			if _, err := compileListenPath(listenPath); err != nil {
+				return false, fmt.Errorf("proxy.listen_path %q is not a valid regular expression: %v", listenPath, err)
+			}
+		}
+	default:
+		return false, fmt.Errorf("proxy.matching_mode %q is not supported", d.MatchingMode)
+	}
+
+	if err := validatePathRewrites(d.PathRewrites); err != nil {
+		return false, err
+	}
+
+	listeners := make(map[string]bool, len(d.Listeners))
+	for _, name := range d.Listeners {
+		if name == "" {
+			return false, errors.New("proxy.listeners name is required")
+		}
+		if listeners[name] {
+			return false, fmt.Errorf("proxy.listeners %q is duplicated", name)
+		}
+		listeners[name] = true
+	}
+
+	if err := d.Hedging.validate(); err != nil {
+		return false, err
+	}
+
+	if err := d.WebSocket.validate(); err != nil {
+		return false, err
+	}
+
+	if err := d.Buffering.validate(); err != nil {
+		return false, err
+	}
+
+	if err := d.Decompression.validate(); err != nil {
+		return false, err
+	}
+
+	if err := d.GRPC.validate(d); err != nil {
+		return false, err
+	}
+
+	for _, header := range d.Headers {
+		if header.Name == "" {
+			return false, errors.New("proxy.headers name is required")
+		}
+		if header.Regex == "" {
+			continue
+		}
+
+		if header.Value != "" {
+			return false, fmt.Errorf("proxy.headers %q must have either a value or a regex", header.Name)
+		}
+		if _, err := regexp.Compile(header.Regex); err != nil {
+			return false, fmt.Errorf("proxy.headers %q regex is not valid: %v", header.Name, err)
+		}
+	}
+
+	return true, nil
+}
+
+// IsRegex checks if the listen path is matched as a regular expression
+func (d *Definition) IsRegex() bool {
+	return d.MatchingMode == MatchingModeRegex
+}
+
+// ListenPathRegexp compiles the listen path as a regular expression anchored at the path start
+func (d *Definition) ListenPathRegexp() (*regexp.Regexp, error) {
+	return compileListenPath(d.ListenPath)
+}
+
+// ListenPaths returns the listen path followed by its aliases
+func (d *Definition) ListenPaths() []string {
+	return append([]string{d.ListenPath}, d.ListenPathAliases...)
+}
+
+// SharesListenPath tells if a listen path or an alias of the definition is one of the other definition,
+// the definitions served on different listeners do not share their listen paths
+func (d *Definition) SharesListenPath(other *Definition) bool {
+	if !d.sharesListener(other) {
+		return false
+	}
+
+	for _, listenPath := range d.ListenPaths() {
+		for _, otherListenPath := range other.ListenPaths() {
+			if listenPath == otherListenPath {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ConflictsWith tells if the definition cannot be registered besides the other one, i.e. they share a
+// listen path and have the same hosts and headers. The definitions with different hosts or headers are
+// routed by the request host and headers.
+func (d *Definition) ConflictsWith(other *Definition) bool {
+	return d.SharesListenPath(other) && sameHosts(d.Hosts, other.Hosts) && sameHeaders(d.Headers, other.Headers)
+}
+
+// sameHosts tells if both lists hold the same hosts, in any order
+func sameHosts(hosts, other []string) bool {
+	set := make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		set[strings.ToLower(host)] = true
+	}
+
+	otherSet := make(map[string]bool, len(other))
+	for _, host := range other {
+		host = strings.ToLower(host)
+		if !set[host] {
+			return false
+		}
+		otherSet[host] = true
+	}
+
+	return len(set) == len(otherSet)
+}
+
+// sameHeaders tells if both lists hold the same header conditions, in any order
+func sameHeaders(headers, other []HeaderMatch) bool {
+	if len(headers) != len(other) {
+		return false
+	}
+
+	counts := make(map[HeaderMatch]int, len(headers))
+	for _, header := range headers {
+		header.Name = http.CanonicalHeaderKey(header.Name)
+		counts[header]++
+	}
+	for _, header := range other {
+		header.Name = http.CanonicalHeaderKey(header.Name)
+		if counts[header] == 0 {
+			return false
+		}
+		counts[header]--
+	}
+
+	return true
+}
+
+func compileListenPath(listenPath string) (*regexp.Regexp, error) {
+	return regexp.Compile("^" + listenPath)
 }
 
 // IsBalancerDefined checks if load balancer is defined
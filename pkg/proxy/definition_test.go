@@ -33,6 +33,34 @@ func TestDefinition(t *testing.T) {
 			scenario: "invalid target url validation",
 			function: testInvalidTargetURLValidation,
 		},
+		{
+			scenario: "regex listen path validation",
+			function: testRegexListenPathValidation,
+		},
+		{
+			scenario: "listen path aliases validation",
+			function: testListenPathAliasesValidation,
+		},
+		{
+			scenario: "headers validation",
+			function: testHeadersValidation,
+		},
+		{
+			scenario: "conflicting definitions",
+			function: testConflictsWith,
+		},
+		{
+			scenario: "listeners validation",
+			function: testListenersValidation,
+		},
+		{
+			scenario: "hedging validation",
+			function: testHedgingValidation,
+		},
+		{
+			scenario: "websocket validation",
+			function: testWebSocketValidation,
+		},
 		{
 			scenario: "is balancer defined",
 			function: testIsBalancerDefined,
@@ -105,6 +133,195 @@ func testInvalidTargetURLValidation(t *testing.T) {
 	assert.False(t, isValid)
 }
 
+func testRegexListenPathValidation(t *testing.T) {
+	definition := Definition{
+		ListenPath:   `/users/(?P<id>\d+)`,
+		MatchingMode: MatchingModeRegex,
+		Upstreams: &Upstreams{
+			Balancing: "roundrobin",
+			Targets: Targets{
+				{Target: "http://test.com"},
+			},
+		},
+	}
+	isValid, err := definition.Validate()
+	assert.NoError(t, err)
+	assert.True(t, isValid)
+
+	definition.ListenPath = "/users/(["
+	isValid, err = definition.Validate()
+	assert.Error(t, err)
+	assert.False(t, isValid)
+
+	definition.ListenPath = "/users"
+	definition.MatchingMode = "glob"
+	isValid, err = definition.Validate()
+	assert.Error(t, err)
+	assert.False(t, isValid)
+}
+
+func testListenPathAliasesValidation(t *testing.T) {
+	definition := Definition{
+		ListenPath:        "/v1/orders/*",
+		ListenPathAliases: []string{"/orders/*"},
+		Upstreams: &Upstreams{
+			Balancing: "roundrobin",
+			Targets: Targets{
+				{Target: "http://test.com"},
+			},
+		},
+	}
+	isValid, err := definition.Validate()
+	assert.NoError(t, err)
+	assert.True(t, isValid)
+	assert.Equal(t, []string{"/v1/orders/*", "/orders/*"}, definition.ListenPaths())
+
+	assert.True(t, definition.SharesListenPath(&Definition{ListenPath: "/orders/*"}))
+	assert.True(t, definition.SharesListenPath(&Definition{ListenPath: "/legacy", ListenPathAliases: []string{"/v1/orders/*"}}))
+	assert.False(t, definition.SharesListenPath(&Definition{ListenPath: "/v2/orders/*"}))
+
+	definition.ListenPathAliases = []string{"orders/*"}
+	isValid, err = definition.Validate()
+	assert.Error(t, err)
+	assert.False(t, isValid)
+
+	definition.ListenPathAliases = []string{"/orders/*", "/v1/orders/*"}
+	isValid, err = definition.Validate()
+	assert.Error(t, err, "the aliases can not repeat the listen path")
+	assert.False(t, isValid)
+
+	definition.ListenPathAliases = []string{"/orders/(["}
+	definition.MatchingMode = MatchingModeRegex
+	isValid, err = definition.Validate()
+	assert.Error(t, err, "the aliases are regular expressions in the regex matching mode")
+	assert.False(t, isValid)
+}
+
+func testListenersValidation(t *testing.T) {
+	definition := Definition{
+		ListenPath: "/orders/*",
+		Listeners:  []string{"partner"},
+		Upstreams: &Upstreams{
+			Balancing: "roundrobin",
+			Targets: Targets{
+				{Target: "http://test.com"},
+			},
+		},
+	}
+	isValid, err := definition.Validate()
+	assert.NoError(t, err)
+	assert.True(t, isValid)
+	assert.Equal(t, []string{"partner"}, definition.BoundListeners())
+	assert.Equal(t, []string{DefaultListener}, (&Definition{}).BoundListeners())
+
+	assert.NoError(t, definition.ValidateListeners([]string{"partner"}))
+	assert.Error(t, definition.ValidateListeners(nil), "the definition is bound to a listener not configured")
+
+	assert.False(t, definition.SharesListenPath(&Definition{ListenPath: "/orders/*"}), "the listen path is routed per listener")
+	assert.True(t, definition.SharesListenPath(&Definition{ListenPath: "/orders/*", Listeners: []string{DefaultListener, "partner"}}))
+
+	definition.Listeners = []string{"partner", "partner"}
+	isValid, err = definition.Validate()
+	assert.Error(t, err)
+	assert.False(t, isValid)
+
+	definition.Listeners = []string{""}
+	isValid, err = definition.Validate()
+	assert.Error(t, err)
+	assert.False(t, isValid)
+}
+
+func testHeadersValidation(t *testing.T) {
+	definition := Definition{
+		ListenPath: "/users",
+		Headers:    []HeaderMatch{{Name: "X-Version", Regex: "^beta"}},
+		Upstreams: &Upstreams{
+			Balancing: "roundrobin",
+			Targets: Targets{
+				{Target: "http://test.com"},
+			},
+		},
+	}
+	isValid, err := definition.Validate()
+	assert.NoError(t, err)
+	assert.True(t, isValid)
+
+	definition.Headers = []HeaderMatch{{Name: "X-Version", Regex: "(["}}
+	isValid, err = definition.Validate()
+	assert.Error(t, err)
+	assert.False(t, isValid)
+
+	definition.Headers = []HeaderMatch{{Name: "X-Version", Value: "beta", Regex: "^beta"}}
+	isValid, err = definition.Validate()
+	assert.Error(t, err)
+	assert.False(t, isValid)
+
+	definition.Headers = []HeaderMatch{{Value: "beta"}}
+	isValid, err = definition.Validate()
+	assert.Error(t, err)
+	assert.False(t, isValid)
+}
+
+func testConflictsWith(t *testing.T) {
+	definition := Definition{ListenPath: "/v1/*", Hosts: []string{"api.a.com", "*.a.com"}}
+
+	assert.True(t, definition.ConflictsWith(&Definition{ListenPath: "/v1/*", Hosts: []string{"*.a.com", "API.a.com"}}))
+	assert.False(t, definition.ConflictsWith(&Definition{ListenPath: "/v2/*", Hosts: []string{"api.a.com", "*.a.com"}}))
+	assert.False(t, definition.ConflictsWith(&Definition{ListenPath: "/v1/*", Hosts: []string{"api.b.com"}}), "the definitions are routed by host")
+	assert.False(t, definition.ConflictsWith(&Definition{ListenPath: "/v1/*", Hosts: []string{"api.a.com"}}))
+	assert.False(t, definition.ConflictsWith(&Definition{ListenPath: "/v1/*"}), "the definition without hosts matches the other hosts")
+	assert.True(t, (&Definition{ListenPath: "/v1/*"}).ConflictsWith(&Definition{ListenPath: "/v1/*"}))
+
+	definition = Definition{ListenPath: "/v1/*", Headers: []HeaderMatch{{Name: "X-Version", Value: "beta"}, {Name: "X-Debug"}}}
+	assert.True(t, definition.ConflictsWith(&Definition{ListenPath: "/v1/*", Headers: []HeaderMatch{{Name: "x-debug"}, {Name: "X-Version", Value: "beta"}}}))
+	assert.False(t, definition.ConflictsWith(&Definition{ListenPath: "/v1/*", Headers: []HeaderMatch{{Name: "X-Version", Value: "beta"}}}), "the definitions are routed by headers")
+	assert.False(t, definition.ConflictsWith(&Definition{ListenPath: "/v1/*", Headers: []HeaderMatch{{Name: "X-Version", Regex: "beta"}, {Name: "X-Debug"}}}))
+	assert.False(t, definition.ConflictsWith(&Definition{ListenPath: "/v1/*"}))
+	assert.False(t, definition.ConflictsWith(&Definition{ListenPath: "/v1/*", Hosts: []string{"api.a.com"}, Headers: definition.Headers}))
+}
+
+func testHedgingValidation(t *testing.T) {
+	definition := Definition{
+		ListenPath: "/*",
+		Upstreams:  &Upstreams{Balancing: "roundrobin", Targets: Targets{{Target: "http://test.com"}}},
+		Hedging:    Hedging{Enabled: true, Delay: Duration(50 * time.Millisecond)},
+	}
+	isValid, err := definition.Validate()
+	assert.NoError(t, err)
+	assert.True(t, isValid)
+
+	definition.Hedging = Hedging{Enabled: true, Percentile: 95}
+	_, err = definition.Validate()
+	assert.NoError(t, err)
+
+	for _, hedging := range []Hedging{
+		{Enabled: true},
+		{Enabled: true, Percentile: 100},
+		{Enabled: true, Delay: Duration(time.Second), MaxConcurrent: -1},
+	} {
+		definition.Hedging = hedging
+		isValid, err = definition.Validate()
+		assert.Error(t, err)
+		assert.False(t, isValid)
+	}
+}
+
+func testWebSocketValidation(t *testing.T) {
+	definition := Definition{
+		ListenPath: "/*",
+		Upstreams:  &Upstreams{Balancing: "roundrobin", Targets: Targets{{Target: "http://test.com"}}},
+		WebSocket:  WebSocket{IdleTimeout: Duration(time.Minute), MaxMessageSize: 1 << 20},
+	}
+	isValid, err := definition.Validate()
+	assert.NoError(t, err)
+	assert.True(t, isValid)
+
+	definition.WebSocket = WebSocket{MaxMessageSize: -1}
+	isValid, err = definition.Validate()
+	assert.Error(t, err)
+	assert.False(t, isValid)
+}
+
 func testIsBalancerDefined(t *testing.T) {
 	definition := NewDefinition()
 	assert.False(t, definition.IsBalancerDefined())
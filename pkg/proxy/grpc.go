@@ -0,0 +1,156 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	grpcContentType = "application/grpc"
+	grpcStatus      = "Grpc-Status"
+	grpcMessage     = "Grpc-Message"
+	grpcTimeout     = "Grpc-Timeout"
+)
+
+// GRPC represents the gRPC settings of an API. The gRPC calls are proxied over HTTP/2 to the upstreams,
+// their messages are streamed in both directions and the status trailers are sent back to the clients.
+type GRPC struct {
+	Enabled bool `bson:"enabled" json:"enabled"`
+}
+
+func (g GRPC) validate(d *Definition) error {
+	if !g.Enabled {
+		return nil
+	}
+
+	// the upstream methods are called on the request paths, e.g. /helloworld.Greeter/SayHello
+	if !d.AppendPath && !d.StripPath {
+		return errors.New("proxy.grpc requires append_path or strip_path")
+	}
+
+	for _, method := range d.Methods {
+		if method == http.MethodPost || strings.EqualFold(method, methodAll) {
+			return nil
+		}
+	}
+
+	return errors.New("proxy.grpc requires the POST method")
+}
+
+// isGRPC tells the gRPC calls from the other requests, the gRPC-Web calls are HTTP/1.1 requests
+// proxied as they are
+func isGRPC(req *http.Request) bool {
+	contentType := req.Header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, grpcContentType) {
+		return false
+	}
+
+	rest := contentType[len(grpcContentType):]
+	return rest == "" || rest[0] == '+' || rest[0] == ';'
+}
+
+// grpcHandler bounds the gRPC calls by the deadline the clients sent with the grpc-timeout header, so
+// the calls are cancelled upstream once the clients gave up on them
+func grpcHandler(handler http.Handler, settings GRPC) http.Handler {
+	if !settings.Enabled {
+		return handler
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout, ok := parseGRPCTimeout(r.Header.Get(grpcTimeout))
+		if !ok || !isGRPC(r) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// parseGRPCTimeout parses the grpc-timeout header value, at most 8 digits followed by the unit
+func parseGRPCTimeout(value string) (time.Duration, bool) {
+	if len(value) < 2 || len(value) > 9 {
+		return 0, false
+	}
+
+	var unit time.Duration
+	switch value[len(value)-1] {
+	case 'H':
+		unit = time.Hour
+	case 'M':
+		unit = time.Minute
+	case 'S':
+		unit = time.Second
+	case 'm':
+		unit = time.Millisecond
+	case 'u':
+		unit = time.Microsecond
+	case 'n':
+		unit = time.Nanosecond
+	default:
+		return 0, false
+	}
+
+	n, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+
+	return time.Duration(n) * unit, true
+}
+
+// writeGRPCStatus answers the gRPC call with a Trailers-Only response, the status is sent in the
+// headers of a response without body
+func writeGRPCStatus(w http.ResponseWriter, code codes.Code, message string) {
+	w.Header().Set("Content-Type", grpcContentType)
+	w.Header().Set(grpcStatus, strconv.Itoa(int(code)))
+	w.Header().Set(grpcMessage, encodeGRPCMessage(message))
+	w.WriteHeader(http.StatusOK)
+}
+
+// grpcCode returns the gRPC status code of the HTTP status code of a gateway error, following the
+// mapping of the gRPC clients for the HTTP responses without status. The gateway timeouts are the
+// deadlines exceeded of the calls.
+func grpcCode(status int) codes.Code {
+	switch status {
+	case http.StatusBadRequest:
+		return codes.Internal
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.Unimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+
+	return codes.Unknown
+}
+
+// encodeGRPCMessage percent-encodes the message of the status, the bytes outside of the printable
+// ASCII characters and the percent sign are encoded
+func encodeGRPCMessage(message string) string {
+	var b strings.Builder
+	for i := 0; i < len(message); i++ {
+		c := message[i]
+		if c < ' ' || c > '~' || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+
+	return b.String()
+}
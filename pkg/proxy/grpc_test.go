@@ -0,0 +1,199 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hellofresh/janus/pkg/router"
+	"github.com/hellofresh/stats-go/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCValidate(t *testing.T) {
+	def := NewDefinition()
+	def.Methods = []string{"POST"}
+	def.AppendPath = true
+	assert.NoError(t, GRPC{}.validate(def))
+	assert.NoError(t, GRPC{Enabled: true}.validate(def))
+
+	def.AppendPath = false
+	assert.Error(t, GRPC{Enabled: true}.validate(def), "the methods are called on the request paths")
+	def.StripPath = true
+	assert.NoError(t, GRPC{Enabled: true}.validate(def))
+
+	def.Methods = []string{"GET"}
+	assert.Error(t, GRPC{Enabled: true}.validate(def), "the gRPC calls are POST requests")
+	def.Methods = []string{"ALL"}
+	assert.NoError(t, GRPC{Enabled: true}.validate(def))
+}
+
+func TestIsGRPC(t *testing.T) {
+	for contentType, expected := range map[string]bool{
+		"application/grpc":             true,
+		"application/grpc+proto":       true,
+		"application/grpc;charset=utf": true,
+		"application/grpc-web":         false,
+		"application/grpc-web+proto":   false,
+		"application/json":             false,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("Content-Type", contentType)
+		assert.Equal(t, expected, isGRPC(req), contentType)
+	}
+}
+
+func TestParseGRPCTimeout(t *testing.T) {
+	timeout, ok := parseGRPCTimeout("100m")
+	assert.True(t, ok)
+	assert.Equal(t, 100*time.Millisecond, timeout)
+
+	timeout, ok = parseGRPCTimeout("2H")
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Hour, timeout)
+
+	for _, value := range []string{"", "S", "100", "100x", "123456789S", "-1S"} {
+		_, ok := parseGRPCTimeout(value)
+		assert.False(t, ok, value)
+	}
+}
+
+func TestGRPCHandlerDeadline(t *testing.T) {
+	var deadline time.Time
+	handler := grpcHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, _ = r.Context().Deadline()
+	}), GRPC{Enabled: true})
+
+	req := httptest.NewRequest(http.MethodPost, "/helloworld.Greeter/SayHello", nil)
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("Grpc-Timeout", "1S")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.WithinDuration(t, time.Now().Add(time.Second), deadline, 100*time.Millisecond, "the call is bounded by the client deadline")
+
+	deadline = time.Time{}
+	req.Header.Del("Grpc-Timeout")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.True(t, deadline.IsZero())
+}
+
+func TestHandleProxyErrorGRPC(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/helloworld.Greeter/SayHello", nil)
+	req.Header.Set("Content-Type", "application/grpc")
+
+	w := httptest.NewRecorder()
+	handleProxyError(w, req, errors.New("connection refused"))
+	assert.Equal(t, http.StatusOK, w.Code, "the status of the call is sent in the headers")
+	assert.Equal(t, "application/grpc", w.Header().Get("Content-Type"))
+	assert.Equal(t, "14", w.Header().Get("Grpc-Status"))
+	assert.Equal(t, "upstream call failed", w.Header().Get("Grpc-Message"))
+	assert.Empty(t, w.Body.String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	w = httptest.NewRecorder()
+	handleProxyError(w, req.WithContext(ctx), context.DeadlineExceeded)
+	assert.Equal(t, "4", w.Header().Get("Grpc-Status"), "the request timeouts are the deadlines exceeded of the calls")
+}
+
+func TestEncodeGRPCMessage(t *testing.T) {
+	assert.Equal(t, "upstream call failed", encodeGRPCMessage("upstream call failed"))
+	assert.Equal(t, "100%25 %C3%A9chou%C3%A9%0A", encodeGRPCMessage("100% échoué\n"))
+}
+
+func TestGRPCProxy(t *testing.T) {
+	_, checksURL, stopChecks := startHealthServer(t)
+	defer stopChecks()
+	watchesHealth, watchesURL, stopWatches := startHealthServer(t)
+	defer stopWatches()
+	watchesHealth.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	// the listener is closed, so the connections to its address are refused
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	refusedURL := "http://" + closed.Addr().String()
+	closed.Close()
+
+	r := router.NewChiRouter()
+	register := NewRegister(WithRouter(r), WithStatsClient(client.NewNoop()))
+	add := func(listenPath, target string) {
+		def := NewDefinition()
+		def.ListenPath = listenPath
+		def.Methods = []string{"POST"}
+		def.AppendPath = true
+		def.Upstreams = &Upstreams{Balancing: "roundrobin", Targets: []*Target{{Target: target}}}
+		def.GRPC = GRPC{Enabled: true}
+		_, err := def.Validate()
+		require.NoError(t, err)
+		require.NoError(t, register.Add(NewRouterDefinition(def)))
+	}
+	// the methods of a service are routed by their own listen paths
+	add("/grpc.health.v1.Health/*", checksURL)
+	add("/grpc.health.v1.Health/Watch", watchesURL)
+	add("/unreachable.Health/*", refusedURL)
+
+	gateway := httptest.NewServer(h2c.NewHandler(r, &http2.Server{}))
+	defer gateway.Close()
+
+	conn, err := grpc.Dial(strings.TrimPrefix(gateway.URL, "http://"), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	healthClient := healthpb.NewHealthClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	t.Run("unary", func(t *testing.T) {
+		resp, err := healthClient.Check(ctx, &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+
+		_, err = healthClient.Check(ctx, &healthpb.HealthCheckRequest{Service: "unknown"})
+		assert.Equal(t, codes.NotFound, status.Code(err), "the status trailers are sent back")
+	})
+
+	t.Run("streaming", func(t *testing.T) {
+		stream, err := healthClient.Watch(ctx, &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.Status, "the method is routed to its own upstream")
+
+		watchesHealth.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+		resp, err = stream.Recv()
+		require.NoError(t, err, "the messages are sent as soon as they are received")
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+	})
+
+	t.Run("upstream failure", func(t *testing.T) {
+		err := conn.Invoke(ctx, "/unreachable.Health/Check", &healthpb.HealthCheckRequest{}, &healthpb.HealthCheckResponse{})
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+		assert.Equal(t, "upstream call failed", status.Convert(err).Message())
+	})
+}
+
+// startHealthServer starts a gRPC server of the health service, serving HTTP/2 without TLS
+func startHealthServer(t *testing.T) (*health.Server, string, func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	healthServer := health.NewServer()
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+	go server.Serve(ln)
+
+	return healthServer, "http://" + ln.Addr().String(), server.Stop
+}
@@ -0,0 +1,328 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultMaxConcurrentHedges is the number of hedged requests in flight per API when no cap is set
+	DefaultMaxConcurrentHedges = 10
+
+	latencySamples    = 1000
+	latencyRecompute  = 100
+	minLatencySamples = 100
+)
+
+// Hedging represents the hedged requests of an API. When the upstream has not responded within the
+// delay, the request is sent to another target as well and the first response is used.
+type Hedging struct {
+	Enabled bool `bson:"enabled" json:"enabled"`
+	// Delay is the time the response is waited for before the hedged request is sent
+	Delay Duration `bson:"delay" json:"delay"`
+	// Percentile derives the delay from the recent upstream latencies, e.g. 95, Delay is used until
+	// enough latencies are known
+	Percentile float64 `bson:"percentile" json:"percentile"`
+	// MaxConcurrent caps the hedged requests in flight, so the extra load on the upstreams is bounded
+	MaxConcurrent int `bson:"max_concurrent" json:"max_concurrent"`
+}
+
+func (h Hedging) validate() error {
+	if !h.Enabled {
+		return nil
+	}
+
+	if h.Delay <= 0 && h.Percentile == 0 {
+		return errors.New("proxy.hedging requires a delay or a percentile")
+	}
+	if h.Percentile < 0 || h.Percentile >= 100 {
+		return errors.New("proxy.hedging percentile must be between 0 and 100")
+	}
+	if h.MaxConcurrent < 0 {
+		return errors.New("proxy.hedging max_concurrent can not be negative")
+	}
+
+	return nil
+}
+
+// elected is the upstream target elected by the director, among the targets of the request
+type elected struct {
+	target  string
+	targets Targets
+}
+
+type roundTripResult struct {
+	resp   *http.Response
+	err    error
+	hedged bool
+	cancel context.CancelFunc
+}
+
+// hedgingTransport sends a hedged request to another target when the upstream is slow to respond,
+// the first response is used and the other request is cancelled
+type hedgingTransport struct {
+	base      http.RoundTripper
+	delay     time.Duration
+	latencies *latencyTracker
+	slots     chan struct{}
+}
+
+func newHedgingTransport(base http.RoundTripper, config Hedging) *hedgingTransport {
+	maxConcurrent := config.MaxConcurrent
+	if maxConcurrent <= 0 {
+		maxConcurrent = DefaultMaxConcurrentHedges
+	}
+
+	t := &hedgingTransport{base: base, delay: time.Duration(config.Delay), slots: make(chan struct{}, maxConcurrent)}
+	if config.Percentile > 0 {
+		t.latencies = &latencyTracker{percentile: config.Percentile}
+	}
+
+	return t
+}
+
+// RoundTrip sends the request to the upstream, and to another target when it is slow to respond
+func (t *hedgingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	delay, ok := t.hedgeDelay()
+	if !ok {
+		return t.send(req)
+	}
+
+	hedged := hedgeRequest(req)
+	if hedged == nil {
+		return t.send(req)
+	}
+
+	results := make(chan roundTripResult, 2)
+	cancelPrimary := t.start(req, false, results, nil)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case res := <-results:
+		return res.response()
+	case <-timer.C:
+	}
+
+	select {
+	case t.slots <- struct{}{}:
+	default:
+		// too many hedged requests are in flight, the upstream is waited for
+		res := <-results
+		return res.response()
+	}
+
+	log.WithField("upstream_url", hedged.URL.String()).Debug("Sending a hedged request")
+	cancelHedged := t.start(hedged, true, results, func() { <-t.slots })
+
+	first := <-results
+	if first.err != nil && req.Context().Err() == nil {
+		// the other request may still succeed
+		first.cancel()
+		return (<-results).response()
+	}
+
+	// the loser is cancelled, the winner is once its body is closed
+	if first.hedged {
+		cancelPrimary()
+	} else {
+		cancelHedged()
+	}
+	go func() {
+		if loser := <-results; loser.resp != nil {
+			loser.resp.Body.Close()
+		}
+	}()
+
+	return first.response()
+}
+
+// start sends the request in the background, its result is sent to results
+func (t *hedgingTransport) start(req *http.Request, hedged bool, results chan<- roundTripResult, done func()) context.CancelFunc {
+	ctx, cancel := context.WithCancel(req.Context())
+	go func() {
+		resp, err := t.send(req.WithContext(ctx))
+		if done != nil {
+			done()
+		}
+		results <- roundTripResult{resp: resp, err: err, hedged: hedged, cancel: cancel}
+	}()
+
+	return cancel
+}
+
+func (t *hedgingTransport) send(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if err == nil && t.latencies != nil {
+		t.latencies.record(time.Since(start))
+	}
+
+	return resp, err
+}
+
+// hedgeDelay returns the time the upstream is waited for, the requests are not hedged until the
+// percentile is known when no delay is set
+func (t *hedgingTransport) hedgeDelay() (time.Duration, bool) {
+	if t.latencies != nil {
+		if delay, ok := t.latencies.get(); ok {
+			return delay, true
+		}
+	}
+
+	return t.delay, t.delay > 0
+}
+
+// response returns the result, the request context is cancelled once the response body is closed
+func (r roundTripResult) response() (*http.Response, error) {
+	if r.err != nil {
+		r.cancel()
+		return nil, r.err
+	}
+
+	r.resp.Body = &cancelOnClose{ReadCloser: r.resp.Body, cancel: r.cancel}
+	return r.resp, nil
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes the body and cancels the request context
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+
+	return err
+}
+
+// hedgeRequest returns the request to another target, or nil when the request can not be hedged.
+// Only the idempotent requests without a body are hedged, the other targets must be balanced
+// between and they must share the path of the elected one.
+func hedgeRequest(req *http.Request) *http.Request {
+	if !isIdempotent(req.Method) || (req.Body != nil && req.Body != http.NoBody) {
+		return nil
+	}
+
+	e, ok := req.Context().Value(electedKey).(elected)
+	if !ok {
+		return nil
+	}
+
+	target := alternativeTarget(e)
+	if target == nil {
+		return nil
+	}
+
+	primary, err := url.Parse(e.target)
+	if err != nil {
+		return nil
+	}
+	alternative, err := url.Parse(target.Target)
+	if err != nil || primary.Path != alternative.Path {
+		return nil
+	}
+
+	hedged := req.WithContext(req.Context())
+	u := *req.URL
+	u.Scheme, u.Host = alternative.Scheme, alternative.Host
+	hedged.URL = &u
+	if req.Host == primary.Host {
+		hedged.Host = alternative.Host
+	}
+
+	// the tracing propagation sets the headers of each request
+	hedged.Header = make(http.Header, len(req.Header))
+	for name, values := range req.Header {
+		hedged.Header[name] = append([]string(nil), values...)
+	}
+
+	return hedged
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return false
+}
+
+// alternativeTarget picks one of the other targets, the targets without weight are not picked
+// when the targets are weighted
+func alternativeTarget(e elected) *Target {
+	weighted := false
+	for _, target := range e.targets {
+		weighted = weighted || target.Weight > 0
+	}
+
+	var candidates Targets
+	for _, target := range e.targets {
+		if target.Target != e.target && (!weighted || target.Weight > 0) {
+			candidates = append(candidates, target)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	return candidates[rand.Intn(len(candidates))]
+}
+
+// latencyTracker keeps the recent upstream latencies, the percentile is recomputed periodically
+type latencyTracker struct {
+	sync.Mutex
+	percentile float64
+	samples    []time.Duration
+	next       int
+	recorded   int
+	value      time.Duration
+	ready      bool
+}
+
+func (l *latencyTracker) record(latency time.Duration) {
+	l.Lock()
+	defer l.Unlock()
+
+	if len(l.samples) < latencySamples {
+		l.samples = append(l.samples, latency)
+	} else {
+		l.samples[l.next] = latency
+		l.next = (l.next + 1) % latencySamples
+	}
+
+	l.recorded++
+	if len(l.samples) < minLatencySamples || l.recorded < latencyRecompute {
+		return
+	}
+
+	sorted := append([]time.Duration(nil), l.samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	index := int(math.Ceil(l.percentile/100*float64(len(sorted)))) - 1
+	if index < 0 {
+		index = 0
+	}
+	l.value, l.ready, l.recorded = sorted[index], true, 0
+}
+
+func (l *latencyTracker) get() (time.Duration, bool) {
+	l.Lock()
+	defer l.Unlock()
+
+	return l.value, l.ready
+}
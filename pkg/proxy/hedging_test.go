@@ -0,0 +1,186 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeUpstreams responds with the host of the request after the delay of the host
+type fakeUpstreams struct {
+	sync.Mutex
+	delays    map[string]time.Duration
+	errors    map[string]error
+	hosts     []string
+	cancelled []string
+}
+
+func (f *fakeUpstreams) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.Lock()
+	f.hosts = append(f.hosts, req.URL.Host)
+	delay, err := f.delays[req.URL.Host], f.errors[req.URL.Host]
+	f.Unlock()
+
+	select {
+	case <-time.After(delay):
+	case <-req.Context().Done():
+		f.Lock()
+		f.cancelled = append(f.cancelled, req.URL.Host)
+		f.Unlock()
+		return nil, req.Context().Err()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       ioutil.NopCloser(strings.NewReader(req.URL.Host)),
+		Request:    req,
+	}, nil
+}
+
+func (f *fakeUpstreams) wasCancelled(host string) bool {
+	f.Lock()
+	defer f.Unlock()
+
+	for _, cancelled := range f.cancelled {
+		if cancelled == host {
+			return true
+		}
+	}
+
+	return false
+}
+
+func newHedgedRequest(t *testing.T, method string) *http.Request {
+	req, err := http.NewRequest(method, "http://primary.local/users", nil)
+	require.NoError(t, err)
+
+	return req.WithContext(context.WithValue(req.Context(), electedKey, elected{
+		target:  "http://primary.local",
+		targets: Targets{{Target: "http://primary.local"}, {Target: "http://secondary.local"}},
+	}))
+}
+
+func readBody(t *testing.T, resp *http.Response) string {
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return string(body)
+}
+
+func TestHedgingTransportFastPrimary(t *testing.T) {
+	upstreams := &fakeUpstreams{}
+	transport := newHedgingTransport(upstreams, Hedging{Enabled: true, Delay: Duration(100 * time.Millisecond)})
+
+	resp, err := transport.RoundTrip(newHedgedRequest(t, http.MethodGet))
+	require.NoError(t, err)
+	assert.Equal(t, "primary.local", readBody(t, resp))
+	assert.Equal(t, []string{"primary.local"}, upstreams.hosts, "no hedged request is sent")
+}
+
+func TestHedgingTransportSlowPrimary(t *testing.T) {
+	upstreams := &fakeUpstreams{delays: map[string]time.Duration{"primary.local": time.Second}}
+	transport := newHedgingTransport(upstreams, Hedging{Enabled: true, Delay: Duration(20 * time.Millisecond)})
+
+	resp, err := transport.RoundTrip(newHedgedRequest(t, http.MethodGet))
+	require.NoError(t, err)
+	assert.Equal(t, "secondary.local", readBody(t, resp))
+	assert.Equal(t, "secondary.local", resp.Request.Host)
+
+	assert.Eventually(t, func() bool { return upstreams.wasCancelled("primary.local") }, time.Second, 10*time.Millisecond)
+	assert.Len(t, transport.slots, 0, "the hedge slot is released")
+}
+
+func TestHedgingTransportFailedHedge(t *testing.T) {
+	upstreams := &fakeUpstreams{
+		delays: map[string]time.Duration{"primary.local": 50 * time.Millisecond},
+		errors: map[string]error{"secondary.local": errors.New("connection refused")},
+	}
+	transport := newHedgingTransport(upstreams, Hedging{Enabled: true, Delay: Duration(10 * time.Millisecond)})
+
+	resp, err := transport.RoundTrip(newHedgedRequest(t, http.MethodGet))
+	require.NoError(t, err)
+	assert.Equal(t, "primary.local", readBody(t, resp), "the primary response is waited for")
+}
+
+func TestHedgingTransportNotHedged(t *testing.T) {
+	upstreams := &fakeUpstreams{delays: map[string]time.Duration{"primary.local": 50 * time.Millisecond}}
+	transport := newHedgingTransport(upstreams, Hedging{Enabled: true, Delay: Duration(10 * time.Millisecond)})
+
+	resp, err := transport.RoundTrip(newHedgedRequest(t, http.MethodPost))
+	require.NoError(t, err)
+	assert.Equal(t, "primary.local", readBody(t, resp))
+
+	req := newHedgedRequest(t, http.MethodGet)
+	req = req.WithContext(context.WithValue(req.Context(), electedKey, elected{
+		target:  "http://primary.local",
+		targets: Targets{{Target: "http://primary.local"}},
+	}))
+	resp, err = transport.RoundTrip(req)
+	require.NoError(t, err)
+	assert.Equal(t, "primary.local", readBody(t, resp))
+
+	assert.Equal(t, []string{"primary.local", "primary.local"}, upstreams.hosts, "only the idempotent requests to several targets are hedged")
+}
+
+func TestHedgingTransportMaxConcurrent(t *testing.T) {
+	upstreams := &fakeUpstreams{delays: map[string]time.Duration{"primary.local": 50 * time.Millisecond}}
+	transport := newHedgingTransport(upstreams, Hedging{Enabled: true, Delay: Duration(10 * time.Millisecond), MaxConcurrent: 1})
+	transport.slots <- struct{}{}
+
+	resp, err := transport.RoundTrip(newHedgedRequest(t, http.MethodGet))
+	require.NoError(t, err)
+	assert.Equal(t, "primary.local", readBody(t, resp))
+	assert.Equal(t, []string{"primary.local"}, upstreams.hosts, "no hedged request is sent over the cap")
+}
+
+func TestHedgeRequestTargets(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "http://primary.local/users?page=2", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Request-ID", "1")
+
+	req = req.WithContext(context.WithValue(req.Context(), electedKey, elected{
+		target: "http://primary.local",
+		targets: Targets{
+			{Target: "http://primary.local", Weight: 10},
+			{Target: "http://drained.local", Weight: 0},
+			{Target: "https://secondary.local", Weight: 5},
+		},
+	}))
+
+	hedged := hedgeRequest(req)
+	require.NotNil(t, hedged)
+	assert.Equal(t, "https://secondary.local/users?page=2", hedged.URL.String(), "the targets without weight are not used")
+	assert.Equal(t, "secondary.local", hedged.Host)
+	assert.Equal(t, "1", hedged.Header.Get("X-Request-ID"))
+
+	hedged.Header.Set("X-Request-ID", "2")
+	assert.Equal(t, "1", req.Header.Get("X-Request-ID"), "the headers are not shared")
+	assert.Equal(t, "http://primary.local/users?page=2", req.URL.String())
+}
+
+func TestLatencyTracker(t *testing.T) {
+	tracker := &latencyTracker{percentile: 95}
+	for i := 1; i < minLatencySamples; i++ {
+		tracker.record(time.Duration(i) * time.Millisecond)
+	}
+	_, ok := tracker.get()
+	assert.False(t, ok, "the percentile is not known with too few latencies")
+
+	tracker.record(100 * time.Millisecond)
+	latency, ok := tracker.get()
+	assert.True(t, ok)
+	assert.Equal(t, 95*time.Millisecond, latency)
+}
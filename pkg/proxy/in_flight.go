@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+)
+
+// InFlightTracker counts the requests being sent to the upstream targets
+type InFlightTracker interface {
+	// Acquire counts the request of the context as in flight to the target until the returned function is called
+	Acquire(ctx context.Context, target string) func()
+}
+
+// inFlightTransport counts the requests in flight to the elected target, until their response body is closed
+type inFlightTransport struct {
+	base    http.RoundTripper
+	tracker InFlightTracker
+}
+
+// RoundTrip sends the request to the upstream
+func (t inFlightTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	e, ok := req.Context().Value(electedKey).(elected)
+	if !ok {
+		return t.base.RoundTrip(req)
+	}
+
+	release := t.tracker.Acquire(req.Context(), e.target)
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.Body == nil {
+		release()
+		return resp, err
+	}
+
+	// the body of the upgraded connections is written to as well
+	if rwc, ok := resp.Body.(io.ReadWriteCloser); ok {
+		resp.Body = &releaseOnCloseRW{ReadWriteCloser: rwc, release: release}
+	} else {
+		resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: release}
+	}
+
+	return resp, nil
+}
+
+type releaseOnClose struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releaseOnClose) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
+
+type releaseOnCloseRW struct {
+	io.ReadWriteCloser
+	release func()
+}
+
+func (b *releaseOnCloseRW) Close() error {
+	defer b.release()
+	return b.ReadWriteCloser.Close()
+}
@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testTracker struct {
+	sync.Mutex
+	inFlight map[string]int
+}
+
+func (t *testTracker) Acquire(ctx context.Context, target string) func() {
+	t.Lock()
+	t.inFlight[target]++
+	t.Unlock()
+
+	return func() {
+		t.Lock()
+		t.inFlight[target]--
+		t.Unlock()
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestInFlightTransport(t *testing.T) {
+	tracker := &testTracker{inFlight: make(map[string]int)}
+	transport := inFlightTransport{tracker: tracker, base: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBufferString("ok"))}, nil
+	})}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), electedKey, elected{target: "http://upstream"}))
+
+	resp, err := transport.RoundTrip(req)
+	require.NoError(t, err)
+	assert.Equal(t, 1, tracker.inFlight["http://upstream"], "the request is in flight until its body is closed")
+
+	resp.Body.Close()
+	assert.Equal(t, 0, tracker.inFlight["http://upstream"])
+
+	transport.base = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	})
+	_, err = transport.RoundTrip(req)
+	assert.Error(t, err)
+	assert.Equal(t, 0, tracker.inFlight["http://upstream"], "the failed requests are released")
+}
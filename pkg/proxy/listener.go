@@ -0,0 +1,46 @@
+package proxy
+
+import "fmt"
+
+// DefaultListener is the name of the proxy listener configured by the listen, port and tls settings, the
+// definitions not bound to any listener are served on it
+const DefaultListener = "default"
+
+// BoundListeners returns the names of the listeners the definition is served on
+func (d *Definition) BoundListeners() []string {
+	if len(d.Listeners) == 0 {
+		return []string{DefaultListener}
+	}
+
+	return d.Listeners
+}
+
+// ValidateListeners checks the definition is only bound to the listeners, or to the default listener
+func (d *Definition) ValidateListeners(listeners []string) error {
+	known := make(map[string]bool, len(listeners)+1)
+	known[DefaultListener] = true
+	for _, name := range listeners {
+		known[name] = true
+	}
+
+	for _, name := range d.Listeners {
+		if !known[name] {
+			return fmt.Errorf("proxy.listeners %q is not a configured listener", name)
+		}
+	}
+
+	return nil
+}
+
+// sharesListener tells if the definitions are served on a common listener
+func (d *Definition) sharesListener(other *Definition) bool {
+	for _, name := range d.BoundListeners() {
+		for _, otherName := range other.BoundListeners() {
+			if name == otherName {
+				return true
+			}
+		}
+	}
+
+	return false
+}
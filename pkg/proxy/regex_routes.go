@@ -0,0 +1,61 @@
+package proxy
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/go-chi/chi"
+)
+
+// regexRoutes holds the routes with regular expression listen paths. It is installed as the
+// router not found handler, so the routes are evaluated in the registration order only when
+// no literal route matched the request.
+type regexRoutes struct {
+	routes   []*regexRoute
+	notFound http.HandlerFunc
+}
+
+type regexRoute struct {
+	*route
+	pattern *regexp.Regexp
+}
+
+func newRegexRoutes(notFound http.HandlerFunc) *regexRoutes {
+	return &regexRoutes{notFound: notFound}
+}
+
+func (rr *regexRoutes) add(pattern *regexp.Regexp, rt *route) {
+	rr.routes = append(rr.routes, &regexRoute{route: rt, pattern: pattern})
+}
+
+// ServeHTTP dispatches the request to the first matching route. Named capture groups are exposed
+// as URL parameters, so they can be read with router.URLParam and used in the upstream target.
+func (rr *regexRoutes) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for _, rt := range rr.routes {
+		if !rt.matchListener(r) || !rt.matchMethod(r) {
+			continue
+		}
+
+		if _, ok := rt.match(r); !ok {
+			continue
+		}
+
+		match := rt.pattern.FindStringSubmatch(r.URL.Path)
+		if match == nil {
+			continue
+		}
+
+		if rctx, ok := r.Context().Value(chi.RouteCtxKey).(*chi.Context); ok {
+			for i, name := range rt.pattern.SubexpNames() {
+				if i > 0 && name != "" {
+					rctx.URLParams.Add(name, match[i])
+				}
+			}
+		}
+
+		rt.handler.ServeHTTP(w, r)
+		return
+	}
+
+	rr.notFound(w, r)
+}
@@ -5,13 +5,17 @@ import (
 	"strings"
 	"time"
 
+	"github.com/hellofresh/janus/pkg/middleware"
+	obs "github.com/hellofresh/janus/pkg/observability"
 	"github.com/hellofresh/janus/pkg/proxy/balancer"
 	"github.com/hellofresh/janus/pkg/proxy/transport"
 	"github.com/hellofresh/janus/pkg/router"
+	"github.com/hellofresh/janus/pkg/sampling"
 	"github.com/hellofresh/stats-go/client"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
 )
 
 const (
@@ -25,14 +29,22 @@ type Register struct {
 	idleConnectionsPerHost int
 	idleConnTimeout        time.Duration
 	flushInterval          time.Duration
+	buffering              Buffering
 	statsClient            client.Client
 	matcher                *router.ListenPathMatcher
+	paramNameExtractor     *router.ListenPathParameterNameExtractor
+	routes                 map[string]*routes
+	regexRoutes            *regexRoutes
+	inFlight               InFlightTracker
+	listeners              []string
 }
 
 // NewRegister creates a new instance of Register
 func NewRegister(opts ...RegisterOption) *Register {
 	r := Register{
-		matcher: router.NewListenPathMatcher(),
+		matcher:            router.NewListenPathMatcher(),
+		paramNameExtractor: router.NewListenPathParamNameExtractor(),
+		routes:             make(map[string]*routes),
 	}
 
 	for _, opt := range opts {
@@ -45,10 +57,16 @@ func NewRegister(opts ...RegisterOption) *Register {
 // UpdateRouter updates the reference to the router. This is useful to reload the mux
 func (p *Register) UpdateRouter(router router.Router) {
 	p.router = router
+	p.routes = make(map[string]*routes)
+	p.regexRoutes = nil
 }
 
 // Add register a new route
 func (p *Register) Add(definition *RouterDefinition) error {
+	if err := definition.ValidateListeners(p.listeners); err != nil {
+		return err
+	}
+
 	log.WithField("balancing_alg", definition.Upstreams.Balancing).Debug("Using a load balancing algorithm")
 	balancerInstance, err := balancer.New(definition.Upstreams.Balancing)
 	if err != nil {
@@ -57,26 +75,97 @@ func (p *Register) Add(definition *RouterDefinition) error {
 		return errors.Wrap(err, msg)
 	}
 
+	buffering := p.Buffering(definition.Definition)
 	handler := NewBalancedReverseProxy(definition.Definition, balancerInstance, p.statsClient)
-	handler.FlushInterval = p.flushInterval
-	handler.Transport = &ochttp.Transport{
-		Base: transport.New(
-			transport.WithIdleConnTimeout(p.idleConnTimeout),
-			transport.WithInsecureSkipVerify(definition.InsecureSkipVerify),
-			transport.WithDialTimeout(time.Duration(definition.ForwardingTimeouts.DialTimeout)),
-			transport.WithResponseHeaderTimeout(time.Duration(definition.ForwardingTimeouts.ResponseHeaderTimeout)),
-		),
+	handler.FlushInterval = buffering.flushInterval()
+	handler.ModifyResponse = modifyResponse
+	if buffering.FlushBufferSize > 0 {
+		handler.BufferPool = getBufferPool(buffering.FlushBufferSize)
+	}
+	baseTransport := transport.New(
+		transport.WithIdleConnTimeout(p.idleConnTimeout),
+		transport.WithReadBufferSize(buffering.ReadBufferSize),
+		transport.WithInsecureSkipVerify(definition.InsecureSkipVerify),
+		transport.WithDialTimeout(time.Duration(definition.ForwardingTimeouts.DialTimeout)),
+		transport.WithResponseHeaderTimeout(time.Duration(definition.ForwardingTimeouts.ResponseHeaderTimeout)),
+		transport.WithHTTP2Only(definition.GRPC.Enabled),
+	)
+	var upstreamTransport http.RoundTripper = &ochttp.Transport{Base: baseTransport, Propagation: obs.Propagation}
+	if definition.Hedging.Enabled {
+		upstreamTransport = newHedgingTransport(upstreamTransport, definition.Hedging)
+	}
+	handler.Transport = upstreamTimer{webSocketTransport{traced: upstreamTransport, upgrades: baseTransport}}
+	if p.inFlight != nil {
+		handler.Transport = inFlightTransport{base: handler.Transport, tracker: p.inFlight}
+	}
+	handler.Transport = electedTransport{base: handler.Transport}
+
+	rt, err := newRoute(definition, &ochttp.Handler{
+		Handler:          tagSpan(grpcHandler(limitWebSocket(handler, definition.WebSocket), definition.GRPC)),
+		Propagation:      obs.Propagation,
+		IsPublicEndpoint: true,
+		// the spans of the APIs with a sampling rate set at runtime are sampled by it
+		GetStartOptions: func(r *http.Request) trace.StartOptions {
+			return trace.StartOptions{Sampler: sampling.FromContext(r.Context())}
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// the aliases share the route, so the requests of all the listen paths go through the same plugins
+	// and upstreams
+	if len(definition.ListenPathAliases) == 0 {
+		return p.register(definition, definition.ListenPath, rt)
+	}
+	for _, listenPath := range definition.ListenPaths() {
+		if err := p.register(definition, listenPath, rt.withListenPath(listenPath)); err != nil {
+			return err
+		}
 	}
 
-	if p.matcher.Match(definition.ListenPath) {
-		p.doRegister(p.matcher.Extract(definition.ListenPath), definition, &ochttp.Handler{Handler: handler, IsPublicEndpoint: true})
+	return nil
+}
+
+// register registers the route on the listen path of the definition
+func (p *Register) register(def *RouterDefinition, listenPath string, rt *route) error {
+	if def.IsRegex() {
+		return p.doRegisterRegex(listenPath, rt)
 	}
 
-	p.doRegister(definition.ListenPath, definition, &ochttp.Handler{Handler: handler, IsPublicEndpoint: true})
+	if p.matcher.Match(listenPath) {
+		p.doRegister(p.matcher.Extract(listenPath), rt)
+	} else if p.isTemplated(listenPath) && !strings.HasSuffix(listenPath, "/") {
+		// templated listen paths match the request path with a trailing slash as well
+		p.doRegister(listenPath+"/", rt)
+	}
+
+	p.doRegister(listenPath, rt)
 	return nil
 }
 
-func (p *Register) doRegister(listenPath string, def *RouterDefinition, handler http.Handler) {
+// Buffering returns the buffering settings of the definition, the settings it does not set are taken
+// from the global ones. The gRPC streams are never buffered, their messages are sent as soon as they
+// are received.
+func (p *Register) Buffering(def *Definition) Buffering {
+	defaults := p.buffering
+	if defaults.FlushInterval == 0 {
+		defaults.FlushInterval = Duration(p.flushInterval)
+	}
+
+	buffering := def.Buffering.merge(defaults)
+	if def.GRPC.Enabled {
+		stream := true
+		buffering.Stream = &stream
+		buffering.BufferRequestBodySize = 0
+	}
+
+	return buffering
+}
+
+// doRegister adds the route to the routes of the listen path. The listen path is registered in the
+// router only once, so APIs sharing the listen path are dispatched by their hosts and methods.
+func (p *Register) doRegister(listenPath string, rt *route) {
 	log.WithFields(log.Fields{
 		"listen_path": listenPath,
 	}).Debug("Registering a route")
@@ -84,13 +173,59 @@ func (p *Register) doRegister(listenPath string, def *RouterDefinition, handler
 	if strings.Index(listenPath, "/") != 0 {
 		log.WithField("listen_path", listenPath).
 			Error("Route listen path must begin with '/'. Skipping invalid route.")
-	} else {
-		for _, method := range def.Methods {
-			if strings.ToUpper(method) == methodAll {
-				p.router.Any(listenPath, handler.ServeHTTP, def.middleware...)
-			} else {
-				p.router.Handle(strings.ToUpper(method), listenPath, handler.ServeHTTP, def.middleware...)
-			}
-		}
+		return
+	}
+
+	rs, ok := p.routes[listenPath]
+	if !ok {
+		rs = &routes{}
+		p.routes[listenPath] = rs
+		p.router.Any(listenPath, rs.ServeHTTP)
 	}
+	rs.add(rt)
+}
+
+func (p *Register) isTemplated(listenPath string) bool {
+	return len(p.paramNameExtractor.Extract(listenPath)) > 0
+}
+
+func (p *Register) doRegisterRegex(listenPath string, rt *route) error {
+	pattern, err := compileListenPath(listenPath)
+	if err != nil {
+		return errors.Wrap(err, "could not compile the listen path regular expression")
+	}
+
+	log.WithField("listen_path", listenPath).Debug("Registering a regex route")
+
+	// regex routes are served by the router not found handler, so literal routes always win
+	if p.regexRoutes == nil {
+		p.regexRoutes = newRegexRoutes(p.router.NotFoundHandler())
+		p.router.NotFound(p.regexRoutes.ServeHTTP)
+	}
+	p.regexRoutes.add(pattern, rt)
+
+	return nil
+}
+
+// upstreamTimer records the time spent waiting for the upstream response headers, the time spent in
+// the gateway is told apart from it by the slow log
+type upstreamTimer struct {
+	base http.RoundTripper
+}
+
+// RoundTrip sends the request to the upstream
+func (t upstreamTimer) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	middleware.AddUpstreamLatency(req.Context(), time.Since(start))
+
+	return resp, err
+}
+
+// tagSpan adds the tags of the API to the server span of the request, started by the tracing handler
+func tagSpan(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		middleware.AddSpanTags(r.Context(), trace.FromContext(r.Context()))
+		handler.ServeHTTP(w, r)
+	})
 }
@@ -24,6 +24,14 @@ func WithFlushInterval(d time.Duration) RegisterOption {
 	}
 }
 
+// WithBuffering sets the default buffering settings of the APIs, the flush interval set with
+// WithFlushInterval is used when it is not set
+func WithBuffering(buffering Buffering) RegisterOption {
+	return func(r *Register) {
+		r.buffering = buffering
+	}
+}
+
 // WithIdleConnectionsPerHost sets idle connections per host option
 func WithIdleConnectionsPerHost(value int) RegisterOption {
 	return func(r *Register) {
@@ -46,3 +54,18 @@ func WithIdleConnTimeout(d time.Duration) RegisterOption {
 		r.idleConnTimeout = d
 	}
 }
+
+// WithInFlightTracker sets the tracker counting the requests in flight to the upstream targets
+func WithInFlightTracker(tracker InFlightTracker) RegisterOption {
+	return func(r *Register) {
+		r.inFlight = tracker
+	}
+}
+
+// WithListeners sets the names of the proxy listeners besides the default one, the definitions bound to
+// other listeners are not registered
+func WithListeners(names ...string) RegisterOption {
+	return func(r *Register) {
+		r.listeners = names
+	}
+}
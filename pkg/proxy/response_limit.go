@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/hellofresh/janus/pkg/middleware"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrResponseBodyTooLarge fails the upstream calls whose response body is larger than the max response
+// body size of an API buffering the bodies, they are answered with 502 Bad Gateway
+var ErrResponseBodyTooLarge = errors.New("upstream response body is larger than the max size")
+
+// responseLimit is the max response body size of the requests of an API
+type responseLimit struct {
+	maxSize  int64
+	enforced bool
+}
+
+// LimitResponses caps the upstream response bodies of the requests to the max size, the loader adds it
+// to the APIs setting one. When it is enforced, e.g. a plugin of the API keeps the bodies in memory, the
+// larger responses fail the upstream call before their bodies are read beyond the max size, otherwise
+// they are streamed and logged.
+func LimitResponses(maxSize int64, enforced bool) func(http.Handler) http.Handler {
+	limit := responseLimit{maxSize: maxSize, enforced: enforced}
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), responseLimitKey, limit)
+			handler.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// limitResponse is the reverse proxy response modifier checking the upstream response body against the
+// max size. The bodies without a Content-Length of the enforcing APIs are read up to the max size before
+// the response is sent, so the larger ones can still be answered with an error.
+func limitResponse(resp *http.Response) error {
+	limit, ok := resp.Request.Context().Value(responseLimitKey).(responseLimit)
+	if !ok || !hasBody(resp) {
+		return nil
+	}
+
+	logger := middleware.ContextLogger(resp.Request.Context()).WithFields(log.Fields{
+		"path":     resp.Request.URL.Path,
+		"max_size": limit.maxSize,
+	})
+
+	if !limit.enforced {
+		if resp.ContentLength > limit.maxSize {
+			logger.WithField("content_length", resp.ContentLength).Warn("The upstream response body is larger than the max size")
+			return nil
+		}
+		resp.Body = &advisoryBody{ReadCloser: resp.Body, remaining: limit.maxSize, logger: logger}
+		return nil
+	}
+
+	if resp.ContentLength > limit.maxSize {
+		resp.Body.Close()
+		return ErrResponseBodyTooLarge
+	}
+	if resp.ContentLength >= 0 {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, limit.maxSize+1))
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	if int64(len(body)) > limit.maxSize {
+		return ErrResponseBodyTooLarge
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return nil
+}
+
+// advisoryBody streams the upstream body, and logs once more than the max size is read
+type advisoryBody struct {
+	io.ReadCloser
+	remaining int64
+	logger    *log.Entry
+}
+
+func (b *advisoryBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if b.remaining >= 0 {
+		b.remaining -= int64(n)
+		if b.remaining < 0 {
+			b.logger.Warn("The upstream response body is larger than the max size")
+		}
+	}
+
+	return n, err
+}
@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hellofresh/janus/pkg/router"
+	"github.com/hellofresh/stats-go/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingReader counts the bytes read from the body
+type countingReader struct {
+	io.Reader
+	read int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.read += int64(n)
+	return n, err
+}
+
+func (r *countingReader) Close() error {
+	return nil
+}
+
+func TestLimitResponse(t *testing.T) {
+	newResponse := func(limit responseLimit, contentLength int64) (*http.Response, *countingReader) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req = req.WithContext(context.WithValue(req.Context(), responseLimitKey, limit))
+		body := &countingReader{Reader: strings.NewReader(strings.Repeat("a", 1<<20))}
+		return &http.Response{StatusCode: http.StatusOK, ContentLength: contentLength, Body: body, Request: req}, body
+	}
+
+	resp, body := newResponse(responseLimit{maxSize: 1024, enforced: true}, -1)
+	assert.Equal(t, ErrResponseBodyTooLarge, limitResponse(resp))
+	assert.Equal(t, int64(1025), body.read, "the body is not read beyond the max size")
+
+	resp, body = newResponse(responseLimit{maxSize: 1024, enforced: true}, 1<<20)
+	assert.Equal(t, ErrResponseBodyTooLarge, limitResponse(resp))
+	assert.Zero(t, body.read, "the body of a larger Content-Length is not read")
+
+	resp, _ = newResponse(responseLimit{maxSize: 2 << 20, enforced: true}, -1)
+	require.NoError(t, limitResponse(resp))
+	b, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Len(t, b, 1<<20, "the bodies within the max size are sent")
+
+	resp, body = newResponse(responseLimit{maxSize: 1024}, -1)
+	require.NoError(t, limitResponse(resp))
+	assert.Zero(t, body.read)
+	b, err = ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Len(t, b, 1<<20, "the larger bodies of the APIs not enforcing the max size are streamed")
+}
+
+func TestLimitedResponses(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+		if r.URL.Query().Get("chunked") == "" {
+			w.Header().Set("Content-Length", strconv.Itoa(size))
+		}
+		io.WriteString(w, strings.Repeat("a", size))
+	}))
+	defer upstream.Close()
+
+	newGateway := func(enforced bool) http.Handler {
+		r := router.NewChiRouter()
+		register := NewRegister(WithRouter(r), WithStatsClient(client.NewNoop()))
+		def := NewDefinition()
+		def.ListenPath = "/api/*"
+		def.StripPath = true
+		def.Upstreams = &Upstreams{Balancing: "roundrobin", Targets: []*Target{{Target: upstream.URL}}}
+		routerDef := NewRouterDefinition(def)
+		routerDef.AddMiddleware(LimitResponses(1024, enforced))
+		require.NoError(t, register.Add(routerDef))
+
+		return r
+	}
+	get := func(gateway http.Handler, query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		gateway.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/?"+query, nil))
+		return w
+	}
+
+	gateway := newGateway(true)
+	assert.Equal(t, http.StatusOK, get(gateway, "size=1024").Code)
+	assert.Equal(t, http.StatusOK, get(gateway, "size=1024&chunked=1").Code)
+	assert.Equal(t, http.StatusBadGateway, get(gateway, "size=4096").Code)
+	w := get(gateway, "size=4096&chunked=1")
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	gateway = newGateway(false)
+	w = get(gateway, "size=4096&chunked=1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 4096, w.Body.Len(), "the max size is only logged when it is not enforced")
+}
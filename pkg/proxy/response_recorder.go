@@ -0,0 +1,102 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/felixge/httpsnoop"
+)
+
+// ResponseRecorder keeps a copy of the response written to the client, the body is kept up to the max
+// size, so the plugins replaying or comparing the responses never hold the larger ones in memory
+type ResponseRecorder struct {
+	maxBodySize int64
+	statusCode  int
+	header      http.Header
+	body        bytes.Buffer
+	truncated   bool
+}
+
+// NewResponseRecorder creates a new instance of ResponseRecorder
+func NewResponseRecorder(maxBodySize int64) *ResponseRecorder {
+	return &ResponseRecorder{maxBodySize: maxBodySize}
+}
+
+// Wrap returns the response writer recording the response written to w
+func (rec *ResponseRecorder) Wrap(w http.ResponseWriter) http.ResponseWriter {
+	return httpsnoop.Wrap(w, httpsnoop.Hooks{
+		WriteHeader: func(next httpsnoop.WriteHeaderFunc) httpsnoop.WriteHeaderFunc {
+			return func(code int) {
+				rec.writeHeader(w, code)
+				next(code)
+			}
+		},
+		Write: func(next httpsnoop.WriteFunc) httpsnoop.WriteFunc {
+			return func(b []byte) (int, error) {
+				rec.writeHeader(w, http.StatusOK)
+				n, err := next(b)
+				rec.write(b[:n])
+				return n, err
+			}
+		},
+		ReadFrom: func(next httpsnoop.ReadFromFunc) httpsnoop.ReadFromFunc {
+			return func(src io.Reader) (int64, error) {
+				rec.writeHeader(w, http.StatusOK)
+				return next(io.TeeReader(src, writerFunc(rec.write)))
+			}
+		},
+	})
+}
+
+// StatusCode returns the status code of the response, it returns 0 when nothing was written
+func (rec *ResponseRecorder) StatusCode() int {
+	return rec.statusCode
+}
+
+// Header returns a copy of the headers of the response taken when the status code was written
+func (rec *ResponseRecorder) Header() http.Header {
+	return rec.header
+}
+
+// Body returns the body of the response, it returns nil when the body is larger than the max size
+func (rec *ResponseRecorder) Body() []byte {
+	if rec.truncated {
+		return nil
+	}
+
+	return rec.body.Bytes()
+}
+
+// Truncated tells if the body is larger than the max size
+func (rec *ResponseRecorder) Truncated() bool {
+	return rec.truncated
+}
+
+func (rec *ResponseRecorder) writeHeader(w http.ResponseWriter, code int) {
+	if rec.statusCode != 0 {
+		return
+	}
+
+	rec.statusCode = code
+	rec.header = make(http.Header, len(w.Header()))
+	for name, values := range w.Header() {
+		rec.header[name] = append([]string(nil), values...)
+	}
+}
+
+func (rec *ResponseRecorder) write(b []byte) (int, error) {
+	if rec.truncated || int64(rec.body.Len()+len(b)) > rec.maxBodySize {
+		rec.truncated = true
+		rec.body.Reset()
+		return len(b), nil
+	}
+
+	return rec.body.Write(b)
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(b []byte) (int, error) {
+	return f(b)
+}
@@ -0,0 +1,45 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseRecorder(t *testing.T) {
+	rec := NewResponseRecorder(16)
+	w := httptest.NewRecorder()
+	rw := rec.Wrap(w)
+
+	rw.Header().Set("Content-Type", "text/plain")
+	rw.Write([]byte("hello "))
+	rw.Header().Set("X-Late", "true")
+	rw.Write([]byte("world"))
+
+	assert.Equal(t, http.StatusOK, rec.StatusCode())
+	assert.Equal(t, "text/plain", rec.Header().Get("Content-Type"))
+	assert.Empty(t, rec.Header().Get("X-Late"), "the headers are the ones sent with the status code")
+	assert.Equal(t, "hello world", string(rec.Body()))
+	assert.False(t, rec.Truncated())
+	assert.Equal(t, "hello world", w.Body.String())
+
+	assert.Equal(t, 0, NewResponseRecorder(16).StatusCode())
+}
+
+func TestResponseRecorderMaxBodySize(t *testing.T) {
+	rec := NewResponseRecorder(16)
+	w := httptest.NewRecorder()
+	rw := rec.Wrap(w)
+
+	rw.WriteHeader(http.StatusCreated)
+	rw.Write([]byte(strings.Repeat("a", 10)))
+	rw.Write([]byte(strings.Repeat("b", 10)))
+
+	assert.Equal(t, http.StatusCreated, rec.StatusCode())
+	assert.True(t, rec.Truncated())
+	assert.Nil(t, rec.Body(), "the larger bodies are not kept")
+	assert.Equal(t, 20, w.Body.Len(), "the response is written to the client")
+}
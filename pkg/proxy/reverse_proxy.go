@@ -1,14 +1,17 @@
 package proxy
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"os"
+	"regexp"
 	"strings"
 
 	"github.com/go-chi/chi"
+	httpErrors "github.com/hellofresh/janus/pkg/errors"
 	"github.com/hellofresh/janus/pkg/middleware"
 	obs "github.com/hellofresh/janus/pkg/observability"
 	"github.com/hellofresh/janus/pkg/proxy/balancer"
@@ -25,28 +28,115 @@ const (
 	statsSection = "upstream"
 )
 
+var quotedParameterPlaceholder = regexp.MustCompile(`\\\{[^/]+?\\\}`)
+
 // NewBalancedReverseProxy creates a reverse proxy that is load balanced
 func NewBalancedReverseProxy(def *Definition, balancer balancer.Balancer, statsClient client.Client) *httputil.ReverseProxy {
 	return &httputil.ReverseProxy{
-		Director: createDirector(def, balancer, statsClient),
+		Director:     createDirector(def, balancer, statsClient),
+		ErrorHandler: handleProxyError,
 	}
 }
 
+// ErrBadGateway is used when the upstream call failed and the errors are rendered as problem details
+// or with the error templates
+var ErrBadGateway = httpErrors.New(http.StatusBadGateway, "upstream call failed")
+
+// handleProxyError answers the failed upstream calls by the way they failed: 504 Gateway Timeout when
+// the request timeout of the API cancelled the call or the upstream timed out, 502 Bad Gateway when the
+// upstream refused or reset the connection, and 503 Service Unavailable when no upstream target could
+// be elected. The reason of the failure is logged and tagged on the span of the proxied request.
+func handleProxyError(w http.ResponseWriter, req *http.Request, err error) {
+	httpErr, reason := classifyUpstreamError(req.Context(), err)
+
+	span := trace.FromContext(req.Context())
+	if span != nil {
+		httpErrors.SetTraceID(w, span.SpanContext().TraceID.String())
+		span.AddAttributes(
+			trace.BoolAttribute("error", true),
+			trace.StringAttribute("error.message", httpErr.Error()),
+			trace.StringAttribute("error.reason", reason),
+		)
+	}
+
+	logger := middleware.ContextLogger(req.Context()).WithError(err).WithField("reason", reason)
+	if reason == reasonRequestTimeout {
+		// the timeout middleware logs the request timeouts
+		logger.Debug("http: proxy error")
+	} else {
+		logger.Error("http: proxy error")
+	}
+
+	switch {
+	case isGRPC(req):
+		// the gRPC clients read the status of the call rather than the HTTP status code
+		writeGRPCStatus(w, grpcCode(httpErr.Code), httpErr.Message)
+	case reason == reasonRequestTimeout, httpErrors.ProblemDetailsEnabled(w), httpErrors.TemplatesEnabled(w):
+		httpErrors.Handler(w, httpErr)
+	default:
+		w.WriteHeader(httpErr.Code)
+	}
+}
+
+// modifyResponse caps the upstream response bodies and decodes them for the plugins when the API was
+// set up to
+func modifyResponse(resp *http.Response) error {
+	if err := limitResponse(resp); err != nil {
+		return err
+	}
+
+	return decodeResponse(resp)
+}
+
 func createDirector(proxyDefinition *Definition, balancer balancer.Balancer, statsClient client.Client) func(req *http.Request) {
 	paramNameExtractor := router.NewListenPathParamNameExtractor()
 	matcher := router.NewListenPathMatcher()
 
+	// regex and templated listen paths are stripped by matching the request path
+	listenPathRegexps := make(map[string]*regexp.Regexp)
+	for _, listenPath := range proxyDefinition.ListenPaths() {
+		if proxyDefinition.IsRegex() {
+			listenPathRegexp, err := compileListenPath(listenPath)
+			if err != nil {
+				log.WithError(err).WithField("listen_path", listenPath).Error("Could not compile the listen path regular expression")
+			}
+			listenPathRegexps[listenPath] = listenPathRegexp
+		} else if len(paramNameExtractor.Extract(listenPath)) > 0 {
+			listenPathRegexps[listenPath] = templateRegexp(matcher.Extract(listenPath))
+		}
+	}
+
+	rewrites, err := compilePathRewrites(proxyDefinition.PathRewrites)
+	if err != nil {
+		log.WithError(err).Error("Could not compile the path rewrites")
+	}
+
 	return func(req *http.Request) {
-		upstream, err := balancer.Elect(proxyDefinition.Upstreams.Targets.ToBalancerTargets())
+		// the path is stripped of the listen path or the alias the request matched
+		listenPath, ok := req.Context().Value(listenPathKey).(string)
+		if !ok {
+			listenPath = proxyDefinition.ListenPath
+		}
+		listenPathRegexp := listenPathRegexps[listenPath]
+
+		targets, ok := UpstreamTargetsFromContext(req.Context())
+		if !ok {
+			targets = proxyDefinition.Upstreams.Targets
+		}
+
+		upstream, err := balancer.Elect(targets.ToBalancerTargets())
 		if err != nil {
-			log.WithError(err).Error("Could not elect one upstream")
+			middleware.ContextLogger(req.Context()).WithError(err).Error("Could not elect one upstream")
+			*req = *req.WithContext(context.WithValue(req.Context(), electionErrorKey, err))
 			return
 		}
 		log.WithField("target", upstream.Target).Debug("Target upstream elected")
+		middleware.SetAccessLogUpstream(req.Context(), upstream.Target)
+		*req = *req.WithContext(context.WithValue(req.Context(), electedKey, elected{target: upstream.Target, targets: targets}))
 
 		target, err := url.Parse(upstream.Target)
 		if err != nil {
-			log.WithError(err).WithField("upstream_url", upstream.Target).Error("Could not parse the target URL")
+			middleware.ContextLogger(req.Context()).WithError(err).WithField("upstream_url", upstream.Target).Error("Could not parse the target URL")
 			return
 		}
 
@@ -61,9 +151,15 @@ func createDirector(proxyDefinition *Definition, balancer balancer.Balancer, sta
 			path = singleJoiningSlash(target.Path, req.URL.Path)
 		}
 
-		if proxyDefinition.StripPath {
+		if proxyDefinition.StripPath && listenPathRegexp != nil {
+			log.WithField("listen_path", listenPath).Debug("Stripping listen path")
+			path = singleJoiningSlash(target.Path, stripRegexp(listenPathRegexp, req.URL.Path))
+			if !strings.HasSuffix(target.Path, "/") && strings.HasSuffix(path, "/") {
+				path = path[:len(path)-1]
+			}
+		} else if proxyDefinition.StripPath {
 			path = singleJoiningSlash(target.Path, req.URL.Path)
-			listenPath := matcher.Extract(proxyDefinition.ListenPath)
+			listenPath := matcher.Extract(listenPath)
 
 			log.WithField("listen_path", listenPath).Debug("Stripping listen path")
 			path = strings.Replace(path, listenPath, "", 1)
@@ -75,14 +171,29 @@ func createDirector(proxyDefinition *Definition, balancer balancer.Balancer, sta
 		paramNames := paramNameExtractor.Extract(path)
 		parametrizedPath, err := applyParameters(req, path, paramNames)
 		if err != nil {
-			log.WithError(err).Warn("Unable to extract param from request")
+			middleware.ContextLogger(req.Context()).WithError(err).Warn("Unable to extract param from request")
 		} else {
 			path = parametrizedPath
 		}
 
+		// the path rewritten from the request path replaces the stripped or appended one, the request
+		// keeps its own path for the logs
+		rewritten, rewrittenQuery, ok, err := rewrites.apply(req)
+		if err != nil {
+			middleware.ContextLogger(req.Context()).WithError(err).Warn("Unable to rewrite the path")
+		} else if ok {
+			path = singleJoiningSlash(target.Path, rewritten)
+			req.URL.RawQuery = joinQuery(rewrittenQuery, req.URL.RawQuery)
+		}
+
 		log.WithField("path", path).Debug("Upstream Path")
 		req.URL.Path = path
 
+		// the responses decoded for the plugins are asked only in the encodings that can be decoded
+		if _, ok := decompressionFromContext(req.Context()); ok {
+			req.Header.Set("Accept-Encoding", acceptedEncodings())
+		}
+
 		// This is very important to avoid problems with ssl verification for the HOST header
 		if proxyDefinition.PreserveHost {
 			log.Debug("Preserving the host header")
@@ -111,6 +222,7 @@ func createDirector(proxyDefinition *Definition, balancer balancer.Balancer, sta
 
 		// Add additional trace attributes
 		addTraceAttributes(req)
+		middleware.ForwardBaggage(req)
 
 		// Insert additional tags
 		ctx, _ := tag.New(req.Context(), tag.Insert(obs.KeyUpstreamPath, upstream.Target))
@@ -131,12 +243,19 @@ func addTraceAttributes(req *http.Request) {
 		host = "unknown"
 	}
 
+	middleware.SetAccessLogTraceID(ctx, span.SpanContext().TraceID.String())
 	span.AddAttributes(
 		trace.StringAttribute("http.host", host),
 		trace.StringAttribute("http.referrer", req.Referer()),
 		trace.StringAttribute("http.remote_address", req.RemoteAddr),
 		trace.StringAttribute("request.id", middleware.RequestIDFromContext(ctx)),
 	)
+	if key, correlationID, ok := middleware.CorrelationBaggage(ctx); ok {
+		span.AddAttributes(trace.StringAttribute(key, correlationID))
+	}
+	if variant := VariantFromContext(ctx); variant != "" {
+		span.AddAttributes(trace.StringAttribute("ab.variant", variant))
+	}
 }
 
 func applyParameters(req *http.Request, path string, paramNames []string) (string, error) {
@@ -158,6 +277,25 @@ func applyParameters(req *http.Request, path string, paramNames []string) (strin
 	return path, nil
 }
 
+// templateRegexp converts a templated listen path, e.g. /users/{id}, to a regular expression
+// matching the request paths where every parameter is a single path segment
+func templateRegexp(listenPath string) *regexp.Regexp {
+	pattern := regexp.QuoteMeta(listenPath)
+	pattern = quotedParameterPlaceholder.ReplaceAllString(pattern, "[^/]+")
+
+	return regexp.MustCompile("^" + pattern)
+}
+
+// stripRegexp removes the part of the path matched by the listen path regular expression
+func stripRegexp(re *regexp.Regexp, path string) string {
+	loc := re.FindStringIndex(path)
+	if loc == nil {
+		return path
+	}
+
+	return path[loc[1]:]
+}
+
 func singleJoiningSlash(a, b string) string {
 	a = cleanSlashes(a)
 	b = cleanSlashes(b)
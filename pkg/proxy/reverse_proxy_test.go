@@ -0,0 +1,188 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	httpErrors "github.com/hellofresh/janus/pkg/errors"
+	"github.com/hellofresh/janus/pkg/middleware"
+	"github.com/hellofresh/janus/pkg/proxy/balancer"
+	"github.com/hellofresh/janus/pkg/router"
+	"github.com/hellofresh/stats-go/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleProxyError(t *testing.T) {
+	w := httptest.NewRecorder()
+	handleProxyError(w, httptest.NewRequest(http.MethodGet, "/", nil), errors.New("connection refused"))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+
+	w = httptest.NewRecorder()
+	handleProxyError(w, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx), ctx.Err())
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+
+	w = httptest.NewRecorder()
+	handleProxyError(w, httptest.NewRequest(http.MethodGet, "/", nil), &noUpstreamError{err: balancer.ErrEmptyBackendList})
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestClassifyUpstreamError(t *testing.T) {
+	opError := func(err error) error {
+		return &net.OpError{Op: "read", Net: "tcp", Err: &os.SyscallError{Syscall: "read", Err: err}}
+	}
+
+	tests := []struct {
+		scenario string
+		err      error
+		code     int
+		reason   string
+	}{
+		{scenario: "dial timeout", err: &net.OpError{Op: "dial", Net: "tcp", Err: timeoutError{}}, code: http.StatusGatewayTimeout, reason: reasonUpstreamTimeout},
+		{scenario: "deadline", err: &url.Error{Op: "Get", URL: "http://upstream", Err: context.DeadlineExceeded}, code: http.StatusGatewayTimeout, reason: reasonUpstreamTimeout},
+		{scenario: "refused", err: opError(syscall.ECONNREFUSED), code: http.StatusBadGateway, reason: reasonConnectionRefused},
+		{scenario: "reset", err: opError(syscall.ECONNRESET), code: http.StatusBadGateway, reason: reasonConnectionReset},
+		{scenario: "closed", err: io.EOF, code: http.StatusBadGateway, reason: reasonConnectionReset},
+		{scenario: "no healthy upstream", err: &noUpstreamError{err: balancer.ErrZeroWeight}, code: http.StatusServiceUnavailable, reason: reasonNoHealthyUpstream},
+		{scenario: "response too large", err: ErrResponseBodyTooLarge, code: http.StatusBadGateway, reason: reasonResponseTooLarge},
+		{scenario: "other", err: errors.New("malformed HTTP response"), code: http.StatusBadGateway, reason: reasonUpstreamError},
+	}
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+			httpErr, reason := classifyUpstreamError(context.Background(), test.err)
+			assert.Equal(t, test.code, httpErr.Code)
+			assert.Equal(t, test.reason, reason)
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+
+	httpErr, reason := classifyUpstreamError(ctx, opError(syscall.ECONNRESET))
+	assert.Equal(t, middleware.ErrRequestTimeout, httpErr, "the request timeout of the API wins over the upstream error")
+	assert.Equal(t, reasonRequestTimeout, reason)
+}
+
+func TestProxyUpstreamFailures(t *testing.T) {
+	// the listener is closed, so the connections to its address are refused
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	refusedURL := "http://" + closed.Addr().String()
+	closed.Close()
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer slow.Close()
+
+	reset := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		require.NoError(t, err)
+		// the connection is reset rather than closed gracefully
+		conn.(*net.TCPConn).SetLinger(0)
+		conn.Close()
+	}))
+	defer reset.Close()
+
+	r := router.NewChiRouter()
+	register := NewRegister(WithRouter(r), WithStatsClient(client.NewNoop()))
+	add := func(listenPath, balancing string, targets ...*Target) {
+		def := NewDefinition()
+		def.ListenPath = listenPath
+		def.Upstreams = &Upstreams{Balancing: balancing, Targets: targets}
+		def.ForwardingTimeouts.ResponseHeaderTimeout = Duration(50 * time.Millisecond)
+		require.NoError(t, register.Add(NewRouterDefinition(def)))
+	}
+	add("/refused", "roundrobin", &Target{Target: refusedURL})
+	add("/timeout", "roundrobin", &Target{Target: slow.URL})
+	add("/reset", "roundrobin", &Target{Target: reset.URL})
+	add("/drained", "weight", &Target{Target: slow.URL, Weight: 0})
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{path: "/refused", code: http.StatusBadGateway},
+		{path: "/timeout", code: http.StatusGatewayTimeout},
+		{path: "/reset", code: http.StatusBadGateway},
+		{path: "/drained", code: http.StatusServiceUnavailable},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+			assert.Equal(t, test.code, w.Code)
+		})
+	}
+}
+
+func TestHandleProxyErrorProblemDetails(t *testing.T) {
+	handler := httpErrors.ProblemDetails(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleProxyError(w, r, errors.New("connection refused"))
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Equal(t, httpErrors.ProblemContentType, w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"type": "about:blank", "title": "Bad Gateway", "status": 502, "detail": "upstream call failed"}`, w.Body.String())
+}
+
+func TestProxyForwardsTrailers(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Checksum")
+		w.Header().Set("Content-Type", "application/grpc-web")
+		w.Write([]byte("first"))
+		w.(http.Flusher).Flush()
+		w.Write([]byte(" second"))
+		w.Header().Set("X-Checksum", "f2b4c0a1")
+		w.Header().Set(http.TrailerPrefix+"Grpc-Status", "0")
+	}))
+	defer upstream.Close()
+
+	r := router.NewChiRouter()
+	register := NewRegister(WithRouter(r), WithStatsClient(client.NewNoop()))
+	def := NewDefinition()
+	def.ListenPath = "/stream"
+	def.Upstreams = &Upstreams{Balancing: "roundrobin", Targets: []*Target{{Target: upstream.URL}}}
+	require.NoError(t, register.Add(NewRouterDefinition(def)))
+
+	gateway := httptest.NewServer(r)
+	defer gateway.Close()
+
+	res, err := http.Get(gateway.URL + "/stream")
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	assert.Equal(t, []string{"chunked"}, res.TransferEncoding)
+	assert.Contains(t, res.Trailer, "X-Checksum", "the trailers are announced")
+
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "first second", string(body))
+	assert.Equal(t, "f2b4c0a1", res.Trailer.Get("X-Checksum"), "the declared trailers are sent after the body")
+	assert.Equal(t, "0", res.Trailer.Get("Grpc-Status"), "the undeclared trailers are sent after the body")
+}
@@ -0,0 +1,125 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/hellofresh/janus/pkg/router"
+)
+
+// captureReference matches the ${name} references to the capture groups, which are not listen path parameters
+var captureReference = regexp.MustCompile(`\$\{\w+\}`)
+
+// PathRewrite rewrites the upstream path and query string of the requests whose path matches the pattern,
+// for the upstreams whose paths can not be derived by stripping or appending the listen path
+type PathRewrite struct {
+	// Pattern is the regular expression matched against the whole request path
+	Pattern string `bson:"pattern" json:"pattern"`
+	// Template is the upstream path with an optional query string, it references the capture groups of
+	// the pattern as $1 or ${name} and the parameters of the listen path as {name}
+	Template string `bson:"template" json:"template"`
+}
+
+// pathRewrite is a compiled path rewrite, the query string of the template is parsed so the captured
+// values are escaped once expanded
+type pathRewrite struct {
+	pattern *regexp.Regexp
+	path    string
+	query   url.Values
+}
+
+type pathRewrites []*pathRewrite
+
+func (r PathRewrite) compile() (*pathRewrite, error) {
+	pattern, err := regexp.Compile("^(?:" + r.Pattern + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("proxy.path_rewrites pattern %q is not a valid regular expression: %v", r.Pattern, err)
+	}
+	if !strings.HasPrefix(r.Template, "/") {
+		return nil, fmt.Errorf("proxy.path_rewrites template %q must begin with '/'", r.Template)
+	}
+
+	path, rawQuery := r.Template, ""
+	if i := strings.Index(path, "?"); i >= 0 {
+		path, rawQuery = path[:i], path[i+1:]
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("proxy.path_rewrites template %q query string is not valid: %v", r.Template, err)
+	}
+
+	return &pathRewrite{pattern: pattern, path: path, query: query}, nil
+}
+
+// compilePathRewrites compiles the path rewrites of a definition, in their order
+func compilePathRewrites(rewrites []PathRewrite) (pathRewrites, error) {
+	compiled := make(pathRewrites, 0, len(rewrites))
+	for _, rewrite := range rewrites {
+		c, err := rewrite.compile()
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, c)
+	}
+
+	return compiled, nil
+}
+
+// apply returns the upstream path and query string of the first rewrite matching the request path
+func (rs pathRewrites) apply(req *http.Request) (string, string, bool, error) {
+	for _, rw := range rs {
+		match := rw.pattern.FindStringSubmatchIndex(req.URL.Path)
+		if match == nil {
+			continue
+		}
+
+		path, err := rw.expand(req, match, rw.path)
+		if err != nil {
+			return "", "", true, err
+		}
+
+		query := make(url.Values, len(rw.query))
+		for name, values := range rw.query {
+			for _, value := range values {
+				if value, err = rw.expand(req, match, value); err != nil {
+					return "", "", true, err
+				}
+				query.Add(name, value)
+			}
+		}
+
+		return path, query.Encode(), true, nil
+	}
+
+	return "", "", false, nil
+}
+
+// expand replaces the references to the capture groups and to the listen path parameters in the template
+func (rw *pathRewrite) expand(req *http.Request, match []int, template string) (string, error) {
+	expanded := string(rw.pattern.ExpandString(nil, template, req.URL.Path, match))
+
+	paramNames := router.NewListenPathParamNameExtractor().Extract(captureReference.ReplaceAllString(template, ""))
+	if len(paramNames) == 0 {
+		return expanded, nil
+	}
+
+	return applyParameters(req, expanded, paramNames)
+}
+
+// validatePathRewrites checks the path rewrites compile
+func validatePathRewrites(rewrites []PathRewrite) error {
+	_, err := compilePathRewrites(rewrites)
+	return err
+}
+
+// joinQuery returns the query string of the rewrite followed by the query string of the request
+func joinQuery(rewritten, query string) string {
+	if rewritten == "" || query == "" {
+		return rewritten + query
+	}
+
+	return rewritten + "&" + query
+}
@@ -0,0 +1,124 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/hellofresh/janus/pkg/router"
+	"github.com/hellofresh/stats-go/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathRewritesApply(t *testing.T) {
+	rewrites, err := compilePathRewrites([]PathRewrite{
+		{Pattern: `/public/v2/orders/([0-9]+)/items/(?P<item>[a-z]+)`, Template: `/internal/orders/${1}/lines?order_id=$1&line=${item}&source=gateway`},
+		{Pattern: `/public/v2/orders/([0-9]+)`, Template: `/internal/orders?order_id=$1`},
+		{Pattern: `/search/(.+)`, Template: `/find?q=$1`},
+	})
+	require.NoError(t, err)
+
+	apply := func(target string) (string, url.Values, bool) {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		path, rawQuery, ok, err := rewrites.apply(req)
+		require.NoError(t, err)
+
+		query, err := url.ParseQuery(rawQuery)
+		require.NoError(t, err)
+		return path, query, ok
+	}
+
+	path, query, ok := apply("/public/v2/orders/42/items/abc")
+	assert.True(t, ok)
+	assert.Equal(t, "/internal/orders/42/lines", path)
+	assert.Equal(t, url.Values{"order_id": {"42"}, "line": {"abc"}, "source": {"gateway"}}, query)
+
+	path, query, ok = apply("/public/v2/orders/42")
+	assert.True(t, ok, "the first matching rewrite applies")
+	assert.Equal(t, "/internal/orders", path)
+	assert.Equal(t, url.Values{"order_id": {"42"}}, query)
+
+	_, query, _ = apply("/search/a&b%20c")
+	assert.Equal(t, url.Values{"q": {"a&b c"}}, query, "the captured values are escaped in the query string")
+
+	_, _, ok = apply("/public/v2/orders/42/cancel")
+	assert.False(t, ok, "the pattern matches the whole path")
+	_, _, ok = apply("/v1/public/v2/orders/42")
+	assert.False(t, ok)
+}
+
+func TestPathRewritesApplyParameters(t *testing.T) {
+	rewrites, err := compilePathRewrites([]PathRewrite{
+		{Pattern: `/public/v2/orders/[^/]+/(\w+)`, Template: `/internal/orders/{id}?view=$1&order={id}`},
+	})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/public/v2/orders/42/summary", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "42")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	path, rawQuery, ok, err := rewrites.apply(req)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "/internal/orders/42", path)
+	assert.Equal(t, "order=42&view=summary", rawQuery)
+}
+
+func TestPathRewritesValidate(t *testing.T) {
+	assert.NoError(t, validatePathRewrites([]PathRewrite{{Pattern: `/orders/(\d+)`, Template: "/internal/orders?id=$1"}}))
+	assert.Error(t, validatePathRewrites([]PathRewrite{{Pattern: `/orders/(\d+`, Template: "/internal/orders"}}))
+	assert.Error(t, validatePathRewrites([]PathRewrite{{Pattern: `/orders/(\d+)`, Template: "internal/orders"}}))
+	assert.Error(t, validatePathRewrites([]PathRewrite{{Pattern: `/orders/(\d+)`, Template: "/internal/orders?id=%zz"}}))
+
+	def := NewDefinition()
+	def.ListenPath = "/orders/*"
+	def.PathRewrites = []PathRewrite{{Pattern: `/orders/(\d+`, Template: "/internal/orders"}}
+	ok, err := def.Validate()
+	assert.False(t, ok)
+	assert.Error(t, err)
+}
+
+func TestProxyPathRewrites(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.URL.RequestURI())
+	}))
+	defer upstream.Close()
+
+	r := router.NewChiRouter()
+	register := NewRegister(WithRouter(r), WithStatsClient(client.NewNoop()))
+	def := NewDefinition()
+	def.ListenPath = "/public/v2/orders/{id}/*"
+	def.StripPath = true
+	def.PathRewrites = []PathRewrite{
+		{Pattern: `/public/v2/orders/[0-9]+/items/([a-z]+)/([0-9]+)`, Template: "/internal/orders?order_id={id}&item=$1&line=$2"},
+	}
+	def.Upstreams = &Upstreams{Balancing: "roundrobin", Targets: []*Target{{Target: upstream.URL + "/v1"}}}
+
+	var loggedPath string
+	routerDef := NewRouterDefinition(def)
+	routerDef.AddMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+			loggedPath = r.URL.RequestURI()
+		})
+	})
+	require.NoError(t, register.Add(routerDef))
+
+	get := func(target string) string {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+
+	assert.Equal(t, "/v1/internal/orders?item=abc&line=7&order_id=42&expand=true", get("/public/v2/orders/42/items/abc/7?expand=true"))
+	assert.Equal(t, "/public/v2/orders/42/items/abc/7?expand=true", loggedPath, "the request keeps the client path")
+
+	assert.Equal(t, "/v1/history", get("/public/v2/orders/42/history"), "the paths not rewritten are stripped")
+}
@@ -0,0 +1,234 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+
+	httpErrors "github.com/hellofresh/janus/pkg/errors"
+	"github.com/hellofresh/janus/pkg/middleware"
+	"github.com/pkg/errors"
+)
+
+// route is an API definition registered on a listen path together with its matching conditions
+type route struct {
+	methods   []string
+	hosts     *middleware.HostMatcher
+	headers   []headerMatcher
+	listeners map[string]bool
+	handler   http.Handler
+}
+
+type headerMatcher struct {
+	name  string
+	value string
+	regex *regexp.Regexp
+}
+
+// routeWeight tells how specific the route conditions matching a request are. Hosts take
+// precedence over headers, a route with more header conditions wins over a route with fewer ones
+// and exact values win over regular expressions.
+type routeWeight struct {
+	host    int
+	headers int
+	exact   int
+}
+
+func (w routeWeight) greater(other routeWeight) bool {
+	if w.host != other.host {
+		return w.host > other.host
+	}
+	if w.headers != other.headers {
+		return w.headers > other.headers
+	}
+
+	return w.exact > other.exact
+}
+
+// newRoute creates a new route, the handler is wrapped with the definition middleware in the same
+// order as the router does it
+func newRoute(def *RouterDefinition, handler http.Handler) (*route, error) {
+	for i := len(def.middleware) - 1; i >= 0; i-- {
+		handler = def.middleware[i](handler)
+	}
+
+	rt := &route{methods: def.Methods, listeners: make(map[string]bool), handler: handler}
+	for _, name := range def.BoundListeners() {
+		rt.listeners[name] = true
+	}
+	if len(def.Hosts) > 0 {
+		rt.hosts = middleware.NewHostMatcher(def.Hosts)
+	}
+
+	for _, header := range def.Headers {
+		matcher := headerMatcher{name: header.Name, value: header.Value}
+		if header.Regex != "" {
+			regex, err := regexp.Compile(header.Regex)
+			if err != nil {
+				return nil, errors.Wrap(err, "could not compile the header regular expression")
+			}
+			matcher.regex = regex
+		}
+		rt.headers = append(rt.headers, matcher)
+	}
+
+	return rt, nil
+}
+
+// withListenPath returns a copy of the route telling the proxy the listen path of the definition the
+// request matched, the upstream path is stripped of that listen path
+func (rt *route) withListenPath(listenPath string) *route {
+	handler := rt.handler
+	copied := *rt
+	copied.handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), listenPathKey, listenPath)))
+	})
+
+	return &copied
+}
+
+// matchListener checks the request was received on a listener the definition is bound to, the routes
+// of the other listeners are not visible to the request, not even by their methods
+func (rt *route) matchListener(r *http.Request) bool {
+	return rt.listeners[ListenerFromContext(r.Context())]
+}
+
+// matchMethod checks the request method, the CORS preflight requests match the routes of the method
+// they ask for, so the preflight is answered by the route plugins, e.g. cors
+func (rt *route) matchMethod(r *http.Request) bool {
+	method := r.Method
+	if preflight := r.Header.Get("Access-Control-Request-Method"); method == http.MethodOptions && preflight != "" {
+		if rt.matchesMethod(http.MethodOptions) {
+			return true
+		}
+		method = preflight
+	}
+
+	return rt.matchesMethod(method)
+}
+
+func (rt *route) matchesMethod(method string) bool {
+	for _, m := range rt.methods {
+		if strings.ToUpper(m) == methodAll || strings.ToUpper(m) == method {
+			return true
+		}
+	}
+
+	return false
+}
+
+// match checks if the request satisfies the route conditions. The returned weight tells how
+// specific the route is, so the most specific one is picked when several routes match the request.
+func (rt *route) match(r *http.Request) (routeWeight, bool) {
+	var weight routeWeight
+	if rt.hosts != nil {
+		hostWeight, ok := rt.hosts.Match(r.Host)
+		if !ok {
+			return weight, false
+		}
+		weight.host = hostWeight
+	}
+
+	for _, header := range rt.headers {
+		if !header.match(r.Header) {
+			return weight, false
+		}
+
+		weight.headers++
+		if header.regex == nil {
+			weight.exact++
+		}
+	}
+
+	return weight, true
+}
+
+// match checks the header value, the header only has to be present when neither value nor regex
+// are given
+func (h headerMatcher) match(headers http.Header) bool {
+	values, ok := headers[http.CanonicalHeaderKey(h.name)]
+	if !ok {
+		return false
+	}
+
+	for _, value := range values {
+		switch {
+		case h.regex != nil:
+			if h.regex.MatchString(value) {
+				return true
+			}
+		case h.value == "" || h.value == value:
+			return true
+		}
+	}
+
+	return false
+}
+
+// routes holds all the routes registered on the same listen path
+type routes struct {
+	list []*route
+}
+
+func (rs *routes) add(rt *route) {
+	rs.list = append(rs.list, rt)
+}
+
+// ServeHTTP dispatches the request to the most specific matching route, the first registered
+// route wins when several routes are equally specific
+func (rs *routes) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var (
+		best            *route
+		bestWeight      routeWeight
+		listenerMatched bool
+		methodMatched   bool
+		allowed         []string
+	)
+
+	for _, rt := range rs.list {
+		if !rt.matchListener(r) {
+			continue
+		}
+		listenerMatched = true
+		allowed = appendMethods(allowed, rt.methods)
+
+		if !rt.matchMethod(r) {
+			continue
+		}
+		methodMatched = true
+
+		if weight, ok := rt.match(r); ok && (best == nil || weight.greater(bestWeight)) {
+			best, bestWeight = rt, weight
+		}
+	}
+
+	switch {
+	case best != nil:
+		best.handler.ServeHTTP(w, r)
+	case listenerMatched && !methodMatched:
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		httpErrors.Handler(w, httpErrors.ErrMethodNotAllowed)
+	default:
+		httpErrors.Handler(w, httpErrors.ErrRouteNotFound)
+	}
+}
+
+// appendMethods appends the methods not listed yet, the methods are sent in the Allow header
+func appendMethods(allowed []string, methods []string) []string {
+	for _, method := range methods {
+		method = strings.ToUpper(method)
+		listed := false
+		for _, m := range allowed {
+			if m == method {
+				listed = true
+				break
+			}
+		}
+		if !listed {
+			allowed = append(allowed, method)
+		}
+	}
+
+	return allowed
+}
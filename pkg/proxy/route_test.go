@@ -0,0 +1,416 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hellofresh/janus/pkg/router"
+	"github.com/hellofresh/stats-go/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegexRoutes(t *testing.T) {
+	t.Parallel()
+
+	r := router.NewChiRouter()
+	r.GET("/users/me", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("literal"))
+	})
+
+	routes := newRegexRoutes(r.NotFoundHandler())
+	userRoute, err := newRoute(newTestDefinition([]string{"GET"}, nil), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("user " + router.URLParam(r, "id")))
+	}))
+	assert.NoError(t, err)
+	routes.add(regexp.MustCompile(`^/users/(?P<id>\d+)`), userRoute)
+	routes.add(regexp.MustCompile(`^/users/`), newTestRoute([]string{"ALL"}, nil, "fallback", func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Middleware", "called")
+			next.ServeHTTP(w, r)
+		})
+	}))
+	r.NotFound(routes.ServeHTTP)
+
+	tests := []struct {
+		method     string
+		url        string
+		code       int
+		body       string
+		middleware string
+	}{
+		{method: http.MethodGet, url: "/users/me", code: http.StatusOK, body: "literal"},
+		{method: http.MethodGet, url: "/users/42/orders", code: http.StatusOK, body: "user 42"},
+		{method: http.MethodPost, url: "/users/42", code: http.StatusOK, body: "fallback", middleware: "called"},
+		{method: http.MethodGet, url: "/users/abc", code: http.StatusOK, body: "fallback", middleware: "called"},
+		{method: http.MethodGet, url: "/api/users/42", code: http.StatusNotFound, body: "404 page not found\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.method+" "+test.url, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(test.method, test.url, nil))
+
+			assert.Equal(t, test.code, w.Code)
+			assert.Equal(t, test.body, w.Body.String())
+			assert.Equal(t, test.middleware, w.Header().Get("X-Middleware"))
+		})
+	}
+}
+
+func TestRoutesHosts(t *testing.T) {
+	t.Parallel()
+
+	rs := &routes{}
+	rs.add(newTestRoute([]string{"GET"}, nil, "any host"))
+	rs.add(newTestRoute([]string{"GET"}, []string{"*.example.com"}, "example wildcard"))
+	rs.add(newTestRoute([]string{"GET"}, []string{"*.api.example.com"}, "api wildcard"))
+	rs.add(newTestRoute([]string{"GET"}, []string{"api.a.com"}, "a"))
+	rs.add(newTestRoute([]string{"GET"}, []string{"api.b.com"}, "b"))
+	rs.add(newTestRoute([]string{"POST"}, []string{"api.a.com"}, "a post"))
+
+	tests := []struct {
+		method string
+		host   string
+		code   int
+		body   string
+	}{
+		{method: http.MethodGet, host: "api.a.com", code: http.StatusOK, body: "a"},
+		{method: http.MethodGet, host: "api.a.com:8080", code: http.StatusOK, body: "a"},
+		{method: http.MethodGet, host: "api.b.com", code: http.StatusOK, body: "b"},
+		{method: http.MethodPost, host: "api.a.com", code: http.StatusOK, body: "a post"},
+		{method: http.MethodGet, host: "www.example.com", code: http.StatusOK, body: "example wildcard"},
+		{method: http.MethodGet, host: "v1.api.example.com", code: http.StatusOK, body: "api wildcard"},
+		{method: http.MethodGet, host: "other.com", code: http.StatusOK, body: "any host"},
+		{method: http.MethodPost, host: "api.b.com", code: http.StatusNotFound},
+		{method: http.MethodDelete, host: "api.a.com", code: http.StatusMethodNotAllowed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.method+" "+test.host, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, "/v1", nil)
+			req.Host = test.host
+			w := httptest.NewRecorder()
+			rs.ServeHTTP(w, req)
+
+			assert.Equal(t, test.code, w.Code)
+			if test.body != "" {
+				assert.Equal(t, test.body, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRoutesHeaders(t *testing.T) {
+	t.Parallel()
+
+	rs := &routes{}
+	rs.add(newTestRoute([]string{"GET"}, nil, "stable"))
+	rs.add(newTestHeadersRoute([]string{"GET"}, nil, []HeaderMatch{{Name: "X-Version", Regex: "^beta"}}, "beta regex"))
+	rs.add(newTestHeadersRoute([]string{"GET"}, nil, []HeaderMatch{{Name: "X-Version", Value: "beta"}}, "beta"))
+	rs.add(newTestHeadersRoute([]string{"GET"}, nil, []HeaderMatch{{Name: "X-Version", Value: "beta"}, {Name: "X-Debug"}}, "beta debug"))
+	rs.add(newTestHeadersRoute([]string{"GET"}, []string{"api.a.com"}, nil, "host"))
+
+	tests := []struct {
+		scenario string
+		host     string
+		headers  map[string]string
+		body     string
+	}{
+		{scenario: "no headers", body: "stable"},
+		{scenario: "other version", headers: map[string]string{"X-Version": "alpha"}, body: "stable"},
+		{scenario: "exact value wins over regex", headers: map[string]string{"X-Version": "beta"}, body: "beta"},
+		{scenario: "regex", headers: map[string]string{"X-Version": "beta-2"}, body: "beta regex"},
+		{scenario: "more conditions win", headers: map[string]string{"X-Version": "beta", "X-Debug": "1"}, body: "beta debug"},
+		{scenario: "host wins over headers", host: "api.a.com", headers: map[string]string{"X-Version": "beta"}, body: "host"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1", nil)
+			if test.host != "" {
+				req.Host = test.host
+			}
+			for name, value := range test.headers {
+				req.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+			rs.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, test.body, w.Body.String())
+		})
+	}
+}
+
+func TestRoutesPreflight(t *testing.T) {
+	t.Parallel()
+
+	rs := &routes{}
+	rs.add(newTestRoute([]string{"GET"}, nil, "get"))
+	rs.add(newTestRoute([]string{"POST"}, nil, "post"))
+
+	tests := []struct {
+		scenario string
+		method   string
+		code     int
+		body     string
+	}{
+		{scenario: "preflight of a route method", method: http.MethodGet, code: http.StatusOK, body: "get"},
+		{scenario: "preflight of another route method", method: http.MethodPost, code: http.StatusOK, body: "post"},
+		{scenario: "preflight of an unknown method", method: http.MethodDelete, code: http.StatusMethodNotAllowed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodOptions, "/", nil)
+			req.Header.Set("Access-Control-Request-Method", test.method)
+			w := httptest.NewRecorder()
+			rs.ServeHTTP(w, req)
+
+			assert.Equal(t, test.code, w.Code)
+			if test.body != "" {
+				assert.Equal(t, test.body, w.Body.String())
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	rs.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "/", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code, "OPTIONS requests which are not preflights match the OPTIONS method only")
+}
+
+func TestRoutesMethodNotAllowed(t *testing.T) {
+	t.Parallel()
+
+	rs := &routes{}
+	rs.add(newTestRoute([]string{"GET"}, nil, "get"))
+	rs.add(newTestRoute([]string{"post", "GET"}, []string{"api.a.com"}, "post"))
+
+	w := httptest.NewRecorder()
+	rs.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "GET, POST", w.Header().Get("Allow"))
+	assert.JSONEq(t, `{"error": "method not allowed by the API"}`, w.Body.String())
+}
+
+func TestTemplatedListenPath(t *testing.T) {
+	t.Parallel()
+
+	r := router.NewChiRouter()
+	register := NewRegister(WithRouter(r))
+	for _, listenPath := range []string{"/users/{userId}/orders/{orderId}", "/users/{userId}/orders/latest", "/accounts/{accountId}/"} {
+		def := NewRouterDefinition(NewDefinition())
+		def.ListenPath = listenPath
+		def.Upstreams.Balancing = "roundrobin"
+		def.Upstreams.Targets = append(def.Upstreams.Targets, &Target{Target: "http://localhost:9089"})
+		listenPath := listenPath
+		def.AddMiddleware(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Listen-Path", listenPath)
+				for name, value := range router.URLParams(r) {
+					w.Header().Set("X-Param-"+name, value)
+				}
+				w.WriteHeader(http.StatusNoContent)
+			})
+		})
+		assert.NoError(t, register.Add(def))
+	}
+
+	tests := []struct {
+		url        string
+		code       int
+		listenPath string
+		params     map[string]string
+	}{
+		{url: "/users/42/orders/7", code: http.StatusNoContent, listenPath: "/users/{userId}/orders/{orderId}", params: map[string]string{"userId": "42", "orderId": "7"}},
+		{url: "/users/42/orders/7/", code: http.StatusNoContent, listenPath: "/users/{userId}/orders/{orderId}", params: map[string]string{"userId": "42", "orderId": "7"}},
+		{url: "/users/42/orders/latest", code: http.StatusNoContent, listenPath: "/users/{userId}/orders/latest", params: map[string]string{"userId": "42"}},
+		{url: "/accounts/1/", code: http.StatusNoContent, listenPath: "/accounts/{accountId}/", params: map[string]string{"accountId": "1"}},
+		{url: "/users/42/orders", code: http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.url, nil))
+
+			assert.Equal(t, test.code, w.Code)
+			assert.Equal(t, test.listenPath, w.Header().Get("X-Listen-Path"))
+			for name, value := range test.params {
+				assert.Equal(t, value, w.Header().Get("X-Param-"+name))
+			}
+		})
+	}
+}
+
+func TestTemplateRegexp(t *testing.T) {
+	t.Parallel()
+
+	re := templateRegexp("/users/{userId}/orders/{orderId}")
+	assert.Equal(t, "/items", stripRegexp(re, "/users/42/orders/7/items"))
+	assert.Equal(t, "", stripRegexp(re, "/users/42/orders/7"))
+	assert.Equal(t, "/users/42", stripRegexp(re, "/users/42"))
+}
+
+func TestStripRegexp(t *testing.T) {
+	t.Parallel()
+
+	re := regexp.MustCompile(`^/v\d+/users`)
+	assert.Equal(t, "/42", stripRegexp(re, "/v2/users/42"))
+	assert.Equal(t, "/other", stripRegexp(re, "/other"))
+}
+
+func newTestDefinition(methods []string, hosts []string, middleware ...router.Constructor) *RouterDefinition {
+	def := NewRouterDefinition(NewDefinition())
+	def.Methods = methods
+	def.Hosts = hosts
+	for _, mw := range middleware {
+		def.AddMiddleware(mw)
+	}
+
+	return def
+}
+
+func newTestRoute(methods []string, hosts []string, body string, middleware ...router.Constructor) *route {
+	return newTestHeadersRoute(methods, hosts, nil, body, middleware...)
+}
+
+func newTestHeadersRoute(methods []string, hosts []string, headers []HeaderMatch, body string, middleware ...router.Constructor) *route {
+	def := newTestDefinition(methods, hosts, middleware...)
+	def.Headers = headers
+
+	rt, err := newRoute(def, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	if err != nil {
+		panic(err)
+	}
+
+	return rt
+}
+
+func TestListenPathAliases(t *testing.T) {
+	t.Parallel()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer upstream.Close()
+
+	r := router.NewChiRouter()
+	register := NewRegister(WithRouter(r), WithStatsClient(client.NewNoop()))
+
+	var calls int32
+	def := NewRouterDefinition(NewDefinition())
+	def.ListenPath = "/v1/orders/*"
+	def.ListenPathAliases = []string{"/orders/*", "/users/{userId}/orders/*"}
+	def.StripPath = true
+	def.Upstreams = &Upstreams{Balancing: "roundrobin", Targets: []*Target{{Target: upstream.URL + "/api"}}}
+	def.AddMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			next.ServeHTTP(w, r)
+		})
+	})
+	assert.NoError(t, register.Add(def))
+
+	regexDef := NewRouterDefinition(NewDefinition())
+	regexDef.ListenPath = `/v[0-9]+/invoices`
+	regexDef.ListenPathAliases = []string{`/billing/invoices`}
+	regexDef.MatchingMode = MatchingModeRegex
+	regexDef.StripPath = true
+	regexDef.Upstreams = &Upstreams{Balancing: "roundrobin", Targets: []*Target{{Target: upstream.URL + "/invoices"}}}
+	assert.NoError(t, register.Add(regexDef))
+
+	tests := []struct {
+		url  string
+		path string
+	}{
+		{url: "/v1/orders/42", path: "/api/42"},
+		{url: "/orders/42", path: "/api/42"},
+		{url: "/users/7/orders/42", path: "/api/42"},
+		{url: "/v2/invoices/42", path: "/invoices/42"},
+		{url: "/billing/invoices/42", path: "/invoices/42"},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.url, nil))
+
+		assert.Equal(t, http.StatusOK, w.Code, test.url)
+		assert.Equal(t, test.path, w.Body.String(), "%s is stripped of the listen path it matched", test.url)
+	}
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls), "the aliases share the middleware of the definition")
+}
+
+func TestListeners(t *testing.T) {
+	t.Parallel()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer upstream.Close()
+
+	r := router.NewChiRouter()
+	register := NewRegister(WithRouter(r), WithStatsClient(client.NewNoop()), WithListeners("partner", "internal"))
+	add := func(listenPath, target string, methods []string, listeners ...string) error {
+		def := NewRouterDefinition(NewDefinition())
+		def.ListenPath = listenPath
+		def.Methods = methods
+		def.AppendPath = true
+		def.Listeners = listeners
+		def.Upstreams = &Upstreams{Balancing: "roundrobin", Targets: []*Target{{Target: upstream.URL + target}}}
+		if strings.Contains(listenPath, "[") {
+			def.MatchingMode = MatchingModeRegex
+		}
+		return register.Add(def)
+	}
+
+	assert.NoError(t, add("/orders/*", "/public", []string{"ALL"}))
+	assert.NoError(t, add("/orders/*", "/partner", []string{"ALL"}, "partner"))
+	assert.NoError(t, add("/reports/*", "/reports", []string{"POST"}, "partner", "internal"))
+	assert.NoError(t, add("/invoices/[0-9]+", "/invoices", []string{"ALL"}, "partner"))
+	assert.NoError(t, add("/status/*", "/status", []string{"ALL"}, DefaultListener, "internal"))
+	assert.Error(t, add("/admin/*", "/admin", []string{"ALL"}, "unknown"), "the definitions bound to an unknown listener are not registered")
+
+	tests := []struct {
+		listener string
+		method   string
+		url      string
+		code     int
+		body     string
+	}{
+		{listener: "", method: http.MethodGet, url: "/orders/42", code: http.StatusOK, body: "/public/orders/42"},
+		{listener: DefaultListener, method: http.MethodGet, url: "/orders/42", code: http.StatusOK, body: "/public/orders/42"},
+		{listener: "partner", method: http.MethodGet, url: "/orders/42", code: http.StatusOK, body: "/partner/orders/42"},
+		{listener: "internal", method: http.MethodGet, url: "/orders/42", code: http.StatusNotFound},
+		{listener: "partner", method: http.MethodPost, url: "/reports/1", code: http.StatusOK, body: "/reports/reports/1"},
+		{listener: "internal", method: http.MethodPost, url: "/reports/1", code: http.StatusOK, body: "/reports/reports/1"},
+		{listener: DefaultListener, method: http.MethodPost, url: "/reports/1", code: http.StatusNotFound},
+		{listener: DefaultListener, method: http.MethodGet, url: "/reports/1", code: http.StatusNotFound},
+		{listener: "partner", method: http.MethodGet, url: "/invoices/7", code: http.StatusOK, body: "/invoices/invoices/7"},
+		{listener: DefaultListener, method: http.MethodGet, url: "/invoices/7", code: http.StatusNotFound},
+		{listener: "internal", method: http.MethodGet, url: "/status/", code: http.StatusOK, body: "/status/status/"},
+		{listener: "partner", method: http.MethodGet, url: "/status/", code: http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.listener+" "+test.method+" "+test.url, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, test.url, nil)
+			if test.listener != "" {
+				req = req.WithContext(WithListener(req.Context(), test.listener))
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, test.code, w.Code)
+			if test.body != "" {
+				assert.Equal(t, test.body, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// errResponseHeaderTimeout fails the calls whose upstream did not send the response headers in time
+var errResponseHeaderTimeout = timeoutError("timeout awaiting response headers")
+
+// http2Transport calls the upstreams over HTTP/2 only, the https targets with TLS and the http ones
+// without it, with prior knowledge of HTTP/2 (h2c)
+type http2Transport struct {
+	tls                   *http2.Transport
+	h2c                   *http2.Transport
+	responseHeaderTimeout time.Duration
+}
+
+func newHTTP2Transport(t transport) *http2Transport {
+	dialer := &net.Dialer{Timeout: t.dialTimeout, KeepAlive: 30 * time.Second}
+
+	return &http2Transport{
+		tls: &http2.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: t.insecureSkipVerify},
+			DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+				return tls.DialWithDialer(dialer, network, addr, cfg)
+			},
+		},
+		h2c: &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+				return dialer.Dial(network, addr)
+			},
+		},
+		responseHeaderTimeout: t.responseHeaderTimeout,
+	}
+}
+
+// RoundTrip sends the request to the upstream, the call fails when the response headers are not received
+// within the response header timeout
+func (t *http2Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tr := t.tls
+	if req.URL.Scheme == "http" {
+		tr = t.h2c
+	}
+	if t.responseHeaderTimeout <= 0 {
+		return tr.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithCancel(req.Context())
+	timer := time.AfterFunc(t.responseHeaderTimeout, cancel)
+
+	resp, err := tr.RoundTrip(req.WithContext(ctx))
+	if !timer.Stop() {
+		if err == nil {
+			resp.Body.Close()
+		}
+		cancel()
+		return nil, errResponseHeaderTimeout
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelBody releases the context of the call once the response body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+type timeoutError string
+
+func (e timeoutError) Error() string   { return string(e) }
+func (e timeoutError) Timeout() bool   { return true }
+func (e timeoutError) Temporary() bool { return true }
@@ -36,3 +36,18 @@ func WithIdleConnTimeout(d time.Duration) Option {
 		t.idleConnTimeout = d
 	}
 }
+
+// WithReadBufferSize sets the size of the buffer the upstream connections are read with
+func WithReadBufferSize(size int) Option {
+	return func(t *transport) {
+		t.readBufferSize = size
+	}
+}
+
+// WithHTTP2Only calls the upstreams over HTTP/2 only, the http targets are called over HTTP/2 without
+// TLS, e.g. the gRPC services
+func WithHTTP2Only(enabled bool) Option {
+	return func(t *transport) {
+		t.http2Only = enabled
+	}
+}
@@ -7,17 +7,17 @@ import (
 
 type registry struct {
 	sync.RWMutex
-	store map[string]*http.Transport
+	store map[string]http.RoundTripper
 }
 
 func newRegistry() *registry {
 	r := new(registry)
-	r.store = make(map[string]*http.Transport)
+	r.store = make(map[string]http.RoundTripper)
 
 	return r
 }
 
-func (r *registry) get(key string) (*http.Transport, bool) {
+func (r *registry) get(key string) (http.RoundTripper, bool) {
 	r.RLock()
 	defer r.RUnlock()
 
@@ -26,7 +26,7 @@ func (r *registry) get(key string) (*http.Transport, bool) {
 	return tr, ok
 }
 
-func (r *registry) put(key string, tr *http.Transport) {
+func (r *registry) put(key string, tr http.RoundTripper) {
 	r.Lock()
 	defer r.Unlock()
 
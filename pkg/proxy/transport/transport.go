@@ -34,6 +34,11 @@ type transport struct {
 	dialTimeout            time.Duration
 	responseHeaderTimeout  time.Duration
 	idleConnTimeout        time.Duration
+	// readBufferSize is the size of the buffer the connections are read with, the net/http default when zero
+	readBufferSize int
+	// http2Only calls the upstreams over HTTP/2 only, with TLS for the https targets and without it for
+	// the http ones
+	http2Only bool
 }
 
 func (t transport) hash() string {
@@ -43,6 +48,8 @@ func (t transport) hash() string {
 		fmt.Sprintf("dialTimeout:%v", t.dialTimeout),
 		fmt.Sprintf("responseHeaderTimeout:%v", t.responseHeaderTimeout),
 		fmt.Sprintf("idleConnTimeout:%v", t.idleConnTimeout),
+		fmt.Sprintf("readBufferSize:%v", t.readBufferSize),
+		fmt.Sprintf("http2Only:%v", t.http2Only),
 	}, ";")
 }
 
@@ -53,7 +60,7 @@ func init() {
 }
 
 // New creates a new instance of Transport with the given params
-func New(opts ...Option) *http.Transport {
+func New(opts ...Option) http.RoundTripper {
 	t := transport{}
 
 	for _, opt := range opts {
@@ -79,6 +86,12 @@ func New(opts ...Option) *http.Transport {
 		return tr
 	}
 
+	if t.http2Only {
+		tr := newHTTP2Transport(t)
+		registryInstance.put(hash, tr)
+		return tr
+	}
+
 	tr := &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
 		DialContext: (&net.Dialer{
@@ -93,6 +106,7 @@ func New(opts ...Option) *http.Transport {
 		ResponseHeaderTimeout: t.responseHeaderTimeout,
 		MaxIdleConnsPerHost:   t.idleConnectionsPerHost,
 		TLSClientConfig:       &tls.Config{InsecureSkipVerify: t.insecureSkipVerify},
+		ReadBufferSize:        t.readBufferSize,
 	}
 
 	http2.ConfigureTransport(tr)
@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"syscall"
+
+	httpErrors "github.com/hellofresh/janus/pkg/errors"
+	"github.com/hellofresh/janus/pkg/middleware"
+)
+
+// Reasons of the failed upstream calls, they are logged and tagged on the span of the proxied request
+const (
+	reasonRequestTimeout    = "request_timeout"
+	reasonUpstreamTimeout   = "upstream_timeout"
+	reasonConnectionRefused = "connection_refused"
+	reasonConnectionReset   = "connection_reset"
+	reasonNoHealthyUpstream = "no_healthy_upstream"
+	reasonResponseTooLarge  = "response_too_large"
+	reasonUpstreamError     = "upstream_error"
+)
+
+var (
+	// ErrUpstreamTimeout is used when the upstream did not accept the connection or send the response
+	// headers within the forwarding timeouts of the API
+	ErrUpstreamTimeout = httpErrors.New(http.StatusGatewayTimeout, "upstream call timed out")
+
+	// ErrNoHealthyUpstream is used when no upstream target can be elected, e.g. all the targets are
+	// drained
+	ErrNoHealthyUpstream = httpErrors.New(http.StatusServiceUnavailable, "no healthy upstream")
+)
+
+// noUpstreamError is returned by the transport for the requests no upstream target was elected for
+type noUpstreamError struct {
+	err error
+}
+
+func (e *noUpstreamError) Error() string {
+	return "no upstream target elected: " + e.err.Error()
+}
+
+func (e *noUpstreamError) Unwrap() error {
+	return e.err
+}
+
+// electedTransport fails the requests no upstream target was elected for without sending them
+type electedTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip sends the request to the elected upstream
+func (t electedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err, ok := req.Context().Value(electionErrorKey).(error); ok {
+		return nil, &noUpstreamError{err: err}
+	}
+
+	return t.base.RoundTrip(req)
+}
+
+// classifyUpstreamError tells the failure of the upstream call from its error and the request context,
+// it returns the error answering the request and the reason of the failure
+func classifyUpstreamError(ctx context.Context, err error) (*httpErrors.Error, string) {
+	if ctx.Err() == context.DeadlineExceeded {
+		return middleware.ErrRequestTimeout, reasonRequestTimeout
+	}
+
+	for ; err != nil; err = unwrapError(err) {
+		if _, ok := err.(*noUpstreamError); ok {
+			return ErrNoHealthyUpstream, reasonNoHealthyUpstream
+		}
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			return ErrUpstreamTimeout, reasonUpstreamTimeout
+		}
+
+		switch err {
+		case ErrResponseBodyTooLarge:
+			return ErrBadGateway, reasonResponseTooLarge
+		case context.DeadlineExceeded:
+			return ErrUpstreamTimeout, reasonUpstreamTimeout
+		case syscall.ECONNREFUSED:
+			return ErrBadGateway, reasonConnectionRefused
+		// the upstream closing the connection before the response is a reset as well
+		case syscall.ECONNRESET, syscall.EPIPE, io.EOF, io.ErrUnexpectedEOF:
+			return ErrBadGateway, reasonConnectionReset
+		}
+	}
+
+	return ErrBadGateway, reasonUpstreamError
+}
+
+// unwrapError returns the error wrapped by the network errors, nil when there is none
+func unwrapError(err error) error {
+	switch e := err.(type) {
+	case *url.Error:
+		return e.Err
+	case *net.OpError:
+		return e.Err
+	case *os.SyscallError:
+		return e.Err
+	case interface{ Unwrap() error }:
+		return e.Unwrap()
+	}
+
+	return nil
+}
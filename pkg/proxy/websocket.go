@@ -0,0 +1,369 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	wsOpClose = 0x8
+
+	wsCloseGoingAway      = 1001
+	wsCloseMessageTooBig  = 1009
+	wsMaxControlFrameSize = 125
+)
+
+var errWebSocketLimit = errors.New("websocket connection limit exceeded")
+
+// WebSocket represents the limits of the proxied WebSocket connections of an API, they are enforced
+// for each connection independently
+type WebSocket struct {
+	// IdleTimeout closes the connection when no frame was sent in either direction for the duration
+	IdleTimeout Duration `bson:"idle_timeout" json:"idle_timeout"`
+	// MaxMessageSize closes the connection when a message payload, all its fragments included, is larger
+	MaxMessageSize int64 `bson:"max_message_size" json:"max_message_size"`
+}
+
+func (w WebSocket) validate() error {
+	if w.IdleTimeout < 0 {
+		return errors.New("proxy.websocket idle_timeout can not be negative")
+	}
+	if w.MaxMessageSize < 0 {
+		return errors.New("proxy.websocket max_message_size can not be negative")
+	}
+
+	return nil
+}
+
+func (w WebSocket) enabled() bool {
+	return w.IdleTimeout > 0 || w.MaxMessageSize > 0
+}
+
+func isWebSocket(req *http.Request) bool {
+	return strings.EqualFold(req.Header.Get("Upgrade"), "websocket")
+}
+
+// webSocketTransport sends the upgrade requests to the upstream without tracing, the traced response
+// body could not be written to once the protocol is switched. The limits are applied to the upgraded
+// upstream connection.
+type webSocketTransport struct {
+	traced   http.RoundTripper
+	upgrades http.RoundTripper
+}
+
+// RoundTrip sends the request to the upstream
+func (t webSocketTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isWebSocket(req) {
+		return t.traced.RoundTrip(req)
+	}
+
+	resp, err := t.upgrades.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusSwitchingProtocols {
+		return resp, err
+	}
+
+	if session, ok := req.Context().Value(webSocketKey).(*wsSession); ok {
+		if backend, ok := resp.Body.(io.ReadWriteCloser); ok {
+			resp.Body = session.backend(backend)
+		}
+	}
+
+	return resp, nil
+}
+
+// limitWebSocket applies the limits to the WebSocket connections proxied by the handler
+func limitWebSocket(handler http.Handler, limits WebSocket) http.Handler {
+	if !limits.enabled() {
+		return handler
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isWebSocket(r) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		session := &wsSession{limits: limits, path: r.URL.Path}
+		ctx := context.WithValue(r.Context(), webSocketKey, session)
+		handler.ServeHTTP(&wsResponseWriter{ResponseWriter: w, session: session}, r.WithContext(ctx))
+	})
+}
+
+type wsResponseWriter struct {
+	http.ResponseWriter
+	session *wsSession
+}
+
+// Hijack takes over the client connection once the upstream switched the protocol
+func (w *wsResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the response writer does not support hijacking")
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return w.session.client(conn), rw, nil
+}
+
+// wsSession holds the state of a proxied WebSocket connection, the frames read from both sides are
+// parsed to track the idle time and the message sizes
+type wsSession struct {
+	limits WebSocket
+	path   string
+
+	mu          sync.Mutex
+	clientConn  net.Conn
+	backendConn io.ReadWriteCloser
+	timer       *time.Timer
+	closed      bool
+}
+
+func (s *wsSession) client(conn net.Conn) net.Conn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clientConn = conn
+	s.start()
+	return &wsClientConn{Conn: conn, session: s, frames: wsFrameReader{limit: s.limits.MaxMessageSize}}
+}
+
+func (s *wsSession) backend(conn io.ReadWriteCloser) io.ReadWriteCloser {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.backendConn = conn
+	s.start()
+	return &wsBackendConn{ReadWriteCloser: conn, session: s, frames: wsFrameReader{limit: s.limits.MaxMessageSize}}
+}
+
+// start starts the idle timer once both sides are connected
+func (s *wsSession) start() {
+	if s.clientConn == nil || s.backendConn == nil || s.limits.IdleTimeout <= 0 {
+		return
+	}
+
+	s.timer = time.AfterFunc(time.Duration(s.limits.IdleTimeout), func() {
+		s.terminate(wsCloseGoingAway, "idle timeout")
+	})
+}
+
+func (s *wsSession) active() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.timer != nil && !s.closed {
+		s.timer.Reset(time.Duration(s.limits.IdleTimeout))
+	}
+}
+
+func (s *wsSession) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+}
+
+// terminate sends a close frame with the reason to both sides and closes the connections. The frame
+// is sent best effort, it may be interleaved with a frame being copied.
+func (s *wsSession) terminate(code uint16, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.closed = true
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+
+	log.WithFields(log.Fields{"path": s.path, "code": code, "reason": reason}).Info("Closing the WebSocket connection")
+
+	if s.clientConn != nil {
+		s.clientConn.SetWriteDeadline(time.Now().Add(time.Second))
+		s.clientConn.Write(closeFrame(code, reason, false))
+		s.clientConn.Close()
+	}
+	if s.backendConn != nil {
+		if conn, ok := s.backendConn.(interface{ SetWriteDeadline(time.Time) error }); ok {
+			conn.SetWriteDeadline(time.Now().Add(time.Second))
+		}
+		s.backendConn.Write(closeFrame(code, reason, true))
+		s.backendConn.Close()
+	}
+}
+
+type wsClientConn struct {
+	net.Conn
+	session *wsSession
+	frames  wsFrameReader
+}
+
+// Read reads the frames sent by the client
+func (c *wsClientConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		if !c.frames.read(b[:n]) {
+			c.session.terminate(wsCloseMessageTooBig, "message too big")
+			return 0, errWebSocketLimit
+		}
+		c.session.active()
+	}
+
+	return n, err
+}
+
+// Close closes the client connection
+func (c *wsClientConn) Close() error {
+	c.session.stop()
+	return c.Conn.Close()
+}
+
+type wsBackendConn struct {
+	io.ReadWriteCloser
+	session *wsSession
+	frames  wsFrameReader
+}
+
+// Read reads the frames sent by the upstream
+func (c *wsBackendConn) Read(b []byte) (int, error) {
+	n, err := c.ReadWriteCloser.Read(b)
+	if n > 0 {
+		if !c.frames.read(b[:n]) {
+			c.session.terminate(wsCloseMessageTooBig, "message too big")
+			return 0, errWebSocketLimit
+		}
+		c.session.active()
+	}
+
+	return n, err
+}
+
+// wsFrameReader follows the frames of a stream, the message sizes are known from the frame headers
+// so an oversized message is detected before its payload is forwarded
+type wsFrameReader struct {
+	limit int64
+
+	header    []byte
+	remaining uint64
+	message   uint64
+}
+
+// read parses the data of the stream and returns false when a message is over the limit
+func (f *wsFrameReader) read(data []byte) bool {
+	for len(data) > 0 {
+		if f.remaining > 0 {
+			skip := uint64(len(data))
+			if skip > f.remaining {
+				skip = f.remaining
+			}
+			f.remaining -= skip
+			data = data[skip:]
+			continue
+		}
+
+		f.header = append(f.header, data[0])
+		data = data[1:]
+
+		size, ok := frameHeaderSize(f.header)
+		if !ok || len(f.header) < size {
+			continue
+		}
+
+		fin, opcode, length := parseFrameHeader(f.header)
+		f.header, f.remaining = f.header[:0], length
+
+		if opcode >= wsOpClose {
+			// the control frames are interleaved with the fragments and not a part of the message
+			continue
+		}
+
+		f.message += length
+		if f.limit > 0 && f.message > uint64(f.limit) {
+			return false
+		}
+		if fin {
+			f.message = 0
+		}
+	}
+
+	return true
+}
+
+// frameHeaderSize returns the size of the frame header, once its first two bytes are known
+func frameHeaderSize(header []byte) (int, bool) {
+	if len(header) < 2 {
+		return 0, false
+	}
+
+	size := 2
+	switch header[1] & 0x7F {
+	case 126:
+		size += 2
+	case 127:
+		size += 8
+	}
+	if header[1]&0x80 != 0 {
+		size += 4
+	}
+
+	return size, true
+}
+
+func parseFrameHeader(header []byte) (bool, byte, uint64) {
+	fin, opcode := header[0]&0x80 != 0, header[0]&0x0F
+
+	var length uint64
+	switch length = uint64(header[1] & 0x7F); length {
+	case 126:
+		length = uint64(binary.BigEndian.Uint16(header[2:]))
+	case 127:
+		length = binary.BigEndian.Uint64(header[2:])
+	}
+
+	return fin, opcode, length
+}
+
+// closeFrame returns a close frame with the status code and the reason, the frames sent to the
+// upstream are masked
+func closeFrame(code uint16, reason string, masked bool) []byte {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, code)
+	payload = append(payload, reason...)
+	if len(payload) > wsMaxControlFrameSize {
+		payload = payload[:wsMaxControlFrameSize]
+	}
+
+	frame := []byte{0x80 | wsOpClose, byte(len(payload))}
+	if !masked {
+		return append(frame, payload...)
+	}
+
+	key := make([]byte, 4)
+	binary.BigEndian.PutUint32(key, rand.Uint32())
+	frame[1] |= 0x80
+	frame = append(frame, key...)
+	for i, b := range payload {
+		frame = append(frame, b^key[i%4])
+	}
+
+	return frame
+}
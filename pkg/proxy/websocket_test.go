@@ -0,0 +1,177 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wsFrame returns a frame, masked with a zero key when it is sent by the client
+func wsFrame(fin bool, opcode byte, payload []byte, masked bool) []byte {
+	frame := []byte{opcode}
+	if fin {
+		frame[0] |= 0x80
+	}
+
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, byte(len(payload)))
+	case len(payload) <= 0xFFFF:
+		frame = append(frame, 126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(len(payload)))
+	default:
+		frame = append(frame, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(len(payload)))
+	}
+
+	if masked {
+		frame[1] |= 0x80
+		frame = append(frame, 0, 0, 0, 0)
+	}
+
+	return append(frame, payload...)
+}
+
+// readCloseFrame reads the frames until the close one and returns its code and reason
+func readCloseFrame(t *testing.T, r *bufio.Reader) (uint16, string) {
+	for {
+		header := make([]byte, 2)
+		_, err := io.ReadFull(r, header)
+		require.NoError(t, err)
+
+		size, _ := frameHeaderSize(header)
+		header = append(header, make([]byte, size-2)...)
+		_, err = io.ReadFull(r, header[2:])
+		require.NoError(t, err)
+
+		_, opcode, length := parseFrameHeader(header)
+		payload := make([]byte, length)
+		_, err = io.ReadFull(r, payload)
+		require.NoError(t, err)
+
+		if header[1]&0x80 != 0 {
+			key := header[size-4:]
+			for i := range payload {
+				payload[i] ^= key[i%4]
+			}
+		}
+
+		if opcode == wsOpClose {
+			return binary.BigEndian.Uint16(payload), string(payload[2:])
+		}
+	}
+}
+
+// startWebSocketProxy starts an upstream switching the protocol and a proxy to it with the limits,
+// the upstream connections are sent to the channel
+func startWebSocketProxy(t *testing.T, limits WebSocket) (string, chan net.Conn, func()) {
+	upstreamConns := make(chan net.Conn, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		require.NoError(t, err)
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n")
+		rw.Flush()
+		upstreamConns <- conn
+	}))
+
+	target, _ := url.Parse(upstream.URL)
+	handler := httputil.NewSingleHostReverseProxy(target)
+	handler.Transport = webSocketTransport{traced: http.DefaultTransport, upgrades: &http.Transport{}}
+	proxy := httptest.NewServer(limitWebSocket(handler, limits))
+
+	return proxy.Listener.Addr().String(), upstreamConns, func() {
+		proxy.Close()
+		upstream.Close()
+	}
+}
+
+func dialWebSocket(t *testing.T, addr string) (net.Conn, *bufio.Reader) {
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+
+	conn.Write([]byte("GET /chat HTTP/1.1\r\nHost: example.com\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n"))
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+	return conn, r
+}
+
+func TestWebSocketIdleTimeout(t *testing.T) {
+	addr, upstreamConns, stop := startWebSocketProxy(t, WebSocket{IdleTimeout: Duration(200 * time.Millisecond)})
+	defer stop()
+
+	conn, r := dialWebSocket(t, addr)
+	defer conn.Close()
+	upstreamConn := <-upstreamConns
+	defer upstreamConn.Close()
+
+	// the frames in either direction keep the connection open
+	for i := 0; i < 3; i++ {
+		time.Sleep(100 * time.Millisecond)
+		conn.Write(wsFrame(true, 0x1, []byte("ping"), true))
+		time.Sleep(100 * time.Millisecond)
+		upstreamConn.Write(wsFrame(true, 0x1, []byte("pong"), false))
+	}
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	code, reason := readCloseFrame(t, r)
+	assert.Equal(t, uint16(wsCloseGoingAway), code)
+	assert.Equal(t, "idle timeout", reason)
+}
+
+func TestWebSocketMaxMessageSize(t *testing.T) {
+	addr, upstreamConns, stop := startWebSocketProxy(t, WebSocket{MaxMessageSize: 10})
+	defer stop()
+
+	conn, r := dialWebSocket(t, addr)
+	defer conn.Close()
+	upstreamConn := <-upstreamConns
+	defer upstreamConn.Close()
+	upstream := bufio.NewReader(upstreamConn)
+
+	conn.Write(wsFrame(false, 0x1, []byte("hello"), true))
+	conn.Write(wsFrame(true, 0x9, []byte("ping"), true))
+	conn.Write(wsFrame(false, 0x0, []byte("world"), true))
+	conn.Write(wsFrame(true, 0x0, []byte("!"), true))
+
+	upstreamConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	code, reason := readCloseFrame(t, upstream)
+	assert.Equal(t, uint16(wsCloseMessageTooBig), code, "the upstream is told the connection is closed")
+	assert.Equal(t, "message too big", reason)
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	code, _ = readCloseFrame(t, r)
+	assert.Equal(t, uint16(wsCloseMessageTooBig), code)
+}
+
+func TestWebSocketFrameReader(t *testing.T) {
+	frames := &wsFrameReader{limit: 70000}
+
+	data := append(wsFrame(false, 0x2, make([]byte, 300), true), wsFrame(true, 0x8, []byte{3, 232}, true)...)
+	data = append(data, wsFrame(true, 0x0, make([]byte, 69700), true)...)
+	for len(data) > 0 {
+		// the frames are split across the reads
+		n := 7
+		if n > len(data) {
+			n = len(data)
+		}
+		require.True(t, frames.read(data[:n]))
+		data = data[n:]
+	}
+	assert.Equal(t, uint64(0), frames.message, "the message size is reset once it is complete")
+
+	assert.True(t, frames.read(wsFrame(true, 0x1, make([]byte, 70000), false)))
+	assert.False(t, frames.read(wsFrame(true, 0x1, make([]byte, 70001), false)))
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/json"
 	"net/http"
+
+	"github.com/ghodss/yaml"
 )
 
 // M is a simple abstraction for a map interface
@@ -24,3 +26,17 @@ func JSON(w http.ResponseWriter, code int, v interface{}) {
 	w.WriteHeader(code)
 	w.Write(buf.Bytes())
 }
+
+// YAML marshals 'v' to YAML using its JSON struct tags and setting the
+// Content-Type as application/x-yaml.
+func YAML(w http.ResponseWriter, code int, v interface{}) {
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-yaml")
+	w.WriteHeader(code)
+	w.Write(b)
+}
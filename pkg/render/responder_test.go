@@ -40,3 +40,14 @@ func TestWrongJson(t *testing.T) {
 
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
 }
+
+func TestRespondAsYAML(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	recipe := test.Recipe{Name: "Test"}
+	render.YAML(w, http.StatusOK, recipe)
+
+	assert.Equal(t, "application/x-yaml", w.Header().Get("Content-Type"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Test")
+}
@@ -113,6 +113,20 @@ func (r *ChiRouter) Use(handlers ...Constructor) Router {
 	return r
 }
 
+// NotFound sets the handler for routing paths that could not be found
+func (r *ChiRouter) NotFound(handler http.HandlerFunc) {
+	r.mux.NotFound(handler)
+}
+
+// NotFoundHandler returns the handler for routing paths that could not be found
+func (r *ChiRouter) NotFoundHandler() http.HandlerFunc {
+	if mux, ok := r.mux.(*chi.Mux); ok {
+		return mux.NotFoundHandler()
+	}
+
+	return http.NotFound
+}
+
 // RoutesCount returns number of routes registered
 func (r *ChiRouter) RoutesCount() int {
 	return r.routesCount(r.mux)
@@ -2,6 +2,7 @@ package router
 
 import (
 	"net/http"
+	"regexp"
 
 	"github.com/go-chi/chi"
 )
@@ -11,11 +12,46 @@ import (
 // so in most cases you can just pass somepackage.New
 type Constructor func(http.Handler) http.Handler
 
+var parameterPlaceholder = regexp.MustCompile(parameterMatchRule)
+
 // URLParam returns the url parameter from a http.Request object.
 func URLParam(r *http.Request, key string) string {
 	return chi.URLParam(r, key)
 }
 
+// URLParams returns all the url parameters captured from the http.Request path.
+func URLParams(r *http.Request) map[string]string {
+	params := make(map[string]string)
+	rctx, ok := r.Context().Value(chi.RouteCtxKey).(*chi.Context)
+	if !ok {
+		return params
+	}
+
+	for i, key := range rctx.URLParams.Keys {
+		if key != "*" && i < len(rctx.URLParams.Values) {
+			params[key] = rctx.URLParams.Values[i]
+		}
+	}
+
+	return params
+}
+
+// ExpandURLParams replaces the {param} placeholders in the given value with the url parameters
+// from the http.Request object. Placeholders without a matching parameter are left untouched.
+func ExpandURLParams(r *http.Request, value string) string {
+	if !parameterPlaceholder.MatchString(value) {
+		return value
+	}
+
+	params := URLParams(r)
+	return parameterPlaceholder.ReplaceAllStringFunc(value, func(placeholder string) string {
+		if param := params[placeholder[1:len(placeholder)-1]]; param != "" {
+			return param
+		}
+		return placeholder
+	})
+}
+
 // Router defines the basic methods for a router
 type Router interface {
 	ServeHTTP(w http.ResponseWriter, req *http.Request)
@@ -32,6 +68,8 @@ type Router interface {
 	CONNECT(path string, handler http.HandlerFunc, handlers ...Constructor)
 	Group(path string) Router
 	Use(handlers ...Constructor) Router
+	NotFound(handler http.HandlerFunc)
+	NotFoundHandler() http.HandlerFunc
 
 	RoutesCount() int
 }
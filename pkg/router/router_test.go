@@ -0,0 +1,25 @@
+package router_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hellofresh/janus/pkg/router"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestURLParams(t *testing.T) {
+	r := router.NewChiRouter()
+	r.GET("/users/{userId}/orders/{orderId}/*", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, map[string]string{"userId": "42", "orderId": "7"}, router.URLParams(r))
+		assert.Equal(t, "/users/42/orders/7/{missing}", router.ExpandURLParams(r, "/users/{userId}/orders/{orderId}/{missing}"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/42/orders/7/items", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	assert.Empty(t, router.URLParams(httptest.NewRequest(http.MethodGet, "/", nil)))
+}
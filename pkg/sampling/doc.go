@@ -0,0 +1,3 @@
+// Package sampling provides the trace sampling rates of the APIs, overriding the default sampler of
+// the traces of an API at runtime, e.g. to trace all the requests of a route while debugging it.
+package sampling
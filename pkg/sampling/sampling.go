@@ -0,0 +1,166 @@
+package sampling
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/hellofresh/janus/pkg/errors"
+	obs "github.com/hellofresh/janus/pkg/observability"
+	"go.opencensus.io/trace"
+)
+
+// ErrInvalidRate is thrown when the sampling rate is not between 0 and 1
+var ErrInvalidRate = errors.New(http.StatusBadRequest, "sampling rate must be between 0 and 1")
+
+// ErrInvalidStrategy is thrown when the sampling strategy is not known
+var ErrInvalidStrategy = errors.New(http.StatusBadRequest, "sampling strategy must be one of always, never or probabilistic")
+
+// Sampling strategies
+const (
+	Always        = "always"
+	Never         = "never"
+	Probabilistic = "probabilistic"
+)
+
+type samplerKeyType int
+
+const samplerKey samplerKeyType = iota
+
+// Rate is the trace sampling rate of an API, override tells it is set at runtime rather than being the
+// default rate
+type Rate struct {
+	Rate     float64 `json:"rate"`
+	Override bool    `json:"override"`
+}
+
+type override struct {
+	rate    float64
+	sampler trace.Sampler
+}
+
+// Rates holds the trace sampling rates of the APIs set at runtime by name. They are read on every
+// request, so a changed rate takes effect without reloading the routes. The APIs without a rate are
+// sampled by the sampling strategy of their definition, or by the default sampler.
+type Rates struct {
+	sync.RWMutex
+	defaultRate float64
+	defaults    map[string]override
+	overrides   map[string]override
+}
+
+// NewRates creates a new instance of Rates, the default rate is the rate of the default sampler
+func NewRates(defaultRate float64) *Rates {
+	return &Rates{defaultRate: defaultRate, defaults: make(map[string]override), overrides: make(map[string]override)}
+}
+
+// Strategy returns the rate of the sampling strategy, the param is the rate of the probabilistic strategy
+func Strategy(strategy string, param float64) (float64, error) {
+	switch strategy {
+	case Always:
+		return 1, nil
+	case Never:
+		return 0, nil
+	case Probabilistic:
+		return param, Validate(param)
+	default:
+		return 0, ErrInvalidStrategy
+	}
+}
+
+// Validate validates the sampling rate
+func Validate(rate float64) error {
+	if !(rate >= 0 && rate <= 1) {
+		return ErrInvalidRate
+	}
+
+	return nil
+}
+
+// Set sets the sampling rate of the API
+func (s *Rates) Set(name string, rate float64) error {
+	if err := Validate(rate); err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.overrides[name] = override{rate: rate, sampler: obs.NewCountingSampler(trace.ProbabilitySampler(rate))}
+	return nil
+}
+
+// SetDefault sets the sampling rate of the API defined by the sampling strategy of its definition, the
+// rate set at runtime overrides it
+func (s *Rates) SetDefault(name string, rate float64) error {
+	if err := Validate(rate); err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.defaults[name] = override{rate: rate, sampler: obs.NewCountingSampler(trace.ProbabilitySampler(rate))}
+	return nil
+}
+
+// Get returns the sampling rate of the API
+func (s *Rates) Get(name string) Rate {
+	s.RLock()
+	defer s.RUnlock()
+
+	if o, ok := s.overrides[name]; ok {
+		return Rate{Rate: o.rate, Override: true}
+	}
+	if d, ok := s.defaults[name]; ok {
+		return Rate{Rate: d.rate}
+	}
+
+	return Rate{Rate: s.defaultRate}
+}
+
+// Remove removes the sampling rate of the API set at runtime, its requests are sampled by the sampling
+// strategy of its definition or by the default sampler again
+func (s *Rates) Remove(name string) {
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.overrides, name)
+}
+
+// Reset removes the sampling rates of all the APIs, the definitions set their rates again when they are
+// registered
+func (s *Rates) Reset() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.defaults = make(map[string]override)
+	s.overrides = make(map[string]override)
+}
+
+// Handler creates the middleware setting the sampler of the spans of the API requests
+func (s *Rates) Handler(name string) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s.RLock()
+			o, ok := s.overrides[name]
+			if !ok {
+				o, ok = s.defaults[name]
+			}
+			s.RUnlock()
+
+			if !ok {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), samplerKey, o.sampler)))
+		})
+	}
+}
+
+// FromContext returns the sampler of the request spans, it is nil when the default sampler is used
+func FromContext(ctx context.Context) trace.Sampler {
+	sampler, _ := ctx.Value(samplerKey).(trace.Sampler)
+	return sampler
+}
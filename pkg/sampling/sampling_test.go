@@ -0,0 +1,103 @@
+package sampling
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/trace"
+)
+
+func serve(rates *Rates) trace.Sampler {
+	var sampler trace.Sampler
+	handler := rates.Handler("example")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sampler = FromContext(r.Context())
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	return sampler
+}
+
+func sampled(sampler trace.Sampler) bool {
+	_, span := trace.StartSpan(context.Background(), "example", trace.WithSampler(sampler))
+	return span.SpanContext().IsSampled()
+}
+
+func TestHandler(t *testing.T) {
+	trace.ApplyConfig(trace.Config{DefaultSampler: trace.NeverSample()})
+	defer trace.ApplyConfig(trace.Config{DefaultSampler: trace.ProbabilitySampler(1e-4)})
+
+	rates := NewRates(0)
+	assert.Nil(t, serve(rates), "the default sampler is used without a rate")
+
+	require.NoError(t, rates.Set("example", 1))
+	sampler := serve(rates)
+	require.NotNil(t, sampler, "the rate takes effect on the next request")
+	assert.True(t, sampled(sampler))
+
+	require.NoError(t, rates.Set("example", 0))
+	assert.False(t, sampled(serve(rates)))
+
+	rates.Remove("example")
+	assert.Nil(t, serve(rates))
+}
+
+func TestRates(t *testing.T) {
+	rates := NewRates(0.25)
+	assert.Equal(t, Rate{Rate: 0.25}, rates.Get("example"))
+
+	require.NoError(t, rates.Set("example", 0.5))
+	assert.Equal(t, Rate{Rate: 0.5, Override: true}, rates.Get("example"))
+	assert.Equal(t, Rate{Rate: 0.25}, rates.Get("other"))
+
+	assert.Equal(t, ErrInvalidRate, rates.Set("example", 1.1))
+	assert.Equal(t, ErrInvalidRate, rates.Set("example", -1))
+	assert.Equal(t, Rate{Rate: 0.5, Override: true}, rates.Get("example"), "an invalid rate is not set")
+
+	rates.Reset()
+	assert.Equal(t, Rate{Rate: 0.25}, rates.Get("example"))
+}
+
+func TestRatesDefaults(t *testing.T) {
+	trace.ApplyConfig(trace.Config{DefaultSampler: trace.NeverSample()})
+	defer trace.ApplyConfig(trace.Config{DefaultSampler: trace.ProbabilitySampler(1e-4)})
+
+	rates := NewRates(0.25)
+	require.NoError(t, rates.SetDefault("example", 1))
+	assert.Equal(t, Rate{Rate: 1}, rates.Get("example"), "the rate of the definition is not an override")
+	assert.True(t, sampled(serve(rates)))
+
+	require.NoError(t, rates.Set("example", 0))
+	assert.Equal(t, Rate{Rate: 0, Override: true}, rates.Get("example"), "the rate set at runtime wins")
+	assert.False(t, sampled(serve(rates)))
+
+	rates.Remove("example")
+	assert.Equal(t, Rate{Rate: 1}, rates.Get("example"))
+	assert.Equal(t, ErrInvalidRate, rates.SetDefault("example", 2))
+
+	rates.Reset()
+	assert.Equal(t, Rate{Rate: 0.25}, rates.Get("example"))
+	assert.Nil(t, serve(rates))
+}
+
+func TestStrategy(t *testing.T) {
+	rate, err := Strategy(Always, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, rate)
+
+	rate, err = Strategy(Never, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, rate)
+
+	rate, err = Strategy(Probabilistic, 0.001)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.001, rate)
+
+	_, err = Strategy(Probabilistic, 1.5)
+	assert.Equal(t, ErrInvalidRate, err)
+	_, err = Strategy("sometimes", 0)
+	assert.Equal(t, ErrInvalidStrategy, err)
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"crypto/tls"
+
+	"github.com/hellofresh/janus/pkg/api"
+	"github.com/hellofresh/janus/pkg/config"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// newCertManager creates the manager obtaining and renewing the certificates of the configured hosts.
+// The certificates are stored in the API definitions database, so all the instances share them, and in
+// the cache directory for the file based definitions.
+func newCertManager(cfg config.ACME, provider api.Repository) (*autocert.Manager, error) {
+	if len(cfg.Hosts) == 0 {
+		return nil, errors.New("ACME hosts are not set")
+	}
+
+	var cache autocert.Cache
+	if store, ok := provider.(api.CertificateStore); ok {
+		cache = store.CertificateCache()
+	} else if cfg.CacheDir != "" {
+		cache = autocert.DirCache(cfg.CacheDir)
+	} else {
+		return nil, errors.New("ACME cache directory is not set")
+	}
+
+	manager := &autocert.Manager{
+		Prompt:      autocert.AcceptTOS,
+		HostPolicy:  autocert.HostWhitelist(cfg.Hosts...),
+		Cache:       cache,
+		Email:       cfg.Email,
+		RenewBefore: cfg.RenewBefore,
+	}
+	if cfg.DirectoryURL != "" {
+		manager.Client = &acme.Client{DirectoryURL: cfg.DirectoryURL}
+	}
+
+	return manager, nil
+}
+
+// isACMEChallenge tells if the TLS handshake is a tls-alpn-01 challenge of the certificate authority
+func isACMEChallenge(hello *tls.ClientHelloInfo) bool {
+	return len(hello.SupportedProtos) == 1 && hello.SupportedProtos[0] == acme.ALPNProto
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hellofresh/janus/pkg/api"
+	"github.com/hellofresh/janus/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+func TestNewCertManager(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	manager, err := newCertManager(config.ACME{Hosts: []string{"api.example.com"}, CacheDir: dir}, api.NewInMemoryRepository())
+	require.NoError(t, err)
+
+	assert.Equal(t, autocert.DirCache(dir), manager.Cache)
+	assert.NoError(t, manager.HostPolicy(context.Background(), "api.example.com"))
+	assert.Error(t, manager.HostPolicy(context.Background(), "other.example.com"), "not allowed hosts are refused")
+	assert.Nil(t, manager.Client, "Let's Encrypt directory is the default")
+}
+
+type certificateStoreRepository struct {
+	*api.InMemoryRepository
+	cache autocert.Cache
+}
+
+func (r certificateStoreRepository) CertificateCache() autocert.Cache {
+	return r.cache
+}
+
+func TestNewCertManagerSharedCache(t *testing.T) {
+	cache := autocert.DirCache("shared")
+	manager, err := newCertManager(config.ACME{Hosts: []string{"api.example.com"}, CacheDir: os.TempDir()}, certificateStoreRepository{api.NewInMemoryRepository(), cache})
+	require.NoError(t, err)
+
+	assert.Equal(t, cache, manager.Cache, "the certificates are stored by the provider")
+}
+
+func TestNewCertManagerInvalid(t *testing.T) {
+	_, err := newCertManager(config.ACME{CacheDir: os.TempDir()}, api.NewInMemoryRepository())
+	assert.Error(t, err, "hosts are required")
+
+	_, err = newCertManager(config.ACME{Hosts: []string{"api.example.com"}}, api.NewInMemoryRepository())
+	assert.Error(t, err, "the cache directory is required without a certificate store")
+}
@@ -0,0 +1,211 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hellofresh/janus/pkg/config"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// certificates selects the certificate of the TLS handshakes by their server name, among the
+// configured ones, and reloads the certificate files when they change
+type certificates struct {
+	files       []config.Certificate
+	defaultFile *config.Certificate
+
+	mu       sync.RWMutex
+	exact    map[string]*tls.Certificate
+	wildcard map[string]*tls.Certificate
+	fallback *tls.Certificate
+	modTimes map[string]time.Time
+}
+
+// newCertificates loads the certificates selected by the server name, the certificate files of the
+// TLS config are served for the other names
+func newCertificates(cfg config.TLS) (*certificates, error) {
+	c := &certificates{files: cfg.Certificates}
+	if cfg.IsHTTPS() {
+		c.defaultFile = &config.Certificate{CertFile: cfg.CertFile, KeyFile: cfg.KeyFile}
+	}
+
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// load reads all the certificate files, the certificates in use are kept when one of them is invalid
+func (c *certificates) load() error {
+	exact := make(map[string]*tls.Certificate)
+	wildcard := make(map[string]*tls.Certificate)
+	modTimes := make(map[string]time.Time)
+
+	var fallback *tls.Certificate
+	if c.defaultFile != nil {
+		cert, err := loadCertificate(*c.defaultFile, modTimes)
+		if err != nil {
+			return err
+		}
+		fallback = cert
+	}
+
+	for i, file := range c.files {
+		cert, err := loadCertificate(file, modTimes)
+		if err != nil {
+			return errors.Wrapf(err, "certificate %d", i)
+		}
+
+		hosts := file.Hosts
+		if len(hosts) == 0 {
+			hosts = cert.Leaf.DNSNames
+		}
+		if len(hosts) == 0 {
+			return errors.Errorf("certificate %d has no hosts and no DNS names", i)
+		}
+
+		for _, host := range hosts {
+			host = strings.ToLower(strings.TrimSuffix(host, "."))
+			if strings.HasPrefix(host, "*.") {
+				// the first certificate of a host wins, like the routes of the API definitions
+				if _, ok := wildcard[host[2:]]; !ok {
+					wildcard[host[2:]] = cert
+				}
+			} else if _, ok := exact[host]; !ok {
+				exact[host] = cert
+			}
+		}
+
+		if fallback == nil {
+			fallback = cert
+		}
+	}
+
+	c.mu.Lock()
+	c.exact, c.wildcard, c.fallback, c.modTimes = exact, wildcard, fallback, modTimes
+	c.mu.Unlock()
+
+	return nil
+}
+
+func loadCertificate(file config.Certificate, modTimes map[string]time.Time) (*tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(file.CertFile, file.KeyFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not load the certificate %s", file.CertFile)
+	}
+
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return nil, errors.Wrapf(err, "could not parse the certificate %s", file.CertFile)
+		}
+	}
+
+	for _, name := range []string{file.CertFile, file.KeyFile} {
+		if info, err := os.Stat(name); err == nil {
+			modTimes[name] = info.ModTime()
+		}
+	}
+
+	return &cert, nil
+}
+
+// match returns the certificate of the server name, an exact host wins over a wildcard one
+func (c *certificates) match(serverName string) *tls.Certificate {
+	serverName = strings.ToLower(strings.TrimSuffix(serverName, "."))
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if cert, ok := c.exact[serverName]; ok {
+		return cert
+	}
+	// a wildcard matches a single label, as the wildcard certificates do
+	if i := strings.IndexByte(serverName, '.'); i > 0 {
+		if cert, ok := c.wildcard[serverName[i+1:]]; ok {
+			return cert
+		}
+	}
+
+	return nil
+}
+
+// GetCertificate is the tls.Config callback returning the certificate of the server name, the default
+// certificate is returned for the other names and the clients not sending one
+func (c *certificates) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if cert := c.match(hello.ServerName); cert != nil {
+		return cert, nil
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.fallback == nil {
+		return nil, errors.Errorf("no certificate for the server name %q", hello.ServerName)
+	}
+	return c.fallback, nil
+}
+
+// changed tells if a certificate file was modified since it was loaded
+func (c *certificates) changed() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for name, modTime := range c.modTimes {
+		info, err := os.Stat(name)
+		if err != nil || !info.ModTime().Equal(modTime) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// watch reloads the certificates when their files change until the context is done
+func (c *certificates) watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if !c.changed() {
+				continue
+			}
+
+			if err := c.load(); err != nil {
+				log.WithError(err).Error("Could not reload the TLS certificates, keeping the current ones")
+				continue
+			}
+			log.Info("TLS certificates reloaded")
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// clientAuthType returns the client certificate policy of the TLS handshakes. The certificates are not
+// verified by the handshakes, the client_cert plugin verifies them against the CA of the routes, so the
+// routes without the plugin accept the anonymous clients.
+func clientAuthType(clientAuth string) (tls.ClientAuthType, error) {
+	switch clientAuth {
+	case "", "none":
+		return tls.NoClientCert, nil
+	case "request":
+		return tls.RequestClientCert, nil
+	case "require":
+		return tls.RequireAnyClientCert, nil
+	default:
+		return tls.NoClientCert, errors.Errorf("invalid TLS client auth %q, must be none, request or require", clientAuth)
+	}
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hellofresh/janus/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCertificate writes a self signed certificate of the DNS names, named after the common name
+func writeCertificate(t *testing.T, dir, commonName string, dnsNames ...string) config.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	cert := config.Certificate{
+		CertFile: filepath.Join(dir, commonName+".crt"),
+		KeyFile:  filepath.Join(dir, commonName+".key"),
+	}
+	require.NoError(t, ioutil.WriteFile(cert.CertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, ioutil.WriteFile(cert.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+
+	return cert
+}
+
+// servedCertificate returns the common name of the certificate served for the server name
+func servedCertificate(t *testing.T, address, serverName string) string {
+	conn, err := tls.Dial("tcp", address, &tls.Config{ServerName: serverName, InsecureSkipVerify: true})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	return conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+}
+
+func TestCertificatesSelectedByServerName(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certificates")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	defaultCert := writeCertificate(t, dir, "default", "default.example.com")
+	api := writeCertificate(t, dir, "api", "api.example.com")
+	wildcard := writeCertificate(t, dir, "wildcard")
+	wildcard.Hosts = []string{"*.example.org", "example.org"}
+
+	certs, err := newCertificates(config.TLS{
+		CertFile:     defaultCert.CertFile,
+		KeyFile:      defaultCert.KeyFile,
+		Certificates: []config.Certificate{api, wildcard},
+	})
+	require.NoError(t, err)
+
+	// the listener has no other certificate, as the proxy TLS config
+	var mu sync.Mutex
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return certs.GetCertificate(hello)
+	}})
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+	address := ln.Addr().String()
+
+	for serverName, expected := range map[string]string{
+		"api.example.com":     "api",
+		"API.Example.com":     "api",
+		"www.example.org":     "wildcard",
+		"example.org":         "wildcard",
+		"a.www.example.org":   "default",
+		"other.example.com":   "default",
+		"default.example.com": "default",
+		"":                    "default",
+	} {
+		assert.Equal(t, expected, servedCertificate(t, address, serverName), serverName)
+	}
+
+	// the first certificate is the default without the certificate files
+	reloaded, err := newCertificates(config.TLS{Certificates: []config.Certificate{api}})
+	require.NoError(t, err)
+	mu.Lock()
+	certs = reloaded
+	mu.Unlock()
+	assert.Equal(t, "api", servedCertificate(t, address, "other.example.com"))
+
+	// the certificate files are reloaded when they change
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloaded.watch(ctx, 10*time.Millisecond)
+
+	renewed := writeCertificate(t, dir, "renewed", "api.example.com")
+	require.NoError(t, os.Rename(renewed.CertFile, api.CertFile))
+	require.NoError(t, os.Rename(renewed.KeyFile, api.KeyFile))
+	assert.Eventually(t, func() bool {
+		return servedCertificate(t, address, "api.example.com") == "renewed"
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestCertificatesInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certificates")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = newCertificates(config.TLS{Certificates: []config.Certificate{{CertFile: filepath.Join(dir, "missing.crt"), KeyFile: filepath.Join(dir, "missing.key")}}})
+	assert.Error(t, err)
+
+	_, err = newCertificates(config.TLS{Certificates: []config.Certificate{writeCertificate(t, dir, "nameless")}})
+	assert.Error(t, err, "the hosts are required when the certificate has no DNS names")
+
+	certs, err := newCertificates(config.TLS{})
+	require.NoError(t, err)
+	_, err = certs.GetCertificate(&tls.ClientHelloInfo{ServerName: "api.example.com"})
+	assert.Error(t, err, "no certificate is served without a default")
+}
+
+func TestClientAuthType(t *testing.T) {
+	for clientAuth, expected := range map[string]tls.ClientAuthType{
+		"":        tls.NoClientCert,
+		"none":    tls.NoClientCert,
+		"request": tls.RequestClientCert,
+		"require": tls.RequireAnyClientCert,
+	} {
+		actual, err := clientAuthType(clientAuth)
+		require.NoError(t, err)
+		assert.Equal(t, expected, actual, clientAuth)
+	}
+
+	_, err := clientAuthType("verify")
+	assert.Error(t, err)
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+
+	"github.com/hellofresh/janus/pkg/config"
+	"github.com/hellofresh/janus/pkg/listener"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/http2"
+)
+
+// namedListener is a proxy listener besides the default one, its server only dispatches the requests
+// to the routes of the definitions bound to it
+type namedListener struct {
+	config   config.Listener
+	server   *http.Server
+	listener net.Listener
+	// h2c serves HTTP/2 without TLS when it is enabled
+	h2c *http2.Server
+}
+
+// validateListeners checks the names of the listeners are unique and their settings are valid
+func validateListeners(listeners []config.Listener) error {
+	names := map[string]bool{proxy.DefaultListener: true}
+	for _, l := range listeners {
+		if l.Name == "" {
+			return errors.New("the listener name is required")
+		}
+		if names[l.Name] {
+			return errors.Errorf("the listener %q is duplicated", l.Name)
+		}
+		names[l.Name] = true
+
+		if l.Address == "" {
+			return errors.Errorf("the address of the listener %q is required", l.Name)
+		}
+		if _, err := clientAuthType(l.TLS.ClientAuth); err != nil {
+			return errors.Wrapf(err, "invalid TLS of the listener %q", l.Name)
+		}
+	}
+
+	return nil
+}
+
+// unixAddresses returns the Unix domain socket addresses of the default and the named proxy listeners
+func unixAddresses(globalConfig *config.Specification) []string {
+	var addresses []string
+	if listener.IsUnix(globalConfig.Listen) {
+		addresses = append(addresses, globalConfig.Listen)
+	}
+	for _, l := range globalConfig.Listeners {
+		if listener.IsUnix(l.Address) {
+			addresses = append(addresses, l.Address)
+		}
+	}
+
+	return addresses
+}
+
+// listenerNames returns the names of the listeners besides the default one
+func listenerNames(listeners []config.Listener) []string {
+	names := make([]string, 0, len(listeners))
+	for _, l := range listeners {
+		names = append(names, l.Name)
+	}
+
+	return names
+}
+
+// listenerTimeouts returns the responding timeouts of the listener, the timeouts it does not set are
+// the global ones
+func listenerTimeouts(timeouts, defaults config.RespondingTimeouts) config.RespondingTimeouts {
+	if timeouts.ReadTimeout == 0 {
+		timeouts.ReadTimeout = defaults.ReadTimeout
+	}
+	if timeouts.ReadHeaderTimeout == 0 {
+		timeouts.ReadHeaderTimeout = defaults.ReadHeaderTimeout
+	}
+	if timeouts.WriteTimeout == 0 {
+		timeouts.WriteTimeout = defaults.WriteTimeout
+	}
+	if timeouts.IdleTimeout == 0 {
+		timeouts.IdleTimeout = defaults.IdleTimeout
+	}
+
+	return timeouts
+}
+
+// withListener tells the routes the listener the requests were received on
+func withListener(name string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r.WithContext(proxy.WithListener(r.Context(), name)))
+	})
+}
+
+// listenerHandler returns the handler of a listener serving the router
+func (s *Server) listenerHandler(name string, handler http.Handler) http.Handler {
+	return limitRequestHeaders(s.withServeContext(withListener(name, handler)), s.globalConfig.RequestHeaders)
+}
+
+// startListeners opens the named listeners and serves the router on them, with their own TLS and timeouts
+func (s *Server) startListeners(handler http.Handler) error {
+	for _, cfg := range s.globalConfig.Listeners {
+		timeouts := listenerTimeouts(cfg.RespondingTimeouts, s.globalConfig.RespondingTimeouts)
+		l := &namedListener{config: cfg, server: &http.Server{
+			Addr:              cfg.Address,
+			Handler:           s.listenerHandler(cfg.Name, handler),
+			MaxHeaderBytes:    s.globalConfig.RequestHeaders.MaxSize,
+			ReadTimeout:       timeouts.ReadTimeout,
+			ReadHeaderTimeout: timeouts.ReadHeaderTimeout,
+			WriteTimeout:      timeouts.WriteTimeout,
+			IdleTimeout:       timeouts.IdleTimeout,
+			ConnState:         s.trackConn,
+		}}
+
+		if cfg.TLS.HasCertificates() {
+			certs, err := newCertificates(config.TLS{CertFile: cfg.TLS.CertFile, KeyFile: cfg.TLS.KeyFile, Certificates: cfg.TLS.Certificates})
+			if err != nil {
+				return errors.Wrapf(err, "could not load the TLS certificates of the listener %q", cfg.Name)
+			}
+			go certs.watch(s.serveCtx, s.globalConfig.TLS.CertificatesReloadInterval)
+
+			clientAuth, err := clientAuthType(cfg.TLS.ClientAuth)
+			if err != nil {
+				return err
+			}
+			l.server.TLSConfig = &tls.Config{GetCertificate: certs.GetCertificate, ClientAuth: clientAuth}
+		} else if cfg.H2C {
+			var err error
+			if l.h2c, err = serveH2C(l.server); err != nil {
+				return err
+			}
+			l.server.Handler = s.h2cHandler(l.h2c, l.server.Handler)
+		}
+
+		ln, err := s.listen(cfg.Address, false)
+		if err != nil {
+			return errors.Wrapf(err, "error opening the listener %q", cfg.Name)
+		}
+		l.listener = ln
+		s.listeners = append(s.listeners, l)
+
+		go func() {
+			logger := log.WithFields(log.Fields{"listener": l.config.Name, "address": l.config.Address})
+			var err error
+			if l.config.TLS.HasCertificates() {
+				logger.Info("Listening HTTPS")
+				err = l.server.ServeTLS(l.listener, "", "")
+			} else {
+				logger.Info("Listening HTTP")
+				err = l.server.Serve(l.listener)
+			}
+			if err != http.ErrServerClosed {
+				logger.WithError(err).Fatal("Could not serve the listener")
+			}
+		}()
+	}
+
+	return nil
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hellofresh/janus/pkg/config"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+)
+
+func TestValidateListeners(t *testing.T) {
+	assert.NoError(t, validateListeners(nil))
+	assert.NoError(t, validateListeners([]config.Listener{
+		{Name: "partner", Address: ":8443", TLS: config.ListenerTLS{ClientAuth: "require"}},
+		{Name: "internal", Address: "127.0.0.1:8081"},
+	}))
+
+	assert.Error(t, validateListeners([]config.Listener{{Address: ":8443"}}), "the name is required")
+	assert.Error(t, validateListeners([]config.Listener{{Name: "partner"}}), "the address is required")
+	assert.Error(t, validateListeners([]config.Listener{{Name: proxy.DefaultListener, Address: ":8443"}}), "the default listener is reserved")
+	assert.Error(t, validateListeners([]config.Listener{
+		{Name: "partner", Address: ":8443"},
+		{Name: "partner", Address: ":8444"},
+	}))
+	assert.Error(t, validateListeners([]config.Listener{{Name: "partner", Address: ":8443", TLS: config.ListenerTLS{ClientAuth: "sometimes"}}}))
+}
+
+func TestUnixAddresses(t *testing.T) {
+	assert.Empty(t, unixAddresses(&config.Specification{Listen: ":8080"}))
+	assert.Equal(t, []string{"unix:/var/run/janus.sock", "unix:/var/run/partner.sock"}, unixAddresses(&config.Specification{
+		Listen: "unix:/var/run/janus.sock",
+		Listeners: []config.Listener{
+			{Name: "partner", Address: "unix:/var/run/partner.sock"},
+			{Name: "internal", Address: "127.0.0.1:8081"},
+		},
+	}))
+}
+
+func TestListenerTimeouts(t *testing.T) {
+	defaults := config.RespondingTimeouts{ReadTimeout: time.Second, WriteTimeout: 2 * time.Second, IdleTimeout: time.Minute}
+	timeouts := listenerTimeouts(config.RespondingTimeouts{WriteTimeout: time.Minute, ReadHeaderTimeout: time.Second}, defaults)
+
+	assert.Equal(t, config.RespondingTimeouts{
+		ReadTimeout:       time.Second,
+		ReadHeaderTimeout: time.Second,
+		WriteTimeout:      time.Minute,
+		IdleTimeout:       time.Minute,
+	}, timeouts)
+}
+
+func TestStartListeners(t *testing.T) {
+	dir, err := ioutil.TempDir("", "listeners")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	cert := writeCertificate(t, dir, "partner", "partner.example.com")
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(proxy.ListenerFromContext(r.Context())))
+	})
+	s, url := startTestServer(t, time.Second, handler)
+	s.globalConfig.Listeners = []config.Listener{
+		{Name: "internal", Address: "127.0.0.1:0"},
+		{Name: "partner", Address: "127.0.0.1:0", TLS: config.ListenerTLS{CertFile: cert.CertFile, KeyFile: cert.KeyFile}},
+	}
+	require.NoError(t, s.startListeners(handler))
+	require.Len(t, s.listeners, 2)
+
+	get := func(client *http.Client, url string) string {
+		resp, err := client.Get(url)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	assert.Equal(t, proxy.DefaultListener, get(http.DefaultClient, url))
+	assert.Equal(t, "internal", get(http.DefaultClient, "http://"+s.listeners[0].listener.Addr().String()))
+
+	partner := s.listeners[1].listener.Addr().String()
+	assert.Equal(t, "partner", servedCertificate(t, partner, "partner.example.com"), "the listener serves its own certificate")
+	tlsClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	assert.Equal(t, "partner", get(tlsClient, "https://"+partner))
+
+	assert.NoError(t, s.Shutdown())
+	_, err = http.Get("http://" + s.listeners[0].listener.Addr().String())
+	assert.Error(t, err, "the listeners are shut down with the server")
+}
+
+func TestStartListenersH2C(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})
+	s, _ := startTestServer(t, time.Second, handler)
+	s.globalConfig.Listeners = []config.Listener{{Name: "grpc", Address: "127.0.0.1:0", H2C: true}}
+	require.NoError(t, s.startListeners(handler))
+	defer s.Shutdown()
+
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+
+	resp, err := client.Get("http://" + s.listeners[0].listener.Addr().String())
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/2.0", string(body), "the listener serves HTTP/2 without TLS")
+
+	resp, err = http.Get("http://" + s.listeners[0].listener.Addr().String())
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, 1, resp.ProtoMajor, "the listener serves HTTP/1.1 as well")
+}
+
+func TestShutdownWithIdleH2CConnection(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})
+	s, _ := startTestServer(t, 200*time.Millisecond, handler)
+	s.globalConfig.Listeners = []config.Listener{{Name: "grpc", Address: "127.0.0.1:0", H2C: true}}
+	require.NoError(t, s.startListeners(handler))
+
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+	resp, err := client.Get("http://" + s.listeners[0].listener.Addr().String())
+	require.NoError(t, err)
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	shutdown := make(chan error)
+	go func() { shutdown <- s.Shutdown() }()
+
+	select {
+	case err := <-shutdown:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("shutdown did not return with an idle h2c connection open")
+	}
+}
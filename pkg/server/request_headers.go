@@ -0,0 +1,39 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/hellofresh/janus/pkg/config"
+	"github.com/hellofresh/janus/pkg/errors"
+)
+
+// ErrRequestHeadersTooLarge is returned when the request headers are over the configured limits
+var ErrRequestHeadersTooLarge = errors.New(http.StatusRequestHeaderFieldsTooLarge, "request header fields too large")
+
+// limitRequestHeaders rejects the requests with too many or too large headers, before any routing or
+// plugin. The connection reader already stops reading the headers a little above the maximum size,
+// so the oversized requests do not use up the memory, the exact limit is checked here.
+func limitRequestHeaders(handler http.Handler, cfg config.RequestHeaders) http.Handler {
+	if cfg.MaxSize <= 0 && cfg.MaxCount <= 0 {
+		return handler
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count, size := 0, len(r.Host)
+		for name, values := range r.Header {
+			for _, value := range values {
+				// the header line is "Name: value\r\n"
+				count, size = count+1, size+len(name)+len(value)+4
+			}
+		}
+
+		if (cfg.MaxCount > 0 && count > cfg.MaxCount) || (cfg.MaxSize > 0 && size > cfg.MaxSize) {
+			// the connection is closed, its unread body may be as large as the headers
+			w.Header().Set("Connection", "close")
+			errors.Handler(w, ErrRequestHeadersTooLarge)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hellofresh/janus/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitRequestHeaders(t *testing.T) {
+	handler := limitRequestHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}), config.RequestHeaders{MaxSize: 256, MaxCount: 3})
+
+	tests := []struct {
+		scenario string
+		headers  map[string][]string
+		status   int
+	}{
+		{
+			scenario: "within the limits",
+			headers:  map[string][]string{"Accept": {"*/*"}, "X-Foo": {"bar"}},
+			status:   http.StatusNoContent,
+		},
+		{
+			scenario: "too many headers",
+			headers:  map[string][]string{"Accept": {"*/*"}, "X-Foo": {"bar", "baz", "qux"}},
+			status:   http.StatusRequestHeaderFieldsTooLarge,
+		},
+		{
+			scenario: "too large headers",
+			headers:  map[string][]string{"Cookie": {strings.Repeat("a", 256)}},
+			status:   http.StatusRequestHeaderFieldsTooLarge,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.scenario, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header = test.headers
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+			assert.Equal(t, test.status, w.Code)
+		})
+	}
+}
+
+func TestServerRejectsOversizedHeaders(t *testing.T) {
+	s := New(WithGlobalConfig(&config.Specification{RequestHeaders: config.RequestHeaders{MaxSize: 1024}}))
+	server := httptest.NewUnstartedServer(nil)
+	server.Config = s.newHTTPServer("", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	server.Start()
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("Cookie", strings.Repeat("a", 64<<10))
+	resp, err := http.DefaultClient.Do(req)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusRequestHeaderFieldsTooLarge, resp.StatusCode, "the connection stops reading the headers")
+	}
+}
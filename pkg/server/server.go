@@ -1,29 +1,46 @@
 package server
 
 import (
+	"bufio"
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
 	"net/http"
+	"os"
+	"sync"
 	"time"
 
-	"github.com/go-chi/chi"
+	"github.com/felixge/httpsnoop"
+	"github.com/go-redis/redis"
 	"github.com/hellofresh/janus/pkg/api"
+	"github.com/hellofresh/janus/pkg/audit"
 	"github.com/hellofresh/janus/pkg/config"
+	"github.com/hellofresh/janus/pkg/drain"
 	"github.com/hellofresh/janus/pkg/errors"
+	"github.com/hellofresh/janus/pkg/listener"
 	"github.com/hellofresh/janus/pkg/loader"
+	"github.com/hellofresh/janus/pkg/lock"
+	"github.com/hellofresh/janus/pkg/maintenance"
 	"github.com/hellofresh/janus/pkg/middleware"
 	"github.com/hellofresh/janus/pkg/plugin"
 	"github.com/hellofresh/janus/pkg/proxy"
 	"github.com/hellofresh/janus/pkg/router"
+	"github.com/hellofresh/janus/pkg/sampling"
+	"github.com/hellofresh/janus/pkg/upstream"
 	"github.com/hellofresh/janus/pkg/web"
+	"github.com/hellofresh/janus/pkg/webhook"
 	"github.com/hellofresh/stats-go/client"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 // Server is the Janus server
 type Server struct {
 	server                *http.Server
+	httpServer            *http.Server
+	listeners             []*namedListener
 	provider              api.Repository
 	register              *proxy.Register
 	apiLoader             *loader.APILoader
@@ -33,16 +50,49 @@ type Server struct {
 	globalConfig          *config.Specification
 	statsClient           client.Client
 	webServer             *web.Server
+	notifier              *webhook.Notifier
 	profilingEnabled      bool
 	profilingPublic       bool
+	readiness             *web.Readiness
+	drainer               *drain.Drainer
+	maintenance           *maintenance.Modes
+	weights               *upstream.Weights
+	samplingRates         *sampling.Rates
+	upstreams             *upstream.States
+	clientIP              *middleware.ClientIP
+	correlationID         *middleware.CorrelationID
+	baggage               *middleware.Baggage
+	errorTemplates        *errors.Templates
+	accessLog             *middleware.AccessLog
+
+	// the requests are served with their own context, cancelled only when the shutdown grace
+	// period is exceeded, so the in-flight requests are not dropped on shutdown
+	serveCtx    context.Context
+	cancelServe context.CancelFunc
+	shutdownErr error
+	closeOnce   sync.Once
+
+	connsMu sync.Mutex
+	conns   map[net.Conn]http.ConnState
+
+	// h2c serves HTTP/2 without TLS on the HTTP port when it is enabled
+	h2c *http2.Server
 }
 
+// ErrShutdownTimeout is returned when the in-flight requests did not finish within the shutdown grace period
+var ErrShutdownTimeout = errors.New(http.StatusServiceUnavailable, "shutdown grace period exceeded")
+
 // New creates a new instance of Server
 func New(opts ...Option) *Server {
 	s := Server{
 		configurationChan: make(chan api.ConfigurationChanged, 100),
 		stopChan:          make(chan struct{}, 1),
+		conns:             make(map[net.Conn]http.ConnState),
+		maintenance:       maintenance.NewModes(),
+		weights:           upstream.NewWeights(),
+		upstreams:         upstream.NewStates(),
 	}
+	s.serveCtx, s.cancelServe = context.WithCancel(context.Background())
 
 	for _, opt := range opts {
 		opt(&s)
@@ -58,16 +108,62 @@ func (s *Server) Start() error {
 
 // StartWithContext starts the server and Stop/Close it when context is Done
 func (s *Server) StartWithContext(ctx context.Context) error {
+	if err := validateListeners(s.globalConfig.Listeners); err != nil {
+		return errors.Wrap(err, "invalid listeners")
+	}
+
+	trustedProxies, err := listener.ParseCIDRs(s.globalConfig.ClientIP.TrustedProxies)
+	if err != nil {
+		return errors.Wrap(err, "invalid client IP trusted proxies")
+	}
+	s.clientIP = middleware.NewClientIP(trustedProxies, s.globalConfig.ClientIP.TrustUnixSocket)
+	if addresses := unixAddresses(s.globalConfig); len(addresses) > 0 && !s.globalConfig.ClientIP.TrustUnixSocket {
+		log.WithField("addresses", addresses).
+			Warn("The requests of the Unix domain sockets have no client address without clientIP.trustUnixSocket, the rate limit and quota plugins count them together")
+	}
+	s.samplingRates = sampling.NewRates(s.globalConfig.Tracing.SamplingRate())
+
+	correlation := s.globalConfig.CorrelationID
+	if s.correlationID, err = middleware.NewCorrelationID(correlation.Header, correlation.BaggageKey, correlation.FromTrace); err != nil {
+		return errors.Wrap(err, "invalid correlation ID")
+	}
+	// the errors rendered by Janus hold the request ID of the correlation ID header
+	errors.RequestIDHeader = s.correlationID.Header()
+
+	if len(s.globalConfig.Tracing.Baggage) > 0 {
+		if s.baggage, err = middleware.NewBaggage(s.globalConfig.Tracing.Baggage); err != nil {
+			return errors.Wrap(err, "invalid tracing baggage")
+		}
+	}
+
+	if format := s.globalConfig.AccessLog.Format; format != "" && !middleware.IsAccessLogFormat(format) {
+		return fmt.Errorf("invalid access log format %q", format)
+	}
+	s.accessLog = middleware.NewAccessLog(s.globalConfig.AccessLog.Fields, os.Stdout).WithFormat(s.globalConfig.AccessLog.Format)
+
+	if len(s.globalConfig.ErrorTemplates) > 0 {
+		s.errorTemplates, err = errors.NewTemplates(s.globalConfig.ErrorTemplates)
+		if err != nil {
+			return errors.Wrap(err, "invalid error templates")
+		}
+	}
+
+	s.drainer = drain.NewDrainer(s.globalConfig.DrainGracePeriod, drainConns{s})
+	s.readiness = web.NewReadiness(s.readinessChecks()...)
+
 	go func() {
 		defer s.Close()
 		<-ctx.Done()
 		log.Info("I have to go...")
+		// stop receiving the traffic while the incoming requests cease
+		s.readiness.SetReady(false)
 		reqAcceptGraceTimeOut := time.Duration(s.globalConfig.GraceTimeOut)
 		if reqAcceptGraceTimeOut > 0 {
 			log.Infof("Waiting %s for incoming requests to cease", reqAcceptGraceTimeOut)
 			time.Sleep(reqAcceptGraceTimeOut)
 		}
 		log.Info("Stopping server gracefully")
+		s.shutdownErr = s.Shutdown()
 	}()
 
 	// Register must be initialised synchronously to avoid race condition
@@ -75,13 +171,34 @@ func (s *Server) StartWithContext(ctx context.Context) error {
 	s.register = proxy.NewRegister(
 		proxy.WithRouter(r),
 		proxy.WithFlushInterval(s.globalConfig.BackendFlushInterval),
+		proxy.WithBuffering(proxy.Buffering{
+			ReadBufferSize:        s.globalConfig.Buffering.ReadBufferSize,
+			FlushBufferSize:       s.globalConfig.Buffering.FlushBufferSize,
+			Stream:                &s.globalConfig.Buffering.Stream,
+			MaxResponseBodySize:   s.globalConfig.Buffering.MaxResponseBodySize,
+			BufferRequestBodySize: s.globalConfig.Buffering.BufferRequestBodySize,
+		}),
 		proxy.WithIdleConnectionsPerHost(s.globalConfig.MaxIdleConnsPerHost),
 		proxy.WithIdleConnTimeout(s.globalConfig.IdleConnTimeout),
 		proxy.WithStatsClient(s.statsClient),
+		proxy.WithInFlightTracker(s.upstreams),
+		proxy.WithListeners(listenerNames(s.globalConfig.Listeners)...),
 	)
 
 	// API Loader must be initialised synchronously as well to avoid race condition
-	s.apiLoader = loader.NewAPILoader(s.register)
+	s.apiLoader = loader.NewAPILoader(s.register,
+		loader.WithMaintenanceModes(s.maintenance),
+		loader.WithWeights(s.weights),
+		loader.WithSamplingRates(s.samplingRates),
+		loader.WithRequestTimeout(s.globalConfig.RequestTimeout),
+		loader.WithAccessLog(s.accessLog),
+		loader.WithAccessLogEnabled(s.globalConfig.AccessLog.Enabled),
+		loader.WithPluginSpans(s.globalConfig.Tracing.PluginSpans),
+	)
+
+	if err := s.startListeners(r); err != nil {
+		return err
+	}
 
 	go func() {
 		if err := s.startHTTPServers(ctx, r); err != nil {
@@ -89,6 +206,9 @@ func (s *Server) StartWithContext(ctx context.Context) error {
 		}
 	}()
 
+	s.notifier = webhook.NewNotifier(s.globalConfig.Webhooks)
+	s.notifier.Start(ctx)
+
 	go s.listenProviders(s.stopChan)
 
 	definitions, err := s.provider.FindAll()
@@ -106,6 +226,7 @@ func (s *Server) StartWithContext(ctx context.Context) error {
 		Register:      s.register,
 		Config:        s.globalConfig,
 		Configuration: definitions,
+		Upstreams:     s.upstreams,
 	}
 
 	if mgoRepo, ok := s.provider.(*api.MongoRepository); ok {
@@ -114,39 +235,175 @@ func (s *Server) StartWithContext(ctx context.Context) error {
 
 	plugin.EmitEvent(plugin.StartupEvent, event)
 	s.apiLoader.RegisterAPIs(definitions)
+	s.readiness.SetReady(true)
 
 	log.Info("Janus started")
 
 	return nil
 }
 
-// Wait blocks until server is shut down.
-func (s *Server) Wait() {
+func (s *Server) readinessChecks() []web.ReadinessCheck {
+	checks := []web.ReadinessCheck{{Name: "drain", Check: s.drainer.Check}}
+	if pinger, ok := s.provider.(api.Pinger); ok {
+		checks = append(checks, web.ReadinessCheck{Name: "database", Check: pinger.Ping})
+	}
+
+	return checks
+}
+
+// Wait blocks until server is shut down, it returns ErrShutdownTimeout when the in-flight requests
+// did not finish within the shutdown grace period.
+func (s *Server) Wait() error {
 	<-s.stopChan
+	return s.shutdownErr
 }
 
 // Stop stops the server
 func (s *Server) Stop() {
 	defer log.Info("Server stopped")
 
-	graceTimeOut := time.Duration(s.globalConfig.GraceTimeOut)
-	ctx, cancel := context.WithTimeout(context.Background(), graceTimeOut)
+	s.shutdownErr = s.Shutdown()
+	log.Debug("Server closed")
+
+	s.stopChan <- struct{}{}
+}
+
+// Shutdown stops accepting new connections and waits up to the shutdown grace period for the
+// in-flight requests to finish. The requests and connections still open at the deadline, e.g.
+// WebSocket and SSE streams, are closed forcibly.
+func (s *Server) Shutdown() error {
+	if s.server == nil {
+		return nil
+	}
+
+	gracePeriod := s.globalConfig.ShutdownGracePeriod
+	ctx, cancel := context.WithTimeout(context.Background(), gracePeriod)
 	defer cancel()
-	log.Debugf("Waiting %s seconds before killing connections...", graceTimeOut)
-	if err := s.server.Shutdown(ctx); err != nil {
-		log.WithError(err).Debug("Wait is over due to error")
+
+	log.Infof("Waiting %s for in-flight requests to finish", gracePeriod)
+	if s.httpServer != nil {
+		s.httpServer.Shutdown(ctx)
+	}
+	err := s.server.Shutdown(ctx)
+	for _, l := range s.listeners {
+		if listenerErr := l.server.Shutdown(ctx); err == nil {
+			err = listenerErr
+		}
+	}
+	if err == nil {
+		// hijacked connections, i.e. WebSocket, are not tracked by the http server
+		err = s.waitHijacked(ctx)
+	}
+
+	if err != nil {
+		log.WithError(err).Warn("Shutdown grace period exceeded, closing the remaining connections")
+		s.cancelServe()
 		s.server.Close()
+		for _, l := range s.listeners {
+			l.server.Close()
+		}
+		s.closeConns()
+		return ErrShutdownTimeout
 	}
-	log.Debug("Server closed")
 
-	s.stopChan <- struct{}{}
+	s.cancelServe()
+	return nil
+}
+
+func (s *Server) trackConn(conn net.Conn, state http.ConnState) {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+
+	if state == http.StateClosed {
+		delete(s.conns, conn)
+		return
+	}
+	s.conns[conn] = state
+}
+
+// waitHijacked waits for the hijacked connections to be closed. The h2c connections are served on a
+// hijacked connection, they are closed once idle as the http server does with its idle connections.
+func (s *Server) waitHijacked(ctx context.Context) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		s.connsMu.Lock()
+		hijacked := 0
+		var idle []net.Conn
+		for conn, state := range s.conns {
+			switch state {
+			case http.StateHijacked:
+				hijacked++
+			case http.StateIdle:
+				idle = append(idle, conn)
+			}
+		}
+		s.connsMu.Unlock()
+
+		for _, conn := range idle {
+			conn.Close()
+		}
+		if hijacked == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// closeConns closes the tracked connections outside of the lock, the hijacked connections report their own close
+func (s *Server) closeConns() {
+	s.connsMu.Lock()
+	conns := make([]net.Conn, 0, len(s.conns))
+	for conn := range s.conns {
+		conns = append(conns, conn)
+		delete(s.conns, conn)
+	}
+	s.connsMu.Unlock()
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
+
+// drainConns are the client connections of the proxy listeners, closed by the drainer
+type drainConns struct {
+	s *Server
+}
+
+func (c drainConns) SetKeepAlivesEnabled(enabled bool) {
+	if c.s.server != nil {
+		c.s.server.SetKeepAlivesEnabled(enabled)
+	}
+	if c.s.httpServer != nil {
+		c.s.httpServer.SetKeepAlivesEnabled(enabled)
+	}
+	for _, l := range c.s.listeners {
+		l.server.SetKeepAlivesEnabled(enabled)
+	}
+}
+
+func (c drainConns) CloseAll() {
+	c.s.closeConns()
 }
 
 // Close closes the server
 func (s *Server) Close() error {
+	var err error
+	s.closeOnce.Do(func() { err = s.close() })
+	return err
+}
+
+func (s *Server) close() error {
 	defer close(s.stopChan)
 	defer close(s.configurationChan)
 	defer s.webServer.Stop()
+	defer s.cancelServe()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -159,20 +416,97 @@ func (s *Server) Close() error {
 		}
 	}(ctx)
 
+	if s.httpServer != nil {
+		s.httpServer.Close()
+	}
+	for _, l := range s.listeners {
+		l.server.Close()
+	}
 	return s.server.Close()
 }
 
 func (s *Server) startHTTPServers(ctx context.Context, r router.Router) error {
-	return s.listenAndServe(chi.ServerBaseContext(ctx, r))
+	return s.listenAndServe(s.withServeContext(r))
+}
+
+// withServeContext cancels the request context when the client disconnects, so the upstream request
+// is cancelled and the plugins release what they hold, or when the shutdown grace period is exceeded.
+// The shutdown itself does not cancel the in-flight requests.
+func (s *Server) withServeContext(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		go func() {
+			select {
+			case <-s.serveCtx.Done():
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		handler.ServeHTTP(s.trackHijacked(w), r.WithContext(ctx))
+	})
+}
+
+// trackHijacked wraps the connections hijacked by the handler, e.g. the WebSocket connections, so they
+// are forgotten once closed, the http server does not track them anymore after the hijack
+func (s *Server) trackHijacked(w http.ResponseWriter) http.ResponseWriter {
+	return httpsnoop.Wrap(w, httpsnoop.Hooks{
+		Hijack: func(next httpsnoop.HijackFunc) httpsnoop.HijackFunc {
+			return func() (net.Conn, *bufio.ReadWriter, error) {
+				conn, rw, err := next()
+				if err != nil {
+					return conn, rw, err
+				}
+				return &hijackedConn{Conn: conn, s: s}, rw, nil
+			}
+		},
+	})
+}
+
+// hijackedConn is a hijacked connection, removed from the tracked connections when it is closed
+type hijackedConn struct {
+	net.Conn
+	s    *Server
+	once sync.Once
+}
+
+func (c *hijackedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(func() { c.s.trackConn(c.Conn, http.StateClosed) })
+	return err
 }
 
 func (s *Server) startProvider(ctx context.Context) error {
+	auditSink, err := audit.NewSink(s.globalConfig.Web.Audit, s.provider)
+	if err != nil {
+		return errors.Wrap(err, "could not create audit sink")
+	}
+
+	healthResults, err := s.startHealthLeader(ctx)
+	if err != nil {
+		return errors.Wrap(err, "could not start the health checks leader")
+	}
+
 	s.webServer = web.New(
 		web.WithConfigurations(s.currentConfigurations),
 		web.WithPort(s.globalConfig.Web.Port),
+		web.WithListen(s.globalConfig.Web.Listen, s.globalConfig.SocketMode),
 		web.WithTLS(s.globalConfig.Web.TLS),
 		web.WithCredentials(s.globalConfig.Web.Credentials),
-		web.WithProfiler(s.profilingEnabled, s.profilingPublic),
+		web.WithProfiler(s.profilingEnabled || s.globalConfig.Web.Profiling.Enabled, s.profilingPublic || s.globalConfig.Web.Profiling.Public),
+		web.WithMetricsPath(s.globalConfig.Stats.PrometheusPath),
+		web.WithReadiness(s.readiness),
+		web.WithDrainer(s.drainer),
+		web.WithListeners(listenerNames(s.globalConfig.Listeners)),
+		web.WithMaintenance(s.maintenance),
+		web.WithWeights(s.weights),
+		web.WithSamplingRates(s.samplingRates),
+		web.WithUpstreamStates(s.upstreams),
+		web.WithProbePaths(s.globalConfig.Web.LivePath, s.globalConfig.Web.ReadyPath),
+		web.WithAuditTrail(audit.NewTrail(auditSink, s.globalConfig.Web.Audit.Size)),
+		web.WithHealthResults(healthResults),
 	)
 
 	if err := s.webServer.Start(); err != nil {
@@ -196,11 +530,21 @@ func (s *Server) startProvider(ctx context.Context) error {
 					return
 				}
 
-				s.updateConfigurations(c)
+				changes := []api.ConfigurationMessage{c}
+				if c.Operation == api.BatchOperation {
+					changes = c.Changes
+				}
+
+				for _, change := range changes {
+					s.updateConfigurations(change)
+				}
 				s.handleEvent(s.currentConfigurations)
 
-				if providerIsListener {
-					ch <- c
+				for _, change := range changes {
+					s.notifier.Notify(webhook.NewOperationEvent(change))
+					if providerIsListener {
+						ch <- change
+					}
 				}
 			case <-ctx.Done():
 				close(ch)
@@ -216,6 +560,34 @@ func (s *Server) startProvider(ctx context.Context) error {
 	return nil
 }
 
+// startHealthLeader takes part in the election of the instance running the health checks when the
+// leader mode is enabled, and returns the health report it shares with the other instances
+func (s *Server) startHealthLeader(ctx context.Context) (web.HealthResults, error) {
+	cfg := s.globalConfig.HealthChecks
+	if !cfg.Leader {
+		return nil, nil
+	}
+
+	if cfg.Interval <= 0 || cfg.LockTTL <= 0 {
+		return nil, fmt.Errorf("the health checks interval and lock ttl must be positive")
+	}
+	if cfg.Interval >= cfg.LockTTL {
+		return nil, fmt.Errorf("the health checks interval %s must be shorter than the lock ttl %s", cfg.Interval, cfg.LockTTL)
+	}
+
+	option, err := redis.ParseURL(cfg.RedisDSN)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid health checks redis DSN")
+	}
+	client := redis.NewClient(option)
+
+	results := web.NewRedisHealthResults(client, cfg.Prefix)
+	leader := web.NewHealthLeader(s.currentConfigurations, lock.NewRedisLocker(client, cfg.Prefix+":lock:"), results, cfg.Interval, cfg.LockTTL)
+	go leader.Run(ctx)
+
+	return results, nil
+}
+
 func (s *Server) listenProviders(stop chan struct{}) {
 	for {
 		select {
@@ -231,43 +603,188 @@ func (s *Server) listenProviders(stop chan struct{}) {
 				continue
 			}
 
+			changed := webhook.ChangedDefinitions(s.currentConfigurations.Definitions, configMsg.Configurations.Definitions)
 			s.currentConfigurations.Definitions = configMsg.Configurations.Definitions
 			s.handleEvent(configMsg.Configurations)
+			s.notifier.Notify(webhook.NewEvent(webhook.ReloadedEvent, changed...))
 		}
 	}
 }
 
 func (s *Server) listenAndServe(handler http.Handler) error {
-	address := fmt.Sprintf(":%v", s.globalConfig.Port)
-	logger := log.WithField("address", address)
-	s.server = &http.Server{
-		Addr:         address,
-		Handler:      handler,
-		ReadTimeout:  s.globalConfig.RespondingTimeouts.ReadTimeout,
-		WriteTimeout: s.globalConfig.RespondingTimeouts.WriteTimeout,
-		IdleTimeout:  s.globalConfig.RespondingTimeouts.IdleTimeout,
-	}
-	listener, err := net.Listen("tcp", address)
+	address := s.globalConfig.Listen
+	if address == "" {
+		address = fmt.Sprintf(":%v", s.globalConfig.Port)
+	}
+	s.server = s.newHTTPServer(address, handler)
+	s.server.ConnState = s.trackConn
+
+	if s.globalConfig.TLS.ACME.Enabled || s.globalConfig.TLS.HasCertificates() {
+		return s.serveTLS(address, handler)
+	}
+
+	ln, err := s.listen(address, s.globalConfig.ProxyProtocol.HTTP)
 	if err != nil {
 		return errors.Wrap(err, "error opening listener")
 	}
 
-	if s.globalConfig.TLS.IsHTTPS() {
-		s.server.Addr = fmt.Sprintf(":%v", s.globalConfig.TLS.Port)
+	if s.globalConfig.H2C {
+		if s.h2c, err = serveH2C(s.server); err != nil {
+			return err
+		}
+		s.server.Handler = s.h2cHandler(s.h2c, s.server.Handler)
+	}
+
+	log.WithField("address", address).Info("Certificate and certificate key were not found, defaulting to HTTP")
+	return s.server.Serve(ln)
+}
+
+// listen opens the listener of the address, reading the PROXY protocol header when it is enabled.
+// The unix socket file is removed when the listener is closed on shutdown.
+func (s *Server) listen(address string, proxyProtocol bool) (net.Listener, error) {
+	var trusted []*net.IPNet
+	if proxyProtocol {
+		if len(s.globalConfig.ProxyProtocol.TrustedCIDRs) == 0 {
+			return nil, errors.New(http.StatusInternalServerError, "PROXY protocol trusted CIDRs are not set")
+		}
 
-		if s.globalConfig.TLS.Redirect {
-			go func() {
-				logger.Info("Listening HTTP redirects to HTTPS")
-				log.Fatal(http.Serve(listener, web.RedirectHTTPS(s.globalConfig.TLS.Port)))
-			}()
+		var err error
+		if trusted, err = listener.ParseCIDRs(s.globalConfig.ProxyProtocol.TrustedCIDRs); err != nil {
+			return nil, err
 		}
+	}
 
-		logger.Info("Listening HTTPS")
-		return s.server.ServeTLS(listener, s.globalConfig.TLS.CertFile, s.globalConfig.TLS.KeyFile)
+	ln, err := listener.New(address, s.globalConfig.SocketMode)
+	if err != nil || !proxyProtocol {
+		return ln, err
 	}
 
-	logger.Info("Certificate and certificate key were not found, defaulting to HTTP")
-	return s.server.Serve(listener)
+	log.WithFields(log.Fields{"address": address, "trusted": s.globalConfig.ProxyProtocol.TrustedCIDRs}).
+		Info("Reading PROXY protocol header")
+	return listener.NewProxyProtocol(ln, trusted, s.globalConfig.ProxyProtocol.HeaderTimeout), nil
+}
+
+func (s *Server) newHTTPServer(address string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              address,
+		Handler:           limitRequestHeaders(handler, s.globalConfig.RequestHeaders),
+		MaxHeaderBytes:    s.globalConfig.RequestHeaders.MaxSize,
+		ReadTimeout:       s.globalConfig.RespondingTimeouts.ReadTimeout,
+		ReadHeaderTimeout: s.globalConfig.RespondingTimeouts.ReadHeaderTimeout,
+		WriteTimeout:      s.globalConfig.RespondingTimeouts.WriteTimeout,
+		IdleTimeout:       s.globalConfig.RespondingTimeouts.IdleTimeout,
+	}
+}
+
+// serveH2C serves HTTP/2 without TLS besides HTTP/1.1, the clients with prior knowledge of HTTP/2 such as
+// the gRPC clients connect with it. The returned HTTP/2 server sends a GOAWAY frame to its connections when
+// the server shuts down, the handlers of the server are wrapped with h2cHandler.
+func serveH2C(server *http.Server) (*http2.Server, error) {
+	h2s := &http2.Server{IdleTimeout: server.IdleTimeout}
+	if err := http2.ConfigureServer(server, h2s); err != nil {
+		return nil, errors.Wrap(err, "could not configure HTTP/2")
+	}
+
+	return h2s, nil
+}
+
+// h2cHandler serves the HTTP/2 connections without TLS with the handler when h2s is set, the connections are
+// hijacked from the server, so they are tracked until they are closed
+func (s *Server) h2cHandler(h2s *http2.Server, handler http.Handler) http.Handler {
+	if h2s == nil {
+		return handler
+	}
+
+	h2cHandler := h2c.NewHandler(handler, h2s)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h2cHandler.ServeHTTP(s.trackHijacked(w), r)
+	})
+}
+
+// serveTLS serves HTTPS on the TLS port with the certificates selected by the server name, the
+// certificate files or the certificates obtained from the ACME certificate authority. The HTTP address redirects to HTTPS when the redirect is
+// enabled and answers the ACME HTTP-01 challenges.
+func (s *Server) serveTLS(httpAddress string, handler http.Handler) error {
+	cfg := s.globalConfig.TLS
+
+	clientAuth, err := clientAuthType(cfg.ClientAuth)
+	if err != nil {
+		return err
+	}
+
+	var httpHandler http.Handler
+	if cfg.Redirect {
+		httpHandler = web.RedirectHTTPS(cfg.GetRedirectPort(), cfg.RedirectStatus)
+	}
+
+	if cfg.ACME.Enabled {
+		manager, err := newCertManager(cfg.ACME, s.provider)
+		if err != nil {
+			return errors.Wrap(err, "could not create the ACME certificate manager")
+		}
+
+		if httpHandler == nil {
+			httpHandler = http.HandlerFunc(errors.NotFound)
+		}
+		httpHandler = manager.HTTPHandler(httpHandler)
+		s.server.TLSConfig = manager.TLSConfig()
+
+		// the certificates selected by the server name are served for their hosts, the certificates
+		// of the others are obtained from the ACME certificate authority
+		if len(cfg.Certificates) > 0 {
+			certs, err := newCertificates(config.TLS{Certificates: cfg.Certificates})
+			if err != nil {
+				return errors.Wrap(err, "could not load the TLS certificates")
+			}
+			go certs.watch(s.serveCtx, cfg.CertificatesReloadInterval)
+
+			getACMECertificate := s.server.TLSConfig.GetCertificate
+			s.server.TLSConfig.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+				if cert := certs.match(hello.ServerName); cert != nil && !isACMEChallenge(hello) {
+					return cert, nil
+				}
+				return getACMECertificate(hello)
+			}
+		}
+	} else {
+		certs, err := newCertificates(cfg)
+		if err != nil {
+			return errors.Wrap(err, "could not load the TLS certificates")
+		}
+		go certs.watch(s.serveCtx, cfg.CertificatesReloadInterval)
+
+		s.server.TLSConfig = &tls.Config{GetCertificate: certs.GetCertificate}
+	}
+	s.server.TLSConfig.ClientAuth = clientAuth
+
+	if httpHandler != nil {
+		ln, err := s.listen(httpAddress, s.globalConfig.ProxyProtocol.HTTP)
+		if err != nil {
+			return errors.Wrap(err, "error opening listener")
+		}
+
+		s.httpServer = s.newHTTPServer(httpAddress, httpHandler)
+		go func() {
+			log.WithField("address", httpAddress).Info("Listening HTTP")
+			if err := s.httpServer.Serve(ln); err != http.ErrServerClosed {
+				log.WithError(err).Fatal("Could not serve HTTP")
+			}
+		}()
+	}
+
+	s.server.Addr = fmt.Sprintf(":%v", cfg.Port)
+	ln, err := s.listen(s.server.Addr, s.globalConfig.ProxyProtocol.HTTPS)
+	if err != nil {
+		return errors.Wrap(err, "error opening TLS listener")
+	}
+
+	logger := log.WithField("address", s.server.Addr)
+	if cfg.ACME.Enabled {
+		logger = logger.WithField("hosts", cfg.ACME.Hosts)
+	}
+	logger.Info("Listening HTTPS")
+	// the certificates are returned by the TLS config, so they are reloaded without a restart
+	return s.server.ServeTLS(ln, "", "")
 }
 
 func (s *Server) createRouter() router.Router {
@@ -275,12 +792,41 @@ func (s *Server) createRouter() router.Router {
 	router.DefaultOptions.NotFoundHandler = errors.NotFound
 	r := router.NewChiRouterWithOptions(router.DefaultOptions)
 
+	// the errors of all the middleware and plugins are rendered as problem details
+	if s.globalConfig.ProblemDetails {
+		r.Use(errors.ProblemDetails)
+	}
+	if s.errorTemplates != nil {
+		r.Use(s.errorTemplates.Handler)
+	}
+
 	// Add RequestID middleware first if enabled, so we could use it in other middlewares, e.g. logger
 	if s.globalConfig.RequestID {
-		r.Use(middleware.RequestID)
+		r.Use(s.correlationID.Handler)
+	}
+
+	// the baggage is cleared before the plugins set it
+	if s.baggage != nil {
+		r.Use(s.baggage.Handler)
+	}
+
+	// the client address is resolved before the logs and the plugins use it
+	if s.clientIP != nil {
+		r.Use(s.clientIP.Handler)
+	}
+
+	// the requests matching an API are logged by the API routes, with the access log settings of the API
+	if s.globalConfig.AccessLog.Enabled {
+		r.Use(s.accessLog.Handler)
+	}
+
+	// the new requests are rejected while draining, before the plugins and the upstreams see them
+	if s.drainer != nil {
+		r.Use(s.drainer.Handler)
 	}
 
 	r.Use(
+		middleware.NewSlowLog(s.globalConfig.SlowLog.Threshold).Handler,
 		middleware.NewStats(s.statsClient).Handler,
 		middleware.NewLogger().Handler,
 		middleware.NewRecovery(errors.RecoveryHandler),
@@ -294,26 +840,30 @@ func (s *Server) createRouter() router.Router {
 	return r
 }
 
+// updateConfigurations applies the change to a copy of the current definitions, so the slices of the
+// definitions read by the admin API are never modified
 func (s *Server) updateConfigurations(cfg api.ConfigurationMessage) {
-	currentDefinitions := s.currentConfigurations.Definitions
+	currentDefinitions := make([]*api.Definition, 0, len(s.currentConfigurations.Definitions)+1)
 
 	switch cfg.Operation {
 	case api.AddedOperation:
+		currentDefinitions = append(currentDefinitions, s.currentConfigurations.Definitions...)
 		currentDefinitions = append(currentDefinitions, cfg.Configuration)
 	case api.UpdatedOperation:
-		for i, d := range currentDefinitions {
+		for _, d := range s.currentConfigurations.Definitions {
 			if d.Name == cfg.Configuration.Name {
-				currentDefinitions[i] = cfg.Configuration
+				d = cfg.Configuration
 			}
+			currentDefinitions = append(currentDefinitions, d)
 		}
 	case api.RemovedOperation:
-		for i, d := range currentDefinitions {
-			if d.Name == cfg.Configuration.Name {
-				copy(currentDefinitions[i:], currentDefinitions[i+1:])
-				// currentDefinitions[len(currentDefinitions)-1] = nil // or the zero value of T
-				currentDefinitions = currentDefinitions[:len(currentDefinitions)-1]
+		for _, d := range s.currentConfigurations.Definitions {
+			if d.Name != cfg.Configuration.Name {
+				currentDefinitions = append(currentDefinitions, d)
 			}
 		}
+	default:
+		return
 	}
 
 	s.currentConfigurations.Definitions = currentDefinitions
@@ -328,6 +878,9 @@ func (s *Server) handleEvent(cfg *api.Configuration) {
 
 	plugin.EmitEvent(plugin.ReloadEvent, plugin.OnReload{Configurations: cfg.Definitions})
 
-	s.server.Handler = newRouter
+	s.server.Handler = s.h2cHandler(s.h2c, limitRequestHeaders(s.withServeContext(newRouter), s.globalConfig.RequestHeaders))
+	for _, l := range s.listeners {
+		l.server.Handler = s.h2cHandler(l.h2c, s.listenerHandler(l.config.Name, newRouter))
+	}
 	log.Debug("Configuration refresh done")
 }
@@ -0,0 +1,291 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hellofresh/janus/pkg/api"
+	"github.com/hellofresh/janus/pkg/config"
+	"github.com/hellofresh/janus/pkg/drain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startTestServer(t *testing.T, gracePeriod time.Duration, handler http.HandlerFunc) (*Server, string) {
+	s := New(WithGlobalConfig(&config.Specification{ShutdownGracePeriod: gracePeriod}))
+	s.server = &http.Server{Handler: s.withServeContext(handler), ConnState: s.trackConn}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go s.server.Serve(listener)
+
+	return s, "http://" + listener.Addr().String()
+}
+
+func TestShutdownWaitsForInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	s, url := startTestServer(t, time.Second, func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte("done"))
+	})
+
+	result := make(chan string)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			result <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		result <- string(body)
+	}()
+
+	<-started
+	assert.NoError(t, s.Shutdown())
+	assert.Equal(t, "done", <-result)
+
+	_, err := http.Get(url)
+	assert.Error(t, err, "new connections are not accepted")
+}
+
+func TestShutdownClosesStreamsAtDeadline(t *testing.T) {
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	s, url := startTestServer(t, 200*time.Millisecond, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		close(started)
+		<-r.Context().Done()
+		close(cancelled)
+	})
+
+	go func() {
+		if resp, err := http.Get(url); err == nil {
+			ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+	}()
+
+	<-started
+	assert.Equal(t, ErrShutdownTimeout, s.Shutdown())
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("stream was not closed at the deadline")
+	}
+}
+
+func TestShutdownAfterWebSocketClosed(t *testing.T) {
+	s, url := startTestServer(t, time.Second, func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		rw.Flush()
+		ioutil.ReadAll(rw)
+	})
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	require.NoError(t, err)
+	conn.Write([]byte("GET / HTTP/1.1\r\nHost: example.com\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n"))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	conn.Close()
+
+	require.Eventually(t, func() bool {
+		s.connsMu.Lock()
+		defer s.connsMu.Unlock()
+		return len(s.conns) == 0
+	}, time.Second, 10*time.Millisecond, "the closed hijacked connections are forgotten")
+
+	start := time.Now()
+	assert.NoError(t, s.Shutdown())
+	assert.True(t, time.Since(start) < 500*time.Millisecond, "the shutdown does not wait for the closed connections")
+}
+
+func TestCloseConnsReportingTheirClose(t *testing.T) {
+	s := New(WithGlobalConfig(&config.Specification{}))
+
+	server, client := net.Pipe()
+	defer client.Close()
+	s.trackConn(&hijackedConn{Conn: server, s: s}, http.StateActive)
+
+	closed := make(chan struct{})
+	go func() {
+		s.closeConns()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("closing the connections reporting their close deadlocked")
+	}
+	assert.Empty(t, s.conns)
+}
+
+func TestListenProxyProtocol(t *testing.T) {
+	s := New(WithGlobalConfig(&config.Specification{
+		ProxyProtocol: config.ProxyProtocol{HTTP: true, TrustedCIDRs: []string{"127.0.0.1"}},
+	}))
+
+	ln, err := s.listen("127.0.0.1:0", true)
+	require.NoError(t, err)
+	defer ln.Close()
+
+	go http.Serve(ln, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.RemoteAddr))
+	}))
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	conn.Write([]byte("PROXY TCP4 203.0.113.7 10.0.0.1 56324 80\r\nGET / HTTP/1.1\r\nHost: example.com\r\nConnection: close\r\n\r\n"))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal(t, "203.0.113.7:56324", string(body))
+}
+
+func TestListenProxyProtocolWithoutTrustedCIDRs(t *testing.T) {
+	s := New(WithGlobalConfig(&config.Specification{}))
+
+	_, err := s.listen("127.0.0.1:0", true)
+	assert.Error(t, err)
+}
+
+func TestRequestCancelledOnClientDisconnect(t *testing.T) {
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	_, url := startTestServer(t, time.Second, func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+		close(cancelled)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	go http.DefaultClient.Do(req.WithContext(ctx))
+
+	<-started
+	cancel()
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("request context was not cancelled on client disconnect")
+	}
+}
+
+func TestDrain(t *testing.T) {
+	streamStarted := make(chan struct{})
+	streamClosed := make(chan struct{})
+	requestStarted := make(chan struct{})
+	var s *Server
+	s, url := startTestServer(t, time.Second, func(w http.ResponseWriter, r *http.Request) {
+		s.drainer.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/stream":
+				w.WriteHeader(http.StatusOK)
+				w.(http.Flusher).Flush()
+				close(streamStarted)
+				<-r.Context().Done()
+				close(streamClosed)
+			case "/slow":
+				close(requestStarted)
+				time.Sleep(100 * time.Millisecond)
+				w.Write([]byte("done"))
+			default:
+				w.Write([]byte("ok"))
+			}
+		})).ServeHTTP(w, r)
+	})
+	s.drainer = drain.NewDrainer(300*time.Millisecond, drainConns{s})
+
+	go func() {
+		if resp, err := http.Get(url + "/stream"); err == nil {
+			ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+	}()
+	<-streamStarted
+
+	result := make(chan string)
+	go func() {
+		resp, err := http.Get(url + "/slow")
+		if err != nil {
+			result <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		result <- string(body)
+	}()
+	<-requestStarted
+
+	s.drainer.Drain()
+
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode, "the new requests are rejected")
+	assert.True(t, resp.Close, "the connections of the new requests are closed")
+
+	assert.Equal(t, "done", <-result, "the in-flight requests finish")
+
+	select {
+	case <-streamClosed:
+	case <-time.After(time.Second):
+		t.Fatal("stream was not closed after the drain grace period")
+	}
+
+	s.drainer.Undrain()
+	resp, err = http.Get(url)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestUpdateConfigurationsReplace(t *testing.T) {
+	definition := func(name string) *api.Definition {
+		d := api.NewDefinition()
+		d.Name = name
+		return d
+	}
+	existing := []*api.Definition{definition("one"), definition("two"), definition("three")}
+
+	s := New()
+	s.currentConfigurations = &api.Configuration{Definitions: existing}
+	snapshot := s.currentConfigurations.Definitions
+
+	for _, d := range existing {
+		s.updateConfigurations(api.ConfigurationMessage{Operation: api.RemovedOperation, Configuration: d})
+	}
+	for _, name := range []string{"four", "five", "two"} {
+		s.updateConfigurations(api.ConfigurationMessage{Operation: api.AddedOperation, Configuration: definition(name)})
+	}
+
+	var names []string
+	for _, d := range s.currentConfigurations.Definitions {
+		names = append(names, d.Name)
+	}
+	assert.Equal(t, []string{"four", "five", "two"}, names)
+	assert.Equal(t, []*api.Definition{existing[0], existing[1], existing[2]}, snapshot, "the definitions read before are not modified")
+}
@@ -0,0 +1,3 @@
+// Package upstream provides the upstream targets of the APIs adjusted at runtime, e.g. the weights
+// shifted during a canary rollout, without reloading the routes, and their runtime state.
+package upstream
@@ -0,0 +1,152 @@
+package upstream
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/hellofresh/janus/pkg/api"
+	obs "github.com/hellofresh/janus/pkg/observability"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// Health statuses of the upstreams, unknown until the health check of the API ran
+const (
+	HealthUnknown = "unknown"
+	HealthUp      = "up"
+	HealthDown    = "down"
+)
+
+// Circuit breaker states, their gauge values are ordered by severity
+const (
+	CircuitClosed   = "closed"
+	CircuitHalfOpen = "half_open"
+	CircuitOpen     = "open"
+)
+
+var circuitValues = map[string]int64{CircuitClosed: 0, CircuitHalfOpen: 1, CircuitOpen: 2}
+
+// TargetState is the runtime state of an upstream target of an API. The health check and the circuit
+// breaker protect the API as a whole, so the targets of an API share their health and circuit state.
+type TargetState struct {
+	Route    string `json:"route"`
+	Target   string `json:"target"`
+	Health   string `json:"health"`
+	Circuit  string `json:"circuit,omitempty"`
+	InFlight int64  `json:"in_flight"`
+}
+
+type targetKey struct {
+	route  string
+	target string
+}
+
+// States holds the runtime state of the upstream targets of the APIs by name, and records it as gauges
+// labeled by API and target, so the cardinality is bounded by the targets and not by the requests
+type States struct {
+	sync.RWMutex
+	health   map[string]string
+	circuits map[string]string
+	inFlight map[targetKey]*int64
+}
+
+// NewStates creates a new instance of States
+func NewStates() *States {
+	return &States{
+		health:   make(map[string]string),
+		circuits: make(map[string]string),
+		inFlight: make(map[targetKey]*int64),
+	}
+}
+
+// SetHealth sets the result of the last health check of the API
+func (s *States) SetHealth(name string, up bool) {
+	health, value := HealthDown, int64(0)
+	if up {
+		health, value = HealthUp, 1
+	}
+
+	s.Lock()
+	s.health[name] = health
+	s.Unlock()
+
+	ctx, _ := tag.New(context.Background(), tag.Upsert(obs.KeyAPIName, name))
+	stats.Record(ctx, obs.MUpstreamHealth.M(value))
+}
+
+// SetCircuit sets the circuit breaker state of the API of the request context
+func (s *States) SetCircuit(ctx context.Context, state string) {
+	name := apiName(ctx)
+
+	s.Lock()
+	s.circuits[name] = state
+	s.Unlock()
+
+	stats.Record(ctx, obs.MCircuitState.M(circuitValues[state]))
+}
+
+// Acquire counts the request of the context as in flight to the target of its API, until the returned
+// function is called
+func (s *States) Acquire(ctx context.Context, target string) func() {
+	key := targetKey{route: apiName(ctx), target: target}
+
+	s.Lock()
+	counter, ok := s.inFlight[key]
+	if !ok {
+		counter = new(int64)
+		s.inFlight[key] = counter
+	}
+	s.Unlock()
+
+	ctx, _ = tag.New(ctx, tag.Upsert(obs.KeyUpstreamTarget, target))
+	stats.Record(ctx, obs.MUpstreamInFlight.M(atomic.AddInt64(counter, 1)))
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			stats.Record(ctx, obs.MUpstreamInFlight.M(atomic.AddInt64(counter, -1)))
+		})
+	}
+}
+
+// List returns the states of the upstream targets of the API definitions, sorted by API and target
+func (s *States) List(defs []*api.Definition) []TargetState {
+	s.RLock()
+	defer s.RUnlock()
+
+	states := make([]TargetState, 0, len(defs))
+	for _, def := range defs {
+		if def.Proxy == nil || def.Proxy.Upstreams == nil {
+			continue
+		}
+
+		health, ok := s.health[def.Name]
+		if !ok {
+			health = HealthUnknown
+		}
+
+		for _, target := range def.Proxy.Upstreams.Targets {
+			state := TargetState{Route: def.Name, Target: target.Target, Health: health, Circuit: s.circuits[def.Name]}
+			if counter, ok := s.inFlight[targetKey{route: def.Name, target: target.Target}]; ok {
+				state.InFlight = atomic.LoadInt64(counter)
+			}
+			states = append(states, state)
+		}
+	}
+
+	sort.Slice(states, func(i, j int) bool {
+		if states[i].Route != states[j].Route {
+			return states[i].Route < states[j].Route
+		}
+		return states[i].Target < states[j].Target
+	})
+
+	return states
+}
+
+func apiName(ctx context.Context) string {
+	name, _ := tag.FromContext(ctx).Value(obs.KeyAPIName)
+	return name
+}
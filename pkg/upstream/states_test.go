@@ -0,0 +1,83 @@
+package upstream
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hellofresh/janus/pkg/api"
+	obs "github.com/hellofresh/janus/pkg/observability"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+func newStatesDefinition(name string, targets ...string) *api.Definition {
+	def := api.NewDefinition()
+	def.Name = name
+	for _, target := range targets {
+		def.Proxy.Upstreams.Targets = append(def.Proxy.Upstreams.Targets, &proxy.Target{Target: target})
+	}
+
+	return def
+}
+
+func apiContext(name string) context.Context {
+	ctx, _ := tag.New(context.Background(), tag.Upsert(obs.KeyAPIName, name))
+	return ctx
+}
+
+func TestStatesList(t *testing.T) {
+	states := NewStates()
+	defs := []*api.Definition{
+		newStatesDefinition("users", "http://users-2", "http://users-1"),
+		newStatesDefinition("orders", "http://orders"),
+	}
+
+	release := states.Acquire(apiContext("users"), "http://users-1")
+	states.Acquire(apiContext("users"), "http://users-1")
+	release()
+	release()
+	states.SetHealth("users", false)
+	states.SetCircuit(apiContext("users"), CircuitOpen)
+
+	assert.Equal(t, []TargetState{
+		{Route: "orders", Target: "http://orders", Health: HealthUnknown},
+		{Route: "users", Target: "http://users-1", Health: HealthDown, Circuit: CircuitOpen, InFlight: 1},
+		{Route: "users", Target: "http://users-2", Health: HealthDown, Circuit: CircuitOpen},
+	}, states.List(defs), "the release is counted once")
+}
+
+func TestStatesGauges(t *testing.T) {
+	inFlightView := &view.View{Name: "test_upstream_requests_in_flight", Measure: obs.MUpstreamInFlight, TagKeys: []tag.Key{obs.KeyAPIName, obs.KeyUpstreamTarget}, Aggregation: view.LastValue()}
+	healthView := &view.View{Name: "test_upstream_health", Measure: obs.MUpstreamHealth, TagKeys: []tag.Key{obs.KeyAPIName}, Aggregation: view.LastValue()}
+	require.NoError(t, view.Register(inFlightView, healthView))
+	defer view.Unregister(inFlightView, healthView)
+
+	states := NewStates()
+	for i := 0; i < 3; i++ {
+		states.Acquire(apiContext("users"), "http://users-1")
+	}
+	states.Acquire(apiContext("users"), "http://users-2")()
+	states.SetHealth("users", true)
+
+	rows, err := view.RetrieveData(inFlightView.Name)
+	require.NoError(t, err)
+	require.Len(t, rows, 2, "the gauges are labeled by target")
+
+	inFlight := make(map[string]float64)
+	for _, row := range rows {
+		for _, tg := range row.Tags {
+			if tg.Key == obs.KeyUpstreamTarget {
+				inFlight[tg.Value] = row.Data.(*view.LastValueData).Value
+			}
+		}
+	}
+	assert.Equal(t, map[string]float64{"http://users-1": 3, "http://users-2": 0}, inFlight)
+
+	rows, err = view.RetrieveData(healthView.Name)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, float64(1), rows[0].Data.(*view.LastValueData).Value)
+}
@@ -0,0 +1,204 @@
+package upstream
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hellofresh/janus/pkg/errors"
+	"github.com/hellofresh/janus/pkg/proxy"
+)
+
+var (
+	// ErrNegativeWeight is thrown when a target weight is negative
+	ErrNegativeWeight = errors.New(http.StatusBadRequest, "target weights can not be negative")
+	// ErrNoPositiveWeight is thrown when no target would receive requests
+	ErrNoPositiveWeight = errors.New(http.StatusBadRequest, "at least one target must have a positive weight")
+	// ErrUnknownTarget is thrown when the weight of a target that is not an upstream of the API is set
+	ErrUnknownTarget = errors.New(http.StatusBadRequest, "target is not an upstream of the API")
+)
+
+// slowStartScale multiplies the weights of the targets of an API while one of them warms up, so the
+// ramped weights keep their precision
+const slowStartScale = 100
+
+// Weights holds the upstream targets of the APIs by name. They are read on every request, so the
+// weight changes take effect without reloading the routes. The targets of an API are replaced as a
+// whole and never modified, so a request sees either the old or the new weights.
+//
+// The targets joining an API with a slow start, i.e. added to its upstreams or put back in rotation
+// with a positive weight, warm up: their weight is ramped up linearly from near zero to the full weight
+// for the slow start duration of the API.
+type Weights struct {
+	sync.RWMutex
+	targets   map[string]proxy.Targets
+	slowStart map[string]time.Duration
+	joined    map[string]map[string]time.Time
+	now       func() time.Time
+}
+
+// NewWeights creates a new instance of Weights
+func NewWeights() *Weights {
+	return &Weights{
+		targets:   make(map[string]proxy.Targets),
+		slowStart: make(map[string]time.Duration),
+		joined:    make(map[string]map[string]time.Time),
+		now:       time.Now,
+	}
+}
+
+// Validate validates the target weights
+func Validate(targets proxy.Targets) error {
+	positive := false
+	for _, target := range targets {
+		if target.Weight < 0 {
+			return ErrNegativeWeight
+		}
+		if target.Weight > 0 {
+			positive = true
+		}
+	}
+
+	if !positive {
+		return ErrNoPositiveWeight
+	}
+
+	return nil
+}
+
+// Apply returns a copy of the targets with the weights of the changes, the other targets keep their
+// weight. The changed targets must be upstreams of the API.
+func Apply(targets proxy.Targets, changes proxy.Targets) (proxy.Targets, error) {
+	weights := make(map[string]int, len(changes))
+	for _, change := range changes {
+		weights[change.Target] = change.Weight
+	}
+
+	updated := make(proxy.Targets, 0, len(targets))
+	for _, target := range targets {
+		weight, ok := weights[target.Target]
+		if !ok {
+			weight = target.Weight
+		}
+		delete(weights, target.Target)
+
+		updated = append(updated, &proxy.Target{Target: target.Target, Weight: weight})
+	}
+
+	if len(weights) > 0 {
+		return nil, ErrUnknownTarget
+	}
+
+	return updated, Validate(updated)
+}
+
+// SetSlowStart sets the time the targets joining the API warm up for, zero disables the slow start
+func (w *Weights) SetSlowStart(name string, duration time.Duration) {
+	w.Lock()
+	defer w.Unlock()
+
+	if duration > 0 {
+		w.slowStart[name] = duration
+	} else {
+		delete(w.slowStart, name)
+		delete(w.joined, name)
+	}
+}
+
+// Set sets the upstream targets of the API, the targets with a positive weight that were not receiving
+// requests before start warming up when the API has a slow start. The targets of a new API do not warm
+// up, there are no targets to take over their requests.
+func (w *Weights) Set(name string, targets proxy.Targets) {
+	w.Lock()
+	defer w.Unlock()
+
+	previous, ok := w.targets[name]
+	w.targets[name] = targets
+
+	duration, slowStart := w.slowStart[name]
+	if !ok || !slowStart {
+		return
+	}
+
+	weights := make(map[string]int, len(previous))
+	for _, target := range previous {
+		weights[target.Target] = target.Weight
+	}
+
+	now := w.now()
+	joined := make(map[string]time.Time)
+	for _, target := range targets {
+		if target.Weight <= 0 {
+			continue
+		}
+
+		if weight, ok := weights[target.Target]; !ok || weight <= 0 {
+			joined[target.Target] = now
+		} else if since, ok := w.joined[name][target.Target]; ok && now.Sub(since) < duration {
+			joined[target.Target] = since
+		}
+	}
+	w.joined[name] = joined
+}
+
+// Get returns the upstream targets of the API
+func (w *Weights) Get(name string) proxy.Targets {
+	w.RLock()
+	defer w.RUnlock()
+
+	return w.targets[name]
+}
+
+// effective returns the upstream targets of the API the requests are balanced to. The weights of the
+// targets warming up are ramped up with the time elapsed since they joined the API, the weights of the
+// other targets are scaled by slowStartScale meanwhile.
+func (w *Weights) effective(name string) proxy.Targets {
+	w.RLock()
+	defer w.RUnlock()
+
+	targets := w.targets[name]
+	joined := w.joined[name]
+	if len(joined) == 0 {
+		return targets
+	}
+
+	duration := w.slowStart[name]
+	now := w.now()
+	warming := false
+	ramped := make(proxy.Targets, 0, len(targets))
+	for _, target := range targets {
+		weight := target.Weight * slowStartScale
+		if since, ok := joined[target.Target]; ok {
+			if elapsed := now.Sub(since); elapsed < duration {
+				warming = true
+				weight = int(float64(weight) * float64(elapsed) / float64(duration))
+				if weight < 1 {
+					weight = 1
+				}
+			}
+		}
+		ramped = append(ramped, &proxy.Target{Target: target.Target, Weight: weight})
+	}
+
+	if !warming {
+		return targets
+	}
+	return ramped
+}
+
+// Handler creates the middleware routing the requests of the API to its current upstream targets, with
+// the ramped weights of the targets warming up. The targets already chosen by a plugin, e.g. the variant
+// of an A/B test, are kept.
+func (w *Weights) Handler(name string) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if _, ok := proxy.UpstreamTargetsFromContext(r.Context()); !ok {
+				if targets := w.effective(name); len(targets) > 0 {
+					r = r.WithContext(proxy.WithUpstreamTargets(r.Context(), targets))
+				}
+			}
+
+			handler.ServeHTTP(rw, r)
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package upstream
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTargets() proxy.Targets {
+	return proxy.Targets{
+		{Target: "http://stable", Weight: 90},
+		{Target: "http://canary", Weight: 10},
+	}
+}
+
+func TestApply(t *testing.T) {
+	targets := newTargets()
+
+	updated, err := Apply(targets, proxy.Targets{{Target: "http://canary", Weight: 50}})
+	require.NoError(t, err)
+	assert.Equal(t, proxy.Targets{{Target: "http://stable", Weight: 90}, {Target: "http://canary", Weight: 50}}, updated)
+	assert.Equal(t, 10, targets[1].Weight, "the targets are copied")
+
+	_, err = Apply(targets, proxy.Targets{{Target: "http://stable", Weight: 0}})
+	assert.NoError(t, err, "a single target can receive all the requests")
+
+	_, err = Apply(targets, proxy.Targets{{Target: "http://canary", Weight: -1}})
+	assert.Equal(t, ErrNegativeWeight, err)
+
+	_, err = Apply(targets, proxy.Targets{{Target: "http://stable", Weight: 0}, {Target: "http://canary", Weight: 0}})
+	assert.Equal(t, ErrNoPositiveWeight, err)
+
+	_, err = Apply(targets, proxy.Targets{{Target: "http://unknown", Weight: 1}})
+	assert.Equal(t, ErrUnknownTarget, err)
+}
+
+func TestHandler(t *testing.T) {
+	weights := NewWeights()
+	weights.Set("example", newTargets())
+
+	var targets proxy.Targets
+	handler := weights.Handler("example")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		targets, _ = proxy.UpstreamTargetsFromContext(r.Context())
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, newTargets(), targets)
+
+	updated, _ := Apply(weights.Get("example"), proxy.Targets{{Target: "http://canary", Weight: 100}})
+	weights.Set("example", updated)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, 100, targets[1].Weight, "the weights take effect on the next request")
+
+	variant := proxy.Targets{{Target: "http://variant", Weight: 1}}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), r.WithContext(proxy.WithUpstreamTargets(r.Context(), variant)))
+	assert.Equal(t, variant, targets, "the targets chosen by a plugin are kept")
+}
+
+func TestSlowStart(t *testing.T) {
+	now := time.Now()
+	weights := NewWeights()
+	weights.now = func() time.Time { return now }
+	weights.SetSlowStart("example", 10*time.Second)
+	weights.Set("example", proxy.Targets{{Target: "http://stable", Weight: 90}})
+	assert.Equal(t, proxy.Targets{{Target: "http://stable", Weight: 90}}, weights.effective("example"),
+		"the targets of a new API do not warm up")
+
+	weights.Set("example", newTargets())
+	assert.Equal(t, proxy.Targets{{Target: "http://stable", Weight: 9000}, {Target: "http://canary", Weight: 1}},
+		weights.effective("example"), "the added target starts from near zero")
+
+	for _, step := range []struct {
+		elapsed time.Duration
+		weight  int
+	}{
+		{2500 * time.Millisecond, 250},
+		{5 * time.Second, 500},
+		{7500 * time.Millisecond, 750},
+	} {
+		now = now.Add(2500 * time.Millisecond)
+		targets := weights.effective("example")
+		assert.Equal(t, 9000, targets[0].Weight)
+		assert.Equal(t, step.weight, targets[1].Weight, "the weight is ramped linearly after %s", step.elapsed)
+	}
+
+	weights.Set("example", newTargets())
+	assert.Equal(t, 750, weights.effective("example")[1].Weight, "the reloaded targets keep warming up")
+
+	now = now.Add(2500 * time.Millisecond)
+	assert.Equal(t, newTargets(), weights.effective("example"), "the target gets its full weight")
+
+	weights.Set("example", proxy.Targets{{Target: "http://stable", Weight: 90}, {Target: "http://canary", Weight: 0}})
+	weights.Set("example", newTargets())
+	assert.Equal(t, 1, weights.effective("example")[1].Weight, "the target put back in rotation warms up")
+
+	weights.SetSlowStart("example", 0)
+	assert.Equal(t, newTargets(), weights.effective("example"))
+}
@@ -1,15 +1,27 @@
 package web
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/ghodss/yaml"
 	"github.com/hellofresh/janus/pkg/api"
+	"github.com/hellofresh/janus/pkg/audit"
 	"github.com/hellofresh/janus/pkg/errors"
+	"github.com/hellofresh/janus/pkg/jwt"
+	"github.com/hellofresh/janus/pkg/maintenance"
 	"github.com/hellofresh/janus/pkg/plugin"
+	"github.com/hellofresh/janus/pkg/proxy"
 	"github.com/hellofresh/janus/pkg/render"
 	"github.com/hellofresh/janus/pkg/router"
+	"github.com/hellofresh/janus/pkg/sampling"
+	"github.com/hellofresh/janus/pkg/upstream"
+	log "github.com/sirupsen/logrus"
 	"go.opencensus.io/trace"
 )
 
@@ -17,6 +29,12 @@ import (
 type APIHandler struct {
 	configurationChan chan<- api.ConfigurationMessage
 	Cfgs              *api.Configuration
+	auditTrail        *audit.Trail
+	maintenance       *maintenance.Modes
+	weights           *upstream.Weights
+	samplingRates     *sampling.Rates
+	listeners         []string
+	rbac              *jwt.RBACMiddleware
 }
 
 // NewAPIHandler creates a new instance of Controller
@@ -42,6 +60,77 @@ func (c *APIHandler) Get() http.HandlerFunc {
 	}
 }
 
+const (
+	importModeMerge   = "merge"
+	importModeReplace = "replace"
+
+	importStatusCreated = "created"
+	importStatusUpdated = "updated"
+	importStatusInvalid = "invalid"
+)
+
+// ImportResult is the import outcome for a single definition
+type ImportResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ImportReport is the outcome of a bulk import
+type ImportReport struct {
+	Mode          string         `json:"mode"`
+	Transactional bool           `json:"transactional"`
+	Applied       bool           `json:"applied"`
+	Results       []ImportResult `json:"results"`
+}
+
+// ValidationReport is the outcome of a dry-run validation
+type ValidationReport struct {
+	Valid   bool           `json:"valid"`
+	Results []ImportResult `json:"results"`
+}
+
+// Failed checks if any of the imported definitions is invalid
+func (r *ImportReport) Failed() bool {
+	for _, result := range r.Results {
+		if result.Status == importStatusInvalid {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Export is the export all definitions handler. Secret plugin configuration values are redacted
+// unless "include_secrets=true" is set by an admin, "format=yaml" renders the document as YAML instead of JSON.
+func (c *APIHandler) Export() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, span := trace.StartSpan(r.Context(), "definitions.Export")
+		defer span.End()
+
+		includeSecrets, _ := strconv.ParseBool(r.URL.Query().Get("include_secrets"))
+		if includeSecrets && c.rbac != nil && !c.rbac.HasRole(r, jwt.RoleAdmin) {
+			errors.Handler(w, errors.New(http.StatusForbidden, "include_secrets requires the admin role"))
+			return
+		}
+
+		export, err := api.NewExport(c.Cfgs.Definitions, !includeSecrets)
+		if err != nil {
+			errors.Handler(w, err)
+			return
+		}
+
+		switch r.URL.Query().Get("format") {
+		case "", "json":
+			render.JSON(w, http.StatusOK, export)
+		case "yaml":
+			render.YAML(w, http.StatusOK, export)
+		default:
+			errors.Handler(w, errors.New(http.StatusBadRequest, "unsupported export format"))
+		}
+	}
+}
+
 // GetBy is the find by handler
 func (c *APIHandler) GetBy() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -74,38 +163,37 @@ func (c *APIHandler) PutBy() http.HandlerFunc {
 			return
 		}
 
-		err = json.NewDecoder(r.Body).Decode(cfg)
+		// the stored definition is decoded in place, so keep its snapshot for the audit diff
+		oldCfg, err := cfg.Redacted()
 		if err != nil {
 			errors.Handler(w, err)
 			return
 		}
 
-		isValid, err := cfg.Validate()
-		if false == isValid && err != nil {
-			errors.Handler(w, errors.New(http.StatusBadRequest, err.Error()))
+		err = json.NewDecoder(r.Body).Decode(cfg)
+		if err != nil {
+			errors.Handler(w, err)
 			return
 		}
 
-		// Additionally validate plugin configuration
-		for _, plg := range cfg.Plugins {
-			isValid, err := plugin.ValidateConfig(plg.Name, plg.Config)
-			if !isValid || err != nil {
-				errors.Handler(w, errors.New(http.StatusBadRequest, err.Error()))
-				return
-			}
+		if err := c.validate(cfg); err != nil {
+			errors.Handler(w, err)
+			return
 		}
 
 		// avoid situation when trying to update existing definition with new path
 		// that is already registered with another name
 		_, span = trace.StartSpan(r.Context(), "repo.FindByListenPath")
-		existingCfg := c.findByListenPath(cfg.Proxy.ListenPath)
+		existingCfg := c.findByListenPath(cfg)
 		span.End()
 
-		if existingCfg != nil && existingCfg.Name != cfg.Name {
+		if existingCfg != nil {
 			errors.Handler(w, api.ErrAPIListenPathExists)
 			return
 		}
 
+		c.recordChange(r, audit.UpdatedOperation, oldCfg, cfg)
+
 		_, span = trace.StartSpan(r.Context(), "repo.Update")
 		c.configurationChan <- api.ConfigurationMessage{
 			Operation:     api.UpdatedOperation,
@@ -117,32 +205,282 @@ func (c *APIHandler) PutBy() http.HandlerFunc {
 	}
 }
 
-// Post is the create handler
-func (c *APIHandler) Post() http.HandlerFunc {
+// GetMaintenanceBy is the maintenance mode find handler
+func (c *APIHandler) GetMaintenanceBy() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		cfg := api.NewDefinition()
+		cfg := c.findByName(router.URLParam(r, "name"))
+		if cfg == nil {
+			errors.Handler(w, api.ErrAPIDefinitionNotFound)
+			return
+		}
 
-		err := json.NewDecoder(r.Body).Decode(cfg)
-		if nil != err {
+		render.JSON(w, http.StatusOK, cfg.Maintenance)
+	}
+}
+
+// PutMaintenanceBy is the maintenance mode update handler, the mode takes effect right away and it is
+// stored in the definition to survive the restarts
+func (c *APIHandler) PutMaintenanceBy() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := router.URLParam(r, "name")
+		cfg := c.findByName(name)
+		if cfg == nil {
+			errors.Handler(w, api.ErrAPIDefinitionNotFound)
+			return
+		}
+
+		var mode api.Maintenance
+		if err := json.NewDecoder(r.Body).Decode(&mode); err != nil {
+			errors.Handler(w, errors.New(http.StatusBadRequest, err.Error()))
+			return
+		}
+
+		if err := maintenance.Validate(mode); err != nil {
+			errors.Handler(w, err)
+			return
+		}
+
+		oldCfg, err := cfg.Redacted()
+		if err != nil {
+			errors.Handler(w, err)
+			return
+		}
+		cfg.Maintenance = mode
+
+		c.recordChange(r, audit.UpdatedOperation, oldCfg, cfg)
+		if c.maintenance != nil {
+			c.maintenance.Set(name, mode)
+		}
+
+		c.configurationChan <- api.ConfigurationMessage{
+			Operation:     api.UpdatedOperation,
+			Configuration: cfg,
+		}
+
+		render.JSON(w, http.StatusOK, mode)
+	}
+}
+
+// GetSamplingBy is the trace sampling rate find handler
+func (c *APIHandler) GetSamplingBy() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := router.URLParam(r, "name")
+		if c.findByName(name) == nil {
+			errors.Handler(w, api.ErrAPIDefinitionNotFound)
+			return
+		}
+
+		render.JSON(w, http.StatusOK, c.samplingRates.Get(name))
+	}
+}
+
+// PutSamplingBy is the trace sampling rate update handler, the rate takes effect right away and it is
+// not stored in the definition, so the API is sampled by the default sampler again once it is reloaded
+func (c *APIHandler) PutSamplingBy() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := router.URLParam(r, "name")
+		if c.findByName(name) == nil {
+			errors.Handler(w, api.ErrAPIDefinitionNotFound)
+			return
+		}
+
+		var rate struct {
+			Rate *float64 `json:"rate"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&rate); err != nil {
+			errors.Handler(w, errors.New(http.StatusBadRequest, err.Error()))
+			return
+		}
+		if rate.Rate == nil {
+			errors.Handler(w, sampling.ErrInvalidRate)
+			return
+		}
+
+		if err := c.samplingRates.Set(name, *rate.Rate); err != nil {
 			errors.Handler(w, err)
 			return
 		}
 
-		isValid, err := cfg.Validate()
-		if false == isValid && err != nil {
+		log.WithFields(log.Fields{"api_name": name, "rate": *rate.Rate}).Info("Trace sampling rate changed")
+		render.JSON(w, http.StatusOK, c.samplingRates.Get(name))
+	}
+}
+
+// DeleteSamplingBy is the trace sampling rate remove handler, the API is sampled by the default sampler
+// right away
+func (c *APIHandler) DeleteSamplingBy() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := router.URLParam(r, "name")
+		if c.findByName(name) == nil {
+			errors.Handler(w, api.ErrAPIDefinitionNotFound)
+			return
+		}
+
+		c.samplingRates.Remove(name)
+		log.WithField("api_name", name).Info("Trace sampling rate reset")
+		render.JSON(w, http.StatusOK, c.samplingRates.Get(name))
+	}
+}
+
+// GetWeightsBy is the upstream target weights find handler
+func (c *APIHandler) GetWeightsBy() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := router.URLParam(r, "name")
+		cfg := c.findByName(name)
+		if cfg == nil {
+			errors.Handler(w, api.ErrAPIDefinitionNotFound)
+			return
+		}
+
+		targets := cfg.Proxy.Upstreams.Targets
+		if c.weights != nil {
+			if current := c.weights.Get(name); current != nil {
+				targets = current
+			}
+		}
+
+		render.JSON(w, http.StatusOK, targets)
+	}
+}
+
+// PutWeightsBy is the upstream target weights update handler. The weights of the listed targets are
+// changed, the routing uses them right away and they are stored in the definition to survive the
+// restarts.
+func (c *APIHandler) PutWeightsBy() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := router.URLParam(r, "name")
+		cfg := c.findByName(name)
+		if cfg == nil {
+			errors.Handler(w, api.ErrAPIDefinitionNotFound)
+			return
+		}
+
+		var changes proxy.Targets
+		if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
 			errors.Handler(w, errors.New(http.StatusBadRequest, err.Error()))
 			return
 		}
 
-		// Additionally validate plugin configuration
+		targets, err := upstream.Apply(cfg.Proxy.Upstreams.Targets, changes)
+		if err != nil {
+			errors.Handler(w, err)
+			return
+		}
+
+		oldCfg, err := cfg.Redacted()
+		if err != nil {
+			errors.Handler(w, err)
+			return
+		}
+		// the upstreams are replaced rather than modified, they are read by the requests in flight
+		upstreams := *cfg.Proxy.Upstreams
+		upstreams.Targets = targets
+		cfg.Proxy.Upstreams = &upstreams
+
+		c.recordChange(r, audit.UpdatedOperation, oldCfg, cfg)
+		if c.weights != nil {
+			c.weights.Set(name, targets)
+		}
+
+		c.configurationChan <- api.ConfigurationMessage{
+			Operation:     api.UpdatedOperation,
+			Configuration: cfg,
+		}
+
+		render.JSON(w, http.StatusOK, targets)
+	}
+}
+
+// GetPluginBy is the find handler of a plugin of the API definition
+func (c *APIHandler) GetPluginBy() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cfg := c.findByName(router.URLParam(r, "name"))
+		if cfg == nil {
+			errors.Handler(w, api.ErrAPIDefinitionNotFound)
+			return
+		}
+
+		name := router.URLParam(r, "plugin")
 		for _, plg := range cfg.Plugins {
-			isValid, err := plugin.ValidateConfig(plg.Name, plg.Config)
-			if !isValid || err != nil {
-				errors.Handler(w, errors.New(http.StatusBadRequest, err.Error()))
+			if plg.Name == name {
+				render.JSON(w, http.StatusOK, plg)
 				return
 			}
 		}
 
+		errors.Handler(w, api.ErrPluginNotFound)
+	}
+}
+
+// PutPluginBy is the update handler of a plugin of the API definition, e.g. to reload the rules of a
+// plugin without sending the whole definition. The plugin is added when the definition has none, the
+// routes are reloaded with it and it is stored in the definition to survive the restarts.
+func (c *APIHandler) PutPluginBy() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cfg := c.findByName(router.URLParam(r, "name"))
+		if cfg == nil {
+			errors.Handler(w, api.ErrAPIDefinitionNotFound)
+			return
+		}
+
+		var plg api.Plugin
+		if err := json.NewDecoder(r.Body).Decode(&plg); err != nil {
+			errors.Handler(w, errors.New(http.StatusBadRequest, err.Error()))
+			return
+		}
+		plg.Name = router.URLParam(r, "plugin")
+
+		if err := validatePlugin(plg); err != nil {
+			errors.Handler(w, err)
+			return
+		}
+
+		oldCfg, err := cfg.Redacted()
+		if err != nil {
+			errors.Handler(w, err)
+			return
+		}
+
+		// the plugins are replaced rather than modified, they are read by the reloads in flight
+		plugins := make([]api.Plugin, 0, len(cfg.Plugins)+1)
+		replaced := false
+		for _, current := range cfg.Plugins {
+			if current.Name == plg.Name {
+				current, replaced = plg, true
+			}
+			plugins = append(plugins, current)
+		}
+		if !replaced {
+			plugins = append(plugins, plg)
+		}
+		cfg.Plugins = plugins
+
+		c.recordChange(r, audit.UpdatedOperation, oldCfg, cfg)
+		c.configurationChan <- api.ConfigurationMessage{
+			Operation:     api.UpdatedOperation,
+			Configuration: cfg,
+		}
+
+		render.JSON(w, http.StatusOK, plg)
+	}
+}
+
+// Post is the create handler
+func (c *APIHandler) Post() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cfg := api.NewDefinition()
+
+		err := json.NewDecoder(r.Body).Decode(cfg)
+		if nil != err {
+			errors.Handler(w, err)
+			return
+		}
+
+		if err := c.validate(cfg); err != nil {
+			errors.Handler(w, err)
+			return
+		}
+
 		_, span := trace.StartSpan(r.Context(), "definition.Exists")
 		exists, err := c.exists(cfg)
 		span.End()
@@ -152,6 +490,8 @@ func (c *APIHandler) Post() http.HandlerFunc {
 			return
 		}
 
+		c.recordChange(r, audit.CreatedOperation, nil, cfg)
+
 		_, span = trace.StartSpan(r.Context(), "repo.Add")
 		c.configurationChan <- api.ConfigurationMessage{
 			Operation:     api.AddedOperation,
@@ -177,6 +517,7 @@ func (c *APIHandler) DeleteBy() http.HandlerFunc {
 			return
 		}
 
+		c.recordChange(r, audit.DeletedOperation, cfg, nil)
 		c.configurationChan <- api.ConfigurationMessage{
 			Operation:     api.RemovedOperation,
 			Configuration: cfg,
@@ -186,13 +527,220 @@ func (c *APIHandler) DeleteBy() http.HandlerFunc {
 	}
 }
 
+// Import is the bulk import handler, it accepts the document produced by the export handler.
+// In "merge" mode (default) imported definitions are added or update the existing ones with the same name,
+// "replace" mode removes all the existing definitions first. In transactional mode (default) nothing is applied
+// unless all the definitions are valid, "transactional=false" applies only the valid ones.
+func (c *APIHandler) Import() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mode := r.URL.Query().Get("mode")
+		if mode == "" {
+			mode = importModeMerge
+		}
+		if mode != importModeMerge && mode != importModeReplace {
+			errors.Handler(w, errors.New(http.StatusBadRequest, "unsupported import mode"))
+			return
+		}
+
+		transactional := true
+		if value := r.URL.Query().Get("transactional"); value != "" {
+			var err error
+			if transactional, err = strconv.ParseBool(value); err != nil {
+				errors.Handler(w, errors.New(http.StatusBadRequest, "transactional must be a boolean"))
+				return
+			}
+		}
+
+		export, err := decodeExport(r)
+		if err != nil {
+			errors.Handler(w, errors.New(http.StatusBadRequest, err.Error()))
+			return
+		}
+
+		_, span := trace.StartSpan(r.Context(), "definitions.Import")
+		report := c.planImport(export.Definitions, mode == importModeReplace)
+		span.End()
+
+		report.Mode = mode
+		report.Transactional = transactional
+		if report.Failed() && transactional {
+			render.JSON(w, http.StatusBadRequest, report)
+			return
+		}
+
+		// the definitions are replaced at once, so the routes are rebuilt once with all the changes
+		_, span = trace.StartSpan(r.Context(), "repo.Import")
+		batch := api.ConfigurationMessage{Operation: api.BatchOperation}
+		if mode == importModeReplace {
+			existing := make([]*api.Definition, len(c.Cfgs.Definitions))
+			copy(existing, c.Cfgs.Definitions)
+			for _, cfg := range existing {
+				c.recordChange(r, audit.DeletedOperation, cfg, nil)
+				batch.Changes = append(batch.Changes, api.ConfigurationMessage{
+					Operation:     api.RemovedOperation,
+					Configuration: cfg,
+				})
+			}
+		}
+
+		for i, result := range report.Results {
+			if result.Status == importStatusInvalid {
+				continue
+			}
+
+			operation := api.AddedOperation
+			if result.Status == importStatusUpdated {
+				operation = api.UpdatedOperation
+				c.recordChange(r, audit.UpdatedOperation, c.findByName(result.Name), export.Definitions[i])
+			} else {
+				c.recordChange(r, audit.CreatedOperation, nil, export.Definitions[i])
+			}
+
+			batch.Changes = append(batch.Changes, api.ConfigurationMessage{
+				Operation:     operation,
+				Configuration: export.Definitions[i],
+			})
+		}
+		if len(batch.Changes) > 0 {
+			c.configurationChan <- batch
+		}
+		span.End()
+
+		report.Applied = true
+		render.JSON(w, http.StatusOK, report)
+	}
+}
+
+// planImport validates the imported definitions against each other and, unless all the existing
+// definitions are going to be replaced, against the existing ones
+func (c *APIHandler) planImport(definitions []*api.Definition, replace bool) *ImportReport {
+	// proxy definitions holding the listen paths as they are going to be after the import, indexed by name
+	listenPaths := make(map[string]*proxy.Definition)
+	stored := make(map[string]*proxy.Definition)
+	if !replace {
+		for _, cfg := range c.Cfgs.Definitions {
+			listenPaths[cfg.Name] = cfg.Proxy
+			stored[cfg.Name] = cfg.Proxy
+		}
+	}
+
+	report := &ImportReport{Results: make([]ImportResult, len(definitions))}
+	for i, cfg := range definitions {
+		if cfg == nil {
+			report.Results[i] = ImportResult{Status: importStatusInvalid, Error: "api definition is empty"}
+			continue
+		}
+
+		report.Results[i] = ImportResult{Name: cfg.Name, Status: importStatusCreated}
+		// a redacted export gets the secrets of the stored definition with the same name back
+		if err := cfg.RestoreRedacted(c.findByName(cfg.Name)); err != nil {
+			report.Results[i].Status = importStatusInvalid
+			report.Results[i].Error = err.Error()
+			continue
+		}
+		if err := c.validate(cfg); err != nil {
+			report.Results[i].Status = importStatusInvalid
+			report.Results[i].Error = err.Error()
+			continue
+		}
+
+		for j := 0; j < i; j++ {
+			if definitions[j] != nil && definitions[j].Name == cfg.Name {
+				report.Results[i].Status = importStatusInvalid
+				report.Results[i].Error = "api name is duplicated in the import"
+			}
+		}
+		if report.Results[i].Status == importStatusInvalid {
+			continue
+		}
+
+		if _, exists := listenPaths[cfg.Name]; exists {
+			report.Results[i].Status = importStatusUpdated
+		}
+		listenPaths[cfg.Name] = cfg.Proxy
+	}
+
+	// the definitions rejected for a conflict keep their stored listen paths, or have none, which can make
+	// other definitions conflict in turn, so the conflicts are checked again until none is found
+	for {
+		var rejected []string
+		for i, cfg := range definitions {
+			if report.Results[i].Status == importStatusInvalid {
+				continue
+			}
+
+			for name, other := range listenPaths {
+				if name != cfg.Name && other.ConflictsWith(cfg.Proxy) {
+					report.Results[i].Status = importStatusInvalid
+					report.Results[i].Error = api.ErrAPIListenPathExists.Error()
+					rejected = append(rejected, cfg.Name)
+					break
+				}
+			}
+		}
+		if len(rejected) == 0 {
+			break
+		}
+
+		for _, name := range rejected {
+			if cfg, ok := stored[name]; ok {
+				listenPaths[name] = cfg
+			} else {
+				delete(listenPaths, name)
+			}
+		}
+	}
+
+	return report
+}
+
+// Validate is the dry-run validation handler, it validates the definitions exactly as the import in
+// "merge" mode would do, but nothing is applied
+func (c *APIHandler) Validate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		definitions, err := decodeDefinitions(r)
+		if err != nil {
+			errors.Handler(w, errors.New(http.StatusBadRequest, err.Error()))
+			return
+		}
+
+		_, span := trace.StartSpan(r.Context(), "definitions.Validate")
+		importReport := c.planImport(definitions, false)
+		span.End()
+
+		report := ValidationReport{Valid: !importReport.Failed(), Results: importReport.Results}
+		if !report.Valid {
+			render.JSON(w, http.StatusBadRequest, report)
+			return
+		}
+
+		render.JSON(w, http.StatusOK, report)
+	}
+}
+
+// recordChange records the configuration change in the audit trail, it must be called before the change
+// is applied to have the change recorded even if it fails to apply
+func (c *APIHandler) recordChange(r *http.Request, operation string, old, new *api.Definition) {
+	if c.auditTrail == nil {
+		return
+	}
+
+	user, _ := jwt.UserFromContext(r.Context())
+	entry, err := audit.NewEntry(user.Username, operation, old, new)
+	if err != nil {
+		log.WithError(err).Error("Could not calculate the audit diff")
+	}
+
+	c.auditTrail.Record(entry)
+}
+
 func (c *APIHandler) exists(cfg *api.Definition) (bool, error) {
 	for _, storedCfg := range c.Cfgs.Definitions {
 		if storedCfg.Name == cfg.Name {
 			return true, api.ErrAPINameExists
 		}
 
-		if storedCfg.Proxy.ListenPath == cfg.Proxy.ListenPath {
+		if storedCfg.Proxy.ConflictsWith(cfg.Proxy) {
 			return true, api.ErrAPIListenPathExists
 		}
 	}
@@ -210,12 +758,104 @@ func (c *APIHandler) findByName(name string) *api.Definition {
 	return nil
 }
 
-func (c *APIHandler) findByListenPath(listenPath string) *api.Definition {
-	for _, cfg := range c.Cfgs.Definitions {
-		if cfg.Proxy.ListenPath == listenPath {
-			return cfg
+// findByListenPath returns the other definition registered on a listen path or an alias of the definition
+// with the same hosts and headers
+func (c *APIHandler) findByListenPath(cfg *api.Definition) *api.Definition {
+	for _, storedCfg := range c.Cfgs.Definitions {
+		if storedCfg.Name != cfg.Name && storedCfg.Proxy.ConflictsWith(cfg.Proxy) {
+			return storedCfg
 		}
 	}
 
 	return nil
 }
+
+// validate validates the definition itself, the listeners it is bound to and its plugins configuration
+func (c *APIHandler) validate(cfg *api.Definition) error {
+	isValid, err := cfg.Validate()
+	if false == isValid && err != nil {
+		return errors.New(http.StatusBadRequest, err.Error())
+	}
+
+	if err := cfg.Proxy.ValidateListeners(c.listeners); err != nil {
+		return errors.New(http.StatusBadRequest, err.Error())
+	}
+
+	// Additionally validate plugin configuration
+	for _, plg := range cfg.Plugins {
+		if err := validatePlugin(plg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validatePlugin validates the plugin configuration, the validators may reject it without an error
+func validatePlugin(plg api.Plugin) error {
+	isValid, err := plugin.ValidateConfig(plg.Name, plg.Config)
+	if err != nil {
+		return errors.New(http.StatusBadRequest, err.Error())
+	}
+	if !isValid {
+		return errors.New(http.StatusBadRequest, fmt.Sprintf("invalid plugin configuration of %q", plg.Name))
+	}
+
+	return nil
+}
+
+// decodeExport decodes the export document from the request body, both JSON and YAML are accepted
+func decodeExport(r *http.Request) (*api.Export, error) {
+	b, err := readBody(r)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &api.Export{}
+	return export, json.Unmarshal(b, export)
+}
+
+// decodeDefinitions decodes one or more definitions from the request body, that can be
+// a single definition, a list of definitions or the export document, both JSON and YAML are accepted
+func decodeDefinitions(r *http.Request) ([]*api.Definition, error) {
+	b, err := readBody(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var document map[string]json.RawMessage
+	switch {
+	case bytes.HasPrefix(bytes.TrimSpace(b), []byte("[")):
+		var definitions []*api.Definition
+		if err := json.Unmarshal(b, &definitions); err != nil {
+			return nil, err
+		}
+		return definitions, nil
+	case json.Unmarshal(b, &document) == nil && document["definitions"] != nil:
+		export := &api.Export{}
+		if err := json.Unmarshal(b, export); err != nil {
+			return nil, err
+		}
+		return export.Definitions, nil
+	default:
+		definition := api.NewDefinition()
+		if err := json.Unmarshal(b, definition); err != nil {
+			return nil, err
+		}
+		return []*api.Definition{definition}, nil
+	}
+}
+
+// readBody reads the request body converting YAML to JSON when the request content type is YAML
+func readBody(r *http.Request) ([]byte, error) {
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.Contains(r.Header.Get("Content-Type"), "yaml") {
+		return yaml.YAMLToJSON(b)
+	}
+
+	return b, nil
+}
@@ -0,0 +1,675 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/go-chi/chi"
+	"github.com/hellofresh/janus/pkg/api"
+	"github.com/hellofresh/janus/pkg/audit"
+	"github.com/hellofresh/janus/pkg/config"
+	"github.com/hellofresh/janus/pkg/jwt"
+	"github.com/hellofresh/janus/pkg/maintenance"
+	"github.com/hellofresh/janus/pkg/plugin"
+	_ "github.com/hellofresh/janus/pkg/plugin/basic"
+	_ "github.com/hellofresh/janus/pkg/plugin/compression"
+	_ "github.com/hellofresh/janus/pkg/plugin/cors"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/hellofresh/janus/pkg/sampling"
+	"github.com/hellofresh/janus/pkg/upstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	// a plugin rejecting its configuration without telling why
+	plugin.RegisterPlugin("test_rejecting", plugin.Plugin{
+		Validate: func(rawConfig plugin.Config) (bool, error) { return false, nil },
+	})
+}
+
+func newTestAPIHandler() *APIHandler {
+	definition := api.NewDefinition()
+	definition.Name = "example"
+	definition.Proxy.ListenPath = "/example/*"
+	definition.Plugins = []api.Plugin{{
+		Name:    "basic_auth",
+		Enabled: true,
+		Config:  map[string]interface{}{"password": "secret"},
+	}}
+
+	handler := NewAPIHandler(make(chan api.ConfigurationMessage))
+	handler.Cfgs = &api.Configuration{Definitions: []*api.Definition{definition}}
+
+	return handler
+}
+
+func TestAPIHandlerExport(t *testing.T) {
+	tests := []struct {
+		scenario string
+		query    string
+		password interface{}
+	}{
+		{scenario: "redacted by default", query: "", password: api.RedactedValue},
+		{scenario: "with secrets", query: "?include_secrets=true", password: "secret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scenario, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			newTestAPIHandler().Export()(w, httptest.NewRequest(http.MethodGet, "/apis/export"+tt.query, nil))
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+			var export api.Export
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &export))
+			require.Len(t, export.Definitions, 1)
+			assert.Equal(t, "example", export.Definitions[0].Name)
+			assert.Equal(t, tt.password, export.Definitions[0].Plugins[0].Config["password"])
+		})
+	}
+}
+
+func TestAPIHandlerExportSecretsRequireAdmin(t *testing.T) {
+	tests := []struct {
+		scenario string
+		roles    []string
+		code     int
+	}{
+		{scenario: "viewer", roles: []string{jwt.RoleViewer}, code: http.StatusForbidden},
+		{scenario: "editor", roles: []string{jwt.RoleEditor}, code: http.StatusForbidden},
+		{scenario: "admin", roles: []string{jwt.RoleAdmin}, code: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scenario, func(t *testing.T) {
+			handler := newTestAPIHandler()
+			handler.rbac = jwt.NewRBACMiddleware(config.RBAC{Enabled: true})
+
+			req := httptest.NewRequest(http.MethodGet, "/apis/export?include_secrets=true", nil)
+			req = req.WithContext(context.WithValue(req.Context(), jwt.Payload{}, jwt.User{Username: "user", Roles: tt.roles}))
+			w := httptest.NewRecorder()
+			handler.Export()(w, req)
+			assert.Equal(t, tt.code, w.Code)
+		})
+	}
+}
+
+func TestAPIHandlerExportYAML(t *testing.T) {
+	w := httptest.NewRecorder()
+	newTestAPIHandler().Export()(w, httptest.NewRequest(http.MethodGet, "/apis/export?format=yaml", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-yaml", w.Header().Get("Content-Type"))
+
+	var export api.Export
+	require.NoError(t, yaml.Unmarshal(w.Body.Bytes(), &export))
+	require.Len(t, export.Definitions, 1)
+	assert.Equal(t, "/example/*", export.Definitions[0].Proxy.ListenPath)
+}
+
+func TestAPIHandlerExportUnsupportedFormat(t *testing.T) {
+	w := httptest.NewRecorder()
+	newTestAPIHandler().Export()(w, httptest.NewRequest(http.MethodGet, "/apis/export?format=xml", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func newImportRequest(query string, definitions ...*api.Definition) *http.Request {
+	b, _ := json.Marshal(api.Export{Definitions: definitions})
+	return httptest.NewRequest(http.MethodPost, "/apis/import"+query, bytes.NewReader(b))
+}
+
+func newImportDefinition(name, listenPath string) *api.Definition {
+	definition := api.NewDefinition()
+	definition.Name = name
+	definition.Proxy.ListenPath = listenPath
+	definition.Proxy.Upstreams = &proxy.Upstreams{
+		Balancing: "roundrobin",
+		Targets:   []*proxy.Target{{Target: "http://localhost:9089/hello-world"}},
+	}
+
+	return definition
+}
+
+func TestAPIHandlerImport(t *testing.T) {
+	invalid := newImportDefinition("invalid", "/invalid/*")
+	invalid.Plugins = []api.Plugin{{Name: "unknown", Enabled: true}}
+	alias := newImportDefinition("new", "/new/*")
+	alias.Proxy.ListenPathAliases = []string{"/example/*"}
+	hosted := newImportDefinition("new", "/example/*")
+	hosted.Proxy.Hosts = []string{"api.example.com"}
+
+	tests := []struct {
+		scenario   string
+		query      string
+		imported   []*api.Definition
+		code       int
+		statuses   []string
+		operations []api.ConfigurationOperation
+	}{
+		{
+			scenario:   "merge",
+			imported:   []*api.Definition{newImportDefinition("example", "/example/*"), newImportDefinition("new", "/new/*")},
+			code:       http.StatusOK,
+			statuses:   []string{importStatusUpdated, importStatusCreated},
+			operations: []api.ConfigurationOperation{api.UpdatedOperation, api.AddedOperation},
+		},
+		{
+			scenario:   "replace",
+			query:      "?mode=replace",
+			imported:   []*api.Definition{newImportDefinition("new", "/example/*")},
+			code:       http.StatusOK,
+			statuses:   []string{importStatusCreated},
+			operations: []api.ConfigurationOperation{api.RemovedOperation, api.AddedOperation},
+		},
+		{
+			scenario: "listen path conflict with existing definition",
+			imported: []*api.Definition{newImportDefinition("new", "/example/*")},
+			code:     http.StatusBadRequest,
+			statuses: []string{importStatusInvalid},
+		},
+		{
+			scenario:   "listen path shared with different hosts",
+			imported:   []*api.Definition{hosted},
+			code:       http.StatusOK,
+			statuses:   []string{importStatusCreated},
+			operations: []api.ConfigurationOperation{api.AddedOperation},
+		},
+		{
+			scenario: "alias conflict with existing definition",
+			imported: []*api.Definition{alias},
+			code:     http.StatusBadRequest,
+			statuses: []string{importStatusInvalid},
+		},
+		{
+			scenario: "rejected update keeps the stored listen path",
+			query:    "?transactional=false",
+			imported: []*api.Definition{
+				newImportDefinition("example", "/new/*"),
+				newImportDefinition("new", "/new/*"),
+				newImportDefinition("other", "/example/*"),
+			},
+			code:     http.StatusOK,
+			statuses: []string{importStatusInvalid, importStatusInvalid, importStatusInvalid},
+		},
+		{
+			scenario: "duplicated name",
+			imported: []*api.Definition{newImportDefinition("new", "/new/*"), newImportDefinition("new", "/other/*")},
+			code:     http.StatusBadRequest,
+			statuses: []string{importStatusCreated, importStatusInvalid},
+		},
+		{
+			scenario: "transactional with invalid definition",
+			imported: []*api.Definition{newImportDefinition("new", "/new/*"), invalid},
+			code:     http.StatusBadRequest,
+			statuses: []string{importStatusCreated, importStatusInvalid},
+		},
+		{
+			scenario:   "non transactional with invalid definition",
+			query:      "?transactional=false",
+			imported:   []*api.Definition{newImportDefinition("new", "/new/*"), invalid},
+			code:       http.StatusOK,
+			statuses:   []string{importStatusCreated, importStatusInvalid},
+			operations: []api.ConfigurationOperation{api.AddedOperation},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scenario, func(t *testing.T) {
+			cfgChan := make(chan api.ConfigurationMessage, 10)
+			handler := NewAPIHandler(cfgChan)
+			handler.Cfgs = &api.Configuration{Definitions: []*api.Definition{newImportDefinition("example", "/example/*")}}
+
+			w := httptest.NewRecorder()
+			handler.Import()(w, newImportRequest(tt.query, tt.imported...))
+			require.Equal(t, tt.code, w.Code)
+
+			var report ImportReport
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+			assert.Equal(t, tt.code == http.StatusOK, report.Applied)
+			require.Len(t, report.Results, len(tt.statuses))
+			for i, status := range tt.statuses {
+				assert.Equal(t, status, report.Results[i].Status)
+			}
+
+			close(cfgChan)
+			var operations []api.ConfigurationOperation
+			for msg := range cfgChan {
+				require.Equal(t, api.BatchOperation, msg.Operation, "the import is applied as one change")
+				for _, change := range msg.Changes {
+					operations = append(operations, change.Operation)
+				}
+			}
+			assert.Equal(t, tt.operations, operations)
+		})
+	}
+}
+
+func TestAPIHandlerImportReplace(t *testing.T) {
+	cfgChan := make(chan api.ConfigurationMessage, 10)
+	handler := NewAPIHandler(cfgChan)
+	handler.Cfgs = &api.Configuration{Definitions: []*api.Definition{
+		newImportDefinition("one", "/one/*"),
+		newImportDefinition("two", "/two/*"),
+		newImportDefinition("three", "/three/*"),
+	}}
+
+	w := httptest.NewRecorder()
+	handler.Import()(w, newImportRequest("?mode=replace",
+		newImportDefinition("four", "/four/*"),
+		newImportDefinition("five", "/five/*"),
+		newImportDefinition("two", "/six/*"),
+	))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	close(cfgChan)
+	var messages []api.ConfigurationMessage
+	for msg := range cfgChan {
+		messages = append(messages, msg)
+	}
+	require.Len(t, messages, 1, "the routes are rebuilt once")
+
+	var changes []string
+	for _, change := range messages[0].Changes {
+		changes = append(changes, fmt.Sprintf("%d:%s", change.Operation, change.Configuration.Name))
+	}
+	assert.Equal(t, []string{
+		fmt.Sprintf("%d:one", api.RemovedOperation),
+		fmt.Sprintf("%d:two", api.RemovedOperation),
+		fmt.Sprintf("%d:three", api.RemovedOperation),
+		fmt.Sprintf("%d:four", api.AddedOperation),
+		fmt.Sprintf("%d:five", api.AddedOperation),
+		fmt.Sprintf("%d:two", api.AddedOperation),
+	}, changes, "all the existing definitions are removed")
+}
+
+func TestAPIHandlerImportExported(t *testing.T) {
+	source := newTestAPIHandler()
+	source.Cfgs.Definitions[0].Plugins = nil
+
+	w := httptest.NewRecorder()
+	source.Export()(w, httptest.NewRequest(http.MethodGet, "/apis/export?format=yaml", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	cfgChan := make(chan api.ConfigurationMessage, 1)
+	target := NewAPIHandler(cfgChan)
+	target.Cfgs = &api.Configuration{}
+
+	req := httptest.NewRequest(http.MethodPost, "/apis/import", w.Body)
+	req.Header.Set("Content-Type", "application/x-yaml")
+	w = httptest.NewRecorder()
+	target.Import()(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	msg := <-cfgChan
+	require.Len(t, msg.Changes, 1)
+	assert.Equal(t, api.AddedOperation, msg.Changes[0].Operation)
+	assert.Equal(t, source.Cfgs.Definitions[0], msg.Changes[0].Configuration)
+}
+
+func TestAPIHandlerImportRedactedExport(t *testing.T) {
+	for _, mode := range []string{importModeMerge, importModeReplace} {
+		t.Run(mode, func(t *testing.T) {
+			cfgChan := make(chan api.ConfigurationMessage, 1)
+			handler := newTestAPIHandler()
+			handler.configurationChan = cfgChan
+			handler.Cfgs.Definitions[0].Proxy.Upstreams = &proxy.Upstreams{
+				Balancing: "roundrobin",
+				Targets:   []*proxy.Target{{Target: "http://localhost:9089/hello-world"}},
+			}
+
+			w := httptest.NewRecorder()
+			handler.Export()(w, httptest.NewRequest(http.MethodGet, "/apis/export", nil))
+			require.Equal(t, http.StatusOK, w.Code)
+
+			req := httptest.NewRequest(http.MethodPost, "/apis/import?mode="+mode, w.Body)
+			w = httptest.NewRecorder()
+			handler.Import()(w, req)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			msg := <-cfgChan
+			imported := msg.Changes[len(msg.Changes)-1].Configuration
+			assert.Equal(t, "example", imported.Name)
+			assert.Equal(t, "secret", imported.Plugins[0].Config["password"], "the stored secret is kept")
+		})
+	}
+}
+
+func TestAPIHandlerImportRedactedWithoutStoredValue(t *testing.T) {
+	definition := newImportDefinition("new", "/new/*")
+	definition.Plugins = []api.Plugin{{
+		Name:    "basic_auth",
+		Enabled: true,
+		Config:  map[string]interface{}{"password": api.RedactedValue},
+	}}
+
+	w := httptest.NewRecorder()
+	newTestAPIHandler().Import()(w, newImportRequest("", definition))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	var report ImportReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	require.Len(t, report.Results, 1)
+	assert.Equal(t, "new", report.Results[0].Name)
+	assert.Equal(t, importStatusInvalid, report.Results[0].Status)
+	assert.Contains(t, report.Results[0].Error, "basic_auth.password")
+}
+
+func TestAPIHandlerImportUnsupportedMode(t *testing.T) {
+	w := httptest.NewRecorder()
+	newTestAPIHandler().Import()(w, newImportRequest("?mode=append"))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAPIHandlerValidate(t *testing.T) {
+	single, _ := json.Marshal(newImportDefinition("new", "/new/*"))
+	list, _ := json.Marshal([]*api.Definition{newImportDefinition("new", "/new/*"), newImportDefinition("example", "/example/*")})
+	conflict, _ := json.Marshal(api.Export{Definitions: []*api.Definition{newImportDefinition("new", "/example/*")}})
+	malformed, _ := json.Marshal(api.NewDefinition())
+	partner := newImportDefinition("partner", "/partner/*")
+	partner.Proxy.Listeners = []string{"partner"}
+	unknownListener, _ := json.Marshal(partner)
+
+	tests := []struct {
+		scenario string
+		body     []byte
+		code     int
+		statuses []string
+	}{
+		{scenario: "single definition", body: single, code: http.StatusOK, statuses: []string{importStatusCreated}},
+		{scenario: "list of definitions", body: list, code: http.StatusOK, statuses: []string{importStatusCreated, importStatusUpdated}},
+		{scenario: "export document with listen path conflict", body: conflict, code: http.StatusBadRequest, statuses: []string{importStatusInvalid}},
+		{scenario: "malformed definition", body: malformed, code: http.StatusBadRequest, statuses: []string{importStatusInvalid}},
+		{scenario: "definition bound to an unknown listener", body: unknownListener, code: http.StatusBadRequest, statuses: []string{importStatusInvalid}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scenario, func(t *testing.T) {
+			// unbuffered channel makes the test hang if anything is applied
+			handler := NewAPIHandler(make(chan api.ConfigurationMessage))
+			handler.Cfgs = &api.Configuration{Definitions: []*api.Definition{newImportDefinition("example", "/example/*")}}
+
+			w := httptest.NewRecorder()
+			handler.Validate()(w, httptest.NewRequest(http.MethodPost, "/apis/validate", bytes.NewReader(tt.body)))
+			require.Equal(t, tt.code, w.Code)
+
+			var report ValidationReport
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+			assert.Equal(t, tt.code == http.StatusOK, report.Valid)
+			require.Len(t, report.Results, len(tt.statuses))
+			for i, status := range tt.statuses {
+				assert.Equal(t, status, report.Results[i].Status)
+			}
+		})
+	}
+}
+
+type memorySink struct {
+	entries []audit.Entry
+}
+
+func (s *memorySink) Write(entry audit.Entry) error {
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func TestAPIHandlerAudit(t *testing.T) {
+	sink := &memorySink{}
+	handler := NewAPIHandler(make(chan api.ConfigurationMessage, 10))
+	handler.Cfgs = &api.Configuration{Definitions: []*api.Definition{newImportDefinition("example", "/example/*")}}
+	handler.auditTrail = audit.NewTrail(sink, 10)
+
+	b, _ := json.Marshal(newImportDefinition("new", "/new/*"))
+	req := httptest.NewRequest(http.MethodPost, "/apis", bytes.NewReader(b))
+	req = req.WithContext(context.WithValue(req.Context(), jwt.Payload{}, jwt.User{Username: "operator"}))
+	w := httptest.NewRecorder()
+	handler.Post()(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	require.Len(t, sink.entries, 1)
+	assert.Equal(t, "operator", sink.entries[0].Actor)
+	assert.Equal(t, audit.CreatedOperation, sink.entries[0].Operation)
+	assert.Equal(t, "new", sink.entries[0].Definition)
+
+	w = httptest.NewRecorder()
+	NewAuditHandler(handler.auditTrail)(w, httptest.NewRequest(http.MethodGet, "/audit?limit=10", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var page AuditPage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Equal(t, 1, page.Total)
+	require.Len(t, page.Entries, 1)
+	assert.Equal(t, "new", page.Entries[0].Definition)
+
+	w = httptest.NewRecorder()
+	NewAuditHandler(handler.auditTrail)(w, httptest.NewRequest(http.MethodGet, "/audit?limit=0", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAPIHandlerListenPathHosts(t *testing.T) {
+	hosted := func(name, listenPath string, hosts ...string) *api.Definition {
+		definition := newImportDefinition(name, listenPath)
+		definition.Proxy.Hosts = hosts
+		return definition
+	}
+
+	cfgChan := make(chan api.ConfigurationMessage, 10)
+	handler := NewAPIHandler(cfgChan)
+	handler.Cfgs = &api.Configuration{Definitions: []*api.Definition{
+		hosted("a", "/v1/*", "api.a.com"),
+		hosted("b", "/b/*", "api.b.com"),
+	}}
+
+	r := chi.NewRouter()
+	r.Post("/apis", handler.Post())
+	r.Put("/apis/{name}", handler.PutBy())
+	serve := func(method, path string, definition *api.Definition) int {
+		b, _ := json.Marshal(definition)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, bytes.NewReader(b)))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusCreated, serve(http.MethodPost, "/apis", hosted("c", "/v1/*", "api.c.com")), "the definitions are routed by host")
+	assert.Equal(t, http.StatusCreated, serve(http.MethodPost, "/apis", hosted("any", "/v1/*")))
+	assert.Equal(t, http.StatusConflict, serve(http.MethodPost, "/apis", hosted("c", "/v1/*", "API.a.com")))
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodPut, "/apis/b", hosted("b", "/v1/*", "api.b.com")))
+	assert.Equal(t, http.StatusConflict, serve(http.MethodPut, "/apis/b", hosted("b", "/v1/*", "api.a.com")))
+
+	beta := hosted("beta", "/v1/*", "api.a.com")
+	beta.Proxy.Headers = []proxy.HeaderMatch{{Name: "X-Version", Value: "beta"}}
+	assert.Equal(t, http.StatusCreated, serve(http.MethodPost, "/apis", beta), "the definitions are routed by headers")
+
+	handler.Cfgs.Definitions = append(handler.Cfgs.Definitions, beta)
+	other := hosted("other", "/v1/*", "api.a.com")
+	other.Proxy.Headers = []proxy.HeaderMatch{{Name: "x-version", Value: "beta"}}
+	assert.Equal(t, http.StatusConflict, serve(http.MethodPost, "/apis", other))
+}
+
+func TestAPIHandlerMaintenance(t *testing.T) {
+	cfgChan := make(chan api.ConfigurationMessage, 10)
+	handler := NewAPIHandler(cfgChan)
+	handler.Cfgs = &api.Configuration{Definitions: []*api.Definition{newImportDefinition("example", "/example/*")}}
+	handler.maintenance = maintenance.NewModes()
+
+	r := chi.NewRouter()
+	r.Get("/apis/{name}/maintenance", handler.GetMaintenanceBy())
+	r.Put("/apis/{name}/maintenance", handler.PutMaintenanceBy())
+
+	w := httptest.NewRecorder()
+	body := `{"enabled": true, "retry_after": 120, "body": "Back soon"}`
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/apis/example/maintenance", bytes.NewBufferString(body)))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	mode := handler.maintenance.Get("example")
+	assert.True(t, mode.Enabled, "the mode takes effect without a reload")
+	assert.Equal(t, 120, mode.RetryAfter)
+
+	msg := <-cfgChan
+	assert.Equal(t, api.UpdatedOperation, msg.Operation)
+	assert.True(t, msg.Configuration.Maintenance.Enabled, "the mode is stored in the definition")
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/apis/example/maintenance", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Back soon")
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/apis/example/maintenance", bytes.NewBufferString(`{"enabled": true, "status": 42}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/apis/unknown/maintenance", bytes.NewBufferString(`{"enabled": true}`)))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAPIHandlerSampling(t *testing.T) {
+	handler := NewAPIHandler(make(chan api.ConfigurationMessage))
+	handler.Cfgs = &api.Configuration{Definitions: []*api.Definition{newImportDefinition("example", "/example/*")}}
+	handler.samplingRates = sampling.NewRates(0.01)
+
+	r := chi.NewRouter()
+	r.Get("/apis/{name}/sampling", handler.GetSamplingBy())
+	r.Put("/apis/{name}/sampling", handler.PutSamplingBy())
+	r.Delete("/apis/{name}/sampling", handler.DeleteSamplingBy())
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/apis/example/sampling", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"rate": 0.01, "override": false}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/apis/example/sampling", bytes.NewBufferString(`{"rate": 1}`)))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"rate": 1, "override": true}`, w.Body.String())
+	assert.Equal(t, sampling.Rate{Rate: 1, Override: true}, handler.samplingRates.Get("example"), "the rate takes effect without a reload")
+
+	for _, invalid := range []string{`{"rate": 1.5}`, `{"rate": -0.1}`, `{}`, `[]`} {
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/apis/example/sampling", bytes.NewBufferString(invalid)))
+		assert.Equal(t, http.StatusBadRequest, w.Code, invalid)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/apis/example/sampling", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"rate": 0.01, "override": false}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/apis/unknown/sampling", bytes.NewBufferString(`{"rate": 1}`)))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAPIHandlerWeights(t *testing.T) {
+	definition := newImportDefinition("example", "/example/*")
+	definition.Proxy.Upstreams = &proxy.Upstreams{
+		Balancing: "weight",
+		Targets:   []*proxy.Target{{Target: "http://stable", Weight: 100}, {Target: "http://canary", Weight: 0}},
+	}
+
+	cfgChan := make(chan api.ConfigurationMessage, 10)
+	handler := NewAPIHandler(cfgChan)
+	handler.Cfgs = &api.Configuration{Definitions: []*api.Definition{definition}}
+	handler.weights = upstream.NewWeights()
+
+	r := chi.NewRouter()
+	r.Get("/apis/{name}/weights", handler.GetWeightsBy())
+	r.Put("/apis/{name}/weights", handler.PutWeightsBy())
+
+	w := httptest.NewRecorder()
+	body := `[{"target": "http://stable", "weight": 80}, {"target": "http://canary", "weight": 20}]`
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/apis/example/weights", bytes.NewBufferString(body)))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	targets := handler.weights.Get("example")
+	require.Len(t, targets, 2)
+	assert.Equal(t, 20, targets[1].Weight, "the weights take effect without a reload")
+
+	msg := <-cfgChan
+	assert.Equal(t, api.UpdatedOperation, msg.Operation)
+	assert.Equal(t, "weight", msg.Configuration.Proxy.Upstreams.Balancing)
+	assert.Equal(t, 80, msg.Configuration.Proxy.Upstreams.Targets[0].Weight, "the weights are stored in the definition")
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/apis/example/weights", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, body, w.Body.String())
+
+	for _, invalid := range []string{
+		`[{"target": "http://canary", "weight": -1}]`,
+		`[{"target": "http://stable", "weight": 0}, {"target": "http://canary", "weight": 0}]`,
+		`[{"target": "http://unknown", "weight": 10}]`,
+		`{"weight": 10}`,
+	} {
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/apis/example/weights", bytes.NewBufferString(invalid)))
+		assert.Equal(t, http.StatusBadRequest, w.Code, invalid)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/apis/unknown/weights", bytes.NewBufferString(body)))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAPIHandlerPlugins(t *testing.T) {
+	definition := newImportDefinition("example", "/example/*")
+	definition.Plugins = []api.Plugin{{Name: "cors", Enabled: true, Config: map[string]interface{}{"domains": []string{"*"}}}}
+
+	cfgChan := make(chan api.ConfigurationMessage, 10)
+	handler := NewAPIHandler(cfgChan)
+	handler.Cfgs = &api.Configuration{Definitions: []*api.Definition{definition}}
+
+	r := chi.NewRouter()
+	r.Get("/apis/{name}/plugins/{plugin}", handler.GetPluginBy())
+	r.Put("/apis/{name}/plugins/{plugin}", handler.PutPluginBy())
+
+	w := httptest.NewRecorder()
+	body := `{"enabled": true, "config": {"domains": ["http://example.com"]}}`
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/apis/example/plugins/cors", bytes.NewBufferString(body)))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	msg := <-cfgChan
+	assert.Equal(t, api.UpdatedOperation, msg.Operation)
+	require.Len(t, msg.Configuration.Plugins, 1, "the plugin is replaced")
+	assert.Equal(t, []interface{}{"http://example.com"}, msg.Configuration.Plugins[0].Config["domains"])
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/apis/example/plugins/cors", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"name": "cors", "enabled": true, "config": {"domains": ["http://example.com"]}}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/apis/example/plugins/compression", bytes.NewBufferString(`{"enabled": true}`)))
+	require.Equal(t, http.StatusOK, w.Code)
+	msg = <-cfgChan
+	assert.Len(t, msg.Configuration.Plugins, 2, "the missing plugin is added")
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/apis/example/plugins/rate_limit", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	for _, invalid := range []string{`{"enabled": true, "config": {"domains": "*"}}`, `[]`} {
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/apis/example/plugins/cors", bytes.NewBufferString(invalid)))
+		assert.Equal(t, http.StatusBadRequest, w.Code, invalid)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/apis/example/plugins/test_rejecting", bytes.NewBufferString(`{"enabled": true}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code, "rejected without an error")
+	assert.Contains(t, w.Body.String(), "invalid plugin configuration")
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/apis/example/plugins/unknown", bytes.NewBufferString(`{"enabled": true}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/apis/unknown/plugins/cors", bytes.NewBufferString(body)))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
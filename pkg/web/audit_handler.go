@@ -0,0 +1,52 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/hellofresh/janus/pkg/audit"
+	"github.com/hellofresh/janus/pkg/errors"
+	"github.com/hellofresh/janus/pkg/render"
+)
+
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 500
+)
+
+// AuditPage represents a page of the recent audit entries
+type AuditPage struct {
+	Total   int           `json:"total"`
+	Offset  int           `json:"offset"`
+	Limit   int           `json:"limit"`
+	Entries []audit.Entry `json:"entries"`
+}
+
+// NewAuditHandler creates a new instance of audit handler, it pages through the recent entries newest first
+func NewAuditHandler(trail *audit.Trail) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		offset, err := queryInt(r, "offset", 0)
+		if err != nil || offset < 0 {
+			errors.Handler(w, errors.New(http.StatusBadRequest, "offset must be a non-negative integer"))
+			return
+		}
+
+		limit, err := queryInt(r, "limit", defaultAuditLimit)
+		if err != nil || limit < 1 || limit > maxAuditLimit {
+			errors.Handler(w, errors.New(http.StatusBadRequest, "limit must be an integer between 1 and 500"))
+			return
+		}
+
+		entries, total := trail.Recent(offset, limit)
+		render.JSON(w, http.StatusOK, AuditPage{Total: total, Offset: offset, Limit: limit, Entries: entries})
+	}
+}
+
+func queryInt(r *http.Request, key string, defaultValue int) (int, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	return strconv.Atoi(value)
+}
@@ -0,0 +1,30 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/hellofresh/janus/pkg/drain"
+	"github.com/hellofresh/janus/pkg/render"
+)
+
+// NewGetDrainHandler creates the handler reading the drain state of the gateway
+func NewGetDrainHandler(drainer *drain.Drainer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, http.StatusOK, drainer.Status())
+	}
+}
+
+// NewDrainHandler creates the handler starting to drain the gateway, the readiness probe fails and the
+// new requests are answered with 503 until the gateway is undrained
+func NewDrainHandler(drainer *drain.Drainer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, http.StatusOK, drainer.Drain())
+	}
+}
+
+// NewUndrainHandler creates the handler stopping to drain the gateway
+func NewUndrainHandler(drainer *drain.Drainer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, http.StatusOK, drainer.Undrain())
+	}
+}
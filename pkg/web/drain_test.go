@@ -0,0 +1,62 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	baseJWT "github.com/dgrijalva/jwt-go"
+	"github.com/hellofresh/janus/pkg/config"
+	"github.com/hellofresh/janus/pkg/drain"
+	"github.com/hellofresh/janus/pkg/jwt"
+	"github.com/hellofresh/janus/pkg/router"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDrainRoutes(t *testing.T) {
+	token, err := jwt.IssueAdminToken(jwt.SigningMethod{Alg: "HS256", Key: "secret"}, baseJWT.MapClaims{"sub": "admin"}, time.Hour)
+	require.NoError(t, err)
+
+	drainer := drain.NewDrainer(0, nil)
+	readiness := NewReadiness(ReadinessCheck{Name: "drain", Check: drainer.Check})
+	readiness.SetReady(true)
+
+	r := router.NewChiRouter()
+	New(
+		WithCredentials(config.Credentials{Algorithm: "HS256", Secret: "secret"}),
+		WithDrainer(drainer),
+		WithReadiness(readiness),
+	).AddRoutes(r)
+	serve := func(method, path string, authorized bool) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		if authorized {
+			req.Header.Set("Authorization", "Bearer "+token.Token)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, serve(http.MethodPost, "/drain", false).Code)
+	assert.False(t, drainer.IsDraining())
+
+	w := serve(http.MethodPost, "/drain", true)
+	require.Equal(t, http.StatusOK, w.Code)
+	var status drain.Status
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.True(t, status.Draining)
+	assert.True(t, drainer.IsDraining())
+	assert.Equal(t, http.StatusServiceUnavailable, serve(http.MethodGet, DefaultReadyPath, false).Code, "the readiness probe fails while draining")
+
+	w = serve(http.MethodGet, "/drain", true)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"draining":true`)
+
+	w = serve(http.MethodPost, "/undrain", true)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"draining":false}`, w.Body.String())
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, DefaultReadyPath, false).Code)
+}
@@ -1,10 +1,11 @@
 package web
 
 import (
-	"fmt"
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 
 	"github.com/hellofresh/janus/pkg/render"
 	log "github.com/sirupsen/logrus"
@@ -17,20 +18,34 @@ func Home() http.HandlerFunc {
 	}
 }
 
-// RedirectHTTPS redirects an http request to https
-func RedirectHTTPS(port int) http.HandlerFunc {
+// RedirectHTTPS redirects an http request to https on the port, preserving the path and the query.
+// The status is the redirect status code, 301 is used when it is not a redirect status.
+func RedirectHTTPS(port int, status int) http.HandlerFunc {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		status = http.StatusMovedPermanently
+	}
+
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		host, _, _ := net.SplitHostPort(req.Host)
+		host, _, err := net.SplitHostPort(req.Host)
+		if err != nil {
+			host = strings.Trim(req.Host, "[]")
+		}
+		if port != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(port))
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
 
 		target := url.URL{
-			Scheme: "https",
-			Host:   fmt.Sprintf("%s:%v", host, port),
-			Path:   req.URL.Path,
-		}
-		if len(req.URL.RawQuery) > 0 {
-			target.RawQuery += "?" + req.URL.RawQuery
+			Scheme:   "https",
+			Host:     host,
+			Path:     req.URL.Path,
+			RawPath:  req.URL.RawPath,
+			RawQuery: req.URL.RawQuery,
 		}
-		log.Printf("redirect to: %s", target.String())
-		http.Redirect(w, req, target.String(), http.StatusTemporaryRedirect)
+		log.Debugf("redirect to: %s", target.String())
+		http.Redirect(w, req, target.String(), status)
 	})
 }
@@ -0,0 +1,40 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedirectHTTPS(t *testing.T) {
+	tests := []struct {
+		name     string
+		port     int
+		status   int
+		host     string
+		target   string
+		expected string
+		code     int
+	}{
+		{"path and query", 8433, http.StatusPermanentRedirect, "example.com:8080", "/api/users?page=2&sort=name", "https://example.com:8433/api/users?page=2&sort=name", http.StatusPermanentRedirect},
+		{"default https port", 443, http.StatusMovedPermanently, "example.com", "/api", "https://example.com/api", http.StatusMovedPermanently},
+		{"escaped path", 443, http.StatusMovedPermanently, "example.com:80", "/a%2Fb", "https://example.com/a%2Fb", http.StatusMovedPermanently},
+		{"ipv6 host", 443, http.StatusMovedPermanently, "[::1]:80", "/", "https://[::1]/", http.StatusMovedPermanently},
+		{"not a redirect status", 8433, http.StatusOK, "example.com", "/", "https://example.com:8433/", http.StatusMovedPermanently},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.Host = tt.host
+			w := httptest.NewRecorder()
+
+			RedirectHTTPS(tt.port, tt.status)(w, req)
+
+			assert.Equal(t, tt.code, w.Code)
+			assert.Equal(t, tt.expected, w.Header().Get("Location"))
+		})
+	}
+}
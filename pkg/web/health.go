@@ -0,0 +1,161 @@
+package web
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hellofresh/janus/pkg/api"
+	"github.com/hellofresh/janus/pkg/render"
+	"github.com/hellofresh/janus/pkg/upstream"
+	log "github.com/sirupsen/logrus"
+)
+
+// Health statuses
+const (
+	HealthOK          = "OK"
+	HealthPartial     = "Partially Available"
+	HealthUnavailable = "Unavailable"
+)
+
+// defaultHealthTimeout is used for the health checks without timeout
+const defaultHealthTimeout = 5 * time.Second
+
+// HealthReport is the aggregated health of the gateway and its upstreams
+type HealthReport struct {
+	Status    string         `json:"status"`
+	Timestamp time.Time      `json:"timestamp"`
+	Routes    []*RouteHealth `json:"routes,omitempty"`
+}
+
+// RouteHealth is the health of an API definition upstream targets
+type RouteHealth struct {
+	Name     string   `json:"name"`
+	Targets  []string `json:"targets"`
+	Critical bool     `json:"critical"`
+	Status   string   `json:"status"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// NewHealthHandler creates instance of the gateway health handler. The gateway is unavailable when
+// all the critical upstreams are down, the non critical ones never change the gateway health.
+// The detailed report lists the health of every API definition with a health check. The results are
+// kept as the upstream health when the upstream states are set. The report of the health checks
+// leader is served when the results are shared, the upstreams are checked by the handler only when
+// there is none yet.
+func NewHealthHandler(cfgs *api.Configuration, detailed bool, states *upstream.States, results HealthResults) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := sharedHealthReport(results)
+		if report == nil {
+			report = checkHealth(findValidAPIHealthChecks(cfgs.Definitions))
+		}
+		if states != nil {
+			for _, route := range report.Routes {
+				states.SetHealth(route.Name, route.Status != HealthUnavailable)
+			}
+		}
+
+		status := http.StatusOK
+		if report.Status == HealthUnavailable {
+			status = http.StatusServiceUnavailable
+		}
+
+		if !detailed {
+			report.Routes = nil
+		}
+
+		render.JSON(w, status, report)
+	}
+}
+
+// sharedHealthReport returns the report of the health checks leader, it returns nil when the results
+// are not shared or not available
+func sharedHealthReport(results HealthResults) *HealthReport {
+	if results == nil {
+		return nil
+	}
+
+	report, err := results.Get()
+	if err != nil {
+		log.WithError(err).Error("Could not get the shared health report, checking the upstreams")
+		return nil
+	}
+	if report == nil {
+		log.Debug("No shared health report yet, checking the upstreams")
+	}
+
+	return report
+}
+
+func checkHealth(defs []*api.Definition) *HealthReport {
+	report := &HealthReport{Status: HealthOK, Timestamp: time.Now(), Routes: make([]*RouteHealth, len(defs))}
+
+	var wg sync.WaitGroup
+	for i, def := range defs {
+		wg.Add(1)
+		go func(i int, def *api.Definition) {
+			defer wg.Done()
+			report.Routes[i] = checkRouteHealth(def)
+		}(i, def)
+	}
+	wg.Wait()
+
+	criticalDown, critical := 0, 0
+	for _, route := range report.Routes {
+		if route.Status != HealthOK && report.Status == HealthOK {
+			report.Status = HealthPartial
+		}
+
+		if route.Critical {
+			critical++
+			if route.Status == HealthUnavailable {
+				criticalDown++
+			}
+		}
+	}
+
+	if critical > 0 && criticalDown == critical {
+		report.Status = HealthUnavailable
+	}
+
+	return report
+}
+
+func checkRouteHealth(def *api.Definition) *RouteHealth {
+	route := &RouteHealth{Name: def.Name, Targets: []string{}, Critical: def.HealthCheck.Critical, Status: HealthOK}
+	if def.Proxy != nil && def.Proxy.Upstreams != nil {
+		for _, target := range def.Proxy.Upstreams.Targets {
+			route.Targets = append(route.Targets, target.Target)
+		}
+	}
+
+	timeout := time.Duration(def.HealthCheck.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultHealthTimeout
+	}
+
+	req, err := http.NewRequest(http.MethodGet, def.HealthCheck.URL, nil)
+	if err != nil {
+		route.Status, route.Error = HealthUnavailable, err.Error()
+		return route
+	}
+	// Inform to close the connection after the transaction is complete
+	req.Header.Set("Connection", "close")
+
+	resp, err := (&http.Client{Timeout: timeout}).Do(req)
+	if err != nil {
+		log.WithError(err).WithField("name", def.Name).Debug("Health check endpoint is unreachable")
+		route.Status, route.Error = HealthUnavailable, "health check endpoint is unreachable"
+		return route
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= http.StatusInternalServerError:
+		route.Status, route.Error = HealthUnavailable, http.StatusText(resp.StatusCode)
+	case resp.StatusCode >= http.StatusBadRequest:
+		route.Status, route.Error = HealthPartial, http.StatusText(resp.StatusCode)
+	}
+
+	return route
+}
@@ -0,0 +1,149 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/hellofresh/janus/pkg/api"
+	"github.com/hellofresh/janus/pkg/lock"
+	log "github.com/sirupsen/logrus"
+)
+
+// healthLeaderKey is the key of the lock held by the instance running the health checks
+const healthLeaderKey = "leader"
+
+// HealthResults shares the health report of the elected leader with the other instances
+type HealthResults interface {
+	// Get returns the last report, it returns nil when there is none
+	Get() (*HealthReport, error)
+	// Set stores the report for the ttl
+	Set(report *HealthReport, ttl time.Duration) error
+}
+
+// redisHealthResults keeps the health report in redis
+type redisHealthResults struct {
+	client *redis.Client
+	key    string
+}
+
+// NewRedisHealthResults creates HealthResults shared by the Janus instances using the redis client
+func NewRedisHealthResults(client *redis.Client, prefix string) HealthResults {
+	return &redisHealthResults{client: client, key: prefix + ":report"}
+}
+
+func (r *redisHealthResults) Get() (*HealthReport, error) {
+	data, err := r.client.Get(r.key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var report HealthReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
+
+func (r *redisHealthResults) Set(report *HealthReport, ttl time.Duration) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	return r.client.Set(r.key, data, ttl).Err()
+}
+
+// HealthLeader runs the health checks of the upstreams on a single instance, so the probes sent to the
+// upstreams do not grow with the Janus instances. The instances race for the leader lock, the one
+// holding it checks the upstreams every interval and shares the report, and another instance takes the
+// lead once the lock of a dead leader expires.
+type HealthLeader struct {
+	cfgs     *api.Configuration
+	locker   lock.Locker
+	results  HealthResults
+	interval time.Duration
+	lockTTL  time.Duration
+}
+
+// NewHealthLeader creates a new instance of HealthLeader, the lock ttl bounds the time the upstreams
+// are not checked when the leader dies
+func NewHealthLeader(cfgs *api.Configuration, locker lock.Locker, results HealthResults, interval, lockTTL time.Duration) *HealthLeader {
+	return &HealthLeader{cfgs: cfgs, locker: locker, results: results, interval: interval, lockTTL: lockTTL}
+}
+
+// Run takes part in the leader election until the context is done, the lock is released then so
+// another instance takes the lead at once
+func (l *HealthLeader) Run(ctx context.Context) {
+	// the lock is extended well before it expires, and the instances not leading try to take it as often
+	ticker := time.NewTicker(l.lockTTL / 3)
+	defer ticker.Stop()
+
+	var (
+		token   *lock.Token
+		checked time.Time
+	)
+	for {
+		leading := token != nil
+		token = l.lead(token)
+		if token != nil && !leading {
+			// the new leader checks the upstreams at once, the report of the previous one may be old
+			checked = time.Time{}
+		}
+
+		if token != nil && time.Since(checked) >= l.interval {
+			l.check(token)
+			checked = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			if token != nil {
+				l.locker.Unlock(token)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// lead extends the lock of the leader, or tries to take it, it returns nil when the instance does not
+// lead
+func (l *HealthLeader) lead(token *lock.Token) *lock.Token {
+	if token != nil {
+		err := l.locker.Extend(token, l.lockTTL)
+		if err == nil {
+			return token
+		}
+		log.WithError(err).Warn("Lost the health checks leader lock")
+	}
+
+	token, err := l.locker.Lock(healthLeaderKey, l.lockTTL)
+	if err != nil {
+		log.WithError(err).Error("Could not take the health checks leader lock")
+		return nil
+	}
+	if token != nil {
+		log.Info("Elected as the health checks leader")
+	}
+
+	return token
+}
+
+// check runs the health checks and shares the report, it is kept until another leader can replace it
+func (l *HealthLeader) check(token *lock.Token) {
+	report := checkHealth(findValidAPIHealthChecks(l.cfgs.Definitions))
+	if !token.Valid() {
+		log.Warn("The health checks leader lock expired while checking the upstreams, the report is not shared")
+		return
+	}
+
+	if err := l.results.Set(report, l.interval+l.lockTTL); err != nil {
+		log.WithError(err).Error("Could not share the health report")
+	}
+}
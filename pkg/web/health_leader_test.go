@@ -0,0 +1,164 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hellofresh/janus/pkg/api"
+	"github.com/hellofresh/janus/pkg/lock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryHealthResults shares the health report between the leaders of a test
+type memoryHealthResults struct {
+	sync.Mutex
+	data []byte
+}
+
+func (r *memoryHealthResults) Get() (*HealthReport, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.data == nil {
+		return nil, nil
+	}
+
+	var report HealthReport
+	err := json.Unmarshal(r.data, &report)
+	return &report, err
+}
+
+func (r *memoryHealthResults) Set(report *HealthReport, ttl time.Duration) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	r.Lock()
+	r.data = data
+	r.Unlock()
+	return nil
+}
+
+// countingUpstream counts the health checks it answers
+func countingUpstream(probes *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(probes, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("the condition is not met")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestHealthLeaderSingleProber(t *testing.T) {
+	var probes int32
+	upstream := countingUpstream(&probes)
+	defer upstream.Close()
+
+	cfgs := &api.Configuration{Definitions: []*api.Definition{newHealthDefinition("users", upstream.URL, true)}}
+	locker := lock.NewMemoryLocker()
+	results := &memoryHealthResults{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for i := 0; i < 3; i++ {
+		go NewHealthLeader(cfgs, locker, results, time.Hour, time.Minute).Run(ctx)
+	}
+
+	waitFor(t, func() bool { return atomic.LoadInt32(&probes) > 0 })
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&probes), "a single instance checks the upstreams")
+
+	w := httptest.NewRecorder()
+	NewHealthHandler(cfgs, true, nil, results)(w, httptest.NewRequest(http.MethodGet, "/health/detail", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&probes), "the shared report is served without checking the upstreams")
+
+	var report HealthReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	require.Len(t, report.Routes, 1)
+	assert.Equal(t, HealthUnavailable, report.Routes[0].Status)
+}
+
+func TestHealthLeaderTakeOver(t *testing.T) {
+	var probes int32
+	upstream := countingUpstream(&probes)
+	defer upstream.Close()
+
+	cfgs := &api.Configuration{Definitions: []*api.Definition{newHealthDefinition("users", upstream.URL, true)}}
+	locker := lock.NewMemoryLocker()
+	results := &memoryHealthResults{}
+
+	// the leader dies without releasing the lock, it is taken over once the lock expires
+	stale, err := locker.Lock(healthLeaderKey, 60*time.Millisecond)
+	require.NoError(t, err)
+	require.NotNil(t, stale)
+	elected := time.Now()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go NewHealthLeader(cfgs, locker, results, time.Hour, 60*time.Millisecond).Run(ctx)
+
+	waitFor(t, func() bool { return atomic.LoadInt32(&probes) > 0 })
+	assert.True(t, time.Since(elected) >= 60*time.Millisecond, "the lock of the dead leader is not taken before it expires")
+	assert.True(t, time.Since(elected) < time.Second, "the lead is taken over within the lock ttl")
+
+	report, err := results.Get()
+	require.NoError(t, err)
+	require.NotNil(t, report)
+	assert.Equal(t, HealthUnavailable, report.Status)
+}
+
+func TestHealthLeaderReleasesLock(t *testing.T) {
+	locker := lock.NewMemoryLocker()
+	leader := NewHealthLeader(&api.Configuration{}, locker, &memoryHealthResults{}, time.Hour, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		leader.Run(ctx)
+		close(done)
+	}()
+
+	waitFor(t, func() bool {
+		token, _ := locker.Lock(healthLeaderKey, time.Minute)
+		if token != nil {
+			locker.Unlock(token)
+		}
+		return token == nil
+	})
+	cancel()
+	<-done
+
+	token, err := locker.Lock(healthLeaderKey, time.Minute)
+	require.NoError(t, err)
+	assert.NotNil(t, token, "the stopped leader releases the lock")
+}
+
+func TestHealthHandlerWithoutSharedReport(t *testing.T) {
+	var probes int32
+	upstream := countingUpstream(&probes)
+	defer upstream.Close()
+
+	cfgs := &api.Configuration{Definitions: []*api.Definition{newHealthDefinition("users", upstream.URL, true)}}
+	w := httptest.NewRecorder()
+	NewHealthHandler(cfgs, false, nil, &memoryHealthResults{})(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&probes), "the upstreams are checked until a report is shared")
+}
@@ -0,0 +1,87 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hellofresh/janus/pkg/api"
+	"github.com/hellofresh/janus/pkg/proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newHealthDefinition(name string, url string, critical bool) *api.Definition {
+	def := api.NewDefinition()
+	def.Name = name
+	def.Active = true
+	def.Proxy.Upstreams.Targets = proxy.Targets{{Target: "http://" + name + ".local"}}
+	def.HealthCheck = api.HealthCheck{URL: url, Timeout: 1, Critical: critical}
+
+	return def
+}
+
+func newUpstream(status int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+}
+
+func doHealth(t *testing.T, cfgs *api.Configuration, detailed bool) (int, HealthReport) {
+	w := httptest.NewRecorder()
+	NewHealthHandler(cfgs, detailed, nil, nil)(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	var report HealthReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+
+	return w.Code, report
+}
+
+func TestHealthNonCriticalDown(t *testing.T) {
+	up := newUpstream(http.StatusOK)
+	defer up.Close()
+	down := newUpstream(http.StatusInternalServerError)
+	defer down.Close()
+
+	cfgs := &api.Configuration{Definitions: []*api.Definition{
+		newHealthDefinition("critical", up.URL, true),
+		newHealthDefinition("optional", down.URL, false),
+	}}
+
+	code, report := doHealth(t, cfgs, false)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, HealthPartial, report.Status)
+	assert.Empty(t, report.Routes)
+}
+
+func TestHealthAllCriticalDown(t *testing.T) {
+	up := newUpstream(http.StatusOK)
+	defer up.Close()
+	down := newUpstream(http.StatusServiceUnavailable)
+	defer down.Close()
+
+	cfgs := &api.Configuration{Definitions: []*api.Definition{
+		newHealthDefinition("first", down.URL, true),
+		newHealthDefinition("second", "http://127.0.0.1:1/unreachable", true),
+		newHealthDefinition("optional", up.URL, false),
+	}}
+
+	code, report := doHealth(t, cfgs, true)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, HealthUnavailable, report.Status)
+
+	require.Len(t, report.Routes, 3)
+	assert.Equal(t, "first", report.Routes[0].Name)
+	assert.Equal(t, []string{"http://first.local"}, report.Routes[0].Targets)
+	assert.True(t, report.Routes[0].Critical)
+	assert.Equal(t, HealthUnavailable, report.Routes[0].Status)
+	assert.Equal(t, HealthUnavailable, report.Routes[1].Status)
+	assert.Equal(t, HealthOK, report.Routes[2].Status)
+}
+
+func TestHealthWithoutChecks(t *testing.T) {
+	code, report := doHealth(t, &api.Configuration{}, true)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, HealthOK, report.Status)
+}
@@ -0,0 +1,47 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/hellofresh/janus/pkg/errors"
+	"github.com/hellofresh/janus/pkg/render"
+	log "github.com/sirupsen/logrus"
+)
+
+// LogLevel represents the level of the logs of the process
+type LogLevel struct {
+	Level string `json:"level"`
+}
+
+// NewGetLogLevelHandler creates the handler reading the level of the standard logger
+func NewGetLogLevelHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, http.StatusOK, LogLevel{Level: log.GetLevel().String()})
+	}
+}
+
+// NewPutLogLevelHandler creates the handler setting the level of the standard logger, the level takes
+// effect right away for the whole process until it is set again or the process restarts
+func NewPutLogLevelHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var level LogLevel
+		if err := json.NewDecoder(r.Body).Decode(&level); err != nil {
+			errors.Handler(w, errors.New(http.StatusBadRequest, err.Error()))
+			return
+		}
+
+		parsed, err := log.ParseLevel(level.Level)
+		if err != nil {
+			errors.Handler(w, errors.New(http.StatusBadRequest, err.Error()))
+			return
+		}
+
+		previous := log.GetLevel()
+		log.SetLevel(parsed)
+		// logged at the warning level, so the changes are kept whatever the new level is
+		log.WithFields(log.Fields{"previous": previous.String(), "level": parsed.String()}).Warn("The log level was changed")
+
+		render.JSON(w, http.StatusOK, LogLevel{Level: parsed.String()})
+	}
+}
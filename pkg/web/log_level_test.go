@@ -0,0 +1,79 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	baseJWT "github.com/dgrijalva/jwt-go"
+	"github.com/hellofresh/janus/pkg/config"
+	"github.com/hellofresh/janus/pkg/jwt"
+	"github.com/hellofresh/janus/pkg/router"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogLevelHandlers(t *testing.T) {
+	defer log.SetLevel(log.GetLevel())
+	log.SetLevel(log.InfoLevel)
+
+	put := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		NewPutLogLevelHandler()(w, httptest.NewRequest(http.MethodPut, "/log/level", strings.NewReader(body)))
+		return w
+	}
+	get := func() LogLevel {
+		w := httptest.NewRecorder()
+		NewGetLogLevelHandler()(w, httptest.NewRequest(http.MethodGet, "/log/level", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var level LogLevel
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &level))
+		return level
+	}
+
+	assert.Equal(t, LogLevel{Level: "info"}, get())
+
+	w := put(`{"level":"debug"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"level":"debug"}`, w.Body.String())
+	assert.Equal(t, log.DebugLevel, log.GetLevel(), "the standard logger level is set right away")
+	assert.Equal(t, LogLevel{Level: "debug"}, get())
+
+	assert.Equal(t, http.StatusOK, put(`{"level":"info"}`).Code)
+	assert.Equal(t, log.InfoLevel, log.GetLevel(), "the level can be set back")
+
+	assert.Equal(t, http.StatusBadRequest, put(`{"level":"verbose"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, put(`{"level":""}`).Code)
+	assert.Equal(t, http.StatusBadRequest, put(`level=debug`).Code)
+	assert.Equal(t, log.InfoLevel, log.GetLevel(), "the unknown levels are rejected")
+}
+
+func TestLogLevelRoutes(t *testing.T) {
+	defer log.SetLevel(log.GetLevel())
+
+	token, err := jwt.IssueAdminToken(jwt.SigningMethod{Alg: "HS256", Key: "secret"}, baseJWT.MapClaims{"sub": "admin"}, time.Hour)
+	require.NoError(t, err)
+
+	r := router.NewChiRouter()
+	New(WithCredentials(config.Credentials{Algorithm: "HS256", Secret: "secret"})).AddRoutes(r)
+	put := func(authorized bool) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, "/log/level", strings.NewReader(`{"level":"debug"}`))
+		if authorized {
+			req.Header.Set("Authorization", "Bearer "+token.Token)
+		}
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	log.SetLevel(log.InfoLevel)
+	assert.Equal(t, http.StatusUnauthorized, put(false))
+	assert.Equal(t, log.InfoLevel, log.GetLevel())
+	assert.Equal(t, http.StatusOK, put(true))
+	assert.Equal(t, log.DebugLevel, log.GetLevel())
+}
@@ -1,8 +1,16 @@
 package web
 
 import (
+	"os"
+
 	"github.com/hellofresh/janus/pkg/api"
+	"github.com/hellofresh/janus/pkg/audit"
 	"github.com/hellofresh/janus/pkg/config"
+	"github.com/hellofresh/janus/pkg/drain"
+	"github.com/hellofresh/janus/pkg/jwt"
+	"github.com/hellofresh/janus/pkg/maintenance"
+	"github.com/hellofresh/janus/pkg/sampling"
+	"github.com/hellofresh/janus/pkg/upstream"
 )
 
 // Option represents the available options
@@ -22,10 +30,20 @@ func WithPort(port int) Option {
 	}
 }
 
+// WithListen sets the address the server listens on instead of the port, "unix:/path/to/sock"
+// listens on the Unix domain socket created with the mode
+func WithListen(address string, mode os.FileMode) Option {
+	return func(s *Server) {
+		s.Listen = address
+		s.socketMode = mode
+	}
+}
+
 // WithCredentials sets the credentials for the server
 func WithCredentials(cred config.Credentials) Option {
 	return func(s *Server) {
 		s.Credentials = cred
+		s.apiHandler.rbac = jwt.NewRBACMiddleware(cred.RBAC)
 	}
 }
 
@@ -43,3 +61,90 @@ func WithProfiler(enabled, public bool) Option {
 		s.profilingPublic = public
 	}
 }
+
+// WithAuditTrail sets the audit trail of the configuration changes
+func WithAuditTrail(trail *audit.Trail) Option {
+	return func(s *Server) {
+		s.auditTrail = trail
+		s.apiHandler.auditTrail = trail
+	}
+}
+
+// WithMetricsPath sets the path serving the prometheus metrics, it defaults to "/metrics"
+func WithMetricsPath(path string) Option {
+	return func(s *Server) {
+		if path != "" {
+			s.metricsPath = path
+		}
+	}
+}
+
+// WithMaintenance sets the maintenance modes of the APIs toggled by the maintenance endpoints
+func WithMaintenance(modes *maintenance.Modes) Option {
+	return func(s *Server) {
+		s.apiHandler.maintenance = modes
+	}
+}
+
+// WithWeights sets the upstream target weights of the APIs adjusted by the weights endpoints
+func WithWeights(weights *upstream.Weights) Option {
+	return func(s *Server) {
+		s.apiHandler.weights = weights
+	}
+}
+
+// WithSamplingRates sets the trace sampling rates of the APIs changed by the sampling endpoints
+func WithSamplingRates(rates *sampling.Rates) Option {
+	return func(s *Server) {
+		s.apiHandler.samplingRates = rates
+	}
+}
+
+// WithUpstreamStates sets the runtime state of the upstream targets listed by the upstreams endpoint
+func WithUpstreamStates(states *upstream.States) Option {
+	return func(s *Server) {
+		s.upstreams = states
+	}
+}
+
+// WithReadiness sets the readiness reported by the readiness probe
+func WithReadiness(readiness *Readiness) Option {
+	return func(s *Server) {
+		s.readiness = readiness
+	}
+}
+
+// WithDrainer sets the drain state of the gateway toggled by the drain endpoints
+func WithDrainer(drainer *drain.Drainer) Option {
+	return func(s *Server) {
+		s.drainer = drainer
+	}
+}
+
+// WithListeners sets the names of the proxy listeners besides the default one, the definitions bound to
+// other listeners are rejected
+func WithListeners(names []string) Option {
+	return func(s *Server) {
+		s.apiHandler.listeners = names
+	}
+}
+
+// WithHealthResults sets the health report shared by the health checks leader, the health endpoints
+// serve it instead of checking the upstreams
+func WithHealthResults(results HealthResults) Option {
+	return func(s *Server) {
+		s.healthResults = results
+	}
+}
+
+// WithProbePaths sets the paths of the liveness and readiness probes, they default to "/live" and "/ready"
+func WithProbePaths(livePath, readyPath string) Option {
+	return func(s *Server) {
+		if livePath != "" {
+			s.livePath = livePath
+		}
+		if readyPath != "" {
+			s.readyPath = readyPath
+		}
+	}
+}
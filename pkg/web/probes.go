@@ -0,0 +1,93 @@
+package web
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/hellofresh/janus/pkg/render"
+	log "github.com/sirupsen/logrus"
+)
+
+// Default probe paths
+const (
+	DefaultLivePath  = "/live"
+	DefaultReadyPath = "/ready"
+)
+
+// ReadinessCheck checks a dependency the gateway needs to serve the requests
+type ReadinessCheck struct {
+	Name  string
+	Check func() error
+}
+
+// Readiness tells whether the gateway is ready to serve the requests. It is not ready until the
+// configuration is loaded, while a dependency is unreachable and once the shutdown has started.
+type Readiness struct {
+	ready  int32
+	checks []ReadinessCheck
+}
+
+type probeResponse struct {
+	Status    string            `json:"status"`
+	Timestamp time.Time         `json:"timestamp"`
+	Failures  map[string]string `json:"failures,omitempty"`
+}
+
+// NewReadiness creates a new instance of Readiness, it is not ready until SetReady is called
+func NewReadiness(checks ...ReadinessCheck) *Readiness {
+	return &Readiness{checks: checks}
+}
+
+// SetReady sets whether the gateway is ready, e.g. false to drain the traffic before shutting down
+func (r *Readiness) SetReady(ready bool) {
+	var value int32
+	if ready {
+		value = 1
+	}
+	atomic.StoreInt32(&r.ready, value)
+}
+
+// IsReady returns whether the gateway has been set ready
+func (r *Readiness) IsReady() bool {
+	return atomic.LoadInt32(&r.ready) == 1
+}
+
+// NewLiveHandler creates instance of the liveness probe handler, it responds as long as the
+// process serves the requests
+func NewLiveHandler() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, http.StatusOK, probeResponse{Status: HealthOK, Timestamp: time.Now()})
+	}
+}
+
+// NewReadyHandler creates instance of the readiness probe handler
+func NewReadyHandler(readiness *Readiness) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response := probeResponse{Status: HealthOK, Timestamp: time.Now()}
+
+		if readiness == nil || !readiness.IsReady() {
+			response.Status = HealthUnavailable
+			render.JSON(w, http.StatusServiceUnavailable, response)
+			return
+		}
+
+		for _, check := range readiness.checks {
+			if err := check.Check(); err != nil {
+				log.WithError(err).WithField("name", check.Name).Warn("Readiness check failed")
+				if response.Failures == nil {
+					response.Failures = make(map[string]string)
+				}
+				response.Failures[check.Name] = err.Error()
+			}
+		}
+
+		if len(response.Failures) > 0 {
+			response.Status = HealthUnavailable
+			render.JSON(w, http.StatusServiceUnavailable, response)
+			return
+		}
+
+		render.JSON(w, http.StatusOK, response)
+	}
+}
@@ -0,0 +1,46 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func doProbe(handler func(w http.ResponseWriter, r *http.Request)) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	return w
+}
+
+func TestLiveHandler(t *testing.T) {
+	assert.Equal(t, http.StatusOK, doProbe(NewLiveHandler()).Code)
+}
+
+func TestReadyHandler(t *testing.T) {
+	var dbErr error
+	readiness := NewReadiness(ReadinessCheck{Name: "database", Check: func() error { return dbErr }})
+	handler := NewReadyHandler(readiness)
+
+	assert.Equal(t, http.StatusServiceUnavailable, doProbe(handler).Code, "not ready until the configuration is loaded")
+
+	readiness.SetReady(true)
+	assert.Equal(t, http.StatusOK, doProbe(handler).Code)
+
+	dbErr = errors.New("no reachable servers")
+	w := doProbe(handler)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "no reachable servers")
+
+	dbErr = nil
+	readiness.SetReady(false)
+	assert.Equal(t, http.StatusServiceUnavailable, doProbe(handler).Code, "not ready while shutting down")
+	assert.Equal(t, http.StatusOK, doProbe(NewLiveHandler()).Code)
+}
+
+func TestReadyHandlerWithoutReadiness(t *testing.T) {
+	assert.Equal(t, http.StatusServiceUnavailable, doProbe(NewReadyHandler(nil)).Code)
+}
@@ -2,18 +2,24 @@ package web
 
 import (
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/pprof"
+	"os"
 
 	chiMiddleware "github.com/go-chi/chi/middleware"
 	"github.com/hellofresh/janus/pkg/api"
+	"github.com/hellofresh/janus/pkg/audit"
 	"github.com/hellofresh/janus/pkg/config"
+	"github.com/hellofresh/janus/pkg/drain"
 	httpErrors "github.com/hellofresh/janus/pkg/errors"
 	"github.com/hellofresh/janus/pkg/jwt"
+	"github.com/hellofresh/janus/pkg/listener"
 	"github.com/hellofresh/janus/pkg/middleware"
 	obs "github.com/hellofresh/janus/pkg/observability"
 	"github.com/hellofresh/janus/pkg/plugin"
 	"github.com/hellofresh/janus/pkg/router"
+	"github.com/hellofresh/janus/pkg/upstream"
 	"github.com/rs/cors"
 	log "github.com/sirupsen/logrus"
 )
@@ -21,12 +27,23 @@ import (
 // Server represents the web server
 type Server struct {
 	Port              int
+	Listen            string
 	Credentials       config.Credentials
 	TLS               config.TLS
 	ConfigurationChan chan api.ConfigurationMessage
 	apiHandler        *APIHandler
+	auditTrail        *audit.Trail
 	profilingEnabled  bool
 	profilingPublic   bool
+	metricsPath       string
+	readiness         *Readiness
+	livePath          string
+	readyPath         string
+	socketMode        os.FileMode
+	listener          net.Listener
+	upstreams         *upstream.States
+	healthResults     HealthResults
+	drainer           *drain.Drainer
 }
 
 // New creates a new web server
@@ -35,6 +52,9 @@ func New(opts ...Option) *Server {
 	s := Server{
 		ConfigurationChan: cfgChan,
 		apiHandler:        NewAPIHandler(cfgChan),
+		metricsPath:       obs.DefaultPrometheusPath,
+		livePath:          DefaultLivePath,
+		readyPath:         DefaultReadyPath,
 	}
 
 	for _, opt := range opts {
@@ -49,6 +69,19 @@ func (s *Server) Start() error {
 	log.Info("Janus Admin API starting...")
 	router.DefaultOptions.NotFoundHandler = httpErrors.NotFound
 	r := router.NewChiRouterWithOptions(router.DefaultOptions)
+
+	if !s.TLS.IsHTTPS() || s.TLS.Redirect {
+		address := s.Listen
+		if address == "" {
+			address = fmt.Sprintf(":%v", s.Port)
+		}
+
+		ln, err := listener.New(address, s.socketMode)
+		if err != nil {
+			return httpErrors.Wrap(err, "could not open the admin API listener")
+		}
+		s.listener = ln
+	}
 	go s.listenAndServe(r)
 
 	s.AddRoutes(r)
@@ -60,6 +93,10 @@ func (s *Server) Start() error {
 // Stop stops the server
 func (s *Server) Stop() {
 	close(s.ConfigurationChan)
+	// closing the listener removes the unix socket file
+	if s.listener != nil {
+		s.listener.Close()
+	}
 }
 
 // AddRoutes adds the admin routes
@@ -88,8 +125,12 @@ func (s *Server) addInternalPublicRoutes(r router.Router) {
 	r.GET("/", Home())
 	r.GET("/status", NewOverviewHandler(s.apiHandler.Cfgs))
 	r.GET("/status/{name}", NewStatusHandler(s.apiHandler.Cfgs))
-	if obs.PrometheusExporter != nil {
-		r.Any("/metrics", obs.PrometheusExporter.ServeHTTP)
+	r.GET("/health", NewHealthHandler(s.apiHandler.Cfgs, false, s.upstreams, s.healthResults))
+	r.GET("/health/detail", NewHealthHandler(s.apiHandler.Cfgs, true, s.upstreams, s.healthResults))
+	r.GET(s.livePath, NewLiveHandler())
+	r.GET(s.readyPath, NewReadyHandler(s.readiness))
+	if obs.PrometheusHandler != nil {
+		r.Any(s.metricsPath, obs.PrometheusHandler.ServeHTTP)
 	}
 }
 
@@ -107,19 +148,65 @@ func (s *Server) addInternalRoutes(r router.Router, guard jwt.Guard) {
 
 	// APIs endpoints
 	groupAPI := r.Group("/apis")
-	groupAPI.Use(jwt.NewMiddleware(guard).Handler)
+	groupAPI.Use(jwt.NewMiddleware(guard).Handler, jwt.NewRBACMiddleware(s.Credentials.RBAC).Handler)
 	{
 		groupAPI.GET("/", s.apiHandler.Get())
+		groupAPI.GET("/export", s.apiHandler.Export())
 		groupAPI.GET("/{name}", s.apiHandler.GetBy())
 		groupAPI.POST("/", s.apiHandler.Post())
+		groupAPI.POST("/import", s.apiHandler.Import())
+		groupAPI.POST("/validate", s.apiHandler.Validate())
 		groupAPI.PUT("/{name}", s.apiHandler.PutBy())
 		groupAPI.DELETE("/{name}", s.apiHandler.DeleteBy())
+		groupAPI.GET("/{name}/maintenance", s.apiHandler.GetMaintenanceBy())
+		groupAPI.PUT("/{name}/maintenance", s.apiHandler.PutMaintenanceBy())
+		groupAPI.GET("/{name}/weights", s.apiHandler.GetWeightsBy())
+		groupAPI.PUT("/{name}/weights", s.apiHandler.PutWeightsBy())
+		groupAPI.GET("/{name}/plugins/{plugin}", s.apiHandler.GetPluginBy())
+		groupAPI.PUT("/{name}/plugins/{plugin}", s.apiHandler.PutPluginBy())
+		if s.apiHandler.samplingRates != nil {
+			groupAPI.GET("/{name}/sampling", s.apiHandler.GetSamplingBy())
+			groupAPI.PUT("/{name}/sampling", s.apiHandler.PutSamplingBy())
+			groupAPI.DELETE("/{name}/sampling", s.apiHandler.DeleteSamplingBy())
+		}
+	}
+
+	if s.upstreams != nil {
+		groupUpstreams := r.Group("/upstreams")
+		groupUpstreams.Use(jwt.NewMiddleware(guard).Handler, jwt.NewRBACMiddleware(s.Credentials.RBAC).Handler)
+		{
+			groupUpstreams.GET("/", NewUpstreamsHandler(s.apiHandler.Cfgs, s.upstreams))
+		}
+	}
+
+	if s.auditTrail != nil {
+		groupAudit := r.Group("/audit")
+		groupAudit.Use(jwt.NewMiddleware(guard).Handler, jwt.NewRBACMiddleware(s.Credentials.RBAC).Handler)
+		{
+			groupAudit.GET("/", NewAuditHandler(s.auditTrail))
+		}
+	}
+
+	groupLog := r.Group("/log")
+	groupLog.Use(jwt.NewMiddleware(guard).Handler, jwt.NewRBACMiddleware(s.Credentials.RBAC).Handler)
+	{
+		groupLog.GET("/level", NewGetLogLevelHandler())
+		groupLog.PUT("/level", NewPutLogLevelHandler())
+	}
+
+	if s.drainer != nil {
+		authenticated := []router.Constructor{jwt.NewMiddleware(guard).Handler, jwt.NewRBACMiddleware(s.Credentials.RBAC).Handler}
+		r.GET("/drain", NewGetDrainHandler(s.drainer), authenticated...)
+		r.POST("/drain", NewDrainHandler(s.drainer), authenticated...)
+		r.POST("/undrain", NewUndrainHandler(s.drainer), authenticated...)
 	}
 
 	if s.profilingEnabled {
 		groupProfiler := r.Group("/debug/pprof")
-		if !s.profilingPublic {
-			groupProfiler.Use(jwt.NewMiddleware(guard).Handler)
+		if s.profilingPublic {
+			log.Warn("The profiler is served without authentication, it exposes the memory and the command line of the process")
+		} else {
+			groupProfiler.Use(jwt.NewMiddleware(guard).Handler, jwt.NewRBACMiddleware(s.Credentials.RBAC).Handler)
 		}
 		{
 			groupProfiler.GET("/*", pprof.Index)
@@ -132,15 +219,13 @@ func (s *Server) addInternalRoutes(r router.Router, guard jwt.Guard) {
 }
 
 func (s *Server) listenAndServe(handler http.Handler) error {
-	address := fmt.Sprintf(":%v", s.Port)
-
 	log.Info("Janus Admin API started")
 	if s.TLS.IsHTTPS() {
 		addressTLS := fmt.Sprintf(":%v", s.TLS.Port)
 		if s.TLS.Redirect {
 			go func() {
-				log.WithField("address", address).Info("Listening HTTP redirects to HTTPS")
-				log.Fatal(http.ListenAndServe(address, RedirectHTTPS(s.TLS.Port)))
+				log.WithField("address", s.listener.Addr().String()).Info("Listening HTTP redirects to HTTPS")
+				log.Fatal(http.Serve(s.listener, RedirectHTTPS(s.TLS.GetRedirectPort(), s.TLS.RedirectStatus)))
 			}()
 		}
 
@@ -148,6 +233,6 @@ func (s *Server) listenAndServe(handler http.Handler) error {
 		return http.ListenAndServeTLS(addressTLS, s.TLS.CertFile, s.TLS.KeyFile, handler)
 	}
 
-	log.WithField("address", address).Info("Certificate and certificate key were not found, defaulting to HTTP")
-	return http.ListenAndServe(address, handler)
+	log.WithField("address", s.listener.Addr().String()).Info("Certificate and certificate key were not found, defaulting to HTTP")
+	return http.Serve(s.listener, handler)
 }
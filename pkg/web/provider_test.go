@@ -0,0 +1,48 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	baseJWT "github.com/dgrijalva/jwt-go"
+	"github.com/hellofresh/janus/pkg/config"
+	"github.com/hellofresh/janus/pkg/jwt"
+	"github.com/hellofresh/janus/pkg/router"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfilerRoutes(t *testing.T) {
+	credentials := config.Credentials{Algorithm: "HS256", Secret: "secret"}
+	token, err := jwt.IssueAdminToken(jwt.SigningMethod{Alg: "HS256", Key: "secret"}, baseJWT.MapClaims{"sub": "admin"}, time.Hour)
+	require.NoError(t, err)
+
+	newRouter := func(opts ...Option) router.Router {
+		r := router.NewChiRouter()
+		New(append(opts, WithCredentials(credentials))...).AddRoutes(r)
+		return r
+	}
+	get := func(r router.Router, path string, authorized bool) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if authorized {
+			req.Header.Set("Authorization", "Bearer "+token.Token)
+		}
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	r := newRouter()
+	assert.Equal(t, http.StatusNotFound, get(r, "/debug/pprof/cmdline", true), "the profiler is not served by default")
+
+	r = newRouter(WithProfiler(true, false))
+	assert.Equal(t, http.StatusUnauthorized, get(r, "/debug/pprof/cmdline", false))
+	assert.Equal(t, http.StatusUnauthorized, get(r, "/debug/pprof/heap", false))
+	assert.Equal(t, http.StatusOK, get(r, "/debug/pprof/cmdline", true))
+	assert.Equal(t, http.StatusOK, get(r, "/debug/pprof/goroutine", true))
+
+	r = newRouter(WithProfiler(true, true))
+	assert.Equal(t, http.StatusOK, get(r, "/debug/pprof/cmdline", false), "the public profiler is served without authentication")
+}
@@ -0,0 +1,17 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/hellofresh/janus/pkg/api"
+	"github.com/hellofresh/janus/pkg/render"
+	"github.com/hellofresh/janus/pkg/upstream"
+)
+
+// NewUpstreamsHandler creates the handler listing the runtime state of the upstream targets of the APIs,
+// their health, circuit breaker state and requests in flight
+func NewUpstreamsHandler(cfgs *api.Configuration, states *upstream.States) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, http.StatusOK, states.List(cfgs.Definitions))
+	}
+}
@@ -0,0 +1,32 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hellofresh/janus/pkg/api"
+	"github.com/hellofresh/janus/pkg/upstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpstreamsHandler(t *testing.T) {
+	ts := newUpstream(http.StatusServiceUnavailable)
+	defer ts.Close()
+
+	states := upstream.NewStates()
+	cfgs := &api.Configuration{Definitions: []*api.Definition{newHealthDefinition("users", ts.URL, true)}}
+
+	// the health checks of the health endpoint set the upstream health
+	NewHealthHandler(cfgs, false, states, nil)(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	w := httptest.NewRecorder()
+	NewUpstreamsHandler(cfgs, states)(w, httptest.NewRequest(http.MethodGet, "/upstreams", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var list []upstream.TargetState
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Equal(t, []upstream.TargetState{{Route: "users", Target: "http://users.local", Health: upstream.HealthDown}}, list)
+}
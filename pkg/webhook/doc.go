@@ -0,0 +1,5 @@
+/*
+Package webhook notifies the configured endpoints when API definitions change. Notifications are
+delivered asynchronously, so they never block applying the configuration.
+*/
+package webhook
@@ -0,0 +1,218 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/hellofresh/janus/pkg/api"
+	"github.com/hellofresh/janus/pkg/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// AddedEvent means a definition was added through the admin API
+	AddedEvent = "added"
+	// UpdatedEvent means a definition was updated through the admin API
+	UpdatedEvent = "updated"
+	// RemovedEvent means a definition was removed through the admin API
+	RemovedEvent = "removed"
+	// ReloadedEvent means the definitions were reloaded from the database
+	ReloadedEvent = "reloaded"
+
+	// SignatureHeader holds the hex encoded HMAC-SHA256 signature of the payload, prefixed with "sha256="
+	SignatureHeader = "X-Janus-Signature"
+
+	queueSize    = 100
+	retryBackoff = 500 * time.Millisecond
+)
+
+// Event is the notification payload
+type Event struct {
+	Type        string    `json:"type"`
+	Definitions []string  `json:"definitions"`
+	Time        time.Time `json:"time"`
+}
+
+// NewEvent creates a new instance of Event
+func NewEvent(eventType string, definitions ...string) Event {
+	return Event{Type: eventType, Definitions: definitions, Time: time.Now()}
+}
+
+// NewOperationEvent creates a new instance of Event for the admin API configuration message
+func NewOperationEvent(msg api.ConfigurationMessage) Event {
+	eventType := AddedEvent
+	switch msg.Operation {
+	case api.UpdatedOperation:
+		eventType = UpdatedEvent
+	case api.RemovedOperation:
+		eventType = RemovedEvent
+	}
+
+	return NewEvent(eventType, msg.Configuration.Name)
+}
+
+// ChangedDefinitions returns the sorted names of the definitions added, removed or changed between the configurations
+func ChangedDefinitions(old, new []*api.Definition) []string {
+	oldDefinitions := make(map[string]*api.Definition, len(old))
+	for _, definition := range old {
+		oldDefinitions[definition.Name] = definition
+	}
+
+	names := make([]string, 0)
+	for _, definition := range new {
+		if oldDefinition, ok := oldDefinitions[definition.Name]; !ok || !reflect.DeepEqual(oldDefinition, definition) {
+			names = append(names, definition.Name)
+		}
+		delete(oldDefinitions, definition.Name)
+	}
+
+	for name := range oldDefinitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Notifier delivers the events to the configured webhook endpoints
+type Notifier struct {
+	config config.Webhooks
+	client *http.Client
+	events chan Event
+}
+
+// NewNotifier creates a new instance of Notifier
+func NewNotifier(cfg config.Webhooks) *Notifier {
+	return &Notifier{
+		config: cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		events: make(chan Event, queueSize),
+	}
+}
+
+// Start delivers the queued events until the context is done
+func (n *Notifier) Start(ctx context.Context) {
+	if len(n.config.URLs) == 0 {
+		return
+	}
+
+	go func() {
+		for {
+			select {
+			case event := <-n.events:
+				n.deliver(ctx, event)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Notify queues the event for the delivery, the event is dropped when the queue is full
+func (n *Notifier) Notify(event Event) {
+	if len(n.config.URLs) == 0 {
+		return
+	}
+
+	select {
+	case n.events <- event:
+	default:
+		log.WithField("type", event.Type).Warn("Webhook queue is full, dropping the event")
+	}
+}
+
+func (n *Notifier) deliver(ctx context.Context, event Event) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.WithError(err).Error("Could not marshal the webhook event")
+		return
+	}
+
+	done := make(chan struct{}, len(n.config.URLs))
+	for _, url := range n.config.URLs {
+		go func(url string) {
+			defer func() { done <- struct{}{} }()
+			n.deliverWithRetries(ctx, url, payload)
+		}(url)
+	}
+
+	for range n.config.URLs {
+		<-done
+	}
+}
+
+func (n *Notifier) deliverWithRetries(ctx context.Context, url string, payload []byte) {
+	logger := log.WithField("url", url)
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		err := n.send(ctx, url, payload)
+		if err == nil {
+			logger.Debug("Webhook delivered")
+			return
+		}
+
+		if _, permanent := err.(permanentError); permanent || attempt >= n.config.Retries {
+			logger.WithError(err).Error("Webhook delivery failed, giving up")
+			return
+		}
+
+		logger.WithError(err).Warn("Webhook delivery failed, retrying")
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (n *Notifier) send(ctx context.Context, url string, payload []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if n.config.Secret != "" {
+		req.Header.Set(SignatureHeader, "sha256="+Sign(n.config.Secret, payload))
+	}
+
+	resp, err := n.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+
+	// client errors will not be fixed by retrying the same request
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return permanentError(fmt.Sprintf("unexpected response status %d", resp.StatusCode))
+	}
+
+	return nil
+}
+
+type permanentError string
+
+func (e permanentError) Error() string {
+	return string(e)
+}
+
+// Sign returns the hex encoded HMAC-SHA256 signature of the payload
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,89 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hellofresh/janus/pkg/api"
+	"github.com/hellofresh/janus/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotifier(t *testing.T) {
+	var attempts int32
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// first attempt fails to check the retry
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	notifier := NewNotifier(config.Webhooks{URLs: []string{ts.URL}, Secret: "secret", Timeout: time.Second, Retries: 1})
+	notifier.Start(ctx)
+	notifier.Notify(NewEvent(UpdatedEvent, "example"))
+
+	select {
+	case r := <-received:
+		body := <-bodies
+		assert.Equal(t, "sha256="+Sign("secret", body), r.Header.Get(SignatureHeader))
+
+		var event Event
+		require.NoError(t, json.Unmarshal(body, &event))
+		assert.Equal(t, UpdatedEvent, event.Type)
+		assert.Equal(t, []string{"example"}, event.Definitions)
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+}
+
+func TestNotifierWithoutURLs(t *testing.T) {
+	notifier := NewNotifier(config.Webhooks{})
+	notifier.Start(context.Background())
+
+	// must not block even when nothing is consuming the events
+	for i := 0; i < queueSize*2; i++ {
+		notifier.Notify(NewEvent(ReloadedEvent))
+	}
+}
+
+func TestChangedDefinitions(t *testing.T) {
+	newDefinition := func(name, listenPath string) *api.Definition {
+		definition := api.NewDefinition()
+		definition.Name = name
+		definition.Proxy.ListenPath = listenPath
+		return definition
+	}
+
+	old := []*api.Definition{newDefinition("same", "/same"), newDefinition("changed", "/changed"), newDefinition("removed", "/removed")}
+	new := []*api.Definition{newDefinition("same", "/same"), newDefinition("changed", "/changed/v2"), newDefinition("added", "/added")}
+
+	assert.Equal(t, []string{"added", "changed", "removed"}, ChangedDefinitions(old, new))
+}
+
+func TestNewOperationEvent(t *testing.T) {
+	definition := api.NewDefinition()
+	definition.Name = "example"
+
+	event := NewOperationEvent(api.ConfigurationMessage{Operation: api.RemovedOperation, Configuration: definition})
+	assert.Equal(t, RemovedEvent, event.Type)
+	assert.Equal(t, []string{"example"}, event.Definitions)
+}